@@ -1,21 +1,75 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/selfupdate"
 	"github.com/sweiss/harcroft/internal/sync"
+	"github.com/sweiss/harcroft/internal/vault"
 )
 
 var (
 	// Flags for init command
-	localPath string
-	scrivPath string
-	alias     string
+	localPath      string
+	scrivPath      string
+	alias          string
+	importExternal bool
+
+	// Flags for sync, pull and push commands
+	planOut    string
+	summaryOut string
+	resume     bool
+	assumeYes  bool
+	fullScan   bool
+	allowTrunc bool
+	force      bool
+	safe       bool
+
+	// Flags for list command
+	listCheck bool
+
+	// Flags for relink command
+	rebaseline bool
+
+	// Flags for mirror command
+	mirrorOut string
+
+	// Flags for status command
+	porcelain bool
+	explain   bool
+
+	// Flags for stats command
+	statsJSON bool
+	statsDays int
+
+	// Flags for grep command
+	grepIgnoreCase bool
+
+	// Flags for outline command
+	outlineFormat  string
+	outlineImport  string
+	outlineProject string
+
+	// Flags for target command
+	targetDraft    int
+	targetSession  int
+	targetDeadline string
+	targetProject  string
+
+	// Flags for labels add command
+	labelColor string
+
+	// Flags for self-update command
+	updateCheck bool
+	updateForce bool
+	updateRepo  string
 
 	// Global flags
+	configPath     string
 	dryRun         bool
 	nonInteractive bool
 	version        = "dev"
@@ -26,6 +80,9 @@ var rootCmd = &cobra.Command{
 	Short:   "Bi-directional sync between Scrivener and markdown",
 	Long:    `A tool for syncing content between Scrivener projects (.scriv) and markdown files.`,
 	Version: version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.SetConfigPath(configPath)
+	},
 }
 
 var initCmd = &cobra.Command{
@@ -75,28 +132,354 @@ Example:
 	RunE: runPush,
 }
 
+var applyCmd = &cobra.Command{
+	Use:   "apply <alias> <plan.json>",
+	Short: "Apply a plan saved with --plan-out",
+	Long: `Apply exactly the operations in a plan saved with --plan-out.
+The plan can be reviewed and edited (e.g. operations removed) first.
+Each operation is revalidated before it runs; operations whose content
+changed on either side since the plan was made are skipped as stale.
+
+Example:
+  scriv-sync sync myproject --dry-run --plan-out plan.json
+  scriv-sync apply myproject plan.json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runApply,
+}
+
 var statusCmd = &cobra.Command{
 	Use:   "status <alias>",
 	Short: "Show pending changes without syncing",
 	Long: `Show the current sync status for a project.
 Lists files that would be created, updated, or are in conflict.
+With --porcelain, prints one tab-separated line per pending operation in a
+format that stays stable across versions, for scripts. With --explain,
+shows under each operation why it is planned and the hashes and times
+compared.
 
 Example:
-  scriv-sync status myproject`,
+  scriv-sync status myproject
+  scriv-sync status myproject --explain
+  scriv-sync status myproject --porcelain`,
 	Args: cobra.ExactArgs(1),
 	RunE: runStatus,
 }
 
+var statsCmd = &cobra.Command{
+	Use:   "stats <alias>",
+	Short: "Show daily word counts",
+	Long: `Show daily word counts from Scrivener's writing history (words written
+in the Draft and elsewhere), alongside the markdown word count recorded by
+each day's last sync. Use --json to export them for charting.
+
+Example:
+  scriv-sync stats myproject
+  scriv-sync stats myproject --json --days 30`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStats,
+}
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <alias> <pattern>",
+	Short: "Search markdown and Scrivener content together",
+	Long: `Search the markdown files and the Scrivener documents (converted to
+markdown) of every mapping for a regular expression. Each matching document
+is listed with the side that matched (markdown, scrivener or both) and
+whether it is in sync, followed by the matching lines; lines both sides
+share are shown once. Useful for finding where an edit lives when the two
+sides have diverged.
+
+Example:
+  scriv-sync grep myproject 'Mrs\. Harcroft'
+  scriv-sync grep myproject -i lighthouse`,
+	Args: cobra.ExactArgs(2),
+	RunE: runGrep,
+}
+
+var outlineCmd = &cobra.Command{
+	Use:   "outline <alias>",
+	Short: "Export the binder as an outline, or apply an edited one",
+	Long: `Print the binder (titles, UUIDs, synopses and word counts) as YAML or
+as a nested markdown list. Edit the outline in a text editor, reordering,
+moving and retitling items and adding folders without a UUID, then apply it
+with --import. Binder items left out of the outline stay where they are;
+nothing is deleted. The Trash is left out.
+
+Example:
+  scriv-sync outline myproject > outline.yaml
+  scriv-sync outline myproject --format markdown > outline.md
+  scriv-sync outline myproject --import outline.md --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runOutline,
+}
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all configured projects",
 	Long: `List all projects configured in ~/.scriv-sync/config.yaml.
+Shows each project's paths, last sync time and tracked file count,
+and flags projects whose Scrivener project can no longer be found.
+Use --check to also scan each project for pending changes.
 
 Example:
-  scriv-sync list`,
+  scriv-sync list
+  scriv-sync list --check`,
 	RunE: runList,
 }
 
+var discoverCmd = &cobra.Command{
+	Use:   "discover [root...]",
+	Short: "Find Scrivener projects and offer to configure them",
+	Long: `Scan common locations (~/Documents, Dropbox, the iCloud Drive Scrivener
+folder) or the given roots for .scriv projects, and offer to run init for
+any that are not yet configured.
+
+Example:
+  scriv-sync discover
+  scriv-sync discover ~/Writing`,
+	RunE: runDiscover,
+}
+
+var newCmd = &cobra.Command{
+	Use:   "new <alias> <mapping> <title>",
+	Short: "Start a new document on both sides of a mapping",
+	Long: `Create a document titled <title> in both the markdown directory and the
+Scrivener folder of a mapping, named by its markdown_dir or
+scrivener_folder, and record the two as in sync. The markdown file starts
+from the mapping's template, with {title} and {date} filled in, or empty
+without one. Fails if either side already has a document by that name.
+
+Example:
+  scriv-sync new myproject chapters "Chapter Twelve"`,
+	Args: cobra.ExactArgs(3),
+	RunE: runNew,
+}
+
+var linksCmd = &cobra.Command{
+	Use:   "links [alias]",
+	Short: "Check markdown links that point into other projects",
+	Long: `Find the links in a project's markdown documents, or those of every
+configured project, that point into another project's markdown, such as a
+novel's links into its worldbuilding wiki. Each target is checked to exist
+and, if it's a document, to be in sync with its Scrivener project. Exits
+with an error if any link's target is missing.
+
+Example:
+  scriv-sync links
+  scriv-sync links mynovel`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLinks,
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <alias>",
+	Short: "Check the integrity of a project's Scrivener files",
+	Long: `Check that every binder item has its content file, every Files/Data
+entry belongs to a binder item, binder UUIDs are unique, and the .scrivx
+file would survive a save without losing anything. Run it before and after
+syncing to catch damage early. Exits with an error if any errors are found.
+
+Example:
+  scriv-sync verify myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVerify,
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [alias]",
+	Short: "Check the config files for mistakes",
+	Long: `Check the global config file and each project's local .scriv-sync.yaml
+for YAML syntax errors, keys that match no setting (most likely typos,
+which loading ignores), values of the wrong type and invalid settings, and
+print each issue with its line number. With an alias, only that project is
+checked. Exits with an error if any errors are found.
+
+Example:
+  scriv-sync config validate
+  scriv-sync config validate myproject`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigValidate,
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <file>",
+	Short: "Print a file sealed by encrypt_state",
+	Long: `Print the decrypted contents of a state file, journal or report that
+scriv-sync sealed because the project sets encrypt_state. The key comes
+from SCRIV_SYNC_KEY or the OS keychain, as when it was sealed. Plain files
+are printed as they are.
+
+Example:
+  scriv-sync decrypt ~/.scriv-sync/reports/myproject/20250101-120000.enc`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDecrypt,
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <alias>",
+	Short: "Show a project's Scrivener metadata",
+	Long: `Print the identifier, the Scrivener version that last saved it, the data
+format (Scrivener 2 or 3), binder statistics and the label and status
+vocabularies of each Scrivener project of an alias. Use it to check the
+right project is configured.
+
+Example:
+  scriv-sync info myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+var targetCmd = &cobra.Command{
+	Use:   "target <alias>",
+	Short: "Show or set a project's Scrivener targets",
+	Long: `Show the draft target, session target and deadline set in Scrivener's
+Project Targets window, or change them. Only the targets are rewritten in
+the .scrivx file; close the project in Scrivener first, or it will save its
+own targets over the change. Progress towards them is shown by stats.
+
+Example:
+  scriv-sync target myproject
+  scriv-sync target myproject --draft 90000 --session 1000
+  scriv-sync target myproject --deadline 2026-06-30
+  scriv-sync target myproject --deadline none`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTarget,
+}
+
+var labelsCmd = vocabularyCmd(sync.VocabLabel, "labels")
+
+var statusesCmd = vocabularyCmd(sync.VocabStatus, "statuses")
+
+// vocabularyCmd builds the command managing the labels or statuses of a
+// project, with its list, add and rename subcommands.
+func vocabularyCmd(kind, plural string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   plural,
+		Short: fmt.Sprintf("List, add and rename a project's Scrivener %s", plural),
+		Long: fmt.Sprintf(`List, add and rename the %s defined in each Scrivener project of an
+alias, so front matter can refer to them. Documents refer to %s by ID,
+so renaming one keeps it on every document. Close the project in Scrivener
+first, or it saves its own %s over the change.
+
+Example:
+  scriv-sync %s list myproject
+  scriv-sync %s add myproject "Needs Research"
+  scriv-sync %s rename myproject "Needs Research" "Research"`, plural, plural, plural, plural, plural, plural),
+	}
+	list := &cobra.Command{
+		Use:   "list <alias>",
+		Short: fmt.Sprintf("List the %s defined in the project", plural),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sync.RunVocabulary(args[0], kind)
+		},
+	}
+	add := &cobra.Command{
+		Use:   "add <alias> <title>",
+		Short: fmt.Sprintf("Define a new %s", kind),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sync.RunAddVocabulary(args[0], kind, args[1], labelColor, dryRun)
+		},
+	}
+	rename := &cobra.Command{
+		Use:   "rename <alias> <old-title> <new-title>",
+		Short: fmt.Sprintf("Rename a %s", kind),
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sync.RunRenameVocabulary(args[0], kind, args[1], args[2], dryRun)
+		},
+	}
+	if kind == sync.VocabLabel {
+		add.Flags().StringVar(&labelColor, "color", "", "color of the new label, as #rrggbb")
+	}
+	cmd.AddCommand(list, add, rename)
+	return cmd
+}
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update scriv-sync to the latest release",
+	Long: `Download the latest scriv-sync release for this platform from GitHub,
+verify it against the release checksums (and their signature, when this
+build has a release key), and replace the running executable with it.
+
+Builds that aren't a tagged release, such as "dev", are only replaced with
+--force.
+
+Example:
+  scriv-sync self-update
+  scriv-sync self-update --check`,
+	Args: cobra.NoArgs,
+	RunE: runSelfUpdate,
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc <alias>",
+	Short: "Remove content files no longer referenced by the binder",
+	Long: `Remove Files/Data entries of a project's Scrivener files whose UUIDs no
+longer appear anywhere in the binder, such as content left behind by
+documents deleted outside of Scrivener. Items in Trash and snapshots are
+kept. Use --dry-run to list what would be removed.
+
+Example:
+  scriv-sync gc myproject --dry-run
+  scriv-sync gc myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGC,
+}
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror <alias>",
+	Short: "Export the whole binder as read-only markdown",
+	Long: `Export the whole binder (except Trash) to a directory tree of markdown,
+for publishing a static snapshot. Folders become directories with an
+index.md holding the folder's synopsis. The export is one-way and does not
+touch sync state. Re-running it replaces the previous export; any other
+non-empty directory is refused.
+
+Example:
+  scriv-sync mirror myproject --out ./site/content`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMirror,
+}
+
+var relinkCmd = &cobra.Command{
+	Use:   "relink <alias>",
+	Short: "Point a project's sync state at its configured Scrivener project",
+	Long: `Point a project's sync state at the Scrivener project in its config,
+after the project was copied (Save As, a cloud service duplicating it) or
+the config was changed to a different project. With --rebaseline the state
+is rebuilt instead: markdown files and documents with identical content are
+recorded as in sync, and everything else is left for the next sync.
+
+Example:
+  scriv-sync relink myproject
+  scriv-sync relink myproject --rebaseline`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRelink,
+}
+
+var importExternalCmd = &cobra.Command{
+	Use:   "import-external <alias>",
+	Short: "Import Scrivener External Folder Sync files as the sync baseline",
+	Long: `Import the files of a Scrivener External Folder Sync folder in a
+project's markdown directory, as init offers to. Each file matched by title
+to a document is written as its markdown file and the original moved to
+.scriv-sync/external-sync/. Run it before the first sync, which would
+otherwise duplicate them.
+
+Example:
+  scriv-sync import-external myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImportExternal,
+}
+
 var removeAliasCmd = &cobra.Command{
 	Use:   "remove-alias <alias>",
 	Short: "Remove a configured project",
@@ -109,20 +492,111 @@ Example:
 	RunE: runRemoveAlias,
 }
 
+var pauseCmd = &cobra.Command{
+	Use:   "pause <alias>",
+	Short: "Pause syncing a project",
+	Long: `Pause syncing a project: sync, pull, push and apply refuse to run for it
+until it is resumed. The project stays configured and is listed as paused.
+
+Example:
+  scriv-sync pause myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <alias>",
+	Short: "Resume syncing a paused project",
+	Long: `Resume syncing a project paused with pause.
+
+Example:
+  scriv-sync resume myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runResume,
+}
+
+var renameCmd = &cobra.Command{
+	Use:   "rename <old-alias> <new-alias>",
+	Short: "Rename a configured project",
+	Long: `Rename a project alias, moving its sync state and reports to the new
+name. Nothing is changed if any of them can't be moved.
+
+Example:
+  scriv-sync rename myproject novel`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRename,
+}
+
 func init() {
 	// Init command flags
 	initCmd.Flags().StringVar(&localPath, "local", "", "path to local markdown directory (required)")
 	initCmd.Flags().StringVar(&scrivPath, "scriv", "", "path to Scrivener .scriv project (required)")
 	initCmd.Flags().StringVar(&alias, "alias", "", "alias name for this project (required)")
+	initCmd.Flags().BoolVar(&importExternal, "import-external-sync", false, "import an External Folder Sync folder in the local path without asking")
 	initCmd.MarkFlagRequired("local")
 	initCmd.MarkFlagRequired("scriv")
 	initCmd.MarkFlagRequired("alias")
 
+	// Plan export flags
+	for _, c := range []*cobra.Command{syncCmd, pullCmd, pushCmd} {
+		c.Flags().StringVar(&planOut, "plan-out", "", "write the computed plan as JSON to this file")
+		c.Flags().BoolVar(&resume, "resume", false, "continue an interrupted sync, reusing its decisions")
+	}
+	for _, c := range []*cobra.Command{syncCmd, pullCmd, pushCmd, applyCmd} {
+		c.Flags().StringVar(&summaryOut, "summary-out", "", "write the run's summary as JSON to this file")
+		c.Flags().BoolVar(&assumeYes, "yes", false, "run a project's first sync, or a plan over impact_gate, without asking for confirmation")
+		c.Flags().BoolVar(&allowTrunc, "allow-truncation", false, "apply updates that empty or drastically shrink a document without asking")
+		c.Flags().BoolVar(&force, "force", false, "write into a Scrivener project with integrity problems, such as duplicate UUIDs")
+		c.Flags().BoolVar(&safe, "safe", false, "never delete or trash anything, nor resolve conflicts by overwriting a side unasked (as options.safe_mode)")
+	}
+	for _, c := range []*cobra.Command{syncCmd, pullCmd, pushCmd, statusCmd} {
+		c.Flags().BoolVar(&fullScan, "full-scan", false, "read and hash every file, ignoring cached hashes")
+	}
+
+	// List command flags
+	listCmd.Flags().BoolVar(&listCheck, "check", false, "scan each project to count pending changes")
+
+	// Status command flags
+	statusCmd.Flags().BoolVar(&porcelain, "porcelain", false, "print a stable, tab-separated line per pending operation")
+	statusCmd.Flags().BoolVar(&explain, "explain", false, "show why each operation is planned")
+
+	// Stats command flags
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "print the stats as JSON")
+	statsCmd.Flags().IntVar(&statsDays, "days", 0, "only show the most recent days (0 for all)")
+
+	// Grep command flags
+	grepCmd.Flags().BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "match regardless of case")
+
+	// Outline command flags
+	outlineCmd.Flags().StringVar(&outlineFormat, "format", "", "yaml or markdown (default yaml, or from the --import file's extension)")
+	outlineCmd.Flags().StringVar(&outlineImport, "import", "", "apply an edited outline file to the binder")
+	outlineCmd.Flags().StringVar(&outlineProject, "project", "", "scriv_projects entry to use instead of scriv_path")
+
+	// Target command flags
+	targetCmd.Flags().IntVar(&targetDraft, "draft", 0, "set the draft target (0 clears it)")
+	targetCmd.Flags().IntVar(&targetSession, "session", 0, "set the session target (0 clears it)")
+	targetCmd.Flags().StringVar(&targetDeadline, "deadline", "", "set the draft deadline (YYYY-MM-DD, or none)")
+	targetCmd.Flags().StringVar(&targetProject, "project", "", "scriv_projects entry to use instead of scriv_path")
+
+	// Self-update command flags
+	selfUpdateCmd.Flags().BoolVar(&updateCheck, "check", false, "only report whether an update is available")
+	selfUpdateCmd.Flags().BoolVar(&updateForce, "force", false, "install the latest release even if it isn't newer")
+	selfUpdateCmd.Flags().StringVar(&updateRepo, "repo", selfupdate.DefaultRepo, "GitHub repository (owner/name) to update from")
+
+	// Mirror command flags
+	mirrorCmd.Flags().StringVar(&mirrorOut, "out", "", "directory to export to (required)")
+	mirrorCmd.MarkFlagRequired("out")
+
+	// Relink command flags
+	relinkCmd.Flags().BoolVar(&rebaseline, "rebaseline", false, "rebuild the sync state instead of re-pointing it")
+
 	// Global flags
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "path to config file (default ~/.scriv-sync/config.yaml, or $SCRIV_SYNC_CONFIG)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "preview changes without applying")
 	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "skip prompts, use config defaults")
 
-	rootCmd.AddCommand(initCmd, syncCmd, pullCmd, pushCmd, statusCmd, listCmd, removeAliasCmd)
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(initCmd, syncCmd, pullCmd, pushCmd, applyCmd, statusCmd, statsCmd, grepCmd, outlineCmd, listCmd, discoverCmd, newCmd, linksCmd, verifyCmd, configCmd, decryptCmd, infoCmd, targetCmd, labelsCmd, statusesCmd, selfUpdateCmd, gcCmd, mirrorCmd, relinkCmd, pauseCmd, resumeCmd, renameCmd, importExternalCmd, removeAliasCmd)
 }
 
 func main() {
@@ -132,19 +606,48 @@ func main() {
 	}
 }
 
+// openSyncer creates the syncer for a project. If its sync state belongs to a
+// different copy of the Scrivener project, the user is asked whether to
+// re-point or rebaseline it first.
+func openSyncer(projectAlias string) (*sync.Syncer, error) {
+	syncer, err := sync.NewSyncerForAlias(projectAlias)
+	var mismatch *sync.ProjectMismatchError
+	if !errors.As(err, &mismatch) || nonInteractive {
+		return syncer, err
+	}
+
+	proceed, err := sync.PromptRelink(mismatch)
+	if err != nil {
+		return nil, err
+	}
+	if !proceed {
+		return nil, fmt.Errorf("aborted")
+	}
+	return sync.NewSyncerForAlias(projectAlias)
+}
+
 func runInit(cmd *cobra.Command, args []string) error {
 	interactive := !nonInteractive
-	return sync.RunInit(alias, localPath, scrivPath, interactive)
+	return sync.RunInit(alias, localPath, scrivPath, interactive, importExternal)
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
 	projectAlias := args[0]
 
-	syncer, err := sync.NewSyncerForAlias(projectAlias)
+	syncer, err := openSyncer(projectAlias)
 	if err != nil {
 		return err
 	}
 
+	syncer.SetPlanOutput(planOut)
+	syncer.SetSummaryOutput(summaryOut)
+	syncer.SetAssumeYes(assumeYes)
+	syncer.SetAllowTruncation(allowTrunc)
+	syncer.SetForce(force)
+	syncer.SetSafeMode(safe)
+	syncer.SetEventHandler(sync.ConsoleRenderer{Out: os.Stdout})
+	syncer.SetResume(resume)
+	syncer.SetFullScan(fullScan)
 	interactive := !nonInteractive
 	return syncer.Sync(dryRun, interactive)
 }
@@ -152,11 +655,20 @@ func runSync(cmd *cobra.Command, args []string) error {
 func runPull(cmd *cobra.Command, args []string) error {
 	projectAlias := args[0]
 
-	syncer, err := sync.NewSyncerForAlias(projectAlias)
+	syncer, err := openSyncer(projectAlias)
 	if err != nil {
 		return err
 	}
 
+	syncer.SetPlanOutput(planOut)
+	syncer.SetSummaryOutput(summaryOut)
+	syncer.SetAssumeYes(assumeYes)
+	syncer.SetAllowTruncation(allowTrunc)
+	syncer.SetForce(force)
+	syncer.SetSafeMode(safe)
+	syncer.SetEventHandler(sync.ConsoleRenderer{Out: os.Stdout})
+	syncer.SetResume(resume)
+	syncer.SetFullScan(fullScan)
 	interactive := !nonInteractive
 	return syncer.Pull(dryRun, interactive)
 }
@@ -164,53 +676,226 @@ func runPull(cmd *cobra.Command, args []string) error {
 func runPush(cmd *cobra.Command, args []string) error {
 	projectAlias := args[0]
 
-	syncer, err := sync.NewSyncerForAlias(projectAlias)
+	syncer, err := openSyncer(projectAlias)
 	if err != nil {
 		return err
 	}
 
+	syncer.SetPlanOutput(planOut)
+	syncer.SetSummaryOutput(summaryOut)
+	syncer.SetAssumeYes(assumeYes)
+	syncer.SetAllowTruncation(allowTrunc)
+	syncer.SetForce(force)
+	syncer.SetSafeMode(safe)
+	syncer.SetEventHandler(sync.ConsoleRenderer{Out: os.Stdout})
+	syncer.SetResume(resume)
+	syncer.SetFullScan(fullScan)
 	interactive := !nonInteractive
 	return syncer.Push(dryRun, interactive)
 }
 
+func runApply(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+
+	syncer, err := openSyncer(projectAlias)
+	if err != nil {
+		return err
+	}
+
+	syncer.SetSummaryOutput(summaryOut)
+	syncer.SetAssumeYes(assumeYes)
+	syncer.SetAllowTruncation(allowTrunc)
+	syncer.SetForce(force)
+	syncer.SetSafeMode(safe)
+	syncer.SetEventHandler(sync.ConsoleRenderer{Out: os.Stdout})
+	interactive := !nonInteractive
+	return syncer.Apply(args[1], dryRun, interactive)
+}
+
 func runStatus(cmd *cobra.Command, args []string) error {
 	projectAlias := args[0]
 
-	syncer, err := sync.NewSyncerForAlias(projectAlias)
+	syncer, err := openSyncer(projectAlias)
+	if err != nil {
+		return err
+	}
+
+	syncer.SetFullScan(fullScan)
+	syncer.SetExplain(explain)
+	return syncer.Status(porcelain)
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+
+	syncer, err := openSyncer(projectAlias)
+	if err != nil {
+		return err
+	}
+
+	return syncer.PrintStats(statsJSON, statsDays)
+}
+
+func runGrep(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+
+	syncer, err := openSyncer(projectAlias)
 	if err != nil {
 		return err
 	}
 
-	return syncer.Status()
+	return syncer.Grep(args[1], grepIgnoreCase)
+}
+
+func runOutline(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	if outlineImport != "" {
+		return sync.RunImportOutline(projectAlias, outlineProject, outlineImport, outlineFormat, dryRun)
+	}
+	return sync.RunOutline(projectAlias, outlineProject, outlineFormat)
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	globalCfg, err := config.LoadGlobal()
+	return sync.RunList(listCheck)
+}
+
+func runDiscover(cmd *cobra.Command, args []string) error {
+	interactive := !nonInteractive
+	return sync.RunDiscover(args, interactive)
+}
+
+func runNew(cmd *cobra.Command, args []string) error {
+	return sync.RunNew(args[0], args[1], args[2])
+}
+
+func runLinks(cmd *cobra.Command, args []string) error {
+	alias := ""
+	if len(args) == 1 {
+		alias = args[0]
+	}
+	return sync.RunLinks(alias)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	return sync.RunVerify(projectAlias)
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	alias := ""
+	if len(args) == 1 {
+		alias = args[0]
+	}
+	return sync.RunValidateConfig(alias)
+}
+
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	data, err := vault.Read(args[0])
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
 
-	aliases := globalCfg.ListProjects()
-	if len(aliases) == 0 {
-		fmt.Println("No projects configured.")
-		fmt.Println("\nTo add a project, run:")
-		fmt.Println("  scriv-sync init --local <path> --scriv <path> --alias <name>")
-		return nil
+func runInfo(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	return sync.RunInfo(projectAlias)
+}
+
+func runTarget(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+
+	var update sync.TargetUpdate
+	if cmd.Flags().Changed("draft") {
+		update.Draft = &targetDraft
+	}
+	if cmd.Flags().Changed("session") {
+		update.Session = &targetSession
 	}
+	if cmd.Flags().Changed("deadline") {
+		deadline, err := sync.ParseDeadline(targetDeadline)
+		if err != nil {
+			return err
+		}
+		update.Deadline = &deadline
+	}
+	return sync.RunTarget(projectAlias, targetProject, update, dryRun)
+}
 
-	fmt.Println("Configured projects:")
-	for _, a := range aliases {
-		proj, _ := globalCfg.GetProject(a)
-		fmt.Printf("  %s\n", a)
-		fmt.Printf("    Local:     %s\n", proj.LocalPath)
-		fmt.Printf("    Scrivener: %s\n", proj.ScrivPath)
-		enabledCount := len(proj.EnabledMappings())
-		fmt.Printf("    Mappings:  %d enabled\n", enabledCount)
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	updater := selfupdate.New(updateRepo)
+	rel, err := updater.Latest()
+	if err != nil {
+		return err
 	}
 
+	newer, known := selfupdate.Newer(rel.Tag, version)
+	switch {
+	case known && !newer && !updateForce:
+		fmt.Printf("scriv-sync %s is up to date (latest release: %s)\n", version, rel.Tag)
+		return nil
+	case !known && !updateForce:
+		fmt.Printf("Latest release: %s. This build (%s) is not a release; rerun with --force to replace it.\n", rel.Tag, version)
+		return nil
+	case updateCheck:
+		fmt.Printf("Update available: %s -> %s. Run scriv-sync self-update to install it.\n", version, rel.Tag)
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("Would update scriv-sync %s to %s\n", version, rel.Tag)
+		return nil
+	}
+
+	fmt.Printf("Downloading scriv-sync %s...\n", rel.Tag)
+	bin, err := updater.Download(rel)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the running executable: %w", err)
+	}
+	if err := selfupdate.Replace(exe, bin); err != nil {
+		return err
+	}
+	fmt.Printf("Updated scriv-sync %s to %s\n", version, rel.Tag)
 	return nil
 }
 
+func runGC(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	interactive := !nonInteractive
+	return sync.RunGC(projectAlias, dryRun, interactive)
+}
+
+func runMirror(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	return sync.RunMirror(projectAlias, mirrorOut, dryRun)
+}
+
+func runRelink(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	return sync.RunRelink(projectAlias, rebaseline)
+}
+
+func runPause(cmd *cobra.Command, args []string) error {
+	return sync.RunSetEnabled(args[0], false)
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	return sync.RunSetEnabled(args[0], true)
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	return sync.RunRenameAlias(args[0], args[1])
+}
+
+func runImportExternal(cmd *cobra.Command, args []string) error {
+	projectAlias := args[0]
+	return sync.RunImportExternalSync(projectAlias)
+}
+
 func runRemoveAlias(cmd *cobra.Command, args []string) error {
 	projectAlias := args[0]
 	return sync.RunRemoveAlias(projectAlias)
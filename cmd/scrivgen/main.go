@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sweiss/harcroft/internal/scrivgen"
+)
+
+var opts = scrivgen.DefaultOptions()
+
+var rootCmd = &cobra.Command{
+	Use:   "scrivgen <path.scriv>",
+	Short: "Generate a synthetic Scrivener project",
+	Long: `Generate a synthetic Scrivener project of a chosen size for
+benchmarking and performance testing.
+
+Example:
+  scrivgen --docs 5000 --folders 50 --size 8 /tmp/Large.scriv`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := scrivgen.Generate(args[0], opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Generated %d documents in %d folders at %s\n", opts.Docs, opts.Folders, path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.Flags().IntVar(&opts.Docs, "docs", opts.Docs, "Number of documents")
+	rootCmd.Flags().IntVar(&opts.Folders, "folders", opts.Folders, "Number of folders under Draft")
+	rootCmd.Flags().IntVar(&opts.DocSizeKB, "size", opts.DocSizeKB, "Approximate size of each document in KB")
+	rootCmd.Flags().Int64Var(&opts.Seed, "seed", opts.Seed, "Seed for generated text")
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
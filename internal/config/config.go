@@ -3,13 +3,49 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
+	"golang.org/x/text/language"
 	"gopkg.in/yaml.v3"
 )
 
+// ConfigEnvVar is the environment variable that overrides the global config file path.
+const ConfigEnvVar = "SCRIV_SYNC_CONFIG"
+
+// LocalConfigName is the name of the optional project-local config file that
+// lives at a project's markdown root and overrides the global settings.
+const LocalConfigName = ".scriv-sync.yaml"
+
+// configPathOverride is set via SetConfigPath (e.g. from the --config flag).
+var configPathOverride string
+
+// SetConfigPath overrides the global config file path. An empty path restores
+// the default lookup (environment variable, then ~/.scriv-sync/config.yaml).
+func SetConfigPath(path string) {
+	configPathOverride = path
+}
+
+// ExpandPath expands environment variables ($VAR or ${VAR}) and a leading ~
+// in a configured path. Paths without either are returned unchanged.
+func ExpandPath(path string) string {
+	path = os.ExpandEnv(path)
+
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return path
+		}
+		return filepath.Join(home, path[1:])
+	}
+
+	return path
+}
+
 // ConfigDir returns the path to the global config directory (~/.scriv-sync/).
 func ConfigDir() (string, error) {
 	home, err := os.UserHomeDir()
@@ -19,8 +55,17 @@ func ConfigDir() (string, error) {
 	return filepath.Join(home, ".scriv-sync"), nil
 }
 
-// ConfigPath returns the path to the global config file.
+// ConfigPath returns the path to the global config file. The --config flag
+// takes precedence over the SCRIV_SYNC_CONFIG environment variable, which
+// takes precedence over ~/.scriv-sync/config.yaml.
 func ConfigPath() (string, error) {
+	if configPathOverride != "" {
+		return filepath.Abs(ExpandPath(configPathOverride))
+	}
+	if env := os.Getenv(ConfigEnvVar); env != "" {
+		return filepath.Abs(ExpandPath(env))
+	}
+
 	dir, err := ConfigDir()
 	if err != nil {
 		return "", err
@@ -28,6 +73,16 @@ func ConfigPath() (string, error) {
 	return filepath.Join(dir, "config.yaml"), nil
 }
 
+// StateName returns the name under which the sync state of one of an
+// alias's Scrivener projects is kept: the alias itself for the project in
+// scriv_path, and "<alias>@<name>" for a project listed in scriv_projects.
+func StateName(alias, project string) string {
+	if project == "" {
+		return alias
+	}
+	return alias + "@" + project
+}
+
 // StatePath returns the path to a project's state file.
 func StatePath(alias string) (string, error) {
 	dir, err := ConfigDir()
@@ -37,36 +92,289 @@ func StatePath(alias string) (string, error) {
 	return filepath.Join(dir, "state", alias+".json"), nil
 }
 
+// JournalPath returns the path to the journal of a project's sync in
+// progress, kept next to its state file.
+func JournalPath(alias string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state", alias+".journal.json"), nil
+}
+
+// ReportsDir returns the directory holding a project's per-run sync reports.
+func ReportsDir(alias string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reports", alias), nil
+}
+
+// RemoteManifestPath returns the path to the record of a project's remote
+// vault as last fetched or uploaded, kept next to its state file.
+func RemoteManifestPath(alias string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state", alias+".remote.json"), nil
+}
+
+// ConversionCacheDir returns the directory caching documents converted to
+// markdown, shared by all projects.
+func ConversionCacheDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cache", "conversions"), nil
+}
+
 // GlobalConfig represents the global configuration with all project aliases.
 type GlobalConfig struct {
 	Version  string                    `yaml:"version"`
 	Projects map[string]*ProjectConfig `yaml:"projects"`
 
 	configPath string
+	issues     []Issue // found when the config was loaded; see Issues
 }
 
 // ProjectConfig represents a single project's sync configuration.
 type ProjectConfig struct {
-	LocalPath      string          `yaml:"local_path"`
-	ScrivPath      string          `yaml:"scriv_path"`
-	FolderMappings []FolderMapping `yaml:"folder_mappings"`
-	Options        Options         `yaml:"options"`
+	LocalPath      string            `yaml:"local_path"`
+	ScrivPath      string            `yaml:"scriv_path"`
+	ScrivProjects  map[string]string `yaml:"scriv_projects,omitempty"` // further Scrivener projects by name, referenced by a mapping's project
+	FolderMappings []FolderMapping   `yaml:"folder_mappings"`
+	Options        Options           `yaml:"options"`
+	Enabled        *bool             `yaml:"enabled,omitempty"` // false pauses syncing; unset means enabled
+	Remote         *Remote           `yaml:"remote,omitempty"`  // remote store holding the markdown vault; local_path is then its working copy
 
 	alias string
 }
 
+// Remote is a store the markdown vault lives on, such as a server-hosted
+// vault. Each run fetches it into local_path first, and sync, pull, push and
+// apply upload what they changed afterwards.
+type Remote struct {
+	Type     string `yaml:"type"`               // webdav | s3
+	URL      string `yaml:"url"`                // https://host/path/ for WebDAV; s3://bucket/prefix for S3
+	Endpoint string `yaml:"endpoint,omitempty"` // S3-compatible endpoint, e.g. https://minio.example.com; AWS by default
+	Region   string `yaml:"region,omitempty"`   // S3 region; us-east-1 by default
+	Username string `yaml:"username,omitempty"` // WebDAV user, or S3 access key ID ($AWS_ACCESS_KEY_ID by default)
+	Password string `yaml:"password,omitempty"` // WebDAV password, or S3 secret key ($AWS_SECRET_ACCESS_KEY by default); may name an environment variable
+}
+
+// Remote store types (Remote.Type).
+const (
+	RemoteWebDAV = "webdav"
+	RemoteS3     = "s3"
+)
+
 // FolderMapping defines a mapping between markdown directory and Scrivener folder.
 type FolderMapping struct {
-	MarkdownDir     string `yaml:"markdown_dir"`
-	ScrivenerFolder string `yaml:"scrivener_folder"`
-	SyncEnabled     bool   `yaml:"sync_enabled"`
+	MarkdownDir     string     `yaml:"markdown_dir"` // relative to local_path, or absolute for a directory elsewhere
+	ScrivenerFolder string     `yaml:"scrivener_folder"`
+	SyncEnabled     bool       `yaml:"sync_enabled"`
+	ExcludeFolders  []string   `yaml:"exclude_folders,omitempty"` // title patterns, e.g. "Front Matter", "*Matter"
+	SectionType     string     `yaml:"section_type,omitempty"`    // section type for documents created by push, e.g. "Scene"
+	Project         string     `yaml:"project,omitempty"`         // name of a scriv_projects entry; empty for scriv_path
+	Titles          TitleRules `yaml:"titles,omitempty"`          // how titles differ from filenames in this mapping
+	Extension       string     `yaml:"extension,omitempty"`       // md | markdown | mdx | txt: extension of the mapping's files; md by default
+	Template        string     `yaml:"template,omitempty"`        // file the new command starts documents from, relative to local_path; {title} and {date} are filled in
+}
+
+// File extensions a mapping's files may have (FolderMapping.Extension). Files
+// with txt hold plain text: documents sync without formatting or front
+// matter, and their text is stored in Scrivener as it is.
+const (
+	ExtensionMD       = "md"
+	ExtensionMarkdown = "markdown"
+	ExtensionMDX      = "mdx"
+	ExtensionText     = "txt"
+)
+
+// TitleRules transform the titles of a mapping's documents and folders
+// between Scrivener and filenames, in both directions, so 07-the-fall.md can
+// be "Chapter 7 — The Fall" in the binder. In prefix and suffix, {n} stands
+// for the number a filename starts with.
+type TitleRules struct {
+	Prefix       string `yaml:"prefix,omitempty"`        // added to titles in Scrivener, left out of filenames, e.g. "Chapter {n} — "
+	Suffix       string `yaml:"suffix,omitempty"`        // as prefix, at the end of titles
+	StripNumbers bool   `yaml:"strip_numbers,omitempty"` // leave filenames' leading numbers out of titles
+	NumberWidth  int    `yaml:"number_width,omitempty"`  // zero-pad numbers put back into filenames to this many digits
+}
+
+// IsZero reports whether the rules leave titles as they are.
+func (t TitleRules) IsZero() bool {
+	return t.Prefix == "" && t.Suffix == "" && !t.StripNumbers
+}
+
+// Numbered reports whether titles lose the number filenames start with.
+func (t TitleRules) Numbered() bool {
+	return t.StripNumbers || strings.Contains(t.Prefix, "{n}") || strings.Contains(t.Suffix, "{n}")
 }
 
 // Options contains sync behavior options.
 type Options struct {
-	CreateMissingFolders      bool   `yaml:"create_missing_folders"`
-	DefaultConflictResolution string `yaml:"default_conflict_resolution"` // prompt | markdown | scrivener | skip
-	DefaultDeletionAction     string `yaml:"default_deletion_action"`     // prompt | delete | recreate | skip
+	CreateMissingFolders      bool            `yaml:"create_missing_folders"`
+	DefaultConflictResolution string          `yaml:"default_conflict_resolution"`  // prompt | markdown | scrivener | skip
+	Conflicts                 []ConflictRule  `yaml:"conflicts,omitempty"`          // rules resolving matching conflicts, tried in order
+	DefaultDeletionAction     string          `yaml:"default_deletion_action"`      // prompt | delete | recreate | skip
+	DeletionStyle             string          `yaml:"deletion_style"`               // archive-dir | trash | hard
+	DuplicateTitles           string          `yaml:"duplicate_titles"`             // warn | error | disambiguate
+	SyncBookmarks             bool            `yaml:"sync_bookmarks"`               // write Scrivener favorites to _bookmarks.md
+	PushBookmarks             bool            `yaml:"push_bookmarks"`               // add _bookmarks.md entries as favorites
+	Underline                 string          `yaml:"underline"`                    // html | ignore
+	Revisions                 string          `yaml:"revisions,omitempty"`          // critic | strip: Scrivener revision marks; off by default
+	Converter                 string          `yaml:"converter,omitempty"`          // builtin | pandoc, for RTF documents
+	PandocImports             bool            `yaml:"pandoc_imports,omitempty"`     // pull imported DOCX/ODT documents through pandoc (read-only)
+	Decorations               string          `yaml:"decorations,omitempty"`        // front_matter | index: show binder icons and labels
+	NormalizeEncoding         bool            `yaml:"normalize_encoding"`           // write markdown back as UTF-8 whatever its original encoding
+	MemoryBudgetMB            int             `yaml:"memory_budget_mb,omitempty"`   // plan content held in memory before spilling to temp files; 0 is unlimited
+	RTF                       RTFStyle        `yaml:"rtf,omitempty"`                // formatting of documents pushed to Scrivener
+	Normalize                 Normalize       `yaml:"normalize,omitempty"`          // markdown clean-up applied before hashing and writing
+	UnmappedDir               string          `yaml:"unmapped_dir,omitempty"`       // pull documents outside every mapped folder here
+	TitleFrontMatter          bool            `yaml:"title_front_matter,omitempty"` // sync binder titles as a title front matter key
+	SyncCompile               bool            `yaml:"sync_compile,omitempty"`       // sync include-in-compile as a compile: true/false front matter key
+	FileLimits                FileLimits      `yaml:"file_limits,omitempty"`        // markdown files too large or binary to sync
+	Symlinks                  string          `yaml:"symlinks,omitempty"`           // files | follow | skip: symlinks in markdown directories
+	TitleCase                 string          `yaml:"title_case,omitempty"`         // insensitive | sensitive: how titles match filenames
+	WordBudgets               []WordBudget    `yaml:"word_budgets,omitempty"`       // word ranges for Scrivener folders, checked before pushing
+	TruncationGuard           TruncationGuard `yaml:"truncation_guard,omitempty"`   // updates that empty a document, held back for confirmation
+	EncryptState              bool            `yaml:"encrypt_state,omitempty"`      // encrypt the state, journal and reports kept in ~/.scriv-sync
+	Routes                    []Route         `yaml:"routes,omitempty"`             // pull documents with a label or keyword into their own directories
+	LockedStatuses            []string        `yaml:"locked_statuses,omitempty"`    // Scrivener statuses that make documents read-only for sync, e.g. Final
+	ConversionCache           string          `yaml:"conversion_cache,omitempty"`   // on | off: keep documents converted to markdown for the next run
+	Webhook                   Webhook         `yaml:"webhook,omitempty"`            // POST a JSON summary of each completed sync here
+	FolderNotes               string          `yaml:"folder_notes,omitempty"`       // index.md | _folder.md: sync each folder's text and synopsis as this file in its directory
+	WhitespaceWins            string          `yaml:"whitespace_wins,omitempty"`    // scrivener | markdown | prompt: which side wins conflicts over whitespace alone; scrivener by default
+	TOC                       string          `yaml:"toc,omitempty"`                // titles | synopses: keep TOC.md at the markdown root in binder order; off by default
+	ImpactGate                ImpactGate      `yaml:"impact_gate,omitempty"`        // plans writing more than this are confirmed before they run
+	TitleAliases              TitleAliases    `yaml:"title_aliases,omitempty"`      // markdown title -> Scrivener title, pairing documents whose names differ
+	SafeMode                  bool            `yaml:"safe_mode,omitempty"`          // never delete or trash anything, nor let rules or defaults overwrite a side of a conflict
+	SessionHistory            bool            `yaml:"session_history,omitempty"`    // add the words each sync pushes to today's entry in Scrivener's writing history
+	Locale                    string          `yaml:"locale,omitempty"`             // BCP 47 language, e.g. tr or de: title casing of filenames and the order of listings
+	RTFPassthrough            bool            `yaml:"rtf_passthrough,omitempty"`    // keep each document's RTF beside its markdown and push edits by patching it
+}
+
+// TitleAliases pairs documents whose titles can't be made to match: each
+// key is the title of a markdown file, as its name reads, and its value the
+// title of the Scrivener document it syncs with, e.g. "Ch01": "Chapter One".
+type TitleAliases map[string]string
+
+// Webhook is where a JSON summary of each completed sync is posted, such
+// as a Slack or Discord incoming webhook or a dashboard.
+type Webhook struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret,omitempty"` // signs each request with HMAC-SHA256; may name an environment variable, e.g. $SCRIV_SYNC_WEBHOOK_SECRET
+}
+
+// Route pulls the documents with a label or keyword into a markdown
+// directory of their own rather than their mapping's, such as scenes into
+// scenes/. The first route matching a document applies.
+type Route struct {
+	Label   string `yaml:"label,omitempty"`   // label title, matched regardless of case
+	Keyword string `yaml:"keyword,omitempty"` // keyword title, matched regardless of case
+	Dir     string `yaml:"dir"`               // markdown directory, relative to the markdown root
+}
+
+// TruncationGuard holds back updates that would replace a substantial
+// document with empty or far shorter content, as an editor crash or a
+// conversion bug can, until they are confirmed.
+type TruncationGuard struct {
+	Shrink   int `yaml:"shrink,omitempty"`    // percentage of a document's words an update must lose to be held back; 0 uses 80, -1 turns the guard off
+	MinWords int `yaml:"min_words,omitempty"` // documents with fewer words are never held back; 0 uses 100
+}
+
+// ImpactGate asks before running a plan that writes more files or more
+// content, both sides together, than a sync usually does, such as one made
+// against the wrong directory or after a mass reformat.
+type ImpactGate struct {
+	MaxFiles int `yaml:"max_files,omitempty"` // files written; 0 uses 100, -1 is unlimited
+	MaxMB    int `yaml:"max_mb,omitempty"`    // megabytes of content written; 0 uses 1, -1 is unlimited
+}
+
+// WordBudget is the range of words a Scrivener folder's documents should
+// total, such as the installments of a serial. A push that takes a matching
+// folder outside it is warned about; the first budget matching a folder
+// applies.
+type WordBudget struct {
+	Folder string `yaml:"folder"`        // glob on the folder's binder path, e.g. "Draft/Chapter *"; ** spans folders
+	Min    int    `yaml:"min,omitempty"` // fewest words; 0 for no minimum
+	Max    int    `yaml:"max,omitempty"` // most words; 0 for no maximum
+}
+
+// FileLimits guards the Scrivener project against markdown files that are
+// too large or aren't text, such as an export saved with a .md name.
+type FileLimits struct {
+	MaxSizeMB int    `yaml:"max_size_mb,omitempty"` // larger files are over the limit; 0 uses 10, -1 is unlimited
+	Action    string `yaml:"action,omitempty"`      // skip | warn: leave files over the limits out, or sync them with a warning
+}
+
+// Ways of treating symlinks in markdown directories (Options.Symlinks).
+const (
+	SymlinksFiles  = "files"  // sync symlinked files, leave symlinked directories out (the default)
+	SymlinksFollow = "follow" // also walk symlinked directories, each at most once
+	SymlinksSkip   = "skip"   // leave all symlinks out
+)
+
+// Ways of matching titles to filenames (Options.TitleCase).
+const (
+	TitleCaseInsensitive = "insensitive" // lowercase filenames, titles match regardless of case (the default)
+	TitleCaseSensitive   = "sensitive"   // filenames keep the title's case, titles match only with the same case
+)
+
+// Conversion cache settings (Options.ConversionCache).
+const (
+	ConversionCacheOn  = "on"  // keep converted documents in ~/.scriv-sync/cache (the default)
+	ConversionCacheOff = "off" // convert every document on every run
+)
+
+// Folder note file names (Options.FolderNotes); unset, folders' own text
+// isn't synced.
+const (
+	FolderNotesIndex  = "index.md"
+	FolderNotesFolder = "_folder.md"
+)
+
+// Table of contents contents (Options.TOC); unset, no TOC.md is written.
+const (
+	TOCTitles   = "titles"   // titles linked to their files, with word counts
+	TOCSynopses = "synopses" // titles followed by their synopses
+)
+
+// File limit actions (FileLimits.Action).
+const (
+	FileLimitSkip = "skip"
+	FileLimitWarn = "warn"
+)
+
+// Normalize selects the clean-up steps applied to markdown before it is
+// hashed or written, so formatting an editor changes on save doesn't count
+// as an edit. All steps are off by default.
+type Normalize struct {
+	TrimTrailingWhitespace bool   `yaml:"trim_trailing_whitespace,omitempty"` // hard line breaks are kept as two spaces
+	FinalNewline           bool   `yaml:"final_newline,omitempty"`            // end with exactly one newline
+	TabWidth               int    `yaml:"tab_width,omitempty"`                // expand tabs to this many columns; 0 keeps tabs
+	BulletMarker           string `yaml:"bullet_marker,omitempty"`            // "-", "*" or "+" for every bullet; empty keeps them
+}
+
+// Ways of showing binder icons and labels in markdown (Options.Decorations).
+const (
+	DecorationsFrontMatter = "front_matter" // icon, label and label_color keys in each file
+	DecorationsIndex       = "index"        // a _binder.md index at the markdown root
+)
+
+// RTFStyle controls the formatting of documents pushed to Scrivener. Sizes
+// and indents are in points; unset fields use the built-in defaults.
+type RTFStyle struct {
+	Font            string `yaml:"font,omitempty"`
+	FontSize        int    `yaml:"font_size,omitempty"`
+	HeadingSizes    []int  `yaml:"heading_sizes,omitempty"` // for #, ## and ###
+	FirstLineIndent int    `yaml:"first_line_indent,omitempty"`
+	MatchExisting   bool   `yaml:"match_existing,omitempty"` // copy unset fields from existing documents
+	Patch           bool   `yaml:"patch,omitempty"`          // rewrite only changed paragraphs of existing documents
 }
 
 // LoadGlobal loads the global config from ~/.scriv-sync/config.yaml.
@@ -89,20 +397,34 @@ func LoadGlobal() (*GlobalConfig, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// Unknown keys are kept as warnings, and invalid projects as errors
+	// that only stop syncing those projects
 	cfg := &GlobalConfig{}
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	doc, warnings, err := decodeStrict(data, cfg)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
 	cfg.configPath = configPath
+	cfg.applyDefaults()
+	if doc != nil {
+		cfg.issues = append(warnings, validationIssues(cfg, doc)...)
+	}
+
+	return cfg, nil
+}
 
-	// Initialize projects map if nil
-	if cfg.Projects == nil {
-		cfg.Projects = make(map[string]*ProjectConfig)
+// applyDefaults initializes the projects map, sets the alias of each
+// project and fills in the options left unset.
+func (g *GlobalConfig) applyDefaults() {
+	if g.Projects == nil {
+		g.Projects = make(map[string]*ProjectConfig)
 	}
 
-	// Set alias on each project and apply defaults
-	for alias, proj := range cfg.Projects {
+	for alias, proj := range g.Projects {
+		if proj == nil {
+			continue
+		}
 		proj.alias = alias
 		if proj.Options.DefaultConflictResolution == "" {
 			proj.Options.DefaultConflictResolution = "prompt"
@@ -110,9 +432,16 @@ func LoadGlobal() (*GlobalConfig, error) {
 		if proj.Options.DefaultDeletionAction == "" {
 			proj.Options.DefaultDeletionAction = "prompt"
 		}
+		if proj.Options.DeletionStyle == "" {
+			proj.Options.DeletionStyle = "archive-dir"
+		}
+		if proj.Options.DuplicateTitles == "" {
+			proj.Options.DuplicateTitles = "warn"
+		}
+		if proj.Options.Underline == "" {
+			proj.Options.Underline = "html"
+		}
 	}
-
-	return cfg, nil
 }
 
 // Save writes the global config to its file.
@@ -132,7 +461,11 @@ func (g *GlobalConfig) Save() error {
 	}
 
 	// Also ensure state directory exists
-	stateDir := filepath.Join(dir, "state")
+	configDir, err := ConfigDir()
+	if err != nil {
+		return err
+	}
+	stateDir := filepath.Join(configDir, "state")
 	if err := os.MkdirAll(stateDir, 0755); err != nil {
 		return fmt.Errorf("failed to create state directory: %w", err)
 	}
@@ -190,10 +523,103 @@ func (g *GlobalConfig) RemoveProject(alias string) error {
 	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete state file: %w", err)
 	}
+	os.Remove(statePath + ".lock")
+	if journalPath, err := JournalPath(alias); err == nil {
+		os.Remove(journalPath)
+	}
+
+	// State files of the alias's other Scrivener projects
+	others, _ := filepath.Glob(filepath.Join(filepath.Dir(statePath), StateName(alias, "*")+".json*"))
+	for _, path := range others {
+		os.Remove(path)
+	}
 
 	return nil
 }
 
+// RenameProject renames a project alias in the global config and moves its
+// state files, journals and reports to the new alias. If a file can't be
+// moved, the ones already moved are put back and the config is unchanged.
+// The returned function undoes the rename, for when saving the config fails.
+func (g *GlobalConfig) RenameProject(oldAlias, newAlias string) (func(), error) {
+	proj, exists := g.Projects[oldAlias]
+	if !exists {
+		return nil, fmt.Errorf("project '%s' not found", oldAlias)
+	}
+	if g.HasProject(newAlias) {
+		return nil, fmt.Errorf("project '%s' already exists", newAlias)
+	}
+	if newAlias == "" || strings.ContainsAny(newAlias, "@/\\") {
+		return nil, fmt.Errorf("invalid alias: %s", newAlias)
+	}
+
+	moves, err := aliasFiles(oldAlias, newAlias)
+	if err != nil {
+		return nil, err
+	}
+	var done [][2]string
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.Rename(done[i][1], done[i][0])
+		}
+		delete(g.Projects, newAlias)
+		g.Projects[oldAlias] = proj
+		proj.alias = oldAlias
+	}
+	for _, move := range moves {
+		if _, err := os.Stat(move[1]); err == nil {
+			undo()
+			return nil, fmt.Errorf("cannot rename: %s already exists", move[1])
+		}
+		if err := os.Rename(move[0], move[1]); err != nil {
+			undo()
+			return nil, fmt.Errorf("failed to move %s: %w", move[0], err)
+		}
+		done = append(done, move)
+	}
+
+	delete(g.Projects, oldAlias)
+	g.Projects[newAlias] = proj
+	proj.alias = newAlias
+	return undo, nil
+}
+
+// aliasFiles returns the state files, journals and report directories kept
+// for an alias, each paired with its path under the new alias.
+func aliasFiles(oldAlias, newAlias string) ([][2]string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// A name belongs to the alias if it is the alias's own ("<alias>" plus
+	// one of suffixes) or one of its scriv_projects ("<alias>@...").
+	var moves [][2]string
+	collect := func(parent string, suffixes ...string) {
+		entries, err := os.ReadDir(parent)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, oldAlias) {
+				continue
+			}
+			rest := name[len(oldAlias):]
+			owned := strings.HasPrefix(rest, "@")
+			for _, suffix := range suffixes {
+				owned = owned || rest == suffix
+			}
+			if owned {
+				moves = append(moves, [2]string{filepath.Join(parent, name), filepath.Join(parent, newAlias+rest)})
+			}
+		}
+	}
+	collect(filepath.Join(dir, "state"), ".json", ".json.lock", ".journal.json")
+	collect(filepath.Join(dir, "reports"), "")
+	return moves, nil
+}
+
 // ListProjects returns all project aliases sorted alphabetically.
 func (g *GlobalConfig) ListProjects() []string {
 	aliases := make([]string, 0, len(g.Projects))
@@ -222,6 +648,46 @@ func (p *ProjectConfig) Validate() []error {
 		errs = append(errs, fmt.Errorf("local_path is required"))
 	}
 
+	if r := p.Remote; r != nil {
+		u, err := url.Parse(r.URL)
+		switch {
+		case r.Type != RemoteWebDAV && r.Type != RemoteS3:
+			errs = append(errs, fmt.Errorf("invalid remote type: %s (use %s or %s)", r.Type, RemoteWebDAV, RemoteS3))
+		case err != nil || u.Host == "":
+			errs = append(errs, fmt.Errorf("invalid remote url: %s", r.URL))
+		case r.Type == RemoteWebDAV && u.Scheme != "http" && u.Scheme != "https":
+			errs = append(errs, fmt.Errorf("webdav remote url must be an http or https URL: %s", r.URL))
+		case r.Type == RemoteS3 && u.Scheme != "s3":
+			errs = append(errs, fmt.Errorf("s3 remote url must look like s3://bucket/prefix: %s", r.URL))
+		}
+	}
+
+	// Validate Scrivener project references
+	for name, path := range p.ScrivProjects {
+		if path == "" {
+			errs = append(errs, fmt.Errorf("scriv_projects entry '%s' has no path", name))
+		}
+		if strings.ContainsAny(name, "@/\\") {
+			errs = append(errs, fmt.Errorf("invalid scriv_projects name: %s", name))
+		}
+	}
+	if err := p.CheckProjectMappings(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, m := range p.FolderMappings {
+		if strings.Count(m.Titles.Prefix+m.Titles.Suffix, "{n}") > 1 {
+			errs = append(errs, fmt.Errorf("mapping '%s': titles may use {n} only once", m.MarkdownDir))
+		}
+		if m.Titles.NumberWidth < 0 || m.Titles.NumberWidth > 9 {
+			errs = append(errs, fmt.Errorf("mapping '%s': titles number_width must be 0-9", m.MarkdownDir))
+		}
+		switch strings.TrimPrefix(m.Extension, ".") {
+		case "", ExtensionMD, ExtensionMarkdown, ExtensionMDX, ExtensionText:
+		default:
+			errs = append(errs, fmt.Errorf("mapping '%s': invalid extension: %s (use md, markdown, mdx or txt)", m.MarkdownDir, m.Extension))
+		}
+	}
+
 	// Validate conflict resolution
 	validConflict := map[string]bool{
 		"prompt": true, "markdown": true, "scrivener": true, "skip": true,
@@ -229,6 +695,39 @@ func (p *ProjectConfig) Validate() []error {
 	if !validConflict[p.Options.DefaultConflictResolution] {
 		errs = append(errs, fmt.Errorf("invalid default_conflict_resolution: %s", p.Options.DefaultConflictResolution))
 	}
+	for i, rule := range p.Options.Conflicts {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("conflicts rule %d: %w", i+1, err))
+		}
+	}
+	for i, b := range p.Options.WordBudgets {
+		switch {
+		case b.Folder == "":
+			errs = append(errs, fmt.Errorf("word_budgets entry %d: folder is required", i+1))
+		case b.Min < 0 || b.Max < 0 || (b.Max > 0 && b.Min > b.Max):
+			errs = append(errs, fmt.Errorf("word_budgets entry %d: invalid range %d-%d", i+1, b.Min, b.Max))
+		}
+		if _, err := path.Match(strings.ReplaceAll(b.Folder, "**", "*"), ""); err != nil {
+			errs = append(errs, fmt.Errorf("word_budgets entry %d: invalid folder pattern %q: %w", i+1, b.Folder, err))
+		}
+	}
+
+	for i, route := range p.Options.Routes {
+		switch {
+		case route.Dir == "":
+			errs = append(errs, fmt.Errorf("routes entry %d: dir is required", i+1))
+		case (route.Label == "") == (route.Keyword == ""):
+			errs = append(errs, fmt.Errorf("routes entry %d: set one of label and keyword", i+1))
+		}
+	}
+
+	if w := p.Options.Webhook; w.URL != "" {
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhook url must be an http or https URL: %s", w.URL))
+		}
+	} else if w.Secret != "" {
+		errs = append(errs, fmt.Errorf("webhook secret is set without a url"))
+	}
 
 	// Validate deletion action
 	validDeletion := map[string]bool{
@@ -238,17 +737,208 @@ func (p *ProjectConfig) Validate() []error {
 		errs = append(errs, fmt.Errorf("invalid default_deletion_action: %s", p.Options.DefaultDeletionAction))
 	}
 
+	// Validate deletion style
+	validStyle := map[string]bool{
+		"archive-dir": true, "trash": true, "hard": true,
+	}
+	if !validStyle[p.Options.DeletionStyle] {
+		errs = append(errs, fmt.Errorf("invalid deletion_style: %s", p.Options.DeletionStyle))
+	}
+
+	// Validate duplicate title handling
+	if d := p.Options.DuplicateTitles; d != "warn" && d != "error" && d != "disambiguate" {
+		errs = append(errs, fmt.Errorf("invalid duplicate_titles: %s", p.Options.DuplicateTitles))
+	}
+
+	// Validate underline markup
+	if p.Options.Underline != "html" && p.Options.Underline != "ignore" {
+		errs = append(errs, fmt.Errorf("invalid underline: %s", p.Options.Underline))
+	}
+	if r := p.Options.Revisions; r != "" && r != "critic" && r != "strip" {
+		errs = append(errs, fmt.Errorf("invalid revisions: %s", r))
+	}
+
+	// Validate binder decorations
+	if d := p.Options.Decorations; d != "" && d != DecorationsFrontMatter && d != DecorationsIndex {
+		errs = append(errs, fmt.Errorf("invalid decorations: %s", d))
+	}
+
+	// Validate normalization
+	if p.Options.Normalize.TabWidth < 0 {
+		errs = append(errs, fmt.Errorf("normalize tab_width must not be negative"))
+	}
+	if m := p.Options.Normalize.BulletMarker; m != "" && m != "-" && m != "*" && m != "+" {
+		errs = append(errs, fmt.Errorf("invalid normalize bullet_marker: %s", m))
+	}
+
+	// Validate memory budget
+	if p.Options.MemoryBudgetMB < 0 {
+		errs = append(errs, fmt.Errorf("memory_budget_mb must not be negative"))
+	}
+
+	// Validate title matching
+	if c := p.Options.TitleCase; c != "" && c != TitleCaseInsensitive && c != TitleCaseSensitive {
+		errs = append(errs, fmt.Errorf("invalid title_case: %s", c))
+	}
+
+	// Validate symlink handling
+	if m := p.Options.Symlinks; m != "" && m != SymlinksFiles && m != SymlinksFollow && m != SymlinksSkip {
+		errs = append(errs, fmt.Errorf("invalid symlinks: %s", m))
+	}
+
+	// Validate the conversion cache
+	if c := p.Options.ConversionCache; c != "" && c != ConversionCacheOn && c != ConversionCacheOff {
+		errs = append(errs, fmt.Errorf("invalid conversion_cache: %s", c))
+	}
+
+	// Validate folder notes
+	if n := p.Options.FolderNotes; n != "" && n != FolderNotesIndex && n != FolderNotesFolder {
+		errs = append(errs, fmt.Errorf("invalid folder_notes: %s (use %s or %s)", n, FolderNotesIndex, FolderNotesFolder))
+	}
+
+	// Validate the table of contents
+	if t := p.Options.TOC; t != "" && t != TOCTitles && t != TOCSynopses {
+		errs = append(errs, fmt.Errorf("invalid toc: %s (use %s or %s)", t, TOCTitles, TOCSynopses))
+	}
+
+	// Validate title aliases: each Scrivener title is paired with one
+	// markdown title
+	aliases := make([]string, 0, len(p.Options.TitleAliases))
+	for md := range p.Options.TitleAliases {
+		aliases = append(aliases, md)
+	}
+	sort.Strings(aliases)
+	paired := make(map[string]string)
+	for _, md := range aliases {
+		scriv := strings.TrimSpace(p.Options.TitleAliases[md])
+		if strings.TrimSpace(md) == "" || scriv == "" {
+			errs = append(errs, fmt.Errorf("title_aliases must pair non-empty titles"))
+			continue
+		}
+		if other, ok := paired[strings.ToLower(scriv)]; ok {
+			errs = append(errs, fmt.Errorf("title_aliases pairs both '%s' and '%s' with '%s'", other, md, scriv))
+			continue
+		}
+		paired[strings.ToLower(scriv)] = md
+	}
+
+	// Validate the locale
+	if l := p.Options.Locale; l != "" {
+		if _, err := language.Parse(l); err != nil {
+			errs = append(errs, fmt.Errorf("invalid locale: %s (use a language tag such as tr or de-CH)", l))
+		}
+	}
+
+	// Validate RTF passthrough, which patches RTF with the built-in converter
+	if p.Options.RTFPassthrough && p.Options.Converter != "" && p.Options.Converter != "builtin" {
+		errs = append(errs, fmt.Errorf("rtf_passthrough needs the builtin converter, not %s", p.Options.Converter))
+	}
+
+	// Validate whitespace conflict resolution
+	switch p.Options.WhitespaceWins {
+	case "", "scrivener", "markdown", "prompt":
+	default:
+		errs = append(errs, fmt.Errorf("invalid whitespace_wins: %s (use scrivener, markdown or prompt)", p.Options.WhitespaceWins))
+	}
+
+	// Validate file limits
+	if g := p.Options.TruncationGuard; g.Shrink < -1 || g.Shrink > 100 {
+		errs = append(errs, fmt.Errorf("truncation_guard shrink must be a percentage, or -1 to turn the guard off"))
+	}
+	if p.Options.TruncationGuard.MinWords < 0 {
+		errs = append(errs, fmt.Errorf("truncation_guard min_words must not be negative"))
+	}
+	if g := p.Options.ImpactGate; g.MaxFiles < -1 || g.MaxMB < -1 {
+		errs = append(errs, fmt.Errorf("impact_gate max_files and max_mb must be -1 (unlimited) or more"))
+	}
+	if p.Options.FileLimits.MaxSizeMB < -1 {
+		errs = append(errs, fmt.Errorf("file_limits max_size_mb must be -1 (unlimited) or more"))
+	}
+	if a := p.Options.FileLimits.Action; a != "" && a != FileLimitSkip && a != FileLimitWarn {
+		errs = append(errs, fmt.Errorf("invalid file_limits action: %s", a))
+	}
+
+	// Validate RTF formatting
+	if p.Options.RTF.FontSize < 0 || p.Options.RTF.FirstLineIndent < 0 {
+		errs = append(errs, fmt.Errorf("rtf font_size and first_line_indent must not be negative"))
+	}
+	if len(p.Options.RTF.HeadingSizes) > 3 {
+		errs = append(errs, fmt.Errorf("rtf heading_sizes has %d entries, at most 3 are used", len(p.Options.RTF.HeadingSizes)))
+	}
+
 	return errs
 }
 
+// CheckProjectMappings checks that every mapping refers to a configured
+// Scrivener project, and that mappings of different Scrivener projects don't
+// share markdown directories.
+func (p *ProjectConfig) CheckProjectMappings() error {
+	for i, a := range p.FolderMappings {
+		if _, ok := p.ScrivProjects[a.Project]; a.Project != "" && !ok {
+			return fmt.Errorf("mapping '%s' refers to unknown project '%s'", a.MarkdownDir, a.Project)
+		}
+		for _, b := range p.FolderMappings[i+1:] {
+			if a.Project != b.Project && a.SyncEnabled && b.SyncEnabled && dirsOverlap(p.MappingDir(a), p.MappingDir(b)) {
+				return fmt.Errorf("markdown directories '%s' and '%s' overlap but sync with different Scrivener projects", a.MarkdownDir, b.MarkdownDir)
+			}
+		}
+	}
+	return nil
+}
+
+// dirsOverlap reports whether one directory contains the other.
+func dirsOverlap(a, b string) bool {
+	return IsWithin(a, b) || IsWithin(b, a)
+}
+
+// IsWithin reports whether path is dir or inside it.
+func IsWithin(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
 // ScrivenerPath returns the absolute path to the Scrivener project.
+// Environment variables and a leading ~ are expanded.
 func (p *ProjectConfig) ScrivenerPath() (string, error) {
-	if filepath.IsAbs(p.ScrivPath) {
-		return p.ScrivPath, nil
+	return p.resolveScrivPath(p.ScrivPath)
+}
+
+// ScrivProjectPath returns the absolute path to a named Scrivener project
+// from scriv_projects, or to the scriv_path project when name is empty.
+func (p *ProjectConfig) ScrivProjectPath(name string) (string, error) {
+	if name == "" {
+		return p.ScrivenerPath()
+	}
+	path, ok := p.ScrivProjects[name]
+	if !ok {
+		return "", fmt.Errorf("unknown Scrivener project '%s'", name)
+	}
+	return p.resolveScrivPath(path)
+}
+
+// ScrivProjectNames returns the names of the scriv_projects entries, sorted.
+func (p *ProjectConfig) ScrivProjectNames() []string {
+	names := make([]string, 0, len(p.ScrivProjects))
+	for name := range p.ScrivProjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveScrivPath expands a configured Scrivener project path, resolving a
+// relative path against the markdown root.
+func (p *ProjectConfig) resolveScrivPath(path string) (string, error) {
+	scrivPath := ExpandPath(path)
+	if filepath.IsAbs(scrivPath) {
+		return scrivPath, nil
 	}
 
 	// Resolve relative to local path
-	absPath := filepath.Join(p.LocalPath, p.ScrivPath)
+	absPath := filepath.Join(p.MarkdownPath(), scrivPath)
 
 	info, err := os.Stat(absPath)
 	if err != nil {
@@ -262,8 +952,25 @@ func (p *ProjectConfig) ScrivenerPath() (string, error) {
 }
 
 // MarkdownPath returns the absolute path to the markdown root.
+// Environment variables and a leading ~ are expanded.
 func (p *ProjectConfig) MarkdownPath() string {
-	return p.LocalPath
+	return ExpandPath(p.LocalPath)
+}
+
+// IsEnabled reports whether syncing the project is enabled, that is it has
+// not been paused.
+func (p *ProjectConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// SetEnabled pauses or resumes syncing the project. Resuming clears the
+// setting, so enabled projects don't carry it in the config file.
+func (p *ProjectConfig) SetEnabled(enabled bool) {
+	if enabled {
+		p.Enabled = nil
+		return
+	}
+	p.Enabled = &enabled
 }
 
 // EnabledMappings returns only the folder mappings that have sync enabled.
@@ -277,11 +984,119 @@ func (p *ProjectConfig) EnabledMappings() []FolderMapping {
 	return enabled
 }
 
+// MappingDir returns the absolute markdown directory of a mapping. A
+// relative markdown_dir is resolved against the markdown root; an absolute
+// one (after expanding ~ and environment variables) is used as is.
+func (p *ProjectConfig) MappingDir(m FolderMapping) string {
+	dir := ExpandPath(m.MarkdownDir)
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(p.MarkdownPath(), dir)
+}
+
+// MarkdownRoots returns the markdown root followed by the directories of
+// enabled mappings that lie outside it.
+func (p *ProjectConfig) MarkdownRoots() []string {
+	roots := []string{p.MarkdownPath()}
+	for _, mapping := range p.EnabledMappings() {
+		dir := p.MappingDir(mapping)
+		inside := false
+		for _, root := range roots {
+			inside = inside || IsWithin(dir, root)
+		}
+		if !inside {
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+// MappingsForProject returns the enabled folder mappings that sync with the
+// named Scrivener project ("" for scriv_path).
+func (p *ProjectConfig) MappingsForProject(name string) []FolderMapping {
+	var mappings []FolderMapping
+	for _, mapping := range p.EnabledMappings() {
+		if mapping.Project == name {
+			mappings = append(mappings, mapping)
+		}
+	}
+	return mappings
+}
+
+// LocalConfigPath returns the path to the project-local config file.
+func (p *ProjectConfig) LocalConfigPath() string {
+	return filepath.Join(p.MarkdownPath(), LocalConfigName)
+}
+
+// WithLocalOverrides returns a copy of the project config with settings from
+// the project-local .scriv-sync.yaml (if present) applied on top. Only the
+// fields present in the local file are overridden; local_path and remote
+// always come from the global config. The receiver is never modified, so the merged
+// result is safe to use without leaking into the global config on save.
+func (p *ProjectConfig) WithLocalOverrides() (*ProjectConfig, error) {
+	merged := *p
+	merged.FolderMappings = append([]FolderMapping(nil), p.FolderMappings...)
+
+	data, err := os.ReadFile(p.LocalConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &merged, nil
+		}
+		return nil, fmt.Errorf("failed to read local config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("failed to parse local config %s: %w", p.LocalConfigPath(), err)
+	}
+	merged.LocalPath = p.LocalPath
+	merged.Remote = p.Remote
+	merged.alias = p.alias
+
+	return &merged, nil
+}
+
 // Alias returns the project's alias.
 func (p *ProjectConfig) Alias() string {
 	return p.alias
 }
 
+// IsRecursive reports whether the mapping mirrors a whole binder subtree,
+// creating one markdown directory per Scrivener folder. This is the case when
+// scrivener_folder is "/" (the entire binder, excluding Trash) or markdown_dir
+// is "." (the Scrivener folder's subtree mirrored into the markdown root).
+func (m FolderMapping) IsRecursive() bool {
+	return m.ScrivenerFolder == "/" || m.MarkdownDir == "." || m.MarkdownDir == ""
+}
+
+// FileExtension returns the extension of the mapping's files, with its dot.
+func (m FolderMapping) FileExtension() string {
+	ext := strings.TrimPrefix(m.Extension, ".")
+	if ext == "" {
+		ext = ExtensionMD
+	}
+	return "." + ext
+}
+
+// PlainText reports whether the mapping's files hold plain text rather than
+// markdown.
+func (m FolderMapping) PlainText() bool {
+	return m.FileExtension() == "."+ExtensionText
+}
+
+// ExcludesTitle reports whether a Scrivener item title matches one of the
+// mapping's exclude_folders patterns. Patterns use shell glob syntax and are
+// matched case-insensitively against the whole title.
+func (m FolderMapping) ExcludesTitle(title string) bool {
+	lowerTitle := strings.ToLower(title)
+	for _, pattern := range m.ExcludeFolders {
+		if ok, err := path.Match(strings.ToLower(pattern), lowerTitle); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 // AddMapping adds a folder mapping to the project config.
 func (p *ProjectConfig) AddMapping(markdownDir, scrivenerFolder string, enabled bool) {
 	p.FolderMappings = append(p.FolderMappings, FolderMapping{
@@ -297,5 +1112,8 @@ func DefaultOptions() Options {
 		CreateMissingFolders:      true,
 		DefaultConflictResolution: "prompt",
 		DefaultDeletionAction:     "prompt",
+		DeletionStyle:             "archive-dir",
+		DuplicateTitles:           "warn",
+		Underline:                 "html",
 	}
 }
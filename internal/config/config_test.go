@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigPath_Precedence(t *testing.T) {
+	home := t.TempDir()
+	tests := []struct {
+		name string
+		flag string
+		env  string
+		want string
+	}{
+		{"default", "", "", filepath.Join(home, ".scriv-sync", "config.yaml")},
+		{"environment", "", filepath.Join(home, "env.yaml"), filepath.Join(home, "env.yaml")},
+		{"environment with ~", "", "~/env.yaml", filepath.Join(home, "env.yaml")},
+		{"flag over environment", filepath.Join(home, "flag.yaml"), filepath.Join(home, "env.yaml"), filepath.Join(home, "flag.yaml")},
+		{"flag with ~", "~/flag.yaml", "", filepath.Join(home, "flag.yaml")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", home)
+			t.Setenv(ConfigEnvVar, tt.env)
+			SetConfigPath(tt.flag)
+			defer SetConfigPath("")
+
+			got, err := ConfigPath()
+			if err != nil {
+				t.Fatalf("ConfigPath failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithLocalOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		local        string // content of .scriv-sync.yaml; "" for none
+		wantDirs     []string
+		wantDeletion string
+		wantConflict string
+	}{
+		{
+			name:         "no local file",
+			wantDirs:     []string{"draft"},
+			wantDeletion: "archive-dir",
+			wantConflict: "markdown",
+		},
+		{
+			name: "mappings",
+			local: `folder_mappings:
+  - markdown_dir: chapters
+    scrivener_folder: Draft
+    sync_enabled: true
+  - markdown_dir: notes
+    scrivener_folder: Research
+    sync_enabled: true
+`,
+			wantDirs:     []string{"chapters", "notes"},
+			wantDeletion: "archive-dir",
+			wantConflict: "markdown",
+		},
+		{
+			name: "options",
+			local: `options:
+  deletion_style: hard
+`,
+			wantDirs:     []string{"draft"},
+			wantDeletion: "hard",
+			wantConflict: "markdown",
+		},
+		{
+			name: "local_path ignored",
+			local: `local_path: /elsewhere
+options:
+  default_conflict_resolution: scrivener
+`,
+			wantDirs:     []string{"draft"},
+			wantDeletion: "archive-dir",
+			wantConflict: "scrivener",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("HOME", dir)
+			SetConfigPath(filepath.Join(dir, "config.yaml"))
+			defer SetConfigPath("")
+
+			local := filepath.Join(dir, "markdown")
+			os.MkdirAll(local, 0755)
+			global := `version: "1.0"
+projects:
+  novel:
+    local_path: ` + local + `
+    scriv_path: ` + filepath.Join(dir, "Novel.scriv") + `
+    folder_mappings:
+      - markdown_dir: draft
+        scrivener_folder: Draft
+        sync_enabled: true
+    options:
+      default_conflict_resolution: markdown
+      deletion_style: archive-dir
+`
+			if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(global), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.local != "" {
+				if err := os.WriteFile(filepath.Join(local, LocalConfigName), []byte(tt.local), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := LoadGlobal()
+			if err != nil {
+				t.Fatalf("LoadGlobal failed: %v", err)
+			}
+			proj, err := cfg.GetProject("novel")
+			if err != nil {
+				t.Fatalf("GetProject failed: %v", err)
+			}
+
+			merged, err := proj.WithLocalOverrides()
+			if err != nil {
+				t.Fatalf("WithLocalOverrides failed: %v", err)
+			}
+			var dirs []string
+			for _, m := range merged.FolderMappings {
+				dirs = append(dirs, m.MarkdownDir)
+			}
+			if len(dirs) != len(tt.wantDirs) {
+				t.Fatalf("Expected mappings %v, got %v", tt.wantDirs, dirs)
+			}
+			for i := range dirs {
+				if dirs[i] != tt.wantDirs[i] {
+					t.Errorf("Expected mappings %v, got %v", tt.wantDirs, dirs)
+				}
+			}
+			if merged.Options.DeletionStyle != tt.wantDeletion {
+				t.Errorf("Expected deletion_style %s, got %s", tt.wantDeletion, merged.Options.DeletionStyle)
+			}
+			if merged.Options.DefaultConflictResolution != tt.wantConflict {
+				t.Errorf("Expected default_conflict_resolution %s, got %s", tt.wantConflict, merged.Options.DefaultConflictResolution)
+			}
+			if merged.LocalPath != local || merged.Alias() != "novel" {
+				t.Errorf("Expected local_path and alias from the global config, got %s and %s", merged.LocalPath, merged.Alias())
+			}
+
+			// The loaded global config is left as it was
+			if len(proj.FolderMappings) != 1 || proj.FolderMappings[0].MarkdownDir != "draft" {
+				t.Errorf("Expected the global mappings unchanged, got %+v", proj.FolderMappings)
+			}
+			if proj.Options.DeletionStyle != "archive-dir" || proj.Options.DefaultConflictResolution != "markdown" {
+				t.Errorf("Expected the global options unchanged, got %+v", proj.Options)
+			}
+		})
+	}
+}
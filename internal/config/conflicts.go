@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+// ConflictRule resolves the conflicts it matches without asking, so syncs
+// behave the same every time. Rules are tried in order and the first one
+// whose conditions all hold decides; conflicts no rule matches fall back to
+// default_conflict_resolution. A rule without conditions matches every
+// conflict.
+type ConflictRule struct {
+	Path      string `yaml:"path,omitempty"`       // glob on the markdown path relative to local_path; ** spans directories
+	Title     string `yaml:"title,omitempty"`      // glob on the document title, case-insensitive
+	OlderThan string `yaml:"older_than,omitempty"` // the markdown file was last edited longer ago than this, e.g. 7d, 12h
+	Resolve   string `yaml:"resolve"`              // markdown | scrivener | skip | prompt
+}
+
+// ConflictFacts are what a conflict rule is matched against.
+type ConflictFacts struct {
+	Path       string    // markdown path relative to local_path, with slashes
+	Title      string    // document title
+	MdModified time.Time // when the markdown file was last modified
+}
+
+// Matches reports whether all of the rule's conditions hold for a conflict.
+func (r ConflictRule) Matches(c ConflictFacts, now time.Time) bool {
+	if r.Path != "" && !MatchPath(r.Path, c.Path) {
+		return false
+	}
+	if r.Title != "" {
+		if ok, err := path.Match(strings.ToLower(r.Title), strings.ToLower(c.Title)); err != nil || !ok {
+			return false
+		}
+	}
+	if r.OlderThan != "" {
+		age, err := ParseAge(r.OlderThan)
+		if err != nil || c.MdModified.IsZero() || timestamp.Since(c.MdModified, now) <= age {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the rule's resolution and conditions.
+func (r ConflictRule) validate() error {
+	switch r.Resolve {
+	case "markdown", "scrivener", "skip", "prompt":
+	default:
+		return fmt.Errorf("invalid resolve: %q", r.Resolve)
+	}
+	if r.Path != "" {
+		if _, err := path.Match(strings.ReplaceAll(r.Path, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", r.Path, err)
+		}
+	}
+	if r.Title != "" {
+		if _, err := path.Match(r.Title, ""); err != nil {
+			return fmt.Errorf("invalid title pattern %q: %w", r.Title, err)
+		}
+	}
+	if r.OlderThan != "" {
+		if _, err := ParseAge(r.OlderThan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchPath matches a slash-separated path against a glob pattern in which
+// each segment is matched as by path.Match and a "**" segment matches any
+// number of directories, including none.
+func MatchPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ParseAge parses a duration as time.ParseDuration does, also accepting
+// days (7d) and weeks (2w).
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q: use a number with s, m, h, d or w", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Issue severities reported by CheckFile.
+const (
+	IssueError   = "error"   // the config can't be used as written
+	IssueWarning = "warning" // ignored when the config is loaded, most likely a typo
+)
+
+// Issue is a problem found in a config file.
+type Issue struct {
+	Severity string
+	Line     int    // line of the config file; 0 if unknown
+	Project  string // alias of the project it is in, if any
+	Message  string
+}
+
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", i.Line, i.Severity, i.Message)
+}
+
+// yamlLineRe matches the line number yaml.v3 puts in its error messages.
+var yamlLineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// lineIssue turns a yaml.v3 error message into an issue at its line.
+func lineIssue(severity, msg string) Issue {
+	if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Issue{Severity: severity, Line: line, Message: m[2]}
+	}
+	return Issue{Severity: severity, Message: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// decodeStrict decodes YAML into out, which points to a struct, and returns
+// a warning for each key out has no field for, with its line. Values of the
+// wrong type are a *yaml.TypeError, as with yaml.Unmarshal; the document
+// node is returned so later issues can be placed on lines too.
+func decodeStrict(data []byte, out interface{}) (*yaml.Node, []Issue, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, nil, nil // an empty file
+	}
+	doc := root.Content[0]
+	issues := unknownKeys(doc, reflect.TypeOf(out).Elem(), "")
+	return doc, issues, doc.Decode(out)
+}
+
+// unknownKeys walks a YAML node alongside the type it decodes into and
+// returns a warning for every mapping key without a field.
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) []Issue {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		return nil // checked where it is anchored
+	}
+	var issues []Issue
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown key '%s'", key.Value)
+				if path != "" {
+					msg += " in " + path
+				}
+				issues = append(issues, Issue{Severity: IssueWarning, Line: key.Line, Message: msg})
+				continue
+			}
+			issues = append(issues, unknownKeys(value, field, joinKey(path, key.Value))...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			found := unknownKeys(node.Content[i+1], t.Elem(), joinKey(path, key))
+			if path == "projects" {
+				for j := range found {
+					found[j].Project = key
+				}
+			}
+			issues = append(issues, found...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			issues = append(issues, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i+1))...)
+		}
+	}
+	return issues
+}
+
+// yamlFields returns the types of a struct's fields by their YAML keys.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// joinKey appends a key to the dotted path of a YAML node.
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// projectNode returns the node of a project's settings in the document node
+// of a global config, or nil.
+func projectNode(doc *yaml.Node, alias string) *yaml.Node {
+	projects := mappingValue(doc, "projects")
+	if projects == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(projects.Content); i += 2 {
+		if projects.Content[i].Value == alias {
+			return projects.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value of a key of a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// wordRe matches the words of a validation error that may be config keys.
+var wordRe = regexp.MustCompile(`[a-z_]+`)
+
+// validationLine places a validation error of a project on the line of the
+// first key the error names, or on the project's first line.
+func validationLine(node *yaml.Node, err error) int {
+	if node == nil {
+		return 0
+	}
+	lines := make(map[string]int)
+	var collect func(n *yaml.Node)
+	collect = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if _, seen := lines[n.Content[i].Value]; !seen {
+					lines[n.Content[i].Value] = n.Content[i].Line
+				}
+			}
+		}
+		for _, c := range n.Content {
+			collect(c)
+		}
+	}
+	collect(node)
+	for _, word := range wordRe.FindAllString(err.Error(), -1) {
+		if line, ok := lines[word]; ok {
+			return line
+		}
+	}
+	return node.Line
+}
+
+// validationIssues validates each project of a config, applying the
+// defaults LoadGlobal applies, and returns its errors placed on lines of the
+// document node.
+func validationIssues(cfg *GlobalConfig, doc *yaml.Node) []Issue {
+	aliases := make([]string, 0, len(cfg.Projects))
+	for alias := range cfg.Projects {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var issues []Issue
+	for _, alias := range aliases {
+		proj := cfg.Projects[alias]
+		if proj == nil {
+			issues = append(issues, Issue{Severity: IssueError, Line: projectLine(doc, alias), Project: alias, Message: fmt.Sprintf("project '%s' has no settings", alias)})
+			continue
+		}
+		node := projectNode(doc, alias)
+		for _, err := range proj.Validate() {
+			issues = append(issues, Issue{
+				Severity: IssueError,
+				Line:     validationLine(node, err),
+				Project:  alias,
+				Message:  fmt.Sprintf("project '%s': %v", alias, err),
+			})
+		}
+	}
+	return issues
+}
+
+// projectLine returns the line of a project's settings, or 0.
+func projectLine(doc *yaml.Node, alias string) int {
+	if node := projectNode(doc, alias); node != nil {
+		return node.Line
+	}
+	return 0
+}
+
+// Issues returns the issues found when the config was loaded that concern
+// the project alias or the whole file.
+func (g *GlobalConfig) Issues(alias string) []Issue {
+	var issues []Issue
+	for _, issue := range g.issues {
+		if issue.Project == "" || issue.Project == alias {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// CheckFile checks a global config file: its YAML syntax, keys that match
+// no setting, values of the wrong type and, per project, the settings
+// Validate checks. Issues are returned in the order of the file.
+func CheckFile(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg := &GlobalConfig{}
+	doc, issues, err := decodeStrict(data, cfg)
+	issues, parsed := typeIssues(issues, err)
+	if parsed && doc != nil {
+		cfg.applyDefaults()
+		issues = append(issues, validationIssues(cfg, doc)...)
+	}
+	sortIssues(issues)
+	return issues, nil
+}
+
+// CheckLocalFile checks the project-local config file for YAML syntax, keys
+// that match no setting and values of the wrong type, and validates the
+// project with its overrides applied, reporting the errors the overrides
+// cause. A missing file has no issues.
+func (p *ProjectConfig) CheckLocalFile() ([]Issue, error) {
+	data, err := os.ReadFile(p.LocalConfigPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local config: %w", err)
+	}
+
+	merged := *p
+	merged.FolderMappings = append([]FolderMapping(nil), p.FolderMappings...)
+	doc, issues, err := decodeStrict(data, &merged)
+	issues, parsed := typeIssues(issues, err)
+	if parsed && doc != nil {
+		merged.LocalPath = p.LocalPath
+		inherited := make(map[string]bool)
+		for _, err := range p.Validate() {
+			inherited[err.Error()] = true
+		}
+		for _, err := range merged.Validate() {
+			if !inherited[err.Error()] {
+				issues = append(issues, Issue{Severity: IssueError, Line: validationLine(doc, err), Project: p.alias, Message: err.Error()})
+			}
+		}
+	}
+	for i := range issues {
+		issues[i].Project = p.alias
+	}
+	sortIssues(issues)
+	return issues, nil
+}
+
+// typeIssues adds the errors of decodeStrict to its issues. It reports false
+// when the file couldn't be parsed at all.
+func typeIssues(issues []Issue, err error) ([]Issue, bool) {
+	if err == nil {
+		return issues, true
+	}
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return append(issues, lineIssue(IssueError, err.Error())), false
+	}
+	for _, msg := range typeErr.Errors {
+		issues = append(issues, lineIssue(IssueError, msg))
+	}
+	return issues, true
+}
+
+// sortIssues orders issues by line, keeping the order of issues on a line.
+func sortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+}
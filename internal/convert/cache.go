@@ -0,0 +1,90 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// cacheFormat is bumped when cached conversions must not be reused, such as
+// after a change to how entries are stored.
+const cacheFormat = 1
+
+// Cached is a converter keeping the markdown its Converter makes in a cache
+// directory, keyed by the hash of the content converted, so unchanged
+// documents are converted once however many runs read them. FromMarkdown is
+// not cached.
+type Cached struct {
+	Converter Converter
+	Dir       string
+	key       string
+}
+
+// NewCached wraps c with a cache in dir. Entries are keyed by c's type and
+// options and by this build, so changing either converts documents afresh.
+func NewCached(c Converter, dir string) *Cached {
+	return &Cached{Converter: c, Dir: dir, key: fmt.Sprintf("%d %T%+v %s", cacheFormat, c, c, buildID())}
+}
+
+// ToMarkdown returns the cached conversion of content, converting and
+// caching it if there is none. A cache that can't be written only costs the
+// conversion next time.
+func (c *Cached) ToMarkdown(content string) (string, error) {
+	sum := sha256.Sum256([]byte(c.key + "\x00" + content))
+	path := filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".md")
+	if data, err := os.ReadFile(path); err == nil {
+		return string(data), nil
+	}
+
+	md, err := c.Converter.ToMarkdown(content)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err == nil {
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(md), 0644); err == nil {
+			if os.Rename(tmp, path) != nil {
+				os.Remove(tmp)
+			}
+		}
+	}
+	return md, nil
+}
+
+// FromMarkdown converts markdown with the wrapped converter.
+func (c *Cached) FromMarkdown(md, existing string) (string, error) {
+	return c.Converter.FromMarkdown(md, existing)
+}
+
+// Unwrap returns the wrapped converter.
+func (c *Cached) Unwrap() Converter {
+	return c.Converter
+}
+
+// IsBuiltin reports whether c is the built-in RTF converter, cached or not.
+func IsBuiltin(c Converter) bool {
+	if cached, ok := c.(*Cached); ok {
+		c = cached.Converter
+	}
+	_, ok := c.(RTF)
+	return ok
+}
+
+// buildID identifies the running build, so a new version's conversions
+// replace those cached by an old one.
+func buildID() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	id := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+			id += " " + setting.Value
+		}
+	}
+	return id
+}
@@ -0,0 +1,109 @@
+// Package convert converts Scrivener document content to and from markdown.
+// Each stored file type (rtf, txt) has a Converter; the built-in one for RTF
+// can be replaced, for example by pandoc for higher fidelity.
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sweiss/harcroft/internal/rtf"
+)
+
+// Converter converts one stored file type to and from markdown.
+type Converter interface {
+	// ToMarkdown converts a document's stored content to markdown.
+	ToMarkdown(content string) (string, error)
+	// FromMarkdown converts markdown to stored content. existing is the
+	// document's current content, or "" for a new document.
+	FromMarkdown(md, existing string) (string, error)
+}
+
+// ErrReadOnly is returned by FromMarkdown for formats that are only read.
+var ErrReadOnly = errors.New("cannot write back to this format")
+
+// Factory creates a named converter for RTF documents with the given
+// conversion options.
+type Factory func(opts rtf.Options) (Converter, error)
+
+// Converter names.
+const (
+	Builtin = "builtin" // the rtf package
+	Pandoc  = "pandoc"  // an external pandoc executable
+)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		Builtin: func(opts rtf.Options) (Converter, error) { return RTF{Options: opts}, nil },
+		Pandoc:  func(opts rtf.Options) (Converter, error) { return NewPandoc("") },
+	}
+)
+
+// Register makes a converter available by name, replacing any converter
+// registered under that name before.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Names returns the registered converter names in order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the named RTF converter. An empty name is the built-in one.
+func New(name string, opts rtf.Options) (Converter, error) {
+	if name == "" {
+		name = Builtin
+	}
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown converter '%s' (available: %v)", name, Names())
+	}
+	return factory(opts)
+}
+
+// RTF is the built-in RTF converter.
+type RTF struct {
+	Options rtf.Options
+}
+
+// ToMarkdown converts RTF to markdown.
+func (c RTF) ToMarkdown(content string) (string, error) {
+	return rtf.RTFToMarkdownWithOptions(content, c.Options), nil
+}
+
+// FromMarkdown converts markdown to RTF. In patch mode an existing document
+// is updated paragraph by paragraph instead of being regenerated.
+func (c RTF) FromMarkdown(md, existing string) (string, error) {
+	if c.Options.Patch && existing != "" {
+		return rtf.PatchRTF(existing, md, c.Options), nil
+	}
+	return rtf.MarkdownToRTFWithOptions(md, c.Options), nil
+}
+
+// Text stores markdown as plain text, unchanged.
+type Text struct{}
+
+// ToMarkdown returns the text unchanged.
+func (Text) ToMarkdown(content string) (string, error) {
+	return content, nil
+}
+
+// FromMarkdown returns the markdown unchanged.
+func (Text) FromMarkdown(md, existing string) (string, error) {
+	return md, nil
+}
@@ -0,0 +1,115 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sweiss/harcroft/internal/rtf"
+)
+
+// upper is a converter for tests that upper-cases on the way in.
+type upper struct{}
+
+func (upper) ToMarkdown(content string) (string, error)        { return strings.ToUpper(content), nil }
+func (upper) FromMarkdown(md, existing string) (string, error) { return md, nil }
+
+func TestNew_BuiltinRoundTrip(t *testing.T) {
+	c, err := New("", rtf.DefaultOptions())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	out, err := c.FromMarkdown("Some **bold** text", "")
+	if err != nil {
+		t.Fatalf("FromMarkdown failed: %v", err)
+	}
+	md, err := c.ToMarkdown(out)
+	if err != nil {
+		t.Fatalf("ToMarkdown failed: %v", err)
+	}
+	if strings.TrimSpace(md) != "Some **bold** text" {
+		t.Errorf("Expected the markdown back, got %q", md)
+	}
+}
+
+func TestNew_Registered(t *testing.T) {
+	if _, err := New("upper", rtf.DefaultOptions()); err == nil {
+		t.Fatal("Expected an error for an unknown converter")
+	}
+
+	Register("upper", func(opts rtf.Options) (Converter, error) { return upper{}, nil })
+	c, err := New("upper", rtf.DefaultOptions())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if md, _ := c.ToMarkdown("text"); md != "TEXT" {
+		t.Errorf("Expected the registered converter, got %q", md)
+	}
+}
+
+func TestPandoc_RunsExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of pandoc")
+	}
+	// A stand-in pandoc that reports its arguments and echoes its input
+	script := filepath.Join(t.TempDir(), "pandoc")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\"\ncat\n"), 0755)
+
+	c, err := NewPandoc(script)
+	if err != nil {
+		t.Fatalf("NewPandoc failed: %v", err)
+	}
+	md, err := c.ToMarkdown("{\\rtf1 Hello}")
+	if err != nil {
+		t.Fatalf("ToMarkdown failed: %v", err)
+	}
+	if !strings.Contains(md, "--from=rtf --to=gfm") || !strings.Contains(md, "{\\rtf1 Hello}") {
+		t.Errorf("Unexpected pandoc output: %q", md)
+	}
+
+	if _, err := NewPandoc(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing pandoc")
+	}
+}
+
+// counting is a converter for tests that counts its conversions.
+type counting struct{ calls *int }
+
+func (c counting) ToMarkdown(content string) (string, error) {
+	*c.calls++
+	return strings.ToUpper(content), nil
+}
+func (counting) FromMarkdown(md, existing string) (string, error) { return md, nil }
+
+func TestCached_ConvertsOnce(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	c := NewCached(counting{calls: &calls}, dir)
+
+	for i := 0; i < 3; i++ {
+		md, err := c.ToMarkdown("text")
+		if err != nil {
+			t.Fatalf("ToMarkdown failed: %v", err)
+		}
+		if md != "TEXT" {
+			t.Errorf("Expected the converted text, got %q", md)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one conversion, got %d", calls)
+	}
+
+	// Another run reuses the entry; changed content is converted afresh
+	c = NewCached(counting{calls: &calls}, dir)
+	c.ToMarkdown("text")
+	c.ToMarkdown("other")
+	if calls != 2 {
+		t.Errorf("Expected only the changed content to be converted, got %d conversions", calls)
+	}
+
+	if !IsBuiltin(NewCached(RTF{}, dir)) || IsBuiltin(c) {
+		t.Error("Expected only the cached built-in converter to be recognised as built-in")
+	}
+}
@@ -0,0 +1,93 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pandocMarkdown is the markdown flavor pandoc reads and writes. GitHub
+// flavored markdown keeps strikethrough and pipe tables like the built-in
+// converter.
+const pandocMarkdown = "gfm"
+
+// PandocConverter converts a document format by running pandoc. Its RTF
+// output does not use the RTF template or patch options, which belong to the
+// built-in converter.
+type PandocConverter struct {
+	path   string
+	format string // pandoc input format of stored content
+}
+
+// readOnlyFormats are formats pandoc reads but that are never written back:
+// regenerating them from markdown would lose most of what they hold.
+var readOnlyFormats = map[string]bool{"docx": true, "odt": true}
+
+// NewPandoc creates a converter that runs the pandoc executable at path, or
+// the one on PATH if path is empty.
+func NewPandoc(path string) (*PandocConverter, error) {
+	if path == "" {
+		path = "pandoc"
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("pandoc converter: %w", err)
+	}
+	return &PandocConverter{path: resolved, format: "rtf"}, nil
+}
+
+// ForFormat returns a converter for another stored format, such as "docx"
+// or "odt", using the same pandoc executable. DOCX and ODT are read-only.
+func (p *PandocConverter) ForFormat(format string) *PandocConverter {
+	return &PandocConverter{path: p.path, format: format}
+}
+
+// ToMarkdown converts stored content to markdown.
+func (p *PandocConverter) ToMarkdown(content string) (string, error) {
+	args := []string{"--from=" + p.format, "--to=" + pandocMarkdown, "--wrap=none"}
+	if p.format == "rtf" {
+		return p.run(content, args...)
+	}
+
+	// Binary formats are read from a file, which every pandoc version supports
+	f, err := os.CreateTemp("", "scriv-sync-*."+p.format)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return p.run("", append(args, f.Name())...)
+}
+
+// FromMarkdown converts markdown to a standalone document of the stored
+// format.
+func (p *PandocConverter) FromMarkdown(md, existing string) (string, error) {
+	if readOnlyFormats[p.format] {
+		return "", fmt.Errorf("%w: %s", ErrReadOnly, p.format)
+	}
+	return p.run(md, "--from="+pandocMarkdown, "--to="+p.format, "--standalone")
+}
+
+// run runs pandoc with the given arguments and input on stdin.
+func (p *PandocConverter) run(input string, args ...string) (string, error) {
+	cmd := exec.Command(p.path, args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("pandoc failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("pandoc failed: %w", err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,314 @@
+// Package remote keeps a markdown vault that lives on a remote store, such
+// as a WebDAV server or an S3 bucket, in step with a local working copy the
+// sync runs against: the store is fetched into it before a run, and what the
+// run changed is uploaded after.
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// Object is a file on a remote store.
+type Object struct {
+	Path string // relative to the vault, with forward slashes
+	ETag string // changes whenever the file does
+	Size int64
+}
+
+// Store is a remote store holding a vault's files.
+type Store interface {
+	// List returns every file in the vault.
+	List() ([]Object, error)
+	// Get returns a file's content.
+	Get(path string) ([]byte, error)
+	// Put writes a file, creating the directories it needs, and returns its
+	// new ETag, or "" if the store didn't say.
+	Put(path string, data []byte) (string, error)
+	// Delete removes a file.
+	Delete(path string) error
+}
+
+// New returns the store a remote config describes.
+func New(cfg config.Remote) (Store, error) {
+	switch cfg.Type {
+	case config.RemoteWebDAV:
+		return NewWebDAV(cfg.URL, os.ExpandEnv(cfg.Username), os.ExpandEnv(cfg.Password))
+	case config.RemoteS3:
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown remote type '%s'", cfg.Type)
+	}
+}
+
+// skipDirs are directories of the working copy never uploaded, such as the
+// archive of files deleted by orphan handling, which is a local backup.
+var skipDirs = map[string]bool{".scriv-sync-archive": true}
+
+// entry is what the manifest records of a file as last fetched or uploaded.
+type entry struct {
+	ETag string `json:"etag"`
+	Hash string `json:"hash"` // SHA-256 of its content
+}
+
+// Manifest records each file of a vault as last fetched or uploaded, to
+// tell which side changed it since.
+type Manifest struct {
+	Files map[string]entry `json:"files"`
+
+	path string
+}
+
+// LoadManifest reads a manifest, or returns an empty one if it doesn't exist.
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{Files: make(map[string]entry), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse remote manifest %s: %w", path, err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]entry)
+	}
+	return m, nil
+}
+
+// Save writes the manifest.
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal remote manifest: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(m.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write remote manifest: %w", err)
+	}
+	return nil
+}
+
+// Result counts what a fetch or upload did. Kept lists the files left alone
+// because both sides changed them.
+type Result struct {
+	Transferred int
+	Deleted     int
+	Kept        []string
+}
+
+// hashOf returns the SHA-256 of data.
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// localHash returns the hash of a working copy file, or "" if it doesn't
+// exist.
+func localHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hashOf(data), nil
+}
+
+// Fetch brings the working copy in dir up to date with the store. Files
+// changed on the store are downloaded and files deleted from it removed,
+// except where the working copy changed them too since the last transfer
+// (as after an upload that failed) or holds a different file of the same
+// name: those are kept on both sides and listed, until one is removed.
+func Fetch(store Store, dir string, m *Manifest) (Result, error) {
+	var res Result
+	objects, err := store.List()
+	if err != nil {
+		return res, err
+	}
+	onStore := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		onStore[obj.Path] = true
+		prev, known := m.Files[obj.Path]
+		if known && obj.ETag != "" && prev.ETag == obj.ETag {
+			continue
+		}
+		local := filepath.Join(dir, filepath.FromSlash(obj.Path))
+		h, err := localHash(local)
+		if err != nil {
+			return res, err
+		}
+		if known && h != "" && h != prev.Hash {
+			res.Kept = append(res.Kept, obj.Path)
+			continue
+		}
+		data, err := store.Get(obj.Path)
+		if err != nil {
+			return res, err
+		}
+		if !known && h != "" && h != hashOf(data) {
+			// A file already in the working copy, differing from the store's
+			res.Kept = append(res.Kept, obj.Path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return res, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(local, data, 0644); err != nil {
+			return res, fmt.Errorf("failed to write %s: %w", local, err)
+		}
+		m.Files[obj.Path] = entry{ETag: obj.ETag, Hash: hashOf(data)}
+		res.Transferred++
+	}
+
+	for _, path := range sortedPaths(m.Files) {
+		if onStore[path] {
+			continue
+		}
+		local := filepath.Join(dir, filepath.FromSlash(path))
+		h, err := localHash(local)
+		if err != nil {
+			return res, err
+		}
+		if h == m.Files[path].Hash {
+			if err := os.Remove(local); err != nil {
+				return res, fmt.Errorf("failed to remove %s: %w", local, err)
+			}
+			res.Deleted++
+		}
+		// A file changed here since is uploaded again as a new one
+		delete(m.Files, path)
+	}
+	return res, m.Save()
+}
+
+// Upload sends the working copy's changes since the last transfer to the
+// store: changed and new files are written and deleted files removed. A file
+// that changed on the store as well since it was fetched is left alone on
+// both sides.
+func Upload(store Store, dir string, m *Manifest) (Result, error) {
+	var res Result
+	objects, err := store.List()
+	if err != nil {
+		return res, err
+	}
+	etags := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		etags[obj.Path] = obj.ETag
+	}
+
+	local := make(map[string]bool)
+	var unknownETags []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		local[rel] = true
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		h := hashOf(data)
+		prev, known := m.Files[rel]
+		if known && prev.Hash == h {
+			return nil
+		}
+		if etag, exists := etags[rel]; exists && (!known || etag != prev.ETag) {
+			res.Kept = append(res.Kept, rel)
+			return nil
+		}
+		etag, err := store.Put(rel, data)
+		if err != nil {
+			return err
+		}
+		if etag == "" {
+			unknownETags = append(unknownETags, rel)
+		}
+		m.Files[rel] = entry{ETag: etag, Hash: h}
+		res.Transferred++
+		return nil
+	})
+	if err != nil {
+		m.Save()
+		return res, err
+	}
+
+	for _, path := range sortedPaths(m.Files) {
+		if local[path] {
+			continue
+		}
+		if etag, exists := etags[path]; exists {
+			if etag != m.Files[path].ETag {
+				res.Kept = append(res.Kept, path)
+				continue
+			}
+			if err := store.Delete(path); err != nil {
+				m.Save()
+				return res, err
+			}
+			res.Deleted++
+		}
+		delete(m.Files, path)
+	}
+
+	// Stores that don't return an ETag on writes are listed again for them
+	if len(unknownETags) > 0 {
+		objects, err := store.List()
+		if err != nil {
+			m.Save()
+			return res, err
+		}
+		for _, obj := range objects {
+			if e, ok := m.Files[obj.Path]; ok && e.ETag == "" {
+				e.ETag = obj.ETag
+				m.Files[obj.Path] = e
+			}
+		}
+	}
+	return res, m.Save()
+}
+
+// sortedPaths returns the paths of a manifest in order.
+func sortedPaths(files map[string]entry) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// cleanPath returns a store path with forward slashes and no leading slash.
+func cleanPath(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
@@ -0,0 +1,343 @@
+package remote
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// memStore is a Store held in memory, with an ETag that changes on every write.
+type memStore struct {
+	files map[string]string
+	etags map[string]string
+	n     int
+}
+
+func newMemStore(files map[string]string) *memStore {
+	s := &memStore{files: make(map[string]string), etags: make(map[string]string)}
+	for p, content := range files {
+		s.Put(p, []byte(content))
+	}
+	return s
+}
+
+func (s *memStore) List() ([]Object, error) {
+	var objects []Object
+	for p, content := range s.files {
+		objects = append(objects, Object{Path: p, ETag: s.etags[p], Size: int64(len(content))})
+	}
+	return objects, nil
+}
+
+func (s *memStore) Get(p string) ([]byte, error) {
+	content, ok := s.files[p]
+	if !ok {
+		return nil, fmt.Errorf("%s: not found", p)
+	}
+	return []byte(content), nil
+}
+
+func (s *memStore) Put(p string, data []byte) (string, error) {
+	s.n++
+	s.files[p] = string(data)
+	s.etags[p] = fmt.Sprintf("v%d", s.n)
+	return s.etags[p], nil
+}
+
+func (s *memStore) Delete(p string) error {
+	delete(s.files, p)
+	delete(s.etags, p)
+	return nil
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestFetchUpload(t *testing.T) {
+	dir := t.TempDir()
+	store := newMemStore(map[string]string{"one.md": "one", "part/two.md": "two"})
+	m, err := LoadManifest(filepath.Join(t.TempDir(), "manifest.json"))
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	res, err := Fetch(store, dir, m)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if res.Transferred != 2 || readFile(t, filepath.Join(dir, "part", "two.md")) != "two" {
+		t.Fatalf("Expected both files fetched, got %+v", res)
+	}
+
+	// Local edits, a new file, a deletion and an archived file
+	os.WriteFile(filepath.Join(dir, "one.md"), []byte("one, edited"), 0644)
+	os.WriteFile(filepath.Join(dir, "three.md"), []byte("three"), 0644)
+	os.Remove(filepath.Join(dir, "part", "two.md"))
+	os.MkdirAll(filepath.Join(dir, ".scriv-sync-archive"), 0755)
+	os.WriteFile(filepath.Join(dir, ".scriv-sync-archive", "old.md"), []byte("old"), 0644)
+
+	res, err = Upload(store, dir, m)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if res.Transferred != 2 || res.Deleted != 1 || len(res.Kept) != 0 {
+		t.Errorf("Expected 2 written and 1 removed, got %+v", res)
+	}
+	if store.files["one.md"] != "one, edited" || store.files["three.md"] != "three" {
+		t.Errorf("Expected local changes on the store, got %v", store.files)
+	}
+	if _, ok := store.files["part/two.md"]; ok {
+		t.Error("Expected the deleted file removed from the store")
+	}
+	if _, ok := store.files[".scriv-sync-archive/old.md"]; ok {
+		t.Error("Expected the archive not uploaded")
+	}
+
+	// A file changed on both sides is kept, not overwritten either way
+	store.Put("one.md", []byte("one, edited remotely"))
+	os.WriteFile(filepath.Join(dir, "one.md"), []byte("one, edited again"), 0644)
+	store.Delete("three.md")
+
+	m, err = LoadManifest(m.path)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	res, err = Fetch(store, dir, m)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(res.Kept) != 1 || res.Kept[0] != "one.md" || res.Deleted != 1 {
+		t.Errorf("Expected one.md kept and three.md removed, got %+v", res)
+	}
+	if readFile(t, filepath.Join(dir, "one.md")) != "one, edited again" {
+		t.Error("Expected the local edit kept by Fetch")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "three.md")); !os.IsNotExist(err) {
+		t.Error("Expected the file deleted from the store removed locally")
+	}
+
+	res, err = Upload(store, dir, m)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(res.Kept) != 1 || store.files["one.md"] != "one, edited remotely" {
+		t.Errorf("Expected the remote edit kept by Upload, got %+v", res)
+	}
+
+	// Deleting the local copy takes the remote's
+	os.Remove(filepath.Join(dir, "one.md"))
+	if _, err := Fetch(store, dir, m); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if readFile(t, filepath.Join(dir, "one.md")) != "one, edited remotely" {
+		t.Error("Expected the remote version fetched")
+	}
+}
+
+// davServer is a minimal WebDAV server over a map of files.
+func davServer(files map[string]string) *httptest.Server {
+	const root = "/dav/vault/"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "ann" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, root) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rel := strings.TrimPrefix(r.URL.Path, root)
+		switch r.Method {
+		case "PROPFIND":
+			type prop struct {
+				Collection *struct{} `xml:"resourcetype>collection"`
+				ETag       string    `xml:"getetag,omitempty"`
+			}
+			type response struct {
+				Href   string `xml:"href"`
+				Status string `xml:"propstat>status"`
+				Prop   prop   `xml:"propstat>prop"`
+			}
+			responses := []response{{Href: r.URL.Path, Status: "HTTP/1.1 200 OK", Prop: prop{Collection: &struct{}{}}}}
+			seen := make(map[string]bool)
+			for p, content := range files {
+				if !strings.HasPrefix(p, rel) {
+					continue
+				}
+				name, _, isDir := strings.Cut(strings.TrimPrefix(p, rel), "/")
+				switch {
+				case isDir && !seen[name]:
+					seen[name] = true
+					responses = append(responses, response{Href: root + rel + name + "/", Status: "HTTP/1.1 200 OK", Prop: prop{Collection: &struct{}{}}})
+				case !isDir:
+					responses = append(responses, response{Href: root + p, Status: "HTTP/1.1 200 OK", Prop: prop{ETag: fmt.Sprintf(`"%x"`, hashOf([]byte(content)))}})
+				}
+			}
+			w.WriteHeader(http.StatusMultiStatus)
+			xml.NewEncoder(w).Encode(struct {
+				XMLName   xml.Name   `xml:"DAV: multistatus"`
+				Responses []response `xml:"response"`
+			}{Responses: responses})
+		case http.MethodGet:
+			content, ok := files[rel]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, content)
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			files[rel] = string(data)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			delete(files, rel)
+			w.WriteHeader(http.StatusNoContent)
+		case "MKCOL":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestWebDAV(t *testing.T) {
+	files := map[string]string{"one.md": "one", "part/two.md": "two"}
+	server := davServer(files)
+	defer server.Close()
+
+	t.Setenv("DAV_PASSWORD", "secret")
+	store, err := New(config.Remote{Type: config.RemoteWebDAV, URL: server.URL + "/dav/vault", Username: "ann", Password: "$DAV_PASSWORD"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	objects, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var paths []string
+	for _, obj := range objects {
+		paths = append(paths, obj.Path)
+		if obj.ETag == "" {
+			t.Errorf("Expected an ETag for %s", obj.Path)
+		}
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "one.md,part/two.md" {
+		t.Errorf("Expected both files listed, got %v", paths)
+	}
+
+	dir := t.TempDir()
+	m, _ := LoadManifest(filepath.Join(t.TempDir(), "manifest.json"))
+	if _, err := Fetch(store, dir, m); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	os.MkdirAll(filepath.Join(dir, "part", "new"), 0755)
+	os.WriteFile(filepath.Join(dir, "part", "new", "three.md"), []byte("three"), 0644)
+	os.Remove(filepath.Join(dir, "one.md"))
+
+	res, err := Upload(store, dir, m)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if res.Transferred != 1 || res.Deleted != 1 {
+		t.Errorf("Expected 1 written and 1 removed, got %+v", res)
+	}
+	if files["part/new/three.md"] != "three" {
+		t.Errorf("Expected the new file on the server, got %v", files)
+	}
+	if _, ok := files["one.md"]; ok {
+		t.Error("Expected the deleted file removed from the server")
+	}
+	if m.Files["part/new/three.md"].ETag == "" {
+		t.Error("Expected the ETag of an upload recorded from a new listing")
+	}
+}
+
+func TestS3(t *testing.T) {
+	files := map[string]string{"notes/one.md": "one", "notes/a b.md": "two", "other.md": "x"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			prefix := r.URL.Query().Get("prefix")
+			fmt.Fprint(w, "<ListBucketResult>")
+			for k, content := range files {
+				if strings.HasPrefix(k, prefix) {
+					fmt.Fprintf(w, "<Contents><Key>%s</Key><ETag>&quot;%x&quot;</ETag><Size>%d</Size></Contents>", k, hashOf([]byte(content))[:8], len(content))
+				}
+			}
+			fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+		case r.Method == http.MethodGet:
+			content, ok := files[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, content)
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			files[key] = string(data)
+			w.Header().Set("ETag", `"new"`)
+		case r.Method == http.MethodDelete:
+			delete(files, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store, err := New(config.Remote{Type: config.RemoteS3, URL: "s3://bucket/notes", Endpoint: server.URL, Region: "eu-west-1", Username: "AKID", Password: "SECRET"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	m, _ := LoadManifest(filepath.Join(t.TempDir(), "manifest.json"))
+	res, err := Fetch(store, dir, m)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if res.Transferred != 2 || readFile(t, filepath.Join(dir, "a b.md")) != "two" {
+		t.Errorf("Expected the two files under the prefix fetched, got %+v", res)
+	}
+
+	os.WriteFile(filepath.Join(dir, "one.md"), []byte("one, edited"), 0644)
+	if _, err := Upload(store, dir, m); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if files["notes/one.md"] != "one, edited" || m.Files["one.md"].ETag != "new" {
+		t.Errorf("Expected the edit uploaded with its ETag recorded, got %v", files)
+	}
+	if files["other.md"] != "x" {
+		t.Error("Expected files outside the prefix left alone")
+	}
+}
+
+func TestAWSEscape(t *testing.T) {
+	if got := awsEscape("notes/a b~(1).md", true); got != "notes/a%20b~%281%29.md" {
+		t.Errorf("Expected path escaped, got %s", got)
+	}
+	if got := canonicalQuery(map[string]string{"prefix": "a/", "list-type": "2"}); got != "list-type=2&prefix=a%2F" {
+		t.Errorf("Expected sorted, escaped query, got %s", got)
+	}
+}
@@ -0,0 +1,265 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// S3 is a vault under a prefix of an S3 bucket, on AWS or an S3-compatible
+// server. Requests use path-style URLs signed with AWS Signature Version 4.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	prefix    string // ends in "/" unless empty
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3 returns the store for the s3://bucket/prefix URL of a remote config.
+func NewS3(cfg config.Remote) (*S3, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 url %q: use s3://bucket/prefix", cfg.URL)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	ep, err := url.Parse(endpoint)
+	if err != nil || ep.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	accessKey, secretKey := os.ExpandEnv(cfg.Username), os.ExpandEnv(cfg.Password)
+	if accessKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if secretKey == "" {
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("no S3 credentials: set the remote's username and password, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return &S3{
+		endpoint:  ep,
+		bucket:    u.Host,
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+		now:       time.Now,
+	}, nil
+}
+
+// listBucketResult is a ListObjectsV2 response.
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns every object under the prefix, a page at a time.
+func (s *S3) List() ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": s.prefix}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		data, err := s.do(http.MethodGet, "", query, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse S3 listing: %w", err)
+		}
+		for _, c := range result.Contents {
+			rel := strings.TrimPrefix(c.Key, s.prefix)
+			if rel == "" || strings.HasSuffix(rel, "/") {
+				continue
+			}
+			objects = append(objects, Object{Path: rel, ETag: strings.Trim(c.ETag, `"`), Size: c.Size})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Get returns an object's content.
+func (s *S3) Get(p string) ([]byte, error) {
+	return s.do(http.MethodGet, s.prefix+cleanPath(p), nil, nil, http.StatusOK)
+}
+
+// Put writes an object and returns its ETag.
+func (s *S3) Put(p string, data []byte) (string, error) {
+	req, err := s.request(http.MethodPut, s.prefix+cleanPath(p), nil, data)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", p, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("PUT %s: %s", req.URL, resp.Status)
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// Delete removes an object.
+func (s *S3) Delete(p string) error {
+	_, err := s.do(http.MethodDelete, s.prefix+cleanPath(p), nil, nil, http.StatusNoContent, http.StatusOK)
+	return err
+}
+
+// do sends a signed request for a key of the bucket ("" for the bucket),
+// returning the response body if its status is one of ok.
+func (s *S3) do(method, key string, query map[string]string, body []byte, ok ...int) ([]byte, error) {
+	req, err := s.request(method, key, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, req.URL, err)
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, req.URL, resp.Status)
+}
+
+// request creates a signed request.
+func (s *S3) request(method, key string, query map[string]string, body []byte) (*http.Request, error) {
+	u := *s.endpoint
+	u.Path = "/" + s.bucket + "/" + key
+	u.RawPath = "/" + awsEscape(s.bucket, false) + "/" + awsEscape(key, true)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "scriv-sync")
+	s.sign(req, u.RawPath, body)
+	return req, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request whose
+// URI-encoded path is uri.
+func (s *S3) sign(req *http.Request, uri string, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns a query string with its parameters sorted and
+// encoded as Signature Version 4 requires.
+func canonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = awsEscape(k, false) + "=" + awsEscape(query[k], false)
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes every byte but the unreserved characters, and
+// "/" if keepSlash is set.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sha256Hex returns the hex SHA-256 of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
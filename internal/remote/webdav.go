@@ -0,0 +1,228 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// WebDAV is a vault in a WebDAV collection, such as a Nextcloud folder.
+type WebDAV struct {
+	base     *url.URL // the vault's collection, ending in "/"
+	username string
+	password string
+	client   *http.Client
+	made     map[string]bool // collections known to exist
+}
+
+// NewWebDAV returns the store for the collection at rawURL, authenticating
+// with basic auth when username is set.
+func NewWebDAV(rawURL, username, password string) (*WebDAV, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebDAV url: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+	return &WebDAV{
+		base:     base,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		made:     map[string]bool{"": true},
+	}, nil
+}
+
+// propfindBody asks for the properties List needs.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getetag/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// multistatus is a PROPFIND response.
+type multistatus struct {
+	Responses []struct {
+		Href  string `xml:"href"`
+		Props []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ETag         string `xml:"getetag"`
+				Length       int64  `xml:"getcontentlength"`
+				LastModified string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// List returns every file in the collection, walking it one level at a
+// time, as not every server allows listing a whole tree at once. Files
+// without an ETag get one from their modification time and size.
+func (w *WebDAV) List() ([]Object, error) {
+	var objects []Object
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		req, err := w.request("PROPFIND", dir, strings.NewReader(propfindBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Depth", "1")
+		req.Header.Set("Content-Type", "application/xml")
+		data, err := w.do(req, http.StatusMultiStatus)
+		if err != nil {
+			return nil, err
+		}
+		var ms multistatus
+		if err := xml.Unmarshal(data, &ms); err != nil {
+			return nil, fmt.Errorf("failed to parse WebDAV listing of %s: %w", w.url(dir), err)
+		}
+
+		for _, r := range ms.Responses {
+			rel, ok := w.relative(r.Href)
+			if !ok || rel == dir || rel+"/" == dir {
+				continue
+			}
+			for _, ps := range r.Props {
+				if !strings.Contains(ps.Status, " 200 ") {
+					continue
+				}
+				p := ps.Prop
+				if p.ResourceType.Collection != nil {
+					pending = append(pending, strings.TrimSuffix(rel, "/")+"/")
+					continue
+				}
+				etag := strings.Trim(p.ETag, `"`)
+				if etag == "" {
+					etag = fmt.Sprintf("%s/%d", p.LastModified, p.Length)
+				}
+				objects = append(objects, Object{Path: rel, ETag: etag, Size: p.Length})
+			}
+		}
+	}
+	return objects, nil
+}
+
+// Get returns a file's content.
+func (w *WebDAV) Get(p string) ([]byte, error) {
+	req, err := w.request(http.MethodGet, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	return w.do(req, http.StatusOK)
+}
+
+// Put writes a file, creating its parent collections first.
+func (w *WebDAV) Put(p string, data []byte) (string, error) {
+	if err := w.mkcol(path.Dir(cleanPath(p))); err != nil {
+		return "", err
+	}
+	req, err := w.request(http.MethodPut, p, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", p, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("PUT %s: %s", w.url(p), resp.Status)
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// Delete removes a file.
+func (w *WebDAV) Delete(p string) error {
+	req, err := w.request(http.MethodDelete, p, nil)
+	if err != nil {
+		return err
+	}
+	_, err = w.do(req, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+	return err
+}
+
+// mkcol creates a collection and its parents, unless known to exist.
+func (w *WebDAV) mkcol(dir string) error {
+	if dir == "." || dir == "/" || w.made[dir] {
+		return nil
+	}
+	if err := w.mkcol(path.Dir(dir)); err != nil {
+		return err
+	}
+	req, err := w.request("MKCOL", dir+"/", nil)
+	if err != nil {
+		return err
+	}
+	// 405 means the collection already exists
+	if _, err := w.do(req, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+		return err
+	}
+	w.made[dir] = true
+	return nil
+}
+
+// url returns the URL of a path in the collection.
+func (w *WebDAV) url(p string) string {
+	u := *w.base
+	u.Path += cleanPath(p)
+	return u.String()
+}
+
+// relative returns the path in the collection of an href from a listing,
+// which may be a full URL or an absolute path, percent-encoded.
+func (w *WebDAV) relative(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	rel := strings.TrimPrefix(u.Path, w.base.Path)
+	if len(rel) == len(u.Path) && u.Path+"/" != w.base.Path {
+		return "", false
+	}
+	return rel, true
+}
+
+// request creates an authenticated request for a path in the collection.
+func (w *WebDAV) request(method, p string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, w.url(p), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "scriv-sync")
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return req, nil
+}
+
+// do sends a request, returning the response body if its status is one of
+// ok.
+func (w *WebDAV) do(req *http.Request, ok ...int) ([]byte, error) {
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, err)
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+}
@@ -0,0 +1,361 @@
+package rtf
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxPatchCells bounds the diff table size; larger edits regenerate the
+// whole document instead.
+const maxPatchCells = 4_000_000
+
+var (
+	// headerGroupRe matches groups that belong to the document header
+	headerGroupRe = regexp.MustCompile(`^\{\\(\*|fonttbl|colortbl|stylesheet|info|listtable|listoverridetable)`)
+	// leadingControlRe matches one control word at the start of text
+	leadingControlRe = regexp.MustCompile(`^\\[a-z]+-?\d*\s?`)
+	// pardRunRe matches a paragraph reset and the formatting that follows it
+	pardRunRe = regexp.MustCompile(`\\pard(?:\\[a-z]+-?\d*\s?)*`)
+	// charStateRe matches character formatting that carries across paragraphs
+	charStateRe = regexp.MustCompile(`\\(f|fs|cf)(\d+)`)
+)
+
+// PatchRTF updates an existing RTF document so it represents md, rewriting
+// only the paragraphs whose markdown changed. Unchanged paragraphs keep
+// their RTF byte for byte, including formatting the markdown cannot express
+// such as comments, footnotes and colors. When the document cannot be
+// patched safely, a fresh document is generated as MarkdownToRTFWithOptions
+// would.
+func PatchRTF(existing, md string, opts Options) string {
+	if patched, ok := patchParagraphs(existing, md, opts); ok {
+		return patched
+	}
+	return MarkdownToRTFWithOptions(md, opts)
+}
+
+// rtfParagraph is one paragraph of an RTF document body.
+type rtfParagraph struct {
+	raw       string // RTF including the paragraph terminator, if any
+	inherited string // paragraph and character formatting in effect when the paragraph starts
+}
+
+// patchParagraphs performs the paragraph patch. It reports false when the
+// document has structure that paragraphs cannot be mapped through (tables,
+// groups spanning paragraphs) or when the patched result would not convert
+// back to the same markdown as a freshly generated document.
+func patchParagraphs(existing, md string, opts Options) (string, bool) {
+	if strings.Contains(existing, `\trowd`) || strings.Contains(md, "```rtf") {
+		return "", false
+	}
+	newLines := strings.Split(md, "\n")
+	for i := range newLines {
+		if isTableStart(newLines, i) {
+			return "", false
+		}
+	}
+
+	header, body, trailer, ok := splitDocument(existing)
+	if !ok {
+		return "", false
+	}
+	paras, ok := splitParagraphs(body)
+	if !ok {
+		return "", false
+	}
+
+	// Each paragraph is converted on its own, with the formatting it inherits
+	oldLines := make([]string, len(paras))
+	for i, p := range paras {
+		oldLines[i] = RTFToMarkdownWithOptions(header+p.inherited+p.raw+"}", opts)
+	}
+
+	ops, ok := diffLines(oldLines, newLines)
+	if !ok {
+		return "", false
+	}
+
+	t := opts.Template
+	hasListTable := strings.Contains(header, `\listtable`)
+	var pieces []string
+	prevOld := -1 // index of the last original paragraph emitted
+
+	for _, op := range ops {
+		if op.old >= 0 && op.new >= 0 {
+			p := paras[op.old]
+			raw := p.raw
+			// Restore inherited formatting when the preceding paragraph changed
+			if prevOld != op.old-1 && !strings.Contains(raw, `\pard`) {
+				raw = p.inherited + raw
+			}
+			pieces = append(pieces, raw)
+			prevOld = op.old
+			continue
+		}
+		if op.new >= 0 {
+			line := newLines[op.new]
+			converted, isList := convertListLine(line, t)
+			if isList && !hasListTable {
+				return "", false
+			}
+			if !isList {
+				converted = convertMarkdownLine(line, t)
+			}
+			if opts.Revisions == RevisionsCritic {
+				// The text is in no revision color but the one its markup gives it
+				if criticInsertRe.MatchString(converted) || criticDeleteRe.MatchString(converted) {
+					color := revisionColorIndex(header)
+					if color < 0 {
+						return "", false
+					}
+					converted = criticToRTF(converted, color)
+				}
+				converted = `\cf0 ` + converted
+			}
+			pieces = append(pieces, converted)
+		}
+	}
+
+	// Terminate every paragraph but the last
+	for i := range pieces {
+		last := i == len(pieces)-1
+		terminated := hasTerminator(pieces[i])
+		if !last && !terminated {
+			pieces[i] += `\par` + "\n"
+		}
+	}
+
+	patched := header + strings.Join(pieces, "") + trailer
+
+	expected := RTFToMarkdownWithOptions(MarkdownToRTFWithOptions(md, opts), opts)
+	if RTFToMarkdownWithOptions(patched, opts) != expected {
+		return "", false
+	}
+	return patched, true
+}
+
+// splitDocument splits RTF into its header (everything before the first
+// paragraph), the body and the closing brace.
+func splitDocument(doc string) (header, body, trailer string, ok bool) {
+	trimmed := strings.TrimRight(doc, " \t\r\n")
+	if !strings.HasPrefix(trimmed, `{\rtf`) || !strings.HasSuffix(trimmed, "}") {
+		return "", "", "", false
+	}
+	end := len(trimmed) - 1
+
+	pos := 1 // past the opening brace
+	for pos < end {
+		rest := trimmed[pos:end]
+		switch {
+		case rest[0] == ' ' || rest[0] == '\n' || rest[0] == '\r' || rest[0] == '\t':
+			pos++
+		case strings.HasPrefix(rest, `\pard`):
+			return trimmed[:pos], trimmed[pos:end], doc[end:], true
+		case headerGroupRe.MatchString(rest):
+			close := matchingBrace(trimmed, pos)
+			if close < 0 || close >= end {
+				return "", "", "", false
+			}
+			pos = close + 1
+		case leadingControlRe.MatchString(rest):
+			pos += len(leadingControlRe.FindString(rest))
+		default:
+			return trimmed[:pos], trimmed[pos:end], doc[end:], true
+		}
+	}
+	return trimmed[:pos], "", doc[end:], true
+}
+
+// splitParagraphs splits an RTF body at paragraph breaks (\par and the
+// backslash-newline form). It reports false if a group spans a break.
+func splitParagraphs(body string) ([]rtfParagraph, bool) {
+	var paras []rtfParagraph
+	pard := ""
+	chars := map[string]string{}
+	depth, start := 0, 0
+
+	add := func(end int) {
+		raw := body[start:end]
+		inherited := pard
+		for _, word := range []string{"f", "fs", "cf"} {
+			if n, ok := chars[word]; ok {
+				inherited += `\` + word + n
+			}
+		}
+		if inherited != "" {
+			inherited += " "
+		}
+		paras = append(paras, rtfParagraph{raw: raw, inherited: inherited})
+
+		top := removeNestedGroups(raw)
+		if runs := pardRunRe.FindAllString(top, -1); len(runs) > 0 {
+			pard = strings.TrimSpace(runs[len(runs)-1])
+		}
+		for _, m := range charStateRe.FindAllStringSubmatch(top, -1) {
+			chars[m[1]] = m[2]
+		}
+		start = end
+	}
+
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+		case '\\':
+			if i+1 >= len(body) {
+				continue
+			}
+			next := body[i+1]
+			if next == '\n' || next == '\r' {
+				if depth > 0 {
+					return nil, false
+				}
+				end := skipNewline(body, i+1)
+				add(end)
+				i = end - 1
+				continue
+			}
+			if strings.HasPrefix(body[i:], `\par`) && (i+4 >= len(body) || !isLetter(body[i+4])) {
+				if depth > 0 {
+					return nil, false
+				}
+				end := i + 4
+				if end < len(body) && body[end] == ' ' {
+					end++
+				}
+				end = skipNewline(body, end)
+				add(end)
+				i = end - 1
+				continue
+			}
+			if !isLetter(next) {
+				i++ // escaped character
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, false
+	}
+	if start < len(body) {
+		add(len(body))
+	}
+	return paras, true
+}
+
+// removeNestedGroups returns raw without any {...} groups, leaving the
+// formatting that applies at the top level.
+func removeNestedGroups(raw string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			if depth == 0 {
+				b.WriteByte(raw[i])
+				if i+1 < len(raw) && !isLetter(raw[i+1]) {
+					b.WriteByte(raw[i+1])
+				}
+			}
+			if i+1 < len(raw) && !isLetter(raw[i+1]) {
+				i++
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+		default:
+			if depth == 0 {
+				b.WriteByte(raw[i])
+			}
+		}
+	}
+	return b.String()
+}
+
+// skipNewline returns the index past a newline at i, if there is one.
+func skipNewline(text string, i int) int {
+	if strings.HasPrefix(text[i:], "\r\n") {
+		return i + 2
+	}
+	if i < len(text) && text[i] == '\n' {
+		return i + 1
+	}
+	return i
+}
+
+// hasTerminator reports whether an RTF paragraph ends with a paragraph break.
+func hasTerminator(raw string) bool {
+	raw = strings.TrimRight(raw, " \r\n")
+	return strings.HasSuffix(raw, `\par`) || strings.HasSuffix(raw, `\`)
+}
+
+// lineOp is one step of a line diff: old >= 0 and new >= 0 keeps a line,
+// only old >= 0 deletes it, only new >= 0 inserts one.
+type lineOp struct {
+	old, new int
+}
+
+// diffLines computes a line diff from old to new using the longest common
+// subsequence of the lines between their common prefix and suffix. It
+// reports false when the changed region is too large to diff.
+func diffLines(old, new []string) ([]lineOp, bool) {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	a := old[prefix : len(old)-suffix]
+	b := new[prefix : len(new)-suffix]
+	if (len(a)+1)*(len(b)+1) > maxPatchCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []lineOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, lineOp{i, i})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, lineOp{prefix + i, prefix + j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			ops = append(ops, lineOp{-1, prefix + j})
+			j++
+		default:
+			ops = append(ops, lineOp{prefix + i, -1})
+			i++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, lineOp{len(old) - suffix + k, len(new) - suffix + k})
+	}
+
+	return ops, true
+}
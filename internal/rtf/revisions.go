@@ -0,0 +1,216 @@
+package rtf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ways of converting Scrivener's revision marks (Options.Revisions). Text
+// typed in revision mode is colored in the revision's color, and text
+// deleted in it is also struck through.
+const (
+	RevisionsCritic = "critic" // CriticMarkup: {++inserted++} and {--deleted--}, and back
+	RevisionsStrip  = "strip"  // accept the revisions: keep insertions as plain text, drop deletions
+)
+
+// revisionPalette holds Scrivener's revision colors: red, blue, green,
+// purple and orange, for the first to fifth revisions.
+var revisionPalette = [][3]int{{255, 0, 0}, {0, 0, 255}, {0, 128, 0}, {128, 0, 128}, {255, 128, 0}}
+
+// revisionTolerance is how far each channel of a color may be from a
+// revision color and still count as it, as color tables store the colors
+// calibrated.
+const revisionTolerance = 64
+
+var (
+	// colorTableRe matches the color table, whose entries end in ";"
+	colorTableRe = regexp.MustCompile(`\{\\colortbl([^}]*)\}`)
+	// colorEntryRe matches the components of a color table entry
+	colorEntryRe = regexp.MustCompile(`\\red(\d+)\\green(\d+)\\blue(\d+)`)
+	// foregroundRe matches a foreground color change
+	foregroundRe = regexp.MustCompile(`\\cf(\d+) ?`)
+	// strikeOnRe matches strikethrough being turned on
+	strikeOnRe = regexp.MustCompile(`\\strike(?:[^a-z0-9]|$)`)
+	// strikeControlRe matches the strikethrough control words
+	strikeControlRe = regexp.MustCompile(`\\strike[a-z]*\d* ?`)
+	// leadingFormatRe and trailingFormatRe match the control words and
+	// whitespace at either end of a run of text
+	leadingFormatRe  = regexp.MustCompile(`^(?:\\[a-z]+-?\d* ?|\s)*`)
+	trailingFormatRe = regexp.MustCompile(`(?:\\[a-z]+-?\d* ?|\s)*$`)
+	// criticInsertRe and criticDeleteRe match CriticMarkup once escaped for RTF
+	criticInsertRe = regexp.MustCompile(`\\\{\+\+(.+?)\+\+\\\}`)
+	criticDeleteRe = regexp.MustCompile(`\\\{--(.+?)--\\\}`)
+)
+
+// revisionColors returns the indexes of the color table entries of an RTF
+// document that are revision colors.
+func revisionColors(doc string) map[int]bool {
+	m := colorTableRe.FindStringSubmatch(doc)
+	if m == nil {
+		return nil
+	}
+	colors := make(map[int]bool)
+	for i, entry := range strings.Split(m[1], ";") {
+		c := colorEntryRe.FindStringSubmatch(entry)
+		if c == nil {
+			continue
+		}
+		r, _ := strconv.Atoi(c[1])
+		g, _ := strconv.Atoi(c[2])
+		b, _ := strconv.Atoi(c[3])
+		for _, rev := range revisionPalette {
+			if abs(r-rev[0]) <= revisionTolerance && abs(g-rev[1]) <= revisionTolerance && abs(b-rev[2]) <= revisionTolerance {
+				colors[i] = true
+				break
+			}
+		}
+	}
+	return colors
+}
+
+// revisionColorIndex returns the index of the first revision color in an
+// RTF document's color table, or -1 if it has none.
+func revisionColorIndex(doc string) int {
+	index := -1
+	for i := range revisionColors(doc) {
+		if index < 0 || i < index {
+			index = i
+		}
+	}
+	return index
+}
+
+// HasRevisions reports whether an RTF document has text in a revision color.
+func HasRevisions(doc string) bool {
+	colors := revisionColors(doc)
+	for _, m := range foregroundRe.FindAllStringSubmatch(doc, -1) {
+		if n, _ := strconv.Atoi(m[1]); colors[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// convertRevisions marks the text of an RTF document that is in a revision
+// color, before it is converted to markdown. With RevisionsCritic each run of
+// it, paragraph by paragraph, is wrapped in stand-ins for CriticMarkup; with
+// RevisionsStrip deleted runs are removed.
+func convertRevisions(doc, mode string) string {
+	colors := revisionColors(doc)
+	if len(colors) == 0 {
+		return doc
+	}
+	var b strings.Builder
+	pos := 0
+	for _, loc := range foregroundRe.FindAllStringSubmatchIndex(doc, -1) {
+		if loc[0] < pos {
+			continue // inside a run already converted
+		}
+		n, _ := strconv.Atoi(doc[loc[2]:loc[3]])
+		if !colors[n] {
+			continue
+		}
+		end := revisionRunEnd(doc, loc[1])
+		b.WriteString(doc[pos:loc[1]])
+		b.WriteString(markRevisionRun(doc[loc[1]:end], mode))
+		pos = end
+	}
+	b.WriteString(doc[pos:])
+	return b.String()
+}
+
+// revisionRunEnd returns where the colored run starting at start ends: at
+// the next color change, or where the group it is in closes.
+func revisionRunEnd(doc string, start int) int {
+	depth := 0
+	for i := start; i < len(doc); i++ {
+		switch doc[i] {
+		case '\\':
+			if strings.HasPrefix(doc[i:], `\cf`) && i+3 < len(doc) && doc[i+3] >= '0' && doc[i+3] <= '9' {
+				return i
+			}
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(doc)
+}
+
+// markRevisionRun marks a colored run, each paragraph of it separately.
+func markRevisionRun(run, mode string) string {
+	deleted := strikeOnRe.MatchString(run)
+	var b strings.Builder
+	for {
+		loc := parBreakRe.FindStringIndex(run)
+		if loc == nil {
+			b.WriteString(markRevisionText(run, mode, deleted))
+			return b.String()
+		}
+		b.WriteString(markRevisionText(run[:loc[0]], mode, deleted))
+		b.WriteString(run[loc[0]:loc[1]])
+		run = run[loc[1]:]
+	}
+}
+
+// parBreakRe matches a paragraph break: \par, or a backslash ending a line.
+var parBreakRe = regexp.MustCompile(`\\par(?:[^a-z]|$)|\\\r?\n`)
+
+// markRevisionText marks the text of one paragraph of a colored run, leaving
+// the formatting and whitespace around it outside the markup.
+func markRevisionText(seg, mode string, deleted bool) string {
+	if deleted {
+		seg = strikeControlRe.ReplaceAllString(seg, "")
+	}
+	lead := leadingFormatRe.FindString(seg)
+	rest := seg[len(lead):]
+	trail := rest[trailingFormatRe.FindStringIndex(rest)[0]:]
+	text := rest[:len(rest)-len(trail)]
+	if text == "" {
+		return seg
+	}
+	switch {
+	case mode == RevisionsStrip && deleted:
+		return lead + trail
+	case mode == RevisionsStrip:
+		return seg
+	case deleted:
+		return lead + deleteStart + text + deleteEnd + trail
+	default:
+		return lead + insertStart + text + insertEnd + trail
+	}
+}
+
+// The CriticMarkup of revisions stands in for it until the conversion to
+// markdown is done; braces would be removed along with RTF groups.
+const (
+	insertStart = "\ue000"
+	insertEnd   = "\ue001"
+	deleteStart = "\ue002"
+	deleteEnd   = "\ue003"
+)
+
+// revisionMarkup replaces the stand-ins with CriticMarkup.
+var revisionMarkup = strings.NewReplacer(insertStart, "{++", insertEnd, "++}", deleteStart, "{--", deleteEnd, "--}")
+
+// criticToRTF turns the CriticMarkup insertions and deletions of converted
+// RTF into text in the revision color at index color.
+func criticToRTF(doc string, color int) string {
+	doc = criticInsertRe.ReplaceAllString(doc, fmt.Sprintf(`{\cf%d $1}`, color))
+	return criticDeleteRe.ReplaceAllString(doc, fmt.Sprintf(`{\cf%d\strike\strikec%d $1}`, color, color))
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
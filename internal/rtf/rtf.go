@@ -11,23 +11,77 @@ var (
 	// headerRe matches RTF header sections like {\fonttbl...} and {\colortbl...}
 	headerRe = regexp.MustCompile(`\{\\(fonttbl|colortbl|stylesheet|info)[^}]*\}`)
 	// controlWordRe matches RTF control words like \par, \b0, etc.
-	controlWordRe = regexp.MustCompile(`\\[a-z]+\d*\s?`)
+	controlWordRe = regexp.MustCompile(`\\[a-z]+-?\d*\s?`)
 	// multiSpaceRe matches multiple spaces (but not newlines)
 	multiSpaceRe = regexp.MustCompile(`[ \t]+`)
 	// multiNewlineRe matches 3+ consecutive newlines
 	multiNewlineRe = regexp.MustCompile(`\n{3,}`)
 
 	// Markdown patterns
-	headingRe    = regexp.MustCompile(`(?m)^(#{1,3})\s+(.+)$`)
-	boldRe       = regexp.MustCompile(`\*\*([^*]+)\*\*`)
-	italicRe     = regexp.MustCompile(`\*([^*]+)\*`)
-	bulletRe     = regexp.MustCompile(`(?m)^-\s+(.+)$`)
+	headingRe  = regexp.MustCompile(`(?m)^(#{1,3})\s+(.+)$`)
+	boldRe     = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicRe   = regexp.MustCompile(`\*([^*]+)\*`)
+	strikeRe   = regexp.MustCompile(`~~([^\s~](?:[^~]*[^\s~])?)~~`)
+	underRe    = regexp.MustCompile(`<u>(.+?)</u>`)
+	markRe     = regexp.MustCompile(`==([^\s=](?:[^=]*[^\s=])?)==`)
+	listItemRe = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.+)$`)
 
 	// RTF formatting patterns for extraction
 	rtfBoldRe   = regexp.MustCompile(`\{\\b\s*([^}]*)\}`)
 	rtfItalicRe = regexp.MustCompile(`\{\\i\s*([^}]*)\}`)
+
+	// RTF strikethrough, underline and highlight runs, in group form ({\strike x})
+	// and inline form (\strike x\strike0)
+	rtfStrikeRe          = regexp.MustCompile(`\{\\strike\b\s*([^}]*)\}`)
+	rtfStrikeInlineRe    = regexp.MustCompile(`\\strike\s+([^\\]+)\\strike0 ?`)
+	rtfUnderRe           = regexp.MustCompile(`\{\\ul\b(?:\\ulc\d+)?\s*([^}]*)\}`)
+	rtfUnderInlineRe     = regexp.MustCompile(`\\ul\b(?:\s*\\ulc\d+)?\s+([^\\]+)\\(?:ulnone|ul0) ?`)
+	rtfHighlightRe       = regexp.MustCompile(`\{\\highlight[1-9]\d*\s*([^}]*)\}`)
+	rtfHighlightInlineRe = regexp.MustCompile(`\\highlight[1-9]\d*\s+([^\\]+)\\highlight0 ?`)
+
+	// RTF list patterns
+	listTextRe   = regexp.MustCompile(`\{\\listtext([^}]*)\}`)
+	listLevelRe  = regexp.MustCompile(`\\ilvl(\d+)`)
+	listNumberRe = regexp.MustCompile(`(\d+)[.)]`)
+	legacyBullet = regexp.MustCompile(`\\bullet\s*`)
+)
+
+// Underline markup styles for RTF-to-markdown conversion.
+const (
+	UnderlineHTML   = "html"   // <u>text</u>
+	UnderlineIgnore = "ignore" // drop the underline, keep the text
 )
 
+// Options controls conversion between RTF and markdown.
+type Options struct {
+	Underline string   // UnderlineHTML or UnderlineIgnore
+	Template  Template // formatting of generated RTF
+	Patch     bool     // update existing documents paragraph by paragraph (see PatchRTF)
+	Revisions string   // RevisionsCritic or RevisionsStrip; "" drops revision colors like any other color
+}
+
+// DefaultOptions returns the default conversion options.
+func DefaultOptions() Options {
+	return Options{Underline: UnderlineHTML, Template: DefaultTemplate()}
+}
+
+// listHeader declares a bullet list (\ls1) and a numbered list (\ls2), each
+// with two levels, in the form Scrivener writes them.
+const listHeader = `{\*\listtable` +
+	`{\list\listtemplateid1\listhybrid` +
+	`{\listlevel\levelnfc23\levelnfcn23\leveljc0\leveljcn0\levelfollow0\levelstartat1\levelspace360\levelindent0{\*\levelmarker \{disc\}}{\leveltext\leveltemplateid1\'01\uc0\u8226 ;}{\levelnumbers;}\fi-360\li720\lin720 }` +
+	`{\listlevel\levelnfc23\levelnfcn23\leveljc0\leveljcn0\levelfollow0\levelstartat1\levelspace360\levelindent0{\*\levelmarker \{circle\}}{\leveltext\leveltemplateid2\'01\uc0\u9702 ;}{\levelnumbers;}\fi-360\li1440\lin1440 }` +
+	`{\listname ;}\listid1}` +
+	`{\list\listtemplateid2\listhybrid` +
+	`{\listlevel\levelnfc0\levelnfcn0\leveljc0\leveljcn0\levelfollow0\levelstartat1\levelspace360\levelindent0{\*\levelmarker \{decimal\}.}{\leveltext\leveltemplateid101\'02\'00.;}{\levelnumbers\'01;}\fi-360\li720\lin720 }` +
+	`{\listlevel\levelnfc0\levelnfcn0\leveljc0\leveljcn0\levelfollow0\levelstartat1\levelspace360\levelindent0{\*\levelmarker \{decimal\}.}{\leveltext\leveltemplateid102\'02\'01.;}{\levelnumbers\'01;}\fi-360\li1440\lin1440 }` +
+	`{\listname ;}\listid2}}` +
+	`{\*\listoverridetable{\listoverride\listid1\listoverridecount0\ls1}{\listoverride\listid2\listoverridecount0\ls2}}`
+
+// ignoredGroups are destination groups that carry no text. They may contain
+// nested braces, so they are removed by brace matching rather than regex.
+var ignoredGroups = []string{"listtable", "listoverridetable", "expandedcolortbl", "stylesheet", "info"}
+
 // StripRTF converts RTF content to plain text by removing RTF formatting.
 func StripRTF(rtfContent string) string {
 	text := rtfContent
@@ -98,58 +152,125 @@ func ToRTF(text string) string {
 }
 
 // MarkdownToRTF converts markdown content to RTF format for Scrivener.
-// Handles: headings, bold, italic, and bullet lists.
+// Handles: headings, bold, italic, bullet and numbered lists, and tables.
 func MarkdownToRTF(md string) string {
+	return MarkdownToRTFWithOptions(md, DefaultOptions())
+}
+
+// MarkdownToRTFWithOptions is MarkdownToRTF using the formatting in
+// opts.Template.
+func MarkdownToRTFWithOptions(md string, opts Options) string {
+	t := opts.Template
+
 	// RTF header
 	rtf := `{\rtf1\ansi\ansicpg1252\cocoartf2709`
 	rtf += `\cocoatextscaling0\cocoaplatform0`
-	rtf += `{\fonttbl\f0\fnil\fcharset0 Helvetica;}`
-	rtf += `{\colortbl;\red255\green255\blue255;}`
-	rtf += "\n"
+	rtf += `{\fonttbl\f0\fnil\fcharset0 ` + escapeRTF(t.fontName()) + `;}`
+	if opts.Revisions == RevisionsCritic {
+		rtf += `{\colortbl;\red255\green255\blue255;\red255\green255\blue0;\red255\green0\blue0;}`
+	} else {
+		rtf += `{\colortbl;\red255\green255\blue255;\red255\green255\blue0;}`
+	}
+	rtf += t.stylesheet()
 
 	// Process line by line to handle block-level elements
 	lines := strings.Split(md, "\n")
 	var result []string
+	hasLists := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Fenced ```rtf blocks hold RTF passed through verbatim
+		if strings.TrimSpace(line) == "```rtf" {
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "```" {
+				end++
+			}
+			result = append(result, strings.Join(lines[i+1:min(end, len(lines))], "\n"))
+			i = end
+			continue
+		}
 
-	for _, line := range lines {
-		converted := convertMarkdownLine(line)
+		if isTableStart(lines, i) {
+			converted, consumed := markdownTableToRTF(lines[i:], t)
+			result = append(result, converted)
+			i += consumed - 1
+			continue
+		}
+
+		if converted, ok := convertListLine(line, t); ok {
+			result = append(result, converted)
+			hasLists = true
+			continue
+		}
+		converted := convertMarkdownLine(line, t)
 		result = append(result, converted)
 	}
 
+	if hasLists {
+		rtf += listHeader
+	}
+	rtf += "\n"
+
 	// Join with RTF paragraph breaks
 	content := strings.Join(result, `\par` + "\n")
+	if opts.Revisions == RevisionsCritic {
+		content = criticToRTF(content, 3) // red, the first revision color
+	}
 
 	rtf += content + "}"
 	return rtf
 }
 
 // convertMarkdownLine converts a single markdown line to RTF.
-func convertMarkdownLine(line string) string {
+func convertMarkdownLine(line string, t Template) string {
 	// Check for headings
 	if matches := headingRe.FindStringSubmatch(line); matches != nil {
 		level := len(matches[1]) // Number of # characters
 		text := matches[2]
 		text = convertInlineFormatting(escapeRTF(text))
 
-		// Font sizes: H1=36pt, H2=30pt, H3=26pt (RTF uses half-points)
-		sizes := map[int]int{1: 72, 2: 60, 3: 52}
-		fontSize := sizes[level]
-		if fontSize == 0 {
-			fontSize = 52
-		}
+		// Font sizes default to H1=36pt, H2=30pt, H3=26pt (RTF uses half-points)
+		return fmt.Sprintf(`\pard\s%d\f0\fs%d\b %s\b0\fs%d`, level, t.headingSize(level), text, t.bodySize())
+	}
 
-		return fmt.Sprintf(`\pard\f0\fs%d\b %s\b0\fs24`, fontSize, text)
+	// Regular paragraph
+	text := convertInlineFormatting(escapeRTF(line))
+	return t.paragraphPrefix() + text
+}
+
+// convertListLine converts a markdown list item ("- item", "1. item",
+// optionally indented one level) to an RTF list paragraph. It reports false
+// for lines that are not list items.
+func convertListLine(line string, t Template) (string, bool) {
+	matches := listItemRe.FindStringSubmatch(line)
+	if matches == nil {
+		return "", false
 	}
 
-	// Check for bullet points
-	if matches := bulletRe.FindStringSubmatch(line); matches != nil {
-		text := convertInlineFormatting(escapeRTF(matches[1]))
-		return `\pard\li360\f0\fs24 \bullet  ` + text
+	indent := len(strings.ReplaceAll(matches[1], "\t", "    "))
+	level := 0
+	if indent >= 2 {
+		level = 1
 	}
 
-	// Regular paragraph
-	text := convertInlineFormatting(escapeRTF(line))
-	return `\pard\f0\fs24 ` + text
+	marker := matches[2]
+	text := convertInlineFormatting(escapeRTF(matches[3]))
+
+	list := 1
+	listText := `\tab \bullet \tab`
+	if level == 1 {
+		listText = `\tab \uc0\u9702 \tab`
+	}
+	if m := listNumberRe.FindStringSubmatch(marker); m != nil {
+		list = 2
+		listText = `\tab ` + m[1] + `.\tab`
+	}
+
+	li := 720 * (level + 1)
+	return fmt.Sprintf(`\pard\tx%d\tx%d\li%d\fi-720\ls%d\ilvl%d\f0\fs%d {\listtext%s}%s`,
+		li-500, li, li, list, level, t.bodySize(), listText, text), true
 }
 
 // convertInlineFormatting converts bold and italic markdown to RTF.
@@ -161,6 +282,11 @@ func convertInlineFormatting(text string) string {
 	// Be careful not to match already-converted bold markers
 	text = italicRe.ReplaceAllString(text, `{\i $1}`)
 
+	// Convert ~~strike~~, <u>underline</u> and ==highlight== (yellow, color 2)
+	text = strikeRe.ReplaceAllString(text, `{\strike $1}`)
+	text = underRe.ReplaceAllString(text, `{\ul $1}`)
+	text = markRe.ReplaceAllString(text, `{\highlight2 $1}`)
+
 	return text
 }
 
@@ -173,13 +299,31 @@ func escapeRTF(text string) string {
 }
 
 // RTFToMarkdown converts RTF content to markdown, preserving formatting.
-// Handles: bold, italic, and basic structure.
+// Handles: bold, italic, strikethrough, underline, highlight, lists, tables,
+// and basic structure.
 func RTFToMarkdown(rtfContent string) string {
+	return RTFToMarkdownWithOptions(rtfContent, DefaultOptions())
+}
+
+// RTFToMarkdownWithOptions is RTFToMarkdown with configurable markup choices.
+func RTFToMarkdownWithOptions(rtfContent string, opts Options) string {
 	text := rtfContent
 
+	// Read heading styles before the stylesheet is removed
+	styles := parseHeadingStyles(text)
+
+	// Mark text in revision colors before the color table is removed
+	if opts.Revisions != "" {
+		text = convertRevisions(text, opts.Revisions)
+	}
+
 	// Remove RTF header sections (font tables, color tables, etc.)
+	text = removeGroups(text, ignoredGroups...)
 	text = headerRe.ReplaceAllString(text, "")
 
+	// Convert tables first; they are restored once the rest is converted
+	text, tables := convertTables(text, opts)
+
 	// Convert bold: {\b text} or \b text\b0 to **text**
 	// Handle nested braces format
 	text = rtfBoldRe.ReplaceAllString(text, "**$1**")
@@ -190,6 +334,20 @@ func RTFToMarkdown(rtfContent string) string {
 	text = rtfItalicRe.ReplaceAllString(text, "*$1*")
 	text = regexp.MustCompile(`\\i\s+([^\\]+)\\i0`).ReplaceAllString(text, "*$1*")
 
+	// Convert strikethrough to ~~text~~ and highlight to ==text==
+	text = rtfStrikeRe.ReplaceAllString(text, "~~$1~~")
+	text = rtfStrikeInlineRe.ReplaceAllString(text, "~~$1~~")
+	text = rtfHighlightRe.ReplaceAllString(text, "==$1==")
+	text = rtfHighlightInlineRe.ReplaceAllString(text, "==$1==")
+
+	// Convert underline to <u>text</u>, or drop it
+	underline := "<u>$1</u>"
+	if opts.Underline == UnderlineIgnore {
+		underline = "$1"
+	}
+	text = rtfUnderRe.ReplaceAllString(text, underline)
+	text = rtfUnderInlineRe.ReplaceAllString(text, underline)
+
 	// Convert RTF line breaks to newlines
 	// Use regex to match \par only when followed by space, newline, or non-letter
 	// This avoids matching \pard, \pardirnatural, \partightenfactor, etc.
@@ -204,9 +362,12 @@ func RTFToMarkdown(rtfContent string) string {
 	text = strings.ReplaceAll(text, "\\\n", "\n")
 	text = strings.ReplaceAll(text, "\\\r\n", "\n")
 
-	// Handle font size changes for headings
+	// Convert list paragraphs to markdown list items
+	text = convertListParagraphs(text)
+
+	// Convert heading paragraphs, by style or by font size
 	// \fs72 = 36pt = H1, \fs60 = 30pt = H2, \fs52 = 26pt = H3
-	text = convertFontSizesToHeadings(text)
+	text = convertHeadings(text, styles)
 
 	// Remove remaining RTF control words
 	text = controlWordRe.ReplaceAllString(text, "")
@@ -257,16 +418,113 @@ func RTFToMarkdown(rtfContent string) string {
 	text = multiSpaceRe.ReplaceAllString(text, " ")
 	text = multiNewlineRe.ReplaceAllString(text, "\n\n")
 
-	// Trim each line
+	// Trim each line, then restore list nesting indentation
 	lines := strings.Split(text, "\n")
 	for i, line := range lines {
-		lines[i] = strings.TrimSpace(line)
+		line = strings.TrimSpace(line)
+		lines[i] = strings.ReplaceAll(line, listIndentMark, " ")
 	}
 	text = strings.Join(lines, "\n")
+	text = restorePlaceholders(text, tables)
+	text = revisionMarkup.Replace(text)
 
 	return strings.TrimSpace(text)
 }
 
+// listIndentMark stands in for list nesting indentation until lines have
+// been trimmed. It is not whitespace, so trimming leaves it alone.
+const listIndentMark = "\x01"
+
+// convertListParagraphs turns RTF list paragraphs into markdown list items.
+// Paragraphs with a {\listtext} group use it for the marker ("1." for
+// numbered lists, "-" otherwise) and \ilvl for nesting; older \bullet
+// paragraphs become "-" items.
+func convertListParagraphs(text string) string {
+	lines := strings.Split(text, "\n")
+	parentWidth := 2
+
+	for i, line := range lines {
+		if m := listTextRe.FindStringSubmatch(line); m != nil {
+			marker := "-"
+			if num := listNumberRe.FindStringSubmatch(controlWordRe.ReplaceAllString(m[1], "")); num != nil {
+				marker = num[1] + "."
+			}
+
+			level := 0
+			if lvl := listLevelRe.FindStringSubmatch(line); lvl != nil {
+				fmt.Sscanf(lvl[1], "%d", &level)
+			}
+
+			indent := ""
+			if level == 0 {
+				parentWidth = len(marker) + 1
+			} else {
+				indent = strings.Repeat(listIndentMark, parentWidth*level)
+			}
+
+			// The marker replaces the listtext group; control words before it are removed later
+			lines[i] = listTextRe.ReplaceAllLiteralString(line, " "+indent+marker+" ")
+			continue
+		}
+
+		if legacyBullet.MatchString(line) {
+			lines[i] = legacyBullet.ReplaceAllLiteralString(line, " - ")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// removeGroups removes every {\name ...} or {\*\name ...} group for the given
+// destination names, matching nested braces and skipping escaped ones.
+func removeGroups(text string, names ...string) string {
+	for _, name := range names {
+		for _, prefix := range []string{`{\*\` + name, `{\` + name} {
+			for {
+				start := strings.Index(text, prefix)
+				if start < 0 {
+					break
+				}
+				// Require a full control word match (e.g. not \listtables)
+				if next := start + len(prefix); next < len(text) && isLetter(text[next]) {
+					break
+				}
+				end := matchingBrace(text, start)
+				if end < 0 {
+					break
+				}
+				text = text[:start] + text[end+1:]
+			}
+		}
+	}
+	return text
+}
+
+// matchingBrace returns the index of the brace closing the group that opens
+// at start, or -1 if the group is unterminated.
+func matchingBrace(text string, start int) int {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++ // skip escaped character
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isLetter reports whether b is an ASCII letter.
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
 // convertFontSizesToHeadings converts RTF font size markers to markdown headings.
 func convertFontSizesToHeadings(text string) string {
 	// Pattern: \fsNN followed by text until next \fs or end
@@ -277,17 +535,11 @@ func convertFontSizesToHeadings(text string) string {
 	for _, line := range lines {
 		// Check for large font size at start of line
 		if strings.Contains(line, "\\fs72") || strings.Contains(line, "\\fs68") {
-			// H1 - remove the font size marker and prefix with #
-			line = regexp.MustCompile(`\\fs\d+\s*`).ReplaceAllString(line, "")
-			line = "# " + strings.TrimSpace(line)
+			line = headingLine(1, line)
 		} else if strings.Contains(line, "\\fs60") || strings.Contains(line, "\\fs56") {
-			// H2
-			line = regexp.MustCompile(`\\fs\d+\s*`).ReplaceAllString(line, "")
-			line = "## " + strings.TrimSpace(line)
+			line = headingLine(2, line)
 		} else if strings.Contains(line, "\\fs52") || strings.Contains(line, "\\fs48") {
-			// H3
-			line = regexp.MustCompile(`\\fs\d+\s*`).ReplaceAllString(line, "")
-			line = "### " + strings.TrimSpace(line)
+			line = headingLine(3, line)
 		}
 		result = append(result, line)
 	}
@@ -203,6 +203,59 @@ func TestMarkdownToRTF_Bullets(t *testing.T) {
 	}
 }
 
+func TestMarkdownToRTF_ListTable(t *testing.T) {
+	md := "1. First\n2. Second\n   - Nested"
+
+	result := MarkdownToRTF(md)
+
+	for _, want := range []string{`{\*\listtable`, `{\*\listoverridetable`, `\ls2\ilvl0`, `\ls1\ilvl1`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %s in result, got: %s", want, result)
+		}
+	}
+
+	if strings.Contains(MarkdownToRTF("Just text"), "listtable") {
+		t.Error("Expected no list table for content without lists")
+	}
+}
+
+func TestMarkdownToRTF_ListRoundtrip(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+	}{
+		{"bullets", "- First item\n- Second item"},
+		{"numbered", "1. First\n2. Second\n3. Third"},
+		{"nested bullets", "- Parent\n  - Child\n- Sibling"},
+		{"nested under numbered", "1. Parent\n   - Child\n2. Next"},
+		{"inline formatting", "- Some **bold** text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RTFToMarkdown(MarkdownToRTF(tt.md))
+			if result != tt.md {
+				t.Errorf("List did not round-trip.\nExpected:\n%s\n\nGot:\n%s", tt.md, result)
+			}
+		})
+	}
+}
+
+func TestRTFToMarkdown_ScrivenerList(t *testing.T) {
+	rtf := `{\rtf1\ansi{\fonttbl\f0\fnil Helvetica;}` +
+		`{\*\listtable{\list\listtemplateid1\listhybrid{\listlevel\levelnfc23{\*\levelmarker \{disc\}}{\leveltext\'01\uc0\u8226 ;}{\levelnumbers;}\fi-360\li720\lin720 }{\listname ;}\listid1}}` +
+		`{\*\listoverridetable{\listoverride\listid1\listoverridecount0\ls1}}` + "\n" +
+		`\pard\tx220\tx720\li720\fi-720\pardirnatural\partightenfactor0\ls1\ilvl0\cf0 {\listtext\tab \uc0\u8226 \tab}Apples\` + "\n" +
+		`{\listtext\tab \uc0\u8226 \tab}Pears}`
+
+	result := RTFToMarkdown(rtf)
+
+	expected := "- Apples\n- Pears"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
 func TestMarkdownToRTF_Roundtrip(t *testing.T) {
 	// Simple text should survive a roundtrip
 	original := "Hello World"
@@ -279,3 +332,313 @@ func TestToRTF_EscapesSpecialCharacters(t *testing.T) {
 		t.Errorf("Backslash should be escaped, got: %s", result)
 	}
 }
+
+func TestMarkdownToRTF_TableRoundtrip(t *testing.T) {
+	md := "Intro\n\n| Name | Role |\n| --- | :---: |\n| **Ana** | Hero |\n| Coach | Mentor \\| guide |\n\nOutro"
+
+	rtf := MarkdownToRTF(md)
+	for _, want := range []string{`\trowd`, `\cellx4320`, `\cellx8640`, `\intbl\qc`, `\row`} {
+		if !strings.Contains(rtf, want) {
+			t.Errorf("Expected %s in RTF, got: %s", want, rtf)
+		}
+	}
+
+	result := RTFToMarkdown(rtf)
+	if result != md {
+		t.Errorf("Table did not round-trip.\nExpected:\n%s\n\nGot:\n%s", md, result)
+	}
+}
+
+func TestRTFToMarkdown_ScrivenerTable(t *testing.T) {
+	rtf := `{\rtf1\ansi{\fonttbl\f0\fnil Helvetica;}` + "\n" +
+		`\itap1\trowd \taflags1 \trgaph108\trleft-108 \clvertalc \clshdrawnil \cellx4320` + "\n" +
+		`\clvertalc \clshdrawnil \cellx8640` + "\n" +
+		`\pard\intbl\itap1\pardeftab720\partightenfactor0` + "\n" +
+		`\f0\fs24 \cf0 Year\cell ` + "\n" +
+		`\pard\intbl\itap1\pardeftab720\partightenfactor0` + "\n" +
+		`\cf0 Event\cell \row` + "\n" +
+		`\itap1\trowd \taflags1 \trgaph108\trleft-108 \clvertalc \clshdrawnil \cellx4320` + "\n" +
+		`\clvertalc \clshdrawnil \cellx8640` + "\n" +
+		`\pard\intbl\itap1\pardeftab720\partightenfactor0` + "\n" +
+		`\cf0 1999\cell ` + "\n" +
+		`\pard\intbl\itap1\pardeftab720\partightenfactor0` + "\n" +
+		`\cf0 The {\b big} game\cell \lastrow\row` + "\n" +
+		`\pard\pardeftab720\partightenfactor0` + "\n" +
+		`\cf0 After the table.}`
+
+	result := RTFToMarkdown(rtf)
+
+	expected := "| Year | Event |\n| --- | --- |\n| 1999 | The **big** game |\n\nAfter the table."
+	if result != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
+
+func TestRTFToMarkdown_ComplexTablePassthrough(t *testing.T) {
+	table := `\trowd\clmgf\cellx2000\clmrg\cellx4000` + "\n" + `\pard\intbl Merged\cell \cell\row`
+	rtf := `{\rtf1\ansi` + "\n" + table + "}"
+
+	result := RTFToMarkdown(rtf)
+
+	expected := "```rtf\n" + table + "\n```"
+	if result != expected {
+		t.Fatalf("Expected fenced passthrough:\n%s\n\nGot:\n%s", expected, result)
+	}
+
+	// Pushing the fenced block back emits the original table
+	if !strings.Contains(MarkdownToRTF(result), table) {
+		t.Errorf("Expected passthrough RTF to be emitted verbatim, got: %s", MarkdownToRTF(result))
+	}
+}
+
+func TestRTFToMarkdown_StrikeUnderlineHighlight(t *testing.T) {
+	rtf := `{\rtf1\ansi\f0\fs24 Was {\strike old} now \strike gone\strike0 , ` +
+		`\ul \ulc0 stressed\ulnone  and {\highlight3 marked}.}`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"html underline", Options{Underline: UnderlineHTML}, "Was ~~old~~ now ~~gone~~, <u>stressed</u> and ==marked==."},
+		{"ignore underline", Options{Underline: UnderlineIgnore}, "Was ~~old~~ now ~~gone~~, stressed and ==marked==."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RTFToMarkdownWithOptions(rtf, tt.opts)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMarkdownToRTF_StrikeUnderlineHighlight(t *testing.T) {
+	md := "A ~~struck~~ and <u>underlined</u> and ==highlighted== word"
+
+	result := MarkdownToRTF(md)
+
+	for _, want := range []string{`{\strike struck}`, `{\ul underlined}`, `{\highlight2 highlighted}`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %s in result, got: %s", want, result)
+		}
+	}
+
+	if back := RTFToMarkdown(result); back != md {
+		t.Errorf("Expected round-trip to %q, got %q", md, back)
+	}
+}
+
+func TestMarkdownToRTF_StrikeHighlightNeedText(t *testing.T) {
+	// Delimiters with a space inside are prose, not a strike or highlight
+	for _, md := range []string{"a == b and c == d", "x ~~ y ~~ z", "=== not a heading ==="} {
+		result := MarkdownToRTF(md)
+		if strings.Contains(result, `\strike`) || strings.Contains(result, `\highlight`) {
+			t.Errorf("Expected no strike or highlight for %q, got: %s", md, result)
+		}
+		if back := RTFToMarkdown(result); back != md {
+			t.Errorf("Expected round-trip to %q, got %q", md, back)
+		}
+	}
+}
+
+func TestRTFToMarkdown_HeadingStyles(t *testing.T) {
+	rtf := `{\rtf1\ansi{\fonttbl\f0\fnil Helvetica;}` +
+		`{\stylesheet{\s0 Normal;}{\s1\fs28\b Heading 1;}{\s2\fs26\b Heading 2;}{\*\cs10\i Heading 3;}{\s5\fs72 Big Quote;}}` + "\n" +
+		`\pard\s1\f0\fs28\b Chapter\b0\par` + "\n" +
+		`\pard\s5\fs72 Not a heading despite the size\par` + "\n" +
+		`\pard\s2\fs26 Scene\par` + "\n" +
+		`\pard\s0\fs24 Body with {\*\cs10 an inline style}\par` + "\n" +
+		`\pard\s0\fs24 {\*\cs10 Minor}}`
+
+	result := RTFToMarkdown(rtf)
+
+	expected := "# Chapter\nNot a heading despite the size\n## Scene\nBody with an inline style\n### Minor"
+	if result != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
+
+func TestMarkdownToRTF_HeadingRoundtrip(t *testing.T) {
+	md := "# Title\n\nBody with **bold** text\n\n## Section\n\n### Scene"
+
+	rtf := MarkdownToRTF(md)
+	if !strings.Contains(rtf, `{\s1\fs72\b Heading 1;}`) || !strings.Contains(rtf, `\pard\s2`) {
+		t.Errorf("Expected heading styles in RTF, got: %s", rtf)
+	}
+
+	if result := RTFToMarkdown(rtf); result != md {
+		t.Errorf("Headings did not round-trip.\nExpected:\n%s\n\nGot:\n%s", md, result)
+	}
+}
+
+func TestMarkdownToRTFWithOptions_Template(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Template = Template{Font: "Palatino", FontSize: 14, HeadingSizes: []int{20, 18, 16}, FirstLineIndent: 18}
+
+	result := MarkdownToRTFWithOptions("# Title\nBody text", opts)
+
+	for _, want := range []string{`\fcharset0 Palatino;`, `{\s1\fs40\b Heading 1;}`, `\pard\s1\f0\fs40\b Title\b0\fs28`, `\pard\fi360\f0\fs28 Body text`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %s in result, got: %s", want, result)
+		}
+	}
+
+	if back := RTFToMarkdown(result); back != "# Title\nBody text" {
+		t.Errorf("Expected template output to round-trip, got %q", back)
+	}
+}
+
+func TestDetectTemplate(t *testing.T) {
+	rtf := `{\rtf1\ansi{\fonttbl\f0\fnil\fcharset0 Palatino-Roman;\f1\fswiss Helvetica;}` +
+		`{\colortbl;\red255\green255\blue255;}` + "\n" +
+		`\pard\fi360\f0\fs26 One\par` + "\n" +
+		`\pard\fi360\f0\fs26 Two\par` + "\n" +
+		`\pard\f0\fs48 Heading}`
+
+	got := DetectTemplate(rtf)
+
+	if got.Font != "Palatino-Roman" || got.FontSize != 13 || got.FirstLineIndent != 18 {
+		t.Errorf("Unexpected template: %+v", got)
+	}
+	if len(got.HeadingSizes) != 0 {
+		t.Errorf("Expected heading sizes to be left unset, got %v", got.HeadingSizes)
+	}
+}
+
+const scrivenerDoc = `{\rtf1\ansi\ansicpg1252\cocoartf2709\cocoatextscaling0\cocoaplatform0{\fonttbl\f0\fnil\fcharset0 Palatino;}` +
+	`{\colortbl;\red255\green255\blue255;\red200\green0\blue0;}` + "\n" +
+	`\paperw11900\paperh16840\margl1440\margr1440\vieww11520\viewh8400\viewkind0` + "\n" +
+	`\pard\tx720\fi360\pardirnatural\partightenfactor0` + "\n" +
+	"\n" +
+	`\f0\fs26 \cf2 First paragraph in red.\` + "\n" +
+	`Second paragraph with {\cb2 background}.\` + "\n" +
+	`Third paragraph.}`
+
+func TestPatchRTF_KeepsUnchangedParagraphs(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		keep []string
+	}{
+		{
+			name: "edit last paragraph",
+			md:   "First paragraph in red.\nSecond paragraph with background.\nThird paragraph, edited.",
+			keep: []string{`\f0\fs26 \cf2 First paragraph in red.\` + "\n", `Second paragraph with {\cb2 background}.\` + "\n"},
+		},
+		{
+			name: "edit first paragraph",
+			md:   "First paragraph, edited.\nSecond paragraph with background.\nThird paragraph.",
+			keep: []string{`\fi360\pardirnatural\partightenfactor0\f0\fs26\cf2 Second paragraph with {\cb2 background}.\` + "\n", `Third paragraph.}`},
+		},
+		{
+			name: "insert and delete",
+			md:   "First paragraph in red.\nA new paragraph.\nThird paragraph.",
+			keep: []string{`\cf2 First paragraph in red.\` + "\n", `Third paragraph.}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PatchRTF(scrivenerDoc, tt.md, DefaultOptions())
+
+			if !strings.HasPrefix(result, `{\rtf1\ansi\ansicpg1252\cocoartf2709\cocoatextscaling0\cocoaplatform0{\fonttbl\f0\fnil\fcharset0 Palatino;}`) {
+				t.Errorf("Expected the original header to be kept, got: %s", result)
+			}
+			for _, want := range tt.keep {
+				if !strings.Contains(result, want) {
+					t.Errorf("Expected unchanged RTF %q to be kept, got: %s", want, result)
+				}
+			}
+			if back := RTFToMarkdown(result); back != tt.md {
+				t.Errorf("Expected patched document to read back as %q, got %q", tt.md, back)
+			}
+		})
+	}
+}
+
+func TestPatchRTF_FallsBackForTables(t *testing.T) {
+	existing := MarkdownToRTF("| A | B |\n| --- | --- |\n| 1 | 2 |")
+	md := "Just text now"
+
+	if result := PatchRTF(existing, md, DefaultOptions()); result != MarkdownToRTF(md) {
+		t.Errorf("Expected a regenerated document, got: %s", result)
+	}
+}
+
+const revisedDoc = `{\rtf1\ansi{\fonttbl\f0\fnil Helvetica;}{\colortbl;\red255\green255\blue255;\red251\green2\blue7;\red120\green120\blue120;}` + "\n" +
+	`\pard\f0\fs24 The story \cf2 really \cf0 begins \cf2 \strike \strikec2 here\strike0\striked0 \cf0  now.\par` + "\n" +
+	`Some \cf3 grey\cf0  text, and \cf2 a new sentence.\par` + "\n" +
+	`Another one.}`
+
+func TestRTFToMarkdown_Revisions(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "The story really begins here now.\nSome grey text, and a new sentence.\nAnother one."},
+		{RevisionsCritic, "The story {++really++} begins {--here--} now.\nSome grey text, and {++a new sentence.++}\n{++Another one.++}"},
+		{RevisionsStrip, "The story really begins now.\nSome grey text, and a new sentence.\nAnother one."},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Revisions = tt.mode
+		if got := RTFToMarkdownWithOptions(revisedDoc, opts); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.mode, tt.want, got)
+		}
+	}
+	if !HasRevisions(revisedDoc) || HasRevisions(MarkdownToRTF("Plain.")) {
+		t.Error("Expected only the revised document to have revisions")
+	}
+}
+
+func TestMarkdownToRTF_Revisions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Revisions = RevisionsCritic
+	md := "Kept {++added++} and {--removed--} text."
+
+	result := MarkdownToRTFWithOptions(md, opts)
+	for _, want := range []string{`\red255\green0\blue0;}`, `{\cf3 added}`, `{\cf3\strike\strikec3 removed}`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %s in result, got: %s", want, result)
+		}
+	}
+	if back := RTFToMarkdownWithOptions(result, opts); back != md {
+		t.Errorf("Expected revisions to round-trip, got %q", back)
+	}
+
+	patched := PatchRTF(revisedDoc, "The story {++really++} begins {--here--} now.\nSome grey text, and {++a new sentence.++}\nAnother {++edited++} one.", Options{Revisions: RevisionsCritic, Template: DefaultTemplate(), Patch: true})
+	if !strings.Contains(patched, `Another {\cf2 edited} one.`) {
+		t.Errorf("Expected the edit in the document's revision color, got: %s", patched)
+	}
+}
+
+// benchmarkMarkdown returns about 16KB of markdown with headings, lists and
+// inline formatting.
+func benchmarkMarkdown() string {
+	var b strings.Builder
+	b.WriteString("# Chapter\n\n")
+	for b.Len() < 16*1024 {
+		b.WriteString("The harbor lantern was **bright** and the *river* ran cold past the old road.\n\n")
+		b.WriteString("- a small promise\n- a long evening\n\n")
+		b.WriteString("## Scene\n\n")
+	}
+	return b.String()
+}
+
+func BenchmarkMarkdownToRTF(b *testing.B) {
+	md := benchmarkMarkdown()
+	b.SetBytes(int64(len(md)))
+	for i := 0; i < b.N; i++ {
+		MarkdownToRTF(md)
+	}
+}
+
+func BenchmarkRTFToMarkdown(b *testing.B) {
+	content := MarkdownToRTF(benchmarkMarkdown())
+	b.SetBytes(int64(len(content)))
+	for i := 0; i < b.N; i++ {
+		RTFToMarkdown(content)
+	}
+}
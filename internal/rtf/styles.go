@@ -0,0 +1,109 @@
+package rtf
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// styleEntryRe matches a paragraph ({\s1 ...}) or character ({\*\cs10 ...})
+	// style definition inside the stylesheet
+	styleEntryRe = regexp.MustCompile(`\{(?:\\\*)?\\(s|cs)(\d+)([^{}]*)\}`)
+	// headingStyleRe matches heading style names like "Heading 1"
+	headingStyleRe = regexp.MustCompile(`(?i)^heading\s*([1-6])$`)
+	// paragraphStyleRe and charStyleRe match style references in the body
+	paragraphStyleRe = regexp.MustCompile(`\\s(\d+)(?:[^a-z0-9]|$)`)
+	charStyleRe      = regexp.MustCompile(`\\cs(\d+)(?:[^a-z0-9]|$)`)
+	charStyleGroupRe = regexp.MustCompile(`\{(?:\\\*)?\\cs\d+[^{}]*\}`)
+	// fontSizeRe matches font size control words
+	fontSizeRe = regexp.MustCompile(`\\fs\d+\s*`)
+)
+
+// headingStyles maps style references ("s1", "cs10") to heading levels.
+type headingStyles map[string]int
+
+// parseHeadingStyles reads the document's stylesheet and returns the styles
+// whose names mark them as headings ("Heading 1" through "Heading 6").
+func parseHeadingStyles(text string) headingStyles {
+	styles := headingStyles{}
+
+	start := -1
+	for _, prefix := range []string{`{\stylesheet`, `{\*\stylesheet`} {
+		if idx := strings.Index(text, prefix); idx >= 0 {
+			start = idx
+			break
+		}
+	}
+	if start < 0 {
+		return styles
+	}
+	end := matchingBrace(text, start)
+	if end < 0 {
+		return styles
+	}
+
+	for _, m := range styleEntryRe.FindAllStringSubmatch(text[start+1:end], -1) {
+		name := strings.TrimSpace(controlWordRe.ReplaceAllString(m[3], ""))
+		name = strings.TrimSpace(strings.TrimSuffix(name, ";"))
+		if h := headingStyleRe.FindStringSubmatch(name); h != nil {
+			level, _ := strconv.Atoi(h[1])
+			styles[m[1]+m[2]] = level
+		}
+	}
+
+	return styles
+}
+
+// convertHeadings turns heading paragraphs into markdown headings. When the
+// document defines heading styles, paragraphs are matched by style: a
+// paragraph style set with \sN lasts until the next \pard, and a character
+// style \csN counts when it spans the whole line. Only documents without heading styles
+// fall back to guessing from font sizes.
+func convertHeadings(text string, styles headingStyles) string {
+	if len(styles) == 0 {
+		return convertFontSizesToHeadings(text)
+	}
+
+	lines := strings.Split(text, "\n")
+	current := 0 // heading level of the current paragraph style
+
+	for i, line := range lines {
+		if strings.Contains(line, `\pard`) {
+			current = 0
+		}
+		if m := paragraphStyleRe.FindAllStringSubmatch(line, -1); m != nil {
+			current = styles["s"+m[len(m)-1][1]]
+		}
+
+		level := current
+		if m := charStyleRe.FindStringSubmatch(line); m != nil && styles["cs"+m[1]] > 0 {
+			// A character style only makes a heading when it spans the whole line
+			rest := charStyleGroupRe.ReplaceAllString(line, "")
+			if strings.Trim(controlWordRe.ReplaceAllString(rest, ""), " \t{}") == "" {
+				level = styles["cs"+m[1]]
+			}
+		}
+
+		if level == 0 || strings.Trim(controlWordRe.ReplaceAllString(line, ""), " \t{}") == "" {
+			continue
+		}
+		lines[i] = headingLine(level, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// headingLine formats an RTF paragraph as a markdown heading. Font sizes are
+// dropped, as is bold covering the whole heading, since headings are bold
+// by style.
+func headingLine(level int, line string) string {
+	line = fontSizeRe.ReplaceAllString(line, "")
+
+	visible := strings.Trim(controlWordRe.ReplaceAllString(line, ""), " \t{}")
+	if strings.HasPrefix(visible, "**") && strings.HasSuffix(visible, "**") && strings.Count(visible, "**") == 2 {
+		line = strings.ReplaceAll(line, "**", "")
+	}
+
+	return strings.Repeat("#", level) + " " + strings.TrimSpace(line)
+}
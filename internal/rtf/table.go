@@ -0,0 +1,282 @@
+package rtf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tableWidth is the total width of generated tables in twips (6 inches).
+const tableWidth = 8640
+
+var (
+	// tableSeparatorRe matches a markdown table delimiter row like | --- | :-: |
+	tableSeparatorRe = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	// complexTableRe matches RTF table features that markdown tables cannot express:
+	// merged cells and nested tables.
+	complexTableRe = regexp.MustCompile(`\\(clmgf|clmrg|clvmgf|clvmrg|nestcell|nestrow|itap[2-9])`)
+	// rowContinuationRe matches what may separate two rows of the same table.
+	rowContinuationRe = regexp.MustCompile(`^\s*(\\itap\d+\s?)?\\trowd`)
+	// Paragraph alignment control words inside a cell
+	centerAlignRe = regexp.MustCompile(`\\qc\b`)
+	rightAlignRe  = regexp.MustCompile(`\\qr\b`)
+)
+
+// placeholderMark delimits blocks that are converted up front and must not be
+// touched by the rest of the RTF-to-markdown pipeline.
+const placeholderMark = "\x02"
+
+// convertTables replaces RTF tables with placeholders and returns the markdown
+// for each placeholder. Simple tables become GitHub-flavored markdown tables;
+// tables with merged cells, nested tables, uneven rows or multi-paragraph
+// cells are kept verbatim in a fenced ```rtf block.
+func convertTables(text string, opts Options) (string, []string) {
+	var blocks []string
+	var out strings.Builder
+
+	for {
+		start := strings.Index(text, `\trowd`)
+		if start < 0 {
+			break
+		}
+		end := rowEnd(text, start)
+		if end < 0 {
+			break
+		}
+
+		// Extend over consecutive rows
+		for rowContinuationRe.MatchString(text[end:]) {
+			next := rowEnd(text, end)
+			if next < 0 {
+				break
+			}
+			end = next
+		}
+
+		raw := text[start:end]
+		md, ok := tableToMarkdown(raw, opts)
+		if !ok {
+			md = "```rtf\n" + strings.TrimSpace(raw) + "\n```"
+		}
+
+		out.WriteString(text[:start])
+		out.WriteString(fmt.Sprintf("\n%s%d%s\n", placeholderMark, len(blocks), placeholderMark))
+		blocks = append(blocks, md)
+		text = text[end:]
+	}
+
+	out.WriteString(text)
+	return out.String(), blocks
+}
+
+// restorePlaceholders substitutes converted blocks back into text.
+func restorePlaceholders(text string, blocks []string) string {
+	for i, block := range blocks {
+		text = strings.Replace(text, fmt.Sprintf("%s%d%s", placeholderMark, i, placeholderMark), block, 1)
+	}
+	return text
+}
+
+// rowEnd returns the index just past the \row control word that ends the row
+// beginning at or after start, or -1 if there is none.
+func rowEnd(text string, start int) int {
+	for i := start; ; {
+		idx := strings.Index(text[i:], `\row`)
+		if idx < 0 {
+			return -1
+		}
+		end := i + idx + len(`\row`)
+		if end >= len(text) || !isLetter(text[end]) {
+			return end
+		}
+		i = end
+	}
+}
+
+// splitCells splits the RTF of one table row into the raw content of its
+// cells. \cellx (cell boundary definitions) is not a cell terminator.
+func splitCells(row string) []string {
+	var cells []string
+	last := 0
+	for i := 0; ; {
+		idx := strings.Index(row[i:], `\cell`)
+		if idx < 0 {
+			break
+		}
+		pos := i + idx
+		end := pos + len(`\cell`)
+		i = end
+		if end < len(row) && isLetter(row[end]) {
+			continue
+		}
+		cells = append(cells, row[last:pos])
+		last = end
+	}
+	return cells
+}
+
+// splitRows splits the RTF of a table into its rows.
+func splitRows(table string) []string {
+	var rows []string
+	for {
+		start := strings.Index(table, `\trowd`)
+		if start < 0 {
+			break
+		}
+		end := rowEnd(table, start)
+		if end < 0 {
+			break
+		}
+		rows = append(rows, table[start:end])
+		table = table[end:]
+	}
+	return rows
+}
+
+// tableToMarkdown converts an RTF table to a markdown table. It reports false
+// when the table is too complex to represent.
+func tableToMarkdown(raw string, opts Options) (string, bool) {
+	if complexTableRe.MatchString(raw) {
+		return "", false
+	}
+
+	rows := splitRows(raw)
+	if len(rows) == 0 {
+		return "", false
+	}
+
+	var lines []string
+	var aligns []string
+	columns := -1
+
+	for r, row := range rows {
+		cells := splitCells(row)
+		if len(cells) == 0 || (columns >= 0 && len(cells) != columns) {
+			return "", false
+		}
+		columns = len(cells)
+
+		var texts []string
+		for _, cell := range cells {
+			text := RTFToMarkdownWithOptions(cell, opts)
+			if strings.Contains(text, "\n") {
+				return "", false
+			}
+			texts = append(texts, strings.ReplaceAll(text, "|", `\|`))
+
+			if r == 0 {
+				aligns = append(aligns, cellAlignment(cell))
+			}
+		}
+		lines = append(lines, "| "+strings.Join(texts, " | ")+" |")
+
+		if r == 0 {
+			lines = append(lines, "| "+strings.Join(aligns, " | ")+" |")
+		}
+	}
+
+	return strings.Join(lines, "\n"), true
+}
+
+// cellAlignment returns the markdown delimiter for a cell's paragraph alignment.
+func cellAlignment(cell string) string {
+	switch {
+	case centerAlignRe.MatchString(cell):
+		return ":---:"
+	case rightAlignRe.MatchString(cell):
+		return "---:"
+	default:
+		return "---"
+	}
+}
+
+// isTableStart reports whether lines[i] begins a markdown table: a pipe row
+// followed by a delimiter row.
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) {
+		return false
+	}
+	line := strings.TrimSpace(lines[i])
+	return strings.HasPrefix(line, "|") && tableSeparatorRe.MatchString(strings.TrimSpace(lines[i+1])) &&
+		strings.Contains(lines[i+1], "-")
+}
+
+// splitMarkdownRow splits a markdown table row into cell texts, honoring
+// escaped pipes.
+func splitMarkdownRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(line[i])
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// markdownTableToRTF converts the markdown table starting at lines[0] to RTF
+// table rows. It returns the RTF and the number of lines consumed.
+func markdownTableToRTF(lines []string, t Template) (string, int) {
+	header := splitMarkdownRow(lines[0])
+	columns := len(header)
+
+	var aligns []string
+	for _, spec := range splitMarkdownRow(lines[1]) {
+		switch {
+		case strings.HasPrefix(spec, ":") && strings.HasSuffix(spec, ":"):
+			aligns = append(aligns, `\qc`)
+		case strings.HasSuffix(spec, ":"):
+			aligns = append(aligns, `\qr`)
+		default:
+			aligns = append(aligns, `\ql`)
+		}
+	}
+
+	rows := [][]string{header}
+	consumed := 2
+	for consumed < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[consumed]), "|") {
+		rows = append(rows, splitMarkdownRow(lines[consumed]))
+		consumed++
+	}
+
+	var def strings.Builder
+	def.WriteString(`\trowd\trgaph108`)
+	for c := 1; c <= columns; c++ {
+		def.WriteString(fmt.Sprintf(`\cellx%d`, tableWidth*c/columns))
+	}
+
+	var out []string
+	for _, row := range rows {
+		var b strings.Builder
+		b.WriteString(def.String())
+		b.WriteString("\n")
+		for c := 0; c < columns; c++ {
+			text, align := "", `\ql`
+			if c < len(row) {
+				text = convertInlineFormatting(escapeRTF(row[c]))
+			}
+			if c < len(aligns) {
+				align = aligns[c]
+			}
+			b.WriteString(fmt.Sprintf(`\pard\intbl%s\f0\fs%d %s\cell`, align, t.bodySize(), text))
+		}
+		b.WriteString(`\row`)
+		out = append(out, b.String())
+	}
+
+	return strings.Join(out, "\n"), consumed
+}
@@ -0,0 +1,145 @@
+package rtf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// fontEntryRe matches a font table entry like \f0\fnil\fcharset0 Helvetica;
+	fontEntryRe = regexp.MustCompile(`\\f(\d+)(?:\\[a-z]+-?\d*)*\s*([^;\\{}]+);`)
+	// bodySizeRe and firstIndentRe match font sizes and positive first-line indents
+	bodySizeRe    = regexp.MustCompile(`\\fs(\d+)`)
+	firstIndentRe = regexp.MustCompile(`\\fi(\d+)`)
+)
+
+// Template describes the formatting of RTF generated from markdown. Sizes
+// and indents are in points.
+type Template struct {
+	Font            string
+	FontSize        int
+	HeadingSizes    []int // for #, ## and ###
+	FirstLineIndent int
+}
+
+// DefaultTemplate returns the built-in formatting: Helvetica 12pt with
+// 36/30/26pt headings and no first-line indent.
+func DefaultTemplate() Template {
+	return Template{
+		Font:         "Helvetica",
+		FontSize:     12,
+		HeadingSizes: []int{36, 30, 26},
+	}
+}
+
+// Merge returns t with its unset fields taken from other.
+func (t Template) Merge(other Template) Template {
+	if t.Font == "" {
+		t.Font = other.Font
+	}
+	if t.FontSize == 0 {
+		t.FontSize = other.FontSize
+	}
+	if len(t.HeadingSizes) == 0 {
+		t.HeadingSizes = other.HeadingSizes
+	}
+	if t.FirstLineIndent == 0 {
+		t.FirstLineIndent = other.FirstLineIndent
+	}
+	return t
+}
+
+// bodySize returns the body font size in RTF half-points.
+func (t Template) bodySize() int {
+	if t.FontSize <= 0 {
+		return 24
+	}
+	return t.FontSize * 2
+}
+
+// headingSize returns the font size for a heading level in RTF half-points.
+func (t Template) headingSize(level int) int {
+	if level >= 1 && level <= len(t.HeadingSizes) && t.HeadingSizes[level-1] > 0 {
+		return t.HeadingSizes[level-1] * 2
+	}
+	return DefaultTemplate().HeadingSizes[min(level, 3)-1] * 2
+}
+
+// fontName returns the font family, defaulting to Helvetica.
+func (t Template) fontName() string {
+	if t.Font == "" {
+		return DefaultTemplate().Font
+	}
+	return t.Font
+}
+
+// stylesheet names the styles used for headings so they can be told apart
+// from body text by style rather than by size.
+func (t Template) stylesheet() string {
+	sheet := `{\stylesheet{\s0 Normal;}`
+	for level := 1; level <= 3; level++ {
+		sheet += fmt.Sprintf(`{\s%d\fs%d\b Heading %d;}`, level, t.headingSize(level), level)
+	}
+	return sheet + "}"
+}
+
+// paragraphPrefix returns the control words that start a body paragraph.
+func (t Template) paragraphPrefix() string {
+	if t.FirstLineIndent > 0 {
+		return fmt.Sprintf(`\pard\fi%d\f0\fs%d `, t.FirstLineIndent*20, t.bodySize())
+	}
+	return fmt.Sprintf(`\pard\f0\fs%d `, t.bodySize())
+}
+
+// DetectTemplate infers the font, body size and first-line indent used by an
+// existing RTF document, so new documents can match it. Fields that cannot
+// be determined are left unset.
+func DetectTemplate(rtfContent string) Template {
+	var t Template
+
+	if start := strings.Index(rtfContent, `{\fonttbl`); start >= 0 {
+		if end := matchingBrace(rtfContent, start); end > 0 {
+			if m := fontEntryRe.FindStringSubmatch(rtfContent[start:end]); m != nil {
+				t.Font = strings.TrimSpace(m[2])
+			}
+		}
+	}
+
+	body := removeGroups(rtfContent, append(ignoredGroups, "fonttbl", "colortbl")...)
+	if size := mostCommon(bodySizeRe, body); size > 0 {
+		t.FontSize = size / 2
+	}
+	if indent := mostCommon(firstIndentRe, body); indent > 0 {
+		t.FirstLineIndent = indent / 20
+	}
+
+	return t
+}
+
+// mostCommon returns the most frequent numeric argument matched by re in
+// text, preferring the smaller value on ties, or 0 if there is none.
+func mostCommon(re *regexp.Regexp, text string) int {
+	counts := make(map[int]int)
+	for _, m := range re.FindAllStringSubmatch(text, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			counts[n]++
+		}
+	}
+
+	values := make([]int, 0, len(counts))
+	for n := range counts {
+		values = append(values, n)
+	}
+	sort.Ints(values)
+
+	best, bestCount := 0, 0
+	for _, n := range values {
+		if counts[n] > bestCount {
+			best, bestCount = n, counts[n]
+		}
+	}
+	return best
+}
@@ -0,0 +1,250 @@
+package scrivener
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+// MetadataField names a binder item metadata field for SetMetadata.
+type MetadataField string
+
+// Metadata fields SetMetadata can set. Values are stored as Scrivener writes
+// them: IDs for the label, status and section type, the icon's name, and
+// "Yes" or "No" for IncludeInCompile.
+const (
+	MetaIcon             MetadataField = "IconFileName"
+	MetaLabel            MetadataField = "LabelID"
+	MetaStatus           MetadataField = "StatusID"
+	MetaIncludeInCompile MetadataField = "IncludeInCompile"
+	MetaSectionType      MetadataField = "SectionType"
+)
+
+// SetMetadata sets one metadata field of a binder item; an empty value
+// clears it.
+func (w *Writer) SetMetadata(uuid string, field MetadataField, value string) error {
+	var get func(*XMLMetaData) *string
+	switch field {
+	case MetaIcon:
+		get = func(md *XMLMetaData) *string { return &md.IconFileName }
+	case MetaLabel:
+		get = func(md *XMLMetaData) *string { return &md.LabelID }
+	case MetaStatus:
+		get = func(md *XMLMetaData) *string { return &md.StatusID }
+	case MetaIncludeInCompile:
+		get = func(md *XMLMetaData) *string { return &md.IncludeInCompile }
+	case MetaSectionType:
+		get = func(md *XMLMetaData) *string { return &md.SectionType }
+	default:
+		return fmt.Errorf("unknown metadata field: %s", field)
+	}
+	return w.setMetaData(uuid, value, get)
+}
+
+// SetIncludeInCompile sets whether a binder item is included in compile.
+func (w *Writer) SetIncludeInCompile(uuid string, include bool) error {
+	value := "No"
+	if include {
+		value = "Yes"
+	}
+	return w.SetMetadata(uuid, MetaIncludeInCompile, value)
+}
+
+// InDraft reports whether a binder item is in the Draft folder.
+func (w *Writer) InDraft(uuid string) bool {
+	for i := range w.project.Binder.Items {
+		if item := &w.project.Binder.Items[i]; item.Type == "DraftFolder" {
+			return item.UUID == uuid || w.findInItems(item.Children, uuid) != nil
+		}
+	}
+	return false
+}
+
+// SetSynopsis sets the synopsis (index card text) of a binder item; an empty
+// synopsis removes it. An unchanged synopsis isn't rewritten.
+func (w *Writer) SetSynopsis(uuid, synopsis string) error {
+	if w.findBinderItem(uuid) == nil {
+		return fmt.Errorf("binder item not found: %s", uuid)
+	}
+	synopsis = strings.TrimSpace(synopsis)
+
+	// Kept beside the content in the layout the item already uses
+	path := filepath.Join(w.filesDir, uuid, "synopsis.txt")
+	if old := filepath.Join(w.filesDir, uuid+"_synopsis.txt"); statOK(old) {
+		path = old
+	}
+	data, err := w.readFile(path)
+	if err == nil && strings.TrimSpace(string(data)) == synopsis {
+		return nil
+	}
+	if synopsis == "" {
+		if err == nil {
+			w.removeFile(path)
+		}
+		return nil
+	}
+	w.writeFile(path, []byte(synopsis))
+	return nil
+}
+
+// statOK reports whether a path exists.
+func statOK(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// MoveItem moves a binder item, with its children, under parentUUID ("" for
+// the binder root) at position index among the parent's children. An index
+// out of range, such as -1, appends it. Moving within the same parent
+// reorders the item.
+func (w *Writer) MoveItem(uuid, parentUUID string, index int) error {
+	item := w.findBinderItem(uuid)
+	if item == nil {
+		return fmt.Errorf("binder item %s not found", uuid)
+	}
+	if parentUUID != "" {
+		if parentUUID == uuid || w.findInItems(item.Children, parentUUID) != nil {
+			return fmt.Errorf("cannot move binder item %s into itself", uuid)
+		}
+		if w.findBinderItem(parentUUID) == nil {
+			return fmt.Errorf("parent UUID not found: %s", parentUUID)
+		}
+	}
+
+	moved, _ := detachItem(&w.project.Binder.Items, uuid)
+	siblings := &w.project.Binder.Items
+	if parentUUID != "" {
+		siblings = &w.findBinderItem(parentUUID).Children
+	}
+	if index < 0 || index > len(*siblings) {
+		index = len(*siblings)
+	}
+	*siblings = append(*siblings, XMLBinderItem{})
+	copy((*siblings)[index+1:], (*siblings)[index:])
+	(*siblings)[index] = moved
+
+	w.modified = true
+	return nil
+}
+
+// Position returns the UUID of a binder item's parent ("" for the binder
+// root) and its index among the parent's children, or false if the binder
+// has no such item.
+func (w *Writer) Position(uuid string) (string, int, bool) {
+	return findPosition(w.project.Binder.Items, "", uuid)
+}
+
+func findPosition(items []XMLBinderItem, parentUUID, uuid string) (string, int, bool) {
+	for i := range items {
+		if items[i].UUID == uuid {
+			return parentUUID, i, true
+		}
+		if parent, index, ok := findPosition(items[i].Children, items[i].UUID, uuid); ok {
+			return parent, index, true
+		}
+	}
+	return "", 0, false
+}
+
+// TrashItem moves a binder item, with its children, to the end of the
+// project's Trash folder, creating the Trash if the project has none.
+func (w *Writer) TrashItem(uuid string) error {
+	trashUUID := ""
+	for _, item := range w.project.Binder.Items {
+		if item.Type == "TrashFolder" {
+			trashUUID = item.UUID
+			break
+		}
+	}
+	if trashUUID == uuid {
+		return fmt.Errorf("cannot move the Trash folder to the Trash")
+	}
+	if trashUUID == "" {
+		trashUUID = w.generateUUID()
+		now := timestamp.FormatBinder(time.Now())
+		w.project.Binder.Items = append(w.project.Binder.Items, XMLBinderItem{
+			UUID:     trashUUID,
+			Type:     "TrashFolder",
+			Created:  now,
+			Modified: now,
+			Title:    "Trash",
+		})
+		w.existingUUIDs[trashUUID] = true
+	}
+	return w.MoveItem(uuid, trashUUID, -1)
+}
+
+// DeleteItem removes a binder item and its children from the project for
+// good, along with their favorites. Their content files are removed when
+// the project is saved.
+func (w *Writer) DeleteItem(uuid string) error {
+	item, ok := detachItem(&w.project.Binder.Items, uuid)
+	if !ok {
+		return fmt.Errorf("binder item %s not found", uuid)
+	}
+
+	removed := make(map[string]bool)
+	var collect func(item XMLBinderItem)
+	collect = func(item XMLBinderItem) {
+		removed[item.UUID] = true
+		w.deleted = append(w.deleted, item.UUID)
+		for _, child := range item.Children {
+			collect(child)
+		}
+	}
+	collect(item)
+
+	if w.project.Favorites != nil {
+		var kept []XMLFavorite
+		for _, fav := range w.project.Favorites.Items {
+			if !removed[fav.UUID()] {
+				kept = append(kept, fav)
+			}
+		}
+		w.project.Favorites.Items = kept
+	}
+
+	w.modified = true
+	return nil
+}
+
+// removeDeletedContent removes the content files of items deleted since
+// the last save: the Files/Data/<UUID>/ folder, or in the older layout the
+// Files/Data/<UUID>.* and <UUID>_*.txt files.
+func (w *Writer) removeDeletedContent() error {
+	for _, uuid := range w.deleted {
+		if err := os.RemoveAll(filepath.Join(w.filesDir, uuid)); err != nil {
+			return fmt.Errorf("failed to remove content of %s: %w", uuid, err)
+		}
+		for _, pattern := range []string{uuid + ".*", uuid + "_*.txt"} {
+			matches, _ := filepath.Glob(filepath.Join(w.filesDir, pattern))
+			for _, path := range matches {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to remove %s: %w", path, err)
+				}
+			}
+		}
+	}
+	w.deleted = nil
+	return nil
+}
+
+// detachItem removes the item with uuid from a binder item tree and
+// returns it.
+func detachItem(items *[]XMLBinderItem, uuid string) (XMLBinderItem, bool) {
+	for i := range *items {
+		if (*items)[i].UUID == uuid {
+			item := (*items)[i]
+			*items = append((*items)[:i], (*items)[i+1:]...)
+			return item, true
+		}
+		if item, ok := detachItem(&(*items)[i].Children, uuid); ok {
+			return item, true
+		}
+	}
+	return XMLBinderItem{}, false
+}
@@ -0,0 +1,229 @@
+package scrivener
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/rtf"
+)
+
+// Inspector comments are stored beside a Scrivener 3 document's content.rtf,
+// in content.comments, each as RTF under the ID its anchor in the document
+// links to: {\field{\*\fldinst{HYPERLINK "scrivcmt://ID"}}{\fldrslt text}}.
+// In markdown they are CriticMarkup comments on a highlight:
+// {==anchor text==}{>>comment<<}. While the document is converted, anchors
+// are plain-text markers so the conversion leaves them alone.
+
+var (
+	// commentFieldRe matches the opening of a comment anchor field
+	commentFieldRe = regexp.MustCompile(`\{\\field\s*\{\\\*\\fldinst\s*\{?\s*HYPERLINK\s+"scrivcmt://([^"]+)"\s*\}?\s*\}\s*\{\\fldrslt\b`)
+	// commentMarkerRe matches an anchor's markers and the text between them
+	commentMarkerRe = regexp.MustCompile(`\[\[scrivcmt:([^\]]+)\]\](.*?)\[\[/scrivcmt\]\]`)
+	// criticCommentRe matches a CriticMarkup comment on a highlight
+	criticCommentRe = regexp.MustCompile(`\{==(.+?)==\}\{>>(.*?)<<\}`)
+	// bareCommentRe matches a CriticMarkup comment without a highlight and
+	// the word before it, which becomes its anchor
+	bareCommentRe = regexp.MustCompile(`([^\s{}]+)\{>>(.*?)<<\}`)
+)
+
+// xmlComments is the content of a content.comments file.
+type xmlComments struct {
+	Version  string       `xml:"Version,attr"`
+	Comments []xmlComment `xml:"Comment"`
+}
+
+// xmlComment is an inspector comment or footnote. Attributes other than ID,
+// such as Footnote and Color, are kept as they were read.
+type xmlComment struct {
+	ID    string     `xml:"ID,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+	RTF   string     `xml:",cdata"`
+}
+
+// text returns a comment as the single line of plain text it has in
+// markdown.
+func (c xmlComment) text() string {
+	return strings.Join(strings.Fields(rtf.StripRTF(c.RTF)), " ")
+}
+
+// commentsPath returns the comments file of a Scrivener 3 content file, or
+// "" for projects in the older format.
+func commentsPath(contentPath string) string {
+	if !strings.HasPrefix(filepath.Base(contentPath), "content.") {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(contentPath), "content.comments")
+}
+
+// readComments reads a comments file. A missing file has no comments.
+func readComments(path string) ([]xmlComment, error) {
+	data, err := os.ReadFile(path)
+	return parseComments(path, data, err)
+}
+
+// readComments reads a comments file as it will be once staged changes are
+// saved. A missing file has no comments.
+func (w *Writer) readComments(path string) ([]xmlComment, error) {
+	data, err := w.readFile(path)
+	return parseComments(path, data, err)
+}
+
+// parseComments parses the data read from a comments file, or returns the
+// error reading it. A missing file has no comments.
+func parseComments(path string, data []byte, err error) ([]xmlComment, error) {
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var parsed xmlComments
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return parsed.Comments, nil
+}
+
+// writeComments stages a comments file, or its removal when there are no
+// comments left.
+func (w *Writer) writeComments(path string, comments []xmlComment) {
+	if len(comments) == 0 {
+		w.removeFile(path)
+		return
+	}
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n<Comments Version=\"1.0\">\n")
+	for _, c := range comments {
+		fmt.Fprintf(&b, "    <Comment ID=%q", c.ID)
+		for _, a := range c.Attrs {
+			fmt.Fprintf(&b, " %s=\"%s\"", a.Name.Local, escapeText(a.Value))
+		}
+		fmt.Fprintf(&b, "><![CDATA[%s]]></Comment>\n", c.RTF)
+	}
+	b.WriteString("</Comments>\n")
+	w.writeFile(path, []byte(b.String()))
+}
+
+// anchorsToMarkers replaces the comment anchor fields of an RTF document
+// with markers around their text, which the conversion keeps as it is.
+func anchorsToMarkers(doc string) string {
+	var b strings.Builder
+	for {
+		loc := commentFieldRe.FindStringSubmatchIndex(doc)
+		if loc == nil {
+			break
+		}
+		fieldEnd := matchingBraceAt(doc, loc[0])
+		resultEnd := matchingBraceAt(doc, loc[1]-len(`{\fldrslt`))
+		if fieldEnd < 0 || resultEnd < 0 || resultEnd > fieldEnd {
+			break
+		}
+		b.WriteString(doc[:loc[0]])
+		text := strings.TrimPrefix(doc[loc[1]:resultEnd], " ") // the space ending \fldrslt
+		if strings.Contains(text, `\`) {
+			text = "{" + text + "}" // keep its formatting to itself
+		}
+		fmt.Fprintf(&b, "[[scrivcmt:%s]]%s[[/scrivcmt]]", doc[loc[2]:loc[3]], text)
+		doc = doc[fieldEnd+1:]
+	}
+	b.WriteString(doc)
+	return b.String()
+}
+
+// markersToAnchors turns the markers in a converted RTF document back into
+// comment anchor fields. Markers whose text doesn't balance its braces are
+// dropped, keeping the text.
+func markersToAnchors(doc string) string {
+	return commentMarkerRe.ReplaceAllStringFunc(doc, func(m string) string {
+		sub := commentMarkerRe.FindStringSubmatch(m)
+		text := sub[2]
+		if matchingBraceAt("{"+text+"}", 0) != len(text)+1 {
+			return text
+		}
+		if strings.HasPrefix(text, "{") && matchingBraceAt(text, 0) == len(text)-1 {
+			text = text[1 : len(text)-1] // the field result is a group of its own
+		}
+		return fmt.Sprintf(`{\field{\*\fldinst{HYPERLINK "scrivcmt://%s"}}{\fldrslt %s}}`, sub[1], text)
+	})
+}
+
+// markersToCriticMarkup turns the markers in converted markdown into
+// CriticMarkup comments. Anchors without a comment keep only their text.
+func markersToCriticMarkup(md string, comments []xmlComment) string {
+	byID := make(map[string]xmlComment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+	return commentMarkerRe.ReplaceAllStringFunc(md, func(m string) string {
+		sub := commentMarkerRe.FindStringSubmatch(m)
+		c, ok := byID[sub[1]]
+		if !ok || strings.TrimSpace(sub[2]) == "" {
+			return sub[2]
+		}
+		return fmt.Sprintf("{==%s==}{>>%s<<}", sub[2], c.text())
+	})
+}
+
+// criticMarkupToMarkers replaces the CriticMarkup comments of markdown with
+// anchor markers and returns the comments they refer to, in order. Each
+// comment takes the ID, attributes and RTF of the first unused existing
+// comment with the same text, so unchanged comments keep their formatting
+// and footnotes stay footnotes; other comments get a new ID from newID.
+func criticMarkupToMarkers(md string, existing []xmlComment, newID func() string) (string, []xmlComment) {
+	used := make(map[int]bool)
+	var comments []xmlComment
+	mark := func(anchor, text string) string {
+		text = strings.Join(strings.Fields(text), " ")
+		c := xmlComment{}
+		for i, e := range existing {
+			if !used[i] && e.text() == text {
+				used[i] = true
+				c = e
+				break
+			}
+		}
+		if c.ID == "" {
+			c = xmlComment{
+				ID:    newID(),
+				Attrs: []xml.Attr{{Name: xml.Name{Local: "Footnote"}, Value: "No"}},
+				RTF:   rtf.ToRTF(text),
+			}
+		}
+		comments = append(comments, c)
+		return fmt.Sprintf("[[scrivcmt:%s]]%s[[/scrivcmt]]", c.ID, anchor)
+	}
+
+	md = criticCommentRe.ReplaceAllStringFunc(md, func(m string) string {
+		sub := criticCommentRe.FindStringSubmatch(m)
+		return mark(sub[1], sub[2])
+	})
+	md = bareCommentRe.ReplaceAllStringFunc(md, func(m string) string {
+		sub := bareCommentRe.FindStringSubmatch(m)
+		return mark(sub[1], sub[2])
+	})
+	return md, comments
+}
+
+// matchingBraceAt returns the index of the brace closing the RTF group that
+// opens at start, or -1 if it is unterminated.
+func matchingBraceAt(text string, start int) int {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,164 @@
+package scrivener
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// WritingDay is one day of the writing history Scrivener records.
+type WritingDay struct {
+	Date       string // YYYY-MM-DD
+	DraftWords int    // words written in the Draft folder
+	DraftChars int
+	OtherWords int // words written elsewhere in the project
+	OtherChars int
+}
+
+// xmlWritingHistory is a list of days, as found in the .scrivx
+// RecentWritingHistory section and in Files/writing.history.
+type xmlWritingHistory struct {
+	Days []xmlWritingDay `xml:"Day"`
+}
+
+// xmlWritingDay holds a day's counts; the date is an attribute or the
+// element's text.
+type xmlWritingDay struct {
+	Date  string `xml:"Date,attr"`
+	Value string `xml:",chardata"`
+	DWC   int    `xml:"DWC,attr"`
+	DCC   int    `xml:"DCC,attr"`
+	OWC   int    `xml:"OWC,attr"`
+	OCC   int    `xml:"OCC,attr"`
+}
+
+// WritingHistory returns the project's daily writing history, oldest first,
+// from Files/writing.history and the RecentWritingHistory section of the
+// .scrivx file. When both record a day, the writing.history entry is used.
+func (r *Reader) WritingHistory() ([]WritingDay, error) {
+	days := make(map[string]WritingDay)
+
+	if r.project.RecentWritingHistory != nil {
+		inner := r.project.RecentWritingHistory.InnerXML
+		if err := addWritingDays(days, append(append([]byte("<h>"), inner...), "</h>"...)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.scrivPath, "Files", "writing.history"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read writing history: %w", err)
+	}
+	if err == nil {
+		if err := addWritingDays(days, data); err != nil {
+			return nil, err
+		}
+	}
+
+	history := make([]WritingDay, 0, len(days))
+	for _, day := range days {
+		history = append(history, day)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Date < history[j].Date })
+	return history, nil
+}
+
+// addWritingDays parses a writing history document into days.
+func addWritingDays(days map[string]WritingDay, data []byte) error {
+	var h xmlWritingHistory
+	if err := xml.Unmarshal(data, &h); err != nil {
+		return fmt.Errorf("failed to parse writing history: %w", err)
+	}
+	for _, d := range h.Days {
+		date := d.Date
+		if date == "" {
+			date = strings.TrimSpace(d.Value)
+		}
+		if len(date) < 10 {
+			continue
+		}
+		date = date[:10] // drop any time of day
+		days[date] = WritingDay{Date: date, DraftWords: d.DWC, DraftChars: d.DCC, OtherWords: d.OWC, OtherChars: d.OCC}
+	}
+	return nil
+}
+
+// writingHistoryDayRe matches a day of a writing history document.
+var writingHistoryDayRe = regexp.MustCompile(`(?s)<Day\b[^>]*?(?:/>|>.*?</Day>)`)
+
+// emptyWritingHistory is a writing history document without days.
+const emptyWritingHistory = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<WritingHistory Version=\"1.0\">\n</WritingHistory>\n"
+
+// AddWrittenWords adds words written outside Scrivener to a day (YYYY-MM-DD)
+// of the project's writing history in Files/writing.history, as words
+// written in the Draft folder and elsewhere. A day's counts don't go below
+// zero, and its other days and counts are kept as Scrivener wrote them. The
+// change is written on Save.
+func (w *Writer) AddWrittenWords(date string, draftWords, otherWords int) error {
+	path := filepath.Join(w.scrivPath, "Files", "writing.history")
+	data, err := w.readFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read writing history: %w", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		data = []byte(emptyWritingHistory)
+	}
+	days := make(map[string]WritingDay)
+	if err := addWritingDays(days, data); err != nil {
+		return err
+	}
+	day, ok := days[date]
+	if !ok && w.project.RecentWritingHistory != nil {
+		// Start from the counts the .scrivx has for the day, which the new
+		// entry takes precedence over
+		recent := make(map[string]WritingDay)
+		inner := w.project.RecentWritingHistory.InnerXML
+		if err := addWritingDays(recent, append(append([]byte("<h>"), inner...), "</h>"...)); err == nil {
+			day = recent[date]
+		}
+	}
+	day.DraftWords += draftWords
+	if day.DraftWords < 0 {
+		day.DraftWords = 0
+	}
+	day.OtherWords += otherWords
+	if day.OtherWords < 0 {
+		day.OtherWords = 0
+	}
+
+	// Days are written as the file writes them: the date as an attribute
+	// or as the element's text
+	dateAttr := false
+	text := string(data)
+	for _, loc := range writingHistoryDayRe.FindAllStringIndex(text, -1) {
+		var d xmlWritingDay
+		if err := xml.Unmarshal([]byte(text[loc[0]:loc[1]]), &d); err != nil {
+			continue
+		}
+		dateAttr = d.Date != ""
+		if strings.HasPrefix(d.Date, date) || strings.HasPrefix(strings.TrimSpace(d.Value), date) {
+			w.writeFile(path, []byte(text[:loc[0]]+writingDayElement(day, date, dateAttr)+text[loc[1]:]))
+			return nil
+		}
+	}
+
+	end := strings.LastIndex(text, "</")
+	if end < 0 {
+		return fmt.Errorf("failed to parse writing history: no root element")
+	}
+	w.writeFile(path, []byte(text[:end]+"    "+writingDayElement(day, date, dateAttr)+"\n"+text[end:]))
+	return nil
+}
+
+// writingDayElement renders a day of a writing history document.
+func writingDayElement(day WritingDay, date string, dateAttr bool) string {
+	counts := fmt.Sprintf(`DWC="%d" DCC="%d" OWC="%d" OCC="%d"`, day.DraftWords, day.DraftChars, day.OtherWords, day.OtherChars)
+	if dateAttr {
+		return fmt.Sprintf(`<Day Date="%s" %s/>`, date, counts)
+	}
+	return fmt.Sprintf(`<Day %s>%s</Day>`, counts, date)
+}
@@ -0,0 +1,89 @@
+package scrivener
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrIntegrity is returned by a Writer asked to save into a project whose
+// binder is damaged, unless it was told to write anyway with SetForce.
+var ErrIntegrity = errors.New("Scrivener project has integrity problems")
+
+// binderIntegrity returns the problems of a binder that would make documents
+// map to the wrong items: UUIDs shared by several binder items, as crashes
+// and merges from iOS leave behind, and items without a UUID.
+func binderIntegrity(items []XMLBinderItem) []Issue {
+	var issues []Issue
+	seen := make(map[string]int)
+	inTrash := make(map[string]int)
+	var walk func(items []XMLBinderItem, trash bool)
+	walk = func(items []XMLBinderItem, trash bool) {
+		for _, item := range items {
+			itemTrash := trash || item.Type == "TrashFolder"
+			if item.UUID == "" {
+				issues = append(issues, Issue{IssueError, "", fmt.Sprintf("binder item '%s' has no UUID", item.Title)})
+			} else {
+				seen[item.UUID]++
+				if itemTrash {
+					inTrash[item.UUID]++
+				}
+			}
+			walk(item.Children, itemTrash)
+		}
+	}
+	walk(items, false)
+
+	uuids := make([]string, 0, len(seen))
+	for uuid, n := range seen {
+		if n > 1 {
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Strings(uuids)
+	for _, uuid := range uuids {
+		msg := fmt.Sprintf("UUID is used by %d binder items", seen[uuid])
+		if n := inTrash[uuid]; n > 0 && n < seen[uuid] {
+			msg += fmt.Sprintf(", %d of them in the Trash", n)
+		}
+		issues = append(issues, Issue{IssueError, uuid, msg})
+	}
+	return issues
+}
+
+// integrityError summarizes binder problems as an ErrIntegrity error.
+func integrityError(issues []Issue) error {
+	parts := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		parts = append(parts, issue.String())
+	}
+	return fmt.Errorf("%w: %s", ErrIntegrity, strings.Join(parts, "; "))
+}
+
+// Problems returns the problems found in the binder when the project was
+// loaded. Documents with a shared UUID map to its first binder item outside
+// the Trash.
+func (r *Reader) Problems() []Issue {
+	return r.problems
+}
+
+// Problems returns the problems found in the binder when the project was
+// loaded, which keep Save from writing unless SetForce is set.
+func (w *Writer) Problems() []Issue {
+	return w.problems
+}
+
+// SetForce lets Save write into a project with integrity problems.
+func (w *Writer) SetForce(force bool) {
+	w.force = force
+}
+
+// CheckIntegrity returns an ErrIntegrity error if the project has integrity
+// problems and the writer wasn't told to write anyway.
+func (w *Writer) CheckIntegrity() error {
+	if w.force || len(w.problems) == 0 {
+		return nil
+	}
+	return integrityError(w.problems)
+}
@@ -0,0 +1,151 @@
+package scrivener
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// noLabelID is the label ID Scrivener uses for items without a label.
+const noLabelID = "-1"
+
+// Label is a label defined in the project, with its color as "#rrggbb" (""
+// if it has none).
+type Label struct {
+	ID    string
+	Title string
+	Color string
+}
+
+// xmlLabels is the list of labels in the LabelSettings section. Scrivener 3
+// nests them in a Labels element; older projects list them directly.
+type xmlLabels struct {
+	Labels []xmlLabel `xml:"Label"`
+	Nested []xmlLabel `xml:"Labels>Label"`
+}
+
+type xmlLabel struct {
+	ID    string `xml:"ID,attr"`
+	Color string `xml:"Color,attr"`
+	Title string `xml:",chardata"`
+}
+
+// parseLabels reads the labels defined in a project, except "No Label".
+func parseLabels(section *XMLRawSection) []Label {
+	if section == nil {
+		return nil
+	}
+	var list xmlLabels
+	data := append(append([]byte("<s>"), section.InnerXML...), "</s>"...)
+	if err := xml.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+
+	var labels []Label
+	for _, l := range append(list.Labels, list.Nested...) {
+		title := strings.TrimSpace(l.Title)
+		if l.ID == "" || l.ID == noLabelID || title == "" {
+			continue
+		}
+		labels = append(labels, Label{ID: l.ID, Title: title, Color: hexColor(l.Color)})
+	}
+	return labels
+}
+
+// hexColor converts a Scrivener color ("r g b" with components from 0 to 1)
+// to "#rrggbb". It returns "" for anything else.
+func hexColor(color string) string {
+	parts := strings.Fields(color)
+	if len(parts) != 3 {
+		return ""
+	}
+	hex := "#"
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 || v > 1 {
+			return ""
+		}
+		hex += fmt.Sprintf("%02x", int(math.Round(v*255)))
+	}
+	return hex
+}
+
+// Labels returns the labels defined in the project.
+func (r *Reader) Labels() []Label {
+	return parseLabels(r.project.LabelSettings)
+}
+
+// Label returns the label with the given ID, and whether the project
+// defines it.
+func (r *Reader) Label(id string) (Label, bool) {
+	for _, l := range r.Labels() {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return Label{}, false
+}
+
+// LabelID returns the ID of the label with the given title, compared
+// case-insensitively, and whether the project defines it.
+func (r *Reader) LabelID(title string) (string, bool) {
+	for _, l := range r.Labels() {
+		if strings.EqualFold(l.Title, title) {
+			return l.ID, true
+		}
+	}
+	return "", false
+}
+
+// GetLabel returns the ID of the label assigned to a binder item, or "" if
+// it has none.
+func (w *Writer) GetLabel(uuid string) string {
+	item := w.findBinderItem(uuid)
+	if item == nil || item.MetaData == nil || item.MetaData.LabelID == noLabelID {
+		return ""
+	}
+	return item.MetaData.LabelID
+}
+
+// SetLabel assigns a label to a binder item; an empty ID removes it.
+func (w *Writer) SetLabel(uuid, labelID string) error {
+	return w.setMetaData(uuid, labelID, func(md *XMLMetaData) *string { return &md.LabelID })
+}
+
+// GetIcon returns the custom icon of a binder item, or "" if it uses the
+// default icon for its type.
+func (w *Writer) GetIcon(uuid string) string {
+	item := w.findBinderItem(uuid)
+	if item == nil || item.MetaData == nil {
+		return ""
+	}
+	return item.MetaData.IconFileName
+}
+
+// SetIcon sets the custom icon of a binder item by its name, as Scrivener
+// lists it (such as "Flag (Red)"); an empty name restores the default icon.
+func (w *Writer) SetIcon(uuid, icon string) error {
+	return w.setMetaData(uuid, icon, func(md *XMLMetaData) *string { return &md.IconFileName })
+}
+
+// setMetaData sets one metadata field of a binder item, creating the
+// item's MetaData element only when there is something to store.
+func (w *Writer) setMetaData(uuid, value string, field func(*XMLMetaData) *string) error {
+	item := w.findBinderItem(uuid)
+	if item == nil {
+		return fmt.Errorf("binder item %s not found", uuid)
+	}
+	if item.MetaData == nil {
+		if value == "" {
+			return nil
+		}
+		item.MetaData = &XMLMetaData{}
+	}
+	if p := field(item.MetaData); *p != value {
+		*p = value
+		w.modified = true
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package scrivener
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+// noStatusID is the status ID Scrivener uses for items without a status.
+const noStatusID = "-1"
+
+// Status is a status defined in the project.
+type Status struct {
+	ID    string
+	Title string
+}
+
+// Keyword is a keyword defined in the project, with its color as "#rrggbb"
+// ("" if it has none).
+type Keyword struct {
+	ID    string
+	Title string
+	Color string
+}
+
+// xmlStatuses is the list of statuses in the StatusSettings section.
+// Scrivener 3 nests them in a Statuses element; older projects list them
+// directly.
+type xmlStatuses struct {
+	Statuses []xmlStatus `xml:"Status"`
+	Nested   []xmlStatus `xml:"Statuses>Status"`
+}
+
+type xmlStatus struct {
+	ID    string `xml:"ID,attr"`
+	Title string `xml:",chardata"`
+}
+
+// xmlKeywords is the list of keywords in the project's Keywords section.
+type xmlKeywords struct {
+	Keywords []struct {
+		ID        string `xml:"ID,attr"`
+		Title     string `xml:"Title"`
+		Color     string `xml:"Color"`
+		ColorAttr string `xml:"Color,attr"`
+		Value     string `xml:",chardata"`
+	} `xml:"Keyword"`
+}
+
+// XMLItemKeywords lists the IDs of the keywords assigned to a binder item.
+type XMLItemKeywords struct {
+	IDs []string `xml:"KeywordID"`
+}
+
+// parseStatuses reads the statuses defined in a project, except "No Status".
+func parseStatuses(section *XMLRawSection) []Status {
+	if section == nil {
+		return nil
+	}
+	var list xmlStatuses
+	data := append(append([]byte("<s>"), section.InnerXML...), "</s>"...)
+	if err := xml.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+
+	var statuses []Status
+	for _, s := range append(list.Statuses, list.Nested...) {
+		title := strings.TrimSpace(s.Title)
+		if s.ID == "" || s.ID == noStatusID || title == "" {
+			continue
+		}
+		statuses = append(statuses, Status{ID: s.ID, Title: title})
+	}
+	return statuses
+}
+
+// parseKeywords reads the keywords defined in a project.
+func parseKeywords(section *XMLRawSection) []Keyword {
+	if section == nil {
+		return nil
+	}
+	var list xmlKeywords
+	data := append(append([]byte("<s>"), section.InnerXML...), "</s>"...)
+	if err := xml.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+
+	var keywords []Keyword
+	for _, k := range list.Keywords {
+		title := strings.TrimSpace(k.Title)
+		if title == "" {
+			title = strings.TrimSpace(k.Value)
+		}
+		color := k.Color
+		if color == "" {
+			color = k.ColorAttr
+		}
+		if k.ID != "" && title != "" {
+			keywords = append(keywords, Keyword{ID: k.ID, Title: title, Color: hexColor(color)})
+		}
+	}
+	return keywords
+}
+
+// Statuses returns the statuses defined in the project.
+func (r *Reader) Statuses() []Status {
+	return parseStatuses(r.project.StatusSettings)
+}
+
+// Keywords returns the keywords defined in the project.
+func (r *Reader) Keywords() []Keyword {
+	return parseKeywords(r.project.Keywords)
+}
+
+// vocabulary maps label, status and keyword IDs to their titles, so
+// documents can be given their metadata by name.
+type vocabulary struct {
+	labels, statuses, keywords map[string]string
+}
+
+// vocab returns the project's vocabulary, built on first use.
+func (r *Reader) vocab() *vocabulary {
+	if r.vocabCache != nil {
+		return r.vocabCache
+	}
+	v := &vocabulary{
+		labels:   make(map[string]string),
+		statuses: make(map[string]string),
+		keywords: make(map[string]string),
+	}
+	for _, l := range r.Labels() {
+		v.labels[l.ID] = l.Title
+	}
+	for _, s := range r.Statuses() {
+		v.statuses[s.ID] = s.Title
+	}
+	for _, k := range r.Keywords() {
+		v.keywords[k.ID] = k.Title
+	}
+	r.vocabCache = v
+	return v
+}
+
+// setMetadata fills in a document's metadata from its binder item: creation
+// time, label, status, keywords, synopsis, and compile and section settings.
+func (r *Reader) setMetadata(doc *Document, item XMLBinderItem) {
+	doc.Created, _ = timestamp.Parse(item.Created)
+	doc.Synopsis = r.Synopsis(item.UUID)
+
+	v := r.vocab()
+	if md := item.MetaData; md != nil {
+		doc.SectionType = md.SectionType
+		doc.Icon = md.IconFileName
+		doc.IncludeInCompile = md.IncludeInCompile == "Yes"
+		if md.LabelID != noLabelID {
+			doc.LabelID = md.LabelID
+			doc.Label = v.labels[md.LabelID]
+		}
+		if md.StatusID != noStatusID {
+			doc.StatusID = md.StatusID
+			doc.Status = v.statuses[md.StatusID]
+		}
+	}
+	if item.Keywords != nil {
+		for _, id := range item.Keywords.IDs {
+			if title := v.keywords[strings.TrimSpace(id)]; title != "" {
+				doc.Keywords = append(doc.Keywords, title)
+			}
+		}
+	}
+}
+
+// Creator returns the Creator attribute of the .scrivx file, which names
+// the Scrivener version that last saved it, such as "SCRMAC-3.5.2-17487".
+func (r *Reader) Creator() string {
+	return r.project.Creator
+}
+
+// Version returns the .scrivx file format version: "2.0" for Scrivener 3,
+// 1.x for Scrivener 2.
+func (r *Reader) Version() string {
+	return r.project.Version
+}
+
+// Format reports the project's data format, "Scrivener 3" or "Scrivener 2",
+// from the layout of its Files folder, or its format version when the
+// layout doesn't tell.
+func (r *Reader) Format() string {
+	if info, err := os.Stat(filepath.Join(r.scrivPath, "Files", "Docs")); err == nil && info.IsDir() {
+		return "Scrivener 2"
+	}
+	if info, err := os.Stat(r.filesDir); err == nil && info.IsDir() {
+		return "Scrivener 3"
+	}
+	switch {
+	case strings.HasPrefix(r.project.Version, "2"):
+		return "Scrivener 3"
+	case strings.HasPrefix(r.project.Version, "1"):
+		return "Scrivener 2"
+	}
+	return "unknown"
+}
+
+// creatorRe matches a Creator attribute: the platform, the app version and
+// its build.
+var creatorRe = regexp.MustCompile(`^SCR(MAC|WIN|IOS)-([\d.]+)`)
+
+// DescribeCreator turns a Creator attribute into a readable app version,
+// such as "Scrivener 3.5.2 for macOS". Unrecognized values are returned as
+// they are.
+func DescribeCreator(creator string) string {
+	m := creatorRe.FindStringSubmatch(creator)
+	if m == nil {
+		return creator
+	}
+	platform := map[string]string{"MAC": "macOS", "WIN": "Windows", "IOS": "iOS"}[m[1]]
+	return fmt.Sprintf("Scrivener %s for %s", m[2], platform)
+}
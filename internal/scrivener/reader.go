@@ -2,25 +2,53 @@ package scrivener
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/sweiss/harcroft/internal/convert"
 	"github.com/sweiss/harcroft/internal/rtf"
 )
 
+// contentExtensions are the content file types read, in order of preference.
+var contentExtensions = []string{"rtf", "txt", "docx", "odt"}
+
+// readOnlyFormats are content file types that are pulled but never written:
+// documents imported into the binder as DOCX or ODT files.
+var readOnlyFormats = map[string]bool{"docx": true, "odt": true}
+
+var (
+	// errNoContent reports a binder item without a content file.
+	errNoContent = errors.New("content not found")
+	// errNoConverter reports a content file in a format without a converter.
+	errNoConverter = errors.New("no converter for content format")
+)
+
 // Reader reads and parses Scrivener project files.
 type Reader struct {
 	scrivPath  string
 	projectXML string
 	filesDir   string
 	project    *XMLProject
+	converters map[string]convert.Converter // by content file extension
+	items      map[string]*XMLBinderItem    // UUID -> binder item, indexed at load
+	vocabCache *vocabulary                  // label, status and keyword titles; see vocab
+	problems   []Issue                      // binder integrity problems found at load
+
+	skipContent func(*Document) bool // reports documents whose content need not be read
 }
 
 // NewReader creates a new Reader for the given Scrivener project path.
 func NewReader(scrivPath string) (*Reader, error) {
+	return NewReaderWithOptions(scrivPath, rtf.DefaultOptions())
+}
+
+// NewReaderWithOptions creates a new Reader that converts document RTF to
+// markdown using the given conversion options.
+func NewReaderWithOptions(scrivPath string, opts rtf.Options) (*Reader, error) {
 	// Validate .scriv exists
 	info, err := os.Stat(scrivPath)
 	if err != nil {
@@ -53,6 +81,10 @@ func NewReader(scrivPath string) (*Reader, error) {
 		scrivPath:  scrivPath,
 		projectXML: projectXML,
 		filesDir:   filesDir,
+		converters: map[string]convert.Converter{
+			"rtf": convert.RTF{Options: opts},
+			"txt": convert.Text{},
+		},
 	}
 
 	// Parse the project XML
@@ -63,6 +95,35 @@ func NewReader(scrivPath string) (*Reader, error) {
 	return r, nil
 }
 
+// SetConverter sets the converter used for content files with the given
+// extension (such as "rtf"), replacing the built-in one.
+func (r *Reader) SetConverter(ext string, c convert.Converter) {
+	r.converters[ext] = c
+}
+
+// SetSkipContent sets a function consulted before each document's content is
+// read and converted. The document it gets has everything but its content;
+// if it returns true, the content is deferred until LoadContent is called.
+// Callers use it to skip documents they know are unchanged.
+func (r *Reader) SetSkipContent(fn func(*Document) bool) {
+	r.skipContent = fn
+}
+
+// LoadContent reads the content of a document that was deferred by the
+// SetSkipContent function. It does nothing for other documents.
+func (r *Reader) LoadContent(doc *Document) error {
+	if !doc.Deferred {
+		return nil
+	}
+	content, _, err := r.readDocumentContent(doc.UUID)
+	if err != nil && !errors.Is(err, errNoContent) && !errors.Is(err, errNoConverter) {
+		return err
+	}
+	doc.Content = content
+	doc.Deferred = false
+	return nil
+}
+
 // loadProject parses the project.scrivx XML file.
 func (r *Reader) loadProject() error {
 	data, err := os.ReadFile(r.projectXML)
@@ -75,6 +136,11 @@ func (r *Reader) loadProject() error {
 		return fmt.Errorf("failed to parse project XML: %w", err)
 	}
 
+	r.items = make(map[string]*XMLBinderItem)
+	r.indexItems(r.project.Binder.Items, false)
+	r.indexItems(r.project.Binder.Items, true)
+	r.problems = binderIntegrity(r.project.Binder.Items)
+
 	return nil
 }
 
@@ -111,26 +177,129 @@ func (r *Reader) GetTopLevelFolders() ([]*Document, error) {
 
 // FindFolderByTitle finds a folder by its title (case-insensitive).
 func (r *Reader) FindFolderByTitle(title string) (*Document, error) {
-	docs, err := r.GetBinderStructure()
-	if err != nil {
-		return nil, err
+	item := findFolderAnywhere(r.project.Binder.Items, title)
+	if item == nil {
+		return nil, nil
 	}
-	return r.findFolderInDocs(docs, title), nil
+	return r.parseBinderItem(*item)
 }
 
-func (r *Reader) findFolderInDocs(docs []*Document, title string) *Document {
-	lowerTitle := strings.ToLower(title)
-	for _, doc := range docs {
-		if doc.IsFolder() && strings.ToLower(doc.Title) == lowerTitle {
-			return doc
+// FindFolderByPath finds a folder by its binder path (case-insensitive).
+// A path with a single segment, such as "Characters", matches the first folder
+// with that title anywhere in the binder. A multi-segment path, such as
+// "Research/Characters/Minor", or one with a leading "/" is resolved from the
+// top of the binder, one folder per segment. Only the folder found is read.
+func (r *Reader) FindFolderByPath(path string) (*Document, error) {
+	segments, anchored := SplitFolderPath(path)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty folder path")
+	}
+
+	var item *XMLBinderItem
+	if anchored {
+		item = resolvePath(r.project.Binder.Items, segments, true)
+	} else {
+		item = findFolderAnywhere(r.project.Binder.Items, segments[0])
+	}
+	if item == nil {
+		return nil, fmt.Errorf("folder not found: %s", path)
+	}
+	return r.parseBinderItem(*item)
+}
+
+// GetByPath returns the binder item, document or folder, at a binder path
+// such as "Research/Characters/Hero", resolved from the top of the binder
+// with case-insensitive titles. Only the item found and its children are
+// read.
+func (r *Reader) GetByPath(path string) (*Document, error) {
+	segments, _ := SplitFolderPath(path)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty binder path")
+	}
+	item := resolvePath(r.project.Binder.Items, segments, false)
+	if item == nil {
+		return nil, fmt.Errorf("binder item not found: %s", path)
+	}
+	return r.parseBinderItem(*item)
+}
+
+// resolvePath follows binder titles from items, one segment per level. With
+// foldersOnly, every segment must name a folder; otherwise the last may name
+// a document.
+func resolvePath(items []XMLBinderItem, segments []string, foldersOnly bool) *XMLBinderItem {
+	var current *XMLBinderItem
+	for n, segment := range segments {
+		current = nil
+		for i := range items {
+			item := &items[i]
+			last := n == len(segments)-1
+			if item.UUID != "" && (isFolderType(item.Type) || (last && !foldersOnly)) && strings.EqualFold(item.Title, segment) {
+				current = item
+				break
+			}
+		}
+		if current == nil {
+			return nil
+		}
+		items = current.Children
+	}
+	return current
+}
+
+// findFolderAnywhere returns the first folder with a title in a depth-first
+// search of items.
+func findFolderAnywhere(items []XMLBinderItem, title string) *XMLBinderItem {
+	for i := range items {
+		if items[i].UUID == "" {
+			continue
 		}
-		if found := r.findFolderInDocs(doc.Children, title); found != nil {
+		if isFolderType(items[i].Type) && strings.EqualFold(items[i].Title, title) {
+			return &items[i]
+		}
+		if found := findFolderAnywhere(items[i].Children, title); found != nil {
 			return found
 		}
 	}
 	return nil
 }
 
+// SplitFolderPath splits a binder folder path into its segments. The returned
+// anchored flag reports whether the path must be resolved from the binder
+// root (it has a leading "/" or more than one segment).
+func SplitFolderPath(path string) ([]string, bool) {
+	trimmed := strings.TrimSpace(path)
+	anchored := strings.HasPrefix(trimmed, "/")
+
+	var segments []string
+	for _, part := range strings.Split(trimmed, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			segments = append(segments, part)
+		}
+	}
+
+	return segments, anchored || len(segments) > 1
+}
+
+// Identifier returns the project's identifier from the .scrivx file. A copy
+// of a project made by duplicating its folder shares the original's.
+func (r *Reader) Identifier() string {
+	return r.project.Identifier
+}
+
+// GetFavorites returns the UUIDs of the project's favorite binder items.
+func (r *Reader) GetFavorites() []string {
+	if r.project.Favorites == nil {
+		return nil
+	}
+	var uuids []string
+	for _, fav := range r.project.Favorites.Items {
+		if uuid := fav.UUID(); uuid != "" {
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids
+}
+
 // GetAllDocuments returns a flattened list of all documents (not folders).
 func (r *Reader) GetAllDocuments() ([]*Document, error) {
 	docs, err := r.GetBinderStructure()
@@ -140,6 +309,63 @@ func (r *Reader) GetAllDocuments() ([]*Document, error) {
 	return r.flattenDocs(docs, false), nil
 }
 
+// HasDocument reports whether the binder contains a document (not a folder)
+// with the given UUID. Unlike GetAllDocuments it reads no content.
+func (r *Reader) HasDocument(uuid string) bool {
+	item := r.items[uuid]
+	return item != nil && !isFolderType(item.Type)
+}
+
+// HasItem reports whether the binder contains a document or folder with the
+// given UUID.
+func (r *Reader) HasItem(uuid string) bool {
+	return r.items[uuid] != nil
+}
+
+// GetDocumentByUUID returns the document or folder with the given UUID,
+// with its children, or nil if the binder has no such item. It looks the
+// item up in the index built when the project was loaded, reading only that
+// item.
+func (r *Reader) GetDocumentByUUID(uuid string) (*Document, error) {
+	item := r.items[uuid]
+	if item == nil {
+		return nil, nil
+	}
+	return r.parseBinderItem(*item)
+}
+
+// GetDocument is GetDocumentByUUID.
+func (r *Reader) GetDocument(uuid string) (*Document, error) {
+	return r.GetDocumentByUUID(uuid)
+}
+
+// indexItems records the binder items in items, and their children, by
+// UUID: those outside the Trash, or with trash set, the Trash too. A UUID
+// several items share keeps the first item recorded, so indexing without
+// the Trash first lets an item outside it win over a copy left in it.
+func (r *Reader) indexItems(items []XMLBinderItem, trash bool) {
+	for i := range items {
+		if items[i].Type == "TrashFolder" && !trash {
+			continue
+		}
+		if items[i].UUID != "" && r.items[items[i].UUID] == nil {
+			r.items[items[i].UUID] = &items[i]
+		}
+		r.indexItems(items[i].Children, trash)
+	}
+}
+
+// isFolderType reports whether a binder item type is a kind of folder.
+func isFolderType(itemType string) bool {
+	return itemType == "Folder" || itemType == "DraftFolder" || itemType == "ResearchFolder" || itemType == "TrashFolder"
+}
+
+// isMediaType reports whether a binder item type is something other than a
+// text document or folder, such as a PDF, image or web archive.
+func isMediaType(itemType string) bool {
+	return itemType != "" && itemType != "Text" && !isFolderType(itemType)
+}
+
 func (r *Reader) flattenDocs(docs []*Document, includeFolders bool) []*Document {
 	var result []*Document
 	for _, doc := range docs {
@@ -158,23 +384,41 @@ func (r *Reader) parseBinderItem(item XMLBinderItem) (*Document, error) {
 	}
 
 	docType := "document"
-	if item.Type == "Folder" || item.Type == "DraftFolder" || item.Type == "ResearchFolder" || item.Type == "TrashFolder" {
+	if isFolderType(item.Type) {
 		docType = "folder"
 	}
 
-	content, err := r.readDocumentContent(item.UUID)
-	if err != nil {
-		// Not all items have content (e.g., folders)
-		content = ""
+	doc := &Document{
+		UUID:           item.UUID,
+		Title:          item.Title,
+		DocType:        docType,
+		ItemType:       item.Type,
+		Modified:       r.getModificationTime(item.UUID),
+		BinderModified: item.Modified,
 	}
+	r.setMetadata(doc, item)
 
-	doc := &Document{
-		UUID:     item.UUID,
-		Title:    item.Title,
-		Content:  content,
-		DocType:  docType,
-		Modified: r.getModificationTime(item.UUID),
+	if path, format := findContentFile(r.filesDir, item.UUID); path != "" && r.skipContent != nil {
+		doc.ContentFormat = format
+		doc.Unconverted = r.converters[format] == nil
+		doc.Deferred = !doc.Unconverted && r.skipContent(doc)
 	}
+	if !doc.Deferred {
+		content, format, err := r.readDocumentContent(item.UUID)
+		unconverted := errors.Is(err, errNoConverter)
+		doc.NoContent = errors.Is(err, errNoContent)
+		if doc.NoContent || unconverted {
+			// Not all items have content (e.g., folders), and content in a
+			// format without a converter is left empty
+			content = ""
+		} else if err != nil {
+			return nil, err
+		}
+		doc.Content = content
+		doc.ContentFormat = format
+		doc.Unconverted = unconverted
+	}
+	doc.Unsupported = isMediaType(item.Type) && (doc.NoContent || doc.Unconverted)
 
 	// Parse children recursively
 	for _, child := range item.Children {
@@ -190,49 +434,161 @@ func (r *Reader) parseBinderItem(item XMLBinderItem) (*Document, error) {
 	return doc, nil
 }
 
-// readDocumentContent reads the content of a document by its UUID.
-func (r *Reader) readDocumentContent(uuid string) (string, error) {
-	// Scrivener 3 stores documents in Files/Data/{UUID}/content.rtf
-	// Try the new format first
-	contentPath := filepath.Join(r.filesDir, uuid, "content.rtf")
-	if data, err := os.ReadFile(contentPath); err == nil {
-		return rtf.RTFToMarkdown(string(data)), nil
+// readDocumentContent reads the content of a document by its UUID and
+// converts it to markdown. It also returns the content file's format.
+func (r *Reader) readDocumentContent(uuid string) (string, string, error) {
+	path, format := findContentFile(r.filesDir, uuid)
+	if path == "" {
+		return "", "", fmt.Errorf("%w for UUID %s", errNoContent, uuid)
+	}
+	converter := r.converters[format]
+	if converter == nil {
+		return "", format, fmt.Errorf("%w %s", errNoConverter, format)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", format, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	// Inspector comments are carried through the built-in RTF converter only
+	var comments []xmlComment
+	if convert.IsBuiltin(converter) && commentsPath(path) != "" {
+		if comments, err = readComments(commentsPath(path)); err != nil {
+			return "", format, err
+		}
+	}
+	text := string(data)
+	if len(comments) > 0 {
+		text = anchorsToMarkers(text)
+	}
+	content, err := converter.ToMarkdown(text)
+	if err != nil {
+		return "", format, fmt.Errorf("failed to convert %s: %w", path, err)
+	}
+	if len(comments) > 0 {
+		content = markersToCriticMarkup(content, comments)
 	}
+	return content, format, nil
+}
 
-	// Try plain text
-	contentPath = filepath.Join(r.filesDir, uuid, "content.txt")
-	if data, err := os.ReadFile(contentPath); err == nil {
-		return string(data), nil
+// PlainText returns a document's text without formatting, as plain text
+// rather than markdown. Documents in formats other than RTF and plain text
+// are read as markdown.
+func (r *Reader) PlainText(uuid string) (string, error) {
+	path, format := findContentFile(r.filesDir, uuid)
+	switch format {
+	case "":
+		return "", nil
+	case "rtf", "txt":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if format == "txt" {
+			return string(data), nil
+		}
+		return rtf.StripRTF(string(data)), nil
 	}
+	content, _, err := r.readDocumentContent(uuid)
+	if err != nil && !errors.Is(err, errNoConverter) {
+		return "", err
+	}
+	return content, nil
+}
 
-	// Try older format: Files/Data/{UUID}.rtf
-	contentPath = filepath.Join(r.filesDir, uuid+".rtf")
-	if data, err := os.ReadFile(contentPath); err == nil {
-		return rtf.RTFToMarkdown(string(data)), nil
+// findContentFile returns the path and format of a binder item's content
+// file, or "" if it has none. Scrivener 3 stores documents in
+// Files/Data/{UUID}/content.rtf; older projects use Files/Data/{UUID}.rtf.
+func findContentFile(filesDir, uuid string) (string, string) {
+	for _, base := range []string{filepath.Join(filesDir, uuid, "content"), filepath.Join(filesDir, uuid)} {
+		for _, ext := range contentExtensions {
+			if info, err := os.Stat(base + "." + ext); err == nil && !info.IsDir() {
+				return base + "." + ext, ext
+			}
+		}
 	}
+	return "", ""
+}
 
-	// Try older format: Files/Data/{UUID}.txt
-	contentPath = filepath.Join(r.filesDir, uuid+".txt")
-	if data, err := os.ReadFile(contentPath); err == nil {
-		return string(data), nil
+// RTF returns the raw content of a document's RTF content file, or "" if
+// its content isn't stored as RTF.
+func (r *Reader) RTF(uuid string) (string, error) {
+	path, format := findContentFile(r.filesDir, uuid)
+	if format != "rtf" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
 	}
+	return string(data), nil
+}
 
-	return "", fmt.Errorf("content not found for UUID %s", uuid)
+// Synopsis returns the synopsis (index card text) of a binder item, or ""
+// if it has none.
+func (r *Reader) Synopsis(uuid string) string {
+	for _, path := range []string{
+		filepath.Join(r.filesDir, uuid, "synopsis.txt"),
+		filepath.Join(r.filesDir, uuid+"_synopsis.txt"),
+	} {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
 }
 
-// getModificationTime returns the modification time of a document file.
-func (r *Reader) getModificationTime(uuid string) time.Time {
-	// Try new format
-	contentPath := filepath.Join(r.filesDir, uuid, "content.rtf")
-	if info, err := os.Stat(contentPath); err == nil {
-		return info.ModTime()
+// HasRevisions reports whether a document's RTF has text in one of
+// Scrivener's revision colors.
+func (r *Reader) HasRevisions(uuid string) bool {
+	path, format := findContentFile(r.filesDir, uuid)
+	if format != "rtf" {
+		return false
 	}
+	data, err := os.ReadFile(path)
+	return err == nil && rtf.HasRevisions(string(data))
+}
 
-	// Try old format
-	contentPath = filepath.Join(r.filesDir, uuid+".rtf")
-	if info, err := os.Stat(contentPath); err == nil {
-		return info.ModTime()
+// SampleDocumentRTF returns the raw RTF of the first text document in the
+// Draft folder, in binder order, for matching the project's formatting.
+// It reports false if the project has no RTF documents there.
+func (r *Reader) SampleDocumentRTF() (string, bool) {
+	var draft []XMLBinderItem
+	for _, item := range r.project.Binder.Items {
+		if item.Type == "DraftFolder" {
+			draft = item.Children
+			break
+		}
 	}
+	return r.firstRTF(draft)
+}
 
+// firstRTF searches items depth-first for a text document with RTF content.
+func (r *Reader) firstRTF(items []XMLBinderItem) (string, bool) {
+	for _, item := range items {
+		if item.Type == "Text" {
+			for _, path := range []string{
+				filepath.Join(r.filesDir, item.UUID, "content.rtf"),
+				filepath.Join(r.filesDir, item.UUID+".rtf"),
+			} {
+				if data, err := os.ReadFile(path); err == nil {
+					return string(data), true
+				}
+			}
+		}
+		if content, ok := r.firstRTF(item.Children); ok {
+			return content, true
+		}
+	}
+	return "", false
+}
+
+// getModificationTime returns the modification time of a document's
+// content file, or now if it has none.
+func (r *Reader) getModificationTime(uuid string) time.Time {
+	if path, _ := findContentFile(r.filesDir, uuid); path != "" {
+		if info, err := os.Stat(path); err == nil {
+			return info.ModTime()
+		}
+	}
 	return time.Now()
 }
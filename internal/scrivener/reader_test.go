@@ -1,6 +1,7 @@
 package scrivener
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -242,3 +243,361 @@ func TestReadProject_InvalidProject(t *testing.T) {
 		t.Error("Expected error for project without .scrivx file")
 	}
 }
+
+func TestReadProject_FindFolderByPath(t *testing.T) {
+	projectPath := filepath.Join(testdataDir, "sample.scriv")
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"Characters", "FOLDER-UUID-0001"},
+		{"Research/Characters", "FOLDER-UUID-0001"},
+		{"/research/characters/", "FOLDER-UUID-0001"},
+		{"/Draft", "DRAFT-UUID-0001"},
+		{"Draft/Characters", ""},
+		{"/Characters", ""},
+	}
+
+	for _, tc := range tests {
+		folder, err := reader.FindFolderByPath(tc.path)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("Should not find '%s', got %s", tc.path, folder.UUID)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected to find '%s': %v", tc.path, err)
+			continue
+		}
+		if folder.UUID != tc.expected {
+			t.Errorf("For '%s', expected %s, got %s", tc.path, tc.expected, folder.UUID)
+		}
+	}
+}
+
+func TestReadProject_GetByPathAndUUID(t *testing.T) {
+	projectPath := filepath.Join(testdataDir, "sample.scriv")
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	hero, err := reader.GetByPath("Research/Characters/Hero")
+	if err != nil {
+		t.Fatalf("Expected to find the document: %v", err)
+	}
+	if hero.UUID != "DOC-UUID-0003" || hero.Content == "" {
+		t.Errorf("Expected Hero with its content, got %+v", hero)
+	}
+	if _, err := reader.GetByPath("Research/Hero"); err == nil {
+		t.Error("Should not find a document outside its folder")
+	}
+
+	doc, err := reader.GetDocumentByUUID("DOC-UUID-0002")
+	if err != nil || doc == nil || doc.Title != "Chapter Two" {
+		t.Errorf("Expected Chapter Two, got %+v (%v)", doc, err)
+	}
+	if doc, _ := reader.GetDocumentByUUID("NO-SUCH-UUID"); doc != nil {
+		t.Errorf("Expected no document, got %+v", doc)
+	}
+	if !reader.HasDocument("DOC-UUID-0003") || reader.HasDocument("FOLDER-UUID-0001") {
+		t.Error("HasDocument should report documents only")
+	}
+}
+
+func TestReadProject_DocumentMetadata(t *testing.T) {
+	projectPath := copyTestProject(t)
+	scrivx := filepath.Join(projectPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	text := strings.Replace(string(data), "<IncludeInCompile>Yes</IncludeInCompile>",
+		"<StatusID>1</StatusID><IncludeInCompile>Yes</IncludeInCompile>", 1)
+	text = strings.Replace(text, "<TextSelection>0,0</TextSelection>\n                    </TextSettings>",
+		"<TextSelection>0,0</TextSelection>\n                    </TextSettings>\n                    <Keywords><KeywordID>7</KeywordID></Keywords>", 1)
+	text = strings.Replace(text, "</StatusSettings>",
+		"</StatusSettings>\n    <Keywords><Keyword ID=\"7\"><Title>Flashback</Title><Color>1.0 0.0 0.0</Color></Keyword></Keywords>", 1)
+	os.WriteFile(scrivx, []byte(text), 0644)
+	os.WriteFile(filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0001", "synopsis.txt"), []byte("Our hero sets out.\n"), 0644)
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	doc, err := reader.GetDocumentByUUID("DOC-UUID-0001")
+	if err != nil || doc == nil {
+		t.Fatalf("Failed to read document: %v", err)
+	}
+	if doc.Status != "In Progress" || doc.StatusID != "1" {
+		t.Errorf("Expected status In Progress, got %q (%q)", doc.Status, doc.StatusID)
+	}
+	if len(doc.Keywords) != 1 || doc.Keywords[0] != "Flashback" {
+		t.Errorf("Expected keyword Flashback, got %v", doc.Keywords)
+	}
+	if doc.Synopsis != "Our hero sets out." || !doc.IncludeInCompile {
+		t.Errorf("Unexpected synopsis or compile setting: %+v", doc)
+	}
+	if doc.Created.IsZero() || doc.Created.Year() != 2025 {
+		t.Errorf("Expected the creation time, got %v", doc.Created)
+	}
+	if kw := reader.Keywords(); len(kw) != 1 || kw[0].Color != "#ff0000" {
+		t.Errorf("Unexpected keywords: %+v", kw)
+	}
+
+	// The keywords survive a save
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	writer.UpdateTitle("DOC-UUID-0002", "Chapter 2")
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	saved, _ := os.ReadFile(scrivx)
+	if !strings.Contains(string(saved), "<KeywordID>7</KeywordID>") || !strings.Contains(string(saved), "Flashback") {
+		t.Error("Expected keywords to be preserved on save")
+	}
+}
+
+func TestReadProject_Format(t *testing.T) {
+	reader, err := NewReader(filepath.Join(testdataDir, "sample.scriv"))
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if got := reader.Format(); got != "Scrivener 3" {
+		t.Errorf("Expected Scrivener 3, got %s", got)
+	}
+	if got := DescribeCreator(reader.Creator()); got != "Scrivener 3.5.2 for macOS" {
+		t.Errorf("Unexpected creator: %s", got)
+	}
+	if got := DescribeCreator("Something else"); got != "Something else" {
+		t.Errorf("Unknown creators should be kept, got %s", got)
+	}
+}
+
+func TestReadProject_SampleDocumentRTF(t *testing.T) {
+	reader, err := NewReader(filepath.Join(testdataDir, "sample.scriv"))
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	sample, ok := reader.SampleDocumentRTF()
+	if !ok {
+		t.Fatal("Expected a sample document")
+	}
+	if !strings.Contains(sample, "Chapter One") {
+		t.Errorf("Expected the first Draft document, got: %s", sample)
+	}
+}
+
+func TestReadProject_WritingHistory(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	scrivx := filepath.Join(projectPath, "sample.scrivx")
+	data, err := os.ReadFile(scrivx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := `<RecentWritingHistory Date="2025-01-03">
+        <Day Date="2025-01-02" DWC="120" DCC="600" OWC="15" OCC="80"/>
+        <Day Date="2025-01-03" DWC="1" DCC="5" OWC="0" OCC="0"/>
+    </RecentWritingHistory>
+</ScrivenerProject>`
+	data = []byte(strings.Replace(string(data), "</ScrivenerProject>", recent, 1))
+	if err := os.WriteFile(scrivx, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	history := `<?xml version="1.0" encoding="UTF-8"?>
+<WritingHistory>
+    <Day DWC="300" DCC="1500" OWC="0" OCC="0">2025-01-01</Day>
+    <Day DWC="450" DCC="2200" OWC="10" OCC="50">2025-01-03</Day>
+</WritingHistory>`
+	if err := os.WriteFile(filepath.Join(projectPath, "Files", "writing.history"), []byte(history), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	days, err := reader.WritingHistory()
+	if err != nil {
+		t.Fatalf("Failed to read writing history: %v", err)
+	}
+
+	expected := []WritingDay{
+		{Date: "2025-01-01", DraftWords: 300, DraftChars: 1500},
+		{Date: "2025-01-02", DraftWords: 120, DraftChars: 600, OtherWords: 15, OtherChars: 80},
+		{Date: "2025-01-03", DraftWords: 450, DraftChars: 2200, OtherWords: 10, OtherChars: 50},
+	}
+	if len(days) != len(expected) {
+		t.Fatalf("Expected %d days, got %+v", len(expected), days)
+	}
+	for i := range expected {
+		if days[i] != expected[i] {
+			t.Errorf("Day %d: expected %+v, got %+v", i, expected[i], days[i])
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	reader, err := NewReader(filepath.Join(testdataDir, "sample.scriv"))
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	issues, err := reader.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Expected the sample project to verify cleanly, got %v", issues)
+	}
+
+	// Damage a copy: missing content, unreferenced data, a duplicate UUID
+	// and metadata a save would drop
+	projectPath := copyTestProject(t)
+	os.RemoveAll(filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0002"))
+	os.MkdirAll(filepath.Join(projectPath, "Files", "Data", "GONE-UUID"), 0755)
+	scrivx := filepath.Join(projectPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	xml := strings.Replace(string(data), "DOC-UUID-0003", "DOC-UUID-0001", 1)
+	xml = strings.Replace(xml, "<IncludeInCompile>Yes</IncludeInCompile>", "<IncludeInCompile>Yes</IncludeInCompile><FutureSetting>3</FutureSetting>", 1)
+	os.WriteFile(scrivx, []byte(xml), 0644)
+
+	reader, err = NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	issues, err = reader.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	var report []string
+	for _, issue := range issues {
+		report = append(report, issue.String())
+	}
+	joined := strings.Join(report, "\n")
+	for _, want := range []string{
+		"warning: DOC-UUID-0002: 'Chapter Two' has no content file",
+		"warning: GONE-UUID: Files/Data/GONE-UUID is not referenced by the binder",
+		"error: DOC-UUID-0001: UUID is used by 2 binder items",
+		`error: a save would drop "3" in ScrivenerProject/Binder/BinderItem/Children/BinderItem/MetaData/FutureSetting`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected %q in:\n%s", want, joined)
+		}
+	}
+}
+
+func TestReadProject_DuplicateUUIDs(t *testing.T) {
+	projectPath := copyTestProject(t)
+	scrivx := filepath.Join(projectPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	xml := strings.Replace(string(data), "<Title>Trash</Title>",
+		`<Title>Trash</Title><Children><BinderItem UUID="DOC-UUID-0001" Type="Text"><Title>Old Chapter One</Title></BinderItem></Children>`, 1)
+	os.WriteFile(scrivx, []byte(xml), 0644)
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	doc, err := reader.GetDocumentByUUID("DOC-UUID-0001")
+	if err != nil || doc == nil {
+		t.Fatalf("Failed to find DOC-UUID-0001: %v", err)
+	}
+	if doc.Title != "Chapter One" {
+		t.Errorf("Expected the UUID to map to the item outside the Trash, got %q", doc.Title)
+	}
+	problems := reader.Problems()
+	if len(problems) != 1 || problems[0].String() != "error: DOC-UUID-0001: UUID is used by 2 binder items, 1 of them in the Trash" {
+		t.Errorf("Expected the duplicate UUID reported, got %v", problems)
+	}
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.UpdateTitle("DOC-UUID-0002", "Chapter 2"); err != nil {
+		t.Fatalf("UpdateTitle failed: %v", err)
+	}
+	if err := writer.Save(); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected Save to refuse a damaged project, got %v", err)
+	}
+	if after, _ := os.ReadFile(scrivx); string(after) != xml {
+		t.Error("Expected the project file left alone")
+	}
+	writer.SetForce(true)
+	if err := writer.Save(); err != nil {
+		t.Errorf("Expected a forced save to succeed, got %v", err)
+	}
+}
+
+func TestUnreferencedData(t *testing.T) {
+	projectPath := copyTestProject(t)
+	dataDir := filepath.Join(projectPath, "Files", "Data")
+	os.MkdirAll(filepath.Join(dataDir, "GONE-UUID"), 0755)
+	os.WriteFile(filepath.Join(dataDir, "OLD-UUID_synopsis.txt"), []byte("gone"), 0644)
+	os.WriteFile(filepath.Join(dataDir, "DOC-UUID-0001_notes.rtf"), []byte("kept"), 0644)
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	paths, err := reader.UnreferencedData()
+	if err != nil {
+		t.Fatalf("UnreferencedData failed: %v", err)
+	}
+
+	want := []string{
+		filepath.Join(dataDir, "GONE-UUID"),
+		filepath.Join(dataDir, "OLD-UUID_synopsis.txt"),
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %v, got %v", want, paths)
+	}
+}
+
+// failingConverter is a converter that cannot convert anything.
+type failingConverter struct{}
+
+func (failingConverter) ToMarkdown(string) (string, error) {
+	return "", errors.New("cannot convert")
+}
+
+func (failingConverter) FromMarkdown(string, string) (string, error) {
+	return "", errors.New("cannot convert")
+}
+
+func TestReadProject_ConverterError(t *testing.T) {
+	reader, err := NewReader(filepath.Join(testdataDir, "sample.scriv"))
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	reader.SetConverter("rtf", failingConverter{})
+
+	// A document that cannot be converted must not read as empty
+	if _, err := reader.GetBinderStructure(); err == nil || !strings.Contains(err.Error(), "cannot convert") {
+		t.Errorf("Expected the conversion error, got %v", err)
+	}
+}
+
+func TestReadProject_Labels(t *testing.T) {
+	reader, err := NewReader(filepath.Join(testdataDir, "sample.scriv"))
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	labels := reader.Labels()
+	if len(labels) != 1 || labels[0] != (Label{ID: "0", Title: "Red", Color: "#fdb3bb"}) {
+		t.Errorf("Unexpected labels: %+v", labels)
+	}
+	if id, ok := reader.LabelID("red"); !ok || id != "0" {
+		t.Errorf("Expected label ID 0 for 'red', got %q", id)
+	}
+}
@@ -0,0 +1,109 @@
+package scrivener
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// SectionType is a Scrivener 3 section type. Compile formats documents by
+// their section type.
+type SectionType struct {
+	ID    string
+	Title string
+}
+
+// xmlSectionTypes is the list of section types in the SectionTypes section.
+type xmlSectionTypes struct {
+	Types []struct {
+		ID    string `xml:"ID,attr"`
+		UUID  string `xml:"UUID,attr"`
+		Title string `xml:"Title"`
+		Value string `xml:",chardata"`
+	} `xml:"SectionType"`
+}
+
+// parseSectionTypes reads the section types defined in a project.
+func parseSectionTypes(section *XMLRawSection) []SectionType {
+	if section == nil {
+		return nil
+	}
+	var list xmlSectionTypes
+	data := append(append([]byte("<s>"), section.InnerXML...), "</s>"...)
+	if err := xml.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+
+	var types []SectionType
+	for _, t := range list.Types {
+		id := t.ID
+		if id == "" {
+			id = t.UUID
+		}
+		title := strings.TrimSpace(t.Title)
+		if title == "" {
+			title = strings.TrimSpace(t.Value)
+		}
+		if id != "" && title != "" {
+			types = append(types, SectionType{ID: id, Title: title})
+		}
+	}
+	return types
+}
+
+// SectionTypes returns the section types defined in the project.
+func (r *Reader) SectionTypes() []SectionType {
+	return parseSectionTypes(r.project.SectionTypes)
+}
+
+// SectionTypeTitle returns the title of the section type with the given ID,
+// or "" if the project does not define it.
+func (r *Reader) SectionTypeTitle(id string) string {
+	for _, t := range r.SectionTypes() {
+		if t.ID == id {
+			return t.Title
+		}
+	}
+	return ""
+}
+
+// SectionTypeID returns the ID of the section type with the given title,
+// compared case-insensitively, and whether the project defines it.
+func (r *Reader) SectionTypeID(title string) (string, bool) {
+	for _, t := range r.SectionTypes() {
+		if strings.EqualFold(t.Title, title) {
+			return t.ID, true
+		}
+	}
+	return "", false
+}
+
+// GetSectionType returns the ID of the section type assigned to a binder
+// item, or "" if it has none.
+func (w *Writer) GetSectionType(uuid string) string {
+	item := w.findBinderItem(uuid)
+	if item == nil || item.MetaData == nil {
+		return ""
+	}
+	return item.MetaData.SectionType
+}
+
+// SetSectionType assigns a section type to a binder item; an empty ID
+// removes the assignment so the item's default by structure applies.
+func (w *Writer) SetSectionType(uuid, sectionTypeID string) error {
+	item := w.findBinderItem(uuid)
+	if item == nil {
+		return fmt.Errorf("binder item %s not found", uuid)
+	}
+	if item.MetaData == nil {
+		if sectionTypeID == "" {
+			return nil
+		}
+		item.MetaData = &XMLMetaData{}
+	}
+	if item.MetaData.SectionType != sectionTypeID {
+		item.MetaData.SectionType = sectionTypeID
+		w.modified = true
+	}
+	return nil
+}
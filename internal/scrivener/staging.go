@@ -0,0 +1,139 @@
+package scrivener
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// stagedSuffix marks the temporary files Save writes content to before
+// moving them into place.
+const stagedSuffix = ".scriv-sync-tmp"
+
+// stagedFile is a change to a file of the project held until Save: new
+// content, or the file's removal.
+type stagedFile struct {
+	data   []byte
+	remove bool
+}
+
+// writeFile stages new content for a file of the project.
+func (w *Writer) writeFile(path string, data []byte) {
+	if w.staged == nil {
+		w.staged = make(map[string]stagedFile)
+	}
+	w.staged[path] = stagedFile{data: data}
+}
+
+// removeFile stages the removal of a file of the project.
+func (w *Writer) removeFile(path string) {
+	if w.staged == nil {
+		w.staged = make(map[string]stagedFile)
+	}
+	w.staged[path] = stagedFile{remove: true}
+}
+
+// readFile reads a file of the project as it will be once staged changes
+// are saved.
+func (w *Writer) readFile(path string) ([]byte, error) {
+	if f, ok := w.staged[path]; ok {
+		if f.remove {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+		return f.data, nil
+	}
+	return os.ReadFile(path)
+}
+
+// commitStaged writes the staged files and the project XML (xmlData, or
+// nil to leave it alone) to temporary files beside them, and only when all
+// have been written moves them into place, so a failure leaves the project
+// as it was. Staged removals are made last.
+func (w *Writer) commitStaged(xmlData []byte) error {
+	paths := make([]string, 0, len(w.staged))
+	for path, f := range w.staged {
+		if !f.remove {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	// Content first and the project file last, which is what Scrivener
+	// reads to find the content
+	type write struct {
+		path string
+		data []byte
+	}
+	var writes []write
+	for _, path := range paths {
+		writes = append(writes, write{path, w.staged[path].data})
+	}
+	if xmlData != nil {
+		writes = append(writes, write{w.projectXML, xmlData})
+	}
+
+	var created []string // directories made for new content, deepest last
+	var temps []string
+	rollback := func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+	}
+	for _, wr := range writes {
+		dirs, err := mkdirAll(filepath.Dir(wr.path))
+		created = append(created, dirs...)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("failed to create content directory: %w", err)
+		}
+		tmp := wr.path + stagedSuffix
+		if err := os.WriteFile(tmp, wr.data, 0644); err != nil {
+			os.Remove(tmp)
+			rollback()
+			return fmt.Errorf("failed to write %s: %w", wr.path, err)
+		}
+		temps = append(temps, tmp)
+	}
+
+	for i, wr := range writes {
+		if err := os.Rename(temps[i], wr.path); err != nil {
+			for _, tmp := range temps[i:] {
+				os.Remove(tmp)
+			}
+			return fmt.Errorf("failed to write %s: %w", wr.path, err)
+		}
+	}
+
+	for path, f := range w.staged {
+		if f.remove {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+	w.staged = nil
+	return nil
+}
+
+// mkdirAll creates a directory and any missing parents, returning those it
+// created, outermost first.
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append([]string{d}, missing...)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return missing, err
+	}
+	return missing, nil
+}
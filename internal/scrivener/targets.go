@@ -0,0 +1,174 @@
+package scrivener
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+// Target types Scrivener counts project targets in.
+const (
+	TargetWords      = "Words"
+	TargetCharacters = "Characters"
+)
+
+// Targets are the project targets set in Scrivener's Project Targets window.
+type Targets struct {
+	Draft       int       // draft target; 0 if none is set
+	DraftType   string    // TargetWords or TargetCharacters
+	Session     int       // session target; 0 if none is set
+	SessionType string    // TargetWords or TargetCharacters
+	Deadline    time.Time // draft deadline; zero if none is set or it is ignored
+
+	CountIncludedOnly bool // the draft target counts only documents included in compile
+}
+
+// xmlTargets is the content of the ProjectTargets section.
+type xmlTargets struct {
+	Draft   *xmlTarget `xml:"DraftTarget"`
+	Session *xmlTarget `xml:"SessionTarget"`
+}
+
+type xmlTarget struct {
+	Type              string `xml:"Type,attr"`
+	Deadline          string `xml:"Deadline,attr"`
+	IgnoreDeadline    string `xml:"IgnoreDeadline,attr"`
+	CountIncludedOnly string `xml:"CountIncludedOnly,attr"`
+	Value             string `xml:",chardata"`
+}
+
+// parseTargets reads the project targets from the ProjectTargets section.
+func parseTargets(section *XMLProjectTargets) Targets {
+	t := Targets{DraftType: TargetWords, SessionType: TargetWords}
+	if section == nil {
+		return t
+	}
+	var list xmlTargets
+	data := append(append([]byte("<t>"), section.InnerXML...), "</t>"...)
+	if err := xml.Unmarshal(data, &list); err != nil {
+		return t
+	}
+
+	if d := list.Draft; d != nil {
+		t.Draft, _ = strconv.Atoi(strings.TrimSpace(d.Value))
+		if d.Type != "" {
+			t.DraftType = d.Type
+		}
+		t.CountIncludedOnly = d.CountIncludedOnly == "Yes"
+		if d.IgnoreDeadline != "Yes" && d.Deadline != "" {
+			if deadline, err := timestamp.Parse(d.Deadline); err == nil {
+				t.Deadline = deadline
+			}
+		}
+	}
+	if s := list.Session; s != nil {
+		t.Session, _ = strconv.Atoi(strings.TrimSpace(s.Value))
+		if s.Type != "" {
+			t.SessionType = s.Type
+		}
+	}
+	return t
+}
+
+// Targets returns the project's draft and session targets and deadline.
+func (r *Reader) Targets() Targets {
+	return parseTargets(r.project.ProjectTargets)
+}
+
+// SetDraftTarget sets the draft target, in the draft target's current type
+// (words unless the project counts characters). 0 clears it.
+func (w *Writer) SetDraftTarget(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid draft target: %d", n)
+	}
+	w.setTarget("DraftTarget", strconv.Itoa(n))
+	return nil
+}
+
+// SetSessionTarget sets the session target, in the session target's current
+// type. 0 clears it.
+func (w *Writer) SetSessionTarget(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid session target: %d", n)
+	}
+	w.setTarget("SessionTarget", strconv.Itoa(n))
+	return nil
+}
+
+// SetDeadline sets the draft deadline. A zero time removes it, as unticking
+// the deadline in Scrivener does.
+func (w *Writer) SetDeadline(deadline time.Time) {
+	if deadline.IsZero() {
+		w.setTarget("DraftTarget", "", "IgnoreDeadline", "Yes")
+		return
+	}
+	w.setTarget("DraftTarget", "", "Deadline", timestamp.FormatBinder(deadline), "IgnoreDeadline", "No", "ShowDeadline", "Yes")
+}
+
+// setTarget sets the value ("" to keep it) and attributes, given as name and
+// value pairs, of a target element of the ProjectTargets section, adding the
+// element or section if the project has none. The rest of the section,
+// including attributes this tool doesn't know, is kept as Scrivener wrote it.
+func (w *Writer) setTarget(name, value string, attrs ...string) {
+	if w.project.ProjectTargets == nil {
+		w.project.ProjectTargets = &XMLProjectTargets{Notify: "No"}
+	}
+	section := w.project.ProjectTargets
+	section.InnerXML = setTargetElement(section.InnerXML, name, value, attrs)
+	w.modified = true
+}
+
+// setTargetElement rewrites the named element of a ProjectTargets section's
+// inner XML, appending a new one if it has none.
+func setTargetElement(inner []byte, name, value string, attrs []string) []byte {
+	re := regexp.MustCompile(`(?s)<` + name + `\b([^>]*?)(/>|>(.*?)</` + name + `>)`)
+	loc := re.FindSubmatchIndex(inner)
+	if loc == nil {
+		if value == "" {
+			value = "0"
+		}
+		start := " Type=\"" + TargetWords + "\""
+		for i := 0; i+1 < len(attrs); i += 2 {
+			start += fmt.Sprintf(" %s=%q", attrs[i], attrs[i+1])
+		}
+		element := "<" + name + start + ">" + value + "</" + name + ">"
+
+		// Indent it like the elements before it
+		text := string(inner)
+		body := strings.TrimRight(text, " \t\r\n")
+		trailing := text[len(body):]
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t\r\n"))]
+		if body == "" {
+			indent, trailing = "", ""
+		}
+		return []byte(body + indent + element + trailing)
+	}
+
+	start := string(inner[loc[2]:loc[3]])
+	for i := 0; i+1 < len(attrs); i += 2 {
+		attr := regexp.MustCompile(`\s` + attrs[i] + `="[^"]*"`)
+		set := fmt.Sprintf(" %s=%q", attrs[i], attrs[i+1])
+		if attr.MatchString(start) {
+			start = attr.ReplaceAllLiteralString(start, set)
+		} else {
+			start = strings.TrimRight(start, " ") + set
+		}
+	}
+	if value == "" && loc[6] >= 0 {
+		value = string(inner[loc[6]:loc[7]])
+	}
+	if value == "" {
+		value = "0"
+	}
+
+	var b strings.Builder
+	b.Write(inner[:loc[0]])
+	b.WriteString("<" + name + start + ">" + value + "</" + name + ">")
+	b.Write(inner[loc[1]:])
+	return []byte(b.String())
+}
@@ -5,17 +5,38 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/xml"
+	"strings"
 	"time"
 )
 
 // Document represents a single document in a Scrivener project.
 type Document struct {
-	UUID     string
-	Title    string
-	Content  string
-	DocType  string // "folder" or "document"
-	Modified time.Time
-	Children []*Document
+	UUID        string
+	Title       string
+	Content     string
+	DocType     string // "folder" or "document"
+	ItemType    string // binder item type, e.g. "Text", "Folder", "DraftFolder", "TrashFolder"
+	SectionType string // ID of the document's section type, if one is assigned
+	LabelID     string // ID of the document's label, if one is assigned
+	Icon        string // name of the document's custom icon, if it has one
+	Created     time.Time
+	Modified    time.Time
+	Children    []*Document
+
+	ContentFormat string // extension of the content file, e.g. "rtf"; "" if it has none
+	Unconverted   bool   // the content file's format has no converter, so Content is empty
+	NoContent     bool   // the item has no content file at all, as opposed to an empty one
+	Unsupported   bool   // a PDF, image, web archive or other item with no text to sync
+
+	BinderModified string // the binder item's Modified attribute, as written by Scrivener
+	Deferred       bool   // Content was not read; see Reader.SetSkipContent
+
+	Label            string   // title of the document's label, if one is assigned
+	StatusID         string   // ID of the document's status, if one is assigned
+	Status           string   // title of the document's status
+	Keywords         []string // titles of the document's keywords, in binder order
+	Synopsis         string   // index card text
+	IncludeInCompile bool
 }
 
 // ContentHash returns an MD5 hash of the document's content for change detection.
@@ -29,6 +50,17 @@ func (d *Document) IsFolder() bool {
 	return d.DocType == "folder"
 }
 
+// IsTrash returns true if this document is the project's Trash folder.
+func (d *Document) IsTrash() bool {
+	return d.ItemType == "TrashFolder"
+}
+
+// IsReadOnly returns true if the document's content is pulled but never
+// written back, such as an imported DOCX file.
+func (d *Document) IsReadOnly() bool {
+	return readOnlyFormats[d.ContentFormat]
+}
+
 // XML structures for parsing .scrivx files
 // These structures preserve ALL Scrivener XML attributes to avoid data loss
 
@@ -44,16 +76,17 @@ type XMLProject struct {
 	ModID      string    `xml:"ModID,attr,omitempty"`
 	Binder     XMLBinder `xml:"Binder"`
 	// Preserve other sections we don't modify
-	Collections            *XMLRawSection `xml:"Collections,omitempty"`
-	SectionTypes           *XMLRawSection `xml:"SectionTypes,omitempty"`
-	LabelSettings          *XMLRawSection `xml:"LabelSettings,omitempty"`
-	StatusSettings         *XMLRawSection `xml:"StatusSettings,omitempty"`
-	CustomMetaDataSettings *XMLRawSection `xml:"CustomMetaDataSettings,omitempty"`
-	ProjectTargets         *XMLProjectTargets `xml:"ProjectTargets,omitempty"`
+	Collections            *XMLRawSection           `xml:"Collections,omitempty"`
+	SectionTypes           *XMLRawSection           `xml:"SectionTypes,omitempty"`
+	LabelSettings          *XMLRawSection           `xml:"LabelSettings,omitempty"`
+	StatusSettings         *XMLRawSection           `xml:"StatusSettings,omitempty"`
+	Keywords               *XMLRawSection           `xml:"Keywords,omitempty"`
+	CustomMetaDataSettings *XMLRawSection           `xml:"CustomMetaDataSettings,omitempty"`
+	ProjectTargets         *XMLProjectTargets       `xml:"ProjectTargets,omitempty"`
 	RecentWritingHistory   *XMLRecentWritingHistory `xml:"RecentWritingHistory,omitempty"`
-	RecentSearches         *XMLRawSection `xml:"RecentSearches,omitempty"`
-	Favorites              *XMLRawSection `xml:"Favorites,omitempty"`
-	PrintSettings          *XMLPrintSettings `xml:"PrintSettings,omitempty"`
+	RecentSearches         *XMLRawSection           `xml:"RecentSearches,omitempty"`
+	Favorites              *XMLFavorites            `xml:"Favorites,omitempty"`
+	PrintSettings          *XMLPrintSettings        `xml:"PrintSettings,omitempty"`
 }
 
 // XMLRawSection preserves XML elements with just inner content.
@@ -61,6 +94,30 @@ type XMLRawSection struct {
 	InnerXML []byte `xml:",innerxml"`
 }
 
+// XMLFavorites holds the project's favorite (bookmarked) binder items.
+type XMLFavorites struct {
+	Items []XMLFavorite `xml:",any"`
+}
+
+// XMLFavorite is a single favorite entry. The element name and attributes
+// are preserved as found; the binder item UUID is either the element text or
+// an ID/UUID attribute.
+type XMLFavorite struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Value   string     `xml:",chardata"`
+}
+
+// UUID returns the binder item UUID this favorite refers to.
+func (f XMLFavorite) UUID() string {
+	for _, attr := range f.Attrs {
+		if attr.Name.Local == "ID" || attr.Name.Local == "UUID" {
+			return attr.Value
+		}
+	}
+	return strings.TrimSpace(f.Value)
+}
+
 // XMLProjectTargets preserves the ProjectTargets section with its attributes.
 type XMLProjectTargets struct {
 	Notify   string `xml:"Notify,attr,omitempty"`
@@ -106,12 +163,17 @@ type XMLBinderItem struct {
 	Title        string           `xml:"Title,omitempty"`
 	MetaData     *XMLMetaData     `xml:"MetaData,omitempty"`
 	TextSettings *XMLTextSettings `xml:"TextSettings,omitempty"`
+	Keywords     *XMLItemKeywords `xml:"Keywords,omitempty"`
 	Children     []XMLBinderItem  `xml:"Children>BinderItem,omitempty"`
 }
 
 // XMLMetaData contains metadata for a binder item.
 type XMLMetaData struct {
+	IconFileName     string `xml:"IconFileName,omitempty"`
+	LabelID          string `xml:"LabelID,omitempty"`
+	StatusID         string `xml:"StatusID,omitempty"`
 	IncludeInCompile string `xml:"IncludeInCompile,omitempty"`
+	SectionType      string `xml:"SectionType,omitempty"`
 }
 
 // XMLTextSettings contains text settings for a binder item.
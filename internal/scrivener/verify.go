@@ -0,0 +1,263 @@
+package scrivener
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Issue severities reported by Verify.
+const (
+	IssueError   = "error"   // the project is damaged or a save would lose data
+	IssueWarning = "warning" // worth a look, but Scrivener itself can produce it
+)
+
+// Issue is a problem found by Verify.
+type Issue struct {
+	Severity string
+	UUID     string // binder item or Files/Data entry concerned, if any
+	Message  string
+}
+
+func (i Issue) String() string {
+	if i.UUID == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.UUID, i.Message)
+}
+
+// maxRoundTripIssues limits how many round-trip differences are reported.
+const maxRoundTripIssues = 20
+
+// Verify checks the integrity of the project: every binder item has its
+// content file, every Files/Data entry belongs to a binder item, binder UUIDs
+// are unique, and the .scrivx file survives a parse and save unchanged.
+func (r *Reader) Verify() ([]Issue, error) {
+	var issues []Issue
+
+	r.verifyItems(r.project.Binder.Items, &issues)
+	issues = append(issues, binderIntegrity(r.project.Binder.Items)...)
+
+	seen := make(map[string]int)
+	countUUIDs(r.project.Binder.Items, seen)
+
+	orphans, err := r.unreferencedData(seen)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, orphans...)
+
+	roundTrip, err := r.verifyRoundTrip()
+	if err != nil {
+		return nil, err
+	}
+	return append(issues, roundTrip...), nil
+}
+
+// verifyItems checks that each binder item with a UUID has content.
+func (r *Reader) verifyItems(items []XMLBinderItem, issues *[]Issue) {
+	for _, item := range items {
+		if item.UUID != "" && !isFolderType(item.Type) && item.Type != "Root" && !r.hasContentFile(item.UUID) {
+			if item.Type == "Text" {
+				*issues = append(*issues, Issue{IssueWarning, item.UUID, fmt.Sprintf("'%s' has no content file (empty, or its text is missing)", item.Title)})
+			} else {
+				*issues = append(*issues, Issue{IssueError, item.UUID, fmt.Sprintf("'%s' (%s) has no content file", item.Title, item.Type)})
+			}
+		}
+		r.verifyItems(item.Children, issues)
+	}
+}
+
+// countUUIDs counts the UUIDs of binder items and their descendants.
+func countUUIDs(items []XMLBinderItem, seen map[string]int) {
+	for _, item := range items {
+		if item.UUID != "" {
+			seen[item.UUID]++
+		}
+		countUUIDs(item.Children, seen)
+	}
+}
+
+// hasContentFile reports whether a binder item has a content file in either
+// the Scrivener 3 (Files/Data/{UUID}/content.*) or older ({UUID}.*) layout.
+func (r *Reader) hasContentFile(uuid string) bool {
+	for _, pattern := range []string{
+		filepath.Join(r.filesDir, uuid, "content.*"),
+		filepath.Join(r.filesDir, uuid+".*"),
+	} {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// unreferencedData reports Files/Data entries whose UUID is not in the binder.
+func (r *Reader) unreferencedData(binder map[string]int) ([]Issue, error) {
+	entries, err := r.dataEntries()
+	if err != nil {
+		return nil, err
+	}
+	var issues []Issue
+	for _, entry := range entries {
+		if binder[entry.UUID] == 0 {
+			issues = append(issues, Issue{IssueWarning, entry.UUID, fmt.Sprintf("Files/Data/%s is not referenced by the binder", entry.Name)})
+		}
+	}
+	return issues, nil
+}
+
+// dataEntry is an entry of Files/Data: a Scrivener 3 UUID directory, or an
+// older-format {UUID}.rtf, {UUID}_synopsis.txt or {UUID}_notes.rtf file.
+type dataEntry struct {
+	Name string
+	UUID string
+}
+
+// dataEntries lists Files/Data with the binder UUID each entry belongs to.
+func (r *Reader) dataEntries() ([]dataEntry, error) {
+	entries, err := os.ReadDir(r.filesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", r.filesDir, err)
+	}
+
+	var result []dataEntry
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		uuid := name
+		if !entry.IsDir() {
+			uuid = strings.TrimSuffix(name, filepath.Ext(name))
+			uuid = strings.TrimSuffix(uuid, "_synopsis")
+			uuid = strings.TrimSuffix(uuid, "_notes")
+		}
+		result = append(result, dataEntry{Name: name, UUID: uuid})
+	}
+	return result, nil
+}
+
+// UnreferencedData returns the paths of Files/Data entries whose UUID no
+// longer appears anywhere in the binder, Trash included. Snapshots are kept
+// outside Files/Data and are never listed.
+func (r *Reader) UnreferencedData() ([]string, error) {
+	binder := make(map[string]int)
+	countUUIDs(r.project.Binder.Items, binder)
+
+	entries, err := r.dataEntries()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if binder[entry.UUID] == 0 {
+			paths = append(paths, filepath.Join(r.filesDir, entry.Name))
+		}
+	}
+	return paths, nil
+}
+
+// verifyRoundTrip parses the .scrivx file and marshals it back the way a
+// save does, reporting any content the save would drop or change.
+func (r *Reader) verifyRoundTrip() ([]Issue, error) {
+	original, err := os.ReadFile(r.projectXML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	saved, err := xml.MarshalIndent(r.project, "", "    ")
+	if err != nil {
+		return []Issue{{IssueError, "", fmt.Sprintf("project cannot be saved: %v", err)}}, nil
+	}
+
+	before, err := xmlFacts(original)
+	if err != nil {
+		return []Issue{{IssueError, "", fmt.Sprintf("project file is not valid XML: %v", err)}}, nil
+	}
+	after, err := xmlFacts(saved)
+	if err != nil {
+		return []Issue{{IssueError, "", fmt.Sprintf("saved project is not valid XML: %v", err)}}, nil
+	}
+
+	var issues []Issue
+	add := func(message string) bool {
+		if len(issues) == maxRoundTripIssues {
+			issues = append(issues, Issue{IssueError, "", "further round-trip differences omitted"})
+		}
+		if len(issues) > maxRoundTripIssues {
+			return false
+		}
+		issues = append(issues, Issue{IssueError, "", message})
+		return true
+	}
+	for _, fact := range sortedKeys(before) {
+		if before[fact] > after[fact] && !add("a save would drop "+fact) {
+			return issues, nil
+		}
+	}
+	for _, fact := range sortedKeys(after) {
+		if after[fact] > before[fact] && !add("a save would add "+fact) {
+			return issues, nil
+		}
+	}
+	return issues, nil
+}
+
+// xmlFacts flattens an XML document into counted facts: each element's path
+// with its attributes, and each non-blank text node with its path. Elements
+// without attributes only count through their content, so an empty element
+// (such as <Children></Children> on a save) is not a difference.
+func xmlFacts(data []byte) (map[string]int, error) {
+	facts := make(map[string]int)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var path []string
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return facts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			attrs := make([]string, 0, len(t.Attr))
+			for _, attr := range t.Attr {
+				attrs = append(attrs, fmt.Sprintf("%s=%q", attr.Name.Local, attr.Value))
+			}
+			if len(attrs) == 0 {
+				continue
+			}
+			sort.Strings(attrs)
+			facts[fmt.Sprintf("<%s %s>", strings.Join(path, "/"), strings.Join(attrs, " "))]++
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				facts[fmt.Sprintf("%q in %s", text, strings.Join(path, "/"))]++
+			}
+		}
+	}
+}
+
+// sortedKeys returns the keys of a fact map in order.
+func sortedKeys(facts map[string]int) []string {
+	keys := make([]string, 0, len(facts))
+	for key := range facts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,189 @@
+package scrivener
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Errors returned when adding or renaming labels and statuses.
+var (
+	ErrVocabExists   = errors.New("already exists")
+	ErrVocabNotFound = errors.New("not found")
+)
+
+// AddLabel defines a new label with an optional "#rrggbb" color and returns
+// its ID. Labels are matched by title case-insensitively, so a title already
+// in use is refused.
+func (w *Writer) AddLabel(title, color string) (string, error) {
+	attrs := ""
+	if color != "" {
+		c, err := scrivColor(color)
+		if err != nil {
+			return "", err
+		}
+		attrs = fmt.Sprintf(" Color=%q", c)
+	}
+	var ids []string
+	for _, l := range parseLabels(w.project.LabelSettings) {
+		if strings.EqualFold(l.Title, title) {
+			return "", fmt.Errorf("label %q %w", l.Title, ErrVocabExists)
+		}
+		ids = append(ids, l.ID)
+	}
+	return w.addVocabEntry(&w.project.LabelSettings, "Label", noLabelID, "No Label", title, attrs, ids)
+}
+
+// RenameLabel renames a label. Documents refer to labels by ID, so they keep
+// it.
+func (w *Writer) RenameLabel(oldTitle, newTitle string) error {
+	var entries []vocabEntry
+	for _, l := range parseLabels(w.project.LabelSettings) {
+		entries = append(entries, vocabEntry{l.ID, l.Title})
+	}
+	return w.renameVocabEntry(w.project.LabelSettings, "Label", entries, oldTitle, newTitle)
+}
+
+// AddStatus defines a new status and returns its ID. A title already in use
+// is refused.
+func (w *Writer) AddStatus(title string) (string, error) {
+	var ids []string
+	for _, s := range parseStatuses(w.project.StatusSettings) {
+		if strings.EqualFold(s.Title, title) {
+			return "", fmt.Errorf("status %q %w", s.Title, ErrVocabExists)
+		}
+		ids = append(ids, s.ID)
+	}
+	return w.addVocabEntry(&w.project.StatusSettings, "Status", noStatusID, "No Status", title, "", ids)
+}
+
+// RenameStatus renames a status. Documents refer to statuses by ID, so they
+// keep it.
+func (w *Writer) RenameStatus(oldTitle, newTitle string) error {
+	var entries []vocabEntry
+	for _, s := range parseStatuses(w.project.StatusSettings) {
+		entries = append(entries, vocabEntry{s.ID, s.Title})
+	}
+	return w.renameVocabEntry(w.project.StatusSettings, "Status", entries, oldTitle, newTitle)
+}
+
+// vocabEntry is a label or status as renameVocabEntry looks them up.
+type vocabEntry struct {
+	ID, Title string
+}
+
+// addVocabEntry appends an element (Label or Status) with the next free ID
+// to a settings section, after its last entry so it is indented alike and
+// stays inside a Scrivener 3 Labels or Statuses list. A project without the
+// section gets one holding the "No Label" or "No Status" entry and the new
+// one.
+func (w *Writer) addVocabEntry(section **XMLRawSection, name, noneID, noneTitle, title, attrs string, ids []string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", fmt.Errorf("%s title must not be empty", strings.ToLower(name))
+	}
+	next := 0
+	for _, id := range ids {
+		if n, err := strconv.Atoi(id); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	id := strconv.Itoa(next)
+	element := fmt.Sprintf("<%s ID=%q%s>%s</%s>", name, id, attrs, escapeText(title), name)
+
+	if *section == nil {
+		*section = &XMLRawSection{InnerXML: []byte(fmt.Sprintf("<%s ID=%q>%s</%s>%s", name, noneID, noneTitle, name, element))}
+		w.modified = true
+		return id, nil
+	}
+	inner := (*section).InnerXML
+	last := regexp.MustCompile(`(?s)<`+name+`\b[^>]*?(/>|>.*?</`+name+`>)`).FindAllIndex(inner, -1)
+	var out []byte
+	if len(last) > 0 {
+		start, end := last[len(last)-1][0], last[len(last)-1][1]
+		indent := inner[bytes.LastIndexByte(inner[:start], '\n')+1 : start]
+		if len(bytes.TrimSpace(indent)) > 0 {
+			indent = nil // not on a line of its own
+		}
+		out = append(out, inner[:end]...)
+		if len(indent) > 0 {
+			out = append(out, '\n')
+			out = append(out, indent...)
+		}
+		out = append(out, element...)
+		out = append(out, inner[end:]...)
+	} else {
+		text := string(inner)
+		body := strings.TrimRight(text, " \t\r\n")
+		out = []byte(body + element + text[len(body):])
+	}
+	(*section).InnerXML = out
+	w.modified = true
+	return id, nil
+}
+
+// renameVocabEntry renames the entry titled oldTitle (case-insensitively) in
+// a settings section, refusing a title another entry already has.
+func (w *Writer) renameVocabEntry(section *XMLRawSection, name string, entries []vocabEntry, oldTitle, newTitle string) error {
+	kind := strings.ToLower(name)
+	newTitle = strings.TrimSpace(newTitle)
+	if newTitle == "" {
+		return fmt.Errorf("%s title must not be empty", kind)
+	}
+	var id string
+	for _, e := range entries {
+		if strings.EqualFold(e.Title, oldTitle) {
+			id = e.ID
+		}
+	}
+	if id == "" {
+		return fmt.Errorf("%s %q %w", kind, oldTitle, ErrVocabNotFound)
+	}
+	for _, e := range entries {
+		if e.ID != id && strings.EqualFold(e.Title, newTitle) {
+			return fmt.Errorf("%s %q %w", kind, e.Title, ErrVocabExists)
+		}
+	}
+
+	re := regexp.MustCompile(`(?s)(<` + name + `\b[^>]*?\sID="` + regexp.QuoteMeta(id) + `"[^>]*>)(.*?)(</` + name + `>)`)
+	loc := re.FindSubmatchIndex(section.InnerXML)
+	if loc == nil {
+		return fmt.Errorf("%s %q %w", kind, oldTitle, ErrVocabNotFound)
+	}
+	var b bytes.Buffer
+	b.Write(section.InnerXML[:loc[4]])
+	b.WriteString(escapeText(newTitle))
+	b.Write(section.InnerXML[loc[5]:])
+	section.InnerXML = b.Bytes()
+	w.modified = true
+	return nil
+}
+
+// escapeText escapes s for use as XML character data.
+func escapeText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// scrivColor converts a "#rrggbb" color to Scrivener's "r g b", with
+// components from 0 to 1.
+func scrivColor(hex string) (string, error) {
+	h := strings.TrimPrefix(hex, "#")
+	if len(h) != 6 {
+		return "", fmt.Errorf("invalid color %q: use #rrggbb", hex)
+	}
+	parts := make([]string, 3)
+	for i := range parts {
+		v, err := strconv.ParseUint(h[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid color %q: use #rrggbb", hex)
+		}
+		parts[i] = strconv.FormatFloat(float64(v)/255, 'f', 6, 64)
+	}
+	return strings.Join(parts, " "), nil
+}
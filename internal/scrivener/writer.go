@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sweiss/harcroft/internal/convert"
 	"github.com/sweiss/harcroft/internal/rtf"
+	"github.com/sweiss/harcroft/internal/timestamp"
 )
 
 // Writer writes content to Scrivener project files.
@@ -20,6 +22,11 @@ type Writer struct {
 	project       *XMLProject
 	existingUUIDs map[string]bool
 	modified      bool
+	converters    map[string]convert.Converter // by content file extension
+	deleted       []string                     // items whose content files Save removes
+	problems      []Issue                      // binder integrity problems found at load
+	force         bool                         // save despite problems; see SetForce
+	staged        map[string]stagedFile        // file changes Save makes; see writeFile
 }
 
 // NewWriter creates a new Writer for the given Scrivener project path.
@@ -62,6 +69,10 @@ func NewWriter(scrivPath string) (*Writer, error) {
 		projectXML:    projectXML,
 		filesDir:      filesDir,
 		existingUUIDs: make(map[string]bool),
+		converters: map[string]convert.Converter{
+			"rtf": convert.RTF{Options: rtf.DefaultOptions()},
+			"txt": convert.Text{},
+		},
 	}
 
 	// Load the project XML
@@ -71,6 +82,7 @@ func NewWriter(scrivPath string) (*Writer, error) {
 
 	// Collect existing UUIDs
 	w.collectUUIDs(w.project.Binder.Items)
+	w.problems = binderIntegrity(w.project.Binder.Items)
 
 	return w, nil
 }
@@ -100,42 +112,161 @@ func (w *Writer) collectUUIDs(items []XMLBinderItem) {
 	}
 }
 
+// SetConversionOptions sets the options used to convert markdown to RTF,
+// including the formatting template for pushed documents.
+func (w *Writer) SetConversionOptions(opts rtf.Options) {
+	w.converters["rtf"] = convert.RTF{Options: opts}
+}
+
+// SetConverter sets the converter used for content files with the given
+// extension (such as "rtf"), replacing the built-in one.
+func (w *Writer) SetConverter(ext string, c convert.Converter) {
+	w.converters[ext] = c
+}
+
 // UpdateDocumentContent updates the content of an existing document.
 // When useRTF is true, converts markdown to RTF format for Scrivener.
 func (w *Writer) UpdateDocumentContent(docUUID, content string, useRTF bool) error {
+	if err := w.checkWritable(docUUID); err != nil {
+		return err
+	}
+
+	ext := "txt"
+	if useRTF {
+		ext = "rtf"
+	}
+	contentPath := w.contentPath(docUUID, ext)
+	data, err := w.fromMarkdown(ext, contentPath, content)
+	if err != nil {
+		return err
+	}
+	w.writeFile(contentPath, []byte(data))
+	return nil
+}
+
+// PatchDocumentRTF updates an existing document by patching base, a copy of
+// its RTF kept elsewhere, with the text edits of markdown content, so
+// formatting the markdown can't represent survives. An empty base is
+// converted in full. It returns the RTF written.
+func (w *Writer) PatchDocumentRTF(docUUID, content, base string) (string, error) {
+	if err := w.checkWritable(docUUID); err != nil {
+		return "", err
+	}
+	converter, _ := w.converters["rtf"].(convert.RTF)
+	converter.Options.Patch = true
+	contentPath := w.contentPath(docUUID, "rtf")
+	data, err := w.convertOver(converter, contentPath, content, base)
+	if err != nil {
+		return "", err
+	}
+	w.writeFile(contentPath, []byte(data))
+	return data, nil
+}
+
+// UpdateDocumentText replaces the content of an existing document with plain
+// text, stored as RTF without any markdown conversion.
+func (w *Writer) UpdateDocumentText(docUUID, text string) error {
+	if err := w.checkWritable(docUUID); err != nil {
+		return err
+	}
+	w.writeFile(w.contentPath(docUUID, "rtf"), []byte(rtf.ToRTF(text)))
+	return nil
+}
+
+// checkWritable returns an error if a document's content can't be written:
+// imported files in a read-only format, and PDFs, images, web archives and
+// other media items without a text content file, which writing would turn
+// into empty text documents.
+func (w *Writer) checkWritable(docUUID string) error {
+	path, format := findContentFile(w.filesDir, docUUID)
+	if readOnlyFormats[format] {
+		return fmt.Errorf("document %s is an imported %s file and cannot be written", docUUID, format)
+	}
+	if item := w.findBinderItem(docUUID); path == "" && item != nil && isMediaType(item.Type) {
+		return fmt.Errorf("document %s is a %s item and cannot be written", docUUID, item.Type)
+	}
+	return nil
+}
+
+// contentPath returns the path of a document's content file with extension
+// ext. Save creates its content directory if the document has no content
+// yet.
+func (w *Writer) contentPath(docUUID, ext string) string {
 	// Determine content path - try new format first
 	contentDir := filepath.Join(w.filesDir, docUUID)
 	if info, err := os.Stat(contentDir); err == nil && info.IsDir() {
 		// New format: Files/Data/{UUID}/content.rtf
-		var contentPath string
-		var data string
-		if useRTF {
-			contentPath = filepath.Join(contentDir, "content.rtf")
-			data = rtf.MarkdownToRTF(content)
-		} else {
-			contentPath = filepath.Join(contentDir, "content.txt")
-			data = content
+		return filepath.Join(contentDir, "content."+ext)
+	}
+	if existing, _ := findContentFile(w.filesDir, docUUID); existing != "" {
+		// Old format: Files/Data/{UUID}.rtf
+		return filepath.Join(w.filesDir, docUUID+"."+ext)
+	}
+	// No content yet, as for new documents and most folders: the new format
+	return filepath.Join(contentDir, "content."+ext)
+}
+
+// UpdateTitle renames a binder item, marking it modified. Renaming to the
+// current title changes nothing.
+func (w *Writer) UpdateTitle(uuid, title string) error {
+	item := w.findBinderItem(uuid)
+	if item == nil {
+		return fmt.Errorf("binder item %s not found", uuid)
+	}
+	if item.Title == title {
+		return nil
+	}
+	item.Title = title
+	item.Modified = timestamp.FormatBinder(time.Now())
+	w.modified = true
+	return nil
+}
+
+// fromMarkdown converts markdown for the content file at path, passing the
+// converter the file's current content so it can update it in place. With
+// the built-in RTF converter, the CriticMarkup comments of the markdown
+// become the document's inspector comments, and its comments file is
+// rewritten to hold them.
+func (w *Writer) fromMarkdown(ext, path, content string) (string, error) {
+	existing, _ := w.readFile(path)
+	return w.convertOver(w.converters[ext], path, content, string(existing))
+}
+
+// convertOver converts markdown for the content file at path as
+// fromMarkdown does, updating existing in place of the file's content.
+func (w *Writer) convertOver(converter convert.Converter, path, content, existing string) (string, error) {
+	cpath := ""
+	if _, builtin := converter.(convert.RTF); builtin {
+		cpath = commentsPath(path)
+	}
+	if cpath == "" {
+		data, err := converter.FromMarkdown(content, existing)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert %s: %w", path, err)
 		}
-		return os.WriteFile(contentPath, []byte(data), 0644)
+		return data, nil
 	}
 
-	// Old format: Files/Data/{UUID}.rtf
-	var contentPath string
-	var data string
-	if useRTF {
-		contentPath = filepath.Join(w.filesDir, docUUID+".rtf")
-		data = rtf.MarkdownToRTF(content)
-	} else {
-		contentPath = filepath.Join(w.filesDir, docUUID+".txt")
-		data = content
+	old, err := w.readComments(cpath)
+	if err != nil {
+		return "", err
 	}
-	return os.WriteFile(contentPath, []byte(data), 0644)
+	md, comments := criticMarkupToMarkers(content, old, w.generateUUID)
+	data, err := converter.FromMarkdown(md, anchorsToMarkers(existing))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s: %w", path, err)
+	}
+	data = markersToAnchors(data)
+	if len(old) > 0 || len(comments) > 0 {
+		w.writeComments(cpath, comments)
+	}
+	return data, nil
 }
 
 // CreateFolder creates a new folder in the binder.
 func (w *Writer) CreateFolder(title, parentUUID string) (string, error) {
 	newUUID := w.generateUUID()
-	now := time.Now().Format("2006-01-02 15:04:05 -0700")
+	now := timestamp.FormatBinder(time.Now())
 
 	item := XMLBinderItem{
 		UUID:         newUUID,
@@ -165,7 +296,7 @@ func (w *Writer) CreateFolder(title, parentUUID string) (string, error) {
 // CreateDocument creates a new document in the binder.
 func (w *Writer) CreateDocument(title, content, parentUUID string, useRTF bool) (string, error) {
 	newUUID := w.generateUUID()
-	now := time.Now().Format("2006-01-02 15:04:05 -0700")
+	now := timestamp.FormatBinder(time.Now())
 
 	item := XMLBinderItem{
 		UUID:         newUUID,
@@ -187,12 +318,7 @@ func (w *Writer) CreateDocument(title, content, parentUUID string, useRTF bool)
 		}
 	}
 
-	// Create content directory and file
-	contentDir := filepath.Join(w.filesDir, newUUID)
-	if err := os.MkdirAll(contentDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create content directory: %w", err)
-	}
-
+	// Create content file
 	if err := w.UpdateDocumentContent(newUUID, content, useRTF); err != nil {
 		return "", err
 	}
@@ -216,6 +342,38 @@ func (w *Writer) addToParent(items *[]XMLBinderItem, parentUUID string, item XML
 	return false
 }
 
+// AddFavorite adds a binder item to the project's favorites. Items that are
+// already favorites or don't exist in the binder are ignored; the return
+// value reports whether the favorite was added.
+func (w *Writer) AddFavorite(docUUID string) bool {
+	if !w.existingUUIDs[docUUID] {
+		return false
+	}
+	if w.project.Favorites == nil {
+		w.project.Favorites = &XMLFavorites{}
+	}
+
+	// New entries follow the shape of existing ones (element name, and
+	// whether the UUID lives in an attribute or the element text)
+	entry := XMLFavorite{XMLName: xml.Name{Local: "BinderItemUUID"}, Value: docUUID}
+	for _, fav := range w.project.Favorites.Items {
+		if fav.UUID() == docUUID {
+			return false
+		}
+		entry = XMLFavorite{XMLName: fav.XMLName, Value: docUUID}
+		for _, attr := range fav.Attrs {
+			if attr.Name.Local == "ID" || attr.Name.Local == "UUID" {
+				entry.Attrs = []xml.Attr{{Name: attr.Name, Value: docUUID}}
+				entry.Value = ""
+			}
+		}
+	}
+
+	w.project.Favorites.Items = append(w.project.Favorites.Items, entry)
+	w.modified = true
+	return true
+}
+
 // FindFolderByTitle finds a folder by title and returns its UUID.
 func (w *Writer) FindFolderByTitle(title string) (string, error) {
 	uuid := w.findFolderUUID(w.project.Binder.Items, title)
@@ -239,26 +397,104 @@ func (w *Writer) findFolderUUID(items []XMLBinderItem, title string) string {
 	return ""
 }
 
-// Save writes changes back to the project.scrivx file.
+// FindFolderByPath finds a folder by binder path and returns its UUID.
+// Paths are resolved as described on Reader.FindFolderByPath.
+func (w *Writer) FindFolderByPath(path string) (string, error) {
+	segments, anchored := SplitFolderPath(path)
+	if len(segments) == 0 {
+		return "", fmt.Errorf("empty folder path")
+	}
+	if !anchored {
+		return w.FindFolderByTitle(segments[0])
+	}
+
+	items := w.project.Binder.Items
+	var uuid string
+	for _, segment := range segments {
+		item := findFolderItem(items, segment)
+		if item == nil {
+			return "", fmt.Errorf("folder not found: %s", path)
+		}
+		uuid = item.UUID
+		items = item.Children
+	}
+	return uuid, nil
+}
+
+// CreateFolderPath ensures every folder along a binder path exists, creating
+// missing ones, and returns the UUID of the last folder. A single-segment path
+// that is not found anywhere is created at the binder root.
+func (w *Writer) CreateFolderPath(path string) (string, error) {
+	if uuid, err := w.FindFolderByPath(path); err == nil {
+		return uuid, nil
+	}
+
+	segments, _ := SplitFolderPath(path)
+	if len(segments) == 0 {
+		return "", fmt.Errorf("empty folder path")
+	}
+
+	parentUUID := ""
+	items := w.project.Binder.Items
+	for _, segment := range segments {
+		if item := findFolderItem(items, segment); item != nil {
+			parentUUID = item.UUID
+			items = item.Children
+			continue
+		}
+
+		uuid, err := w.CreateFolder(segment, parentUUID)
+		if err != nil {
+			return "", err
+		}
+		parentUUID = uuid
+		items = nil
+	}
+	return parentUUID, nil
+}
+
+// findFolderItem returns the folder among items (not recursive) with the given title.
+func findFolderItem(items []XMLBinderItem, title string) *XMLBinderItem {
+	for i := range items {
+		isFolder := items[i].Type == "Folder" || items[i].Type == "DraftFolder" || items[i].Type == "ResearchFolder"
+		if isFolder && strings.EqualFold(items[i].Title, title) {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+// Save writes changes back to the project: the content files changed since
+// the last save and the project.scrivx file, together, so that if any of them
+// can't be written the project is left as it was.
 func (w *Writer) Save() error {
-	if !w.modified {
+	if !w.modified && len(w.staged) == 0 {
 		return nil
 	}
+	if err := w.CheckIntegrity(); err != nil {
+		return err
+	}
 
-	// Update project modification timestamp and ID
-	w.project.Modified = time.Now().Format("2006-01-02 15:04:05 -0700")
-	w.project.ModID = strings.ToUpper(uuid.New().String())
+	var xmlData []byte
+	if w.modified {
+		// Update project modification timestamp and ID
+		w.project.Modified = timestamp.FormatBinder(time.Now())
+		w.project.ModID = strings.ToUpper(uuid.New().String())
 
-	data, err := xml.MarshalIndent(w.project, "", "    ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal project XML: %w", err)
-	}
+		data, err := xml.MarshalIndent(w.project, "", "    ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal project XML: %w", err)
+		}
 
-	// Add XML declaration
-	xmlData := []byte(xml.Header + string(data))
+		// Add XML declaration
+		xmlData = []byte(xml.Header + string(data))
+	}
 
-	if err := os.WriteFile(w.projectXML, xmlData, 0644); err != nil {
-		return fmt.Errorf("failed to write project file: %w", err)
+	if err := w.commitStaged(xmlData); err != nil {
+		return err
+	}
+	if err := w.removeDeletedContent(); err != nil {
+		return err
 	}
 
 	w.modified = false
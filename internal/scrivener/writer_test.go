@@ -2,10 +2,14 @@ package scrivener
 
 import (
 	"encoding/xml"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/rtf"
 )
 
 // copyTestProject creates a temporary copy of the test project for modification.
@@ -194,6 +198,9 @@ func TestWriter_UpdateContent(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to update content: %v", err)
 	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
 
 	// Read the content file directly
 	contentPath := filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0001", "content.rtf")
@@ -211,6 +218,78 @@ func TestWriter_UpdateContent(t *testing.T) {
 	}
 }
 
+func TestWriter_SaveIsAllOrNothing(t *testing.T) {
+	projectPath := copyTestProject(t)
+	defer os.RemoveAll(filepath.Dir(projectPath))
+
+	contentPath := filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0001", "content.rtf")
+	scrivxPath := filepath.Join(projectPath, "sample.scrivx")
+	content, _ := os.ReadFile(contentPath)
+	scrivx, _ := os.ReadFile(scrivxPath)
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.UpdateDocumentContent("DOC-UUID-0001", "Rewritten.", true); err != nil {
+		t.Fatalf("Failed to update content: %v", err)
+	}
+	newUUID, err := writer.CreateDocument("Chapter Three", "New.", "DRAFT-UUID-0001", true)
+	if err != nil {
+		t.Fatalf("Failed to create document: %v", err)
+	}
+	if err := writer.SetSynopsis("DOC-UUID-0002", "Staged."); err != nil {
+		t.Fatalf("Failed to set synopsis: %v", err)
+	}
+	if data, _ := os.ReadFile(contentPath); string(data) != string(content) {
+		t.Error("Expected content left alone until Save")
+	}
+	if _, err := os.Stat(filepath.Join(projectPath, "Files", "Data", newUUID)); err == nil {
+		t.Error("Expected no content directory for the new document until Save")
+	}
+
+	// The project file can't be written, so nothing is
+	blocker := scrivxPath + stagedSuffix
+	os.Mkdir(blocker, 0755)
+	os.WriteFile(filepath.Join(blocker, "keep"), nil, 0644)
+	if err := writer.Save(); err == nil {
+		t.Fatal("Expected Save to fail")
+	}
+	if data, _ := os.ReadFile(contentPath); string(data) != string(content) {
+		t.Error("Expected content left alone by a failed Save")
+	}
+	if data, _ := os.ReadFile(scrivxPath); string(data) != string(scrivx) {
+		t.Error("Expected the project file left alone by a failed Save")
+	}
+	for _, path := range []string{
+		filepath.Join(projectPath, "Files", "Data", newUUID),
+		filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0002", "synopsis.txt"),
+		contentPath + stagedSuffix,
+	} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("Expected %s not left behind by a failed Save", path)
+		}
+	}
+
+	os.RemoveAll(blocker)
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	for uuid, want := range map[string]string{"DOC-UUID-0001": "Rewritten.", newUUID: "New."} {
+		doc, err := reader.GetDocumentByUUID(uuid)
+		if err != nil || doc.Content != want {
+			t.Errorf("Expected %s saved with %q, got %+v, %v", uuid, want, doc, err)
+		}
+	}
+	if got := reader.Synopsis("DOC-UUID-0002"); got != "Staged." {
+		t.Errorf("Expected the synopsis saved, got %q", got)
+	}
+}
+
 func TestWriter_PreservesProjectAttrs(t *testing.T) {
 	projectPath := copyTestProject(t)
 
@@ -398,3 +477,422 @@ func TestWriter_FindFolderByTitle(t *testing.T) {
 		}
 	}
 }
+
+func TestWriter_FindFolderByPath_DuplicateTitles(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	// Two "Notes" folders in different parts of the binder
+	draftNotes, err := writer.CreateFolderPath("Draft/Notes")
+	if err != nil {
+		t.Fatalf("Failed to create Draft/Notes: %v", err)
+	}
+	researchNotes, err := writer.CreateFolderPath("Research/Characters/Notes")
+	if err != nil {
+		t.Fatalf("Failed to create Research/Characters/Notes: %v", err)
+	}
+	if draftNotes == researchNotes {
+		t.Fatal("Expected distinct folders")
+	}
+
+	uuid, err := writer.FindFolderByPath("Research/Characters/Notes")
+	if err != nil || uuid != researchNotes {
+		t.Errorf("Expected %s, got %s (%v)", researchNotes, uuid, err)
+	}
+	uuid, err = writer.FindFolderByPath("Draft/Notes")
+	if err != nil || uuid != draftNotes {
+		t.Errorf("Expected %s, got %s (%v)", draftNotes, uuid, err)
+	}
+
+	// Creating an existing path returns the existing folder
+	again, err := writer.CreateFolderPath("draft/notes")
+	if err != nil || again != draftNotes {
+		t.Errorf("Expected existing folder %s, got %s (%v)", draftNotes, again, err)
+	}
+}
+
+func TestWriter_AddFavorite(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	if !writer.AddFavorite("DOC-UUID-0001") {
+		t.Error("Expected favorite to be added")
+	}
+	if writer.AddFavorite("DOC-UUID-0001") {
+		t.Error("Duplicate favorite should be ignored")
+	}
+	if writer.AddFavorite("NO-SUCH-UUID") {
+		t.Error("Unknown UUID should be ignored")
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	favorites := reader.GetFavorites()
+	if len(favorites) != 1 || favorites[0] != "DOC-UUID-0001" {
+		t.Errorf("Expected [DOC-UUID-0001], got %v", favorites)
+	}
+}
+
+func TestWriter_MoveItem(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	// Reorder within Draft, then move Hero into Draft at the front
+	if err := writer.MoveItem("DOC-UUID-0002", "DRAFT-UUID-0001", 0); err != nil {
+		t.Fatalf("Failed to reorder: %v", err)
+	}
+	if err := writer.MoveItem("DOC-UUID-0003", "DRAFT-UUID-0001", 1); err != nil {
+		t.Fatalf("Failed to move: %v", err)
+	}
+	if parent, index, ok := writer.Position("DOC-UUID-0003"); !ok || parent != "DRAFT-UUID-0001" || index != 1 {
+		t.Errorf("Expected Hero second in Draft, got %q %d %v", parent, index, ok)
+	}
+	if err := writer.MoveItem("DRAFT-UUID-0001", "DOC-UUID-0001", 0); err == nil {
+		t.Error("Moving a folder into its own child should fail")
+	}
+	if err := writer.UpdateTitle("DOC-UUID-0003", "Heroine"); err != nil {
+		t.Fatalf("Failed to retitle: %v", err)
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	draft, err := reader.FindFolderByPath("Draft")
+	if err != nil {
+		t.Fatalf("Failed to find Draft: %v", err)
+	}
+	var titles []string
+	for _, doc := range draft.Children {
+		titles = append(titles, doc.Title)
+	}
+	if strings.Join(titles, ",") != "Chapter Two,Heroine,Chapter One" {
+		t.Errorf("Unexpected Draft order: %v", titles)
+	}
+}
+
+func TestWriter_TrashAndDeleteItem(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	writer.AddFavorite("DOC-UUID-0002")
+
+	if err := writer.TrashItem("DOC-UUID-0001"); err != nil {
+		t.Fatalf("Failed to trash: %v", err)
+	}
+	if err := writer.DeleteItem("DOC-UUID-0002"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := writer.DeleteItem("DOC-UUID-0002"); err == nil {
+		t.Error("Deleting a missing item should fail")
+	}
+	if err := writer.SetMetadata("DOC-UUID-0003", MetaStatus, "2"); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+	if err := writer.SetIncludeInCompile("DOC-UUID-0003", false); err != nil {
+		t.Fatalf("Failed to set include in compile: %v", err)
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0002")); !os.IsNotExist(err) {
+		t.Error("Expected the deleted document's content to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0001")); err != nil {
+		t.Error("Expected the trashed document's content to be kept")
+	}
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if reader.HasDocument("DOC-UUID-0002") {
+		t.Error("Expected the deleted document to be gone")
+	}
+	if len(reader.GetFavorites()) != 0 {
+		t.Errorf("Expected the favorite to be removed, got %v", reader.GetFavorites())
+	}
+	trash, err := reader.FindFolderByPath("Trash")
+	if err != nil || len(trash.Children) != 1 || trash.Children[0].UUID != "DOC-UUID-0001" {
+		t.Errorf("Expected Chapter One in the Trash, got %+v", trash)
+	}
+
+	data, _ := os.ReadFile(writer.projectXML)
+	if !strings.Contains(string(data), "<StatusID>2</StatusID>") || !strings.Contains(string(data), "<IncludeInCompile>No</IncludeInCompile>") {
+		t.Error("Expected the metadata to be written")
+	}
+}
+
+// TestWriter_SetTargets tests that project targets are read and updated,
+// keeping the target settings this tool doesn't manage.
+func TestWriter_SetTargets(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	targets := reader.Targets()
+	if targets.Draft != 50000 || targets.DraftType != TargetWords || !targets.CountIncludedOnly {
+		t.Errorf("Expected a 50000 word draft target, got %+v", targets)
+	}
+	if !targets.Deadline.IsZero() || targets.Session != 0 {
+		t.Errorf("Expected the ignored deadline and no session target, got %+v", targets)
+	}
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.SetDraftTarget(90000); err != nil {
+		t.Fatalf("Failed to set the draft target: %v", err)
+	}
+	if err := writer.SetSessionTarget(1000); err != nil {
+		t.Fatalf("Failed to set the session target: %v", err)
+	}
+	deadline := time.Date(2026, 6, 30, 23, 59, 59, 0, time.FixedZone("", -6*3600))
+	writer.SetDeadline(deadline)
+	if err := writer.SetDraftTarget(-1); err == nil {
+		t.Error("Expected a negative target to be refused")
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	reader, err = NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	targets = reader.Targets()
+	if targets.Draft != 90000 || targets.Session != 1000 || !targets.Deadline.Equal(deadline) {
+		t.Errorf("Expected the updated targets, got %+v", targets)
+	}
+	data, _ := os.ReadFile(writer.projectXML)
+	if !strings.Contains(string(data), `CurrentCompileGroupOnly="No"`) || !strings.Contains(string(data), `IgnoreDeadline="No"`) {
+		t.Error("Expected the draft target's other settings to be kept")
+	}
+
+	// Removing the deadline keeps its date, as Scrivener does
+	writer.SetDeadline(time.Time{})
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	reader, _ = NewReader(projectPath)
+	if !reader.Targets().Deadline.IsZero() {
+		t.Error("Expected the deadline to be removed")
+	}
+}
+
+// TestWriter_Vocabulary tests adding and renaming labels and statuses.
+func TestWriter_Vocabulary(t *testing.T) {
+	projectPath := copyTestProject(t)
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	id, err := writer.AddLabel("Blue & Green", "#0000ff")
+	if err != nil || id != "1" {
+		t.Fatalf("Expected the label added with ID 1, got %q, %v", id, err)
+	}
+	if _, err := writer.AddLabel("red", ""); !errors.Is(err, ErrVocabExists) {
+		t.Errorf("Expected an existing label to be refused, got %v", err)
+	}
+	if _, err := writer.AddLabel("Pink", "pink"); err == nil {
+		t.Error("Expected an invalid color to be refused")
+	}
+	if err := writer.RenameLabel("red", "Crimson"); err != nil {
+		t.Fatalf("Failed to rename label: %v", err)
+	}
+	if err := writer.RenameLabel("Crimson", "blue & green"); !errors.Is(err, ErrVocabExists) {
+		t.Errorf("Expected renaming onto another label to be refused, got %v", err)
+	}
+	if id, err := writer.AddStatus("Revised"); err != nil || id != "3" {
+		t.Fatalf("Expected the status added with ID 3, got %q, %v", id, err)
+	}
+	if err := writer.RenameStatus("Missing", "Other"); !errors.Is(err, ErrVocabNotFound) {
+		t.Errorf("Expected an unknown status to be reported, got %v", err)
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	labels := reader.Labels()
+	want := []Label{{ID: "0", Title: "Crimson", Color: "#fdb3bb"}, {ID: "1", Title: "Blue & Green", Color: "#0000ff"}}
+	if len(labels) != 2 || labels[0] != want[0] || labels[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, labels)
+	}
+	statuses := reader.Statuses()
+	if len(statuses) != 4 || statuses[3] != (Status{ID: "3", Title: "Revised"}) {
+		t.Errorf("Expected the Revised status, got %+v", statuses)
+	}
+	data, _ := os.ReadFile(writer.projectXML)
+	if !strings.Contains(string(data), "\n        <Status ID=\"3\">Revised</Status>\n    </StatusSettings>") {
+		t.Error("Expected the new status indented like the others")
+	}
+}
+
+// TestWriter_Comments tests that inspector comments are read as CriticMarkup
+// and that pushing markdown rewrites them, keeping unchanged ones as they were.
+func TestWriter_Comments(t *testing.T) {
+	projectPath := copyTestProject(t)
+	defer os.RemoveAll(filepath.Dir(projectPath))
+
+	dir := filepath.Join(projectPath, "Files", "Data", "DOC-UUID-0001")
+	os.WriteFile(filepath.Join(dir, "content.rtf"), []byte(`{\rtf1\ansi{\fonttbl\f0\fnil\fcharset0 Helvetica;}
+\pard\f0\fs24 The {\field{\*\fldinst{HYPERLINK "scrivcmt://CMT-1"}}{\fldrslt story}} begins {\field{\*\fldinst{HYPERLINK "scrivcmt://CMT-2"}}{\fldrslt here}}.\par
+Second paragraph.}`), 0644)
+	os.WriteFile(filepath.Join(dir, "content.comments"), []byte(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<Comments Version="1.0">
+    <Comment ID="CMT-1" Footnote="No" Color="0.9 0.9 0.5"><![CDATA[{\rtf1\ansi{\fonttbl\f0\fnil Helvetica;}\f0\fs20 Which story?}]]></Comment>
+    <Comment ID="CMT-2" Footnote="No"><![CDATA[{\rtf1\ansi Cut this.}]]></Comment>
+</Comments>
+`), 0644)
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	doc, err := reader.GetDocumentByUUID("DOC-UUID-0001")
+	if err != nil {
+		t.Fatalf("Failed to read document: %v", err)
+	}
+	want := "The {==story==}{>>Which story?<<} begins {==here==}{>>Cut this.<<}.\nSecond paragraph."
+	if doc.Content != want {
+		t.Fatalf("Expected %q, got %q", want, doc.Content)
+	}
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	md := "The {==story==}{>>Which story?<<} begins here.\nSecond{>>Expand.<<} paragraph."
+	if err := writer.UpdateDocumentContent("DOC-UUID-0001", md, true); err != nil {
+		t.Fatalf("Failed to update content: %v", err)
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "content.comments"))
+	comments := string(data)
+	if !strings.Contains(comments, `<Comment ID="CMT-1" Footnote="No" Color="0.9 0.9 0.5">`) || strings.Contains(comments, "CMT-2") {
+		t.Errorf("Expected the unchanged comment kept and the removed one dropped, got:\n%s", comments)
+	}
+	if strings.Count(comments, "<Comment ") != 2 || !strings.Contains(comments, "Expand.") {
+		t.Errorf("Expected the new comment added, got:\n%s", comments)
+	}
+
+	reader, err = NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	doc, err = reader.GetDocumentByUUID("DOC-UUID-0001")
+	if err != nil {
+		t.Fatalf("Failed to read document: %v", err)
+	}
+	want = "The {==story==}{>>Which story?<<} begins here.\n{==Second==}{>>Expand.<<} paragraph."
+	if doc.Content != want {
+		t.Errorf("Expected %q, got %q", want, doc.Content)
+	}
+
+	// Patching keeps the anchors of unchanged paragraphs as they were
+	opts := rtf.DefaultOptions()
+	opts.Patch = true
+	writer.SetConversionOptions(opts)
+	before, _ := os.ReadFile(filepath.Join(dir, "content.rtf"))
+	if err := writer.UpdateDocumentContent("DOC-UUID-0001", want+"\nThird paragraph.", true); err != nil {
+		t.Fatalf("Failed to update content: %v", err)
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, "content.rtf"))
+	line := strings.Split(string(before), "\n")[1]
+	if !strings.Contains(line, `{\field{\*\fldinst{HYPERLINK "scrivcmt://CMT-1"}}`) || !strings.Contains(string(after), line) {
+		t.Errorf("Expected the first paragraph kept with its anchor, got:\n%s", after)
+	}
+}
+
+func TestWriter_AddWrittenWords(t *testing.T) {
+	projectPath := copyTestProject(t)
+	history := `<?xml version="1.0" encoding="UTF-8"?>
+<WritingHistory>
+    <Day DWC="300" DCC="1500" OWC="0" OCC="0">2025-01-01</Day>
+    <Day DWC="450" DCC="2200" OWC="10" OCC="50">2025-01-03</Day>
+</WritingHistory>`
+	path := filepath.Join(projectPath, "Files", "writing.history")
+	if err := os.WriteFile(path, []byte(history), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewWriter(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.AddWrittenWords("2025-01-03", 50, -20); err != nil {
+		t.Fatalf("Failed to add words: %v", err)
+	}
+	if err := writer.AddWrittenWords("2025-01-04", 120, 5); err != nil {
+		t.Fatalf("Failed to add words: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != history {
+		t.Error("Expected the writing history written only on Save")
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `<Day DWC="300" DCC="1500" OWC="0" OCC="0">2025-01-01</Day>`) {
+		t.Errorf("Expected other days kept as they were, got:\n%s", data)
+	}
+
+	reader, err := NewReader(projectPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	days, err := reader.WritingHistory()
+	if err != nil {
+		t.Fatalf("Failed to read writing history: %v", err)
+	}
+	expected := []WritingDay{
+		{Date: "2025-01-01", DraftWords: 300, DraftChars: 1500},
+		{Date: "2025-01-03", DraftWords: 500, DraftChars: 2200, OtherWords: 0, OtherChars: 50},
+		{Date: "2025-01-04", DraftWords: 120, OtherWords: 5},
+	}
+	if len(days) != len(expected) {
+		t.Fatalf("Expected %d days, got %+v", len(expected), days)
+	}
+	for i := range expected {
+		if days[i] != expected[i] {
+			t.Errorf("Day %d: expected %+v, got %+v", i, expected[i], days[i])
+		}
+	}
+}
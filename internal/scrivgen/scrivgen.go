@@ -0,0 +1,166 @@
+// Package scrivgen generates synthetic Scrivener projects of a chosen size
+// for benchmarks and performance testing.
+package scrivgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/rtf"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// timestamp is used for every generated binder item so output is reproducible.
+const timestamp = "2025-01-01 12:00:00 -0600"
+
+// Options describes the project to generate.
+type Options struct {
+	Docs      int   // number of text documents
+	Folders   int   // number of folders under Draft; documents are spread across them
+	DocSizeKB int   // approximate markdown size of each document
+	Seed      int64 // seed for generated text, so runs are reproducible
+}
+
+// DefaultOptions returns a small project: 100 documents of 4KB in 10 folders.
+func DefaultOptions() Options {
+	return Options{Docs: 100, Folders: 10, DocSizeKB: 4, Seed: 1}
+}
+
+// Generate writes a new Scrivener project at path (a .scriv directory) and
+// returns the path. The Draft folder holds opts.Folders folders containing
+// opts.Docs documents in round-robin order.
+func Generate(path string, opts Options) (string, error) {
+	if opts.Docs < 0 || opts.Folders < 0 || opts.DocSizeKB < 0 {
+		return "", fmt.Errorf("sizes must not be negative")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+
+	dataDir := filepath.Join(path, "Files", "Data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	folders := make([]scrivener.XMLBinderItem, opts.Folders)
+	for i := range folders {
+		folders[i] = binderItem(fmt.Sprintf("FOLDER-%06d", i+1), "Folder", fmt.Sprintf("Part %d", i+1))
+	}
+
+	var loose []scrivener.XMLBinderItem
+	for i := 0; i < opts.Docs; i++ {
+		title := fmt.Sprintf("Scene %d", i+1)
+		doc := binderItem(fmt.Sprintf("DOC-%06d", i+1), "Text", title)
+		doc.TextSettings = &scrivener.XMLTextSettings{TextSelection: "0,0"}
+
+		content := rtf.MarkdownToRTF(Markdown(rng, title, opts.DocSizeKB))
+		docDir := filepath.Join(dataDir, doc.UUID)
+		if err := os.MkdirAll(docDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create document directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(docDir, "content.rtf"), []byte(content), 0644); err != nil {
+			return "", fmt.Errorf("failed to write document: %w", err)
+		}
+
+		if len(folders) == 0 {
+			loose = append(loose, doc)
+		} else {
+			f := &folders[i%len(folders)]
+			f.Children = append(f.Children, doc)
+		}
+	}
+
+	draft := binderItem("DRAFT-000001", "DraftFolder", "Draft")
+	draft.MetaData = nil
+	draft.Children = append(folders, loose...)
+
+	research := binderItem("RESEARCH-000001", "ResearchFolder", "Research")
+	research.MetaData = nil
+	trash := binderItem("TRASH-000001", "TrashFolder", "Trash")
+	trash.MetaData = nil
+
+	project := scrivener.XMLProject{
+		Identifier: fmt.Sprintf("SYNTHETIC-%d", opts.Seed),
+		Version:    "2.0",
+		Creator:    "scrivgen",
+		Modified:   timestamp,
+		ModID:      "SYNTHETIC",
+		Binder:     scrivener.XMLBinder{Items: []scrivener.XMLBinderItem{draft, research, trash}},
+	}
+
+	data, err := xml.MarshalIndent(project, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal project XML: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".scriv") + ".scrivx"
+	if err := os.WriteFile(filepath.Join(path, name), []byte(xml.Header+string(data)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write project file: %w", err)
+	}
+
+	return path, nil
+}
+
+// binderItem returns a binder item with fixed timestamps.
+func binderItem(uuid, itemType, title string) scrivener.XMLBinderItem {
+	return scrivener.XMLBinderItem{
+		UUID:     uuid,
+		Type:     itemType,
+		Created:  timestamp,
+		Modified: timestamp,
+		Title:    title,
+		MetaData: &scrivener.XMLMetaData{IncludeInCompile: "Yes"},
+	}
+}
+
+// words is the vocabulary for generated text.
+var words = strings.Fields(`the a harbor lantern quiet storm letter river
+	winter coach ice rink mother window promise road evening morning
+	ran waited whispered turned remembered carried found lost kept
+	slowly never again almost bright cold old small long`)
+
+// Markdown returns roughly sizeKB kilobytes of markdown with a heading,
+// paragraphs with inline formatting, and an occasional list.
+func Markdown(rng *rand.Rand, title string, sizeKB int) string {
+	var b strings.Builder
+	b.WriteString("# " + title + "\n\n")
+
+	target := sizeKB * 1024
+	for b.Len() < target {
+		switch rng.Intn(8) {
+		case 0:
+			for i := 0; i < 3; i++ {
+				b.WriteString("- " + sentence(rng, 6) + "\n")
+			}
+		case 1:
+			b.WriteString("## " + sentence(rng, 3) + "\n")
+		default:
+			for i := 0; i < 4; i++ {
+				s := sentence(rng, 12)
+				if i == 1 {
+					s = "**" + s + "**"
+				}
+				b.WriteString(s + ". ")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// sentence returns n random words with the first capitalized.
+func sentence(rng *rand.Rand, n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = words[rng.Intn(len(words))]
+	}
+	parts[0] = strings.ToUpper(parts[0][:1]) + parts[0][1:]
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,48 @@
+package scrivgen
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+func TestGenerate_ReadableProject(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Synthetic.scriv")
+	opts := Options{Docs: 25, Folders: 4, DocSizeKB: 2, Seed: 7}
+
+	if _, err := Generate(path, opts); err != nil {
+		t.Fatalf("Failed to generate project: %v", err)
+	}
+
+	reader, err := scrivener.NewReader(path)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	docs, err := reader.GetAllDocuments()
+	if err != nil {
+		t.Fatalf("Failed to read documents: %v", err)
+	}
+	if len(docs) != opts.Docs {
+		t.Errorf("Expected %d documents, got %d", opts.Docs, len(docs))
+	}
+	for _, doc := range docs {
+		if len(doc.Content) < 1024 {
+			t.Errorf("Expected about 2KB of content in %s, got %d bytes", doc.Title, len(doc.Content))
+			break
+		}
+	}
+
+	folder, err := reader.FindFolderByPath("Draft/Part 4")
+	if err != nil {
+		t.Fatalf("Failed to find generated folder: %v", err)
+	}
+	if len(folder.Children) != 6 {
+		t.Errorf("Expected 6 documents in the last folder, got %d", len(folder.Children))
+	}
+
+	if _, err := Generate(path, opts); err == nil {
+		t.Error("Expected an error when the project already exists")
+	}
+}
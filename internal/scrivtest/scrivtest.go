@@ -0,0 +1,250 @@
+// Package scrivtest builds small Scrivener projects for tests: a binder of
+// folders and documents with their content, written to a temporary
+// directory, so a test sets up exactly the project it needs instead of
+// copying a sample one.
+package scrivtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sweiss/harcroft/internal/rtf"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// timestamp is used for every binder item so built projects are reproducible.
+const timestamp = "2025-01-01 12:00:00 -0600"
+
+// Project describes a Scrivener project to build. Items are added by binder
+// path, such as "Draft/Part One/Opening"; folders on the way that don't
+// exist yet are created. Every project has the Draft, Research and Trash
+// folders, and other names at the top level become folders beside them.
+//
+// The add methods return the project so calls can be chained; the first
+// error among them is returned by Write.
+type Project struct {
+	name   string
+	roots  []*item
+	byPath map[string]*item
+	ids    map[string]int
+	err    error
+}
+
+// item is a binder item of a project being built.
+type item struct {
+	uuid     string
+	itemType string
+	title    string
+	content  *string // RTF; nil for an item without a content file
+	synopsis string
+	children []*item
+}
+
+// New returns a project named name (without ".scriv") holding only the
+// Draft, Research and Trash folders.
+func New(name string) *Project {
+	p := &Project{name: name, byPath: make(map[string]*item), ids: make(map[string]int)}
+	for _, root := range []struct{ title, itemType, prefix string }{
+		{"Draft", "DraftFolder", "DRAFT"},
+		{"Research", "ResearchFolder", "RESEARCH"},
+		{"Trash", "TrashFolder", "TRASH"},
+	} {
+		it := &item{uuid: p.newUUID(root.prefix), itemType: root.itemType, title: root.title}
+		p.roots = append(p.roots, it)
+		p.byPath[root.title] = it
+	}
+	return p
+}
+
+// Folder adds a folder, and any missing folders above it.
+func (p *Project) Folder(path string) *Project {
+	p.add(path, "Folder", nil)
+	return p
+}
+
+// Doc adds a text document with markdown content, converted to RTF.
+func (p *Project) Doc(path, markdown string) *Project {
+	content := rtf.MarkdownToRTF(markdown)
+	p.add(path, "Text", &content)
+	return p
+}
+
+// RTFDoc adds a text document whose content file holds rtfContent as is.
+func (p *Project) RTFDoc(path, rtfContent string) *Project {
+	p.add(path, "Text", &rtfContent)
+	return p
+}
+
+// Synopsis sets the index card text of an item added before.
+func (p *Project) Synopsis(path, text string) *Project {
+	it, ok := p.byPath[path]
+	if !ok {
+		p.fail(fmt.Errorf("no item at %s to set the synopsis of", path))
+		return p
+	}
+	it.synopsis = text
+	return p
+}
+
+// UUID returns the UUID of the item at path, or "" if there is none.
+func (p *Project) UUID(path string) string {
+	if it, ok := p.byPath[path]; ok {
+		return it.uuid
+	}
+	return ""
+}
+
+// Build writes the project to a temporary directory of the test and returns
+// the path of the .scriv directory. It stops the test if writing fails.
+func (p *Project) Build(t testing.TB) string {
+	t.Helper()
+	path, err := p.Write(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to build project: %v", err)
+	}
+	return path
+}
+
+// Write writes the project to a new .scriv directory in dir and returns its
+// path.
+func (p *Project) Write(dir string) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	path := filepath.Join(dir, p.name+".scriv")
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	dataDir := filepath.Join(path, "Files", "Data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	items := make([]scrivener.XMLBinderItem, 0, len(p.roots))
+	for _, root := range p.roots {
+		x, err := root.write(dataDir)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, x)
+	}
+
+	project := scrivener.XMLProject{
+		Identifier: "SCRIVTEST-" + strings.ToUpper(p.name),
+		Version:    "2.0",
+		Creator:    "scrivtest",
+		Modified:   timestamp,
+		ModID:      "SCRIVTEST",
+		Binder:     scrivener.XMLBinder{Items: items},
+	}
+	data, err := xml.MarshalIndent(project, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal project XML: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, p.name+".scrivx"), []byte(xml.Header+string(data)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write project file: %w", err)
+	}
+	return path, nil
+}
+
+// add adds an item of itemType at path, creating the folders above it.
+func (p *Project) add(path, itemType string, content *string) {
+	if p.err != nil {
+		return
+	}
+	if _, ok := p.byPath[path]; ok {
+		p.fail(fmt.Errorf("%s was added twice", path))
+		return
+	}
+	parts := strings.Split(path, "/")
+	for _, part := range parts {
+		if part == "" {
+			p.fail(fmt.Errorf("invalid binder path %q", path))
+			return
+		}
+	}
+
+	var parent *item
+	for i, title := range parts {
+		sub := strings.Join(parts[:i+1], "/")
+		it, ok := p.byPath[sub]
+		if !ok {
+			it = &item{itemType: "Folder", title: title}
+			if i == len(parts)-1 {
+				it.itemType, it.content = itemType, content
+			}
+			prefix := "FOLDER"
+			if it.itemType == "Text" {
+				prefix = "DOC"
+			}
+			it.uuid = p.newUUID(prefix)
+			if parent == nil {
+				p.roots = append(p.roots, it)
+			} else {
+				parent.children = append(parent.children, it)
+			}
+			p.byPath[sub] = it
+		}
+		parent = it
+	}
+}
+
+// newUUID returns the next UUID with a prefix, such as DOC-000001.
+func (p *Project) newUUID(prefix string) string {
+	p.ids[prefix]++
+	return fmt.Sprintf("%s-%06d", prefix, p.ids[prefix])
+}
+
+// fail records the first error of the add methods.
+func (p *Project) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// write writes the files of an item and its children, and returns its
+// binder entry.
+func (it *item) write(dataDir string) (scrivener.XMLBinderItem, error) {
+	x := scrivener.XMLBinderItem{
+		UUID:     it.uuid,
+		Type:     it.itemType,
+		Created:  timestamp,
+		Modified: timestamp,
+		Title:    it.title,
+	}
+	switch it.itemType {
+	case "Folder", "Text":
+		x.MetaData = &scrivener.XMLMetaData{IncludeInCompile: "Yes"}
+	}
+
+	if it.content != nil || it.synopsis != "" {
+		docDir := filepath.Join(dataDir, it.uuid)
+		if err := os.MkdirAll(docDir, 0755); err != nil {
+			return x, fmt.Errorf("failed to create document directory: %w", err)
+		}
+		if it.content != nil {
+			x.TextSettings = &scrivener.XMLTextSettings{TextSelection: "0,0"}
+			if err := os.WriteFile(filepath.Join(docDir, "content.rtf"), []byte(*it.content), 0644); err != nil {
+				return x, fmt.Errorf("failed to write document: %w", err)
+			}
+		}
+		if it.synopsis != "" {
+			if err := os.WriteFile(filepath.Join(docDir, "synopsis.txt"), []byte(it.synopsis), 0644); err != nil {
+				return x, fmt.Errorf("failed to write synopsis: %w", err)
+			}
+		}
+	}
+
+	for _, child := range it.children {
+		cx, err := child.write(dataDir)
+		if err != nil {
+			return x, err
+		}
+		x.Children = append(x.Children, cx)
+	}
+	return x, nil
+}
@@ -0,0 +1,74 @@
+package scrivtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+func TestBuild_ReadableProject(t *testing.T) {
+	p := New("Novel").
+		Doc("Draft/Part One/Opening", "The *harbor* was quiet.").
+		Doc("Draft/Part One/Storm", "Rain, then wind.").
+		Folder("Draft/Part Two").
+		Doc("Research/Places/Harbor", "Notes on the harbor.").
+		Synopsis("Draft/Part One/Opening", "Arrival at dawn").
+		RTFDoc("Notes", `{\rtf1\ansi Loose notes.}`)
+
+	reader, err := scrivener.NewReader(p.Build(t))
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	docs, err := reader.GetAllDocuments()
+	if err != nil {
+		t.Fatalf("Failed to read documents: %v", err)
+	}
+	if len(docs) != 4 {
+		t.Errorf("Expected 4 documents, got %d", len(docs))
+	}
+
+	opening, err := reader.GetDocumentByUUID(p.UUID("Draft/Part One/Opening"))
+	if err != nil {
+		t.Fatalf("Failed to find document by UUID: %v", err)
+	}
+	if opening.Title != "Opening" || !strings.Contains(opening.Content, "*harbor*") {
+		t.Errorf("Unexpected document %q with content %q", opening.Title, opening.Content)
+	}
+	if got := reader.Synopsis(opening.UUID); got != "Arrival at dawn" {
+		t.Errorf("Expected the synopsis, got %q", got)
+	}
+
+	part, err := reader.FindFolderByPath("Draft/Part One")
+	if err != nil {
+		t.Fatalf("Failed to find folder: %v", err)
+	}
+	if len(part.Children) != 2 || part.Children[1].Title != "Storm" {
+		t.Errorf("Expected Opening and Storm in binder order, got %d children", len(part.Children))
+	}
+	if _, err := reader.FindFolderByPath("Draft/Part Two"); err != nil {
+		t.Errorf("Expected the empty folder: %v", err)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	if _, err := New("Dup").Doc("Draft/A", "a").Doc("Draft/A", "b").Write(t.TempDir()); err == nil {
+		t.Error("Expected an error for an item added twice")
+	}
+	if _, err := New("Empty").Doc("Draft//A", "a").Write(t.TempDir()); err == nil {
+		t.Error("Expected an error for an empty title in a path")
+	}
+	if _, err := New("Missing").Synopsis("Draft/A", "s").Write(t.TempDir()); err == nil {
+		t.Error("Expected an error for a synopsis of a missing item")
+	}
+
+	dir := t.TempDir()
+	p := New("Twice").Doc("Draft/A", "a")
+	if _, err := p.Write(dir); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+	if _, err := p.Write(dir); err == nil {
+		t.Error("Expected an error when the project already exists")
+	}
+}
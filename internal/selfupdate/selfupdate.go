@@ -0,0 +1,279 @@
+// Package selfupdate replaces the running scriv-sync binary with the latest
+// GitHub release built for the platform, after verifying its checksum and,
+// when a release key is built in, the signature of the checksums.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRepo is the GitHub repository releases are fetched from.
+const DefaultRepo = "stephencweiss/scrivener-sync"
+
+// ChecksumsFile is the release asset listing the SHA-256 of every binary, one
+// "<hex>  <name>" line each, as written by sha256sum. ChecksumsFile + ".sig"
+// holds its base64 Ed25519 signature.
+const ChecksumsFile = "checksums.txt"
+
+// PublicKey is the base64 Ed25519 key release checksums are signed with, set
+// at build time with -ldflags "-X .../selfupdate.PublicKey=...". When it is
+// set, releases without a valid signature are refused.
+var PublicKey string
+
+// Release is a published release and its downloadable assets.
+type Release struct {
+	Tag    string
+	Assets map[string]string // asset name -> download URL
+}
+
+// Updater fetches releases from GitHub.
+type Updater struct {
+	Repo    string       // owner/name of the GitHub repository
+	APIBase string       // GitHub API URL; "" for https://api.github.com
+	Client  *http.Client // nil for a client with a timeout
+}
+
+// New creates an Updater for repo ("" for DefaultRepo).
+func New(repo string) *Updater {
+	if repo == "" {
+		repo = DefaultRepo
+	}
+	return &Updater{Repo: repo}
+}
+
+// AssetName returns the name of the release binary for a platform, matching
+// the Makefile's build-all target: scriv-sync-<os>-<arch>, with .exe on
+// Windows.
+func AssetName(goos, goarch string) string {
+	name := fmt.Sprintf("scriv-sync-%s-%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Latest returns the latest published release.
+func (u *Updater) Latest() (*Release, error) {
+	base := u.APIBase
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	data, err := u.get(fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(base, "/"), u.Repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest release: %w", err)
+	}
+
+	var resp struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse the latest release: %w", err)
+	}
+	if resp.TagName == "" {
+		return nil, fmt.Errorf("the latest release of %s has no tag", u.Repo)
+	}
+	rel := &Release{Tag: resp.TagName, Assets: make(map[string]string)}
+	for _, a := range resp.Assets {
+		rel.Assets[a.Name] = a.URL
+	}
+	return rel, nil
+}
+
+// Download fetches the release binary for the running platform and verifies
+// it against the release checksums, and the checksums against their
+// signature when PublicKey is set.
+func (u *Updater) Download(rel *Release) ([]byte, error) {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	binURL, ok := rel.Assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no binary for %s/%s (%s)", rel.Tag, runtime.GOOS, runtime.GOARCH, name)
+	}
+	sumsURL, ok := rel.Assets[ChecksumsFile]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s to verify the download with", rel.Tag, ChecksumsFile)
+	}
+
+	sums, err := u.get(sumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ChecksumsFile, err)
+	}
+	if PublicKey != "" {
+		sigURL, ok := rel.Assets[ChecksumsFile+".sig"]
+		if !ok {
+			return nil, fmt.Errorf("release %s is not signed", rel.Tag)
+		}
+		sig, err := u.get(sigURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download the signature: %w", err)
+		}
+		if err := VerifySignature(sums, sig, PublicKey); err != nil {
+			return nil, err
+		}
+	}
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	bin, err := u.get(binURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	got := sha256.Sum256(bin)
+	if hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: the download is corrupt or was tampered with", name)
+	}
+	return bin, nil
+}
+
+// get fetches a URL, failing on any status but 200.
+func (u *Updater) get(url string) ([]byte, error) {
+	client := u.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "scriv-sync-self-update")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// checksumFor returns the SHA-256 listed for name in a checksums file.
+func checksumFor(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", ChecksumsFile, name)
+}
+
+// VerifySignature checks a base64 Ed25519 signature of data against a base64
+// public key.
+func VerifySignature(data, sig []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid release signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, raw) {
+		return fmt.Errorf("release signature does not match: the checksums were not signed with the release key")
+	}
+	return nil
+}
+
+// Replace writes a new binary over the executable at exe. The new binary is
+// written next to it and renamed into place, so a failure leaves the old one
+// working. Windows can't overwrite a running program, so there the old one
+// is moved aside to exe + ".old" first.
+func Replace(exe string, bin []byte) error {
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", exe, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".scriv-sync-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write the new binary next to %s: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write the new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		return fmt.Errorf("failed to make the new binary executable: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", exe, err)
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(old, exe)
+			return fmt.Errorf("failed to replace %s: %w", exe, err)
+		}
+		return nil
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	return nil
+}
+
+// Newer reports whether release tag latest is a newer version than current.
+// Both are compared as vMAJOR.MINOR.PATCH; a current version that isn't one,
+// such as "dev" or a git describe suffix past a tag, reports ok false.
+func Newer(latest, current string) (newer, ok bool) {
+	l, lok := parseVersion(latest)
+	c, cok := parseVersion(current)
+	if !lok || !cok {
+		return false, false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i], true
+		}
+	}
+	return false, true
+}
+
+// parseVersion parses "v1.2.3" (or "1.2"), returning its major, minor and
+// patch numbers.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	fields := strings.Split(strings.TrimPrefix(strings.TrimSpace(v), "v"), ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
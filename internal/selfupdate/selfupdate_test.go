@@ -0,0 +1,129 @@
+package selfupdate
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// newReleaseServer serves a latest release with the platform's binary, its
+// checksums and, if key is set, their signature.
+func newReleaseServer(t *testing.T, bin []byte, sum string, key ed25519.PrivateKey) *httptest.Server {
+	t.Helper()
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	sums := []byte(fmt.Sprintf("0000  scriv-sync-plan9-mips\n%s  %s\n", sum, name))
+
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/repos/owner/repo/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "v1.4.0", "assets": [
+			{"name": %q, "browser_download_url": "%s/bin"},
+			{"name": "checksums.txt", "browser_download_url": "%s/sums"},
+			{"name": "checksums.txt.sig", "browser_download_url": "%s/sig"}]}`, name, srv.URL, srv.URL, srv.URL)
+	})
+	mux.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) { w.Write(bin) })
+	mux.HandleFunc("/sums", func(w http.ResponseWriter, r *http.Request) { w.Write(sums) })
+	mux.HandleFunc("/sig", func(w http.ResponseWriter, r *http.Request) {
+		if key == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, sums))))
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestDownload_VerifiesChecksumAndSignature tests that a download is checked
+// against the release checksums and their signature.
+func TestDownload_VerifiesChecksumAndSignature(t *testing.T) {
+	bin := []byte("new binary")
+	hash := sha256.Sum256(bin)
+	pub, priv, _ := ed25519.GenerateKey(nil)
+
+	srv := newReleaseServer(t, bin, hex.EncodeToString(hash[:]), priv)
+	u := &Updater{Repo: "owner/repo", APIBase: srv.URL}
+	rel, err := u.Latest()
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	if rel.Tag != "v1.4.0" {
+		t.Errorf("Expected tag v1.4.0, got %q", rel.Tag)
+	}
+	got, err := u.Download(rel)
+	if err != nil || string(got) != "new binary" {
+		t.Fatalf("Expected the verified binary, got %q, %v", got, err)
+	}
+
+	// A signed release verifies with the right key only
+	PublicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { PublicKey = "" })
+	if _, err := u.Download(rel); err != nil {
+		t.Errorf("Expected the signature to verify, got %v", err)
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+	PublicKey = base64.StdEncoding.EncodeToString(other)
+	if _, err := u.Download(rel); err == nil {
+		t.Error("Expected a signature from another key to be refused")
+	}
+	PublicKey = ""
+
+	// A binary not matching its checksum is refused
+	srv = newReleaseServer(t, []byte("tampered"), hex.EncodeToString(hash[:]), nil)
+	u = &Updater{Repo: "owner/repo", APIBase: srv.URL}
+	rel, _ = u.Latest()
+	if _, err := u.Download(rel); err == nil {
+		t.Error("Expected a checksum mismatch to be refused")
+	}
+}
+
+// TestReplace tests that the executable is replaced and stays executable.
+func TestReplace(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "scriv-sync")
+	os.WriteFile(exe, []byte("old binary"), 0755)
+
+	if err := Replace(exe, []byte("new binary")); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	data, _ := os.ReadFile(exe)
+	if string(data) != "new binary" {
+		t.Errorf("Expected the new binary, got %q", data)
+	}
+	if info, _ := os.Stat(exe); runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+		t.Errorf("Expected the new binary executable, got mode %v", info.Mode())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(exe))
+	if runtime.GOOS != "windows" && len(entries) != 1 {
+		t.Errorf("Expected no temp files left behind, got %d entries", len(entries))
+	}
+}
+
+// TestNewer tests release version comparison.
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		newer, ok       bool
+	}{
+		{"v1.4.0", "v1.3.9", true, true},
+		{"v1.4.0", "v1.4.0", false, true},
+		{"v1.4.0", "v1.10.0", false, true},
+		{"v2.0", "1.9.9", true, true},
+		{"v1.4.0", "dev", false, false},
+		{"v1.4.0", "v1.3.0-2-gabc123", false, false},
+	}
+	for _, tt := range tests {
+		newer, ok := Newer(tt.latest, tt.current)
+		if newer != tt.newer || ok != tt.ok {
+			t.Errorf("Newer(%q, %q) = %v, %v; want %v, %v", tt.latest, tt.current, newer, ok, tt.newer, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// BinderIndexFileName is the decorated binder index kept at the markdown
+// root with decorations: index.
+const BinderIndexFileName = "_binder.md"
+
+// isIndexFile reports whether a markdown file name is one of the indexes the
+// sync generates, which are never synced as documents.
+func isIndexFile(name string) bool {
+	return name == BookmarksFileName || name == BinderIndexFileName
+}
+
+// writeBinderIndex regenerates _binder.md from the binder: each item with its
+// label color, label and icon, linked to its synced markdown file.
+func (s *Syncer) writeBinderIndex() error {
+	// Only the scriv_path project's binder is indexed
+	if s.config.Options.Decorations != config.DecorationsIndex || s.project != "" {
+		return nil
+	}
+
+	docs, err := s.reader.GetBinderStructure()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("# Binder\n\n")
+	b.WriteString("<!-- Generated by scriv-sync from the Scrivener binder. -->\n\n")
+	for _, doc := range docs {
+		if !doc.IsTrash() {
+			s.renderBinderItem(&b, doc, 0)
+		}
+	}
+	index := b.String()
+
+	indexPath := filepath.Join(s.mdRoot, BinderIndexFileName)
+	existing, _ := os.ReadFile(indexPath)
+	if string(existing) == index {
+		return nil
+	}
+	if err := os.WriteFile(indexPath, []byte(index), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", indexPath, err)
+	}
+	fmt.Printf("  Updated %s\n", indexPath)
+	return nil
+}
+
+// renderBinderItem writes one binder item and its children as nested list
+// entries, linking each item that is synced to its markdown file.
+func (s *Syncer) renderBinderItem(b *strings.Builder, doc *scrivener.Document, depth int) {
+	entry := doc.Title
+	if mdPath := s.state.GetPathForUUID(doc.UUID); mdPath != "" {
+		rel, err := filepath.Rel(s.mdRoot, mdPath)
+		if err != nil {
+			rel = mdPath
+		}
+		entry = fmt.Sprintf("[%s](%s)", doc.Title, filepath.ToSlash(rel))
+	}
+	if label, ok := s.reader.Label(doc.LabelID); ok {
+		if label.Color != "" {
+			entry += fmt.Sprintf(` · <span style="color:%s">■</span> %s`, label.Color, label.Title)
+		} else {
+			entry += " · " + label.Title
+		}
+	}
+	if doc.Icon != "" {
+		entry += " · icon: " + doc.Icon
+	}
+	fmt.Fprintf(b, "%s- %s\n", strings.Repeat("  ", depth), entry)
+
+	for _, child := range doc.Children {
+		s.renderBinderItem(b, child, depth+1)
+	}
+}
+
+// afterSync saves baselines brought up to date while planning and updates
+// the generated indexes once a sync has run.
+func (s *Syncer) afterSync(pull, push bool) error {
+	if s.rebaselined {
+		if err := s.state.Save(); err != nil {
+			return fmt.Errorf("failed to save sync state: %w", err)
+		}
+		s.rebaselined = false
+	}
+	if err := s.syncBookmarks(pull, push); err != nil {
+		return err
+	}
+	if pull {
+		if err := s.writeBinderIndex(); err != nil {
+			return err
+		}
+		return s.writeTOC()
+	}
+	return nil
+}
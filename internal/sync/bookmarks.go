@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// BookmarksFileName is the index of Scrivener favorites kept at the markdown root.
+const BookmarksFileName = "_bookmarks.md"
+
+// bookmarkLinkRe matches list entries like "- [Title](path/to/file.md)".
+var bookmarkLinkRe = regexp.MustCompile(`(?m)^\s*[-*]\s+\[[^\]]*\]\(([^)]+)\)`)
+
+// syncBookmarks keeps the _bookmarks.md index and Scrivener favorites in
+// step. When push is set, linked files missing from the favorites are added
+// to Scrivener first; when pull is set, the index is regenerated from the
+// favorites.
+func (s *Syncer) syncBookmarks(pull, push bool) error {
+	// Only the scriv_path project's favorites are indexed
+	if !s.config.Options.SyncBookmarks || s.project != "" {
+		return nil
+	}
+
+	indexPath := filepath.Join(s.mdRoot, BookmarksFileName)
+
+	if push && s.config.Options.PushBookmarks {
+		added := 0
+		for _, mdPath := range s.readBookmarkLinks(indexPath) {
+			uuid := s.state.GetUUIDForPath(mdPath)
+			if uuid != "" && s.writer.AddFavorite(uuid) {
+				added++
+			}
+		}
+		if added > 0 {
+			if err := s.writer.Save(); err != nil {
+				return fmt.Errorf("failed to save Scrivener favorites: %w", err)
+			}
+			fmt.Printf("  Added %d favorite(s) to Scrivener\n", added)
+
+			reader, err := newReader(s.scrivPath, s.config.Options)
+			if err != nil {
+				return fmt.Errorf("failed to reload Scrivener project: %w", err)
+			}
+			s.reader = reader
+		}
+	}
+
+	if pull {
+		index, err := s.renderBookmarks()
+		if err != nil {
+			return err
+		}
+		existing, _ := os.ReadFile(indexPath)
+		if string(existing) != index {
+			if err := os.WriteFile(indexPath, []byte(index), 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", indexPath, err)
+			}
+			fmt.Printf("  Updated %s\n", indexPath)
+		}
+	}
+
+	return nil
+}
+
+// readBookmarkLinks returns the absolute markdown paths linked from the index.
+func (s *Syncer) readBookmarkLinks(indexPath string) []string {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, m := range bookmarkLinkRe.FindAllStringSubmatch(string(data), -1) {
+		link := strings.TrimSpace(m[1])
+		if strings.Contains(link, "://") {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.mdRoot, filepath.FromSlash(link)))
+	}
+	return paths
+}
+
+// renderBookmarks builds the _bookmarks.md index from Scrivener favorites,
+// linking each favorite to its synced markdown file where there is one.
+func (s *Syncer) renderBookmarks() (string, error) {
+	docs, err := s.reader.GetBinderStructure()
+	if err != nil {
+		return "", err
+	}
+	byUUID := make(map[string]*scrivener.Document)
+	var index func([]*scrivener.Document)
+	index = func(docs []*scrivener.Document) {
+		for _, doc := range docs {
+			byUUID[doc.UUID] = doc
+			index(doc.Children)
+		}
+	}
+	index(docs)
+
+	var b strings.Builder
+	b.WriteString("# Bookmarks\n\n")
+	b.WriteString("<!-- Generated by scriv-sync from Scrivener favorites. -->\n\n")
+
+	for _, uuid := range s.reader.GetFavorites() {
+		doc := byUUID[uuid]
+		if doc == nil {
+			continue
+		}
+		mdPath := s.state.GetPathForUUID(uuid)
+		if mdPath == "" {
+			fmt.Fprintf(&b, "- %s\n", doc.Title)
+			continue
+		}
+		rel, err := filepath.Rel(s.mdRoot, mdPath)
+		if err != nil {
+			rel = mdPath
+		}
+		fmt.Fprintf(&b, "- [%s](%s)\n", doc.Title, filepath.ToSlash(rel))
+	}
+
+	return b.String(), nil
+}
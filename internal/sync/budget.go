@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// BudgetWarning is a Scrivener folder a push takes outside its word budget.
+type BudgetWarning struct {
+	Folder string `json:"folder"`
+	Before int    `json:"words_before"`
+	After  int    `json:"words_after"`
+	Min    int    `json:"min,omitempty"`
+	Max    int    `json:"max,omitempty"`
+}
+
+// budgetFolder is a Scrivener folder with a word budget, and the documents
+// counted towards it.
+type budgetFolder struct {
+	path   string
+	budget config.WordBudget
+	words  map[string]int // UUID -> words, for every document in the folder's subtree
+}
+
+// checkBudgets lists in the plan the folders with a word budget that the
+// plan's pushes take outside it. Folders already outside their budget are
+// listed only if the push changes their count.
+func (s *Syncer) checkBudgets(plan *Plan) error {
+	budgets := s.config.Options.WordBudgets
+	if len(budgets) == 0 || len(plan.ToCreateInScriv)+len(plan.ToUpdateInScriv) == 0 {
+		return nil
+	}
+	docs, err := s.reader.GetBinderStructure()
+	if err != nil {
+		return err
+	}
+
+	var folders []*budgetFolder
+	var walk func(docs []*scrivener.Document, parent string, in []*budgetFolder)
+	walk = func(docs []*scrivener.Document, parent string, in []*budgetFolder) {
+		for _, doc := range docs {
+			if doc.IsTrash() {
+				continue
+			}
+			path := doc.Title
+			if parent != "" {
+				path = parent + "/" + doc.Title
+			}
+			within := in
+			if doc.IsFolder() {
+				for _, b := range budgets {
+					if config.MatchPath(b.Folder, path) {
+						f := &budgetFolder{path: path, budget: b, words: make(map[string]int)}
+						folders = append(folders, f)
+						within = append(within[:len(within):len(within)], f)
+						break
+					}
+				}
+			} else if len(within) > 0 {
+				words := 0
+				if err := s.reader.LoadContent(doc); err == nil {
+					words = countWords(budgetText(doc.Content))
+				}
+				for _, f := range within {
+					f.words[doc.UUID] = words
+				}
+			}
+			walk(doc.Children, path, within)
+		}
+	}
+	walk(docs, "", nil)
+
+	for _, f := range folders {
+		before := 0
+		for _, words := range f.words {
+			before += words
+		}
+		after := before
+		for _, fc := range plan.ToUpdateInScriv {
+			if old, ok := f.words[fc.ScrivUUID]; ok {
+				content, err := fc.content()
+				if err != nil {
+					return err
+				}
+				after += countWords(budgetText(content)) - old
+			}
+		}
+		for _, fc := range plan.ToCreateInScriv {
+			folder, ok := s.scrivenerFolderPath(fc.MarkdownPath)
+			if ok && (folder == f.path || strings.HasPrefix(folder, f.path+"/")) {
+				content, err := fc.content()
+				if err != nil {
+					return err
+				}
+				after += countWords(budgetText(content))
+			}
+		}
+
+		b := f.budget
+		if after != before && (after < b.Min || (b.Max > 0 && after > b.Max)) {
+			plan.BudgetWarnings = append(plan.BudgetWarnings, BudgetWarning{Folder: f.path, Before: before, After: after, Min: b.Min, Max: b.Max})
+		}
+	}
+	return nil
+}
+
+// budgetText returns markdown without its front matter, the text counted
+// against word budgets.
+func budgetText(content string) string {
+	_, body, _ := splitFrontMatter(content)
+	return body
+}
+
+// printBudgetWarnings lists the folders the plan takes outside their word
+// budgets.
+func (p *Plan) printBudgetWarnings() {
+	if len(p.BudgetWarnings) == 0 {
+		return
+	}
+	fmt.Println("\nWord budget warnings (after pushing):")
+	for _, w := range p.BudgetWarnings {
+		limit := fmt.Sprintf("under the minimum of %d", w.Min)
+		if w.Max > 0 && w.After > w.Max {
+			limit = fmt.Sprintf("over the maximum of %d", w.Max)
+		}
+		fmt.Printf("  ! %s: %d -> %d words, %s\n", w.Folder, w.Before, w.After, limit)
+	}
+}
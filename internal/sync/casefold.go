@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// caseSensitive reports whether titles match filenames only with the same
+// case (options.title_case: sensitive).
+func (s *Syncer) caseSensitive() bool {
+	return s.config.Options.TitleCase == config.TitleCaseSensitive
+}
+
+// titleKey returns the key documents are matched and grouped by: the title
+// itself when matching is case-sensitive, or lowercased.
+func (s *Syncer) titleKey(title string) string {
+	if s.caseSensitive() {
+		return title
+	}
+	return strings.ToLower(title)
+}
+
+// filenameFor converts a title to the base name of its markdown file or
+// directory, keeping its case when matching is case-sensitive and otherwise
+// lowercasing it by the rules of options.locale.
+func (s *Syncer) filenameFor(title string) string {
+	if s.caseSensitive() {
+		return sanitizeName(title, true)
+	}
+	return sanitizeName(s.lowerLocale(title), false)
+}
+
+// titleFor converts a markdown file or directory name back to a title,
+// title-cased by the rules of options.locale. When matching is
+// case-sensitive the name's case is kept as it is.
+func (s *Syncer) titleFor(filename string) string {
+	if !s.caseSensitive() {
+		return titleFromFilename(filename, s.titleCaser())
+	}
+	name := strings.TrimSuffix(filename, ".md")
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.Join(strings.Fields(strings.ReplaceAll(name, "-", " ")), " ")
+}
+
+// matchTitle returns the first document whose title matches, with or without
+// regard to case as options.title_case says.
+func (s *Syncer) matchTitle(docs []*scrivener.Document, title string) *scrivener.Document {
+	if !s.caseSensitive() {
+		return matchByTitle(docs, title)
+	}
+	for _, doc := range docs {
+		if !doc.IsFolder() && doc.Title == title {
+			return doc
+		}
+	}
+	return nil
+}
+
+// caseCollisions finds markdown files whose paths differ only in case, which
+// are one file on a case-insensitive filesystem (macOS and Windows) and so
+// can't be synced safely. It maps each such file to one it collides with.
+func caseCollisions(mdFiles []string) map[string]string {
+	byKey := make(map[string][]string)
+	for _, path := range mdFiles {
+		key := strings.ToLower(path)
+		byKey[key] = append(byKey[key], path)
+	}
+	collisions := make(map[string]string)
+	for _, group := range byKey {
+		for i, path := range group {
+			if len(group) > 1 {
+				collisions[path] = group[(i+1)%len(group)]
+			}
+		}
+	}
+	return collisions
+}
+
+// caseCollisionReason describes why a file colliding with other is skipped.
+func caseCollisionReason(other string) string {
+	return fmt.Sprintf("name differs only in case from %s", filepath.Base(other))
+}
@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// RunValidateConfig checks the global config file and each project's local
+// config file, printing their issues with line numbers. With an alias only
+// that project's settings and local file are checked. It returns an error
+// if any file has errors.
+func RunValidateConfig(alias string) error {
+	configPath, err := config.ConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		fmt.Printf("No config file at %s. Run 'scriv-sync init' to create one.\n", configPath)
+		return nil
+	}
+
+	issues, err := config.CheckFile(configPath)
+	if err != nil {
+		return err
+	}
+	var shown []config.Issue
+	for _, issue := range issues {
+		if alias == "" || issue.Project == "" || issue.Project == alias {
+			shown = append(shown, issue)
+		}
+	}
+	errs := printConfigIssues(configPath, shown)
+
+	// Local files are only found through a config that loads
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("%d error(s) in the config", errs)
+	}
+	aliases := globalCfg.ListProjects()
+	if alias != "" {
+		if _, err := globalCfg.GetProject(alias); err != nil {
+			return err
+		}
+		aliases = []string{alias}
+	}
+	sort.Strings(aliases)
+	for _, a := range aliases {
+		proj, err := globalCfg.GetProject(a)
+		if err != nil || proj == nil {
+			continue
+		}
+		if _, err := os.Stat(proj.LocalConfigPath()); err != nil {
+			continue
+		}
+		local, err := proj.CheckLocalFile()
+		if err != nil {
+			return err
+		}
+		errs += printConfigIssues(proj.LocalConfigPath(), local)
+	}
+
+	if errs > 0 {
+		return fmt.Errorf("%d error(s) in the config", errs)
+	}
+	return nil
+}
+
+// printConfigIssues prints the issues of a config file and returns the
+// number of errors.
+func printConfigIssues(path string, issues []config.Issue) int {
+	fmt.Printf("%s\n", path)
+	if len(issues) == 0 {
+		fmt.Println("  OK")
+		return 0
+	}
+
+	errs := 0
+	for _, issue := range issues {
+		if issue.Severity == config.IssueError {
+			errs++
+		}
+		fmt.Printf("  %s\n", issue)
+	}
+	fmt.Printf("  %d error(s), %d warning(s)\n", errs, len(issues)-errs)
+	return errs
+}
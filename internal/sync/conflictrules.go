@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// ruleResolution returns how the first conflict rule matching a conflict
+// resolves it, or false if no rule matches or the matching rule prompts.
+func (s *Syncer) ruleResolution(conflict Conflict) (string, bool) {
+	if len(s.config.Options.Conflicts) == 0 {
+		return "", false
+	}
+	facts := config.ConflictFacts{Path: conflict.MarkdownPath, Title: conflict.Title}
+	if rel, err := filepath.Rel(s.config.MarkdownPath(), conflict.MarkdownPath); err == nil {
+		facts.Path = filepath.ToSlash(rel)
+	}
+	if info, err := os.Stat(conflict.MarkdownPath); err == nil {
+		facts.MdModified = info.ModTime()
+	}
+
+	now := time.Now()
+	for i, rule := range s.config.Options.Conflicts {
+		if !rule.Matches(facts, now) {
+			continue
+		}
+		if rule.Resolve == "prompt" {
+			return "", false
+		}
+		fmt.Printf("  Conflict rule %d resolves %s: %s\n", i+1, facts.Path, rule.Resolve)
+		return rule.Resolve, true
+	}
+	return "", false
+}
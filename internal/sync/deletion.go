@@ -33,7 +33,7 @@ func promptDeletionAction(orphan Orphan, defaultAction string) DeletionAction {
 	}
 
 	if !orphan.LastSyncTime.IsZero() {
-		fmt.Printf("  Last synced: %s\n", orphan.LastSyncTime.Format("2006-01-02 15:04:05"))
+		fmt.Printf("  Last synced: %s\n", orphan.LastSyncTime.Local().Format("2006-01-02 15:04:05"))
 	}
 
 	fmt.Println()
@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// discoverMaxDepth limits how deep discovery descends below each root.
+const discoverMaxDepth = 6
+
+// DefaultDiscoveryRoots returns the common locations scanned for .scriv projects.
+func DefaultDiscoveryRoots() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, "Documents"),
+		filepath.Join(home, "Dropbox"),
+		filepath.Join(home, "Library", "CloudStorage", "Dropbox"),
+		filepath.Join(home, "Library", "Mobile Documents", "iCloud~com~literatureandlatte~scrivener3", "Documents"),
+	}
+}
+
+// RunDiscover scans the given roots (or the defaults) for Scrivener projects
+// and offers to initialize those not yet configured.
+func RunDiscover(roots []string, interactive bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+
+	if len(roots) == 0 {
+		roots = DefaultDiscoveryRoots()
+	}
+
+	configured := make(map[string]string) // scriv path -> alias
+	for _, a := range globalCfg.ListProjects() {
+		proj, _ := globalCfg.GetProject(a)
+		if p, err := proj.ScrivenerPath(); err == nil {
+			configured[filepath.Clean(p)] = a
+		}
+	}
+
+	fmt.Println("Scanning for Scrivener projects...")
+	var found []string
+	for _, root := range roots {
+		root = config.ExpandPath(root)
+		if !directoryExists(root) {
+			continue
+		}
+		fmt.Printf("  %s\n", root)
+		found = append(found, findScrivProjects(root, discoverMaxDepth)...)
+	}
+
+	if len(found) == 0 {
+		fmt.Println("\nNo Scrivener projects found.")
+		return nil
+	}
+
+	var unconfigured []string
+	fmt.Println("\nFound projects:")
+	for _, p := range found {
+		if a, ok := configured[filepath.Clean(p)]; ok {
+			fmt.Printf("  [configured as '%s'] %s\n", a, p)
+		} else {
+			fmt.Printf("  [new] %s\n", p)
+			unconfigured = append(unconfigured, p)
+		}
+	}
+
+	if len(unconfigured) == 0 {
+		fmt.Println("\nAll discovered projects are already configured.")
+		return nil
+	}
+
+	if !interactive {
+		fmt.Println("\nTo configure a project, run:")
+		for _, p := range unconfigured {
+			fmt.Printf("  scriv-sync init --local <path> --scriv %q --alias %s\n", p, suggestAlias(p))
+		}
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for _, p := range unconfigured {
+		fmt.Printf("\nInitialize %s? [y/N]: ", filepath.Base(p))
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return nil
+		}
+		if strings.TrimSpace(strings.ToLower(input)) != "y" {
+			continue
+		}
+
+		defaultAlias := suggestAlias(p)
+		fmt.Printf("  Alias [%s]: ", defaultAlias)
+		aliasInput, _ := reader.ReadString('\n')
+		projAlias := strings.TrimSpace(aliasInput)
+		if projAlias == "" {
+			projAlias = defaultAlias
+		}
+
+		fmt.Print("  Local markdown directory: ")
+		localInput, _ := reader.ReadString('\n')
+		local := strings.TrimSpace(localInput)
+		if local == "" {
+			fmt.Println("  No directory given, skipping.")
+			continue
+		}
+
+		if err := RunInit(projAlias, local, p, true, false); err != nil {
+			fmt.Printf("  Failed to initialize '%s': %v\n", projAlias, err)
+		}
+	}
+
+	return nil
+}
+
+// findScrivProjects walks root looking for .scriv packages, descending at
+// most maxDepth directories. Hidden directories are skipped.
+func findScrivProjects(root string, maxDepth int) []string {
+	var projects []string
+	rootDepth := strings.Count(filepath.Clean(root), string(filepath.Separator))
+
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than aborting the scan
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		name := info.Name()
+		if path != root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+			return filepath.SkipDir
+		}
+		if strings.HasSuffix(name, ".scriv") {
+			projects = append(projects, path)
+			return filepath.SkipDir
+		}
+		if strings.Count(path, string(filepath.Separator))-rootDepth >= maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	sort.Strings(projects)
+	return projects
+}
+
+// suggestAlias derives an alias from a .scriv project path.
+func suggestAlias(scrivPath string) string {
+	name := strings.TrimSuffix(filepath.Base(scrivPath), ".scriv")
+	return sanitizeFilename(name)
+}
@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// disambiguatedSuffixRe matches the numeric suffix added to duplicate filenames.
+var disambiguatedSuffixRe = regexp.MustCompile(`-\d+$`)
+
+// duplicateTitles groups documents by title key (see titleKey), returning
+// only titles shared by more than one document. Groups keep binder order.
+func (s *Syncer) duplicateTitles(docs []*scrivener.Document) map[string][]*scrivener.Document {
+	byTitle := make(map[string][]*scrivener.Document)
+	for _, doc := range docs {
+		if doc.IsFolder() {
+			continue
+		}
+		key := s.titleKey(doc.Title)
+		byTitle[key] = append(byTitle[key], doc)
+	}
+
+	for key, group := range byTitle {
+		if len(group) < 2 {
+			delete(byTitle, key)
+		}
+	}
+	return byTitle
+}
+
+// checkDuplicateTitles handles documents sharing a title in a Scrivener
+// folder as duplicate_titles says: error stops the sync; otherwise they sync
+// to numbered files, and unless disambiguate was chosen a warning is given
+// the first time each title is seen.
+func (s *Syncer) checkDuplicateTitles(folder string, docs []*scrivener.Document) error {
+	dups := s.duplicateTitles(docs)
+	if len(dups) == 0 {
+		return nil
+	}
+	switch s.config.Options.DuplicateTitles {
+	case "error":
+		return duplicateTitlesError(folder, dups)
+	case "disambiguate":
+		return nil
+	}
+	keys := make([]string, 0, len(dups))
+	for key := range dups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if s.state.WarnOnce("duplicate_titles:" + folder + "/" + key) {
+			s.rebaselined = true
+			fmt.Printf("Warning: %d documents in Scrivener folder '%s' are titled '%s'; they sync to numbered files. Rename them in Scrivener, or set options.duplicate_titles: error to stop syncs instead.\n",
+				len(dups[key]), folder, dups[key][0].Title)
+		}
+	}
+	return nil
+}
+
+// duplicateTitlesError describes duplicate titles found in a Scrivener folder.
+func duplicateTitlesError(folder string, dups map[string][]*scrivener.Document) error {
+	var parts []string
+	for _, group := range dups {
+		var uuids []string
+		for _, doc := range group {
+			uuids = append(uuids, doc.UUID)
+		}
+		parts = append(parts, fmt.Sprintf("'%s' (%s)", group[0].Title, strings.Join(uuids, ", ")))
+	}
+	sort.Strings(parts)
+	return fmt.Errorf("duplicate document titles in Scrivener folder '%s': %s. Rename them in Scrivener or set options.duplicate_titles: disambiguate",
+		folder, strings.Join(parts, "; "))
+}
+
+// assignMarkdownPaths returns the markdown path for each document in a
+// mapping, keyed by UUID. Documents keep a previously tracked path when it
+// still matches their title, so disambiguated files never swap contents.
+// With title_front_matter they keep it whatever their title. Remaining
+// duplicates get numbered suffixes (prologue.md, prologue-2.md) in binder
+// order. No two paths differ only in case, so none collide on a
+// case-insensitive filesystem.
+func (s *Syncer) assignMarkdownPaths(mdDir string, docs []*scrivener.Document) map[string]string {
+	paths := make(map[string]string)
+	used := make(map[string]bool)
+	ext := s.extensionFor(mdDir)
+
+	for _, doc := range docs {
+		if doc.IsFolder() {
+			continue
+		}
+		tracked := s.state.GetPathForUUID(doc.UUID)
+		if tracked == "" || used[strings.ToLower(tracked)] || filepath.Dir(tracked) != mdDir {
+			continue
+		}
+		base := strings.TrimSuffix(filepath.Base(tracked), ext)
+		if s.config.Options.TitleFrontMatter {
+			// The title lives in the front matter, so renames keep the file
+			paths[doc.UUID] = tracked
+			used[strings.ToLower(tracked)] = true
+			continue
+		}
+		if s.sameDocName(mdDir, disambiguatedSuffixRe.ReplaceAllString(base, ""), doc.Title) || s.sameDocName(mdDir, base, doc.Title) {
+			paths[doc.UUID] = tracked
+			used[strings.ToLower(tracked)] = true
+		}
+	}
+
+	for _, doc := range docs {
+		if doc.IsFolder() || paths[doc.UUID] != "" {
+			continue
+		}
+		base := s.docFilename(mdDir, doc.Title)
+		path := filepath.Join(mdDir, base+ext)
+		for n := 2; used[strings.ToLower(path)]; n++ {
+			path = filepath.Join(mdDir, fmt.Sprintf("%s-%d%s", base, n, ext))
+		}
+		paths[doc.UUID] = path
+		used[strings.ToLower(path)] = true
+	}
+
+	return paths
+}
+
+// matchByTitle returns the first document whose title matches (case-insensitive).
+func matchByTitle(docs []*scrivener.Document, title string) *scrivener.Document {
+	for _, doc := range docs {
+		if !doc.IsFolder() && strings.EqualFold(doc.Title, title) {
+			return doc
+		}
+	}
+	return nil
+}
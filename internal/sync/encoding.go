@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// textEncoding identifies how a markdown file is encoded on disk.
+type textEncoding string
+
+const (
+	encodingUTF8    textEncoding = "utf-8"
+	encodingUTF8BOM textEncoding = "utf-8-bom"
+	encodingUTF16LE textEncoding = "utf-16le"
+	encodingUTF16BE textEncoding = "utf-16be"
+	encodingCP1252  textEncoding = "windows-1252"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// cp1252 maps the Windows-1252 bytes 0x80-0x9F that differ from Latin-1.
+var cp1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+// decodeText converts file contents to UTF-8 and reports the encoding found.
+// BOMs identify UTF-8 and UTF-16; BOM-less UTF-16 is recognized by its zero
+// bytes, and data that is not valid UTF-8 is read as Windows-1252.
+func decodeText(data []byte) (string, textEncoding) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return string(data[len(bomUTF8):]), encodingUTF8BOM
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeUTF16(data[2:], binary.LittleEndian), encodingUTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeUTF16(data[2:], binary.BigEndian), encodingUTF16BE
+	}
+
+	if enc, ok := guessUTF16(data); ok {
+		if enc == encodingUTF16LE {
+			return decodeUTF16(data, binary.LittleEndian), enc
+		}
+		return decodeUTF16(data, binary.BigEndian), enc
+	}
+
+	if utf8.Valid(data) {
+		return string(data), encodingUTF8
+	}
+
+	var b strings.Builder
+	for _, c := range data {
+		if r, ok := cp1252[c]; ok {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String(), encodingCP1252
+}
+
+// guessUTF16 detects BOM-less UTF-16 text: mostly-ASCII UTF-16 has a zero
+// in every other byte.
+func guessUTF16(data []byte) (textEncoding, bool) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return "", false
+	}
+	var evenZeros, oddZeros int
+	for i, c := range data {
+		if c != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	half := len(data) / 2
+	switch {
+	case oddZeros > half*3/4 && evenZeros == 0:
+		return encodingUTF16LE, true
+	case evenZeros > half*3/4 && oddZeros == 0:
+		return encodingUTF16BE, true
+	}
+	return "", false
+}
+
+// decodeUTF16 decodes UTF-16 data in the given byte order.
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeText converts UTF-8 content to the given encoding. Content that
+// Windows-1252 cannot represent is written as UTF-8 instead.
+func encodeText(content string, enc textEncoding) []byte {
+	switch enc {
+	case encodingUTF8BOM:
+		return append(append([]byte{}, bomUTF8...), content...)
+	case encodingUTF16LE, encodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := bomUTF16LE
+		if enc == encodingUTF16BE {
+			order, bom = binary.BigEndian, bomUTF16BE
+		}
+		units := utf16.Encode([]rune(content))
+		out := make([]byte, len(bom)+len(units)*2)
+		copy(out, bom)
+		for i, u := range units {
+			order.PutUint16(out[len(bom)+i*2:], u)
+		}
+		return out
+	case encodingCP1252:
+		reverse := make(map[rune]byte, len(cp1252))
+		for b, r := range cp1252 {
+			reverse[r] = b
+		}
+		out := make([]byte, 0, len(content))
+		for _, r := range content {
+			if b, ok := reverse[r]; ok {
+				out = append(out, b)
+			} else if _, mapped := cp1252[byte(r)]; r < 0x100 && !mapped {
+				out = append(out, byte(r))
+			} else {
+				return []byte(content)
+			}
+		}
+		return out
+	default:
+		return []byte(content)
+	}
+}
+
+// readMarkdownFile reads a markdown file as UTF-8, remembering its original
+// encoding so it can be written back the same way. Transient errors are
+// retried.
+func (s *Syncer) readMarkdownFile(path string) (string, error) {
+	var data []byte
+	err := withRetry(func() (err error) {
+		data, err = os.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	content, enc := decodeText(data)
+	s.mdEncodings[path] = enc
+	s.mdWords[path] = countWords(content)
+	return content, nil
+}
+
+// writeMarkdownFile writes UTF-8 content to a markdown file in the encoding
+// it was read with, or as plain UTF-8 when normalize_encoding is set.
+// Transient errors are retried.
+func (s *Syncer) writeMarkdownFile(path, content string) error {
+	enc, ok := s.mdEncodings[path]
+	if !ok {
+		// Files not read during this run keep the encoding they have on disk
+		enc = encodingUTF8
+		if data, err := os.ReadFile(path); err == nil {
+			_, enc = decodeText(data)
+		}
+	}
+	if s.config.Options.NormalizeEncoding {
+		enc = encodingUTF8
+	}
+	data := encodeText(content, enc)
+	if err := withRetry(func() error { return os.WriteFile(path, data, 0644) }); err != nil {
+		return err
+	}
+	s.mdWords[path] = countWords(content)
+	return nil
+}
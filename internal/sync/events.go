@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// EventKind is the type of an event a sync emits while executing its plan.
+type EventKind string
+
+// Event kinds.
+const (
+	OperationStarted    EventKind = "operation_started"    // an operation of the plan is about to run
+	OperationCompleted  EventKind = "operation_completed"  // an operation has run
+	ConflictEncountered EventKind = "conflict_encountered" // a conflict is about to be resolved
+	OperationFailed     EventKind = "operation_failed"     // an operation failed; the rest of the plan still runs
+	EventError          EventKind = "error"                // the plan stopped on an error
+	EventInfo           EventKind = "info"                 // a notice, such as where the report was written
+	EventWarning        EventKind = "warning"              // something went wrong that doesn't fail the sync
+	SyncCompleted       EventKind = "sync_completed"       // the plan has run; carries its summary and failures
+)
+
+// Operation is what an event's operation does, named as in sync reports.
+type Operation string
+
+// Operations of a plan.
+const (
+	OpConflict          Operation = "conflict"
+	OpCreateInScrivener Operation = "create in Scrivener"
+	OpCreateInMarkdown  Operation = "create in markdown"
+	OpUpdateInScrivener Operation = "update in Scrivener"
+	OpUpdateInMarkdown  Operation = "update in markdown"
+	OpOrphan            Operation = "orphan"
+)
+
+// Event reports the progress of a plan being executed.
+type Event struct {
+	Kind      EventKind
+	Operation Operation // "" for EventError, SyncCompleted, and info and warnings about the whole sync
+	Path      string    // markdown path
+	Title     string
+	ScrivUUID string
+	Detail    string    // once completed: a conflict's resolution, an orphan's decision, or "metadata only" for a push that left the text alone
+	Message   string    // what the console shows for the event; "" shows nothing
+	Index     int       // position of the operation in the plan, from 1
+	Total     int       // operations in the plan
+	Err       error     // for OperationFailed and EventError
+	Summary   *Summary  // for SyncCompleted
+	Failures  []Failure // for SyncCompleted
+}
+
+// EventHandler receives the events of a sync. Events are delivered in
+// order, on the goroutine running the sync.
+type EventHandler interface {
+	HandleEvent(Event)
+}
+
+// EventFunc adapts a function to an EventHandler.
+type EventFunc func(Event)
+
+// HandleEvent calls f.
+func (f EventFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// ConsoleRenderer prints events as the CLI shows them: one line per event
+// that has a message, indented when it belongs to an operation, and the
+// outcome and summary once the plan has run.
+type ConsoleRenderer struct {
+	Out io.Writer // nil for standard output
+}
+
+// HandleEvent prints the event.
+func (r ConsoleRenderer) HandleEvent(e Event) {
+	out := r.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	if e.Kind == SyncCompleted {
+		printCompletion(out, e.Failures)
+		if e.Summary != nil {
+			e.Summary.Fprint(out)
+		}
+		return
+	}
+	if e.Message == "" {
+		return
+	}
+	message := e.Message
+	if e.Kind == EventWarning {
+		message = "Warning: " + message
+	}
+	if e.Operation == "" && (e.Kind == EventInfo || e.Kind == EventWarning) {
+		fmt.Fprintln(out, message)
+		return
+	}
+	fmt.Fprintf(out, "  %s\n", message)
+}
+
+// printCompletion prints how a plan finished, listing its failed
+// operations.
+func printCompletion(out io.Writer, failures []Failure) {
+	if len(failures) == 0 {
+		fmt.Fprintln(out, "\nSync completed successfully!")
+		return
+	}
+	fmt.Fprintf(out, "\nSync completed with %d failed operation(s):\n", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(out, "  x %s %s: %v\n", f.Operation, f.Path, f.Err)
+	}
+}
+
+// SetEventHandler sends the events of executing plans to h instead of
+// printing them; nil restores the console output.
+func (s *Syncer) SetEventHandler(h EventHandler) {
+	s.events = h
+	for _, linked := range s.linked {
+		linked.events = h
+	}
+}
+
+// emit delivers an event to the event handler, or prints it.
+func (s *Syncer) emit(e Event) {
+	if s.events == nil {
+		ConsoleRenderer{}.HandleEvent(e)
+		return
+	}
+	s.events.HandleEvent(e)
+}
+
+// operationCount returns the number of operations a plan executes.
+func (p *Plan) operationCount() int {
+	return len(p.Conflicts) + len(p.ToCreateInScriv) + len(p.ToCreateInMarkdown) +
+		len(p.ToUpdateInScriv) + len(p.ToUpdateInMarkdown) + len(p.Orphans)
+}
@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// mappingFor returns the mapping whose directory most closely contains path,
+// and false if none does.
+func (s *Syncer) mappingFor(path string) (config.FolderMapping, bool) {
+	var best config.FolderMapping
+	bestDir := ""
+	for _, mapping := range s.config.MappingsForProject(s.project) {
+		dir := s.config.MappingDir(mapping)
+		if config.IsWithin(path, dir) && len(dir) > len(bestDir) {
+			best, bestDir = mapping, dir
+		}
+	}
+	return best, bestDir != ""
+}
+
+// extensionFor returns the extension, with its dot, of the markdown files in
+// a directory: its mapping's, or .md outside any mapping.
+func (s *Syncer) extensionFor(dir string) string {
+	if mapping, ok := s.mappingFor(dir); ok {
+		return mapping.FileExtension()
+	}
+	return "." + config.ExtensionMD
+}
+
+// plainTextDir reports whether a directory's files hold plain text, which
+// syncs without markdown conversion or front matter.
+func (s *Syncer) plainTextDir(dir string) bool {
+	mapping, ok := s.mappingFor(dir)
+	return ok && mapping.PlainText()
+}
+
+// markPlainText records the documents syncing with the plain text files of
+// mdDir, whose content is then read and written as plain text.
+func (s *Syncer) markPlainText(mdDir string, docs []*scrivener.Document) {
+	if !s.plainTextDir(mdDir) {
+		return
+	}
+	if s.plainDocs == nil {
+		s.plainDocs = make(map[string]bool)
+	}
+	for _, doc := range docs {
+		s.plainDocs[doc.UUID] = true
+	}
+}
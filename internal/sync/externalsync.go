@@ -0,0 +1,214 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/rtf"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// ExternalSyncArchiveDir is where init moves the files of a Scrivener
+// External Folder Sync folder after importing them, relative to the
+// markdown root.
+const ExternalSyncArchiveDir = ".scriv-sync/external-sync"
+
+// externalSyncNameRe matches the file names Scrivener's External Folder Sync
+// writes: the title with a number prefix ("12 Chapter One.txt") or, in some
+// versions, a number suffix ("Chapter One (12).txt").
+var externalSyncNameRe = regexp.MustCompile(`^(?:(\d+)\s+(.+?)|(.+?)\s+\((\d+)\))\.(txt|rtf|md)$`)
+
+// externalFile is a document file of an External Folder Sync folder.
+type externalFile struct {
+	Path  string
+	Title string
+}
+
+// findExternalSync looks for the Draft folder Scrivener's External Folder
+// Sync writes at the top of localPath and returns its document files.
+func findExternalSync(localPath string) []externalFile {
+	entries, err := os.ReadDir(localPath)
+	if err != nil {
+		return nil
+	}
+	var files []externalFile
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.EqualFold(entry.Name(), "Draft") {
+			continue
+		}
+		dir := filepath.Join(localPath, entry.Name())
+		docs, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, doc := range docs {
+			m := externalSyncNameRe.FindStringSubmatch(doc.Name())
+			if doc.IsDir() || m == nil {
+				continue
+			}
+			title := m[2]
+			if title == "" {
+				title = m[3]
+			}
+			files = append(files, externalFile{Path: filepath.Join(dir, doc.Name()), Title: title})
+		}
+	}
+	return files
+}
+
+// offerExternalSyncImport asks whether to import an External Folder Sync
+// folder, which moves and rewrites its files. Without prompting they are
+// imported only if importFiles asks for it; otherwise, or if the user
+// declines, it prints how to import them later.
+func offerExternalSyncImport(in *bufio.Reader, files []externalFile, alias string, interactive, importFiles bool) bool {
+	fmt.Printf("\nFound %d file(s) from Scrivener's External Folder Sync in %s.\n", len(files), filepath.Dir(files[0].Path))
+	if importFiles {
+		fmt.Println("Importing them as the sync baseline.")
+		return true
+	}
+	if interactive && promptYesNo(in, "Import them as the sync baseline so the first sync doesn't duplicate them", true) {
+		return true
+	}
+	fmt.Printf("Left them as they are. To import them before the first sync, run: scriv-sync import-external %s\n", alias)
+	return false
+}
+
+// RunImportExternalSync imports the External Folder Sync files in a
+// project's markdown directory as its sync baseline, as init offers to.
+func RunImportExternalSync(alias string) error {
+	syncer, err := NewSyncerForAlias(alias)
+	if err != nil {
+		return fmt.Errorf("failed to open project for import: %w", err)
+	}
+	files := findExternalSync(syncer.mdRoot)
+	if len(files) == 0 {
+		fmt.Printf("No External Folder Sync files found in %s\n", syncer.mdRoot)
+		return nil
+	}
+	return syncer.reportExternalImport(files)
+}
+
+// reportExternalImport imports External Folder Sync files and prints how
+// many were.
+func (s *Syncer) reportExternalImport(files []externalFile) error {
+	imported, err := s.importExternalSync(files)
+	if err != nil {
+		return fmt.Errorf("failed to import External Folder Sync files: %w", err)
+	}
+	fmt.Printf("Imported %d of %d file(s); originals moved to %s\n",
+		imported, len(files), filepath.Join(s.mdRoot, filepath.FromSlash(ExternalSyncArchiveDir)))
+	return nil
+}
+
+// importExternalSync binds External Folder Sync files to the documents they
+// were exported from, matching titles among the documents of the enabled
+// mappings. Each matched file is written where the sync expects it, as
+// markdown, and its original is moved to the archive. Files identical to
+// their document are recorded as in sync; the others become conflicts on
+// the first sync, so nothing is overwritten unasked. It returns the number
+// of files imported.
+func (s *Syncer) importExternalSync(files []externalFile) (int, error) {
+	docs := make(map[string]*scrivener.Document) // title key -> document
+	dirs := make(map[string]string)              // UUID -> markdown directory
+	for _, mapping := range s.config.EnabledMappings() {
+		if mapping.IsRecursive() || mapping.Project != "" {
+			continue
+		}
+		folder, err := s.reader.FindFolderByPath(mapping.ScrivenerFolder)
+		if err != nil {
+			continue
+		}
+		mdDir := s.config.MappingDir(mapping)
+		s.setTitleRules(mdDir, mapping.Titles)
+		for _, doc := range excludeItems(mapping, folder.Children) {
+			key := s.titleKey(doc.Title)
+			if _, dup := docs[key]; dup {
+				docs[key] = nil // ambiguous: left for the sync to sort out
+				continue
+			}
+			if !doc.IsFolder() {
+				docs[key] = doc
+				dirs[doc.UUID] = mdDir
+			}
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	imported := 0
+	for _, file := range files {
+		doc := docs[s.titleKey(file.Title)]
+		if doc == nil || s.state.GetPathForUUID(doc.UUID) != "" {
+			fmt.Printf("  Not imported (no matching document): %s\n", file.Path)
+			continue
+		}
+		mdDir := dirs[doc.UUID]
+		target := filepath.Join(mdDir, s.docFilename(mdDir, doc.Title)+s.extensionFor(mdDir))
+		if target != file.Path && fileExists(target) {
+			fmt.Printf("  Not imported (%s already exists): %s\n", target, file.Path)
+			continue
+		}
+
+		content, err := s.readExternalFile(file.Path)
+		if err != nil {
+			return imported, err
+		}
+		if err := s.archiveExternalFile(file.Path); err != nil {
+			return imported, err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return imported, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return imported, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+
+		hash := ""
+		if s.contentHash(content) == s.docHash(doc) {
+			hash = s.docHash(doc)
+		}
+		s.state.RecordFile(target, doc.UUID, hash, time.Now())
+		imported++
+	}
+
+	if err := s.state.Save(); err != nil {
+		return imported, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return imported, nil
+}
+
+// readExternalFile reads an External Folder Sync file as markdown.
+func (s *Syncer) readExternalFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content, _ := decodeText(data)
+	if strings.EqualFold(filepath.Ext(path), ".rtf") {
+		content = rtf.RTFToMarkdownWithOptions(content, conversionOptions(s.config.Options))
+	}
+	return content, nil
+}
+
+// archiveExternalFile moves an imported file into the archive, keeping its
+// path under the markdown root.
+func (s *Syncer) archiveExternalFile(path string) error {
+	root := s.rootFor(path)
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	dest := filepath.Join(root, filepath.FromSlash(ExternalSyncArchiveDir), rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SetAssumeYes makes the first sync of a project run without asking for
+// confirmation, as --yes does.
+func (s *Syncer) SetAssumeYes(yes bool) {
+	s.assumeYes = yes
+	for _, linked := range s.linked {
+		linked.assumeYes = yes
+	}
+}
+
+// isFirstSync reports whether the project has never been synced.
+func (s *Syncer) isFirstSync() bool {
+	return s.state.LastSync == nil && len(s.state.Files) == 0
+}
+
+// confirmFirstSync lists every file a project's first sync would write and
+// asks before it runs, even in non-interactive mode, as the first run is
+// where a wrong mapping mass-creates or overwrites content. --yes skips the
+// question. Later syncs run as usual.
+func (s *Syncer) confirmFirstSync(plan *Plan) error {
+	if !s.isFirstSync() {
+		return nil
+	}
+	s.printFirstSyncReview(plan)
+	if s.assumeYes {
+		return nil
+	}
+
+	in := s.input
+	if in == nil {
+		in = os.Stdin
+	}
+	fmt.Print("\nThis is the first sync of this project. Proceed? [y/N]: ")
+	input, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.TrimSpace(strings.ToLower(input)) {
+	case "y", "yes":
+		return nil
+	}
+	fmt.Println()
+	return fmt.Errorf("first sync not confirmed: check the folder mappings, then rerun it with --yes or answer y")
+}
+
+// printFirstSyncReview prints every markdown file and Scrivener document a
+// plan writes, grouped by side, with their counts.
+func (s *Syncer) printFirstSyncReview(plan *Plan) {
+	fmt.Printf("\nFirst sync review for '%s'", s.alias)
+	if s.project != "" {
+		fmt.Printf(" (Scrivener project '%s')", s.project)
+	}
+	fmt.Println()
+	fmt.Println(strings.Repeat("=", 50))
+
+	fmt.Printf("\nMarkdown: %d to create, %d to overwrite\n", len(plan.ToCreateInMarkdown), len(plan.ToUpdateInMarkdown))
+	for _, fc := range plan.ToCreateInMarkdown {
+		fmt.Printf("  + %s  <- %s\n", fc.MarkdownPath, fc.Title)
+	}
+	for _, fc := range plan.ToUpdateInMarkdown {
+		fmt.Printf("  ~ %s  <- %s\n", fc.MarkdownPath, fc.Title)
+	}
+
+	fmt.Printf("\nScrivener: %d to create, %d to overwrite\n", len(plan.ToCreateInScriv), len(plan.ToUpdateInScriv))
+	for _, fc := range plan.ToCreateInScriv {
+		folder, _ := s.scrivenerFolderPath(fc.MarkdownPath)
+		fmt.Printf("  + %s  <- %s\n", joinBinderPath(folder, fc.Title), fc.MarkdownPath)
+	}
+	for _, fc := range plan.ToUpdateInScriv {
+		fmt.Printf("  ~ %s  <- %s\n", fc.Title, fc.MarkdownPath)
+	}
+
+	if len(plan.Conflicts) > 0 {
+		fmt.Printf("\nOn both sides, to be resolved: %d\n", len(plan.Conflicts))
+		for _, c := range plan.Conflicts {
+			fmt.Printf("  ! %s  <-> %s\n", c.MarkdownPath, c.Title)
+		}
+	}
+}
+
+// joinBinderPath appends a title to a folder's binder path.
+func joinBinderPath(folder, title string) string {
+	if folder == "" {
+		return title
+	}
+	return folder + "/" + title
+}
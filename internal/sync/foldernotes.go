@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// isFolderNote reports whether a markdown path is the folder note of its
+// directory, with folder_notes on.
+func (s *Syncer) isFolderNote(mdPath string) bool {
+	name := s.config.Options.FolderNotes
+	return name != "" && filepath.Base(mdPath) == name
+}
+
+// detectFolderNote compares a Scrivener folder's own text and synopsis with
+// the folder note of its markdown directory and adds the resulting
+// operation to the plan. A note is written only for a folder with text or a
+// synopsis, and a note without a folder waits until one is created for the
+// directory's documents. Deleting a note leaves the folder alone.
+func (s *Syncer) detectFolderNote(mdDir string, folder *scrivener.Document, plan *Plan) {
+	if s.config.Options.FolderNotes == "" || folder == nil || folder.IsTrash() || s.state.IgnoredUUID(folder.UUID) {
+		return
+	}
+	notePath := filepath.Join(mdDir, s.config.Options.FolderNotes)
+	scrivContent := s.docContent(folder)
+	scrivHash := s.contentHash(scrivContent)
+	hasText := strings.TrimSpace(folder.Content) != "" || folder.Synopsis != ""
+
+	if !fileExists(notePath) {
+		if isCloudPlaceholder(notePath) {
+			plan.skipPlaceholder(notePath)
+		} else if hasText {
+			plan.AddCreateInMarkdown(notePath, folder.UUID, folder.Title, scrivContent)
+			plan.ToCreateInMarkdown[len(plan.ToCreateInMarkdown)-1].Provenance = s.provenance(ReasonNewFile, notePath, "", folder, scrivHash)
+		}
+		return
+	}
+	if s.guardFile(notePath, true, plan) {
+		return
+	}
+	mdContent, err := s.readMarkdownFile(notePath)
+	if err != nil {
+		plan.Skipped = append(plan.Skipped, SkippedFile{Path: notePath, Reason: fmt.Sprintf("unreadable: %v", err)})
+		return
+	}
+	mdHash := s.contentHash(mdContent)
+
+	conflict := s.state.DetectConflict(notePath, mdHash, folder.UUID, scrivHash)
+	if conflict == ConflictNewFile {
+		switch {
+		case mdHash == scrivHash:
+			conflict = ConflictNone
+		case !hasText:
+			// A new note for a folder without text of its own
+			conflict = ConflictMarkdownOnly
+		}
+	}
+	if conflict != ConflictNone {
+		if by := s.lockedBy(mdContent, folder); by != "" {
+			change, location := lockedChange(conflict)
+			plan.refuseLocked(notePath, folder.UUID, folder.Title, change, location, by)
+			return
+		}
+	}
+
+	var why Provenance
+	if conflict != ConflictNone {
+		why = s.provenance(s.changeReason(notePath, conflict), notePath, mdHash, folder, scrivHash)
+	}
+	switch conflict {
+	case ConflictMarkdownOnly:
+		plan.AddUpdateInScriv(notePath, folder.UUID, folder.Title, mdContent)
+		plan.ToUpdateInScriv[len(plan.ToUpdateInScriv)-1].Provenance = why
+	case ConflictScrivenerOnly:
+		plan.AddUpdateInMarkdown(notePath, folder.UUID, folder.Title, scrivContent)
+		plan.ToUpdateInMarkdown[len(plan.ToUpdateInMarkdown)-1].Provenance = why
+	case ConflictNewFile, ConflictBoth:
+		plan.AddConflict(notePath, folder.UUID, folder.Title, mdContent, scrivContent)
+		plan.Conflicts[len(plan.Conflicts)-1].Provenance = why
+	case ConflictNone:
+		if fs := s.state.GetFileState(notePath); fs == nil || fs.ContentHash != mdHash {
+			s.state.RecordFile(notePath, folder.UUID, mdHash, time.Now())
+			s.rebaselined = true
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the YAML block at the top of a markdown file that carries
+// Scrivener metadata. Keys the sync doesn't manage are kept in Extra; they
+// stay in the markdown file but are not sent to Scrivener.
+type frontMatter struct {
+	Title       string         `yaml:"title,omitempty"`
+	SectionType string         `yaml:"section_type,omitempty"`
+	Icon        string         `yaml:"icon,omitempty"`
+	Label       string         `yaml:"label,omitempty"`
+	LabelColor  string         `yaml:"label_color,omitempty"`
+	Synopsis    string         `yaml:"synopsis,omitempty"`
+	Compile     *bool          `yaml:"compile,omitempty"`
+	Extra       map[string]any `yaml:",inline"`
+}
+
+// managedKeys selects the optional front matter keys the sync manages; the
+// section type is always managed.
+type managedKeys struct {
+	Decorations bool // icon, label and label_color, with decorations: front_matter
+	Title       bool // title, with title_front_matter
+	Synopsis    bool // synopsis, with folder_notes
+	Compile     bool // compile, with sync_compile
+}
+
+// managed returns the front matter without unmanaged keys.
+func (fm frontMatter) managed(keys managedKeys) frontMatter {
+	m := frontMatter{SectionType: fm.SectionType}
+	if keys.Decorations {
+		m.Icon, m.Label, m.LabelColor = fm.Icon, fm.Label, fm.LabelColor
+	}
+	if keys.Title {
+		m.Title = fm.Title
+	}
+	if keys.Synopsis {
+		m.Synopsis = fm.Synopsis
+	}
+	if keys.Compile {
+		m.Compile = fm.Compile
+	}
+	return m
+}
+
+// isEmpty reports whether the front matter has no keys.
+func (fm frontMatter) isEmpty() bool {
+	return fm.Title == "" && fm.SectionType == "" && fm.Icon == "" && fm.Label == "" && fm.LabelColor == "" && fm.Synopsis == "" && fm.Compile == nil && len(fm.Extra) == 0
+}
+
+// syncDisabled reports whether markdown opts out of syncing with
+// "scrivsync: false" or "sync: skip" in its front matter.
+func syncDisabled(content string) bool {
+	fm, _, ok := splitFrontMatter(strings.ReplaceAll(content, "\r\n", "\n"))
+	if !ok {
+		return false
+	}
+	return fm.Extra["scrivsync"] == false || fm.Extra["sync"] == "skip"
+}
+
+// splitFrontMatter separates a leading "---" delimited YAML block from the
+// markdown body. It reports false, returning content as the body, when there
+// is no well-formed front matter.
+func splitFrontMatter(content string) (frontMatter, string, bool) {
+	var fm frontMatter
+	if !strings.HasPrefix(content, "---\n") {
+		return fm, content, false
+	}
+	rest := content[len("---\n"):]
+
+	var block, body string
+	if strings.HasPrefix(rest, "---\n") || rest == "---" {
+		block, body = "", strings.TrimPrefix(rest, "---")
+	} else {
+		end := strings.Index(rest, "\n---\n")
+		if end < 0 {
+			if !strings.HasSuffix(rest, "\n---") {
+				return fm, content, false
+			}
+			end = len(rest) - len("\n---")
+		}
+		block, body = rest[:end], rest[end+len("\n---"):]
+	}
+
+	if err := yaml.Unmarshal([]byte(block), &fm); err != nil {
+		return frontMatter{}, content, false
+	}
+	body = strings.TrimPrefix(body, "\n")
+	body = strings.TrimPrefix(body, "\n") // the blank line after the block
+	return fm, body, true
+}
+
+// joinFrontMatter renders front matter above a markdown body. Empty front
+// matter leaves the body unchanged.
+func joinFrontMatter(fm frontMatter, body string) string {
+	if fm.isEmpty() {
+		return body
+	}
+	data, err := yaml.Marshal(fm)
+	if err != nil {
+		return body
+	}
+	return "---\n" + string(data) + "---\n\n" + body
+}
+
+// canonicalContent returns markdown as the sync compares it: front matter
+// reduced to the keys Scrivener stores, so unmanaged keys never register as
+// changes. Content without front matter is returned unchanged.
+func canonicalContent(content string, keys managedKeys) string {
+	fm, body, ok := splitFrontMatter(content)
+	if !ok {
+		return content
+	}
+	return joinFrontMatter(fm.managed(keys), body)
+}
+
+// contentHash returns the hash of markdown after normalization, with its
+// front matter reduced to the managed keys and its body in canonical form.
+// Both sides are hashed this way, so formatting lost in the round trip
+// through RTF never counts as a change.
+func (s *Syncer) contentHash(content string) string {
+	return computeHash(canonicalMarkdown(canonicalContent(s.normalize(content), s.managedKeys())))
+}
+
+// partHashes returns the hashes of the body and of the managed front matter
+// of markdown, each normalized as contentHash normalizes the whole.
+func (s *Syncer) partHashes(content string) (body, meta string) {
+	fm, text, _ := splitFrontMatter(s.normalize(content))
+	return computeHash(canonicalMarkdown(text)), computeHash(joinFrontMatter(fm.managed(s.managedKeys()), ""))
+}
+
+// pushMetadata updates only the metadata of a document whose markdown body
+// is unchanged since its last sync, leaving its text, and the formatting
+// Scrivener keeps in it, alone. It reports false, changing nothing, if the
+// body changed and the whole document must be pushed. Only pushes of files
+// changed in markdown alone use it: the document's text is then as synced.
+func (s *Syncer) pushMetadata(mdPath, uuid, content string) (bool, error) {
+	fs := s.state.GetFileState(mdPath)
+	if fs == nil || fs.BodyHash == "" || fs.ScrivUUID != uuid || s.plainDocs[uuid] {
+		return false, nil
+	}
+	if body, _ := s.partHashes(content); body != fs.BodyHash {
+		return false, nil
+	}
+	fm, _ := pushContent(content)
+	return true, s.applyFrontMatter(uuid, fm, "", false)
+}
+
+// decorated reports whether binder decorations are synced as front matter.
+func (s *Syncer) decorated() bool {
+	return s.config.Options.Decorations == config.DecorationsFrontMatter
+}
+
+// managedKeys returns the optional front matter keys the options enable.
+func (s *Syncer) managedKeys() managedKeys {
+	return managedKeys{
+		Decorations: s.decorated(),
+		Title:       s.config.Options.TitleFrontMatter,
+		Synopsis:    s.config.Options.FolderNotes != "",
+		Compile:     s.config.Options.SyncCompile,
+	}
+}
+
+// docContent returns a Scrivener document as normalized markdown, with its
+// metadata as front matter. Content deferred by the hash cache is read now.
+func (s *Syncer) docContent(doc *scrivener.Document) string {
+	if s.plainDocs[doc.UUID] {
+		text, err := s.reader.PlainText(doc.UUID)
+		if err != nil {
+			fmt.Printf("  Warning: %v\n", err)
+		}
+		return s.normalize(text)
+	}
+	if err := s.reader.LoadContent(doc); err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	}
+	return s.normalize(documentMarkdown(s.reader, doc, s.managedKeys()))
+}
+
+// documentMarkdown renders a Scrivener document as markdown with front
+// matter, including the optional keys selected by keys.
+func documentMarkdown(reader *scrivener.Reader, doc *scrivener.Document, keys managedKeys) string {
+	var fm frontMatter
+	if keys.Title {
+		fm.Title = doc.Title
+	}
+	if doc.SectionType != "" {
+		fm.SectionType = reader.SectionTypeTitle(doc.SectionType)
+	}
+	if keys.Decorations {
+		fm.Icon = doc.Icon
+		if label, ok := reader.Label(doc.LabelID); ok {
+			fm.Label, fm.LabelColor = label.Title, label.Color
+		}
+	}
+	if keys.Synopsis {
+		fm.Synopsis = doc.Synopsis
+	}
+	if keys.Compile && !doc.IsFolder() {
+		include := doc.IncludeInCompile
+		fm.Compile = &include
+	}
+	return joinFrontMatter(fm, doc.Content)
+}
+
+// docHash returns the hash of a Scrivener document as docContent renders it,
+// from the cache if its content was deferred.
+func (s *Syncer) docHash(doc *scrivener.Document) string {
+	if doc.Deferred {
+		if hash, ok := s.cachedScrivenerHash(doc); ok {
+			return hash
+		}
+	}
+	return s.contentHash(s.docContent(doc))
+}
+
+// pushContent splits markdown bound for Scrivener into the body to store as
+// the document text and the front matter to apply as metadata.
+func pushContent(content string) (frontMatter, string) {
+	fm, body, _ := splitFrontMatter(content)
+	return fm, body
+}
+
+// applyFrontMatter sets a document's metadata from front matter. With
+// title_front_matter, a title differing from the binder title renames the
+// document; the markdown file keeps its name. With sync_compile, a compile
+// key includes or excludes the document from compile; without one, the
+// setting is left as it is.
+func (s *Syncer) applyFrontMatter(uuid string, fm frontMatter, defaultSection string, created bool) error {
+	if s.config.Options.TitleFrontMatter && fm.Title != "" {
+		if err := s.writer.UpdateTitle(uuid, fm.Title); err != nil {
+			return err
+		}
+	}
+	if err := s.applySectionType(uuid, fm.SectionType, defaultSection, created); err != nil {
+		return err
+	}
+	if s.managedKeys().Synopsis && (fm.Synopsis != "" || !created) {
+		if err := s.writer.SetSynopsis(uuid, fm.Synopsis); err != nil {
+			return err
+		}
+	}
+	if s.config.Options.SyncCompile && fm.Compile != nil {
+		if err := s.writer.SetIncludeInCompile(uuid, *fm.Compile); err != nil {
+			return err
+		}
+	}
+	return s.applyDecorations(uuid, fm, created)
+}
+
+// applySectionType sets a document's section type by its title. Without
+// one, defaultSection (a title) is used for new documents, and an existing
+// document's section type is cleared only if it is one the markdown could
+// have shown.
+func (s *Syncer) applySectionType(uuid, title, defaultSection string, created bool) error {
+	if title == "" && created {
+		title = defaultSection
+	}
+	if title == "" {
+		current := s.writer.GetSectionType(uuid)
+		if created || current == "" || s.reader.SectionTypeTitle(current) == "" {
+			return nil
+		}
+		return s.writer.SetSectionType(uuid, "")
+	}
+
+	id, ok := s.reader.SectionTypeID(title)
+	if !ok {
+		fmt.Printf("  Warning: unknown section type '%s'; leaving it unchanged\n", title)
+		return nil
+	}
+	return s.writer.SetSectionType(uuid, id)
+}
+
+// applyDecorations sets a document's icon and label from front matter, with
+// decorations: front_matter. As with section types, a label missing from the
+// front matter is cleared only if it is one the markdown could have shown.
+// label_color is shown for reference and never applied.
+func (s *Syncer) applyDecorations(uuid string, fm frontMatter, created bool) error {
+	if !s.decorated() {
+		return nil
+	}
+	if fm.Icon != "" || !created {
+		if err := s.writer.SetIcon(uuid, fm.Icon); err != nil {
+			return err
+		}
+	}
+
+	if fm.Label == "" {
+		current := s.writer.GetLabel(uuid)
+		if _, known := s.reader.Label(current); created || current == "" || !known {
+			return nil
+		}
+		return s.writer.SetLabel(uuid, "")
+	}
+	id, ok := s.reader.LabelID(fm.Label)
+	if !ok {
+		fmt.Printf("  Warning: unknown label '%s'; leaving it unchanged (define it with 'scriv-sync labels add')\n", fm.Label)
+		return nil
+	}
+	return s.writer.SetLabel(uuid, id)
+}
+
+// withExistingFrontMatter adds the unmanaged front matter keys of the
+// markdown file at path to content pulled from Scrivener, so pulling never
+// drops keys the user added. Optional keys keys doesn't select are unmanaged
+// too.
+func withExistingFrontMatter(path, content string, keys managedKeys) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return content
+	}
+	text, _ := decodeText(data)
+	existing, _, ok := splitFrontMatter(text)
+	if !ok {
+		return content
+	}
+	fm, body, _ := splitFrontMatter(content)
+	fm.Extra = existing.Extra
+	if !keys.Decorations {
+		fm.Icon, fm.Label, fm.LabelColor = existing.Icon, existing.Label, existing.LabelColor
+	}
+	if !keys.Title {
+		fm.Title = existing.Title
+	}
+	if !keys.Synopsis {
+		fm.Synopsis = existing.Synopsis
+	}
+	if fm.isEmpty() {
+		return content
+	}
+	return joinFrontMatter(fm, body)
+}
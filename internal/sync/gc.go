@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// RunGC removes the Files/Data entries of each Scrivener project of an alias
+// whose UUIDs no longer appear in the binder. Documents deleted from the
+// binder outside of Scrivener leave their content behind; repeated
+// create/delete cycles otherwise bloat the project. Items in Trash are kept.
+func RunGC(alias string, dryRun, interactive bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range append([]string{""}, projCfg.ScrivProjectNames()...) {
+		scrivPath, err := projCfg.ScrivProjectPath(name)
+		if err != nil {
+			return err
+		}
+		if err := gcProject(scrivPath, dryRun, interactive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcProject lists and, unless dryRun, removes the unreferenced content of one
+// Scrivener project.
+func gcProject(scrivPath string, dryRun, interactive bool) error {
+	reader, err := scrivener.NewReader(scrivPath)
+	if err != nil {
+		return fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+	}
+	paths, err := reader.UnreferencedData()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", scrivPath)
+	if len(paths) == 0 {
+		fmt.Println("  Nothing to remove")
+		return nil
+	}
+
+	for _, path := range paths {
+		if dryRun {
+			fmt.Printf("  Would remove: %s\n", path)
+		} else {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would remove %d unreferenced item(s)\n", len(paths))
+		return nil
+	}
+
+	if interactive {
+		fmt.Printf("Remove %d unreferenced item(s)? [y/N]: ", len(paths))
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil || strings.TrimSpace(strings.ToLower(input)) != "y" {
+			fmt.Println("  Skipped")
+			return nil
+		}
+	}
+
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	fmt.Printf("Removed %d unreferenced item(s)\n", len(paths))
+	return nil
+}
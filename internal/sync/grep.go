@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// GrepLine is a line matching a search.
+type GrepLine struct {
+	Number int
+	Text   string
+}
+
+// GrepMatch is a document whose markdown, Scrivener content or both match a
+// search, with its sync status so a match on one side only can be told from
+// an edit not yet synced.
+type GrepMatch struct {
+	MarkdownPath   string
+	ScrivUUID      string
+	Side           string // "markdown", "scrivener" or "both"
+	Status         string // e.g. "in sync", "changed in markdown", "conflict"
+	MarkdownLines  []GrepLine
+	ScrivenerLines []GrepLine
+}
+
+// Grep searches the markdown files and the converted Scrivener documents of
+// every mapping for a regular expression, printing each document that
+// matches on either side. Each Scrivener project of the alias is searched in
+// turn.
+func (s *Syncer) Grep(pattern string, ignoreCase bool) error {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	return s.each(func(p *Syncer) error {
+		matches, err := p.grep(re)
+		if err != nil {
+			return err
+		}
+		p.printGrep(matches)
+		return nil
+	})
+}
+
+// grep returns the documents of a single Scrivener project matching re,
+// ordered by markdown path.
+func (s *Syncer) grep(re *regexp.Regexp) ([]GrepMatch, error) {
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer plan.Close()
+
+	uuids, status := s.docStatuses(plan)
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+	s.sortStrings(paths)
+
+	var matches []GrepMatch
+	for _, path := range paths {
+		m := GrepMatch{MarkdownPath: path, ScrivUUID: uuids[path], Status: status[path]}
+		if _, err := os.Stat(path); err == nil {
+			content, err := s.readMarkdownFile(path)
+			if err != nil {
+				return nil, err
+			}
+			_, body, _ := splitFrontMatter(strings.ReplaceAll(content, "\r\n", "\n"))
+			m.MarkdownLines = grepLines(re, body)
+		}
+		if m.ScrivUUID != "" && s.reader.HasDocument(m.ScrivUUID) {
+			doc, err := s.reader.GetDocumentByUUID(m.ScrivUUID)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.reader.LoadContent(doc); err != nil {
+				return nil, err
+			}
+			m.ScrivenerLines = grepLines(re, doc.Content)
+		}
+
+		switch {
+		case len(m.MarkdownLines) > 0 && len(m.ScrivenerLines) > 0:
+			m.Side = "both"
+		case len(m.MarkdownLines) > 0:
+			m.Side = "markdown"
+		case len(m.ScrivenerLines) > 0:
+			m.Side = "scrivener"
+		default:
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// docStatuses returns every document either side of a plan knows of, by
+// markdown path: its Scrivener UUID where it has one, and its sync status.
+func (s *Syncer) docStatuses(plan *Plan) (uuids, status map[string]string) {
+	uuids = make(map[string]string)
+	status = make(map[string]string)
+	for path, fs := range s.state.Files {
+		uuids[path] = fs.ScrivUUID
+		status[path] = "in sync"
+	}
+	for _, fc := range plan.ToCreateInScriv {
+		status[fc.MarkdownPath] = "new in markdown"
+	}
+	for _, fc := range plan.ToCreateInMarkdown {
+		uuids[fc.MarkdownPath] = fc.ScrivUUID
+		status[fc.MarkdownPath] = "new in Scrivener"
+	}
+	for _, fc := range plan.ToUpdateInScriv {
+		status[fc.MarkdownPath] = "changed in markdown"
+	}
+	for _, fc := range plan.ToUpdateInMarkdown {
+		status[fc.MarkdownPath] = "changed in Scrivener"
+	}
+	for _, c := range plan.Conflicts {
+		status[c.MarkdownPath] = "conflict"
+	}
+	for _, o := range plan.Orphans {
+		if o.Location == "markdown" {
+			status[o.Path] = "deleted in Scrivener"
+		} else {
+			status[o.Path] = "deleted in markdown"
+		}
+	}
+	for _, l := range plan.Locked {
+		status[l.MarkdownPath] = "locked, " + l.Change + " refused"
+	}
+	return uuids, status
+}
+
+// grepLines returns the lines of text matching re.
+func grepLines(re *regexp.Regexp, text string) []GrepLine {
+	var lines []GrepLine
+	for i, line := range strings.Split(text, "\n") {
+		if re.MatchString(line) {
+			lines = append(lines, GrepLine{Number: i + 1, Text: line})
+		}
+	}
+	return lines
+}
+
+// sameLines reports whether two sides matched the same text.
+func sameLines(a, b []GrepLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i].Text) != strings.TrimSpace(b[i].Text) {
+			return false
+		}
+	}
+	return true
+}
+
+// printGrep prints the matching documents and their lines. Lines both sides
+// share are printed once.
+func (s *Syncer) printGrep(matches []GrepMatch) {
+	if len(matches) == 0 {
+		fmt.Println("No matches.")
+		return
+	}
+	for _, m := range matches {
+		path := m.MarkdownPath
+		if rel, err := filepath.Rel(s.mdRoot, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+		fmt.Printf("%s (%s; %s)\n", path, m.Side, m.Status)
+		if sameLines(m.MarkdownLines, m.ScrivenerLines) {
+			printGrepLines("both", m.MarkdownLines)
+			continue
+		}
+		printGrepLines("markdown", m.MarkdownLines)
+		printGrepLines("scrivener", m.ScrivenerLines)
+	}
+	fmt.Printf("\n%d document(s) matched\n", len(matches))
+}
+
+// printGrepLines prints the lines one side matched.
+func printGrepLines(side string, lines []GrepLine) {
+	for _, line := range lines {
+		fmt.Printf("  %s:%d: %s\n", side, line.Number, strings.TrimSpace(line.Text))
+	}
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// defaultMaxFileSizeMB is the size limit of markdown files when
+// file_limits.max_size_mb is not set.
+const defaultMaxFileSizeMB = 10
+
+// sniffSize is how much of a markdown file is checked for binary content.
+const sniffSize = 8000
+
+// SkippedFile is a markdown file left out of the sync because it is over the
+// file limits or is a cloud placeholder.
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// overFileLimits returns why a markdown file is over the file limits, or ""
+// if it is within them. Its content is sniffed only if sniff is set, so
+// files unchanged since they were last checked aren't read again.
+func (s *Syncer) overFileLimits(path string, sniff bool) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	maxMB := s.config.Options.FileLimits.MaxSizeMB
+	if maxMB == 0 {
+		maxMB = defaultMaxFileSizeMB
+	}
+	if maxMB > 0 && info.Size() > int64(maxMB)<<20 {
+		return fmt.Sprintf("larger than %d MB (%.1f MB)", maxMB, float64(info.Size())/(1<<20))
+	}
+	if !sniff {
+		return ""
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, sniffSize)
+	n, _ := io.ReadFull(f, head)
+	if looksBinary(head[:n&^1]) {
+		return "not a text file"
+	}
+	return ""
+}
+
+// looksBinary reports whether data is binary rather than text in one of the
+// encodings markdown files are read in: it holds a NUL, or more than one in
+// ten of its characters are control characters other than whitespace.
+func looksBinary(data []byte) bool {
+	text, _ := decodeText(data)
+	if strings.ContainsRune(text, 0) {
+		return true
+	}
+	var chars, controls int
+	for _, r := range text {
+		chars++
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' || r == 0x7F {
+			controls++
+		}
+	}
+	return controls*10 > chars
+}
+
+// guardFile applies the file limits to a markdown file about to be synced,
+// reporting whether it should be left out. Files over the limits are listed
+// in the plan, or synced with a warning if file_limits.action is warn. Cloud
+// placeholders are always left out.
+func (s *Syncer) guardFile(path string, sniff bool, plan *Plan) bool {
+	if isCloudPlaceholder(path) {
+		// Never read, whatever the action: it would look emptied
+		plan.skipPlaceholder(path)
+		return true
+	}
+	reason := s.overFileLimits(path, sniff)
+	if reason == "" {
+		return false
+	}
+	if s.config.Options.FileLimits.Action == config.FileLimitWarn {
+		fmt.Printf("  Warning: %s is %s; syncing it anyway\n", path, reason)
+		return false
+	}
+	plan.Skipped = append(plan.Skipped, SkippedFile{Path: path, Reason: reason})
+	return true
+}
+
+// skipPlaceholder lists a markdown file not downloaded from the cloud as
+// skipped, with a warning to download it.
+func (p *Plan) skipPlaceholder(path string) {
+	fmt.Printf("  Warning: %s is not downloaded from the cloud; skipping it until it is\n", path)
+	p.Skipped = append(p.Skipped, SkippedFile{Path: path, Reason: "cloud placeholder, not downloaded"})
+}
+
+// printSkipped lists the markdown files left out for being over the file
+// limits or not downloaded.
+func (p *Plan) printSkipped() {
+	if len(p.Skipped) == 0 {
+		return
+	}
+	fmt.Println("\nSkipped files (not synced):")
+	for _, f := range p.Skipped {
+		fmt.Printf("  - %s (%s)\n", f.Path, f.Reason)
+	}
+}
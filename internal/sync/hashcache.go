@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+// scrivenerStamp identifies the state of a Scrivener document for the hash
+// cache: its content file time, its binder Modified attribute, the front
+// matter its metadata renders as, and the options that shape its markdown.
+// Any of these changing invalidates the cached hash.
+func (s *Syncer) scrivenerStamp(doc *scrivener.Document) string {
+	meta := *doc
+	meta.Content = ""
+	meta.Children = nil
+	return computeHash(strings.Join([]string{
+		doc.Modified.UTC().Format(time.RFC3339Nano),
+		timestamp.Canonical(doc.BinderModified),
+		documentMarkdown(s.reader, &meta, s.managedKeys()),
+		fmt.Sprintf("%+v", s.config.Options),
+		fmt.Sprint(s.plainDocs[doc.UUID]),
+	}, "\x00"))
+}
+
+// trackedPath returns the markdown path the state tracks for a Scrivener
+// UUID, or "" if it isn't tracked.
+func (s *Syncer) trackedPath(uuid string) string {
+	if s.uuidPaths == nil {
+		s.uuidPaths = make(map[string]string, len(s.state.Files))
+		for path, fs := range s.state.Files {
+			s.uuidPaths[fs.ScrivUUID] = path
+		}
+	}
+	return s.uuidPaths[uuid]
+}
+
+// cachedScrivenerHash returns the cached hash of a document whose stamp is
+// unchanged since it was recorded.
+func (s *Syncer) cachedScrivenerHash(doc *scrivener.Document) (string, bool) {
+	if s.fullScan {
+		return "", false
+	}
+	fs := s.state.GetFileState(s.trackedPath(doc.UUID))
+	if fs == nil || fs.ScrivHash == "" || fs.ScrivStamp != s.scrivenerStamp(doc) {
+		return "", false
+	}
+	return fs.ScrivHash, true
+}
+
+// skipUnchanged is the reader's SetSkipContent function: documents with a
+// valid cached hash aren't read or converted until their content is needed.
+func (s *Syncer) skipUnchanged(doc *scrivener.Document) bool {
+	_, ok := s.cachedScrivenerHash(doc)
+	return ok
+}
+
+// cacheScrivenerHash records the hash of a document read in full against
+// the markdown path it is synced to, for the next run.
+func (s *Syncer) cacheScrivenerHash(mdPath string, doc *scrivener.Document, hash string) {
+	if doc.Deferred || s.state.GetUUIDForPath(mdPath) != doc.UUID {
+		return
+	}
+	if s.state.RecordScrivenerHash(mdPath, s.scrivenerStamp(doc), hash) {
+		s.rebaselined = true
+	}
+}
+
+// markdownStamp identifies the state of a markdown file for the hash cache:
+// its size and modification time, and the options that shape its hash. It
+// returns "" if the file can't be examined.
+func (s *Syncer) markdownStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d:%s", info.Size(), info.ModTime().UnixNano(),
+		computeHash(fmt.Sprintf("%+v", s.config.Options))[:8])
+}
+
+// cachedMarkdown returns the cached state of a tracked markdown file whose
+// size and modification time are unchanged since it was recorded.
+func (s *Syncer) cachedMarkdown(path string) (*FileState, bool) {
+	if s.fullScan {
+		return nil, false
+	}
+	fs := s.state.GetFileState(path)
+	if fs == nil || fs.MdHash == "" || fs.MdStamp != s.markdownStamp(path) {
+		return nil, false
+	}
+	return fs, true
+}
+
+// cacheMarkdownHash records the hash and word count of a tracked markdown
+// file read in full, for the next run.
+func (s *Syncer) cacheMarkdownHash(path, hash string) {
+	words, ok := s.mdWords[path]
+	if !ok {
+		return
+	}
+	if s.state.RecordMarkdownHash(path, s.markdownStamp(path), hash, words) {
+		s.rebaselined = true
+	}
+}
+
+// SetFullScan makes the sync read and hash every file and document, instead
+// of trusting the hashes cached for those unchanged since the last sync.
+func (s *Syncer) SetFullScan(fullScan bool) {
+	s.fullScan = fullScan
+	for _, linked := range s.linked {
+		linked.fullScan = fullScan
+	}
+}
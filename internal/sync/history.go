@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HistoryFileName is the file under the markdown root recording who applied
+// each change, so collaborators syncing the same vault can see whose edit a
+// document last carried. It lives in the vault, not ~/.scriv-sync, to be
+// shared with it.
+const HistoryFileName = ".scriv-sync/history.jsonl"
+
+// historyAttributes makes git keep both sides' lines when collaborators'
+// histories are merged, rather than reporting a conflict.
+const historyAttributes = "history.jsonl merge=union\n"
+
+// HistoryEntry is a change applied by a sync, with who applied it.
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+	Operation Operation `json:"operation"`
+	Path      string    `json:"path"` // relative to the markdown root, with forward slashes
+	ScrivUUID string    `json:"scriv_uuid,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Author returns who made the change, as user@host.
+func (e HistoryEntry) Author() string {
+	if e.Host == "" {
+		return e.User
+	}
+	return e.User + "@" + e.Host
+}
+
+// currentAuthor returns the OS user name and host name of this machine.
+func currentAuthor() (string, string) {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if name == "" {
+		name = os.Getenv("USER")
+	}
+	if name == "" {
+		name = os.Getenv("USERNAME")
+	}
+	host, _ := os.Hostname()
+	return name, host
+}
+
+// historyPath returns the path of the history file.
+func (s *Syncer) historyPath() string {
+	return filepath.Join(s.mdRoot, filepath.FromSlash(HistoryFileName))
+}
+
+// historyKey returns how a markdown path is recorded in the history:
+// relative to the markdown root, so it's the same on every collaborator's
+// machine.
+func (s *Syncer) historyKey(mdPath string) string {
+	rel, err := filepath.Rel(s.mdRoot, mdPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(mdPath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// recordHistory adds a completed operation to the entries written at the
+// end of the sync.
+func (s *Syncer) recordHistory(op Operation, mdPath, title, uuid, detail string) {
+	user, host := currentAuthor()
+	s.history = append(s.history, HistoryEntry{
+		Time:      time.Now(),
+		User:      user,
+		Host:      host,
+		Operation: op,
+		Path:      s.historyKey(mdPath),
+		ScrivUUID: uuid,
+		Title:     title,
+		Detail:    detail,
+	})
+}
+
+// writeHistory appends the recorded entries to the history file.
+func (s *Syncer) writeHistory() error {
+	if len(s.history) == 0 {
+		return nil
+	}
+	path := s.historyPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	attributes := filepath.Join(filepath.Dir(path), ".gitattributes")
+	if _, err := os.Stat(attributes); os.IsNotExist(err) {
+		os.WriteFile(attributes, []byte(historyAttributes), 0644)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	for _, entry := range s.history {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history entry: %w", err)
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+	s.history = nil
+	return nil
+}
+
+// lastChanges returns the latest history entry of each markdown path, keyed
+// by the path as given to the plan, when more than one person has synced
+// the vault; with a single author there is no one else to point at, and it
+// returns nil. Lines that can't be read are skipped.
+func (s *Syncer) lastChanges() map[string]HistoryEntry {
+	f, err := os.Open(s.historyPath())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	latest := make(map[string]HistoryEntry)
+	authors := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Path == "" {
+			continue
+		}
+		authors[entry.Author()] = true
+		if prev, ok := latest[entry.Path]; !ok || !entry.Time.Before(prev.Time) {
+			latest[entry.Path] = entry
+		}
+	}
+	if len(authors) < 2 {
+		return nil
+	}
+
+	byPath := make(map[string]HistoryEntry, len(latest))
+	for key, entry := range latest {
+		path := filepath.FromSlash(key)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.mdRoot, path)
+		}
+		byPath[path] = entry
+	}
+	return byPath
+}
+
+// printAuthor shows who last changed a document through a sync, when the
+// vault has several authors.
+func (p *Plan) printAuthor(mdPath string) {
+	entry, ok := p.authors[mdPath]
+	if !ok {
+		return
+	}
+	fmt.Printf("      last changed by %s on %s (%s)\n", entry.Author(), entry.Time.Local().Format("2006-01-02 15:04"), entry.Operation)
+}
@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Defaults of the impact gate when impact_gate leaves them unset.
+const (
+	defaultImpactMaxFiles = 100
+	defaultImpactMaxMB    = 1
+)
+
+// Impact is an estimate of what running a plan writes on each side.
+// Scrivener bytes count the markdown pushed, which its RTF somewhat exceeds.
+type Impact struct {
+	MarkdownFiles  int
+	MarkdownBytes  int
+	MarkdownWords  int
+	ScrivenerFiles int // content files, and the .scrivx when the binder changes
+	ScrivenerBytes int
+	ScrivenerWords int
+	Conflicts      int // may write either side once resolved
+	Orphans        int // may delete on either side once decided
+}
+
+// Files returns the number of files written on both sides.
+func (i Impact) Files() int {
+	return i.MarkdownFiles + i.ScrivenerFiles
+}
+
+// Bytes returns the size of the content written on both sides.
+func (i Impact) Bytes() int {
+	return i.MarkdownBytes + i.ScrivenerBytes
+}
+
+// estimateImpact estimates what running a plan writes.
+func estimateImpact(plan *Plan) (Impact, error) {
+	var impact Impact
+	add := func(changes []FileChange, files, bytes, words *int) error {
+		for _, fc := range changes {
+			content, err := fc.content()
+			if err != nil {
+				return err
+			}
+			*files++
+			*bytes += len(content)
+			*words += countWords(content)
+		}
+		return nil
+	}
+	if err := add(plan.ToCreateInMarkdown, &impact.MarkdownFiles, &impact.MarkdownBytes, &impact.MarkdownWords); err != nil {
+		return impact, err
+	}
+	if err := add(plan.ToUpdateInMarkdown, &impact.MarkdownFiles, &impact.MarkdownBytes, &impact.MarkdownWords); err != nil {
+		return impact, err
+	}
+	if err := add(plan.ToCreateInScriv, &impact.ScrivenerFiles, &impact.ScrivenerBytes, &impact.ScrivenerWords); err != nil {
+		return impact, err
+	}
+	if err := add(plan.ToUpdateInScriv, &impact.ScrivenerFiles, &impact.ScrivenerBytes, &impact.ScrivenerWords); err != nil {
+		return impact, err
+	}
+	impact.Conflicts = len(plan.Conflicts)
+	impact.Orphans = len(plan.Orphans)
+
+	// New documents and deletions change the binder
+	if len(plan.ToCreateInScriv) > 0 || impact.Orphans > 0 {
+		impact.ScrivenerFiles++
+	}
+	return impact, nil
+}
+
+// impactLimits returns the files and bytes a plan may write before it is
+// confirmed; -1 is unlimited.
+func (s *Syncer) impactLimits() (files, bytes int) {
+	g := s.config.Options.ImpactGate
+	files, mb := g.MaxFiles, g.MaxMB
+	if files == 0 {
+		files = defaultImpactMaxFiles
+	}
+	if mb == 0 {
+		mb = defaultImpactMaxMB
+	}
+	bytes = -1
+	if mb > 0 {
+		bytes = mb << 20
+	}
+	return files, bytes
+}
+
+// confirmImpact prints the estimated impact of a plan and, if it writes
+// more than impact_gate allows, asks before it runs, even in non-interactive
+// mode. --yes skips the question, as does a first sync, whose review asked
+// already.
+func (s *Syncer) confirmImpact(plan *Plan) error {
+	impact, err := estimateImpact(plan)
+	if err != nil {
+		return err
+	}
+	s.printImpact(impact)
+
+	maxFiles, maxBytes := s.impactLimits()
+	var over []string
+	if maxFiles >= 0 && impact.Files() > maxFiles {
+		over = append(over, fmt.Sprintf("%d files", maxFiles))
+	}
+	if maxBytes >= 0 && impact.Bytes() > maxBytes {
+		over = append(over, formatSize(maxBytes))
+	}
+	// A first sync has been confirmed file by file already
+	if len(over) == 0 || s.assumeYes || s.isFirstSync() {
+		return nil
+	}
+
+	in := s.input
+	if in == nil {
+		in = os.Stdin
+	}
+	fmt.Printf("\nThis writes more than %s (impact_gate). Proceed? [y/N]: ", strings.Join(over, " or "))
+	input, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.TrimSpace(strings.ToLower(input)) {
+	case "y", "yes":
+		return nil
+	}
+	fmt.Println()
+	return fmt.Errorf("large plan not confirmed: check it with --dry-run, then rerun with --yes or answer y")
+}
+
+// printImpact prints the estimated impact of a plan.
+func (s *Syncer) printImpact(impact Impact) {
+	fmt.Println("\nEstimated impact:")
+	fmt.Printf("  Markdown:  %d file(s), %s, %d words written\n", impact.MarkdownFiles, formatSize(impact.MarkdownBytes), impact.MarkdownWords)
+	fmt.Printf("  Scrivener: %d file(s) touched, about %s, %d words written\n", impact.ScrivenerFiles, formatSize(impact.ScrivenerBytes), impact.ScrivenerWords)
+	if impact.Conflicts > 0 || impact.Orphans > 0 {
+		fmt.Printf("  Pending:   %d conflict(s) and %d deletion(s) may write more once decided\n", impact.Conflicts, impact.Orphans)
+	}
+	fmt.Printf("  Backup:    %s\n", s.backupNote())
+}
+
+// backupNote describes what is kept of the content a sync removes.
+func (s *Syncer) backupNote() string {
+	var markdown string
+	switch s.config.Options.DeletionStyle {
+	case "hard":
+		markdown = "none for deleted markdown files (deletion_style: hard)"
+	case "trash":
+		markdown = "deleted markdown files go to the system trash"
+	default:
+		markdown = "deleted markdown files are archived in " + ArchiveDirName
+	}
+	return markdown + "; deleted Scrivener documents go to its Trash"
+}
+
+// formatSize formats a number of bytes, e.g. "512 B", "12.4 KB" or "3.1 MB".
+func formatSize(n int) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+}
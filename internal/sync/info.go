@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// binderCounts are the statistics info prints for a binder.
+type binderCounts struct {
+	Folders   int
+	Documents int
+	Words     int
+	Trashed   int // items in Trash, not counted above
+}
+
+// RunInfo prints the metadata of each Scrivener project of an alias: its
+// identifier, the Scrivener version that saved it, its data format, binder
+// statistics and its label and status vocabularies.
+func RunInfo(alias string) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+
+	for i, name := range append([]string{""}, projCfg.ScrivProjectNames()...) {
+		scrivPath, err := projCfg.ScrivProjectPath(name)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := printProjectInfo(scrivPath, projCfg.Options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printProjectInfo prints the metadata of one Scrivener project.
+func printProjectInfo(scrivPath string, opts config.Options) error {
+	reader, err := newReader(scrivPath, opts)
+	if err != nil {
+		return err
+	}
+	docs, err := reader.GetBinderStructure()
+	if err != nil {
+		return err
+	}
+	counts := countBinder(docs)
+
+	fmt.Println(scrivPath)
+	fmt.Printf("  Identifier:  %s\n", orNone(reader.Identifier()))
+	fmt.Printf("  Creator:     %s\n", orNone(scrivener.DescribeCreator(reader.Creator())))
+	fmt.Printf("  Format:      %s (.scrivx version %s)\n", reader.Format(), orNone(reader.Version()))
+	fmt.Printf("  Binder:      %d folder(s), %d document(s), %d word(s)", counts.Folders, counts.Documents, counts.Words)
+	if counts.Trashed > 0 {
+		fmt.Printf("; %d item(s) in Trash", counts.Trashed)
+	}
+	fmt.Println()
+
+	var labels, statuses []string
+	for _, l := range reader.Labels() {
+		labels = append(labels, l.Title)
+	}
+	for _, s := range reader.Statuses() {
+		statuses = append(statuses, s.Title)
+	}
+	fmt.Printf("  Labels:      %s\n", orNone(strings.Join(labels, ", ")))
+	fmt.Printf("  Statuses:    %s\n", orNone(strings.Join(statuses, ", ")))
+	return nil
+}
+
+// countBinder counts the folders, documents and words of a binder tree,
+// leaving what is in Trash out of all but Trashed.
+func countBinder(docs []*scrivener.Document) binderCounts {
+	var c binderCounts
+	var walk func(docs []*scrivener.Document, trashed bool)
+	walk = func(docs []*scrivener.Document, trashed bool) {
+		for _, doc := range docs {
+			inTrash := trashed || doc.IsTrash()
+			switch {
+			case doc.IsTrash():
+			case inTrash:
+				c.Trashed++
+			case doc.IsFolder():
+				c.Folders++
+			default:
+				c.Documents++
+			}
+			if !inTrash {
+				c.Words += countWords(doc.Content)
+			}
+			walk(doc.Children, inTrash)
+		}
+	}
+	walk(docs, false)
+	return c
+}
+
+// orNone returns s, or "(none)" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
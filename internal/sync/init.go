@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/sweiss/harcroft/internal/config"
 	"github.com/sweiss/harcroft/internal/scrivener"
+	"golang.org/x/text/cases"
 )
 
-// RunInit runs the initialization process for a new project.
-func RunInit(alias, localPath, scrivPath string, interactive bool) error {
+// RunInit runs the initialization process for a new project. External
+// Folder Sync files in the local path are imported if importExternal is
+// set or the user agrees.
+func RunInit(alias, localPath, scrivPath string, interactive, importExternal bool) error {
 	// 1. Load global config
 	globalCfg, err := config.LoadGlobal()
 	if err != nil {
@@ -25,12 +31,12 @@ func RunInit(alias, localPath, scrivPath string, interactive bool) error {
 	}
 
 	// 3. Validate paths
-	localPath, err = filepath.Abs(localPath)
+	localPath, err = filepath.Abs(config.ExpandPath(localPath))
 	if err != nil {
 		return fmt.Errorf("failed to resolve local path: %w", err)
 	}
 
-	scrivPath, err = filepath.Abs(scrivPath)
+	scrivPath, err = filepath.Abs(config.ExpandPath(scrivPath))
 	if err != nil {
 		return fmt.Errorf("failed to resolve scriv path: %w", err)
 	}
@@ -70,11 +76,16 @@ func RunInit(alias, localPath, scrivPath string, interactive bool) error {
 	}
 
 	// 7. Suggest mappings
-	mappings := suggestMappings(folders, localDirs)
+	suggestions := suggestMappings(folders, localDirs, localPath)
 
 	// 8. Interactive selection
-	if interactive && len(mappings) > 0 {
-		mappings = interactiveMappingSelection(mappings, localPath)
+	in := bufio.NewReader(os.Stdin)
+	if interactive && len(suggestions) > 0 {
+		suggestions = interactiveMappingSelection(in, suggestions, localPath)
+	}
+	mappings := make([]config.FolderMapping, len(suggestions))
+	for i, sg := range suggestions {
+		mappings[i] = sg.Mapping
 	}
 
 	// 9. Add project to global config
@@ -82,6 +93,9 @@ func RunInit(alias, localPath, scrivPath string, interactive bool) error {
 	for _, m := range mappings {
 		proj.AddMapping(m.MarkdownDir, m.ScrivenerFolder, m.SyncEnabled)
 	}
+	if interactive {
+		interactiveOptions(in, &proj.Options)
+	}
 
 	// 10. Save global config
 	if err := globalCfg.Save(); err != nil {
@@ -91,47 +105,169 @@ func RunInit(alias, localPath, scrivPath string, interactive bool) error {
 	enabledCount := len(proj.EnabledMappings())
 	configPath, _ := config.ConfigPath()
 	fmt.Printf("\nProject '%s' added to %s with %d folder mapping(s).\n", alias, configPath, enabledCount)
+
+	// 11. Adopt an existing Scrivener External Folder Sync folder
+	if files := findExternalSync(localPath); len(files) > 0 && offerExternalSyncImport(in, files, alias, interactive, importExternal) {
+		syncer, err := NewSyncerForAlias(alias)
+		if err != nil {
+			return fmt.Errorf("failed to open project for import: %w", err)
+		}
+		if err := syncer.reportExternalImport(files); err != nil {
+			return err
+		}
+	}
 	fmt.Printf("\nTo sync, run: scriv-sync sync %s\n", alias)
 
 	return nil
 }
 
-// suggestMappings creates suggested folder mappings based on name matching.
-func suggestMappings(scrivFolders []*scrivener.Document, localDirs []string) []config.FolderMapping {
-	var mappings []config.FolderMapping
+// Confidence thresholds of mapping suggestions: below minSuggestConfidence
+// a folder and directory aren't paired; from enableSuggestConfidence the
+// pairing is switched on.
+const (
+	minSuggestConfidence    = 0.3
+	enableSuggestConfidence = 0.5
+)
+
+// mappingSuggestion is a folder mapping proposed at init, with how sure the
+// suggestion is (0 to 1) and why.
+type mappingSuggestion struct {
+	Mapping    config.FolderMapping
+	Confidence float64
+	Reason     string // e.g. "same name" or "9 of 11 titles match"
+}
 
-	// Create a map of lowercase local dir names for matching
-	localDirMap := make(map[string]string)
-	for _, dir := range localDirs {
-		localDirMap[strings.ToLower(dir)] = dir
+// suggestMappings suggests a mapping for each Scrivener folder. A folder is
+// paired with the local directory of the same or a similar name ("drafts"
+// for Draft), or whose markdown files are named after the folder's
+// documents ("people" for Characters), the surest pairings first. Folders
+// left unpaired are suggested a new directory, switched off.
+func suggestMappings(scrivFolders []*scrivener.Document, localDirs []string, localPath string) []mappingSuggestion {
+	type pairing struct {
+		folder, dir int
+		confidence  float64
+		reason      string
+	}
+	files := make([]map[string]bool, len(localDirs))
+	for j, dir := range localDirs {
+		files[j] = markdownNames(filepath.Join(localPath, dir))
+	}
+	var pairings []pairing
+	for i, folder := range scrivFolders {
+		titles := make(map[string]bool)
+		collectTitles(folder.Children, titles)
+		for j, dir := range localDirs {
+			confidence, reason := nameSimilarity(folder.Title, dir)
+			if c, r := contentSimilarity(titles, files[j]); c > confidence {
+				confidence, reason = c, r
+			}
+			if confidence >= minSuggestConfidence {
+				pairings = append(pairings, pairing{i, j, confidence, reason})
+			}
+		}
 	}
+	sort.SliceStable(pairings, func(a, b int) bool { return pairings[a].confidence > pairings[b].confidence })
 
-	for _, folder := range scrivFolders {
-		lowerTitle := strings.ToLower(folder.Title)
-		mapping := config.FolderMapping{
-			ScrivenerFolder: folder.Title,
-			SyncEnabled:     false,
+	suggestions := make([]mappingSuggestion, len(scrivFolders))
+	for i, folder := range scrivFolders {
+		// No match - suggest creating directory
+		suggestions[i].Mapping = config.FolderMapping{ScrivenerFolder: folder.Title, MarkdownDir: strings.ToLower(folder.Title)}
+	}
+	paired := make(map[int]bool)
+	usedDirs := make(map[int]bool)
+	for _, p := range pairings {
+		if paired[p.folder] || usedDirs[p.dir] {
+			continue
 		}
+		paired[p.folder], usedDirs[p.dir] = true, true
+		suggestions[p.folder] = mappingSuggestion{
+			Mapping: config.FolderMapping{
+				ScrivenerFolder: scrivFolders[p.folder].Title,
+				MarkdownDir:     localDirs[p.dir],
+				SyncEnabled:     p.confidence >= enableSuggestConfidence,
+			},
+			Confidence: p.confidence,
+			Reason:     p.reason,
+		}
+	}
+	return suggestions
+}
 
-		// Check for exact case-insensitive match
-		if localDir, exists := localDirMap[lowerTitle]; exists {
-			mapping.MarkdownDir = localDir
-			mapping.SyncEnabled = true
-		} else {
-			// No match - suggest creating directory
-			mapping.MarkdownDir = strings.ToLower(folder.Title)
+// nameSimilarity scores how alike a folder's title and a directory's name
+// are: 1 for the same name, 0.9 for the same name but for a plural "s" or
+// punctuation ("drafts", "Draft"), and 0 otherwise.
+func nameSimilarity(title, dir string) (float64, string) {
+	if strings.EqualFold(title, dir) {
+		return 1, "same name"
+	}
+	simplify := func(name string) string {
+		name = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, name)
+		return strings.TrimSuffix(name, "s")
+	}
+	if a := simplify(title); a != "" && a == simplify(dir) {
+		return 0.9, "similar name"
+	}
+	return 0, ""
+}
+
+// contentSimilarity scores how many of a folder's document titles name the
+// markdown files of a directory, both as filenames, by their Dice
+// coefficient: 1 when each title has a file and each file a title.
+func contentSimilarity(titles, files map[string]bool) (float64, string) {
+	if len(titles) == 0 || len(files) == 0 {
+		return 0, ""
+	}
+	matched := 0
+	for name := range titles {
+		if files[name] {
+			matched++
 		}
+	}
+	if matched == 0 {
+		return 0, ""
+	}
+	return 2 * float64(matched) / float64(len(titles)+len(files)),
+		fmt.Sprintf("%d of %d titles match", matched, len(titles))
+}
 
-		mappings = append(mappings, mapping)
+// collectTitles adds the titles of documents and folders, and of their
+// children, to titles as filenames.
+func collectTitles(docs []*scrivener.Document, titles map[string]bool) {
+	for _, doc := range docs {
+		titles[sanitizeFilename(doc.Title)] = true
+		collectTitles(doc.Children, titles)
 	}
+}
 
-	return mappings
+// markdownNames returns the lowercased names, without extensions, of the
+// markdown files and subdirectories under dir, skipping hidden ones.
+func markdownNames(dir string) map[string]bool {
+	names := make(map[string]bool)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || isDocumentFile(path) {
+			names[strings.ToLower(strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())))] = true
+		}
+		return nil
+	})
+	return names
 }
 
 // interactiveMappingSelection allows user to toggle mappings.
-func interactiveMappingSelection(mappings []config.FolderMapping, localPath string) []config.FolderMapping {
-	reader := bufio.NewReader(os.Stdin)
-
+func interactiveMappingSelection(reader *bufio.Reader, mappings []mappingSuggestion, localPath string) []mappingSuggestion {
 	fmt.Println("\nSuggested mappings:")
 	printMappings(mappings, localPath)
 
@@ -155,7 +291,8 @@ func interactiveMappingSelection(mappings []config.FolderMapping, localPath stri
 			return mappings
 		case "c":
 			// Create missing directories for enabled mappings
-			for _, m := range mappings {
+			for _, sg := range mappings {
+				m := sg.Mapping
 				dirPath := filepath.Join(localPath, m.MarkdownDir)
 				if m.SyncEnabled && !directoryExists(dirPath) {
 					if err := os.MkdirAll(dirPath, 0755); err != nil {
@@ -174,7 +311,7 @@ func interactiveMappingSelection(mappings []config.FolderMapping, localPath stri
 			var num int
 			if _, err := fmt.Sscanf(input, "%d", &num); err == nil {
 				if num >= 1 && num <= len(mappings) {
-					mappings[num-1].SyncEnabled = !mappings[num-1].SyncEnabled
+					mappings[num-1].Mapping.SyncEnabled = !mappings[num-1].Mapping.SyncEnabled
 					printMappings(mappings, localPath)
 				} else {
 					fmt.Printf("Invalid number. Enter 1-%d.\n", len(mappings))
@@ -186,9 +323,81 @@ func interactiveMappingSelection(mappings []config.FolderMapping, localPath stri
 	}
 }
 
-// printMappings displays the current mapping selections.
-func printMappings(mappings []config.FolderMapping, localPath string) {
-	for i, m := range mappings {
+// interactiveOptions asks for the project's sync defaults, starting from
+// opts. Pressing enter keeps the value shown in brackets.
+func interactiveOptions(reader *bufio.Reader, opts *config.Options) {
+	fmt.Println("\nSync options (press enter to keep the default):")
+
+	opts.DefaultConflictResolution = promptChoice(reader,
+		"When a file changed on both sides", []string{"prompt", "markdown", "scrivener", "skip"}, opts.DefaultConflictResolution)
+	opts.DefaultDeletionAction = promptChoice(reader,
+		"When a file was deleted on one side", []string{"prompt", "delete", "recreate", "skip"}, opts.DefaultDeletionAction)
+	if opts.DefaultDeletionAction != "recreate" && opts.DefaultDeletionAction != "skip" {
+		opts.DeletionStyle = promptChoice(reader,
+			"Deleted markdown files go to", []string{"archive-dir", "trash", "hard"}, opts.DeletionStyle)
+	}
+	opts.CreateMissingFolders = promptYesNo(reader,
+		"Create missing Scrivener folders", opts.CreateMissingFolders)
+
+	decorations := opts.Decorations
+	if decorations == "" {
+		decorations = "none"
+	}
+	decorations = promptChoice(reader,
+		"Show binder icons and labels", []string{"none", config.DecorationsFrontMatter, config.DecorationsIndex}, decorations)
+	opts.Decorations = ""
+	if decorations != "none" {
+		opts.Decorations = decorations
+	}
+}
+
+// promptChoice asks for one of choices until it gets one, returning current
+// on an empty answer or when input ends.
+func promptChoice(reader *bufio.Reader, question string, choices []string, current string) string {
+	for {
+		fmt.Printf("  %s (%s) [%s]: ", question, strings.Join(choices, "/"), current)
+		input, err := reader.ReadString('\n')
+		answer := strings.TrimSpace(strings.ToLower(input))
+		if answer == "" {
+			if err != nil {
+				fmt.Println()
+			}
+			return current
+		}
+		for _, choice := range choices {
+			if answer == choice {
+				return choice
+			}
+		}
+		fmt.Printf("  Enter one of: %s\n", strings.Join(choices, ", "))
+		if err != nil {
+			return current
+		}
+	}
+}
+
+// promptYesNo asks a yes/no question, returning current on an empty answer.
+func promptYesNo(reader *bufio.Reader, question string, current bool) bool {
+	hint := "y/N"
+	if current {
+		hint = "Y/n"
+	}
+	fmt.Printf("  %s? [%s]: ", question, hint)
+	input, _ := reader.ReadString('\n')
+	switch strings.TrimSpace(strings.ToLower(input)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return current
+}
+
+// printMappings displays the current mapping selections, with the
+// confidence of each suggested pairing.
+func printMappings(mappings []mappingSuggestion, localPath string) {
+	for i, sg := range mappings {
+		m := sg.Mapping
 		checkmark := " "
 		if m.SyncEnabled {
 			checkmark = "x"
@@ -200,7 +409,11 @@ func printMappings(mappings []config.FolderMapping, localPath string) {
 			dirStatus = fmt.Sprintf("(create) %s", m.MarkdownDir)
 		}
 
-		fmt.Printf("  [%s] %d. %s  <->  %s\n", checkmark, i+1, dirStatus, m.ScrivenerFolder)
+		confidence := ""
+		if sg.Reason != "" {
+			confidence = fmt.Sprintf("  (%.0f%%: %s)", sg.Confidence*100, sg.Reason)
+		}
+		fmt.Printf("  [%s] %d. %s  <->  %s%s\n", checkmark, i+1, dirStatus, m.ScrivenerFolder, confidence)
 	}
 }
 
@@ -251,10 +464,18 @@ func fileExists(path string) bool {
 	return !info.IsDir()
 }
 
-// sanitizeFilename converts a title to a safe filename.
+// sanitizeFilename converts a title to a safe, lowercase filename.
 func sanitizeFilename(title string) string {
-	// Convert to lowercase
-	name := strings.ToLower(title)
+	return sanitizeName(title, false)
+}
+
+// sanitizeName converts a title to a safe filename, lowercased unless
+// keepCase is set.
+func sanitizeName(title string, keepCase bool) string {
+	name := title
+	if !keepCase {
+		name = strings.ToLower(title)
+	}
 
 	// Replace spaces and special characters
 	replacer := strings.NewReplacer(
@@ -282,8 +503,9 @@ func sanitizeFilename(title string) string {
 	return name
 }
 
-// titleFromFilename converts a filename back to a title.
-func titleFromFilename(filename string) string {
+// titleFromFilename converts a filename back to a title, title-casing each
+// word with caser, or without one by upper-casing its first letter.
+func titleFromFilename(filename string, caser *cases.Caser) string {
 	// Remove .md extension
 	name := strings.TrimSuffix(filename, ".md")
 	name = strings.TrimSuffix(name, filepath.Ext(name))
@@ -294,9 +516,12 @@ func titleFromFilename(filename string) string {
 	// Title case
 	words := strings.Fields(name)
 	for i, word := range words {
-		if len(word) > 0 {
-			words[i] = strings.ToUpper(string(word[0])) + strings.ToLower(word[1:])
+		if caser != nil {
+			words[i] = caser.String(word)
+			continue
 		}
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(first)) + strings.ToLower(word[size:])
 	}
 
 	return strings.Join(words, " ")
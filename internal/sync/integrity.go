@@ -0,0 +1,37 @@
+package sync
+
+import "fmt"
+
+// SetForce lets a run write into a Scrivener project whose binder has
+// integrity problems, as --force does.
+func (s *Syncer) SetForce(force bool) {
+	s.writer.SetForce(force)
+	for _, linked := range s.linked {
+		linked.writer.SetForce(force)
+	}
+}
+
+// warnIntegrity prints the binder problems found when the Scrivener project
+// was opened.
+func (s *Syncer) warnIntegrity() {
+	problems := s.reader.Problems()
+	if len(problems) == 0 {
+		return
+	}
+	fmt.Printf("Warning: %s has integrity problems; documents sharing a UUID sync with its first binder item outside the Trash:\n", s.scrivPath)
+	for _, issue := range problems {
+		fmt.Printf("  %s\n", issue)
+	}
+}
+
+// checkIntegrity refuses a plan that writes to a Scrivener project with
+// integrity problems, unless the run was forced.
+func (s *Syncer) checkIntegrity(plan *Plan) error {
+	if len(plan.ToCreateInScriv)+len(plan.ToUpdateInScriv)+len(plan.Conflicts)+len(plan.Orphans) == 0 {
+		return nil
+	}
+	if err := s.writer.CheckIntegrity(); err != nil {
+		return fmt.Errorf("%w\nFix the project in Scrivener (run 'scriv-sync verify %s' for details), or pass --force to write anyway", err, s.alias)
+	}
+	return nil
+}
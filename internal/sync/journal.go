@@ -0,0 +1,208 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/vault"
+)
+
+// Journal records the decisions and completed operations of a sync while it
+// runs. It is removed when the sync finishes; if the sync is interrupted, a
+// rerun with --resume reuses its decisions instead of prompting again and
+// treats the operations it already carried out as done.
+type Journal struct {
+	StartedAt time.Time                 `json:"started_at"`
+	Conflicts map[string]ConflictChoice `json:"conflicts"` // by markdown path
+	Orphans   map[string]DeletionAction `json:"orphans"`   // by markdown path, or UUID for Scrivener orphans
+	Completed map[string]string         `json:"completed"` // markdown path -> hash of the content synced
+
+	path    string
+	hash    func(string) string // the Syncer's content hash
+	encrypt bool                // save seals the file; see encrypt_state
+}
+
+// ConflictChoice is a conflict resolution recorded in the journal. It only
+// applies while both sides still have the content it was made for.
+type ConflictChoice struct {
+	Resolution    string `json:"resolution"`
+	MarkdownHash  string `json:"markdown_hash"`
+	ScrivenerHash string `json:"scrivener_hash"`
+}
+
+// newJournal creates an empty journal for a state name, comparing content
+// with the given hash.
+func newJournal(name string, hash func(string) string) (*Journal, error) {
+	path, err := config.JournalPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{
+		StartedAt: time.Now(),
+		Conflicts: make(map[string]ConflictChoice),
+		Orphans:   make(map[string]DeletionAction),
+		Completed: make(map[string]string),
+		path:      path,
+		hash:      hash,
+	}, nil
+}
+
+// loadJournal reads the journal left by an interrupted sync. It returns nil
+// if there is none.
+func loadJournal(name string, hash func(string) string) (*Journal, error) {
+	j, err := newJournal(name, hash)
+	if err != nil {
+		return nil, err
+	}
+	data, err := vault.Read(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync journal: %w", err)
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("failed to parse sync journal: %w", err)
+	}
+	if j.Conflicts == nil {
+		j.Conflicts = make(map[string]ConflictChoice)
+	}
+	if j.Orphans == nil {
+		j.Orphans = make(map[string]DeletionAction)
+	}
+	if j.Completed == nil {
+		j.Completed = make(map[string]string)
+	}
+	return j, nil
+}
+
+// save writes the journal, so progress survives an interruption.
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync journal: %w", err)
+	}
+	if data, err = vault.Encode(data, j.encrypt); err != nil {
+		return fmt.Errorf("failed to encrypt sync journal: %w", err)
+	}
+	if err := os.WriteFile(j.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write sync journal: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the journal once the sync has finished.
+func (j *Journal) remove() {
+	os.Remove(j.path)
+}
+
+// conflictChoice returns the recorded resolution of a conflict, if it was
+// made for the conflict's current content.
+func (j *Journal) conflictChoice(c Conflict) (string, bool) {
+	choice, ok := j.Conflicts[c.MarkdownPath]
+	if !ok {
+		return "", false
+	}
+	md, err := c.markdownContent()
+	if err != nil {
+		return "", false
+	}
+	scriv, err := c.scrivenerContent()
+	if err != nil {
+		return "", false
+	}
+	if choice.MarkdownHash != j.hash(md) || choice.ScrivenerHash != j.hash(scriv) {
+		return "", false
+	}
+	return choice.Resolution, true
+}
+
+// recordConflict records how a conflict was resolved.
+func (j *Journal) recordConflict(c Conflict, resolution string) error {
+	md, err := c.markdownContent()
+	if err != nil {
+		return err
+	}
+	scriv, err := c.scrivenerContent()
+	if err != nil {
+		return err
+	}
+	j.Conflicts[c.MarkdownPath] = ConflictChoice{
+		Resolution:    resolution,
+		MarkdownHash:  j.hash(md),
+		ScrivenerHash: j.hash(scriv),
+	}
+	return j.save()
+}
+
+// recordOrphan records the action chosen for an orphan.
+func (j *Journal) recordOrphan(key string, action DeletionAction) error {
+	j.Orphans[key] = action
+	return j.save()
+}
+
+// recordCompleted records an operation that synced a file.
+func (j *Journal) recordCompleted(mdPath, hash string) error {
+	j.Completed[mdPath] = hash
+	return j.save()
+}
+
+// SetResume makes Sync, Pull and Push continue a sync that was interrupted,
+// using the journal it left behind.
+func (s *Syncer) SetResume(resume bool) {
+	s.resume = resume
+	for _, linked := range s.linked {
+		linked.resume = resume
+	}
+}
+
+// startJournal begins the journal for a run. When resuming, the journal of
+// the interrupted run is continued and operations it completed are dropped
+// from the plan; otherwise a leftover journal is reported and replaced.
+func (s *Syncer) startJournal(plan *Plan) error {
+	previous, err := loadJournal(s.stateName(), s.contentHash)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && s.resume {
+		fmt.Printf("Resuming the sync started %s.\n", previous.StartedAt.Format("2006-01-02 15:04"))
+		s.journal = previous
+		s.journal.encrypt = s.config.Options.EncryptState
+		s.dropCompleted(plan)
+		return nil
+	}
+	if previous != nil {
+		fmt.Printf("Note: a sync started %s was interrupted; its decisions are discarded (use --resume to continue it).\n",
+			previous.StartedAt.Format("2006-01-02 15:04"))
+	}
+
+	s.journal, err = newJournal(s.stateName(), s.contentHash)
+	if err != nil {
+		return err
+	}
+	s.journal.encrypt = s.config.Options.EncryptState
+	return s.journal.save()
+}
+
+// dropCompleted removes the conflicts an interrupted run already settled:
+// both sides hold the content the run synced, so only the state record is
+// missing, and it is added here.
+func (s *Syncer) dropCompleted(plan *Plan) {
+	var remaining []Conflict
+	for _, c := range plan.Conflicts {
+		md, mdErr := c.markdownContent()
+		scriv, scrivErr := c.scrivenerContent()
+		hash, done := s.journal.Completed[c.MarkdownPath]
+		if done && mdErr == nil && scrivErr == nil && s.contentHash(md) == hash && s.contentHash(scriv) == hash {
+			s.recordSync(c.MarkdownPath, c.ScrivUUID, md)
+			fmt.Printf("  Already synced: %s\n", c.MarkdownPath)
+			continue
+		}
+		remaining = append(remaining, c)
+	}
+	plan.Conflicts = remaining
+}
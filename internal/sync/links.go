@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// markdownLinkRe matches inline links like "[text](target)" or
+// "[text](<target> "title")", images included.
+var markdownLinkRe = regexp.MustCompile(`\[[^\]]*\]\(\s*(<[^>]*>|[^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+
+// linkSchemeRe matches the scheme of a link to something other than a file,
+// e.g. "https:" or "mailto:".
+var linkSchemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// Statuses of cross-project link targets besides the sync statuses of
+// docStatuses.
+const (
+	linkMissing   = "missing"
+	linkUntracked = "not synced"
+)
+
+// CrossLink is a link in a markdown document of one project to a file in the
+// markdown of another.
+type CrossLink struct {
+	Source string // markdown file holding the link
+	Line   int
+	Target string // file the link points to
+	Alias  string // project the target belongs to
+	Status string // the target document's sync status, "missing" or "not synced"
+}
+
+// Broken reports whether the link's target doesn't exist.
+func (l CrossLink) Broken() bool {
+	return l.Status == linkMissing
+}
+
+// OK reports whether the link's target exists and, if it's a document, is
+// in sync with Scrivener.
+func (l CrossLink) OK() bool {
+	return l.Status == "" || l.Status == "in sync"
+}
+
+// linkProject is a configured project as seen by the links report.
+type linkProject struct {
+	alias  string
+	config *config.ProjectConfig
+	status map[string]string // sync status by markdown path, once loaded
+}
+
+// RunLinks reports the links in the markdown of a project, or of every
+// configured project when alias is empty, that point into another project's
+// markdown, such as a novel's links into its worldbuilding wiki. Each target
+// is checked to exist and, if it's a document, to be in sync with its
+// Scrivener project. It returns an error if any link is broken.
+func RunLinks(alias string) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	if alias != "" {
+		if _, err := globalCfg.GetProject(alias); err != nil {
+			return err
+		}
+	}
+
+	var projects []*linkProject
+	for _, a := range globalCfg.ListProjects() {
+		proj, _ := globalCfg.GetProject(a)
+		merged, err := proj.WithLocalOverrides()
+		if err != nil {
+			if a == alias {
+				return err
+			}
+			fmt.Printf("Warning: skipping project '%s': %v\n", a, err)
+			continue
+		}
+		projects = append(projects, &linkProject{alias: a, config: merged})
+	}
+
+	broken := 0
+	for _, source := range projects {
+		if alias != "" && source.alias != alias {
+			continue
+		}
+		links, err := crossLinks(source, projects)
+		if err != nil {
+			return fmt.Errorf("project '%s': %w", source.alias, err)
+		}
+		printCrossLinks(source, links, projects)
+		for _, link := range links {
+			if link.Broken() {
+				broken++
+			}
+		}
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d broken cross-project link(s)", broken)
+	}
+	return nil
+}
+
+// loadStatus loads the sync status of the documents of every Scrivener
+// project of p.
+func (p *linkProject) loadStatus() error {
+	if p.status != nil {
+		return nil
+	}
+	s, err := NewSyncer(p.config, p.alias)
+	if err != nil {
+		return err
+	}
+	p.status = make(map[string]string)
+	return s.each(func(s *Syncer) error {
+		plan, err := s.detectAllChanges()
+		if err != nil {
+			return err
+		}
+		defer plan.Close()
+		_, status := s.docStatuses(plan)
+		for path, st := range status {
+			p.status[path] = st
+		}
+		return nil
+	})
+}
+
+// owner returns the project whose markdown holds path, the one with the
+// closest markdown root if several do, or nil if none does.
+func owner(projects []*linkProject, path string) *linkProject {
+	var best *linkProject
+	bestRoot := ""
+	for _, p := range projects {
+		for _, root := range p.config.MarkdownRoots() {
+			if config.IsWithin(path, root) && len(root) > len(bestRoot) {
+				best, bestRoot = p, root
+			}
+		}
+	}
+	return best
+}
+
+// crossLinks returns the links from the documents of source into the
+// markdown of the other projects, ordered by source file and line.
+func crossLinks(source *linkProject, projects []*linkProject) ([]CrossLink, error) {
+	if err := source.loadStatus(); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(source.status))
+	for path := range source.status {
+		paths = append(paths, path)
+	}
+	sortLocale(source.config.Options.Locale, paths)
+
+	var links []CrossLink
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // deleted in markdown
+		}
+		for _, link := range fileLinks(path, string(data)) {
+			target := owner(projects, link.Target)
+			if target == nil || target == source {
+				continue
+			}
+			link.Alias = target.alias
+			if err := target.loadStatus(); err != nil {
+				return nil, fmt.Errorf("project '%s': %w", target.alias, err)
+			}
+			link.Status = linkStatus(target, link.Target)
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// linkStatus returns the status of the target of a link into a project:
+// missing if it doesn't exist, the sync status of a document, "not synced"
+// for a document no mapping syncs, or "" for a file that isn't a document,
+// such as an image.
+func linkStatus(p *linkProject, target string) string {
+	info, err := os.Stat(target)
+	if err != nil {
+		return linkMissing
+	}
+	if status, ok := p.status[target]; ok {
+		return status
+	}
+	if !info.IsDir() && isDocumentFile(target) {
+		return linkUntracked
+	}
+	return ""
+}
+
+// isDocumentFile reports whether a file has one of the extensions a mapping's
+// documents may have.
+func isDocumentFile(path string) bool {
+	switch strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".") {
+	case config.ExtensionMD, config.ExtensionMarkdown, config.ExtensionMDX, config.ExtensionText:
+		return true
+	}
+	return false
+}
+
+// fileLinks returns the links to local files in the markdown of the file at
+// path, with their targets resolved against its directory. Links in fenced
+// code blocks, to URLs and to anchors in the same file are left out.
+func fileLinks(path, content string) []CrossLink {
+	var links []CrossLink
+	inFence := false
+	for i, line := range strings.Split(content, "\n") {
+		if fenceRe.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		for _, m := range markdownLinkRe.FindAllStringSubmatch(line, -1) {
+			target := strings.TrimSuffix(strings.TrimPrefix(m[1], "<"), ">")
+			if cut := strings.IndexAny(target, "#?"); cut >= 0 {
+				target = target[:cut]
+			}
+			if target == "" || linkSchemeRe.MatchString(target) {
+				continue
+			}
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				target = unescaped
+			}
+			target = filepath.FromSlash(target)
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			links = append(links, CrossLink{Source: path, Line: i + 1, Target: filepath.Clean(target)})
+		}
+	}
+	return links
+}
+
+// printCrossLinks prints the cross-project links of a project, marking those
+// whose target is missing or not in sync.
+func printCrossLinks(source *linkProject, links []CrossLink, projects []*linkProject) {
+	fmt.Printf("%s\n", source.alias)
+	if len(links) == 0 {
+		fmt.Println("  No links to other projects")
+		return
+	}
+	problems := 0
+	for _, link := range links {
+		from := relToProject(source, link.Source)
+		to := link.Target
+		if target := owner(projects, link.Target); target != nil {
+			to = relToProject(target, link.Target)
+		}
+		mark := " "
+		note := ""
+		switch {
+		case link.Broken():
+			mark, note = "x", " ("+link.Status+")"
+			problems++
+		case !link.OK():
+			mark, note = "!", " ("+link.Status+")"
+			problems++
+		}
+		fmt.Printf("  %s %s:%d -> %s: %s%s\n", mark, from, link.Line, link.Alias, to, note)
+	}
+	fmt.Printf("  %d link(s) to other projects, %d with problems\n", len(links), problems)
+}
+
+// relToProject returns path relative to a project's markdown root, or path
+// itself outside it.
+func relToProject(p *linkProject, path string) string {
+	root := p.config.MarkdownPath()
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
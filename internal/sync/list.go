@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// ProjectHealth summarizes the sync health of a configured project.
+type ProjectHealth struct {
+	Alias        string
+	LocalPath    string
+	ScrivPath    string
+	Mappings     int
+	LastSync     *time.Time
+	TrackedFiles int
+	Pending      int // -1 when not checked
+	ScrivMissing bool
+	Paused       bool
+	Err          error
+}
+
+// RunList prints all configured projects with their health and last-sync info.
+// When check is true, a quick scan is performed to count pending changes.
+func RunList(check bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	aliases := globalCfg.ListProjects()
+	if len(aliases) == 0 {
+		fmt.Println("No projects configured.")
+		fmt.Println("\nTo add a project, run:")
+		fmt.Println("  scriv-sync init --local <path> --scriv <path> --alias <name>")
+		return nil
+	}
+
+	var rows []ProjectHealth
+	for _, a := range aliases {
+		proj, _ := globalCfg.GetProject(a)
+		h := ProjectHealth{Alias: a, LocalPath: proj.LocalPath, ScrivPath: proj.ScrivPath, Pending: -1}
+		if merged, err := proj.WithLocalOverrides(); err != nil {
+			h.Err = err
+		} else {
+			h = projectHealth(merged, a, check)
+		}
+		rows = append(rows, h)
+	}
+
+	printProjectHealth(os.Stdout, rows, check)
+	return nil
+}
+
+// projectHealth gathers health information for a single project.
+func projectHealth(proj *config.ProjectConfig, alias string, check bool) ProjectHealth {
+	h := ProjectHealth{
+		Alias:     alias,
+		LocalPath: proj.LocalPath,
+		ScrivPath: proj.ScrivPath,
+		Mappings:  len(proj.EnabledMappings()),
+		Pending:   -1,
+		Paused:    !proj.IsEnabled(),
+	}
+
+	if scrivPath, err := proj.ScrivenerPath(); err != nil {
+		h.ScrivMissing = true
+	} else if !directoryExists(scrivPath) {
+		h.ScrivMissing = true
+	}
+
+	statePath, err := config.StatePath(alias)
+	if err == nil {
+		if state, err := LoadState(statePath); err == nil {
+			h.LastSync = state.LastSync
+			h.TrackedFiles = len(state.Files)
+		} else {
+			h.Err = err
+		}
+	}
+
+	if check && !h.ScrivMissing && !h.Paused {
+		syncer, err := NewSyncer(proj, alias)
+		if err != nil {
+			h.Err = err
+			return h
+		}
+		h.Pending = 0
+		err = syncer.each(func(p *Syncer) error {
+			plan, err := p.detectAllChanges()
+			if err != nil {
+				return err
+			}
+			h.Pending += plan.TotalOperations()
+			return plan.Close()
+		})
+		if err != nil {
+			h.Err = err
+			return h
+		}
+	}
+
+	return h
+}
+
+// printProjectHealth prints project health rows as an aligned table, with
+// each project's paths in the last columns.
+func printProjectHealth(w io.Writer, rows []ProjectHealth, check bool) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := "ALIAS\tMAPPINGS\tTRACKED\tLAST SYNC"
+	if check {
+		header += "\tPENDING"
+	}
+	header += "\tHEALTH\tLOCAL\tSCRIVENER"
+	fmt.Fprintln(tw, header)
+
+	for _, h := range rows {
+		lastSync := "never"
+		if h.LastSync != nil {
+			lastSync = h.LastSync.Local().Format("2006-01-02 15:04")
+		}
+
+		line := fmt.Sprintf("%s\t%d\t%d\t%s", h.Alias, h.Mappings, h.TrackedFiles, lastSync)
+		if check {
+			pending := "-"
+			if h.Pending >= 0 {
+				pending = fmt.Sprintf("%d", h.Pending)
+			}
+			line += "\t" + pending
+		}
+		line += "\t" + h.healthLabel() + "\t" + h.LocalPath + "\t" + h.ScrivPath
+		fmt.Fprintln(tw, line)
+	}
+	tw.Flush()
+
+	for _, h := range rows {
+		if h.ScrivMissing {
+			fmt.Fprintf(w, "\nWarning: '%s' Scrivener project not found: %s\n", h.Alias, h.ScrivPath)
+		} else if h.Err != nil {
+			fmt.Fprintf(w, "\nWarning: '%s': %v\n", h.Alias, h.Err)
+		}
+	}
+}
+
+// healthLabel returns a short health description for the table.
+func (h ProjectHealth) healthLabel() string {
+	switch {
+	case h.Paused:
+		return "paused"
+	case h.ScrivMissing:
+		return "scrivener missing"
+	case h.Err != nil:
+		return "error"
+	case h.Pending > 0:
+		return "changes pending"
+	default:
+		return "ok"
+	}
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"sort"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// locale returns the language of options.locale, and false if none is set,
+// in which case titles are cased and lists sorted as before.
+func (s *Syncer) locale() (language.Tag, bool) {
+	return parseLocale(s.config.Options.Locale)
+}
+
+// parseLocale parses a locale such as "tr" or "de-CH"; config validation has
+// rejected invalid ones already.
+func parseLocale(locale string) (language.Tag, bool) {
+	if locale == "" {
+		return language.Und, false
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Und, false
+	}
+	return tag, true
+}
+
+// titleCaser returns the caser title-casing words for options.locale, or nil
+// without one.
+func (s *Syncer) titleCaser() *cases.Caser {
+	tag, ok := s.locale()
+	if !ok {
+		return nil
+	}
+	c := cases.Title(tag)
+	return &c
+}
+
+// lowerLocale lowercases a title by the rules of options.locale, such as
+// Turkish "I" to "ı"; without one it is returned as it is.
+func (s *Syncer) lowerLocale(title string) string {
+	tag, ok := s.locale()
+	if !ok {
+		return title
+	}
+	return cases.Lower(tag).String(title)
+}
+
+// sortStrings sorts strings in the order of options.locale, or byte order
+// without one.
+func (s *Syncer) sortStrings(list []string) {
+	sortLocale(s.config.Options.Locale, list)
+}
+
+// sortLocale sorts strings in the collation order of a locale, or byte order
+// if locale is empty.
+func sortLocale(locale string, list []string) {
+	tag, ok := parseLocale(locale)
+	if !ok {
+		sort.Strings(list)
+		return
+	}
+	collate.New(tag).SortStrings(list)
+}
+
+// sortPlan orders the operations of a plan by markdown path in the order of
+// options.locale, which is also the order new documents are added to their
+// Scrivener folders. Without a locale they stay in the order they were found.
+func (s *Syncer) sortPlan(plan *Plan) {
+	tag, ok := s.locale()
+	if !ok {
+		return
+	}
+	c := collate.New(tag)
+	less := func(a, b string) bool { return c.CompareString(a, b) < 0 }
+	sortChanges := func(changes []FileChange) {
+		sort.SliceStable(changes, func(i, j int) bool { return less(changes[i].MarkdownPath, changes[j].MarkdownPath) })
+	}
+	sortChanges(plan.ToCreateInScriv)
+	sortChanges(plan.ToCreateInMarkdown)
+	sortChanges(plan.ToUpdateInScriv)
+	sortChanges(plan.ToUpdateInMarkdown)
+	sort.SliceStable(plan.Conflicts, func(i, j int) bool { return less(plan.Conflicts[i].MarkdownPath, plan.Conflicts[j].MarkdownPath) })
+	sort.SliceStable(plan.Orphans, func(i, j int) bool { return less(plan.Orphans[i].Path, plan.Orphans[j].Path) })
+}
@@ -0,0 +1,43 @@
+//go:build !unix
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+const (
+	lockTimeout = 30 * time.Second
+	// lockStaleAge is how old a lock file must be before it is assumed to be
+	// left over from a crashed process
+	lockStaleAge = 2 * time.Minute
+)
+
+// lockPath takes an exclusive lock on path by creating it, waiting while
+// another process holds it, and returns a function that releases it. Used
+// where flock is not available.
+func lockPath(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && timestamp.Since(info.ModTime(), time.Now()) > lockStaleAge {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
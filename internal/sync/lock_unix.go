@@ -0,0 +1,26 @@
+//go:build unix
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockPath takes an exclusive advisory lock (flock) on path, waiting while
+// another process holds it, and returns a function that releases it.
+func lockPath(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
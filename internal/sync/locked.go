@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// Locked is a change refused because its document is locked, by
+// "locked: true" in the markdown file's front matter or by a Scrivener
+// status listed in options.locked_statuses.
+type Locked struct {
+	MarkdownPath string `json:"markdown_path"`
+	ScrivUUID    string `json:"scriv_uuid,omitempty"`
+	Title        string `json:"title"`
+	Change       string `json:"change"`    // the refused change, e.g. "update in Scrivener"
+	Location     string `json:"location"`  // side the change would modify: "markdown", "scrivener" or "both"
+	LockedBy     string `json:"locked_by"` // "front matter" or the locking status
+}
+
+// frontMatterLocked reports whether markdown sets "locked: true" in its
+// front matter.
+func frontMatterLocked(content string) bool {
+	fm, _, ok := splitFrontMatter(strings.ReplaceAll(content, "\r\n", "\n"))
+	return ok && fm.Extra["locked"] == true
+}
+
+// lockedBy returns what locks a document, given its markdown (either may be
+// missing), or "" if it isn't locked.
+func (s *Syncer) lockedBy(mdContent string, doc *scrivener.Document) string {
+	if frontMatterLocked(mdContent) {
+		return "front matter"
+	}
+	if doc == nil || doc.Status == "" {
+		return ""
+	}
+	for _, status := range s.config.Options.LockedStatuses {
+		if strings.EqualFold(status, doc.Status) {
+			return fmt.Sprintf("status %q", doc.Status)
+		}
+	}
+	return ""
+}
+
+// refuseLocked lists a change to a locked document in the plan instead of
+// making it.
+func (p *Plan) refuseLocked(mdPath, uuid, title, change, location, by string) {
+	p.Locked = append(p.Locked, Locked{MarkdownPath: mdPath, ScrivUUID: uuid, Title: title, Change: change, Location: location, LockedBy: by})
+}
+
+// lockedChange describes the change a conflict result would make, and the
+// side it would modify.
+func lockedChange(conflict ConflictType) (string, string) {
+	switch conflict {
+	case ConflictMarkdownOnly:
+		return "update in Scrivener", "scrivener"
+	case ConflictScrivenerOnly:
+		return "update in markdown", "markdown"
+	default:
+		return "conflict resolution", "both"
+	}
+}
+
+// lockedFor returns the refused changes that touch the given side.
+func (p *Plan) lockedFor(location string) []Locked {
+	var locked []Locked
+	for _, l := range p.Locked {
+		if l.Location == location || l.Location == "both" {
+			locked = append(locked, l)
+		}
+	}
+	return locked
+}
+
+// printLocked lists the changes refused because their documents are locked.
+func (p *Plan) printLocked() {
+	if len(p.Locked) == 0 {
+		return
+	}
+	fmt.Println("\nErrors: locked documents (changes refused):")
+	for _, l := range p.Locked {
+		fmt.Printf("  x %s: %s refused, locked by %s\n", l.MarkdownPath, l.Change, l.LockedBy)
+	}
+}
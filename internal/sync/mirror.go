@@ -0,0 +1,210 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// mirrorMarkerFile marks a directory written by mirror, so a later export
+// may replace its contents.
+const mirrorMarkerFile = ".scriv-sync-mirror"
+
+// mirrorIndexFile holds a mirrored folder's title, synopsis and text.
+const mirrorIndexFile = "index.md"
+
+// mirror renders a binder to a directory tree of markdown.
+type mirror struct {
+	reader    *scrivener.Reader
+	dryRun    bool
+	decorated bool // include icons and labels in front matter
+	files     int
+}
+
+// RunMirror exports the whole binder of a project (except Trash) to outDir
+// as markdown: folders become directories with an index.md holding the
+// folder's synopsis, documents become files. It is one-way and never reads
+// or writes sync state, so the export is independent of the synced markdown.
+func RunMirror(alias, outDir string, dryRun bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+
+	scrivPath, err := projCfg.ScrivenerPath()
+	if err != nil {
+		return err
+	}
+	outDir, err = filepath.Abs(outDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	for _, dir := range append(projCfg.MarkdownRoots(), scrivPath) {
+		if config.IsWithin(outDir, dir) || config.IsWithin(dir, outDir) {
+			return fmt.Errorf("output directory %s must not contain or be inside %s", outDir, dir)
+		}
+	}
+
+	reader, err := newReader(scrivPath, projCfg.Options)
+	if err != nil {
+		return err
+	}
+
+	m := &mirror{reader: reader, dryRun: dryRun, decorated: projCfg.Options.Decorations == config.DecorationsFrontMatter}
+	if err := m.export(outDir); err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would export %d file(s) to %s\n", m.files, outDir)
+	} else {
+		fmt.Printf("Exported %d file(s) to %s\n", m.files, outDir)
+	}
+	return nil
+}
+
+// export writes the binder to outDir, replacing a previous export there.
+func (m *mirror) export(outDir string) error {
+	docs, err := m.reader.GetBinderStructure()
+	if err != nil {
+		return err
+	}
+	var items []*scrivener.Document
+	for _, doc := range docs {
+		if !doc.IsTrash() {
+			items = append(items, doc)
+		}
+	}
+
+	if err := m.prepare(outDir); err != nil {
+		return err
+	}
+	if err := m.writeItems(outDir, items, nil); err != nil {
+		return err
+	}
+	if m.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, mirrorMarkerFile), nil, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", mirrorMarkerFile, err)
+	}
+	return nil
+}
+
+// prepare empties a previous export at outDir. A non-empty directory that
+// was not written by mirror is left alone and reported as an error.
+func (m *mirror) prepare(outDir string) error {
+	entries, err := os.ReadDir(outDir)
+	if os.IsNotExist(err) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+	if !fileExists(filepath.Join(outDir, mirrorMarkerFile)) {
+		return fmt.Errorf("output directory %s is not empty and was not written by mirror", outDir)
+	}
+	if m.dryRun {
+		return nil
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(outDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clear previous export: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeItems writes the binder items of one folder to dir. Titles that
+// collide get numbered names (prologue.md, prologue-2.md); reserved names
+// are never used for items.
+func (m *mirror) writeItems(dir string, docs []*scrivener.Document, reserved []string) error {
+	used := make(map[string]bool)
+	for _, name := range reserved {
+		used[name] = true
+	}
+	unique := func(base, ext string) string {
+		name := base + ext
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		used[name] = true
+		return name
+	}
+
+	for _, doc := range docs {
+		base := sanitizeFilename(doc.Title)
+		if base == "" {
+			base = "untitled"
+		}
+
+		if doc.IsFolder() {
+			sub := filepath.Join(dir, unique(base, ""))
+			if err := m.write(filepath.Join(sub, mirrorIndexFile), m.folderIndex(doc)); err != nil {
+				return err
+			}
+			if err := m.writeItems(sub, doc.Children, []string{mirrorIndexFile}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := unique(base, ".md")
+		if err := m.write(filepath.Join(dir, name), documentMarkdown(m.reader, doc, managedKeys{Decorations: m.decorated})); err != nil {
+			return err
+		}
+		// A document with subdocuments gets a directory of the same name
+		if len(doc.Children) > 0 {
+			sub := filepath.Join(dir, unique(strings.TrimSuffix(name, ".md"), ""))
+			if err := m.writeItems(sub, doc.Children, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// folderIndex renders a folder's index.md: its title, synopsis and any text
+// the folder itself holds.
+func (m *mirror) folderIndex(doc *scrivener.Document) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", doc.Title)
+	if synopsis := m.reader.Synopsis(doc.UUID); synopsis != "" {
+		fmt.Fprintf(&b, "\n%s\n", synopsis)
+	}
+	if text := strings.TrimSpace(doc.Content); text != "" {
+		fmt.Fprintf(&b, "\n%s\n", text)
+	}
+	return b.String()
+}
+
+// write writes one exported file, creating its directory.
+func (m *mirror) write(path, content string) error {
+	m.files++
+	if m.dryRun {
+		fmt.Printf("  Would write: %s\n", path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// RunNew creates a document titled title on both sides of a mapping, named
+// by its markdown_dir or scrivener_folder: a markdown file from the
+// mapping's template and a Scrivener document in its folder, recorded as in
+// sync so the next sync has nothing to do for them.
+func RunNew(alias, mapping, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("a title is required")
+	}
+	s, err := NewSyncerForAlias(alias)
+	if err != nil {
+		return err
+	}
+	if err := s.checkEnabled(); err != nil {
+		return err
+	}
+	for _, p := range append([]*Syncer{s}, s.linked...) {
+		for _, m := range p.config.MappingsForProject(p.project) {
+			if mappingNamed(m, mapping) {
+				err := p.newDocument(m, title)
+				return s.uploadRemote(false, err)
+			}
+		}
+	}
+	return fmt.Errorf("no enabled mapping '%s' in project '%s'", mapping, alias)
+}
+
+// mappingNamed reports whether a mapping is the one named by name, its
+// markdown_dir or its scrivener_folder.
+func mappingNamed(m config.FolderMapping, name string) bool {
+	clean := func(s string) string { return strings.ToLower(strings.Trim(filepath.ToSlash(s), "/")) }
+	return clean(m.MarkdownDir) == clean(name) || clean(m.ScrivenerFolder) == clean(name)
+}
+
+// newDocument creates a document titled title at the top of a mapping.
+func (s *Syncer) newDocument(mapping config.FolderMapping, title string) error {
+	s.warnIntegrity()
+	mdDir := s.config.MappingDir(mapping)
+	folderPath := mapping.ScrivenerFolder
+	if mapping.IsRecursive() {
+		folderPath = ""
+		if mapping.ScrivenerFolder != "/" {
+			folderPath = "/" + strings.TrimPrefix(mapping.ScrivenerFolder, "/")
+		}
+	}
+	s.folderForDir[mdDir] = folderPath
+	s.sectionForDir[mdDir] = mapping.SectionType
+	s.setTitleRules(mdDir, mapping.Titles)
+
+	mdPath := filepath.Join(mdDir, s.docFilename(mdDir, title)+mapping.FileExtension())
+	if _, err := os.Stat(mdPath); err == nil {
+		return fmt.Errorf("%s already exists", mdPath)
+	}
+	if s.hasDocumentTitled(folderPath, title) {
+		return fmt.Errorf("Scrivener folder '%s' already has a document titled '%s'", folderLabel(folderPath), title)
+	}
+
+	content, err := s.templateContent(mapping, title)
+	if err != nil {
+		return err
+	}
+	if err := s.writer.CheckIntegrity(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(mdDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", mdDir, err)
+	}
+	if err := s.writeMarkdownFile(mdPath, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", mdPath, err)
+	}
+
+	folderUUID, err := s.ensureScrivenerFolder(mdPath)
+	if err == nil {
+		var uuid string
+		uuid, content, err = s.createDocument(title, content, folderUUID, mdPath)
+		if err == nil {
+			s.recordSync(mdPath, uuid, content)
+			err = s.writer.Save()
+		}
+		if err == nil {
+			err = s.writeSidecars()
+		}
+	}
+	if err != nil {
+		// Leave nothing behind for the next sync to push half-made
+		os.Remove(mdPath)
+		return fmt.Errorf("failed to create document '%s': %w", title, err)
+	}
+	if err := s.state.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+
+	fmt.Printf("Created %s\n", mdPath)
+	fmt.Printf("Created '%s' in Scrivener folder '%s'\n", title, folderLabel(folderPath))
+	return nil
+}
+
+// hasDocumentTitled reports whether the Scrivener folder at folderPath (""
+// for the binder root) already holds an item titled title.
+func (s *Syncer) hasDocumentTitled(folderPath, title string) bool {
+	var items []*scrivener.Document
+	if folderPath == "" {
+		items, _ = s.reader.GetBinderStructure()
+	} else if folder, err := s.reader.FindFolderByPath(folderPath); err == nil {
+		items = folder.Children
+	}
+	for _, item := range items {
+		if strings.EqualFold(item.Title, title) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateContent returns the content of a new document of a mapping: its
+// template with {title} and {date} filled in, or nothing without one. A
+// relative template path is resolved against the markdown root.
+func (s *Syncer) templateContent(mapping config.FolderMapping, title string) (string, error) {
+	if mapping.Template == "" {
+		return "", nil
+	}
+	path := config.ExpandPath(mapping.Template)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.config.MarkdownPath(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	return strings.NewReplacer(
+		"{title}", title,
+		"{date}", time.Now().Format("2006-01-02"),
+	).Replace(string(data)), nil
+}
+
+// folderLabel returns a binder folder path for display, "/" for the root.
+func folderLabel(folderPath string) string {
+	if folderPath == "" {
+		return "/"
+	}
+	return folderPath
+}
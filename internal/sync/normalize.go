@@ -0,0 +1,221 @@
+package sync
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// normalizeStep is one stage of the markdown normalization pipeline. Each
+// step gets the lines of the body and whether each is inside a fenced code
+// block.
+type normalizeStep func(lines []string, code []bool) []string
+
+var (
+	bulletRe        = regexp.MustCompile(`^(\s*)[-*+]([ \t])`)
+	thematicBreakRe = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	fenceRe         = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+)
+
+// normalizeSteps returns the steps opts enables, in the order they run.
+func normalizeSteps(opts config.Normalize) []normalizeStep {
+	var steps []normalizeStep
+	if opts.TabWidth > 0 {
+		steps = append(steps, expandTabs(opts.TabWidth))
+	}
+	if opts.BulletMarker != "" {
+		steps = append(steps, unifyBullets(opts.BulletMarker))
+	}
+	if opts.TrimTrailingWhitespace {
+		steps = append(steps, trimTrailingWhitespace)
+	}
+	if opts.FinalNewline {
+		steps = append(steps, ensureFinalNewline)
+	}
+	return steps
+}
+
+// normalize runs the configured normalization pipeline over markdown. Front
+// matter is left as it is; only the body below it is normalized.
+func (s *Syncer) normalize(content string) string {
+	return normalizeMarkdown(content, s.config.Options.Normalize)
+}
+
+// normalizeMarkdown applies the steps opts enables to the body of content.
+func normalizeMarkdown(content string, opts config.Normalize) string {
+	steps := normalizeSteps(opts)
+	if len(steps) == 0 {
+		return content
+	}
+
+	_, body, _ := splitFrontMatter(content)
+	prefix := content[:len(content)-len(body)]
+
+	lines := strings.Split(body, "\n")
+	code := fencedLines(lines)
+	for _, step := range steps {
+		lines = step(lines, code)
+	}
+	return prefix + strings.Join(lines, "\n")
+}
+
+// fencedLines reports for each line whether it is inside a fenced code
+// block. The fence lines themselves count as inside.
+func fencedLines(lines []string) []bool {
+	code := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			code[i] = true
+			if fence == "" {
+				fence = m[1]
+			} else if m[1] == fence {
+				fence = ""
+			}
+			continue
+		}
+		code[i] = fence != ""
+	}
+	return code
+}
+
+// expandTabs replaces tabs outside code blocks with spaces up to the next
+// multiple of width columns.
+func expandTabs(width int) normalizeStep {
+	return func(lines []string, code []bool) []string {
+		for i, line := range lines {
+			if code[i] || !strings.Contains(line, "\t") {
+				continue
+			}
+			var b strings.Builder
+			col := 0
+			for _, r := range line {
+				if r == '\t' {
+					n := width - col%width
+					b.WriteString(strings.Repeat(" ", n))
+					col += n
+					continue
+				}
+				b.WriteRune(r)
+				col++
+			}
+			lines[i] = b.String()
+		}
+		return lines
+	}
+}
+
+// unifyBullets writes every bullet list marker outside code blocks as
+// marker. Thematic breaks such as "* * *" are not list items and are kept.
+func unifyBullets(marker string) normalizeStep {
+	return func(lines []string, code []bool) []string {
+		for i, line := range lines {
+			if code[i] || thematicBreakRe.MatchString(line) {
+				continue
+			}
+			lines[i] = bulletRe.ReplaceAllString(line, "${1}"+marker+"${2}")
+		}
+		return lines
+	}
+}
+
+// trimTrailingWhitespace removes whitespace at the end of lines. Two or more
+// trailing spaces before a following line are a markdown hard line break,
+// and are written as exactly two spaces.
+func trimTrailingWhitespace(lines []string, code []bool) []string {
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t")
+		hardBreak := !code[i] && trimmed != "" && strings.HasSuffix(line, "  ") &&
+			i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != ""
+		if hardBreak {
+			trimmed += "  "
+		}
+		lines[i] = trimmed
+	}
+	return lines
+}
+
+// ensureFinalNewline ends a non-empty body with exactly one newline. It
+// doesn't keep lines in step with code, so it runs last.
+func ensureFinalNewline(lines []string, code []bool) []string {
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// emphasisRe matches underscore emphasis, __strong__ or _em_, not within a
+// word (snake_case stays as it is).
+var emphasisRe = regexp.MustCompile(`(^|[^\w\\])(__?)([^_\s](?:[^_]*[^_\s])?)(__?)($|[^\w])`)
+
+// canonicalSteps turn markdown into the canonical form content hashes are
+// computed from: differences the conversion to and from RTF doesn't
+// preserve, such as the bullet marker, emphasis style, trailing whitespace
+// and blank lines, are smoothed out so they never register as edits.
+var canonicalSteps = []normalizeStep{
+	unifyBullets("-"),
+	unifyEmphasis,
+	func(lines []string, code []bool) []string {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		return lines
+	},
+	collapseBlankLines,
+	ensureFinalNewline,
+}
+
+// canonicalMarkdown returns the canonical form of markdown. Like
+// normalization, it leaves front matter as it is.
+func canonicalMarkdown(content string) string {
+	content = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(content)
+	_, body, _ := splitFrontMatter(content)
+	prefix := content[:len(content)-len(body)]
+
+	lines := strings.Split(body, "\n")
+	code := fencedLines(lines)
+	for _, step := range canonicalSteps {
+		lines = step(lines, code)
+	}
+	return prefix + strings.Join(lines, "\n")
+}
+
+// unifyEmphasis writes underscore emphasis outside code blocks with
+// asterisks.
+func unifyEmphasis(lines []string, code []bool) []string {
+	for i, line := range lines {
+		if code[i] || !strings.Contains(line, "_") {
+			continue
+		}
+		// Twice, as adjacent matches share the character between them
+		for n := 0; n < 2; n++ {
+			line = emphasisRe.ReplaceAllStringFunc(line, func(m string) string {
+				parts := emphasisRe.FindStringSubmatch(m)
+				if parts[2] != parts[4] {
+					return m
+				}
+				marker := strings.Repeat("*", len(parts[2]))
+				return parts[1] + marker + parts[3] + marker + parts[5]
+			})
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// collapseBlankLines drops leading blank lines and reduces runs of blank
+// lines outside code blocks to one.
+func collapseBlankLines(lines []string, code []bool) []string {
+	out := lines[:0]
+	for i, line := range lines {
+		if line == "" && !code[i] && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,391 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+	"gopkg.in/yaml.v3"
+)
+
+// Outline formats.
+const (
+	OutlineYAML     = "yaml"
+	OutlineMarkdown = "markdown"
+)
+
+// OutlineItem is a binder item in an outline. Items without a UUID are
+// folders to create when the outline is imported.
+type OutlineItem struct {
+	Title    string        `yaml:"title"`
+	UUID     string        `yaml:"uuid,omitempty"`
+	Folder   bool          `yaml:"folder,omitempty"`
+	Synopsis string        `yaml:"synopsis,omitempty"`
+	Words    int           `yaml:"words,omitempty"` // for folders, the words of everything in them
+	Children []OutlineItem `yaml:"children,omitempty"`
+}
+
+// outlineItemRe matches an item of a markdown outline: its indentation and
+// its text.
+var outlineItemRe = regexp.MustCompile(`^( *)[-*] (.*)$`)
+
+// outlineWordsRe and outlineUUIDRe match the word count and the UUID at the
+// end of a markdown outline item.
+var (
+	outlineWordsRe = regexp.MustCompile(`\s*\(\d+ words?\)$`)
+	outlineUUIDRe  = regexp.MustCompile("\\s*`([^`]+)`$")
+)
+
+// buildOutline returns the binder items of docs as an outline, leaving the
+// Trash out.
+func buildOutline(docs []*scrivener.Document) []OutlineItem {
+	var items []OutlineItem
+	for _, doc := range docs {
+		if doc.IsTrash() {
+			continue
+		}
+		item := OutlineItem{
+			Title:    doc.Title,
+			UUID:     doc.UUID,
+			Folder:   doc.IsFolder(),
+			Synopsis: doc.Synopsis,
+			Words:    countWords(doc.Content),
+			Children: buildOutline(doc.Children),
+		}
+		for _, child := range item.Children {
+			item.Words += child.Words
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// formatOutline writes an outline as YAML or as a nested markdown list.
+func formatOutline(items []OutlineItem, format string) (string, error) {
+	switch format {
+	case OutlineYAML, "":
+		data, err := yaml.Marshal(items)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal outline: %w", err)
+		}
+		return string(data), nil
+	case OutlineMarkdown:
+		var b strings.Builder
+		writeMarkdownOutline(&b, items, 0)
+		return b.String(), nil
+	default:
+		return "", fmt.Errorf("unknown outline format '%s' (use %s or %s)", format, OutlineYAML, OutlineMarkdown)
+	}
+}
+
+// writeMarkdownOutline writes items as list items indented by depth, with
+// folders in bold, then the UUID and word count. A synopsis follows its item
+// as a quote.
+func writeMarkdownOutline(b *strings.Builder, items []OutlineItem, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, item := range items {
+		title := item.Title
+		if item.Folder {
+			title = "**" + title + "**"
+		}
+		fmt.Fprintf(b, "%s- %s `%s`", indent, title, item.UUID)
+		if item.Words > 0 {
+			fmt.Fprintf(b, " (%d words)", item.Words)
+		}
+		b.WriteString("\n")
+		if item.Synopsis != "" {
+			for _, line := range strings.Split(strings.TrimRight(item.Synopsis, "\n"), "\n") {
+				fmt.Fprintf(b, "%s  > %s\n", indent, line)
+			}
+		}
+		writeMarkdownOutline(b, item.Children, depth+1)
+	}
+}
+
+// parseOutline reads an outline written by formatOutline, and perhaps
+// edited since.
+func parseOutline(data []byte, format string) ([]OutlineItem, error) {
+	switch format {
+	case OutlineYAML, "":
+		var items []OutlineItem
+		if err := yaml.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse outline: %w", err)
+		}
+		return items, nil
+	case OutlineMarkdown:
+		return parseMarkdownOutline(string(data))
+	default:
+		return nil, fmt.Errorf("unknown outline format '%s' (use %s or %s)", format, OutlineYAML, OutlineMarkdown)
+	}
+}
+
+// parseMarkdownOutline reads a nested markdown list outline. Lines other
+// than list items, such as synopses, are ignored.
+func parseMarkdownOutline(text string) ([]OutlineItem, error) {
+	var root []OutlineItem
+	// path holds the last item read at each depth
+	var path []*[]OutlineItem
+	path = append(path, &root)
+
+	for n, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		m := outlineItemRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		depth := len(m[1]) / 2
+		if depth > len(path)-1 {
+			return nil, fmt.Errorf("line %d: indented deeper than the item before it", n+1)
+		}
+		path = path[:depth+1]
+
+		item := OutlineItem{}
+		rest := outlineWordsRe.ReplaceAllString(strings.TrimSpace(m[2]), "")
+		if u := outlineUUIDRe.FindStringSubmatch(rest); u != nil {
+			item.UUID = u[1]
+			rest = rest[:len(rest)-len(u[0])]
+		}
+		if strings.HasPrefix(rest, "**") && strings.HasSuffix(rest, "**") && len(rest) > 4 {
+			item.Folder = true
+			rest = rest[2 : len(rest)-2]
+		}
+		item.Title = strings.TrimSpace(rest)
+
+		siblings := path[depth]
+		*siblings = append(*siblings, item)
+		path = append(path, &(*siblings)[len(*siblings)-1].Children)
+	}
+	return root, nil
+}
+
+// outlineFormat returns the format of an outline file from its extension,
+// unless one is given.
+func outlineFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return OutlineMarkdown
+	}
+	return OutlineYAML
+}
+
+// applyOutline rearranges the binder to match an outline: items are retitled,
+// moved to their place in the outline, and folders without a UUID created.
+// Binder items the outline leaves out stay where they are, after the items
+// it places. It returns a description of each change and of the items left
+// out, without saving.
+func applyOutline(reader *scrivener.Reader, writer *scrivener.Writer, items []OutlineItem) ([]string, []string, error) {
+	docs, err := reader.GetBinderStructure()
+	if err != nil {
+		return nil, nil, err
+	}
+	known := make(map[string]*scrivener.Document)
+	var index func(docs []*scrivener.Document)
+	index = func(docs []*scrivener.Document) {
+		for _, doc := range docs {
+			known[doc.UUID] = doc
+			index(doc.Children)
+		}
+	}
+	index(docs)
+
+	// Check the whole outline before changing anything
+	seen := make(map[string]bool)
+	var check func(items []OutlineItem, depth int) error
+	check = func(items []OutlineItem, depth int) error {
+		for _, item := range items {
+			if item.UUID == "" {
+				if strings.TrimSpace(item.Title) == "" {
+					return fmt.Errorf("outline has a new folder without a title")
+				}
+			} else {
+				doc := known[item.UUID]
+				if doc == nil {
+					return fmt.Errorf("outline item %q has UUID %s, which is not in the binder", item.Title, item.UUID)
+				}
+				if seen[item.UUID] {
+					return fmt.Errorf("outline lists %q (%s) more than once", doc.Title, item.UUID)
+				}
+				seen[item.UUID] = true
+				if depth > 0 && isSpecialFolder(doc) {
+					return fmt.Errorf("%q must stay at the top of the binder", doc.Title)
+				}
+			}
+			if err := check(item.Children, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(items, 0); err != nil {
+		return nil, nil, err
+	}
+
+	var changes []string
+	titles := map[string]string{"": "the binder"}
+	var place func(items []OutlineItem, parent string) error
+	place = func(items []OutlineItem, parent string) error {
+		for i, item := range items {
+			uuid := item.UUID
+			if uuid == "" {
+				if uuid, err = writer.CreateFolder(item.Title, parent); err != nil {
+					return err
+				}
+				changes = append(changes, fmt.Sprintf("create folder %q in %s", item.Title, titles[parent]))
+				titles[uuid] = strconv.Quote(item.Title)
+			} else {
+				doc := known[uuid]
+				titles[uuid] = strconv.Quote(doc.Title)
+				if item.Title != "" && item.Title != doc.Title {
+					if err := writer.UpdateTitle(uuid, item.Title); err != nil {
+						return err
+					}
+					changes = append(changes, fmt.Sprintf("retitle %q to %q", doc.Title, item.Title))
+					titles[uuid] = strconv.Quote(item.Title)
+				}
+			}
+
+			if p, at, _ := writer.Position(uuid); p != parent || at != i {
+				if err := writer.MoveItem(uuid, parent, i); err != nil {
+					return err
+				}
+				if p != parent {
+					changes = append(changes, fmt.Sprintf("move %s into %s", titles[uuid], titles[parent]))
+				} else if item.UUID != "" {
+					changes = append(changes, fmt.Sprintf("reorder %s in %s", titles[uuid], titles[parent]))
+				}
+			}
+			if err := place(item.Children, uuid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := place(items, ""); err != nil {
+		return nil, nil, err
+	}
+
+	var left []string
+	var unlisted func(docs []*scrivener.Document)
+	unlisted = func(docs []*scrivener.Document) {
+		for _, doc := range docs {
+			if doc.IsTrash() {
+				continue
+			}
+			if !seen[doc.UUID] {
+				left = append(left, doc.Title)
+			}
+			unlisted(doc.Children)
+		}
+	}
+	unlisted(docs)
+	return changes, left, nil
+}
+
+// isSpecialFolder reports whether doc is the Draft, Research or Trash folder,
+// which Scrivener keeps at the top of the binder.
+func isSpecialFolder(doc *scrivener.Document) bool {
+	return doc.ItemType == "DraftFolder" || doc.ItemType == "ResearchFolder" || doc.ItemType == "TrashFolder"
+}
+
+// outlineProject returns the path and options of a Scrivener project of an
+// alias: scriv_path, or the named scriv_projects entry.
+func outlineProject(alias, project string) (string, config.Options, error) {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return "", config.Options{}, fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return "", config.Options{}, err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return "", config.Options{}, err
+	}
+	scrivPath, err := projCfg.ScrivProjectPath(project)
+	if err != nil {
+		return "", config.Options{}, err
+	}
+	return scrivPath, projCfg.Options, nil
+}
+
+// RunOutline prints the binder of a project's Scrivener project as an
+// outline: titles, UUIDs, synopses and word counts, as YAML or a nested
+// markdown list.
+func RunOutline(alias, project, format string) error {
+	scrivPath, opts, err := outlineProject(alias, project)
+	if err != nil {
+		return err
+	}
+	reader, err := newReader(scrivPath, opts)
+	if err != nil {
+		return err
+	}
+	docs, err := reader.GetBinderStructure()
+	if err != nil {
+		return err
+	}
+	out, err := formatOutline(buildOutline(docs), format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// RunImportOutline applies an edited outline to the binder of a project's
+// Scrivener project: items are reordered, moved and retitled, and folders
+// without a UUID created. Nothing is deleted.
+func RunImportOutline(alias, project, path, format string, dryRun bool) error {
+	scrivPath, _, err := outlineProject(alias, project)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read outline: %w", err)
+	}
+	items, err := parseOutline(data, outlineFormat(path, format))
+	if err != nil {
+		return err
+	}
+
+	reader, err := scrivener.NewReader(scrivPath)
+	if err != nil {
+		return fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+	}
+	writer, err := scrivener.NewWriter(scrivPath)
+	if err != nil {
+		return fmt.Errorf("failed to open Scrivener project for writing: %w", err)
+	}
+	changes, left, err := applyOutline(reader, writer, items)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(scrivPath)
+	if len(changes) == 0 {
+		fmt.Println("  The binder already matches the outline.")
+	}
+	verb := ""
+	if dryRun {
+		verb = "would "
+	}
+	for _, change := range changes {
+		fmt.Printf("  %s%s\n", verb, change)
+	}
+	if len(left) > 0 {
+		fmt.Printf("  %d binder item(s) not in the outline left in place: %s\n", len(left), strings.Join(left, ", "))
+	}
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+	return writer.Save()
+}
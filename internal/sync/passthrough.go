@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// rtfSidecar returns the path of the RTF kept beside a markdown file in
+// rtf_passthrough mode, e.g. chapter-1.rtf for chapter-1.md.
+func rtfSidecar(mdPath string) string {
+	return strings.TrimSuffix(mdPath, filepath.Ext(mdPath)) + ".rtf"
+}
+
+// storeRTF keeps a copy of a document's RTF beside the markdown file it was
+// pulled to, when rtf_passthrough is on.
+func (s *Syncer) storeRTF(mdPath, uuid string) error {
+	if !s.config.Options.RTFPassthrough {
+		return nil
+	}
+	data, err := s.reader.RTF(uuid)
+	if err != nil || data == "" {
+		return err
+	}
+	s.stageSidecar(mdPath, data)
+	return nil
+}
+
+// pushRTF updates a document from the body of a markdown file by patching
+// the RTF kept beside it, or the document's current RTF if there is none
+// yet, and keeps the result beside the file. Formatting the markdown can't
+// represent survives the round trip, as only edited paragraphs are
+// rewritten.
+func (s *Syncer) pushRTF(mdPath, uuid, body string) error {
+	base, err := s.readSidecar(mdPath)
+	if os.IsNotExist(err) {
+		current, err := s.reader.RTF(uuid)
+		if err != nil {
+			return err
+		}
+		base = []byte(current)
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", rtfSidecar(mdPath), err)
+	}
+	data, err := s.writer.PatchDocumentRTF(uuid, body, string(base))
+	if err != nil {
+		return err
+	}
+	s.stageSidecar(mdPath, data)
+	return nil
+}
+
+// storeNewRTF keeps the RTF of a document just created from a markdown
+// file beside it, when rtf_passthrough is on. Any RTF already there, left
+// by an earlier file of the same name, is replaced.
+func (s *Syncer) storeNewRTF(mdPath, uuid, body string) error {
+	if !s.config.Options.RTFPassthrough {
+		return nil
+	}
+	data, err := s.writer.PatchDocumentRTF(uuid, body, "")
+	if err != nil {
+		return err
+	}
+	s.stageSidecar(mdPath, data)
+	return nil
+}
+
+// stageSidecar holds the RTF to keep beside a markdown file until the
+// Scrivener project is saved, so a sidecar never gets ahead of the
+// document it copies.
+func (s *Syncer) stageSidecar(mdPath, data string) {
+	if s.sidecars == nil {
+		s.sidecars = make(map[string]string)
+	}
+	s.sidecars[rtfSidecar(mdPath)] = data
+}
+
+// readSidecar returns the RTF kept beside a markdown file, including one
+// staged this run.
+func (s *Syncer) readSidecar(mdPath string) ([]byte, error) {
+	path := rtfSidecar(mdPath)
+	if data, ok := s.sidecars[path]; ok {
+		return []byte(data), nil
+	}
+	return os.ReadFile(path)
+}
+
+// writeSidecars writes the staged sidecars, once the Scrivener project
+// they copy has been saved.
+func (s *Syncer) writeSidecars() error {
+	paths := make([]string, 0, len(s.sidecars))
+	for path := range s.sidecars {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte(s.sidecars[path]), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		delete(s.sidecars, path)
+	}
+	return nil
+}
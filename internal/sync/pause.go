@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// RunSetEnabled pauses (enabled false) or resumes syncing a project.
+func RunSetEnabled(alias string, enabled bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+
+	proj, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	if proj.IsEnabled() == enabled {
+		if enabled {
+			fmt.Printf("Project '%s' is not paused.\n", alias)
+		} else {
+			fmt.Printf("Project '%s' is already paused.\n", alias)
+		}
+		return nil
+	}
+
+	proj.SetEnabled(enabled)
+	if err := globalCfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if enabled {
+		fmt.Printf("Project '%s' resumed.\n", alias)
+	} else {
+		fmt.Printf("Project '%s' paused. Run 'scriv-sync resume %s' to sync it again.\n", alias, alias)
+	}
+	return nil
+}
+
+// checkEnabled returns an error if the project is paused.
+func (s *Syncer) checkEnabled() error {
+	if !s.config.IsEnabled() {
+		return fmt.Errorf("project '%s' is paused. Run 'scriv-sync resume %s' to sync it again", s.alias, s.alias)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package sync
+
+import (
+	"os"
+	"syscall"
+)
+
+// sfDataless is the file flag macOS sets on files whose content is in the
+// cloud and not on disk (SF_DATALESS).
+const sfDataless = 0x40000000
+
+// isDataless reports whether a file is a placeholder without its content.
+func isDataless(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Flags&sfDataless != 0
+}
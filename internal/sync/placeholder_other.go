@@ -0,0 +1,11 @@
+//go:build !darwin && !windows
+
+package sync
+
+import "os"
+
+// isDataless reports whether a file is a placeholder without its content.
+// Only macOS and Windows mark placeholder files.
+func isDataless(info os.FileInfo) bool {
+	return false
+}
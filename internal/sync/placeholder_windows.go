@@ -0,0 +1,21 @@
+//go:build windows
+
+package sync
+
+import (
+	"os"
+	"syscall"
+)
+
+// Attributes cloud providers such as OneDrive and Dropbox set on files
+// whose content is not on disk.
+const (
+	fileAttributeOffline            = 0x1000
+	fileAttributeRecallOnDataAccess = 0x400000
+)
+
+// isDataless reports whether a file is a placeholder without its content.
+func isDataless(info os.FileInfo) bool {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnDataAccess) != 0
+}
@@ -8,38 +8,62 @@ import (
 
 // Plan represents a set of sync operations to be executed.
 type Plan struct {
-	ToCreateInScriv    []FileChange
-	ToCreateInMarkdown []FileChange
-	ToUpdateInScriv    []FileChange
-	ToUpdateInMarkdown []FileChange
-	Conflicts          []Conflict
-	Orphans            []Orphan
+	ToCreateInScriv    []FileChange    `json:"to_create_in_scrivener"`
+	ToCreateInMarkdown []FileChange    `json:"to_create_in_markdown"`
+	ToUpdateInScriv    []FileChange    `json:"to_update_in_scrivener"`
+	ToUpdateInMarkdown []FileChange    `json:"to_update_in_markdown"`
+	Conflicts          []Conflict      `json:"conflicts"`
+	Orphans            []Orphan        `json:"orphans"`
+	Unmapped           []Unmapped      `json:"unmapped,omitempty"`        // listed only, never applied
+	UnmappedDirs       []UnmappedDir   `json:"unmapped_dirs,omitempty"`   // listed only, never applied
+	MissingContent     []FileChange    `json:"missing_content,omitempty"` // listed only, never applied
+	Skipped            []SkippedFile   `json:"skipped,omitempty"`         // listed only, never applied
+	BudgetWarnings     []BudgetWarning `json:"budget_warnings,omitempty"` // listed only, never applied
+	Truncations        []Truncation    `json:"truncations,omitempty"`     // listed only; confirmed when applied
+	Locked             []Locked        `json:"locked,omitempty"`          // refused, never applied
+	Unsupported        []Unsupported   `json:"unsupported,omitempty"`     // listed only, never synced
+
+	store   *contentStore // where content is kept; nil keeps it all in memory
+	explain bool          // PrintStatus lists each operation's reason and evidence
+
+	authors map[string]HistoryEntry // last recorded change of each markdown path, when the vault has several authors
 }
 
 // FileChange represents a single file change operation.
 type FileChange struct {
-	MarkdownPath string
-	ScrivUUID    string
-	Title        string
-	Content      string
+	MarkdownPath string `json:"markdown_path"`
+	ScrivUUID    string `json:"scriv_uuid,omitempty"`
+	Title        string `json:"title"`
+	Content      string `json:"content"`
+	// BaseHash is the hash of the destination side when the plan was made,
+	// used to detect staleness when a saved plan is applied later.
+	BaseHash string `json:"base_hash,omitempty"`
+	Provenance
+
+	spill string // temp file holding Content when it was spilled to disk
 }
 
 // Conflict represents a file that has been modified on both sides.
 type Conflict struct {
-	MarkdownPath     string
-	ScrivUUID        string
-	Title            string
-	MarkdownContent  string
-	ScrivenerContent string
+	MarkdownPath     string `json:"markdown_path"`
+	ScrivUUID        string `json:"scriv_uuid"`
+	Title            string `json:"title"`
+	MarkdownContent  string `json:"markdown_content"`
+	ScrivenerContent string `json:"scrivener_content"`
+	Quarantine       string `json:"quarantine,omitempty"` // directory holding both versions from an earlier skip
+	Provenance
+
+	markdownSpill, scrivenerSpill string // temp files holding spilled content
 }
 
 // Orphan represents a file that exists on one side but not the other.
 type Orphan struct {
-	Path         string
-	Location     string // "scrivener" or "markdown"
-	ScrivUUID    string
-	Title        string
-	LastSyncTime time.Time
+	Path         string    `json:"path"`
+	Location     string    `json:"location"` // "scrivener" or "markdown"
+	ScrivUUID    string    `json:"scriv_uuid,omitempty"`
+	Title        string    `json:"title"`
+	LastSyncTime time.Time `json:"last_sync_time"`
+	Provenance
 }
 
 // NewPlan creates a new empty sync plan.
@@ -98,6 +122,13 @@ func (p *Plan) Summary() string {
 func (p *Plan) PrintStatus() {
 	if p.IsEmpty() {
 		fmt.Println("Everything is in sync!")
+		p.printUnmapped()
+		p.printUnmappedDirs()
+		p.printMissingContent()
+		p.printSkipped()
+		p.printUnsupported()
+		p.printBudgetWarnings()
+		p.printLocked()
 		return
 	}
 
@@ -108,6 +139,7 @@ func (p *Plan) PrintStatus() {
 		fmt.Println("\nNew files to create in Scrivener:")
 		for _, fc := range p.ToCreateInScriv {
 			fmt.Printf("  + %s\n", fc.MarkdownPath)
+			p.printExplained(fc.Provenance)
 		}
 	}
 
@@ -115,6 +147,7 @@ func (p *Plan) PrintStatus() {
 		fmt.Println("\nNew files to create in markdown:")
 		for _, fc := range p.ToCreateInMarkdown {
 			fmt.Printf("  + %s (%s)\n", fc.Title, fc.ScrivUUID)
+			p.printExplained(fc.Provenance)
 		}
 	}
 
@@ -122,6 +155,8 @@ func (p *Plan) PrintStatus() {
 		fmt.Println("\nFiles to update in Scrivener (markdown -> Scrivener):")
 		for _, fc := range p.ToUpdateInScriv {
 			fmt.Printf("  ~ %s\n", fc.MarkdownPath)
+			p.printAuthor(fc.MarkdownPath)
+			p.printExplained(fc.Provenance)
 		}
 	}
 
@@ -129,6 +164,8 @@ func (p *Plan) PrintStatus() {
 		fmt.Println("\nFiles to update in markdown (Scrivener -> markdown):")
 		for _, fc := range p.ToUpdateInMarkdown {
 			fmt.Printf("  ~ %s\n", fc.MarkdownPath)
+			p.printAuthor(fc.MarkdownPath)
+			p.printExplained(fc.Provenance)
 		}
 	}
 
@@ -136,6 +173,11 @@ func (p *Plan) PrintStatus() {
 		fmt.Println("\nConflicts (both sides modified):")
 		for _, c := range p.Conflicts {
 			fmt.Printf("  ! %s (UUID: %s)\n", c.MarkdownPath, c.ScrivUUID)
+			if c.Quarantine != "" {
+				fmt.Printf("      both versions saved in %s\n", c.Quarantine)
+			}
+			p.printAuthor(c.MarkdownPath)
+			p.printExplained(c.Provenance)
 		}
 	}
 
@@ -147,13 +189,36 @@ func (p *Plan) PrintStatus() {
 			} else {
 				fmt.Printf("  ? %s (deleted from markdown)\n", o.Title)
 			}
+			p.printAuthor(o.Path)
+			p.printExplained(o.Provenance)
 		}
 	}
 
+	p.printUnmapped()
+	p.printUnmappedDirs()
+	p.printMissingContent()
+	p.printSkipped()
+	p.printUnsupported()
+	p.printBudgetWarnings()
+	p.printTruncations()
+	p.printLocked()
+
 	fmt.Println()
 	fmt.Println(p.Summary())
 }
 
+// printMissingContent lists the documents whose Scrivener content file is
+// missing, where the markdown was kept rather than emptied.
+func (p *Plan) printMissingContent() {
+	if len(p.MissingContent) == 0 {
+		return
+	}
+	fmt.Println("\nDocuments without a content file in Scrivener (markdown kept):")
+	for _, fc := range p.MissingContent {
+		fmt.Printf("  - %s (%s)\n", fc.MarkdownPath, fc.ScrivUUID)
+	}
+}
+
 // TotalOperations returns the total number of operations in the plan.
 func (p *Plan) TotalOperations() int {
 	return len(p.ToCreateInScriv) +
@@ -166,61 +231,139 @@ func (p *Plan) TotalOperations() int {
 
 // AddCreateInScriv adds a file to be created in Scrivener.
 func (p *Plan) AddCreateInScriv(mdPath, title, content string) {
+	content, spill := p.store.put(content)
 	p.ToCreateInScriv = append(p.ToCreateInScriv, FileChange{
 		MarkdownPath: mdPath,
 		Title:        title,
 		Content:      content,
+		Provenance:   Provenance{Reason: ReasonNewFile},
+		spill:        spill,
 	})
 }
 
 // AddCreateInMarkdown adds a file to be created in markdown.
 func (p *Plan) AddCreateInMarkdown(mdPath, scrivUUID, title, content string) {
+	content, spill := p.store.put(content)
 	p.ToCreateInMarkdown = append(p.ToCreateInMarkdown, FileChange{
 		MarkdownPath: mdPath,
 		ScrivUUID:    scrivUUID,
 		Title:        title,
 		Content:      content,
+		Provenance:   Provenance{Reason: ReasonNewFile},
+		spill:        spill,
 	})
 }
 
 // AddUpdateInScriv adds a file to be updated in Scrivener.
 func (p *Plan) AddUpdateInScriv(mdPath, scrivUUID, title, content string) {
+	content, spill := p.store.put(content)
 	p.ToUpdateInScriv = append(p.ToUpdateInScriv, FileChange{
 		MarkdownPath: mdPath,
 		ScrivUUID:    scrivUUID,
 		Title:        title,
 		Content:      content,
+		Provenance:   Provenance{Reason: ReasonMarkdownChanged},
+		spill:        spill,
 	})
 }
 
 // AddUpdateInMarkdown adds a file to be updated in markdown.
 func (p *Plan) AddUpdateInMarkdown(mdPath, scrivUUID, title, content string) {
+	content, spill := p.store.put(content)
 	p.ToUpdateInMarkdown = append(p.ToUpdateInMarkdown, FileChange{
 		MarkdownPath: mdPath,
 		ScrivUUID:    scrivUUID,
 		Title:        title,
 		Content:      content,
+		Provenance:   Provenance{Reason: ReasonScrivenerChanged},
+		spill:        spill,
 	})
 }
 
 // AddConflict adds a conflict to the plan.
 func (p *Plan) AddConflict(mdPath, scrivUUID, title, mdContent, scrivContent string) {
+	mdContent, mdSpill := p.store.put(mdContent)
+	scrivContent, scrivSpill := p.store.put(scrivContent)
 	p.Conflicts = append(p.Conflicts, Conflict{
 		MarkdownPath:     mdPath,
 		ScrivUUID:        scrivUUID,
 		Title:            title,
 		MarkdownContent:  mdContent,
 		ScrivenerContent: scrivContent,
+		Provenance:       Provenance{Reason: ReasonBothChanged},
+		markdownSpill:    mdSpill,
+		scrivenerSpill:   scrivSpill,
 	})
 }
 
 // AddOrphan adds an orphan to the plan.
 func (p *Plan) AddOrphan(path, location, scrivUUID, title string, lastSync time.Time) {
+	reason := ReasonDeletedInMarkdown
+	if location == "markdown" {
+		reason = ReasonDeletedInScrivener
+	}
 	p.Orphans = append(p.Orphans, Orphan{
 		Path:         path,
 		Location:     location,
 		ScrivUUID:    scrivUUID,
 		Title:        title,
 		LastSyncTime: lastSync,
+		Provenance:   Provenance{Reason: reason},
 	})
 }
+
+// Close removes any content the plan spilled to temp files.
+func (p *Plan) Close() error {
+	return p.store.close()
+}
+
+// content returns the change's content, reading it back if it was spilled.
+func (fc FileChange) content() (string, error) {
+	return loadContent(fc.Content, fc.spill)
+}
+
+// markdownContent returns the markdown side of the conflict.
+func (c Conflict) markdownContent() (string, error) {
+	return loadContent(c.MarkdownContent, c.markdownSpill)
+}
+
+// scrivenerContent returns the Scrivener side of the conflict.
+func (c Conflict) scrivenerContent() (string, error) {
+	return loadContent(c.ScrivenerContent, c.scrivenerSpill)
+}
+
+// inline returns a copy of the plan with all spilled content read back into
+// memory, for serializing it.
+func (p *Plan) inline() (*Plan, error) {
+	out := *p
+	out.store = nil
+	lists := []*[]FileChange{&out.ToCreateInScriv, &out.ToCreateInMarkdown, &out.ToUpdateInScriv, &out.ToUpdateInMarkdown}
+	for _, list := range lists {
+		changes := make([]FileChange, len(*list))
+		for i, fc := range *list {
+			content, err := fc.content()
+			if err != nil {
+				return nil, err
+			}
+			fc.Content, fc.spill = content, ""
+			changes[i] = fc
+		}
+		*list = changes
+	}
+
+	out.Conflicts = make([]Conflict, len(p.Conflicts))
+	for i, c := range p.Conflicts {
+		md, err := c.markdownContent()
+		if err != nil {
+			return nil, err
+		}
+		scriv, err := c.scrivenerContent()
+		if err != nil {
+			return nil, err
+		}
+		c.MarkdownContent, c.ScrivenerContent = md, scriv
+		c.markdownSpill, c.scrivenerSpill = "", ""
+		out.Conflicts[i] = c
+	}
+	return &out, nil
+}
@@ -0,0 +1,254 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// planFileVersion is the current version of the saved plan format.
+const planFileVersion = 1
+
+// PlanFile is the serialized form of a plan written with --plan-out and
+// replayed with `scriv-sync apply`.
+type PlanFile struct {
+	Version   int       `json:"version"`
+	Alias     string    `json:"alias"`
+	Project   string    `json:"project,omitempty"` // scriv_projects entry the plan is for; empty for scriv_path
+	CreatedAt time.Time `json:"created_at"`
+	Plan      *Plan     `json:"plan"`
+}
+
+// SetPlanOutput makes Sync, Pull and Push write their plan as JSON to path.
+// The plan for each further Scrivener project goes next to it, named after
+// the project (plan.json -> plan.novel.json).
+func (s *Syncer) SetPlanOutput(path string) {
+	s.planOut = path
+	for _, linked := range s.linked {
+		linked.planOut = ""
+		if path != "" {
+			ext := filepath.Ext(path)
+			linked.planOut = strings.TrimSuffix(path, ext) + "." + linked.project + ext
+		}
+	}
+}
+
+// writePlanFile saves a plan so it can be reviewed, edited and applied later.
+// Base hashes for updates are filled in from the current sync state, and
+// content spilled to temp files is read back so the file is self-contained.
+func (s *Syncer) writePlanFile(plan *Plan, path string) error {
+	for i := range plan.ToUpdateInScriv {
+		if fs := s.state.GetFileState(plan.ToUpdateInScriv[i].MarkdownPath); fs != nil {
+			plan.ToUpdateInScriv[i].BaseHash = fs.ContentHash
+		}
+	}
+	for i := range plan.ToUpdateInMarkdown {
+		if fs := s.state.GetFileState(plan.ToUpdateInMarkdown[i].MarkdownPath); fs != nil {
+			plan.ToUpdateInMarkdown[i].BaseHash = fs.ContentHash
+		}
+	}
+
+	inlined, err := plan.inline()
+	if err != nil {
+		return err
+	}
+
+	pf := PlanFile{
+		Version:   planFileVersion,
+		Alias:     s.alias,
+		Project:   s.project,
+		CreatedAt: time.Now(),
+		Plan:      inlined,
+	}
+
+	data, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+
+	fmt.Printf("\nPlan written to %s\n", path)
+	return nil
+}
+
+// LoadPlanFile reads a plan previously written with --plan-out.
+func LoadPlanFile(path string) (*PlanFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	pf := &PlanFile{}
+	if err := json.Unmarshal(data, pf); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+	if pf.Version != planFileVersion {
+		return nil, fmt.Errorf("unsupported plan file version %d", pf.Version)
+	}
+	if pf.Plan == nil {
+		pf.Plan = NewPlan()
+	}
+
+	return pf, nil
+}
+
+// Apply executes a saved plan. Every operation is revalidated against the
+// current content on both sides first; operations whose inputs changed since
+// the plan was made are skipped as stale.
+func (s *Syncer) Apply(planPath string, dryRun, interactive bool) error {
+	if err := s.checkEnabled(); err != nil {
+		return err
+	}
+	pf, err := LoadPlanFile(planPath)
+	if err != nil {
+		return err
+	}
+	if pf.Alias != "" && pf.Alias != s.alias {
+		return fmt.Errorf("plan was made for project '%s', not '%s'", pf.Alias, s.alias)
+	}
+	if pf.Project != s.project {
+		for _, linked := range s.linked {
+			if linked.project == pf.Project {
+				return s.uploadRemote(dryRun, linked.applyPlan(pf, dryRun, interactive))
+			}
+		}
+		return fmt.Errorf("plan was made for Scrivener project '%s', which '%s' does not have", pf.Project, s.alias)
+	}
+	return s.uploadRemote(dryRun, s.applyPlan(pf, dryRun, interactive))
+}
+
+// applyPlan executes a loaded plan against this Syncer's Scrivener project.
+func (s *Syncer) applyPlan(pf *PlanFile, dryRun, interactive bool) error {
+	plan, stale, err := s.revalidatePlan(pf.Plan)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range stale {
+		fmt.Printf("  Stale, skipping: %s\n", msg)
+	}
+	if len(stale) > 0 {
+		fmt.Printf("\n%d operation(s) skipped because content changed since the plan was made.\n\n", len(stale))
+	}
+
+	if plan.IsEmpty() {
+		fmt.Println("Nothing to apply.")
+		return nil
+	}
+
+	plan.PrintStatus()
+
+	if dryRun {
+		fmt.Println("\n(dry-run mode - no changes applied)")
+		return nil
+	}
+
+	if err := s.confirmFirstSync(plan); err != nil {
+		return err
+	}
+	if err := s.confirmTruncations(plan); err != nil {
+		return err
+	}
+	if err := s.confirmImpact(plan); err != nil {
+		return err
+	}
+	return s.executePlan(plan, interactive)
+}
+
+// revalidatePlan returns the operations of plan that are still valid, plus a
+// description of each stale operation.
+func (s *Syncer) revalidatePlan(plan *Plan) (*Plan, []string, error) {
+	docs, err := s.reader.GetAllDocuments()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Scrivener documents: %w", err)
+	}
+	byUUID := make(map[string]*scrivener.Document, len(docs))
+	for _, doc := range docs {
+		byUUID[doc.UUID] = doc
+	}
+
+	scrivHash := func(uuid string) (string, bool) {
+		doc, ok := byUUID[uuid]
+		if !ok {
+			return "", false
+		}
+		return s.docHash(doc), true
+	}
+	mdHash := func(path string) (string, bool) {
+		content, err := s.readMarkdownFile(path)
+		if err != nil {
+			return "", false
+		}
+		return s.contentHash(content), true
+	}
+
+	valid := NewPlan()
+	var stale []string
+
+	for _, fc := range plan.ToCreateInScriv {
+		if h, ok := mdHash(fc.MarkdownPath); ok && h == s.contentHash(fc.Content) {
+			valid.ToCreateInScriv = append(valid.ToCreateInScriv, fc)
+		} else {
+			stale = append(stale, "create in Scrivener: "+fc.MarkdownPath)
+		}
+	}
+
+	for _, fc := range plan.ToCreateInMarkdown {
+		h, ok := scrivHash(fc.ScrivUUID)
+		if ok && h == s.contentHash(fc.Content) && !fileExists(fc.MarkdownPath) {
+			valid.ToCreateInMarkdown = append(valid.ToCreateInMarkdown, fc)
+		} else {
+			stale = append(stale, "create in markdown: "+fc.MarkdownPath)
+		}
+	}
+
+	for _, fc := range plan.ToUpdateInScriv {
+		mh, mok := mdHash(fc.MarkdownPath)
+		sh, sok := scrivHash(fc.ScrivUUID)
+		if mok && sok && mh == s.contentHash(fc.Content) && (fc.BaseHash == "" || sh == fc.BaseHash) {
+			valid.ToUpdateInScriv = append(valid.ToUpdateInScriv, fc)
+		} else {
+			stale = append(stale, "update in Scrivener: "+fc.MarkdownPath)
+		}
+	}
+
+	for _, fc := range plan.ToUpdateInMarkdown {
+		mh, mok := mdHash(fc.MarkdownPath)
+		sh, sok := scrivHash(fc.ScrivUUID)
+		if mok && sok && sh == s.contentHash(fc.Content) && (fc.BaseHash == "" || mh == fc.BaseHash) {
+			valid.ToUpdateInMarkdown = append(valid.ToUpdateInMarkdown, fc)
+		} else {
+			stale = append(stale, "update in markdown: "+fc.MarkdownPath)
+		}
+	}
+
+	for _, c := range plan.Conflicts {
+		mh, mok := mdHash(c.MarkdownPath)
+		sh, sok := scrivHash(c.ScrivUUID)
+		if mok && sok && mh == s.contentHash(c.MarkdownContent) && sh == s.contentHash(c.ScrivenerContent) {
+			valid.Conflicts = append(valid.Conflicts, c)
+		} else {
+			stale = append(stale, "conflict: "+c.MarkdownPath)
+		}
+	}
+
+	for _, o := range plan.Orphans {
+		_, scrivExists := byUUID[o.ScrivUUID]
+		mdExists := fileExists(o.Path)
+		if (o.Location == "markdown" && mdExists && !scrivExists) ||
+			(o.Location == "scrivener" && !mdExists && scrivExists) {
+			valid.Orphans = append(valid.Orphans, o)
+		} else {
+			stale = append(stale, "orphan: "+o.Path)
+		}
+	}
+
+	return valid, stale, nil
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Porcelain status codes, one per kind of pending operation. These are part
+// of the porcelain format and must never change meaning; new kinds of
+// operation get new codes.
+const (
+	PorcelainCreateInScriv     = "A" // new markdown file, to be created in Scrivener
+	PorcelainCreateInMarkdown  = "N" // new Scrivener document, to be created in markdown
+	PorcelainUpdateInScriv     = "M" // markdown modified, to be copied to Scrivener
+	PorcelainUpdateInMarkdown  = "S" // Scrivener modified, to be copied to markdown
+	PorcelainConflict          = "C" // modified on both sides
+	PorcelainDeletedInMarkdown = "D" // markdown file deleted, document still in Scrivener
+	PorcelainDeletedInScriv    = "X" // Scrivener document deleted, markdown file still present
+)
+
+// WritePorcelain writes the plan in the porcelain format: one line per
+// operation with four tab-separated fields, the status code, markdown path,
+// Scrivener UUID and title. Empty fields are left empty, and tabs or
+// newlines within a field are replaced by spaces. An empty plan writes
+// nothing. The format is kept stable across versions for scripts.
+func (p *Plan) WritePorcelain(w io.Writer) {
+	line := func(code, path, uuid, title string) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", code, porcelainField(path), porcelainField(uuid), porcelainField(title))
+	}
+
+	for _, fc := range p.ToCreateInScriv {
+		line(PorcelainCreateInScriv, fc.MarkdownPath, fc.ScrivUUID, fc.Title)
+	}
+	for _, fc := range p.ToCreateInMarkdown {
+		line(PorcelainCreateInMarkdown, fc.MarkdownPath, fc.ScrivUUID, fc.Title)
+	}
+	for _, fc := range p.ToUpdateInScriv {
+		line(PorcelainUpdateInScriv, fc.MarkdownPath, fc.ScrivUUID, fc.Title)
+	}
+	for _, fc := range p.ToUpdateInMarkdown {
+		line(PorcelainUpdateInMarkdown, fc.MarkdownPath, fc.ScrivUUID, fc.Title)
+	}
+	for _, c := range p.Conflicts {
+		line(PorcelainConflict, c.MarkdownPath, c.ScrivUUID, c.Title)
+	}
+	for _, o := range p.Orphans {
+		code := PorcelainDeletedInMarkdown
+		if o.Location == "markdown" {
+			code = PorcelainDeletedInScriv
+		}
+		line(code, o.Path, o.ScrivUUID, o.Title)
+	}
+}
+
+// porcelainField makes a value safe to use as a porcelain field.
+func porcelainField(value string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(value)
+}
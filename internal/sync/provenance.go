@@ -0,0 +1,144 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+	"github.com/sweiss/harcroft/internal/timestamp"
+)
+
+// Reason is a machine-readable code for why a plan holds an operation.
+type Reason string
+
+// Reasons for the operations of a plan.
+const (
+	ReasonNewFile            Reason = "new-file"                 // on one side only, never synced
+	ReasonNewOnBothSides     Reason = "new-on-both-sides"        // never synced, with a file and a document of the same title
+	ReasonRecreated          Reason = "recreated-after-deletion" // synced once, deleted, and back on both sides
+	ReasonMarkdownChanged    Reason = "markdown-changed"         // the markdown hash differs from the last sync
+	ReasonScrivenerChanged   Reason = "scrivener-changed"        // the Scrivener hash differs from the last sync
+	ReasonBothChanged        Reason = "both-changed"             // both hashes differ from the last sync and each other
+	ReasonDeletedInScrivener Reason = "deleted-in-scrivener"     // synced once, the document is gone
+	ReasonDeletedInMarkdown  Reason = "deleted-in-markdown"      // synced once, the file is gone
+)
+
+// Provenance explains an operation: its reason and the evidence the plan
+// was made from.
+type Provenance struct {
+	Reason   Reason    `json:"reason,omitempty"`
+	Evidence *Evidence `json:"evidence,omitempty"`
+}
+
+// Evidence is what the sync compared to decide on an operation. Hashes are
+// of the markdown each side converts to; times are RFC 3339.
+type Evidence struct {
+	SyncedHash        string `json:"synced_hash,omitempty"` // recorded at the last sync
+	MarkdownHash      string `json:"markdown_hash,omitempty"`
+	ScrivenerHash     string `json:"scrivener_hash,omitempty"`
+	LastSynced        string `json:"last_synced,omitempty"`
+	MarkdownModified  string `json:"markdown_modified,omitempty"`
+	ScrivenerModified string `json:"scrivener_modified,omitempty"`
+}
+
+// provenance gathers the evidence for an operation on mdPath and doc, either
+// of which may be missing, from the hashes compared and the sync state.
+func (s *Syncer) provenance(reason Reason, mdPath, mdHash string, doc *scrivener.Document, scrivHash string) Provenance {
+	ev := &Evidence{MarkdownHash: mdHash, ScrivenerHash: scrivHash}
+	fs := s.state.GetFileState(mdPath)
+	if fs == nil {
+		fs = s.state.GetDeletedFileState(mdPath)
+	}
+	if fs != nil {
+		ev.SyncedHash, ev.LastSynced = fs.ContentHash, fs.LastSynced
+	}
+	if info, err := os.Stat(mdPath); err == nil {
+		ev.MarkdownModified = timestamp.Format(info.ModTime())
+	}
+	if doc != nil && !doc.Modified.IsZero() {
+		ev.ScrivenerModified = timestamp.Format(doc.Modified)
+	}
+	return Provenance{Reason: reason, Evidence: ev}
+}
+
+// changeReason returns the reason for a change DetectConflict found
+// between a markdown file and its document.
+func (s *Syncer) changeReason(mdPath string, conflict ConflictType) Reason {
+	switch conflict {
+	case ConflictMarkdownOnly:
+		return ReasonMarkdownChanged
+	case ConflictScrivenerOnly:
+		return ReasonScrivenerChanged
+	case ConflictNewFile:
+		return ReasonNewOnBothSides
+	}
+	if s.state.GetFileState(mdPath) == nil && s.state.GetDeletedFileState(mdPath) != nil {
+		return ReasonRecreated
+	}
+	return ReasonBothChanged
+}
+
+// explain returns the reason and evidence as an indented line, or "" for an
+// operation without a reason, such as one from an older saved plan.
+func (p Provenance) explain() string {
+	if p.Reason == "" {
+		return ""
+	}
+	line := "      why: " + string(p.Reason)
+	ev := p.Evidence
+	if ev == nil {
+		return line
+	}
+	if ev.SyncedHash != "" {
+		line += fmt.Sprintf("; synced %s", shortHash(ev.SyncedHash))
+		if ev.LastSynced != "" {
+			line += " at " + ev.LastSynced
+		}
+	}
+	line += side("markdown", ev.MarkdownHash, ev.MarkdownModified)
+	line += side("Scrivener", ev.ScrivenerHash, ev.ScrivenerModified)
+	return line
+}
+
+// side describes the evidence from one side, or returns "" if there is none.
+func side(name, hash, modified string) string {
+	if hash == "" && modified == "" {
+		return ""
+	}
+	desc := "; " + name
+	if hash != "" {
+		desc += " " + shortHash(hash)
+	}
+	if modified != "" {
+		desc += " modified " + modified
+	}
+	return desc
+}
+
+// shortHash abbreviates a hash for display.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// printExplained prints a line under an operation of the detailed status
+// with its reason and evidence, when the status explains the plan.
+func (p *Plan) printExplained(why Provenance) {
+	if !p.explain {
+		return
+	}
+	if line := why.explain(); line != "" {
+		fmt.Println(line)
+	}
+}
+
+// SetExplain makes Status list the reason and evidence of each operation
+// under it, as --explain does.
+func (s *Syncer) SetExplain(explain bool) {
+	s.explain = explain
+	for _, linked := range s.linked {
+		linked.explain = explain
+	}
+}
@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// QuarantineDirName is the directory under the markdown root that holds both
+// versions of each skipped conflict until it is resolved.
+const QuarantineDirName = ".scriv-sync/conflicts"
+
+// quarantinePaths returns where the markdown and Scrivener versions of a
+// skipped conflict are kept: <root>/.scriv-sync/conflicts/<file>.markdown.md
+// and <file>.scrivener.md, mirroring the file's path under its root.
+func (s *Syncer) quarantinePaths(mdPath string) (string, string) {
+	root := s.rootFor(mdPath)
+	rel, err := filepath.Rel(root, mdPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(mdPath)
+	}
+	base := filepath.Join(root, filepath.FromSlash(QuarantineDirName), strings.TrimSuffix(rel, filepath.Ext(rel)))
+	return base + ".markdown.md", base + ".scrivener.md"
+}
+
+// quarantineConflict writes both versions of a skipped conflict to the
+// quarantine directory and returns the directory holding them.
+func (s *Syncer) quarantineConflict(c Conflict) (string, error) {
+	md, err := c.markdownContent()
+	if err != nil {
+		return "", err
+	}
+	scriv, err := c.scrivenerContent()
+	if err != nil {
+		return "", err
+	}
+
+	mdCopy, scrivCopy := s.quarantinePaths(c.MarkdownPath)
+	if err := os.MkdirAll(filepath.Dir(mdCopy), 0755); err != nil {
+		return "", fmt.Errorf("failed to create conflict directory: %w", err)
+	}
+	if err := os.WriteFile(mdCopy, []byte(md), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", mdCopy, err)
+	}
+	if err := os.WriteFile(scrivCopy, []byte(scriv), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", scrivCopy, err)
+	}
+	return filepath.Dir(mdCopy), nil
+}
+
+// releaseQuarantine removes the quarantined versions of a file once its
+// conflict is resolved.
+func (s *Syncer) releaseQuarantine(mdPath string) {
+	mdCopy, scrivCopy := s.quarantinePaths(mdPath)
+	os.Remove(mdCopy)
+	os.Remove(scrivCopy)
+}
+
+// markQuarantined points each conflict of the plan at its quarantined
+// versions from an earlier skip, if they exist.
+func (s *Syncer) markQuarantined(plan *Plan) {
+	for i, c := range plan.Conflicts {
+		if mdCopy, _ := s.quarantinePaths(c.MarkdownPath); fileExists(mdCopy) {
+			plan.Conflicts[i].Quarantine = filepath.Dir(mdCopy)
+		}
+	}
+}
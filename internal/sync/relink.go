@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// ProjectMismatchError reports that a project's sync state was recorded
+// against a different Scrivener project than the one configured, e.g. after
+// the project was copied with Save As or duplicated by a cloud service.
+// Syncing anyway could pair the wrong documents or report every document as
+// a conflict, so the user must re-point or rebaseline the state first.
+type ProjectMismatchError struct {
+	Alias       string
+	StatePath   string // Scrivener project the state was recorded against
+	ScrivPath   string // Scrivener project in the config
+	SameProject bool   // both have the same Identifier, so one is a copy of the other
+}
+
+func (e *ProjectMismatchError) Error() string {
+	var b strings.Builder
+	if e.SameProject {
+		fmt.Fprintf(&b, "sync state for '%s' was recorded against %s, but the config points to %s, a copy of the same project.\n",
+			e.Alias, e.StatePath, e.ScrivPath)
+	} else {
+		fmt.Fprintf(&b, "sync state for '%s' was recorded against %s, but the config points to %s, a different Scrivener project.\n",
+			e.Alias, e.StatePath, e.ScrivPath)
+	}
+	fmt.Fprintf(&b, "  To continue syncing with %s, run: scriv-sync relink %s\n", e.ScrivPath, e.Alias)
+	fmt.Fprintf(&b, "  To start over, matching documents whose content is identical, run: scriv-sync relink %s --rebaseline", e.Alias)
+	return b.String()
+}
+
+// checkProjectIdentity verifies that the state belongs to the configured
+// Scrivener project. A project that was moved (its old path is gone and the
+// Identifier matches) is followed automatically; a copy alongside the
+// original, or a different project, is reported as a ProjectMismatchError.
+func (s *Syncer) checkProjectIdentity() error {
+	old := s.state.ScrivPath
+	if old == "" || filepath.Clean(old) == filepath.Clean(s.scrivPath) || len(s.state.Files) == 0 {
+		return nil
+	}
+
+	id := s.reader.Identifier()
+	sameID := s.state.ProjectID == "" || id == "" || s.state.ProjectID == id
+	if sameID && !directoryExists(old) {
+		fmt.Printf("Scrivener project moved from %s to %s; following it.\n", old, s.scrivPath)
+		return nil
+	}
+
+	return &ProjectMismatchError{
+		Alias:       s.alias,
+		StatePath:   old,
+		ScrivPath:   s.scrivPath,
+		SameProject: sameID,
+	}
+}
+
+// Rebaseline rebuilds the sync state against the configured Scrivener
+// project. Markdown files and documents that pair up and have identical
+// content are recorded as in sync; everything else is left for the next
+// sync to create or report as a conflict. It returns the number of pairs
+// recorded.
+func (s *Syncer) Rebaseline() (int, error) {
+	s.state.Files = make(map[string]FileState)
+	s.state.DeletedFiles = make(map[string]FileState)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		return 0, err
+	}
+	defer plan.Close()
+
+	matched := 0
+	for _, c := range plan.Conflicts {
+		md, err := c.markdownContent()
+		if err != nil {
+			return 0, err
+		}
+		scriv, err := c.scrivenerContent()
+		if err != nil {
+			return 0, err
+		}
+		if s.contentHash(md) == s.contentHash(scriv) {
+			s.recordSync(c.MarkdownPath, c.ScrivUUID, md)
+			matched++
+		}
+	}
+
+	s.state.LastSync = nil
+	if err := s.state.Save(); err != nil {
+		return 0, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return matched, nil
+}
+
+// RunRelink re-points a project's sync state at its configured Scrivener
+// project, or with rebaseline rebuilds the state from scratch.
+func RunRelink(alias string, rebaseline bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+
+	s, err := newSyncer(projCfg, alias, false)
+	if err != nil {
+		return err
+	}
+
+	// With several Scrivener projects, only those whose state doesn't match
+	// are rebaselined, unless none of them mismatch
+	mismatched := s.mismatch != nil
+	for _, linked := range s.linked {
+		mismatched = mismatched || linked.mismatch != nil
+	}
+	return s.each(func(p *Syncer) error {
+		if rebaseline && (p.mismatch != nil || !mismatched) {
+			matched, err := p.Rebaseline()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Rebaselined '%s' against %s: %d document(s) already in sync.\n", alias, p.scrivPath, matched)
+			return nil
+		}
+
+		// newSyncer already pointed the state at the configured project
+		if err := p.state.Save(); err != nil {
+			return fmt.Errorf("failed to save sync state: %w", err)
+		}
+		fmt.Printf("Project '%s' now syncs with %s.\n", alias, p.scrivPath)
+		return nil
+	})
+}
+
+// PromptRelink asks how to resolve a project mismatch and applies the
+// choice. It reports false if the user chose to stop.
+func PromptRelink(mismatch *ProjectMismatchError) (bool, error) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println()
+	fmt.Printf("The sync state for '%s' was recorded against a different Scrivener project:\n", mismatch.Alias)
+	fmt.Printf("  State:  %s\n", mismatch.StatePath)
+	fmt.Printf("  Config: %s\n", mismatch.ScrivPath)
+	if mismatch.SameProject {
+		fmt.Println("  Both are copies of the same project.")
+	}
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  [r] Re-point the state at the configured project and keep syncing")
+	fmt.Println("  [b] Rebaseline: start over, matching documents with identical content")
+	fmt.Println("  [q] Quit without changing anything")
+
+	for {
+		fmt.Print("\nChoice: ")
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return false, nil
+		}
+
+		switch strings.TrimSpace(strings.ToLower(input)) {
+		case "r":
+			return true, RunRelink(mismatch.Alias, false)
+		case "b":
+			return true, RunRelink(mismatch.Alias, true)
+		case "q":
+			return false, nil
+		default:
+			fmt.Println("Invalid choice. Please enter r, b, or q.")
+		}
+	}
+}
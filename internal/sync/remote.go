@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/remote"
+)
+
+// openRemote returns the store and manifest of a project's remote vault.
+func openRemote(cfg *config.ProjectConfig, alias string) (remote.Store, *remote.Manifest, error) {
+	store, err := remote.New(*cfg.Remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifestPath, err := config.RemoteManifestPath(alias)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := remote.LoadManifest(manifestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, m, nil
+}
+
+// fetchRemote brings local_path up to date with the project's remote vault
+// before a run.
+func fetchRemote(cfg *config.ProjectConfig, alias string) error {
+	if cfg.LocalPath == "" {
+		return fmt.Errorf("local_path is required: it holds the working copy of the remote vault")
+	}
+	store, m, err := openRemote(cfg, alias)
+	if err != nil {
+		return err
+	}
+	res, err := remote.Fetch(store, cfg.MarkdownPath(), m)
+	if err != nil {
+		return fmt.Errorf("failed to fetch remote vault: %w", err)
+	}
+	if res.Transferred > 0 || res.Deleted > 0 {
+		fmt.Printf("Fetched remote vault: %d downloaded, %d removed\n", res.Transferred, res.Deleted)
+	}
+	printKept(res.Kept)
+	return nil
+}
+
+// uploadRemote sends what a run changed in local_path to the project's
+// remote vault, unless it was a dry run or failed outright; runErr is the
+// run's result, returned unless the upload fails after a run that succeeded.
+func (s *Syncer) uploadRemote(dryRun bool, runErr error) error {
+	var failed *FailedOperationsError
+	if s.config.Remote == nil || dryRun || (runErr != nil && !errors.As(runErr, &failed)) {
+		return runErr
+	}
+	err := func() error {
+		store, m, err := openRemote(s.config, s.alias)
+		if err != nil {
+			return err
+		}
+		res, err := remote.Upload(store, s.mdRoot, m)
+		if err != nil {
+			return fmt.Errorf("failed to upload to remote vault: %w", err)
+		}
+		if res.Transferred > 0 || res.Deleted > 0 {
+			fmt.Printf("Uploaded to remote vault: %d written, %d removed\n", res.Transferred, res.Deleted)
+		}
+		printKept(res.Kept)
+		return nil
+	}()
+	if err == nil {
+		return runErr
+	}
+	if runErr == nil {
+		return err
+	}
+	fmt.Printf("  Warning: %v\n", err)
+	return runErr
+}
+
+// printKept lists files left alone because they changed both locally and on
+// the remote vault.
+func printKept(kept []string) {
+	if len(kept) == 0 {
+		return
+	}
+	fmt.Printf("Changed both locally and on the remote vault, left alone (%d):\n", len(kept))
+	for _, path := range kept {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Println("  Delete the local copy to take the remote's version, or the remote copy to keep yours, then run again.")
+}
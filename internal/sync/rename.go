@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// RunRenameAlias renames a project alias, moving its sync state and reports
+// along with it.
+func RunRenameAlias(oldAlias, newAlias string) error {
+	// 1. Load global config
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+
+	// 2. Rename project and move its files
+	undo, err := globalCfg.RenameProject(oldAlias, newAlias)
+	if err != nil {
+		return err
+	}
+
+	// 3. Save global config, putting the files back if that fails
+	if err := globalCfg.Save(); err != nil {
+		undo()
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Printf("Project '%s' renamed to '%s'.\n", oldAlias, newAlias)
+	return nil
+}
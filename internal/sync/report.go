@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sweiss/harcroft/internal/vault"
+)
+
+// Report records every operation performed during one sync run so it can be
+// kept as an audit trail.
+type Report struct {
+	Alias    string
+	Started  time.Time
+	Finished time.Time
+	Entries  []ReportEntry
+
+	encrypt bool // Write seals the report as <timestamp>.enc; see encrypt_state
+}
+
+// ReportEntry is a single operation or decision in a report.
+type ReportEntry struct {
+	Action string
+	Path   string
+	Title  string
+	Detail string
+}
+
+// NewReport creates an empty report for a run starting now.
+func NewReport(alias string) *Report {
+	return &Report{
+		Alias:   alias,
+		Started: time.Now(),
+	}
+}
+
+// Add records an operation in the report.
+func (r *Report) Add(action, path, title, detail string) {
+	r.Entries = append(r.Entries, ReportEntry{
+		Action: action,
+		Path:   path,
+		Title:  title,
+		Detail: detail,
+	})
+}
+
+// Markdown renders the report as a markdown document with one table row per entry.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# scriv-sync report: %s\n\n", r.Alias)
+	fmt.Fprintf(&b, "- Started: %s\n", r.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Finished: %s\n", r.Finished.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Operations: %d\n\n", len(r.Entries))
+
+	if len(r.Entries) == 0 {
+		b.WriteString("No operations.\n")
+		return b.String()
+	}
+
+	b.WriteString("| Action | Path | Title | Detail |\n")
+	b.WriteString("|--------|------|-------|--------|\n")
+	for _, e := range r.Entries {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+			escapeTableCell(e.Action), escapeTableCell(e.Path), escapeTableCell(e.Title), escapeTableCell(e.Detail))
+	}
+
+	return b.String()
+}
+
+// Write saves the report as <dir>/<timestamp>.md and returns its path.
+func (r *Report) Write(dir string) (string, error) {
+	if r.Finished.IsZero() {
+		r.Finished = time.Now()
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create reports directory: %w", err)
+	}
+
+	ext := ".md"
+	if r.encrypt {
+		ext = ".enc"
+	}
+	data, err := vault.Encode([]byte(r.Markdown()), r.encrypt)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt report: %w", err)
+	}
+	path := uniquePath(filepath.Join(dir, r.Started.Format("20060102-150405")+ext))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
+
+// escapeTableCell makes a value safe to place in a markdown table cell.
+func escapeTableCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.ReplaceAll(value, "\n", " ")
+}
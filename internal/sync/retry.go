@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// retryAttempts is how many times a file operation failing with a transient
+// error is tried; retryDelay is the wait before the second try, doubling
+// after each one.
+var (
+	retryAttempts = 5
+	retryDelay    = 100 * time.Millisecond
+)
+
+// TransientError is a file operation that kept failing with an error that
+// is usually brief, such as a cloud sync client holding the file.
+type TransientError struct {
+	Err      error
+	Attempts int
+}
+
+// Error describes the failure and what to do about it.
+func (e *TransientError) Error() string {
+	return fmt.Sprintf("%v (still failing after %d attempts; the cloud sync client may be busy with it, try again shortly)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the last error.
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// isTransient reports whether err is one that retrying may get past.
+func isTransient(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, t := range transientErrnos {
+		if errno == t {
+			return true
+		}
+	}
+	return false
+}
+
+// withRetry runs a file operation, trying it again with backoff while it
+// fails with a transient error. Other errors are returned at once; one still
+// transient after the last try is returned as a TransientError.
+func withRetry(op func() error) error {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if attempt >= retryAttempts {
+			return &TransientError{Err: err, Attempts: attempt}
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Failure is an operation of a plan that failed while the rest of the plan
+// ran.
+type Failure struct {
+	Operation Operation
+	Path      string
+	Title     string
+	Err       error
+}
+
+// FailedOperationsError is returned by a sync that applied and saved its
+// plan except for the operations that failed.
+type FailedOperationsError struct {
+	Failures []Failure
+}
+
+// Error summarizes the failures.
+func (e *FailedOperationsError) Error() string {
+	return fmt.Sprintf("%d operation(s) failed; the rest were applied, and the next sync retries them", len(e.Failures))
+}
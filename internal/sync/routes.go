@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// routeDir returns the markdown directory of a route. Linked projects each
+// get a subdirectory, as with unmappedDir.
+func (s *Syncer) routeDir(route config.Route) string {
+	dir := config.ExpandPath(route.Dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.config.MarkdownPath(), dir)
+	}
+	if s.project != "" {
+		dir = filepath.Join(dir, sanitizeFilename(s.project))
+	}
+	return filepath.Clean(dir)
+}
+
+// isRouteDir reports whether dir is the directory of one of the routes.
+func (s *Syncer) isRouteDir(dir string) bool {
+	for _, route := range s.config.Options.Routes {
+		if s.routeDir(route) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// routeFor returns the directory a document is routed to, or "" if it
+// stays with its mapping. Routes decide where a document is first pulled:
+// one already synced stays where its file is, so a document whose label
+// changes isn't pulled twice.
+func (s *Syncer) routeFor(doc *scrivener.Document) string {
+	if tracked := s.trackedPath(doc.UUID); tracked != "" {
+		if dir := filepath.Dir(tracked); s.isRouteDir(dir) {
+			return dir
+		}
+		return ""
+	}
+	for _, route := range s.config.Options.Routes {
+		if route.Label != "" && strings.EqualFold(route.Label, doc.Label) {
+			return s.routeDir(route)
+		}
+		if route.Keyword != "" && hasKeyword(doc, route.Keyword) {
+			return s.routeDir(route)
+		}
+	}
+	return ""
+}
+
+// hasKeyword reports whether the document has the keyword, compared
+// regardless of case.
+func hasKeyword(doc *scrivener.Document, keyword string) bool {
+	for _, k := range doc.Keywords {
+		if strings.EqualFold(k, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeDocs takes the routed documents out of the documents of mdDir,
+// keeping them for detectRouted. A document not synced yet whose file is
+// already in mdDir stays there, rather than being pulled beside it.
+func (s *Syncer) routeDocs(mdDir string, docs []*scrivener.Document, mdFiles []string) []*scrivener.Document {
+	if len(s.config.Options.Routes) == 0 || s.isRouteDir(mdDir) {
+		return docs
+	}
+	ext := s.extensionFor(mdDir)
+	existing := make(map[string]bool, len(mdFiles))
+	for _, mdPath := range mdFiles {
+		existing[strings.ToLower(filepath.Base(mdPath))] = true
+	}
+
+	var kept []*scrivener.Document
+	for _, doc := range docs {
+		dir := ""
+		if !doc.IsFolder() {
+			dir = s.routeFor(doc)
+		}
+		if dir == "" || dir == mdDir || (s.trackedPath(doc.UUID) == "" && existing[strings.ToLower(s.docFilename(mdDir, doc.Title)+ext)]) {
+			kept = append(kept, doc)
+			continue
+		}
+		if s.routed == nil {
+			s.routed = make(map[string][]*scrivener.Document)
+		}
+		s.routed[dir] = append(s.routed[dir], doc)
+	}
+	return kept
+}
+
+// detectRouted syncs the routed documents with their directories. As with
+// unmapped documents, new markdown files there are never created in
+// Scrivener, as there is no folder to put them in.
+func (s *Syncer) detectRouted(plan *Plan) error {
+	seen := make(map[string]bool)
+	for _, route := range s.config.Options.Routes {
+		dir := s.routeDir(route)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		mdFiles, err := s.getMarkdownFiles(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		docs := s.routed[dir]
+		var known []string
+		for _, mdPath := range mdFiles {
+			if s.state.WasPreviouslySynced(mdPath) || s.matchTitle(docs, s.scrivenerAlias(s.titleFor(filepath.Base(mdPath)))) != nil {
+				known = append(known, mdPath)
+			}
+		}
+		label, err := filepath.Rel(s.config.MarkdownPath(), dir)
+		if err != nil {
+			label = dir
+		}
+		if err := s.detectChangesInDir(dir, filepath.ToSlash(label)+"/", docs, known, plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package sync
+
+import "fmt"
+
+// SetSafeMode makes runs never delete anything, as --safe does, whatever
+// options.safe_mode says.
+func (s *Syncer) SetSafeMode(safe bool) {
+	s.safeMode = safe
+	for _, linked := range s.linked {
+		linked.safeMode = safe
+	}
+}
+
+// safe reports whether the run is in safe mode (options.safe_mode or
+// --safe): markdown files are archived rather than trashed or deleted,
+// documents are never moved to Scrivener's Trash, and conflicts overwrite
+// a side only when the user picks it.
+func (s *Syncer) safe() bool {
+	return s.safeMode || s.config.Options.SafeMode
+}
+
+// safeOrphanAction returns the action safe mode takes on an orphan instead
+// of action, noting what was held back in s.kept. Deleting a markdown file
+// archives it; deleting a Scrivener document is skipped.
+func (s *Syncer) safeOrphanAction(orphan Orphan, action DeletionAction) DeletionAction {
+	if !s.safe() || action != ActionDelete {
+		return action
+	}
+	if orphan.Location == "markdown" {
+		switch s.config.Options.DeletionStyle {
+		case "hard":
+			s.keep(fmt.Sprintf("archived %s instead of deleting it", orphan.Path))
+		case "trash":
+			s.keep(fmt.Sprintf("archived %s instead of moving it to the trash", orphan.Path))
+		}
+		return action
+	}
+	s.keep(fmt.Sprintf("kept '%s' out of Scrivener's Trash", orphan.Title))
+	return ActionSkip
+}
+
+// safeResolution returns the resolution safe mode takes on a conflict that
+// a rule or the default resolved without asking: both versions are kept
+// rather than one overwriting the other.
+func (s *Syncer) safeResolution(conflict Conflict, resolution string) string {
+	if !s.safe() {
+		return resolution
+	}
+	switch resolution {
+	case "markdown":
+		s.keep(fmt.Sprintf("kept both versions of %s instead of overwriting Scrivener's", conflict.MarkdownPath))
+	case "scrivener":
+		s.keep(fmt.Sprintf("kept both versions of %s instead of overwriting it", conflict.MarkdownPath))
+	default:
+		return resolution
+	}
+	return "skip"
+}
+
+// keep notes something safe mode held back in the run.
+func (s *Syncer) keep(note string) {
+	s.kept = append(s.kept, note)
+}
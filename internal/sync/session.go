@@ -0,0 +1,52 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+)
+
+// addSessionWords counts the words written in markdown and pushed to a
+// document towards the session: the words it has after the push less those
+// it had before.
+func (s *Syncer) addSessionWords(summary *Summary, uuid string, before, after int) {
+	n := after - before
+	summary.SessionWords += n
+	if s.writer.InDraft(uuid) {
+		summary.sessionDraft += n
+	} else {
+		summary.sessionOther += n
+	}
+}
+
+// recordSession adds the session's words to today's entry in Scrivener's
+// writing history, with options.session_history.
+func (s *Syncer) recordSession(summary *Summary) error {
+	if !s.config.Options.SessionHistory || (summary.sessionDraft == 0 && summary.sessionOther == 0) {
+		return nil
+	}
+	if err := s.writer.AddWrittenWords(time.Now().Format("2006-01-02"), summary.sessionDraft, summary.sessionOther); err != nil {
+		return fmt.Errorf("failed to record the session in Scrivener's writing history: %w", err)
+	}
+	return nil
+}
+
+// sessionMessage describes the words written in markdown since the last
+// sync, e.g. "You wrote 1,243 words since last sync", or "" if none were.
+func sessionMessage(words int) string {
+	switch {
+	case words > 0:
+		return fmt.Sprintf("You wrote %s words since last sync", formatCount(words))
+	case words < 0:
+		return fmt.Sprintf("You cut %s words since last sync", formatCount(-words))
+	}
+	return ""
+}
+
+// formatCount formats a count with thousands separators, e.g. "1,243".
+func formatCount(n int) string {
+	digits := fmt.Sprint(n)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
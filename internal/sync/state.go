@@ -3,12 +3,15 @@ package sync
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/timestamp"
+	"github.com/sweiss/harcroft/internal/vault"
 )
 
 // State tracks the sync state between markdown files and Scrivener documents.
@@ -16,18 +19,47 @@ type State struct {
 	LastSync      *time.Time           `json:"last_sync"`
 	Files         map[string]FileState `json:"files"`
 	ScrivPath     string               `json:"scriv_path"`
+	ProjectID     string               `json:"project_id,omitempty"` // Identifier of the Scrivener project
 	DeletedFiles  map[string]FileState `json:"deleted_files,omitempty"`
 	ConfigVersion string               `json:"config_version"`
-
-	filePath string
+	WordCounts    map[string]int       `json:"word_counts,omitempty"` // markdown words per day (YYYY-MM-DD), at that day's last sync
+	Ignored       map[string]string    `json:"ignored,omitempty"`     // markdown path -> UUID of its document ("" if none), for files whose front matter disables syncing
+	Warned        map[string]bool      `json:"warned,omitempty"`      // warnings given once, such as duplicate titles in a folder
+	Version       int                  `json:"version"`               // incremented on every save
+
+	filePath      string
+	loadedVersion int  // Version of the file when it was loaded
+	encrypt       bool // Save seals the file; see encrypt_state
 }
 
+// ErrStateModified is returned by State.Save when another process saved the
+// state file after this State was loaded.
+var ErrStateModified = errors.New("sync state was modified by another process")
+
 // FileState represents the sync state of a single file.
 type FileState struct {
 	ScrivUUID    string `json:"scriv_uuid"`
 	ContentHash  string `json:"content_hash"`
 	ModifiedTime string `json:"modified_time"`
 	LastSynced   string `json:"last_synced"`
+
+	// ScrivHash caches the hash of the Scrivener document, valid while the
+	// document's stamp (content file time, binder Modified attribute and
+	// metadata) is still ScrivStamp, so unchanged documents aren't converted.
+	ScrivStamp string `json:"scriv_stamp,omitempty"`
+	ScrivHash  string `json:"scriv_hash,omitempty"`
+
+	// MdHash and MdWords cache the hash and word count of the markdown file,
+	// valid while its size and modification time are still MdStamp.
+	MdStamp string `json:"md_stamp,omitempty"`
+	MdHash  string `json:"md_hash,omitempty"`
+	MdWords int    `json:"md_words,omitempty"`
+
+	// BodyHash and MetaHash split ContentHash into the hashes of the body
+	// and of the managed front matter, so a push of a file whose body is
+	// unchanged only updates the document's metadata.
+	BodyHash string `json:"body_hash,omitempty"`
+	MetaHash string `json:"meta_hash,omitempty"`
 }
 
 // ConflictType represents the type of conflict detected during sync.
@@ -46,9 +78,26 @@ const (
 	ConflictNewFile ConflictType = "new_file"
 )
 
-// LoadState reads the state file from the given path.
+// LoadState reads the state file from the given path. The file is locked
+// while it is read so a concurrent save is never seen half-written.
 func LoadState(path string) (*State, error) {
-	data, err := os.ReadFile(path)
+	if _, err := os.Stat(filepath.Dir(path)); os.IsNotExist(err) {
+		return NewState(path), nil
+	}
+
+	release, err := lockPath(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return readState(path)
+}
+
+// readState reads and parses the state file without locking it, decrypting
+// it if it is sealed.
+func readState(path string) (*State, error) {
+	data, err := vault.Read(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return NewState(path), nil
@@ -62,6 +111,7 @@ func LoadState(path string) (*State, error) {
 	}
 
 	state.filePath = path
+	state.loadedVersion = state.Version
 
 	// Initialize maps if nil
 	if state.Files == nil {
@@ -70,6 +120,9 @@ func LoadState(path string) (*State, error) {
 	if state.DeletedFiles == nil {
 		state.DeletedFiles = make(map[string]FileState)
 	}
+	if state.Ignored == nil {
+		state.Ignored = make(map[string]string)
+	}
 
 	return state, nil
 }
@@ -79,6 +132,7 @@ func NewState(path string) *State {
 	return &State{
 		Files:        make(map[string]FileState),
 		DeletedFiles: make(map[string]FileState),
+		Ignored:      make(map[string]string),
 		filePath:     path,
 	}
 }
@@ -99,31 +153,62 @@ func LoadStateForAlias(alias string) (*State, error) {
 	return LoadState(statePath)
 }
 
-// Save writes the state to its file.
+// Save writes the state to its file. The file is locked for the duration,
+// and if another process saved it since this State was loaded, Save refuses
+// to overwrite its records and returns ErrStateModified.
 func (s *State) Save() error {
 	if s.filePath == "" {
 		return fmt.Errorf("state file path not set")
 	}
 
+	release, err := lockPath(s.filePath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	current, err := readState(s.filePath)
+	if err != nil {
+		return err
+	}
+	if current.Version != s.loadedVersion {
+		return fmt.Errorf("%w (version %d, loaded %d); run the sync again", ErrStateModified, current.Version, s.loadedVersion)
+	}
+
+	s.Version = s.loadedVersion + 1
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
+		s.Version = s.loadedVersion
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
+	if data, err = vault.Encode(data, s.encrypt); err != nil {
+		s.Version = s.loadedVersion
+		return fmt.Errorf("failed to encrypt state: %w", err)
+	}
 
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
+	// Write to a temp file and rename so readers never see a partial file
+	tmpPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		s.Version = s.loadedVersion
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.filePath); err != nil {
+		os.Remove(tmpPath)
+		s.Version = s.loadedVersion
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
+	s.loadedVersion = s.Version
 	return nil
 }
 
 // RecordFile records the sync state for a file.
 func (s *State) RecordFile(mdPath, scrivUUID, hash string, modified time.Time) {
-	now := time.Now().Format(time.RFC3339)
+	now := timestamp.Format(time.Now())
 	s.Files[mdPath] = FileState{
 		ScrivUUID:    scrivUUID,
 		ContentHash:  hash,
-		ModifiedTime: modified.Format(time.RFC3339),
+		ModifiedTime: timestamp.Format(modified),
 		LastSynced:   now,
 	}
 
@@ -139,6 +224,53 @@ func (s *State) RemoveFile(mdPath string) {
 	}
 }
 
+// Ignore excludes a markdown file from syncing, together with the document
+// it was synced with (uuid, "" if none). The file stops being tracked, so
+// neither side is treated as an orphan. It reports whether anything changed.
+func (s *State) Ignore(mdPath, uuid string) bool {
+	if old, ok := s.Ignored[mdPath]; ok && (old == uuid || uuid == "") {
+		return false
+	}
+	s.Ignored[mdPath] = uuid
+	delete(s.Files, mdPath)
+	delete(s.DeletedFiles, mdPath)
+	return true
+}
+
+// WarnOnce records that the warning with key was given, reporting whether
+// it is the first time.
+func (s *State) WarnOnce(key string) bool {
+	if s.Warned[key] {
+		return false
+	}
+	if s.Warned == nil {
+		s.Warned = make(map[string]bool)
+	}
+	s.Warned[key] = true
+	return true
+}
+
+// Unignore syncs a markdown file excluded by Ignore again, as a file never
+// synced before. It reports whether the file was ignored.
+func (s *State) Unignore(mdPath string) bool {
+	if _, ok := s.Ignored[mdPath]; !ok {
+		return false
+	}
+	delete(s.Ignored, mdPath)
+	return true
+}
+
+// IgnoredUUID reports whether a Scrivener document belongs to an ignored
+// markdown file.
+func (s *State) IgnoredUUID(uuid string) bool {
+	for _, id := range s.Ignored {
+		if id != "" && id == uuid {
+			return true
+		}
+	}
+	return false
+}
+
 // GetFileState returns the state for a file, or nil if not tracked.
 func (s *State) GetFileState(mdPath string) *FileState {
 	if fs, exists := s.Files[mdPath]; exists {
@@ -174,6 +306,11 @@ func (s *State) DetectConflict(mdPath, mdHash, scrivUUID, scrivHash string) Conf
 		return ConflictNewFile
 	}
 
+	// Identical content on both sides needs no sync, whatever was recorded
+	if mdHash == scrivHash {
+		return ConflictNone
+	}
+
 	mdChanged := fs.ContentHash != mdHash
 	scrivChanged := fs.ContentHash != scrivHash
 
@@ -195,12 +332,55 @@ func (s *State) SetScrivPath(path string) {
 	s.ScrivPath = path
 }
 
+// RecordWordCount records the markdown word count for today.
+func (s *State) RecordWordCount(words int) {
+	if s.WordCounts == nil {
+		s.WordCounts = make(map[string]int)
+	}
+	s.WordCounts[time.Now().Format("2006-01-02")] = words
+}
+
 // UpdateLastSync updates the last sync timestamp to now.
 func (s *State) UpdateLastSync() {
 	now := time.Now()
 	s.LastSync = &now
 }
 
+// RecordScrivenerHash caches the hash of the Scrivener document synced to a
+// tracked markdown path, with the stamp it is valid for. It reports whether
+// the cache changed.
+func (s *State) RecordScrivenerHash(mdPath, stamp, hash string) bool {
+	fs, ok := s.Files[mdPath]
+	if !ok || (fs.ScrivStamp == stamp && fs.ScrivHash == hash) {
+		return false
+	}
+	fs.ScrivStamp, fs.ScrivHash = stamp, hash
+	s.Files[mdPath] = fs
+	return true
+}
+
+// RecordPartHashes records the body and front matter hashes of a file's
+// synced content, after RecordFile.
+func (s *State) RecordPartHashes(mdPath, bodyHash, metaHash string) {
+	if fs, ok := s.Files[mdPath]; ok {
+		fs.BodyHash, fs.MetaHash = bodyHash, metaHash
+		s.Files[mdPath] = fs
+	}
+}
+
+// RecordMarkdownHash caches the hash and word count of a tracked markdown
+// file, with the stamp they are valid for. It reports whether the cache
+// changed.
+func (s *State) RecordMarkdownHash(mdPath, stamp, hash string, words int) bool {
+	fs, ok := s.Files[mdPath]
+	if !ok || stamp == "" || (fs.MdStamp == stamp && fs.MdHash == hash && fs.MdWords == words) {
+		return false
+	}
+	fs.MdStamp, fs.MdHash, fs.MdWords = stamp, hash, words
+	s.Files[mdPath] = fs
+	return true
+}
+
 // GetUUIDForPath returns the Scrivener UUID for a markdown path, or empty string if not found.
 func (s *State) GetUUIDForPath(mdPath string) string {
 	if fs := s.GetFileState(mdPath); fs != nil {
@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -180,6 +181,17 @@ func TestState_DetectConflict_Both(t *testing.T) {
 	}
 }
 
+func TestState_DetectConflict_IdenticalContent(t *testing.T) {
+	state := NewState("/tmp/test.json")
+	state.RecordFile("/test/file.md", "UUID-ABC", "oldhash", time.Now())
+
+	// Both sides differ from the recorded hash but agree with each other
+	conflict := state.DetectConflict("/test/file.md", "newhash", "UUID-ABC", "newhash")
+	if conflict != ConflictNone {
+		t.Errorf("Expected ConflictNone, got %s", conflict)
+	}
+}
+
 func TestState_GetUUIDForPath(t *testing.T) {
 	state := NewState("/tmp/test.json")
 	state.RecordFile("/test/file.md", "UUID-123", "hash", time.Now())
@@ -289,3 +301,45 @@ func TestState_RecordFileRemovesFromDeleted(t *testing.T) {
 		t.Error("File should be removed from DeletedFiles")
 	}
 }
+
+func TestState_SaveRefusesConcurrentModification(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "test-state.json")
+
+	if err := NewState(statePath).Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	first, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	second, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	first.RecordFile("/path/a.md", "UUID-A", "hashA", time.Now())
+	if err := first.Save(); err != nil {
+		t.Fatalf("First save should succeed: %v", err)
+	}
+	// Saving again from the same State is not a conflict
+	if err := first.Save(); err != nil {
+		t.Fatalf("Repeated save should succeed: %v", err)
+	}
+
+	second.RecordFile("/path/b.md", "UUID-B", "hashB", time.Now())
+	if err := second.Save(); !errors.Is(err, ErrStateModified) {
+		t.Fatalf("Expected ErrStateModified, got %v", err)
+	}
+
+	loaded, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if loaded.Version != 3 {
+		t.Errorf("Expected version 3, got %d", loaded.Version)
+	}
+	if loaded.GetFileState("/path/a.md") == nil || loaded.GetFileState("/path/b.md") != nil {
+		t.Error("Expected only the first writer's records to be saved")
+	}
+}
@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// DailyStats combines Scrivener's writing history for a day with the
+// markdown word count recorded by the day's last sync.
+type DailyStats struct {
+	Date            string `json:"date"`
+	DraftWords      int    `json:"scrivener_draft_words"`      // written in Scrivener's Draft folder
+	OtherWords      int    `json:"scrivener_other_words"`      // written elsewhere in the project
+	MarkdownWords   *int   `json:"markdown_words,omitempty"`   // total markdown words at the day's last sync
+	MarkdownWritten *int   `json:"markdown_written,omitempty"` // change since the previous day with a sync
+}
+
+// countWords counts the words in markdown, ignoring tokens without letters
+// or digits such as list markers and heading hashes.
+func countWords(text string) int {
+	n := 0
+	for _, field := range strings.Fields(text) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// markdownWords returns the total word count of the tracked markdown files.
+func (s *Syncer) markdownWords() int {
+	total := 0
+	for _, path := range s.state.AllTrackedPaths() {
+		total += s.fileWords(path)
+	}
+	return total
+}
+
+// fileWords returns the word count of a markdown file, from the cache while
+// the file is unchanged.
+func (s *Syncer) fileWords(path string) int {
+	words, ok := s.mdWords[path]
+	if fs, unchanged := s.cachedMarkdown(path); !ok && unchanged {
+		words, ok = fs.MdWords, true
+	}
+	if !ok && fileExists(path) {
+		if _, err := s.readMarkdownFile(path); err == nil {
+			words = s.mdWords[path]
+		}
+	}
+	return words
+}
+
+// Stats returns daily writing statistics, oldest first, merging Scrivener's
+// writing history with the markdown word counts recorded at sync time.
+func (s *Syncer) Stats() ([]DailyStats, error) {
+	history, err := s.reader.WritingHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string]*DailyStats)
+	for _, day := range history {
+		byDate[day.Date] = &DailyStats{Date: day.Date, DraftWords: day.DraftWords, OtherWords: day.OtherWords}
+	}
+
+	dates := make([]string, 0, len(s.state.WordCounts))
+	for date := range s.state.WordCounts {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for i, date := range dates {
+		words := s.state.WordCounts[date]
+		day, ok := byDate[date]
+		if !ok {
+			day = &DailyStats{Date: date}
+			byDate[date] = day
+		}
+		day.MarkdownWords = &words
+		if i > 0 {
+			written := words - s.state.WordCounts[dates[i-1]]
+			day.MarkdownWritten = &written
+		}
+	}
+
+	stats := make([]DailyStats, 0, len(byDate))
+	for _, day := range byDate {
+		stats = append(stats, *day)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Date < stats[j].Date })
+	return stats, nil
+}
+
+// PrintStats prints daily writing statistics as a table, followed by progress
+// towards the project targets, or as JSON for external dashboards. days limits
+// the output to the most recent days; 0 prints them all. Each Scrivener
+// project of the alias is printed in turn.
+func (s *Syncer) PrintStats(asJSON bool, days int) error {
+	return s.each(func(p *Syncer) error { return p.printProjectStats(asJSON, days) })
+}
+
+// printProjectStats prints the statistics of a single Scrivener project.
+func (s *Syncer) printProjectStats(asJSON bool, days int) error {
+	stats, err := s.Stats()
+	if err != nil {
+		return err
+	}
+	if days > 0 && len(stats) > days {
+		stats = stats[len(stats)-days:]
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal stats: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(stats) == 0 {
+		fmt.Println("No writing history yet.")
+		return s.printTargetProgress(stats, time.Now())
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "DATE\tDRAFT\tOTHER\tMARKDOWN\tWRITTEN\t")
+	for _, day := range stats {
+		markdown, written := "-", "-"
+		if day.MarkdownWords != nil {
+			markdown = fmt.Sprintf("%d", *day.MarkdownWords)
+		}
+		if day.MarkdownWritten != nil {
+			written = fmt.Sprintf("%+d", *day.MarkdownWritten)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t\n", day.Date, day.DraftWords, day.OtherWords, markdown, written)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return s.printTargetProgress(stats, time.Now())
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// contentStore holds the document content collected in a plan. Content is
+// kept in memory until the budget is used up; after that each piece is
+// written to its own temporary file and read back when it is needed.
+type contentStore struct {
+	budget int64 // bytes kept in memory; 0 keeps everything in memory
+	used   int64
+	dir    string // temp directory, created on the first spill
+	files  int
+}
+
+// newContentStore creates a store that keeps up to budgetMB megabytes of
+// content in memory.
+func newContentStore(budgetMB int) *contentStore {
+	return &contentStore{budget: int64(budgetMB) << 20}
+}
+
+// put stores content and returns what to keep in the plan: the content
+// itself, or "" and the path of the temp file holding it. Content that
+// cannot be spilled stays in memory.
+func (c *contentStore) put(content string) (string, string) {
+	if c == nil || c.budget == 0 || c.used+int64(len(content)) <= c.budget {
+		if c != nil {
+			c.used += int64(len(content))
+		}
+		return content, ""
+	}
+
+	path, err := c.spill(content)
+	if err != nil {
+		fmt.Printf("Warning: keeping content in memory: %v\n", err)
+		c.used += int64(len(content))
+		return content, ""
+	}
+	return "", path
+}
+
+// spill writes content to a new temp file.
+func (c *contentStore) spill(content string) (string, error) {
+	if c.dir == "" {
+		dir, err := os.MkdirTemp("", "scriv-sync-plan-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		c.dir = dir
+	}
+	c.files++
+	path := filepath.Join(c.dir, fmt.Sprintf("%06d.md", c.files))
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return path, nil
+}
+
+// close removes the temp files.
+func (c *contentStore) close() error {
+	if c == nil || c.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.dir)
+	c.dir = ""
+	return err
+}
+
+// loadContent returns stored content: inline if it was kept in memory,
+// otherwise read from its temp file.
+func loadContent(inline, spillPath string) (string, error) {
+	if spillPath == "" {
+		return inline, nil
+	}
+	data, err := os.ReadFile(spillPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read spilled content: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary totals what one run of a plan did, printed at its end and written
+// as JSON with --summary-out.
+type Summary struct {
+	Alias              string         `json:"alias"`
+	Project            string         `json:"project,omitempty"` // scriv_projects entry; empty for scriv_path
+	Started            time.Time      `json:"started"`
+	ElapsedSeconds     float64        `json:"elapsed_seconds"`
+	CreatedInScrivener int            `json:"created_in_scrivener"`
+	UpdatedInScrivener int            `json:"updated_in_scrivener"`
+	CreatedInMarkdown  int            `json:"created_in_markdown"`
+	UpdatedInMarkdown  int            `json:"updated_in_markdown"`
+	Conflicts          map[string]int `json:"conflicts"` // resolution -> count
+	Orphans            map[string]int `json:"orphans"`   // action -> count
+	WordsAdded         int            `json:"words_added"`
+	WordsRemoved       int            `json:"words_removed"`
+	Failed             int            `json:"failed,omitempty"` // operations that failed and are retried next sync
+	Kept               []string       `json:"kept,omitempty"`   // removals and overwrites safe mode held back
+	SessionWords       int            `json:"session_words"`    // net words written in markdown and pushed
+
+	sessionDraft int // SessionWords written in the Draft folder
+	sessionOther int // SessionWords written elsewhere
+}
+
+// newSummary starts the summary of a run starting now.
+func newSummary(alias, project string) *Summary {
+	return &Summary{
+		Alias:     alias,
+		Project:   project,
+		Started:   time.Now(),
+		Conflicts: make(map[string]int),
+		Orphans:   make(map[string]int),
+	}
+}
+
+// addWords counts the words added or removed by replacing text of before
+// words with text of after words.
+func (sm *Summary) addWords(before, after int) {
+	if after > before {
+		sm.WordsAdded += after - before
+	} else {
+		sm.WordsRemoved += before - after
+	}
+}
+
+// finish records the run's elapsed time.
+func (sm *Summary) finish() {
+	sm.ElapsedSeconds = time.Since(sm.Started).Seconds()
+}
+
+// Fprint prints the summary block to w.
+func (sm *Summary) Fprint(w io.Writer) {
+	fmt.Fprintln(w, "\nSummary")
+	fmt.Fprintf(w, "  Scrivener:  %d created, %d updated\n", sm.CreatedInScrivener, sm.UpdatedInScrivener)
+	fmt.Fprintf(w, "  Markdown:   %d created, %d updated\n", sm.CreatedInMarkdown, sm.UpdatedInMarkdown)
+	if len(sm.Conflicts) > 0 {
+		fmt.Fprintf(w, "  Conflicts:  %s\n", countsList(sm.Conflicts))
+	}
+	if len(sm.Orphans) > 0 {
+		fmt.Fprintf(w, "  Orphans:    %s\n", countsList(sm.Orphans))
+	}
+	if sm.Failed > 0 {
+		fmt.Fprintf(w, "  Failed:     %d\n", sm.Failed)
+	}
+	if len(sm.Kept) > 0 {
+		fmt.Fprintf(w, "  Safe mode:  %d removal(s) or overwrite(s) held back\n", len(sm.Kept))
+		for _, note := range sm.Kept {
+			fmt.Fprintf(w, "    - %s\n", note)
+		}
+	}
+	fmt.Fprintf(w, "  Words:      +%d / -%d\n", sm.WordsAdded, sm.WordsRemoved)
+	fmt.Fprintf(w, "  Elapsed:    %s\n", time.Duration(sm.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond))
+	if message := sessionMessage(sm.SessionWords); message != "" {
+		fmt.Fprintf(w, "\n%s\n", message)
+	}
+}
+
+// countsList renders counts by name as "2 markdown, 1 skip".
+func countsList(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", counts[name], name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SetSummaryOutput makes Sync, Pull, Push and Apply write the summary of
+// each run as JSON to path. The summary for each further Scrivener project
+// goes next to it, named after the project (summary.json ->
+// summary.novel.json).
+func (s *Syncer) SetSummaryOutput(path string) {
+	s.summaryOut = path
+	for _, linked := range s.linked {
+		linked.summaryOut = ""
+		if path != "" {
+			ext := filepath.Ext(path)
+			linked.summaryOut = strings.TrimSuffix(path, ext) + "." + linked.project + ext
+		}
+	}
+}
+
+// write saves the summary as JSON.
+func (sm *Summary) write(path string) error {
+	data, err := json.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	return nil
+}
+
+// markdownWordsBefore returns the word count of a markdown file about to be
+// overwritten, or 0 if it doesn't exist yet.
+func markdownWordsBefore(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	text, _ := decodeText(data)
+	return countWords(text)
+}
+
+// scrivenerWordsBefore returns the word count of a Scrivener document about
+// to be overwritten.
+func (s *Syncer) scrivenerWordsBefore(uuid string) int {
+	doc, err := s.reader.GetDocumentByUUID(uuid)
+	if err != nil || doc == nil {
+		return 0
+	}
+	if err := s.reader.LoadContent(doc); err != nil {
+		return 0
+	}
+	return countWords(doc.Content)
+}
@@ -4,14 +4,19 @@ import (
 	"bufio"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/convert"
+	"github.com/sweiss/harcroft/internal/rtf"
 	"github.com/sweiss/harcroft/internal/scrivener"
+	"github.com/sweiss/harcroft/internal/timestamp"
 )
 
 // Syncer handles bi-directional sync between markdown and Scrivener.
@@ -24,6 +29,99 @@ type Syncer struct {
 	mdRoot    string
 	scrivPath string
 	alias     string
+	project   string // name of the Scrivener project in scriv_projects; "" for scriv_path
+
+	// linked holds a Syncer for each further Scrivener project of the alias
+	// (scriv_projects), each with its own reader, writer and state.
+	linked []*Syncer
+
+	// mismatch is the identity check result when it was not enforced.
+	mismatch error
+
+	// resume makes executePlan continue the journal of an interrupted run.
+	resume bool
+
+	// journal records the progress of the plan being executed.
+	journal *Journal
+
+	// planOut, when set, is where Sync, Pull and Push write their plan as JSON.
+	planOut string
+
+	// summaryOut, when set, is where each run writes its summary as JSON.
+	summaryOut string
+
+	// summary totals the last plan executed.
+	summary *Summary
+
+	// assumeYes runs a project's first sync without asking; see
+	// confirmFirstSync.
+	assumeYes bool
+
+	// allowTruncation applies updates that empty or drastically shrink a
+	// document without asking; see confirmTruncations.
+	allowTruncation bool
+
+	// safeMode never deletes anything, as options.safe_mode does; see safe.
+	safeMode bool
+
+	// kept lists what safe mode held back in the plan being executed.
+	kept []string
+
+	// events receives the events of executing plans; nil prints them.
+	events EventHandler
+
+	// explain makes Status list the reason and evidence of each operation.
+	explain bool
+
+	// input is where confirmations are read from; nil for standard input.
+	input io.Reader
+
+	// folderForDir maps markdown directories to the Scrivener folder path
+	// they sync with, as discovered during change detection.
+	folderForDir map[string]string
+
+	// sectionForDir maps markdown directories to the section type their
+	// mapping assigns to new documents.
+	sectionForDir map[string]string
+
+	// titlesForDir maps markdown directories to their mapping's title rules.
+	titlesForDir map[string]config.TitleRules
+
+	// plainDocs records the documents syncing with plain text files.
+	plainDocs map[string]bool
+
+	// mdEncodings records the on-disk encoding of markdown files read this run.
+	mdEncodings map[string]textEncoding
+
+	// mdWords records the word count of markdown files read or written this run.
+	mdWords map[string]int
+
+	// sidecars holds the RTF to keep beside markdown files in rtf_passthrough
+	// mode, by sidecar path, until the Scrivener project is saved.
+	sidecars map[string]string
+
+	// rebaselined is set when stale hashes of unchanged files or cached
+	// Scrivener hashes were updated in the state, which afterSync then saves.
+	rebaselined bool
+
+	// uuidPaths maps tracked Scrivener UUIDs to markdown paths, for the hash
+	// cache and routes; it is rebuilt for each scan.
+	uuidPaths map[string]string
+
+	// fullScan ignores the hash caches.
+	fullScan bool
+
+	// mirroredDirs holds the real paths of the markdown directories mirrored
+	// in this scan, so symlinked directories can't make it loop.
+	mirroredDirs map[string]bool
+
+	// routed holds the documents taken out of their mappings by routes,
+	// by route directory; it is rebuilt for each scan.
+	routed map[string][]*scrivener.Document
+
+	// history holds the operations applied by this sync, with who applied
+	// them, until they are appended to the vault's history file.
+	history []HistoryEntry
 }
 
 // NewSyncerForAlias creates a new Syncer for the given project alias.
@@ -37,79 +135,299 @@ func NewSyncerForAlias(alias string) (*Syncer, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, issue := range globalCfg.Issues(alias) {
+		if issue.Severity == config.IssueWarning {
+			fmt.Printf("  Warning: config %s\n", issue)
+		}
+	}
+
+	if projCfg.Remote != nil {
+		if err := fetchRemote(projCfg, alias); err != nil {
+			return nil, err
+		}
+	}
+
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return nil, err
+	}
+	if errs := projCfg.Validate(); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return nil, fmt.Errorf("invalid config for project '%s': %s (run 'scriv-sync config validate' for details)", alias, strings.Join(msgs, "; "))
+	}
 
 	return NewSyncer(projCfg, alias)
 }
 
-// NewSyncer creates a new Syncer from the given project configuration.
+// NewSyncer creates a new Syncer from the given project configuration. It
+// returns a *ProjectMismatchError if the sync state belongs to a different
+// copy of the Scrivener project.
 func NewSyncer(cfg *config.ProjectConfig, alias string) (*Syncer, error) {
-	scrivPath, err := cfg.ScrivenerPath()
+	return newSyncer(cfg, alias, true)
+}
+
+// newSyncer creates a Syncer for the alias's scriv_path project, linked to
+// one for each of its scriv_projects, checking that each state belongs to
+// its Scrivener project when checkIdentity is set.
+func newSyncer(cfg *config.ProjectConfig, alias string, checkIdentity bool) (*Syncer, error) {
+	if err := cfg.CheckProjectMappings(); err != nil {
+		return nil, err
+	}
+
+	s, err := openProject(cfg, alias, "", checkIdentity)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range cfg.ScrivProjectNames() {
+		linked, err := openProject(cfg, alias, name, checkIdentity)
+		if err != nil {
+			return nil, fmt.Errorf("Scrivener project '%s': %w", name, err)
+		}
+		s.linked = append(s.linked, linked)
+	}
+	return s, nil
+}
+
+// openProject creates a Syncer for one of an alias's Scrivener projects.
+func openProject(cfg *config.ProjectConfig, alias, project string, checkIdentity bool) (*Syncer, error) {
+	scrivPath, err := cfg.ScrivProjectPath(project)
 	if err != nil {
 		return nil, err
 	}
 
 	mdRoot := cfg.MarkdownPath()
 
-	reader, err := scrivener.NewReader(scrivPath)
+	reader, err := newReader(scrivPath, cfg.Options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+		return nil, err
 	}
 
 	writer, err := scrivener.NewWriter(scrivPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open Scrivener project for writing: %w", err)
 	}
+	rtfConverter, err := convert.New(cfg.Options.Converter, outputOptions(cfg.Options, reader))
+	if err != nil {
+		return nil, err
+	}
+	writer.SetConverter("rtf", rtfConverter)
 
-	state, err := LoadStateForAlias(alias)
+	state, err := LoadStateForAlias(config.StateName(alias, project))
 	if err != nil {
 		return nil, fmt.Errorf("failed to load sync state: %w", err)
 	}
+	state.encrypt = cfg.Options.EncryptState
+
+	s := &Syncer{
+		config:        cfg,
+		state:         state,
+		reader:        reader,
+		writer:        writer,
+		mdRoot:        mdRoot,
+		scrivPath:     scrivPath,
+		alias:         alias,
+		project:       project,
+		folderForDir:  make(map[string]string),
+		sectionForDir: make(map[string]string),
+		mdEncodings:   make(map[string]textEncoding),
+		mdWords:       make(map[string]int),
+	}
+
+	reader.SetSkipContent(s.skipUnchanged)
+
+	s.mismatch = s.checkProjectIdentity()
+	if checkIdentity && s.mismatch != nil {
+		return nil, s.mismatch
+	}
 	state.SetScrivPath(scrivPath)
+	state.ProjectID = reader.Identifier()
+
+	return s, nil
+}
+
+// each runs fn for this Syncer and then for each linked Scrivener project,
+// announcing the project before its output when there are several.
+func (s *Syncer) each(fn func(*Syncer) error) error {
+	if err := fn(s); err != nil {
+		return err
+	}
+	for _, linked := range s.linked {
+		fmt.Printf("\n== Scrivener project '%s' (%s) ==\n", linked.project, linked.scrivPath)
+		if err := fn(linked); err != nil {
+			return fmt.Errorf("Scrivener project '%s': %w", linked.project, err)
+		}
+	}
+	return nil
+}
+
+// stateName returns the name of this Syncer's state and report directory.
+func (s *Syncer) stateName() string {
+	return config.StateName(s.alias, s.project)
+}
+
+// newReader opens a Scrivener project for reading, converting RTF with the
+// configured converter and, with pandoc_imports, imported DOCX and ODT
+// documents with pandoc.
+func newReader(scrivPath string, opts config.Options) (*scrivener.Reader, error) {
+	reader, err := scrivener.NewReaderWithOptions(scrivPath, conversionOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+	}
+	rtfConverter, err := convert.New(opts.Converter, conversionOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	reader.SetConverter("rtf", cachedConverter(rtfConverter, opts))
+
+	if opts.PandocImports {
+		pandoc, err := convert.NewPandoc("")
+		if err != nil {
+			fmt.Printf("Note: %v; imported DOCX and ODT documents are skipped\n", err)
+		} else {
+			reader.SetConverter("docx", cachedConverter(pandoc.ForFormat("docx"), opts))
+			reader.SetConverter("odt", cachedConverter(pandoc.ForFormat("odt"), opts))
+		}
+	}
+	return reader, nil
+}
+
+// cachedConverter wraps a converter with the conversion cache, so the
+// documents unchanged since a status or dry run aren't converted again.
+// With encrypt_state nothing is cached, as entries hold document text.
+func cachedConverter(c convert.Converter, opts config.Options) convert.Converter {
+	if opts.ConversionCache == config.ConversionCacheOff || opts.EncryptState {
+		return c
+	}
+	dir, err := config.ConversionCacheDir()
+	if err != nil {
+		return c
+	}
+	return convert.NewCached(c, dir)
+}
 
-	return &Syncer{
-		config:    cfg,
-		state:     state,
-		reader:    reader,
-		writer:    writer,
-		mdRoot:    mdRoot,
-		scrivPath: scrivPath,
-		alias:     alias,
-	}, nil
+// conversionOptions maps project options to RTF conversion options.
+func conversionOptions(opts config.Options) rtf.Options {
+	convert := rtf.DefaultOptions()
+	if opts.Underline != "" {
+		convert.Underline = opts.Underline
+	}
+	convert.Revisions = opts.Revisions
+	return convert
+}
+
+// outputOptions returns the conversion options for pushed documents. The
+// configured RTF style wins; with match_existing, unset fields are taken from
+// an existing document in the project before falling back to the defaults.
+func outputOptions(opts config.Options, reader *scrivener.Reader) rtf.Options {
+	convert := conversionOptions(opts)
+
+	style := opts.RTF
+	t := rtf.Template{
+		Font:            style.Font,
+		FontSize:        style.FontSize,
+		HeadingSizes:    style.HeadingSizes,
+		FirstLineIndent: style.FirstLineIndent,
+	}
+	if style.MatchExisting {
+		if sample, ok := reader.SampleDocumentRTF(); ok {
+			t = t.Merge(rtf.DetectTemplate(sample))
+		}
+	}
+	convert.Template = t.Merge(rtf.DefaultTemplate())
+	convert.Patch = style.Patch
+
+	return convert
 }
 
 // Sync performs bi-directional sync.
 func (s *Syncer) Sync(dryRun, interactive bool) error {
+	if err := s.checkEnabled(); err != nil {
+		return err
+	}
+	err := s.each(func(p *Syncer) error { return p.syncProject(dryRun, interactive) })
+	return s.uploadRemote(dryRun, err)
+}
+
+// syncProject runs Sync for a single Scrivener project.
+func (s *Syncer) syncProject(dryRun, interactive bool) error {
+	s.warnIntegrity()
+	if err := s.offerUnmappedDirs(interactive && !dryRun); err != nil {
+		return err
+	}
 	plan, err := s.detectAllChanges()
 	if err != nil {
 		return err
 	}
+	defer plan.Close()
 
 	if plan.IsEmpty() {
 		fmt.Println("Everything is in sync!")
-		return nil
+		plan.printLocked()
+		if dryRun {
+			return nil
+		}
+		return s.afterSync(true, true)
 	}
 
 	plan.PrintStatus()
 
+	if s.planOut != "" {
+		if err := s.writePlanFile(plan, s.planOut); err != nil {
+			return err
+		}
+	}
+
 	if dryRun {
 		fmt.Println("\n(dry-run mode - no changes applied)")
 		return nil
 	}
 
-	return s.executePlan(plan, interactive)
+	if err := s.confirmFirstSync(plan); err != nil {
+		return err
+	}
+	if err := s.confirmTruncations(plan); err != nil {
+		return err
+	}
+	if err := s.confirmImpact(plan); err != nil {
+		return err
+	}
+	if err := s.executePlan(plan, interactive); err != nil {
+		return err
+	}
+	return s.afterSync(true, true)
 }
 
 // Pull syncs from Scrivener to markdown.
 func (s *Syncer) Pull(dryRun, interactive bool) error {
+	if err := s.checkEnabled(); err != nil {
+		return err
+	}
+	err := s.each(func(p *Syncer) error { return p.pullProject(dryRun, interactive) })
+	return s.uploadRemote(dryRun, err)
+}
+
+// pullProject runs Pull for a single Scrivener project.
+func (s *Syncer) pullProject(dryRun, interactive bool) error {
+	s.warnIntegrity()
 	plan, err := s.detectAllChanges()
 	if err != nil {
 		return err
 	}
+	defer plan.Close()
 
 	// Filter plan to only Scrivener -> markdown changes
 	pullPlan := NewPlan()
 	pullPlan.ToCreateInMarkdown = plan.ToCreateInMarkdown
 	pullPlan.ToUpdateInMarkdown = plan.ToUpdateInMarkdown
+	pullPlan.Locked = plan.lockedFor("markdown")
+	for _, t := range plan.Truncations {
+		if t.Location == "markdown" {
+			pullPlan.Truncations = append(pullPlan.Truncations, t)
+		}
+	}
 	// Include orphans that exist in markdown but not Scrivener
 	for _, o := range plan.Orphans {
 		if o.Location == "markdown" {
@@ -119,30 +437,73 @@ func (s *Syncer) Pull(dryRun, interactive bool) error {
 
 	if pullPlan.IsEmpty() {
 		fmt.Println("No changes to pull from Scrivener.")
-		return nil
+		pullPlan.printLocked()
+		if dryRun {
+			return nil
+		}
+		return s.afterSync(true, false)
 	}
 
 	pullPlan.PrintStatus()
 
+	if s.planOut != "" {
+		if err := s.writePlanFile(pullPlan, s.planOut); err != nil {
+			return err
+		}
+	}
+
 	if dryRun {
 		fmt.Println("\n(dry-run mode - no changes applied)")
 		return nil
 	}
 
-	return s.executePlan(pullPlan, interactive)
+	if err := s.confirmFirstSync(pullPlan); err != nil {
+		return err
+	}
+	if err := s.confirmTruncations(pullPlan); err != nil {
+		return err
+	}
+	if err := s.confirmImpact(pullPlan); err != nil {
+		return err
+	}
+	if err := s.executePlan(pullPlan, interactive); err != nil {
+		return err
+	}
+	return s.afterSync(true, false)
 }
 
 // Push syncs from markdown to Scrivener.
 func (s *Syncer) Push(dryRun, interactive bool) error {
+	if err := s.checkEnabled(); err != nil {
+		return err
+	}
+	err := s.each(func(p *Syncer) error { return p.pushProject(dryRun, interactive) })
+	return s.uploadRemote(dryRun, err)
+}
+
+// pushProject runs Push for a single Scrivener project.
+func (s *Syncer) pushProject(dryRun, interactive bool) error {
+	s.warnIntegrity()
+	if err := s.offerUnmappedDirs(interactive && !dryRun); err != nil {
+		return err
+	}
 	plan, err := s.detectAllChanges()
 	if err != nil {
 		return err
 	}
+	defer plan.Close()
 
 	// Filter plan to only markdown -> Scrivener changes
 	pushPlan := NewPlan()
 	pushPlan.ToCreateInScriv = plan.ToCreateInScriv
 	pushPlan.ToUpdateInScriv = plan.ToUpdateInScriv
+	pushPlan.BudgetWarnings = plan.BudgetWarnings
+	pushPlan.Locked = plan.lockedFor("scrivener")
+	for _, t := range plan.Truncations {
+		if t.Location == "scrivener" {
+			pushPlan.Truncations = append(pushPlan.Truncations, t)
+		}
+	}
 	// Include orphans that exist in Scrivener but not markdown
 	for _, o := range plan.Orphans {
 		if o.Location == "scrivener" {
@@ -152,26 +513,63 @@ func (s *Syncer) Push(dryRun, interactive bool) error {
 
 	if pushPlan.IsEmpty() {
 		fmt.Println("No changes to push to Scrivener.")
-		return nil
+		pushPlan.printLocked()
+		if dryRun {
+			return nil
+		}
+		return s.afterSync(false, true)
 	}
 
 	pushPlan.PrintStatus()
 
+	if s.planOut != "" {
+		if err := s.writePlanFile(pushPlan, s.planOut); err != nil {
+			return err
+		}
+	}
+
 	if dryRun {
 		fmt.Println("\n(dry-run mode - no changes applied)")
 		return nil
 	}
 
-	return s.executePlan(pushPlan, interactive)
+	if err := s.confirmFirstSync(pushPlan); err != nil {
+		return err
+	}
+	if err := s.confirmTruncations(pushPlan); err != nil {
+		return err
+	}
+	if err := s.confirmImpact(pushPlan); err != nil {
+		return err
+	}
+	if err := s.executePlan(pushPlan, interactive); err != nil {
+		return err
+	}
+	return s.afterSync(false, true)
+}
+
+// Status shows the current sync status without making changes. With
+// porcelain it is written in the stable line format of Plan.WritePorcelain.
+func (s *Syncer) Status(porcelain bool) error {
+	return s.each(func(p *Syncer) error { return p.statusProject(porcelain) })
 }
 
-// Status shows the current sync status without making changes.
-func (s *Syncer) Status() error {
+// statusProject runs Status for a single Scrivener project.
+func (s *Syncer) statusProject(porcelain bool) error {
+	if !porcelain {
+		s.warnIntegrity()
+	}
 	plan, err := s.detectAllChanges()
 	if err != nil {
 		return err
 	}
+	defer plan.Close()
 
+	if porcelain {
+		plan.WritePorcelain(os.Stdout)
+		return nil
+	}
+	plan.explain = s.explain
 	plan.PrintStatus()
 	return nil
 }
@@ -179,25 +577,55 @@ func (s *Syncer) Status() error {
 // detectAllChanges scans both sides and creates a sync plan.
 func (s *Syncer) detectAllChanges() (*Plan, error) {
 	plan := NewPlan()
+	plan.store = newContentStore(s.config.Options.MemoryBudgetMB)
+	s.uuidPaths = nil
+	s.mirroredDirs = make(map[string]bool)
+	s.routed = nil
 
-	for _, mapping := range s.config.EnabledMappings() {
+	for _, mapping := range s.config.MappingsForProject(s.project) {
 		if err := s.detectChangesForMapping(mapping, plan); err != nil {
+			plan.Close()
 			return nil, err
 		}
 	}
+	if err := s.detectUnmapped(plan); err != nil {
+		plan.Close()
+		return nil, err
+	}
+	if err := s.detectRouted(plan); err != nil {
+		plan.Close()
+		return nil, err
+	}
 
 	// Detect orphans (files that were synced before but now missing from one side)
 	s.detectOrphans(plan)
+	s.sortPlan(plan)
+	s.markQuarantined(plan)
+	if err := s.checkBudgets(plan); err != nil {
+		plan.Close()
+		return nil, err
+	}
+	truncations, err := s.findTruncations(plan)
+	if err != nil {
+		plan.Close()
+		return nil, err
+	}
+	plan.Truncations = truncations
+	plan.authors = s.lastChanges()
 
 	return plan, nil
 }
 
 // detectChangesForMapping detects changes for a single folder mapping.
 func (s *Syncer) detectChangesForMapping(mapping config.FolderMapping, plan *Plan) error {
-	mdDir := filepath.Join(s.mdRoot, mapping.MarkdownDir)
+	mdDir := s.config.MappingDir(mapping)
+
+	if mapping.IsRecursive() {
+		return s.detectChangesForSubtree(mapping, mdDir, plan)
+	}
 
 	// Get Scrivener folder
-	scrivFolder, err := s.reader.FindFolderByTitle(mapping.ScrivenerFolder)
+	scrivFolder, err := s.reader.FindFolderByPath(mapping.ScrivenerFolder)
 	if err != nil {
 		// Folder doesn't exist in Scrivener
 		if s.config.Options.CreateMissingFolders {
@@ -216,72 +644,383 @@ func (s *Syncer) detectChangesForMapping(mapping config.FolderMapping, plan *Pla
 	// Get Scrivener documents
 	var scrivDocs []*scrivener.Document
 	if scrivFolder != nil {
-		scrivDocs = scrivFolder.Children
+		scrivDocs = excludeItems(mapping, scrivFolder.Children)
+	}
+
+	s.folderForDir[mdDir] = mapping.ScrivenerFolder
+	s.sectionForDir[mdDir] = mapping.SectionType
+	s.setTitleRules(mdDir, mapping.Titles)
+	if err := s.detectChangesInDir(mdDir, mapping.ScrivenerFolder, scrivDocs, mdFiles, plan); err != nil {
+		return err
+	}
+	s.detectFolderNote(mdDir, scrivFolder, plan)
+	return nil
+}
+
+// detectChangesForSubtree detects changes for a recursive mapping, mirroring
+// each Scrivener folder in the subtree as a markdown directory.
+func (s *Syncer) detectChangesForSubtree(mapping config.FolderMapping, mdDir string, plan *Plan) error {
+	var scrivDocs []*scrivener.Document
+	var folder *scrivener.Document
+	folderPath := ""
+
+	if mapping.ScrivenerFolder == "/" {
+		docs, err := s.reader.GetBinderStructure()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if !doc.IsTrash() {
+				scrivDocs = append(scrivDocs, doc)
+			}
+		}
+	} else {
+		scrivFolder, err := s.reader.FindFolderByPath(mapping.ScrivenerFolder)
+		if err != nil && !s.config.Options.CreateMissingFolders {
+			return fmt.Errorf("Scrivener folder '%s' not found", mapping.ScrivenerFolder)
+		}
+		if scrivFolder != nil {
+			scrivDocs = scrivFolder.Children
+		}
+		folder = scrivFolder
+		folderPath = mapping.ScrivenerFolder
+		if !strings.HasPrefix(folderPath, "/") {
+			folderPath = "/" + folderPath
+		}
+	}
+
+	return s.mirrorFolder(mapping, mdDir, folderPath, folder, scrivDocs, plan)
+}
+
+// mirrorFolder detects changes between one markdown directory and one
+// Scrivener folder, then recurses into subfolders and subdirectories.
+// folderPath is the anchored binder path ("" for the binder root); folder is
+// the Scrivener folder, or nil for the binder root or a folder not yet
+// created.
+func (s *Syncer) mirrorFolder(mapping config.FolderMapping, mdDir, folderPath string, folder *scrivener.Document, scrivDocs []*scrivener.Document, plan *Plan) error {
+	if real, err := filepath.EvalSymlinks(mdDir); err == nil {
+		if s.mirroredDirs[real] {
+			fmt.Printf("  Warning: skipping %s, a symlink back to a directory already synced\n", mdDir)
+			return nil
+		}
+		s.mirroredDirs[real] = true
+	}
+	mdFiles, subdirs, err := s.listMarkdownDir(mdDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	scrivDocs = excludeItems(mapping, scrivDocs)
+
+	s.folderForDir[mdDir] = folderPath
+	s.sectionForDir[mdDir] = mapping.SectionType
+	s.setTitleRules(mdDir, mapping.Titles)
+	label := folderPath
+	if label == "" {
+		label = "/"
+	}
+	if err := s.detectChangesInDir(mdDir, label, scrivDocs, mdFiles, plan); err != nil {
+		return err
+	}
+	s.detectFolderNote(mdDir, folder, plan)
+
+	// Recurse into Scrivener folders, pairing each with its markdown directory
+	seen := make(map[string]bool)
+	for _, doc := range scrivDocs {
+		if !doc.IsFolder() || doc.IsTrash() {
+			continue
+		}
+		dirName := s.docFilename(mdDir, doc.Title)
+		for _, d := range subdirs {
+			if strings.EqualFold(d, doc.Title) || strings.EqualFold(d, dirName) {
+				dirName = d
+				break
+			}
+		}
+		seen[strings.ToLower(dirName)] = true
+		if err := s.mirrorFolder(mapping, filepath.Join(mdDir, dirName), folderPath+"/"+doc.Title, doc, doc.Children, plan); err != nil {
+			return err
+		}
+	}
+
+	// Markdown directories without a Scrivener folder yet
+	for _, d := range subdirs {
+		if seen[strings.ToLower(d)] || mapping.ExcludesTitle(d) || mapping.ExcludesTitle(s.titleFor(d)) {
+			continue
+		}
+		if s.unmappedDir() == filepath.Join(mdDir, d) || s.isRouteDir(filepath.Join(mdDir, d)) {
+			continue // holds unmapped or routed documents, not a new folder
+		}
+		if err := s.mirrorFolder(mapping, filepath.Join(mdDir, d), folderPath+"/"+s.docTitle(mdDir, d), nil, nil, plan); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// excludeItems drops Scrivener items whose titles match the mapping's
+// exclude_folders patterns; an excluded folder takes its contents with it.
+func excludeItems(mapping config.FolderMapping, docs []*scrivener.Document) []*scrivener.Document {
+	if len(mapping.ExcludeFolders) == 0 {
+		return docs
+	}
+	var kept []*scrivener.Document
+	for _, doc := range docs {
+		if !mapping.ExcludesTitle(doc.Title) {
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
+
+// convertedItems drops documents whose content is in a format without a
+// converter, such as imported DOCX files when pandoc_imports is off.
+func convertedItems(docs []*scrivener.Document) []*scrivener.Document {
+	var kept []*scrivener.Document
+	for _, doc := range docs {
+		if !doc.Unconverted {
+			kept = append(kept, doc)
+		}
 	}
+	return kept
+}
 
-	// Build lookup maps
-	mdFileMap := make(map[string]string) // title -> path
-	for _, path := range mdFiles {
-		title := titleFromFilename(filepath.Base(path))
-		mdFileMap[strings.ToLower(title)] = path
+// detectChangesInDir compares markdown files against the documents of one
+// Scrivener folder and adds the resulting operations to the plan.
+func (s *Syncer) detectChangesInDir(mdDir, folderLabel string, scrivDocs []*scrivener.Document, mdFiles []string, plan *Plan) error {
+	// PDFs, images, web archives and other items with no text are left alone
+	scrivDocs, unsupported := splitUnsupported(scrivDocs)
+	plan.addUnsupported(unsupported)
+	scrivDocs = s.routeDocs(mdDir, convertedItems(scrivDocs), mdFiles)
+	s.markPlainText(mdDir, scrivDocs)
+
+	// Detect documents sharing a title, which would otherwise collapse into one file
+	if err := s.checkDuplicateTitles(folderLabel, scrivDocs); err != nil {
+		return err
 	}
 
-	scrivDocMap := make(map[string]*scrivener.Document) // title -> doc
+	// Assign each Scrivener document the markdown path it syncs to
+	docPaths := s.assignMarkdownPaths(mdDir, scrivDocs)
+	docByPath := make(map[string]*scrivener.Document, len(docPaths))
 	for _, doc := range scrivDocs {
-		if !doc.IsFolder() {
-			scrivDocMap[strings.ToLower(doc.Title)] = doc
+		if path, ok := docPaths[doc.UUID]; ok {
+			docByPath[path] = doc
 		}
 	}
+	claimed := make(map[string]bool) // UUID -> matched to a markdown file
+
+	// Files whose names differ only in case are one file on a
+	// case-insensitive filesystem, so none of them is synced
+	collisions := caseCollisions(mdFiles)
+	existing := make(map[string]string, len(mdFiles)) // lowercased path -> path
+	for _, mdPath := range mdFiles {
+		existing[strings.ToLower(mdPath)] = mdPath
+	}
 
 	// Check each markdown file
 	for _, mdPath := range mdFiles {
-		title := titleFromFilename(filepath.Base(mdPath))
-		lowerTitle := strings.ToLower(title)
+		title := s.docTitle(mdDir, filepath.Base(mdPath))
 
-		mdContent, err := os.ReadFile(mdPath)
-		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", mdPath, err)
+		scrivDoc := docByPath[mdPath]
+		if scrivDoc == nil {
+			scrivDoc = s.matchTitle(scrivDocs, title)
+		}
+		if other, ok := collisions[mdPath]; ok {
+			plan.Skipped = append(plan.Skipped, SkippedFile{Path: mdPath, Reason: caseCollisionReason(other)})
+			if scrivDoc != nil {
+				claimed[scrivDoc.UUID] = true
+			}
+			continue
+		}
+
+		// A file unchanged since the last sync is read only if its content
+		// is needed
+		var mdContent, mdHash string
+		cached, unchanged := s.cachedMarkdown(mdPath)
+		if s.guardFile(mdPath, !unchanged, plan) {
+			if scrivDoc != nil {
+				claimed[scrivDoc.UUID] = true // never pulled over the skipped file
+			}
+			continue
+		}
+		readContent := func() error {
+			content, err := s.readMarkdownFile(mdPath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", mdPath, err)
+			}
+			mdContent, mdHash = content, s.contentHash(content)
+			return nil
+		}
+		// A file that can't be read, even after retrying, is skipped with
+		// its document rather than failing the whole sync
+		skipUnreadable := func(err error) {
+			plan.Skipped = append(plan.Skipped, SkippedFile{Path: mdPath, Reason: fmt.Sprintf("unreadable: %v", errors.Unwrap(err))})
+			if scrivDoc != nil {
+				claimed[scrivDoc.UUID] = true
+			}
+		}
+		if unchanged {
+			mdHash = cached.MdHash
+		} else if err := readContent(); err != nil {
+			skipUnreadable(err)
+			continue
+		}
+
+		// A file whose front matter disables syncing is left alone, with
+		// its document; an unchanged file hasn't gained the flag since the
+		// last sync
+		if !unchanged && syncDisabled(mdContent) {
+			uuid := s.state.Ignored[mdPath]
+			if scrivDoc != nil {
+				uuid = scrivDoc.UUID
+				claimed[uuid] = true
+			} else if fs := s.state.GetFileState(mdPath); fs != nil {
+				uuid = fs.ScrivUUID
+			}
+			if s.state.Ignore(mdPath, uuid) {
+				s.rebaselined = true
+			}
+			plan.Skipped = append(plan.Skipped, SkippedFile{Path: mdPath, Reason: "syncing disabled in its front matter"})
+			continue
+		}
+		if s.state.Unignore(mdPath) {
+			s.rebaselined = true
 		}
-		mdHash := computeHash(string(mdContent))
 
-		scrivDoc := scrivDocMap[lowerTitle]
 		if scrivDoc == nil {
 			// Markdown file exists, Scrivener doc doesn't
 			if !s.state.WasPreviouslySynced(mdPath) {
-				plan.AddCreateInScriv(mdPath, title, string(mdContent))
+				if doc := s.matchTitle(unsupported, title); doc != nil {
+					plan.Skipped = append(plan.Skipped, SkippedFile{Path: mdPath, Reason: unsupportedReason(doc)})
+					continue
+				}
+				if unchanged {
+					if err := readContent(); err != nil {
+						skipUnreadable(err)
+						continue
+					}
+				}
+				plan.AddCreateInScriv(mdPath, title, mdContent)
+				plan.ToCreateInScriv[len(plan.ToCreateInScriv)-1].Provenance = s.provenance(ReasonNewFile, mdPath, mdHash, nil, "")
 			}
 			// If was previously synced, it will be handled as orphan
 		} else {
 			// Both exist - check for changes
-			scrivHash := scrivDoc.ContentHash()
+			scrivHash := s.docHash(scrivDoc)
 			conflict := s.state.DetectConflict(mdPath, mdHash, scrivDoc.UUID, scrivHash)
 
+			if scrivDoc.IsReadOnly() && conflict != ConflictScrivenerOnly && conflict != ConflictNone {
+				// Imported DOCX/ODT documents are never written back
+				fmt.Printf("  Warning: '%s' is an imported %s file in Scrivener; markdown edits to %s are not pushed\n",
+					scrivDoc.Title, strings.ToUpper(scrivDoc.ContentFormat), mdPath)
+				conflict = ConflictNone
+			}
+
+			if scrivDoc.NoContent && !scrivDoc.IsFolder() && (conflict == ConflictScrivenerOnly || conflict == ConflictBoth || conflict == ConflictNewFile) {
+				// A missing content file is not an emptied document, so it
+				// never overwrites markdown that has text
+				if unchanged {
+					if err := readContent(); err != nil {
+						skipUnreadable(err)
+						continue
+					}
+					unchanged = false
+				}
+				if strings.TrimSpace(mdContent) != "" {
+					plan.MissingContent = append(plan.MissingContent, FileChange{MarkdownPath: mdPath, ScrivUUID: scrivDoc.UUID, Title: scrivDoc.Title})
+					if conflict == ConflictScrivenerOnly {
+						conflict = ConflictNone
+					} else {
+						conflict = ConflictMarkdownOnly
+					}
+				}
+			}
+
+			if unchanged && (conflict == ConflictNewFile || conflict == ConflictMarkdownOnly || conflict == ConflictBoth) {
+				if err := readContent(); err != nil {
+					skipUnreadable(err)
+					continue
+				}
+			}
+
+			// Locked documents are never changed by the sync; the change is
+			// listed as refused instead, and stays pending until unlocked
+			if conflict != ConflictNone {
+				if unchanged && mdContent == "" {
+					if err := readContent(); err != nil {
+						skipUnreadable(err)
+						continue
+					}
+				}
+				if by := s.lockedBy(mdContent, scrivDoc); by != "" {
+					change, location := lockedChange(conflict)
+					plan.refuseLocked(mdPath, scrivDoc.UUID, title, change, location, by)
+					conflict = ConflictNone
+				}
+			}
+
+			var why Provenance
+			if conflict != ConflictNone {
+				why = s.provenance(s.changeReason(mdPath, conflict), mdPath, mdHash, scrivDoc, scrivHash)
+			}
 			switch conflict {
 			case ConflictNewFile:
 				// New file on both sides with same title - treat as conflict
-				plan.AddConflict(mdPath, scrivDoc.UUID, title, string(mdContent), scrivDoc.Content)
+				plan.AddConflict(mdPath, scrivDoc.UUID, title, mdContent, s.docContent(scrivDoc))
+				plan.Conflicts[len(plan.Conflicts)-1].Provenance = why
 			case ConflictMarkdownOnly:
-				plan.AddUpdateInScriv(mdPath, scrivDoc.UUID, title, string(mdContent))
+				plan.AddUpdateInScriv(mdPath, scrivDoc.UUID, title, mdContent)
+				plan.ToUpdateInScriv[len(plan.ToUpdateInScriv)-1].Provenance = why
 			case ConflictScrivenerOnly:
-				plan.AddUpdateInMarkdown(mdPath, scrivDoc.UUID, title, scrivDoc.Content)
+				plan.AddUpdateInMarkdown(mdPath, scrivDoc.UUID, title, s.docContent(scrivDoc))
+				plan.ToUpdateInMarkdown[len(plan.ToUpdateInMarkdown)-1].Provenance = why
 			case ConflictBoth:
-				plan.AddConflict(mdPath, scrivDoc.UUID, title, string(mdContent), scrivDoc.Content)
+				plan.AddConflict(mdPath, scrivDoc.UUID, title, mdContent, s.docContent(scrivDoc))
+				plan.Conflicts[len(plan.Conflicts)-1].Provenance = why
 			case ConflictNone:
-				// No changes needed
+				// No changes needed, but a baseline recorded from content
+				// in another form is brought up to date
+				if fs := s.state.GetFileState(mdPath); mdHash == scrivHash && (fs == nil || fs.ContentHash != mdHash) {
+					s.state.RecordFile(mdPath, scrivDoc.UUID, mdHash, time.Now())
+					s.rebaselined = true
+				}
+			}
+			s.cacheScrivenerHash(mdPath, scrivDoc, scrivHash)
+			if !unchanged {
+				s.cacheMarkdownHash(mdPath, mdHash)
 			}
 
-			delete(scrivDocMap, lowerTitle)
+			claimed[scrivDoc.UUID] = true
 		}
 	}
 
-	// Remaining Scrivener docs don't have matching markdown files
-	for _, doc := range scrivDocMap {
-		if doc.IsFolder() {
+	// Remaining Scrivener docs don't have matching markdown files; those of
+	// ignored files are left alone even once the file is gone
+	for _, doc := range scrivDocs {
+		if doc.IsFolder() || claimed[doc.UUID] || s.state.IgnoredUUID(doc.UUID) {
+			continue
+		}
+		mdPath := docPaths[doc.UUID]
+		if _, ignored := s.state.Ignored[mdPath]; ignored {
+			continue
+		}
+		if s.isFolderNote(mdPath) {
+			plan.Skipped = append(plan.Skipped, SkippedFile{Path: mdPath, Reason: "named like the folder note; rename the document"})
 			continue
 		}
-		mdPath := filepath.Join(mdDir, sanitizeFilename(doc.Title)+".md")
 		if !s.state.WasPreviouslySynced(mdPath) {
-			plan.AddCreateInMarkdown(mdPath, doc.UUID, doc.Title, doc.Content)
+			if isCloudPlaceholder(mdPath) {
+				plan.skipPlaceholder(mdPath)
+				continue
+			}
+			if other, ok := existing[strings.ToLower(mdPath)]; ok && other != mdPath {
+				// Writing it would overwrite other on a case-insensitive filesystem
+				plan.Skipped = append(plan.Skipped, SkippedFile{Path: mdPath, Reason: caseCollisionReason(other)})
+				continue
+			}
+			content := s.docContent(doc)
+			plan.AddCreateInMarkdown(mdPath, doc.UUID, doc.Title, content)
+			plan.ToCreateInMarkdown[len(plan.ToCreateInMarkdown)-1].Provenance = s.provenance(ReasonNewFile, mdPath, "", doc, s.contentHash(content))
 		}
 		// If was previously synced, it will be handled as orphan
 	}
@@ -292,31 +1031,54 @@ func (s *Syncer) detectChangesForMapping(mapping config.FolderMapping, plan *Pla
 // detectOrphans finds files that were previously synced but now exist only on one side.
 func (s *Syncer) detectOrphans(plan *Plan) {
 	for _, mdPath := range s.state.AllTrackedPaths() {
-		// Check if markdown file still exists
+		// Check if markdown file still exists; one evicted to the cloud
+		// is not deleted
 		mdExists := fileExists(mdPath)
+		if !mdExists && isCloudPlaceholder(mdPath) {
+			plan.skipPlaceholder(mdPath)
+			continue
+		}
 
 		// Check if Scrivener doc still exists
 		uuid := s.state.GetUUIDForPath(mdPath)
-		scrivExists := s.scrivDocExists(uuid)
+		scrivExists := s.scrivDocExists(uuid) || (s.isFolderNote(mdPath) && s.reader.HasItem(uuid))
 
 		if mdExists && !scrivExists {
 			// Markdown exists, Scrivener deleted
+			if content, err := s.readMarkdownFile(mdPath); err == nil && frontMatterLocked(content) {
+				plan.refuseLocked(mdPath, uuid, s.docTitle(filepath.Dir(mdPath), filepath.Base(mdPath)), "deletion in markdown", "markdown", "front matter")
+				continue
+			}
 			fs := s.state.GetFileState(mdPath)
 			var lastSync time.Time
 			if fs != nil {
-				lastSync, _ = time.Parse(time.RFC3339, fs.LastSynced)
+				lastSync, _ = timestamp.Parse(fs.LastSynced)
 			}
-			plan.AddOrphan(mdPath, "markdown", uuid, titleFromFilename(filepath.Base(mdPath)), lastSync)
+			plan.AddOrphan(mdPath, "markdown", uuid, s.docTitle(filepath.Dir(mdPath), filepath.Base(mdPath)), lastSync)
+			plan.Orphans[len(plan.Orphans)-1].Provenance = s.provenance(ReasonDeletedInScrivener, mdPath, "", nil, "")
 		} else if !mdExists && scrivExists {
-			// Markdown deleted, Scrivener exists
+			// Markdown deleted, Scrivener exists; a deleted folder note
+			// never deletes its folder
+			if s.isFolderNote(mdPath) {
+				continue
+			}
+			doc, _ := s.reader.GetDocumentByUUID(uuid)
+			if doc != nil && doc.Unsupported {
+				continue // never synced, so never deleted
+			}
+			if by := s.lockedBy("", doc); by != "" {
+				plan.refuseLocked(mdPath, uuid, doc.Title, "deletion in Scrivener", "scrivener", by)
+				continue
+			}
 			fs := s.state.GetFileState(mdPath)
 			var lastSync time.Time
 			var title string
 			if fs != nil {
-				lastSync, _ = time.Parse(time.RFC3339, fs.LastSynced)
-				title = titleFromFilename(filepath.Base(mdPath))
+				lastSync, _ = timestamp.Parse(fs.LastSynced)
+				title = s.docTitle(filepath.Dir(mdPath), filepath.Base(mdPath))
 			}
 			plan.AddOrphan(mdPath, "scrivener", uuid, title, lastSync)
+			plan.Orphans[len(plan.Orphans)-1].Provenance = s.provenance(ReasonDeletedInMarkdown, mdPath, "", doc, "")
 		} else if !mdExists && !scrivExists {
 			// Both deleted - just clean up state
 			s.state.RemoveFile(mdPath)
@@ -329,137 +1091,331 @@ func (s *Syncer) scrivDocExists(uuid string) bool {
 	if uuid == "" {
 		return false
 	}
-	docs, err := s.reader.GetAllDocuments()
-	if err != nil {
-		return false
-	}
-	for _, doc := range docs {
-		if doc.UUID == uuid {
-			return true
+	return s.reader.HasDocument(uuid)
+}
+
+// executePlan executes the sync plan, emitting an event as each operation
+// starts and completes.
+func (s *Syncer) executePlan(plan *Plan, interactive bool) (err error) {
+	report := NewReport(s.stateName())
+	report.encrypt = s.config.Options.EncryptState
+	summary := newSummary(s.alias, s.project)
+	s.kept = nil
+	s.sidecars = nil
+	defer func() {
+		var partial *FailedOperationsError
+		if err != nil && !errors.As(err, &partial) {
+			s.emit(Event{Kind: EventError, Err: err})
 		}
+	}()
+	if err := s.checkIntegrity(plan); err != nil {
+		return err
+	}
+	if err := s.startJournal(plan); err != nil {
+		return err
+	}
+	index, total := 0, plan.operationCount()
+	started := func(op Operation, path, title, uuid, message string) {
+		index++
+		s.emit(Event{Kind: OperationStarted, Operation: op, Path: path, Title: title, ScrivUUID: uuid, Message: message, Index: index, Total: total})
+	}
+	completed := func(op Operation, path, title, uuid, detail, message string) {
+		s.emit(Event{Kind: OperationCompleted, Operation: op, Path: path, Title: title, ScrivUUID: uuid, Detail: detail, Message: message, Index: index, Total: total})
+		s.recordHistory(op, path, title, uuid, detail)
+	}
+	// An operation that fails is listed and the rest of the plan still
+	// runs; its file stays out of the state, so the next sync retries it
+	var failures []Failure
+	failed := func(op Operation, path, title, uuid string, err error) {
+		failures = append(failures, Failure{Operation: op, Path: path, Title: title, Err: err})
+		report.Add("failed: "+string(op), path, title, err.Error())
+		s.emit(Event{Kind: OperationFailed, Operation: op, Path: path, Title: title, ScrivUUID: uuid, Message: fmt.Sprintf("Failed: %s: %v", path, err), Index: index, Total: total, Err: err})
 	}
-	return false
-}
 
-// executePlan executes the sync plan.
-func (s *Syncer) executePlan(plan *Plan, interactive bool) error {
 	// Handle conflicts first
 	for _, conflict := range plan.Conflicts {
-		resolution, err := s.resolveConflict(conflict, interactive)
-		if err != nil {
-			return err
-		}
-
-		switch resolution {
-		case "markdown":
-			// Use markdown content
-			if err := s.writer.UpdateDocumentContent(conflict.ScrivUUID, conflict.MarkdownContent, true); err != nil {
+		s.emit(Event{Kind: ConflictEncountered, Operation: OpConflict, Path: conflict.MarkdownPath, Title: conflict.Title, ScrivUUID: conflict.ScrivUUID, Index: index + 1, Total: total})
+		// Versions differing only in whitespace need no decision
+		whitespaceSide, whitespace := s.whitespaceResolution(conflict)
+		resolution, ok := s.journal.conflictChoice(conflict)
+		if !ok {
+			var err error
+			if whitespace {
+				s.emit(Event{Kind: EventInfo, Operation: OpConflict, Path: conflict.MarkdownPath, Title: conflict.Title, ScrivUUID: conflict.ScrivUUID, Message: fmt.Sprintf("Whitespace-only conflict resolves %s: %s", conflict.MarkdownPath, whitespaceSide), Index: index + 1, Total: total})
+				resolution = whitespaceSide
+			} else if resolution, err = s.resolveConflict(conflict, interactive); err != nil {
 				return err
 			}
-			s.recordSync(conflict.MarkdownPath, conflict.ScrivUUID, conflict.MarkdownContent)
-		case "scrivener":
-			// Use Scrivener content
-			if err := os.WriteFile(conflict.MarkdownPath, []byte(conflict.ScrivenerContent), 0644); err != nil {
+			if err := s.journal.recordConflict(conflict, resolution); err != nil {
 				return err
 			}
-			s.recordSync(conflict.MarkdownPath, conflict.ScrivUUID, conflict.ScrivenerContent)
-		case "skip":
-			fmt.Printf("  Skipped conflict: %s\n", conflict.MarkdownPath)
 		}
+		started(OpConflict, conflict.MarkdownPath, conflict.Title, conflict.ScrivUUID, "")
+		message, err := s.applyResolution(conflict, resolution, summary)
+		if err != nil {
+			failed(OpConflict, conflict.MarkdownPath, conflict.Title, conflict.ScrivUUID, err)
+			continue
+		}
+		if whitespace && resolution == whitespaceSide {
+			report.Add(string(OpConflict), conflict.MarkdownPath, conflict.Title, autoResolvedWhitespace+": "+resolution)
+			summary.Conflicts[autoResolvedWhitespace]++
+		} else {
+			report.Add(string(OpConflict), conflict.MarkdownPath, conflict.Title, "resolved: "+resolution)
+			summary.Conflicts[resolution]++
+		}
+		completed(OpConflict, conflict.MarkdownPath, conflict.Title, conflict.ScrivUUID, resolution, message)
 	}
 
 	// Create in Scrivener
 	for _, fc := range plan.ToCreateInScriv {
-		fmt.Printf("  Creating in Scrivener: %s\n", fc.Title)
+		started(OpCreateInScrivener, fc.MarkdownPath, fc.Title, "", "Creating in Scrivener: "+fc.Title)
+
+		content, err := fc.content()
+		if err != nil {
+			failed(OpCreateInScrivener, fc.MarkdownPath, fc.Title, "", err)
+			continue
+		}
 
 		// Find or create parent folder
 		folderUUID, err := s.ensureScrivenerFolder(fc.MarkdownPath)
 		if err != nil {
-			return err
+			failed(OpCreateInScrivener, fc.MarkdownPath, fc.Title, "", err)
+			continue
 		}
 
-		uuid, err := s.writer.CreateDocument(fc.Title, fc.Content, folderUUID, true)
+		uuid, content, err := s.createDocument(fc.Title, content, folderUUID, fc.MarkdownPath)
 		if err != nil {
-			return fmt.Errorf("failed to create document '%s': %w", fc.Title, err)
+			failed(OpCreateInScrivener, fc.MarkdownPath, fc.Title, "", fmt.Errorf("failed to create document '%s': %w", fc.Title, err))
+			continue
 		}
 
-		s.recordSync(fc.MarkdownPath, uuid, fc.Content)
+		s.recordSync(fc.MarkdownPath, uuid, content)
+		report.Add(string(OpCreateInScrivener), fc.MarkdownPath, fc.Title, uuid)
+		summary.CreatedInScrivener++
+		summary.addWords(0, countWords(content))
+		s.addSessionWords(summary, uuid, 0, countWords(content))
+		completed(OpCreateInScrivener, fc.MarkdownPath, fc.Title, uuid, "", "")
 	}
 
 	// Create in markdown
 	for _, fc := range plan.ToCreateInMarkdown {
-		fmt.Printf("  Creating in markdown: %s\n", fc.MarkdownPath)
+		started(OpCreateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, "Creating in markdown: "+fc.MarkdownPath)
+
+		content, err := fc.content()
+		if err != nil {
+			failed(OpCreateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, err)
+			continue
+		}
 
 		// Ensure directory exists
 		dir := filepath.Dir(fc.MarkdownPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		if err := withRetry(func() error { return os.MkdirAll(dir, 0755) }); err != nil {
+			failed(OpCreateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, fmt.Errorf("failed to create directory %s: %w", dir, err))
+			continue
 		}
 
-		if err := os.WriteFile(fc.MarkdownPath, []byte(fc.Content), 0644); err != nil {
-			return fmt.Errorf("failed to write %s: %w", fc.MarkdownPath, err)
+		if err := s.pullDocument(fc.MarkdownPath, fc.ScrivUUID, content); err != nil {
+			failed(OpCreateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, fmt.Errorf("failed to write %s: %w", fc.MarkdownPath, err))
+			continue
 		}
 
-		s.recordSync(fc.MarkdownPath, fc.ScrivUUID, fc.Content)
+		s.recordSync(fc.MarkdownPath, fc.ScrivUUID, content)
+		s.warnStrippedRevisions(OpCreateInMarkdown, fc)
+		report.Add(string(OpCreateInMarkdown), fc.MarkdownPath, fc.Title, fc.ScrivUUID)
+		summary.CreatedInMarkdown++
+		summary.addWords(0, countWords(content))
+		completed(OpCreateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, "", "")
 	}
 
 	// Update in Scrivener
 	for _, fc := range plan.ToUpdateInScriv {
-		fmt.Printf("  Updating in Scrivener: %s\n", fc.Title)
+		started(OpUpdateInScrivener, fc.MarkdownPath, fc.Title, fc.ScrivUUID, "Updating in Scrivener: "+fc.Title)
+
+		content, err := fc.content()
+		if err != nil {
+			failed(OpUpdateInScrivener, fc.MarkdownPath, fc.Title, fc.ScrivUUID, err)
+			continue
+		}
 
-		if err := s.writer.UpdateDocumentContent(fc.ScrivUUID, fc.Content, true); err != nil {
-			return fmt.Errorf("failed to update document '%s': %w", fc.Title, err)
+		before := s.scrivenerWordsBefore(fc.ScrivUUID)
+		metadataOnly, err := s.pushMetadata(fc.MarkdownPath, fc.ScrivUUID, content)
+		if err == nil && !metadataOnly {
+			err = s.pushDocument(fc.MarkdownPath, fc.ScrivUUID, content)
+		}
+		if err != nil {
+			failed(OpUpdateInScrivener, fc.MarkdownPath, fc.Title, fc.ScrivUUID, fmt.Errorf("failed to update document '%s': %w", fc.Title, err))
+			continue
 		}
+		summary.addWords(before, countWords(content))
+		s.addSessionWords(summary, fc.ScrivUUID, before, countWords(content))
 
-		s.recordSync(fc.MarkdownPath, fc.ScrivUUID, fc.Content)
+		s.recordSync(fc.MarkdownPath, fc.ScrivUUID, content)
+		detail := ""
+		if metadataOnly {
+			detail = "metadata only"
+		}
+		report.Add(string(OpUpdateInScrivener), fc.MarkdownPath, fc.Title, fc.ScrivUUID)
+		summary.UpdatedInScrivener++
+		completed(OpUpdateInScrivener, fc.MarkdownPath, fc.Title, fc.ScrivUUID, detail, "")
 	}
 
 	// Update in markdown
 	for _, fc := range plan.ToUpdateInMarkdown {
-		fmt.Printf("  Updating in markdown: %s\n", fc.MarkdownPath)
+		started(OpUpdateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, "Updating in markdown: "+fc.MarkdownPath)
 
-		if err := os.WriteFile(fc.MarkdownPath, []byte(fc.Content), 0644); err != nil {
-			return fmt.Errorf("failed to write %s: %w", fc.MarkdownPath, err)
+		content, err := fc.content()
+		if err != nil {
+			failed(OpUpdateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, err)
+			continue
 		}
 
-		s.recordSync(fc.MarkdownPath, fc.ScrivUUID, fc.Content)
+		before := markdownWordsBefore(fc.MarkdownPath)
+		if err := s.pullDocument(fc.MarkdownPath, fc.ScrivUUID, content); err != nil {
+			failed(OpUpdateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, fmt.Errorf("failed to write %s: %w", fc.MarkdownPath, err))
+			continue
+		}
+		summary.addWords(before, countWords(content))
+
+		s.recordSync(fc.MarkdownPath, fc.ScrivUUID, content)
+		s.warnStrippedRevisions(OpUpdateInMarkdown, fc)
+		report.Add(string(OpUpdateInMarkdown), fc.MarkdownPath, fc.Title, fc.ScrivUUID)
+		summary.UpdatedInMarkdown++
+		completed(OpUpdateInMarkdown, fc.MarkdownPath, fc.Title, fc.ScrivUUID, "", "")
 	}
 
 	// Handle orphans
 	orphanActions := make(map[string]DeletionAction)
 	for _, orphan := range plan.Orphans {
-		action := resolveOrphanAction(orphan, s.config.Options.DefaultDeletionAction, interactive)
-
 		key := orphan.Path
 		if orphan.Location == "scrivener" {
 			key = orphan.ScrivUUID
 		}
+		action, ok := s.journal.Orphans[key]
+		if !ok {
+			action = s.safeOrphanAction(orphan, resolveOrphanAction(orphan, s.config.Options.DefaultDeletionAction, interactive))
+			if err := s.journal.recordOrphan(key, action); err != nil {
+				return err
+			}
+		}
 		orphanActions[key] = action
 
-		if err := s.executeOrphanAction(orphan, action); err != nil {
-			return err
+		started(OpOrphan, orphan.Path, orphan.Title, orphan.ScrivUUID, "")
+		message, err := s.executeOrphanAction(orphan, action)
+		if err != nil {
+			failed(OpOrphan, orphan.Path, orphan.Title, orphan.ScrivUUID, err)
+			continue
 		}
+		report.Add("orphan in "+orphan.Location, orphan.Path, orphan.Title, "decision: "+string(action))
+		summary.Orphans[string(action)]++
+		completed(OpOrphan, orphan.Path, orphan.Title, orphan.ScrivUUID, string(action), message)
 	}
 
 	// Save Scrivener changes
+	if err := s.recordSession(summary); err != nil {
+		return err
+	}
 	if err := s.writer.Save(); err != nil {
 		return fmt.Errorf("failed to save Scrivener project: %w", err)
 	}
+	if err := s.writeSidecars(); err != nil {
+		return err
+	}
 
 	// Save state
+	s.state.RecordWordCount(s.markdownWords())
 	s.state.UpdateLastSync()
 	if err := s.state.Save(); err != nil {
 		return fmt.Errorf("failed to save sync state: %w", err)
 	}
+	s.journal.remove()
+	s.journal = nil
+
+	summary.Failed = len(failures)
+	summary.Kept = s.kept
+	for _, note := range s.kept {
+		report.Add("safe mode", "", "", note)
+	}
+	summary.finish()
+	s.emit(Event{Kind: SyncCompleted, Summary: summary, Failures: failures})
+	s.summary = summary
+	if s.summaryOut != "" {
+		if err := summary.write(s.summaryOut); err != nil {
+			return err
+		}
+	}
+
+	// Notify the webhook; failing to do so doesn't fail the sync
+	if err := s.notifyWebhook(summary, failures); err != nil {
+		s.emit(Event{Kind: EventWarning, Message: err.Error(), Err: err})
+	}
+
+	// Record who applied the changes; failing to do so doesn't fail the sync
+	if err := s.writeHistory(); err != nil {
+		s.emit(Event{Kind: EventWarning, Message: err.Error(), Err: err})
+	}
 
-	fmt.Println("\nSync completed successfully!")
+	// Write the audit report; failing to do so doesn't fail the sync
+	if dir, err := config.ReportsDir(s.stateName()); err == nil {
+		if path, err := report.Write(dir); err != nil {
+			s.emit(Event{Kind: EventWarning, Message: err.Error(), Err: err})
+		} else {
+			s.emit(Event{Kind: EventInfo, Path: path, Message: "Report: " + path})
+		}
+	}
+	if len(failures) > 0 {
+		return &FailedOperationsError{Failures: failures}
+	}
 	return nil
 }
 
-// resolveConflict prompts the user to resolve a conflict.
+// applyResolution applies a conflict's resolution and returns a description
+// of what it did.
+func (s *Syncer) applyResolution(conflict Conflict, resolution string, summary *Summary) (string, error) {
+	switch resolution {
+	case "markdown":
+		// Use markdown content
+		content, err := conflict.markdownContent()
+		if err != nil {
+			return "", err
+		}
+		before := s.scrivenerWordsBefore(conflict.ScrivUUID)
+		if err := s.pushDocument(conflict.MarkdownPath, conflict.ScrivUUID, content); err != nil {
+			return "", err
+		}
+		summary.addWords(before, countWords(content))
+		s.addSessionWords(summary, conflict.ScrivUUID, before, countWords(content))
+		s.recordSync(conflict.MarkdownPath, conflict.ScrivUUID, content)
+	case "scrivener":
+		// Use Scrivener content
+		content, err := conflict.scrivenerContent()
+		if err != nil {
+			return "", err
+		}
+		before := markdownWordsBefore(conflict.MarkdownPath)
+		if err := s.pullDocument(conflict.MarkdownPath, conflict.ScrivUUID, content); err != nil {
+			return "", err
+		}
+		summary.addWords(before, countWords(content))
+		s.recordSync(conflict.MarkdownPath, conflict.ScrivUUID, content)
+	case "skip":
+		dir, err := s.quarantineConflict(conflict)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Skipped conflict: %s (both versions saved in %s)", conflict.MarkdownPath, dir), nil
+	}
+	return "", nil
+}
+
+// resolveConflict decides a conflict by the first conflict rule matching it,
+// or else prompts the user (or, non-interactively, uses the default). In
+// safe mode, a rule or default overwriting a side skips the conflict instead.
 func (s *Syncer) resolveConflict(conflict Conflict, interactive bool) (string, error) {
+	if resolution, ok := s.ruleResolution(conflict); ok {
+		return s.safeResolution(conflict, resolution), nil
+	}
 	if !interactive {
-		return s.config.Options.DefaultConflictResolution, nil
+		return s.safeResolution(conflict, s.config.Options.DefaultConflictResolution), nil
 	}
 
 	reader := bufio.NewReader(os.Stdin)
@@ -495,118 +1451,238 @@ func (s *Syncer) resolveConflict(conflict Conflict, interactive bool) (string, e
 	}
 }
 
-// executeOrphanAction handles an orphan based on the chosen action.
-func (s *Syncer) executeOrphanAction(orphan Orphan, action DeletionAction) error {
+// executeOrphanAction handles an orphan based on the chosen action, and
+// returns a description of what it did.
+func (s *Syncer) executeOrphanAction(orphan Orphan, action DeletionAction) (string, error) {
+	message := ""
 	switch action {
 	case ActionDelete:
 		if orphan.Location == "markdown" {
-			// Delete the markdown file
-			fmt.Printf("  Deleting markdown file: %s\n", orphan.Path)
-			if err := os.Remove(orphan.Path); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("failed to delete %s: %w", orphan.Path, err)
+			// Delete the markdown file (archived or trashed unless style is hard)
+			result, err := s.removeMarkdownFile(orphan.Path)
+			if err != nil {
+				return "", fmt.Errorf("failed to delete %s: %w", orphan.Path, err)
 			}
+			message = fmt.Sprintf("Deleting markdown file: %s (%s)", orphan.Path, result)
 			s.state.RemoveFile(orphan.Path)
 		} else {
-			// Delete from Scrivener - this is more complex and might need additional implementation
-			fmt.Printf("  Note: Deleting from Scrivener not yet implemented. Skipping: %s\n", orphan.Title)
+			// Move the document to Scrivener's Trash, where it can be recovered
+			if err := s.writer.TrashItem(orphan.ScrivUUID); err != nil {
+				return "", fmt.Errorf("failed to trash '%s' in Scrivener: %w", orphan.Title, err)
+			}
+			message = fmt.Sprintf("Moved to Scrivener Trash: %s", orphan.Title)
+			s.state.RemoveFile(orphan.Path)
 		}
 
 	case ActionRecreate:
 		if orphan.Location == "markdown" {
 			// Recreate in Scrivener from markdown
-			content, err := os.ReadFile(orphan.Path)
+			content, err := s.readMarkdownFile(orphan.Path)
 			if err != nil {
-				return fmt.Errorf("failed to read %s: %w", orphan.Path, err)
+				return "", fmt.Errorf("failed to read %s: %w", orphan.Path, err)
 			}
 
 			folderUUID, err := s.ensureScrivenerFolder(orphan.Path)
 			if err != nil {
-				return err
+				return "", err
 			}
 
-			uuid, err := s.writer.CreateDocument(orphan.Title, string(content), folderUUID, true)
+			uuid, content, err := s.createDocument(orphan.Title, content, folderUUID, orphan.Path)
 			if err != nil {
-				return fmt.Errorf("failed to recreate document '%s': %w", orphan.Title, err)
+				return "", fmt.Errorf("failed to recreate document '%s': %w", orphan.Title, err)
 			}
 
-			fmt.Printf("  Recreated in Scrivener: %s\n", orphan.Title)
-			s.recordSync(orphan.Path, uuid, string(content))
+			message = fmt.Sprintf("Recreated in Scrivener: %s", orphan.Title)
+			s.recordSync(orphan.Path, uuid, content)
 		} else {
 			// Recreate markdown from Scrivener
-			docs, _ := s.reader.GetAllDocuments()
-			for _, doc := range docs {
-				if doc.UUID == orphan.ScrivUUID {
-					if err := os.WriteFile(orphan.Path, []byte(doc.Content), 0644); err != nil {
-						return fmt.Errorf("failed to recreate %s: %w", orphan.Path, err)
-					}
-					fmt.Printf("  Recreated markdown: %s\n", orphan.Path)
-					s.recordSync(orphan.Path, orphan.ScrivUUID, doc.Content)
-					break
+			if doc, err := s.reader.GetDocumentByUUID(orphan.ScrivUUID); err == nil && doc != nil && !doc.IsFolder() {
+				content := s.docContent(doc)
+				if err := s.writeMarkdownFile(orphan.Path, content); err != nil {
+					return "", fmt.Errorf("failed to recreate %s: %w", orphan.Path, err)
 				}
+				message = fmt.Sprintf("Recreated markdown: %s", orphan.Path)
+				s.recordSync(orphan.Path, orphan.ScrivUUID, content)
 			}
 		}
 
 	case ActionSkip:
-		fmt.Printf("  Skipped orphan: %s\n", orphan.Path)
+		message = fmt.Sprintf("Skipped orphan: %s", orphan.Path)
 	}
 
-	return nil
+	return message, nil
 }
 
 // ensureScrivenerFolder finds or creates the Scrivener folder for a markdown path.
 func (s *Syncer) ensureScrivenerFolder(mdPath string) (string, error) {
-	// Determine which mapping this path belongs to
-	relPath, err := filepath.Rel(s.mdRoot, mdPath)
-	if err != nil {
-		return "", err
+	folderPath, ok := s.scrivenerFolderPath(mdPath)
+	if !ok || folderPath == "" {
+		return "", nil // Binder root
 	}
-
-	parts := strings.Split(relPath, string(filepath.Separator))
-	if len(parts) < 2 {
-		return "", nil // Root level, no parent folder
+	uuid, err := s.writer.FindFolderByPath(folderPath)
+	if err == nil {
+		return uuid, nil
+	}
+	// Create the folder (and any missing parents)
+	if s.config.Options.CreateMissingFolders {
+		return s.writer.CreateFolderPath(folderPath)
 	}
+	return "", fmt.Errorf("Scrivener folder '%s' not found", folderPath)
+}
 
-	mdDir := parts[0]
+// scrivenerFolderPath returns the binder path of the Scrivener folder a
+// markdown file syncs into ("" for the binder root), and false if no mapping
+// covers it.
+func (s *Syncer) scrivenerFolderPath(mdPath string) (string, bool) {
+	// Directories seen during detection know their Scrivener folder
+	if folderPath, ok := s.folderForDir[filepath.Dir(mdPath)]; ok {
+		return folderPath, true
+	}
 
-	// Find the mapping
-	for _, mapping := range s.config.EnabledMappings() {
-		if mapping.MarkdownDir == mdDir {
-			uuid, err := s.writer.FindFolderByTitle(mapping.ScrivenerFolder)
-			if err != nil {
-				// Create the folder
-				if s.config.Options.CreateMissingFolders {
-					return s.writer.CreateFolder(mapping.ScrivenerFolder, "")
-				}
-				return "", fmt.Errorf("Scrivener folder '%s' not found", mapping.ScrivenerFolder)
-			}
-			return uuid, nil
+	// Otherwise the path belongs to a non-recursive mapping's directory tree
+	for _, mapping := range s.config.MappingsForProject(s.project) {
+		if !mapping.IsRecursive() && config.IsWithin(filepath.Dir(mdPath), s.config.MappingDir(mapping)) {
+			return mapping.ScrivenerFolder, true
 		}
 	}
+	return "", false
+}
 
-	return "", nil
+// rootFor returns the markdown root holding path: the project's markdown
+// root, or the directory of a mapping outside it.
+func (s *Syncer) rootFor(path string) string {
+	for _, root := range s.config.MarkdownRoots() {
+		if config.IsWithin(path, root) {
+			return root
+		}
+	}
+	return s.mdRoot
 }
 
 // recordSync records a successful sync in the state.
 func (s *Syncer) recordSync(mdPath, scrivUUID, content string) {
-	hash := computeHash(content)
+	hash := s.contentHash(content)
 	s.state.RecordFile(mdPath, scrivUUID, hash, time.Now())
+	body, meta := s.partHashes(content)
+	s.state.RecordPartHashes(mdPath, body, meta)
+	s.releaseQuarantine(mdPath)
+	if s.journal != nil {
+		if err := s.journal.recordCompleted(mdPath, hash); err != nil {
+			s.emit(Event{Kind: EventWarning, Message: err.Error(), Err: err})
+		}
+	}
 }
 
-// getMarkdownFiles returns all .md files in a directory.
-func (s *Syncer) getMarkdownFiles(dir string) ([]string, error) {
-	var files []string
+// createDocument creates a Scrivener document from markdown, applying its
+// front matter, or the mapping's section type, as metadata. When the
+// mapping's section type is used, with title_front_matter the title comes
+// from the file name, or with sync_compile the file has no compile key, it
+// is added to the markdown file's front matter too, and the updated content
+// is returned.
+func (s *Syncer) createDocument(title, content, folderUUID, mdPath string) (string, string, error) {
+	if s.plainTextDir(filepath.Dir(mdPath)) {
+		return s.createPlainDocument(title, content, folderUUID, mdPath)
+	}
+	fm, body := pushContent(content)
+	titled := s.config.Options.TitleFrontMatter
+	if titled && fm.Title != "" {
+		title = fm.Title
+	}
+	uuid, err := s.writer.CreateDocument(title, body, folderUUID, true)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.storeNewRTF(mdPath, uuid, body); err != nil {
+		return "", "", err
+	}
+	if err := s.applyFrontMatter(uuid, fm, s.sectionForDir[filepath.Dir(mdPath)], true); err != nil {
+		return "", "", err
+	}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	rewrite := false
+	if titled && fm.Title == "" {
+		fm.Title = title
+		rewrite = true
+	}
+	if fm.SectionType == "" {
+		if id := s.writer.GetSectionType(uuid); id != "" {
+			fm.SectionType = s.reader.SectionTypeTitle(id)
+			rewrite = true
 		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
-			files = append(files, path)
+	}
+	if s.config.Options.SyncCompile && fm.Compile == nil {
+		include := true
+		fm.Compile = &include
+		rewrite = true
+	}
+	if rewrite {
+		content = joinFrontMatter(fm, body)
+		if err := s.writeMarkdownFile(mdPath, content); err != nil {
+			return "", "", err
 		}
-		return nil
-	})
+	}
+	return uuid, content, nil
+}
+
+// createPlainDocument creates a Scrivener document from a plain text file,
+// storing its text as it is. The mapping's section type applies to it.
+func (s *Syncer) createPlainDocument(title, content, folderUUID, mdPath string) (string, string, error) {
+	uuid, err := s.writer.CreateDocument(title, "", folderUUID, true)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.writer.UpdateDocumentText(uuid, content); err != nil {
+		return "", "", err
+	}
+	if err := s.applySectionType(uuid, "", s.sectionForDir[filepath.Dir(mdPath)], true); err != nil {
+		return "", "", err
+	}
+	if s.plainDocs == nil {
+		s.plainDocs = make(map[string]bool)
+	}
+	s.plainDocs[uuid] = true
+	return uuid, content, nil
+}
 
-	return files, err
+// pushDocument updates a Scrivener document's text and metadata from the
+// markdown file at mdPath. With rtf_passthrough, the RTF kept beside the
+// file is patched rather than the markdown converted.
+func (s *Syncer) pushDocument(mdPath, uuid, content string) error {
+	if s.plainDocs[uuid] {
+		return s.writer.UpdateDocumentText(uuid, content)
+	}
+	fm, body := pushContent(content)
+	if s.config.Options.RTFPassthrough {
+		if err := s.pushRTF(mdPath, uuid, body); err != nil {
+			return err
+		}
+	} else if err := s.writer.UpdateDocumentContent(uuid, body, true); err != nil {
+		return err
+	}
+	return s.applyFrontMatter(uuid, fm, "", false)
+}
+
+// pullDocument writes markdown pulled from Scrivener, keeping any front
+// matter keys the existing file has that Scrivener doesn't store, and with
+// rtf_passthrough the document's RTF beside it.
+func (s *Syncer) pullDocument(path, uuid, content string) error {
+	if s.plainTextDir(filepath.Dir(path)) {
+		return s.writeMarkdownFile(path, content)
+	}
+	if err := s.writeMarkdownFile(path, withExistingFrontMatter(path, content, s.managedKeys())); err != nil {
+		return err
+	}
+	return s.storeRTF(path, uuid)
+}
+
+// warnStrippedRevisions warns when a document pulled with revisions: strip
+// has revision marks, which its markdown leaves out: pushing the file back
+// accepts them in Scrivener too.
+func (s *Syncer) warnStrippedRevisions(op Operation, fc FileChange) {
+	if s.config.Options.Revisions == rtf.RevisionsStrip && s.reader.HasRevisions(fc.ScrivUUID) {
+		s.emit(Event{Kind: EventWarning, Operation: op, Path: fc.MarkdownPath, Title: fc.Title, ScrivUUID: fc.ScrivUUID,
+			Message: fmt.Sprintf("revision marks in '%s' were accepted in its markdown; pushing it back accepts them in Scrivener", fc.Title)})
+	}
 }
 
 // computeHash returns the MD5 hash of a string.
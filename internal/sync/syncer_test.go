@@ -1,14 +1,30 @@
 package sync
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/sweiss/harcroft/internal/config"
 	"github.com/sweiss/harcroft/internal/scrivener"
+	"github.com/sweiss/harcroft/internal/scrivgen"
+	"github.com/sweiss/harcroft/internal/scrivtest"
+	"github.com/sweiss/harcroft/internal/vault"
 )
 
 var testdataDir = filepath.Join("..", "..", "testdata")
@@ -311,3 +327,3891 @@ func TestIntegration_ProjectConfigCreation(t *testing.T) {
 		t.Errorf("Markdown path mismatch: %s vs %s", mdRoot, mdPath)
 	}
 }
+
+// TestIntegration_ProjectConfigExpandsPaths tests that ~ and $VARS in config paths resolve.
+func TestIntegration_ProjectConfigExpandsPaths(t *testing.T) {
+	tmpDir := copyTestProject(t)
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("SCRIV_TEST_ROOT", tmpDir)
+
+	cfg := &config.ProjectConfig{
+		ScrivPath: "$SCRIV_TEST_ROOT/sample.scriv",
+		LocalPath: "~/markdown",
+	}
+
+	scrivPath, err := cfg.ScrivenerPath()
+	if err != nil {
+		t.Fatalf("Failed to get Scrivener path: %v", err)
+	}
+	if scrivPath != filepath.Join(tmpDir, "sample.scriv") {
+		t.Errorf("Scrivener path not expanded: %s", scrivPath)
+	}
+
+	if mdRoot := cfg.MarkdownPath(); mdRoot != filepath.Join(tmpDir, "markdown") {
+		t.Errorf("Markdown path not expanded: %s", mdRoot)
+	}
+}
+
+// TestDiscover_FindsScrivProjects tests that discovery finds .scriv packages without descending into them.
+func TestDiscover_FindsScrivProjects(t *testing.T) {
+	tmpDir := copyTestProject(t)
+	os.MkdirAll(filepath.Join(tmpDir, "nested", "deeper", "Other.scriv"), 0755)
+	os.MkdirAll(filepath.Join(tmpDir, ".hidden", "Hidden.scriv"), 0755)
+
+	found := findScrivProjects(tmpDir, discoverMaxDepth)
+
+	expected := []string{
+		filepath.Join(tmpDir, "nested", "deeper", "Other.scriv"),
+		filepath.Join(tmpDir, "sample.scriv"),
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d projects, got %d: %v", len(expected), len(found), found)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], found[i])
+		}
+	}
+
+	if alias := suggestAlias(found[0]); alias != "other" {
+		t.Errorf("Expected alias 'other', got %s", alias)
+	}
+}
+
+// TestInit_InteractiveOptions tests that the init wizard sets the sync
+// defaults from answers, keeping defaults on empty ones.
+func TestInit_InteractiveOptions(t *testing.T) {
+	opts := config.DefaultOptions()
+	answers := "sometimes\nskip\n\ntrash\nn\nindex\n"
+	interactiveOptions(bufio.NewReader(strings.NewReader(answers)), &opts)
+
+	if opts.DefaultConflictResolution != "skip" {
+		t.Errorf("Expected conflict resolution skip, got %s", opts.DefaultConflictResolution)
+	}
+	if opts.DefaultDeletionAction != "prompt" {
+		t.Errorf("Expected the default deletion action kept, got %s", opts.DefaultDeletionAction)
+	}
+	if opts.DeletionStyle != "trash" || opts.CreateMissingFolders || opts.Decorations != config.DecorationsIndex {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	// Input ending early keeps the remaining defaults
+	opts = config.DefaultOptions()
+	interactiveOptions(bufio.NewReader(strings.NewReader("markdown\n")), &opts)
+	if opts.DefaultConflictResolution != "markdown" || opts.DefaultDeletionAction != "prompt" || !opts.CreateMissingFolders || opts.Decorations != "" {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+}
+
+// TestInit_SuggestMappings tests that init pairs folders with directories of
+// similar names or matching contents, surest first.
+func TestInit_SuggestMappings(t *testing.T) {
+	root := t.TempDir()
+	write := func(path string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755)
+		os.WriteFile(filepath.Join(root, path), []byte("Text."), 0644)
+	}
+	write("drafts/chapter-one.md")
+	write("people/wilder-young.md")
+	write("people/ada-north.md")
+	write("people/shopping-list.md")
+	write("misc/notes.md")
+
+	doc := func(title string, children ...*scrivener.Document) *scrivener.Document {
+		return &scrivener.Document{Title: title, Children: children}
+	}
+	folders := []*scrivener.Document{
+		doc("Draft", doc("Prologue")),
+		doc("Characters", doc("Wilder Young"), doc("Ada North"), doc("Brun")),
+		doc("Places", doc("Harbor")),
+	}
+	suggestions := suggestMappings(folders, []string{"drafts", "misc", "people"}, root)
+	if len(suggestions) != 3 {
+		t.Fatalf("Expected a suggestion per folder, got %+v", suggestions)
+	}
+
+	if m := suggestions[0]; m.Mapping.MarkdownDir != "drafts" || !m.Mapping.SyncEnabled || m.Reason != "similar name" {
+		t.Errorf("Expected drafts <-> Draft by name, got %+v", m)
+	}
+	if m := suggestions[1]; m.Mapping.MarkdownDir != "people" || !m.Mapping.SyncEnabled || m.Reason != "2 of 3 titles match" {
+		t.Errorf("Expected people <-> Characters by content, got %+v", m)
+	} else if m.Confidence < 0.66 || m.Confidence > 0.67 {
+		t.Errorf("Expected a confidence of 2/3, got %v", m.Confidence)
+	}
+	if m := suggestions[2]; m.Mapping.MarkdownDir != "places" || m.Mapping.SyncEnabled || m.Confidence != 0 {
+		t.Errorf("Expected a new places directory switched off, got %+v", m)
+	}
+}
+
+// TestImportExternalSync tests that an External Folder Sync folder becomes
+// the first sync's baseline instead of a set of duplicates.
+func TestImportExternalSync(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+
+	draft, err := s.reader.FindFolderByPath("Draft")
+	if err != nil {
+		t.Fatalf("Failed to find Draft: %v", err)
+	}
+	external := filepath.Join(s.mdRoot, "Draft")
+	os.MkdirAll(external, 0755)
+	os.WriteFile(filepath.Join(external, "1 Chapter One.txt"), []byte(s.docContent(draft.Children[0])), 0644)
+	os.WriteFile(filepath.Join(external, "Chapter Two (2).txt"), []byte("Edited outside Scrivener"), 0644)
+	os.WriteFile(filepath.Join(external, "3 Epilogue.txt"), []byte("No such document"), 0644)
+
+	files := findExternalSync(s.mdRoot)
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 External Folder Sync files, got %+v", files)
+	}
+	if offerExternalSyncImport(nil, files, "test", false, false) {
+		t.Error("Expected no import without prompting unless asked for")
+	}
+	if !offerExternalSyncImport(nil, files, "test", false, true) {
+		t.Error("Expected the import asked for to go ahead")
+	}
+	imported, err := s.importExternalSync(files)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("Expected 2 files imported, got %d", imported)
+	}
+
+	archived := filepath.Join(s.mdRoot, ExternalSyncArchiveDir, "Draft", "1 Chapter One.txt")
+	if !fileExists(archived) || fileExists(filepath.Join(external, "1 Chapter One.txt")) {
+		t.Error("Imported originals should be moved to the archive")
+	}
+	if !fileExists(filepath.Join(external, "3 Epilogue.txt")) {
+		t.Error("Unmatched files should be left in place")
+	}
+
+	plan, err := reloadSyncer(t, s).detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToCreateInMarkdown) != 0 || len(plan.ToCreateInScriv) != 0 || len(plan.ToUpdateInMarkdown) != 0 {
+		t.Errorf("Imported files should not be created again: %+v", plan)
+	}
+	if len(plan.Conflicts) != 1 || filepath.Base(plan.Conflicts[0].MarkdownPath) != "chapter-two.md" {
+		t.Errorf("Expected the edited file as the only conflict, got %+v", plan.Conflicts)
+	}
+}
+
+// TestImportExternalSync_TitleRules tests that imported files are named
+// by the mapping's title rules, so the first sync finds them rather than
+// pulling their documents again.
+func TestImportExternalSync_TitleRules(t *testing.T) {
+	p := scrivtest.New("Novel").
+		Doc("Draft/Chapter 7 — The Fall", "It fell.")
+	rules := config.TitleRules{Prefix: "Chapter {n} — ", NumberWidth: 2}
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true, Titles: rules}
+	s := newBuiltSyncer(t, p, config.DefaultOptions(), draft)
+
+	folder, err := s.reader.FindFolderByPath("Draft")
+	if err != nil {
+		t.Fatalf("Failed to find Draft: %v", err)
+	}
+	external := filepath.Join(s.mdRoot, "Draft")
+	os.MkdirAll(external, 0755)
+	os.WriteFile(filepath.Join(external, "1 Chapter 7 — The Fall.txt"), []byte(s.docContent(folder.Children[0])), 0644)
+
+	if imported, err := s.importExternalSync(findExternalSync(s.mdRoot)); err != nil || imported != 1 {
+		t.Fatalf("Expected 1 file imported, got %d (%v)", imported, err)
+	}
+	path := filepath.Join(s.mdRoot, "draft", "07-the-fall.md")
+	if !fileExists(path) {
+		t.Fatal("Expected the file imported as draft/07-the-fall.md")
+	}
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(s.mdRoot, "draft"))
+	if len(entries) != 1 {
+		t.Errorf("Expected only 07-the-fall.md in draft, got %d files", len(entries))
+	}
+	if uuid := s.state.GetUUIDForPath(path); uuid != folder.Children[0].UUID {
+		t.Errorf("Expected 07-the-fall.md tracked as the chapter, got %q", uuid)
+	}
+	folder, _ = reloadSyncer(t, s).reader.FindFolderByPath("Draft")
+	if folder == nil || len(folder.Children) != 1 {
+		t.Errorf("Expected no documents added to Draft, got %+v", folder)
+	}
+}
+
+// TestRenameAlias tests that renaming an alias moves its state and reports,
+// and leaves those of an alias sharing its prefix alone.
+func TestRenameAlias(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(config.ConfigEnvVar, "")
+
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	globalCfg.AddProject("test", home, filepath.Join(home, "sample.scriv"))
+	globalCfg.AddProject("other", home, filepath.Join(home, "sample.scriv"))
+	if err := globalCfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	dir, _ := config.ConfigDir()
+	for _, name := range []string{"state/test.json", "state/test.journal.json", "state/test@notes.json", "state/testing.json", "reports/test/1.md"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("{}"), 0644)
+	}
+
+	if err := RunRenameAlias("test", "other"); err == nil {
+		t.Error("Renaming onto an existing alias should fail")
+	}
+	if err := RunRenameAlias("test", "novel"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	for _, name := range []string{"state/novel.json", "state/novel.journal.json", "state/novel@notes.json", "state/testing.json", "reports/novel/1.md"} {
+		if !fileExists(filepath.Join(dir, filepath.FromSlash(name))) {
+			t.Errorf("Expected %s after rename", name)
+		}
+	}
+	if fileExists(filepath.Join(dir, "state", "test.json")) {
+		t.Error("Old state file should be moved")
+	}
+
+	reloaded, err := config.LoadGlobal()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if reloaded.HasProject("test") || !reloaded.HasProject("novel") {
+		t.Errorf("Expected the config to list novel instead of test, got %v", reloaded.ListProjects())
+	}
+}
+
+// TestValidateConfig tests that config mistakes are reported on their lines,
+// and that an invalid project refuses to open.
+func TestValidateConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := filepath.Join(home, "config.yaml")
+	t.Setenv(config.ConfigEnvVar, configPath)
+
+	os.WriteFile(configPath, []byte(`version: "1.0"
+projects:
+  novel:
+    local_path: `+home+`
+    scriv_path: `+filepath.Join(home, "sample.scriv")+`
+    folder_mappings:
+      - markdown_dir: draft
+        scrivener_folder: Draft
+        sync_enabld: true
+    options:
+      underline: bold
+      memory_budget_mb: lots
+`), 0644)
+
+	issues, err := config.CheckFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to check config: %v", err)
+	}
+	want := map[int]string{
+		9:  "warning: unknown key 'sync_enabld' in projects.novel.folder_mappings[1]",
+		12: "error: cannot unmarshal",
+	}
+	for _, issue := range issues {
+		if prefix, ok := want[issue.Line]; ok && strings.HasPrefix(issue.Severity+": "+issue.Message, prefix) {
+			delete(want, issue.Line)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("Missing issues %v in %v", want, issues)
+	}
+
+	// Once the type error is fixed the config loads, with the typo as a
+	// warning and the invalid option stopping the project
+	data, _ := os.ReadFile(configPath)
+	os.WriteFile(configPath, []byte(strings.Replace(string(data), "memory_budget_mb: lots", "memory_budget_mb: 0", 1)), 0644)
+	issues, _ = config.CheckFile(configPath)
+	if len(issues) != 2 || issues[1].Line != 11 || !strings.Contains(issues[1].Message, "invalid underline: bold") {
+		t.Errorf("Expected the typo and the invalid option on line 11, got %v", issues)
+	}
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		t.Fatalf("Failed to load config with an unknown key: %v", err)
+	}
+	if got := globalCfg.Issues("novel"); len(got) != 2 || got[0].Severity != config.IssueWarning {
+		t.Errorf("Expected the load to keep both issues, got %v", got)
+	}
+	if _, err := NewSyncerForAlias("novel"); err == nil || !strings.Contains(err.Error(), "invalid underline") {
+		t.Errorf("Expected an invalid project to refuse to open, got %v", err)
+	}
+	if err := RunValidateConfig("novel"); err == nil {
+		t.Error("Expected validation to fail")
+	}
+}
+
+// TestSync_PausedProject tests that a paused project refuses to sync and is
+// listed as paused without being scanned.
+func TestSync_PausedProject(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	s.config.SetEnabled(false)
+
+	if err := s.Sync(false, false); err == nil || !strings.Contains(err.Error(), "paused") {
+		t.Errorf("Expected a paused error, got %v", err)
+	}
+	if fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) {
+		t.Error("A paused project should not be synced")
+	}
+
+	h := projectHealth(s.config, "test", true)
+	if h.healthLabel() != "paused" || h.Pending != -1 {
+		t.Errorf("Expected an unscanned paused project, got %+v", h)
+	}
+
+	s.config.SetEnabled(true)
+	if s.config.Enabled != nil {
+		t.Error("Resuming should clear the enabled setting")
+	}
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync after resume failed: %v", err)
+	}
+}
+
+// TestPlan_WritePorcelain tests the stable line format of status --porcelain.
+func TestPlan_WritePorcelain(t *testing.T) {
+	plan := NewPlan()
+	plan.AddCreateInScriv("/md/draft/new.md", "New", "")
+	plan.AddConflict("/md/draft/a.md", "UUID-A", "Tab\tTitle", "", "")
+	plan.AddOrphan("/md/draft/b.md", "markdown", "UUID-B", "B", time.Now())
+	plan.AddOrphan("/md/draft/c.md", "scrivener", "UUID-C", "C", time.Now())
+
+	var out strings.Builder
+	plan.WritePorcelain(&out)
+	expected := "A\t/md/draft/new.md\t\tNew\n" +
+		"C\t/md/draft/a.md\tUUID-A\tTab Title\n" +
+		"X\t/md/draft/b.md\tUUID-B\tB\n" +
+		"D\t/md/draft/c.md\tUUID-C\tC\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected porcelain output:\n%q\nwant:\n%q", out.String(), expected)
+	}
+}
+
+// TestNormalizeMarkdown tests each normalization step and that front matter
+// and code blocks are left alone.
+func TestNormalizeMarkdown(t *testing.T) {
+	opts := config.Normalize{TrimTrailingWhitespace: true, FinalNewline: true, TabWidth: 4, BulletMarker: "-"}
+	content := "---\ntags:\n  - a\n---\n\n* one \n\t+ two\n* * *\nline break  \nnext\n```\n*\tcode\n```\n\n\n"
+	expected := "---\ntags:\n  - a\n---\n\n- one\n    - two\n* * *\nline break  \nnext\n```\n*\tcode\n```\n"
+	if got := normalizeMarkdown(content, opts); got != expected {
+		t.Errorf("Unexpected normalized markdown:\n%q\nwant:\n%q", got, expected)
+	}
+	if got := normalizeMarkdown(content, config.Normalize{}); got != content {
+		t.Errorf("Normalization should be off by default, got %q", got)
+	}
+}
+
+// TestSync_NormalizationIgnoresEditorFormatting tests that formatting
+// normalized away doesn't count as a markdown change.
+func TestSync_NormalizationIgnoresEditorFormatting(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.Normalize = config.Normalize{TrimTrailingWhitespace: true, FinalNewline: true}
+	s := newTestSyncer(t, opts,
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(data), "\n") || strings.HasSuffix(string(data), "\n\n") {
+		t.Errorf("Pulled markdown should end with one newline, got %q", data)
+	}
+	os.WriteFile(path, []byte(strings.ReplaceAll(string(data), "\n", "  \t\n")+"\n\n"), 0644)
+
+	plan, err := reloadSyncer(t, s).detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Whitespace-only edits should not be changes, got %d operation(s)", plan.TotalOperations())
+	}
+}
+
+// TestCanonicalMarkdown tests that formatting the RTF round trip doesn't
+// preserve is smoothed out of the canonical form.
+func TestCanonicalMarkdown(t *testing.T) {
+	a := "\n\n* one\r\n+ __two__ and _three_ \n\n\n\nsnake_case_name\n"
+	b := "- one\n- **two** and *three*\n\nsnake_case_name"
+	if canonicalMarkdown(a) != canonicalMarkdown(b) {
+		t.Errorf("Expected the same canonical form:\n%q\n%q", canonicalMarkdown(a), canonicalMarkdown(b))
+	}
+	if got := canonicalMarkdown(b); got != "- one\n- **two** and *three*\n\nsnake_case_name\n" {
+		t.Errorf("Unexpected canonical form %q", got)
+	}
+}
+
+// TestSync_CanonicalHashRefreshesBaseline tests that files identical on both
+// sides are in sync even when the recorded hash is stale, and that the
+// baseline is brought up to date.
+func TestSync_CanonicalHashRefreshesBaseline(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// A stale hash, as recorded by an older version hashing raw content
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	fs := s.state.GetFileState(path)
+	s.state.RecordFile(path, fs.ScrivUUID, "stale", time.Now())
+	if err := s.state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	if got := s.state.GetFileState(path).ContentHash; got == "stale" {
+		t.Error("Expected the stale baseline to be replaced")
+	}
+
+	// An edit on one side is then a plain update, not a conflict
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append(data, []byte("\nMore text.\n")...), 0644)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToUpdateInScriv) != 1 || len(plan.Conflicts) != 0 {
+		t.Errorf("Expected one update to Scrivener, got %+v", plan)
+	}
+}
+
+// TestSync_UnchangedDocumentsNotConverted tests the Scrivener hash cache:
+// documents unchanged since the last sync are not read, and a change to one
+// is still picked up.
+func TestSync_UnchangedDocumentsNotConverted(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	for i := 0; i < 2; i++ { // the second sync fills the cache
+		if err := s.Sync(false, false); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		s = reloadSyncer(t, s)
+	}
+
+	draft, err := s.reader.FindFolderByPath("Draft")
+	if err != nil {
+		t.Fatalf("Failed to find Draft: %v", err)
+	}
+	for _, doc := range draft.Children {
+		if !doc.Deferred || doc.Content != "" {
+			t.Errorf("Expected '%s' to be skipped, got deferred=%v", doc.Title, doc.Deferred)
+		}
+	}
+
+	// Edit chapter one in Scrivener
+	if err := s.writer.UpdateDocumentContent("DOC-UUID-0001", "Scrivener edit.", true); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf"), later, later)
+
+	plan, err := reloadSyncer(t, s).detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToUpdateInMarkdown) != 1 || !strings.Contains(plan.ToUpdateInMarkdown[0].Content, "Scrivener edit.") {
+		t.Errorf("Expected the edited document to be pulled, got %+v", plan)
+	}
+}
+
+// TestSync_UnchangedMarkdownNotRead tests the markdown hash cache: a file
+// with the size and time of the last sync is not read unless --full-scan is
+// given.
+func TestSync_UnchangedMarkdownNotRead(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	for i := 0; i < 2; i++ { // the second sync fills the cache
+		if err := s.Sync(false, false); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		s = reloadSyncer(t, s)
+	}
+
+	// Swap a character, keeping the size and time
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	info, _ := os.Stat(path)
+	data, _ := os.ReadFile(path)
+	data[len(data)-2] ^= 0x01
+	os.WriteFile(path, data, 0644)
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected the cached hash to be trusted, got %d operation(s)", plan.TotalOperations())
+	}
+
+	s.SetFullScan(true)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToUpdateInScriv) != 1 {
+		t.Errorf("Expected a full scan to find the edit, got %+v", plan)
+	}
+}
+
+// TestSync_UnmappedDocuments tests that documents outside every mapped folder
+// are listed, and pulled into unmapped_dir when it is set.
+func TestSync_UnmappedDocuments(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Unmapped) != 1 || plan.Unmapped[0].ScrivUUID != "DOC-UUID-0003" || plan.Unmapped[0].BinderPath != "/Research/Characters" {
+		t.Fatalf("Expected Hero to be listed as unmapped, got %+v", plan.Unmapped)
+	}
+
+	opts := config.DefaultOptions()
+	opts.UnmappedDir = "unsorted"
+	s.config.Options = opts
+	stray := filepath.Join(s.mdRoot, "unsorted", "notes.md")
+	os.MkdirAll(filepath.Dir(stray), 0755)
+	os.WriteFile(stray, []byte("Stray notes\n"), 0644)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !fileExists(filepath.Join(s.mdRoot, "unsorted", "hero.md")) {
+		t.Error("Expected the unmapped document to be pulled into unsorted/")
+	}
+
+	s = reloadSyncer(t, s)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() || len(plan.Unmapped) != 0 {
+		t.Errorf("Expected the stray file to stay out of Scrivener, got %+v", plan)
+	}
+}
+
+// TestSync_UnmappedMarkdownDirs tests that markdown directories outside
+// every mapping are reported and can be mapped to a new Scrivener folder.
+func TestSync_UnmappedMarkdownDirs(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	for _, name := range []string{"notes/idea.md", "notes/.hidden/skip.md", "draft/part/scene.md"} {
+		path := filepath.Join(s.mdRoot, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("Text\n"), 0644)
+	}
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	notes := filepath.Join(s.mdRoot, "notes")
+	if len(plan.UnmappedDirs) != 1 || plan.UnmappedDirs[0].Path != notes || plan.UnmappedDirs[0].Files != 1 {
+		t.Fatalf("Expected notes/ to be reported, got %+v", plan.UnmappedDirs)
+	}
+
+	t.Setenv(config.ConfigEnvVar, "")
+	globalCfg, _ := config.LoadGlobal()
+	proj := globalCfg.AddProject("test", s.mdRoot, s.scrivPath)
+	proj.FolderMappings = []config.FolderMapping{draft}
+	if err := globalCfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := s.mapMarkdownDir(notes, "Draft/Notes"); err != nil {
+		t.Fatalf("Failed to map directory: %v", err)
+	}
+
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.UnmappedDirs) != 0 {
+		t.Errorf("Expected no unmapped directories, got %+v", plan.UnmappedDirs)
+	}
+	found := false
+	for _, fc := range plan.ToCreateInScriv {
+		found = found || fc.MarkdownPath == filepath.Join(notes, "idea.md")
+	}
+	if !found {
+		t.Errorf("Expected notes/idea.md to be created in Scrivener, got %+v", plan.ToCreateInScriv)
+	}
+
+	globalCfg, _ = config.LoadGlobal()
+	saved, _ := globalCfg.GetProject("test")
+	if len(saved.FolderMappings) != 2 || saved.FolderMappings[1].MarkdownDir != "notes" {
+		t.Errorf("Expected the mapping to be saved, got %+v", saved.FolderMappings)
+	}
+}
+
+// TestSync_TitleFrontMatter tests that title_front_matter renames documents
+// from the front matter and updates it from the binder, keeping file names.
+func TestSync_TitleFrontMatter(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.TitleFrontMatter = true
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "title: Chapter One\n") {
+		t.Fatalf("Expected the title in the front matter, got %q", data)
+	}
+
+	// Push: the front matter title renames the document
+	os.WriteFile(path, []byte(strings.Replace(string(data), "title: Chapter One", "title: Chapter 1", 1)), 0644)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	if doc, _ := s.reader.GetDocument("DOC-UUID-0001"); doc == nil || doc.Title != "Chapter 1" {
+		t.Fatalf("Expected the document to be renamed, got %+v", doc)
+	}
+
+	// Pull: a binder rename updates the front matter of the same file
+	s.writer.UpdateTitle("DOC-UUID-0001", "The Beginning")
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "title: The Beginning\n") {
+		t.Errorf("Expected the front matter title to follow the binder, got %q", data)
+	}
+	if fileExists(filepath.Join(s.mdRoot, "draft", "the-beginning.md")) {
+		t.Error("Expected the markdown file to keep its name")
+	}
+
+	plan, err := reloadSyncer(t, s).detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing left to sync, got %+v", plan)
+	}
+}
+
+// TestSync_MissingContentFile tests that a document whose content file is
+// missing doesn't empty its markdown, and is listed in the plan instead.
+func TestSync_MissingContentFile(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	before, _ := os.ReadFile(path)
+	if strings.TrimSpace(string(before)) == "" {
+		t.Fatal("Expected the first sync to pull Chapter One's text")
+	}
+
+	os.Remove(filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf"))
+	s = reloadSyncer(t, s)
+	if doc, _ := s.reader.GetDocument("DOC-UUID-0001"); doc == nil || !doc.NoContent {
+		t.Fatalf("Expected the document to be marked as having no content file, got %+v", doc)
+	}
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToUpdateInMarkdown) != 0 {
+		t.Errorf("Expected no markdown updates, got %+v", plan.ToUpdateInMarkdown)
+	}
+	if len(plan.MissingContent) != 1 || plan.MissingContent[0].ScrivUUID != "DOC-UUID-0001" {
+		t.Errorf("Expected Chapter One listed as missing its content, got %+v", plan.MissingContent)
+	}
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("Expected the markdown kept, got %q", after)
+	}
+
+	// An edit in markdown restores the content
+	os.WriteFile(path, []byte("Rewritten opening."), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	if doc, _ := s.reader.GetDocument("DOC-UUID-0001"); doc == nil || doc.NoContent || !strings.Contains(doc.Content, "Rewritten opening.") {
+		t.Errorf("Expected the markdown pushed to Scrivener, got %+v", doc)
+	}
+}
+
+// TestSync_FileLimits tests that markdown files too large or binary are left
+// out of the sync, or synced with a warning when the action is warn.
+func TestSync_FileLimits(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.FileLimits.MaxSizeMB = 1
+	s := newTestSyncer(t, opts, draft)
+	dir := filepath.Join(s.mdRoot, "draft")
+	os.MkdirAll(dir, 0755)
+
+	os.WriteFile(filepath.Join(dir, "export.md"), []byte(strings.Repeat("words ", 200000)), 0644)
+	os.WriteFile(filepath.Join(dir, "image.md"), []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00"), 0644)
+	os.WriteFile(filepath.Join(dir, "chapter-one.md"), []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, 0644)
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("Plain notes."), 0644)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Skipped) != 3 {
+		t.Fatalf("Expected 3 skipped files, got %+v", plan.Skipped)
+	}
+	if len(plan.ToCreateInScriv) != 1 || plan.ToCreateInScriv[0].Title != "Notes" {
+		t.Errorf("Expected only notes.md created in Scrivener, got %+v", plan.ToCreateInScriv)
+	}
+	for _, fc := range plan.ToCreateInMarkdown {
+		if fc.Title == "Chapter One" {
+			t.Error("Expected Chapter One not pulled over the skipped file")
+		}
+	}
+
+	// With the warn action they are synced
+	s.config.Options.FileLimits.Action = config.FileLimitWarn
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Skipped) != 0 || len(plan.ToCreateInScriv) != 3 {
+		t.Errorf("Expected the files synced with a warning, got %d skipped and %d to create", len(plan.Skipped), len(plan.ToCreateInScriv))
+	}
+}
+
+// TestSync_SymlinksAndPlaceholders tests that symlinked directories are
+// followed only when asked, without looping, and that a file evicted to
+// iCloud is neither read nor treated as deleted.
+func TestSync_SymlinksAndPlaceholders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on Windows")
+	}
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	dir := filepath.Join(s.mdRoot, "draft")
+	os.MkdirAll(dir, 0755)
+	notes := filepath.Join(t.TempDir(), "notes")
+	os.MkdirAll(notes, 0755)
+	os.WriteFile(filepath.Join(notes, "idea.md"), []byte("An idea."), 0644)
+	os.Symlink(notes, filepath.Join(dir, "notes"))
+	os.Symlink(dir, filepath.Join(dir, "loop"))
+
+	files, err := s.getMarkdownFiles(dir)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("Expected symlinked directories left out by default, got %v, %v", files, err)
+	}
+	s.config.Options.Symlinks = config.SymlinksFollow
+	files, err = s.getMarkdownFiles(dir)
+	if err != nil || len(files) != 1 || filepath.Base(files[0]) != "idea.md" {
+		t.Fatalf("Expected the symlinked directory followed once, got %v, %v", files, err)
+	}
+	s.config.Options.Symlinks = ""
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// iCloud evicts a synced file, leaving a stub in its place
+	path := filepath.Join(dir, "chapter-one.md")
+	os.Remove(path)
+	os.WriteFile(icloudStub(path), []byte("bplist00"), 0644)
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Orphans) != 0 {
+		t.Errorf("Expected the evicted file not treated as deleted, got %+v", plan.Orphans)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Path != path {
+		t.Errorf("Expected the evicted file listed as skipped, got %+v", plan.Skipped)
+	}
+	if len(plan.ToCreateInMarkdown) != 0 {
+		t.Errorf("Expected nothing pulled over the evicted file, got %+v", plan.ToCreateInMarkdown)
+	}
+}
+
+// TestSync_TitleCase tests case-sensitive title matching and that markdown
+// files differing only in case are never synced or written.
+func TestSync_TitleCase(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("needs a case-sensitive filesystem")
+	}
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.TitleCase = config.TitleCaseSensitive
+	s := newTestSyncer(t, opts, draft)
+	draftUUID, _ := s.writer.FindFolderByTitle("Draft")
+	s.writer.CreateDocument("Notes", "Upper notes", draftUUID, true)
+	s.writer.CreateDocument("NOTES", "Shouting notes", draftUUID, true)
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(s.mdRoot, "draft")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "idea.md"), []byte("Lower idea."), 0644)
+	os.WriteFile(filepath.Join(dir, "Idea.md"), []byte("Upper idea."), 0644)
+	os.WriteFile(filepath.Join(dir, "chapter-two.md"), []byte("Old lowercase copy."), 0644)
+
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	created := make(map[string]bool)
+	for _, fc := range plan.ToCreateInMarkdown {
+		created[filepath.Base(fc.MarkdownPath)] = true
+	}
+	if !created["Chapter-One.md"] || !created["Notes.md"] || !created["NOTES-2.md"] {
+		t.Errorf("Expected files named in the titles' case without collisions, got %v", created)
+	}
+	if created["Chapter-Two.md"] {
+		t.Error("Expected Chapter Two not written over chapter-two.md")
+	}
+	skipped := make(map[string]bool)
+	for _, f := range plan.Skipped {
+		skipped[filepath.Base(f.Path)] = true
+	}
+	if !skipped["idea.md"] || !skipped["Idea.md"] || !skipped["Chapter-Two.md"] {
+		t.Errorf("Expected the case collisions skipped, got %+v", plan.Skipped)
+	}
+	for _, fc := range plan.ToCreateInScriv {
+		if fc.Title == "idea" || fc.Title == "Idea" {
+			t.Errorf("Expected no colliding file created in Scrivener, got %q", fc.Title)
+		}
+	}
+}
+
+// TestResolveConflict_Rules tests that conflict rules resolve the conflicts
+// they match in order, and that others fall back to the default.
+func TestResolveConflict_Rules(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.DefaultConflictResolution = "skip"
+	opts.Conflicts = []config.ConflictRule{
+		{Path: "draft/**/notes-*.md", Resolve: "prompt"},
+		{Path: "draft/**", Resolve: "markdown"},
+		{OlderThan: "7d", Resolve: "scrivener"},
+		{Title: "*Outline", Resolve: "skip"},
+	}
+	s := newTestSyncer(t, opts)
+	if errs := s.config.Validate(); len(errs) != 0 {
+		t.Fatalf("Expected the rules to be valid, got %v", errs)
+	}
+
+	write := func(rel string, age time.Duration) string {
+		path := filepath.Join(s.mdRoot, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("text"), 0644)
+		when := time.Now().Add(-age)
+		os.Chtimes(path, when, when)
+		return path
+	}
+	tests := []struct {
+		path, title, want string
+	}{
+		{write("draft/part-1/scene.md", 30*24*time.Hour), "Scene", "markdown"},
+		{write("draft/notes-a.md", time.Hour), "Notes A", "skip"}, // prompts, so the default
+		{write("research/old.md", 8*24*time.Hour), "Old", "scrivener"},
+		{write("research/new.md", time.Hour), "New", "skip"},
+		{write("research/plot.md", time.Hour), "Plot Outline", "skip"},
+	}
+	for _, tt := range tests {
+		got, err := s.resolveConflict(Conflict{MarkdownPath: tt.path, Title: tt.title}, false)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveConflict(%s) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+
+	s.config.Options.Conflicts = []config.ConflictRule{{OlderThan: "soon", Resolve: "newest"}}
+	if errs := s.config.Validate(); len(errs) == 0 {
+		t.Error("Expected an invalid rule to be reported")
+	}
+}
+
+// TestSync_Summary tests the totals printed at the end of a run and written
+// with --summary-out.
+func TestSync_Summary(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if s.summary == nil || s.summary.CreatedInMarkdown != 2 || s.summary.WordsAdded == 0 {
+		t.Fatalf("Expected 2 files created with their words, got %+v", s.summary)
+	}
+
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append(data, []byte("\n\nThree more words.\n")...), 0644)
+	out := filepath.Join(t.TempDir(), "summary.json")
+	s = reloadSyncer(t, s)
+	s.SetSummaryOutput(out)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	var summary Summary
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Expected the summary written: %v", err)
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("Failed to parse summary: %v", err)
+	}
+	if summary.Alias != "test" || summary.UpdatedInScrivener != 1 || summary.CreatedInMarkdown != 0 {
+		t.Errorf("Expected one update in Scrivener, got %+v", summary)
+	}
+	if summary.WordsAdded != 3 || summary.WordsRemoved != 0 {
+		t.Errorf("Expected +3 / -0 words, got +%d / -%d", summary.WordsAdded, summary.WordsRemoved)
+	}
+}
+
+// TestSync_IgnoreFrontMatter tests that a file whose front matter disables
+// syncing is never pushed, pulled or treated as an orphan, even once it was
+// synced, until the flag is removed.
+func TestSync_IgnoreFrontMatter(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	chapter := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	os.WriteFile(chapter, []byte("---\nscrivsync: false\n---\n\nLocal rewrite."), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "private.md"), []byte("---\nsync: skip\n---\n\nSecret."), 0644)
+
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Skipped) != 2 || !plan.IsEmpty() {
+		t.Fatalf("Expected both files skipped and nothing to do, got %+v", plan)
+	}
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Deleting the ignored file neither deletes nor restores its document
+	os.Remove(chapter)
+	s = reloadSyncer(t, s)
+	if s.state.Ignored[chapter] != "DOC-UUID-0001" {
+		t.Fatalf("Expected the exclusion tracked with its document, got %v", s.state.Ignored)
+	}
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Orphans) != 0 || len(plan.ToCreateInMarkdown) != 0 {
+		t.Errorf("Expected no orphan and nothing pulled, got %+v", plan)
+	}
+
+	// Without the flag the file syncs again, as a new file
+	os.WriteFile(chapter, []byte("Back in sync."), 0644)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0].ScrivUUID != "DOC-UUID-0001" {
+		t.Errorf("Expected the file compared with its document again, got %+v", plan.Conflicts)
+	}
+	if _, ok := s.state.Ignored[chapter]; ok {
+		t.Error("Expected the exclusion lifted")
+	}
+}
+
+// TestSync_WordBudgets tests that a push taking a folder outside its word
+// budget is warned about.
+func TestSync_WordBudgets(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append(data, []byte("\n\nThree more words.\n")...), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "interlude.md"), []byte("Two words."), 0644)
+
+	s = reloadSyncer(t, s)
+	s.config.Options.WordBudgets = []config.WordBudget{{Folder: "Research/**", Max: 1}, {Folder: "Dr*", Min: 10000}}
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.BudgetWarnings) != 1 {
+		t.Fatalf("Expected a warning for the Draft only, got %+v", plan.BudgetWarnings)
+	}
+	w := plan.BudgetWarnings[0]
+	if w.Folder != "Draft" || w.After != w.Before+5 || w.Min != 10000 {
+		t.Errorf("Expected the Draft 5 words longer and under its minimum, got %+v", w)
+	}
+
+	s.config.Options.WordBudgets = []config.WordBudget{{Folder: "Draft", Min: 1, Max: 10000}}
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.BudgetWarnings) != 0 {
+		t.Errorf("Expected no warning within the budget, got %+v", plan.BudgetWarnings)
+	}
+}
+
+// TestSync_FirstSyncConfirmation tests that a project's first sync runs only
+// once confirmed, even non-interactively, and later syncs aren't asked about.
+func TestSync_FirstSyncConfirmation(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	s.SetAssumeYes(false)
+	chapter := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+
+	s.input = strings.NewReader("")
+	if err := s.Sync(false, false); err == nil || !strings.Contains(err.Error(), "first sync not confirmed") {
+		t.Fatalf("Expected the unconfirmed first sync refused, got %v", err)
+	}
+	if fileExists(chapter) || !s.isFirstSync() {
+		t.Fatal("Expected nothing written without confirmation")
+	}
+
+	s.input = strings.NewReader("y\n")
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !fileExists(chapter) {
+		t.Fatal("Expected the confirmed first sync to run")
+	}
+
+	// Later syncs run without asking
+	os.WriteFile(chapter, []byte("Rewritten."), 0644)
+	s = reloadSyncer(t, s)
+	s.SetAssumeYes(false)
+	s.input = strings.NewReader("")
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Expected a later sync to run unasked, got %v", err)
+	}
+}
+
+// TestSync_TruncationGuard tests that an update emptying a substantial
+// document is listed and held back until confirmed.
+func TestSync_TruncationGuard(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	chapter := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	os.WriteFile(chapter, []byte(strings.Repeat("The hero walks on. ", 50)), 0644)
+	if err := s.Push(false, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	os.WriteFile(chapter, []byte("Oops."), 0644)
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("detectAllChanges failed: %v", err)
+	}
+	plan.Close()
+	if len(plan.Truncations) != 1 || plan.Truncations[0].Before != 200 || plan.Truncations[0].After != 1 {
+		t.Fatalf("Expected the update listed as a truncation, got %+v", plan.Truncations)
+	}
+
+	s.input = strings.NewReader("")
+	if err := s.Push(false, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if words := s.scrivenerWordsBefore("DOC-UUID-0001"); words != 200 {
+		t.Fatalf("Expected the unconfirmed update held back, document has %d words", words)
+	}
+
+	s = reloadSyncer(t, s)
+	s.input = strings.NewReader("y\n")
+	if err := s.Push(false, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if words := s.scrivenerWordsBefore("DOC-UUID-0001"); words != 1 {
+		t.Fatalf("Expected the confirmed update applied, document has %d words", words)
+	}
+}
+
+// TestSync_Events tests that executing a plan emits an event as each
+// operation starts and completes, with its progress through the plan.
+func TestSync_Events(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	var events []Event
+	s.SetEventHandler(EventFunc(func(e Event) { events = append(events, e) }))
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(events) != 6 {
+		t.Fatalf("Expected a start and a completion for each of 2 documents, the sync's completion and its report, got %+v", events)
+	}
+	for i, e := range events[:4] {
+		kind := OperationStarted
+		if i%2 == 1 {
+			kind = OperationCompleted
+		}
+		if e.Kind != kind || e.Operation != OpCreateInMarkdown || e.Index != i/2+1 || e.Total != 2 {
+			t.Errorf("Unexpected event %d: %+v", i, e)
+		}
+	}
+	if !strings.HasPrefix(events[0].Message, "Creating in markdown: ") || events[1].ScrivUUID == "" {
+		t.Errorf("Expected the console message and document, got %+v", events[:2])
+	}
+	if e := events[4]; e.Kind != SyncCompleted || e.Summary == nil || e.Summary.CreatedInMarkdown != 2 || len(e.Failures) != 0 {
+		t.Errorf("Expected the sync's completion with its summary, got %+v", e)
+	}
+	if e := events[5]; e.Kind != EventInfo || e.Path == "" || e.Message != "Report: "+e.Path {
+		t.Errorf("Expected where the report was written, got %+v", e)
+	}
+}
+
+// TestConsoleRenderer tests that the console prints operation messages
+// indented, notices about the whole sync unindented, and a completed sync
+// as its outcome and summary.
+func TestConsoleRenderer(t *testing.T) {
+	var out bytes.Buffer
+	r := ConsoleRenderer{Out: &out}
+	r.HandleEvent(Event{Kind: OperationStarted, Operation: OpCreateInMarkdown, Message: "Creating in markdown: a.md"})
+	r.HandleEvent(Event{Kind: OperationCompleted, Operation: OpCreateInMarkdown})
+	r.HandleEvent(Event{Kind: EventWarning, Operation: OpUpdateInMarkdown, Message: "revision marks in 'A' were accepted"})
+	r.HandleEvent(Event{Kind: SyncCompleted, Summary: &Summary{CreatedInMarkdown: 1, Failed: 1},
+		Failures: []Failure{{Operation: OpCreateInScrivener, Path: "b.md", Err: errors.New("disk full")}}})
+	r.HandleEvent(Event{Kind: EventWarning, Message: "webhook unreachable"})
+	r.HandleEvent(Event{Kind: EventInfo, Message: "Report: r.md"})
+
+	for _, want := range []string{
+		"  Creating in markdown: a.md\n",
+		"  Warning: revision marks in 'A' were accepted\n",
+		"\nSync completed with 1 failed operation(s):\n  x create in Scrivener b.md: disk full\n",
+		"  Markdown:   1 created, 0 updated\n",
+		"  Failed:     1\n",
+		"  Elapsed:    0s\nWarning: webhook unreachable\nReport: r.md\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestSync_BuiltProject tests mirroring a project built by scrivtest, with
+// documents nested in folders, into the markdown root.
+func TestSync_BuiltProject(t *testing.T) {
+	p := scrivtest.New("Novel").
+		Doc("Draft/Part One/Opening", "The harbor was quiet.").
+		Doc("Draft/Part One/Storm", "Rain, then wind.").
+		Doc("Draft/Epilogue", "Years later.")
+	mirror := config.FolderMapping{MarkdownDir: ".", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newBuiltSyncer(t, p, config.DefaultOptions(), mirror)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	for _, rel := range []string{"part-one/opening.md", "part-one/storm.md", "epilogue.md"} {
+		if !fileExists(filepath.Join(s.mdRoot, rel)) {
+			t.Errorf("Expected %s to be created", rel)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(s.mdRoot, "part-one", "opening.md"))
+	if !strings.Contains(string(data), "The harbor was quiet.") {
+		t.Errorf("Expected the document's content, got %q", data)
+	}
+	if got := s.state.GetPathForUUID(p.UUID("Draft/Part One/Opening")); filepath.Base(got) != "opening.md" {
+		t.Errorf("Expected the document to be linked to opening.md, got %q", got)
+	}
+}
+
+// TestSync_Provenance tests that planned operations carry a reason code and
+// the hashes and times they were decided from.
+func TestSync_Provenance(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToCreateInMarkdown) == 0 {
+		t.Fatal("Expected documents to create in markdown")
+	}
+	created := plan.ToCreateInMarkdown[0]
+	if created.Reason != ReasonNewFile || created.Evidence == nil || created.Evidence.ScrivenerHash == "" || created.Evidence.SyncedHash != "" {
+		t.Errorf("Expected a new file with the Scrivener hash, got %+v", created.Provenance)
+	}
+	plan.Close()
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	mdPath := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(mdPath)
+	os.WriteFile(mdPath, append(data, []byte("\nAn added line.\n")...), 0644)
+
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	defer plan.Close()
+	if len(plan.ToUpdateInScriv) != 1 {
+		t.Fatalf("Expected 1 update in Scrivener, got %d", len(plan.ToUpdateInScriv))
+	}
+	why := plan.ToUpdateInScriv[0].Provenance
+	ev := why.Evidence
+	if why.Reason != ReasonMarkdownChanged || ev == nil || ev.SyncedHash == "" || ev.SyncedHash == ev.MarkdownHash ||
+		ev.SyncedHash != ev.ScrivenerHash || ev.LastSynced == "" || ev.MarkdownModified == "" {
+		t.Errorf("Expected a markdown change with its evidence, got %+v %+v", why, ev)
+	}
+
+	encoded, err := json.Marshal(plan.ToUpdateInScriv[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal change: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"reason":"markdown-changed"`) || !strings.Contains(string(encoded), `"synced_hash":`) {
+		t.Errorf("Expected the reason and evidence in the JSON, got %s", encoded)
+	}
+	if line := why.explain(); !strings.Contains(line, "markdown-changed") || !strings.Contains(line, shortHash(ev.SyncedHash)) {
+		t.Errorf("Unexpected explanation %q", line)
+	}
+}
+
+// TestSync_EncryptState tests that encrypt_state seals the state and reports
+// and that they are read back transparently.
+func TestSync_EncryptState(t *testing.T) {
+	t.Setenv(vault.KeyEnvVar, "test passphrase")
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.EncryptState = true
+	s := newTestSyncer(t, opts, draft)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	data, err := os.ReadFile(s.state.filePath)
+	if err != nil {
+		t.Fatalf("Failed to read state: %v", err)
+	}
+	if !vault.IsSealed(data) || strings.Contains(string(data), "chapter-one") {
+		t.Error("Expected the state file to be sealed")
+	}
+	reports, _ := filepath.Glob(filepath.Join(os.Getenv("HOME"), ".scriv-sync", "reports", "test", "*"))
+	if len(reports) != 1 || filepath.Ext(reports[0]) != ".enc" {
+		t.Fatalf("Expected one encrypted report, got %v", reports)
+	}
+	report, err := vault.Read(reports[0])
+	if err != nil || !strings.Contains(string(report), "chapter-one") {
+		t.Errorf("Expected the report to open, got %v", err)
+	}
+
+	s = reloadSyncer(t, s)
+	if len(s.state.Files) == 0 {
+		t.Fatal("Expected the sealed state to load")
+	}
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	defer plan.Close()
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to do after reloading the sealed state, got %s", plan.Summary())
+	}
+}
+
+// TestSync_Routes tests that routed documents are pulled into their route's
+// directory, sync back from it, and stay there once their label changes.
+func TestSync_Routes(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.Routes = []config.Route{{Keyword: "worldbuilding", Dir: "world"}, {Label: "red", Dir: "scenes"}}
+	s := newTestSyncer(t, opts, draft)
+	s.writer.SetLabel("DOC-UUID-0002", "0")
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	routed := filepath.Join(s.mdRoot, "scenes", "chapter-two.md")
+	if !fileExists(routed) || fileExists(filepath.Join(s.mdRoot, "draft", "chapter-two.md")) {
+		t.Fatal("Expected Chapter Two to be pulled into scenes/ only")
+	}
+	if !fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) {
+		t.Error("Expected Chapter One to stay in draft/")
+	}
+
+	// Edits sync back, and new files in the route directory are left alone
+	os.WriteFile(routed, []byte("Rewritten in scenes.\n"), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "scenes", "new-scene.md"), []byte("New.\n"), 0644)
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToUpdateInScriv) != 1 || len(plan.ToCreateInScriv) != 0 || len(plan.UnmappedDirs) != 0 {
+		t.Errorf("Expected only the routed update, got %s", plan.Summary())
+	}
+	plan.Close()
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	doc, err := s.reader.GetDocumentByUUID("DOC-UUID-0002")
+	if err != nil || !strings.Contains(doc.Content, "Rewritten in scenes.") {
+		t.Errorf("Expected the routed edit in Scrivener, got %v", err)
+	}
+
+	// Removing the label doesn't move the file
+	s.writer.SetLabel("DOC-UUID-0002", "-1")
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	defer plan.Close()
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to do after the label was removed, got %s", plan.Summary())
+	}
+}
+
+// TestSync_TitleRules tests that a mapping's title rules turn numbered
+// filenames into prefixed binder titles and back.
+func TestSync_TitleRules(t *testing.T) {
+	p := scrivtest.New("Novel").
+		Doc("Draft/Chapter 7 — The Fall", "It fell.").
+		Doc("Draft/Prologue", "Before.")
+	rules := config.TitleRules{Prefix: "Chapter {n} — ", NumberWidth: 2}
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true, Titles: rules}
+	s := newBuiltSyncer(t, p, config.DefaultOptions(), draft)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	for _, name := range []string{"07-the-fall.md", "prologue.md"} {
+		if !fileExists(filepath.Join(s.mdRoot, "draft", name)) {
+			t.Errorf("Expected draft/%s to be created", name)
+		}
+	}
+
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "08-the-rise.md"), []byte("It rose.\n"), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	folder, err := s.reader.FindFolderByPath("Draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, doc := range folder.Children {
+		titles = append(titles, doc.Title)
+	}
+	if got := strings.Join(titles, ", "); got != "Chapter 7 — The Fall, Prologue, Chapter 8 — The Rise" {
+		t.Errorf("Unexpected binder titles %q", got)
+	}
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	defer plan.Close()
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to do, got %s", plan.Summary())
+	}
+
+	// Without {n}, strip_numbers drops leading numbers from titles
+	s.setTitleRules("dir", config.TitleRules{StripNumbers: true})
+	if got := s.docTitle("dir", "03-the-fall.md"); got != "The Fall" {
+		t.Errorf("Expected 'The Fall', got %q", got)
+	}
+	if !s.sameDocName("dir", "03-the-fall", "The Fall") {
+		t.Error("Expected 03-the-fall to still belong to 'The Fall'")
+	}
+}
+
+// TestSync_LockedDocuments tests that documents locked by front matter or
+// by a locked status are never changed, with the refused change listed.
+func TestSync_LockedDocuments(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.LockedStatuses = []string{"final"}
+	s := newTestSyncer(t, opts, draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Chapter One is locked in markdown, Chapter Two by its status
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	data, _ := os.ReadFile(chapterOne)
+	os.WriteFile(chapterOne, append([]byte("---\nlocked: true\n---\n"), data...), 0644)
+	locked, _ := os.ReadFile(chapterOne)
+	os.WriteFile(chapterTwo, []byte("Edited in markdown.\n"), 0644)
+	final, err := s.writer.AddStatus("Final")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.writer.SetMetadata("DOC-UUID-0002", scrivener.MetaStatus, final)
+	s.writer.UpdateDocumentContent("DOC-UUID-0001", "Edited in Scrivener.", true)
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() || len(plan.Locked) != 2 {
+		t.Fatalf("Expected two refused changes and nothing else, got %s and %+v", plan.Summary(), plan.Locked)
+	}
+	byPath := map[string]Locked{}
+	for _, l := range plan.Locked {
+		byPath[l.MarkdownPath] = l
+	}
+	if l := byPath[chapterOne]; l.Location != "markdown" || l.LockedBy != "front matter" {
+		t.Errorf("Unexpected refusal for Chapter One: %+v", l)
+	}
+	if l := byPath[chapterTwo]; l.Location != "scrivener" || l.LockedBy != `status "Final"` {
+		t.Errorf("Unexpected refusal for Chapter Two: %+v", l)
+	}
+	plan.Close()
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if data, _ := os.ReadFile(chapterOne); string(data) != string(locked) {
+		t.Errorf("Expected the locked file to be left alone, got %q", data)
+	}
+	s = reloadSyncer(t, s)
+	doc, _ := s.reader.GetDocumentByUUID("DOC-UUID-0002")
+	if doc == nil || strings.Contains(doc.Content, "Edited in markdown.") {
+		t.Error("Expected the document with a locked status to be left alone")
+	}
+
+	// Deleting the markdown of a document with a locked status is refused too
+	os.Remove(chapterTwo)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	defer plan.Close()
+	if len(plan.Orphans) != 0 || len(plan.lockedFor("scrivener")) != 1 {
+		t.Errorf("Expected the deletion to be refused, got %s and %+v", plan.Summary(), plan.Locked)
+	}
+}
+
+// TestWithRetry tests that transient errors are retried and others aren't.
+func TestWithRetry(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = 0
+	if len(transientErrnos) == 0 {
+		t.Skip("no transient errors on this platform")
+	}
+
+	busy := &os.PathError{Op: "open", Path: "chapter.md", Err: transientErrnos[0]}
+	calls := 0
+	err := withRetry(func() error {
+		if calls++; calls < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third try, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	err = withRetry(func() error { calls++; return busy })
+	var transient *TransientError
+	if !errors.As(err, &transient) || calls != retryAttempts || !errors.Is(err, transientErrnos[0]) {
+		t.Errorf("Expected a transient error after %d tries, got %v after %d", retryAttempts, err, calls)
+	}
+
+	calls = 0
+	err = withRetry(func() error { calls++; return os.ErrNotExist })
+	if !errors.Is(err, os.ErrNotExist) || calls != 1 {
+		t.Errorf("Expected a permanent error to fail at once, got %v after %d", err, calls)
+	}
+}
+
+// TestSync_FailedOperations tests that an operation failing doesn't stop
+// the rest of the plan, and is retried by the next sync.
+func TestSync_FailedOperations(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+
+	// A directory where Chapter Two's file should go can't be written
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.MkdirAll(chapterTwo, 0755)
+
+	err := s.Sync(false, false)
+	var partial *FailedOperationsError
+	if !errors.As(err, &partial) || len(partial.Failures) != 1 || partial.Failures[0].Path != chapterTwo {
+		t.Fatalf("Expected Chapter Two to fail alone, got %v", err)
+	}
+	if !fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) || s.state.GetPathForUUID("DOC-UUID-0001") == "" {
+		t.Error("Expected Chapter One to be pulled and recorded")
+	}
+	if s.state.GetPathForUUID("DOC-UUID-0002") != "" {
+		t.Error("Expected the failed file to stay out of the state")
+	}
+
+	os.Remove(chapterTwo)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !fileExists(chapterTwo) {
+		t.Error("Expected the next sync to pull Chapter Two")
+	}
+}
+
+func TestSync_Grep(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// An edit on each side, not yet synced
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.WriteFile(chapterTwo, []byte("The lighthouse keeper waited.\n"), 0644)
+	s.writer.UpdateDocumentContent("DOC-UUID-0001", "The story begins here.\n\nA LIGHTHOUSE on the cliff.", true)
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+
+	matches, err := s.grep(regexp.MustCompile("(?i)lighthouse"))
+	if err != nil {
+		t.Fatalf("grep failed: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("Expected two matching documents, got %+v", matches)
+	}
+	if m := matches[0]; m.Side != "scrivener" || m.Status != "changed in Scrivener" || len(m.ScrivenerLines) != 1 {
+		t.Errorf("Unexpected match for Chapter One: %+v", m)
+	}
+	if m := matches[1]; m.Side != "markdown" || m.Status != "changed in markdown" || m.MarkdownLines[0].Number != 1 {
+		t.Errorf("Unexpected match for Chapter Two: %+v", m)
+	}
+
+	matches, err = s.grep(regexp.MustCompile("story begins"))
+	if err != nil {
+		t.Fatalf("grep failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Side != "both" || !sameLines(matches[0].MarkdownLines, matches[0].ScrivenerLines) {
+		t.Errorf("Expected Chapter One to match on both sides, got %+v", matches)
+	}
+
+	if err := s.Grep("(", false); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestOutline_ExportImport(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions())
+	docs, err := s.reader.GetBinderStructure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := buildOutline(docs)
+
+	// Both formats read back what they wrote, which changes nothing
+	for _, format := range []string{OutlineYAML, OutlineMarkdown} {
+		out, err := formatOutline(items, format)
+		if err != nil {
+			t.Fatalf("formatOutline(%s) failed: %v", format, err)
+		}
+		parsed, err := parseOutline([]byte(out), format)
+		if err != nil {
+			t.Fatalf("parseOutline(%s) failed: %v", format, err)
+		}
+		changes, left, err := applyOutline(s.reader, s.writer, parsed)
+		if err != nil {
+			t.Fatalf("applyOutline(%s) failed: %v", format, err)
+		}
+		if len(changes) != 0 || len(left) != 0 {
+			t.Errorf("%s: expected no changes, got %v, left %v\n%s", format, changes, left, out)
+		}
+	}
+
+	// Group the chapters into a new part, reordered, retitling one
+	edited := "- **Draft** `DRAFT-UUID-0001`\n" +
+		"  - **Part One**\n" +
+		"    - Chapter Two `DOC-UUID-0002` (4 words)\n" +
+		"    - Opening `DOC-UUID-0001`\n" +
+		"      > The story begins.\n" +
+		"- **Research** `RESEARCH-UUID-0001`\n"
+	parsed, err := parseOutline([]byte(edited), OutlineMarkdown)
+	if err != nil {
+		t.Fatalf("parseOutline failed: %v", err)
+	}
+	changes, left, err := applyOutline(s.reader, s.writer, parsed)
+	if err != nil {
+		t.Fatalf("applyOutline failed: %v", err)
+	}
+	if len(changes) != 4 || strings.Join(left, ",") != "Characters,Hero" {
+		t.Errorf("Unexpected changes %v, left %v", changes, left)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := scrivener.NewReader(s.scrivPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := reader.FindFolderByPath("Draft/Part One")
+	if err != nil {
+		t.Fatalf("Expected the new part: %v", err)
+	}
+	var titles []string
+	for _, doc := range part.Children {
+		titles = append(titles, doc.Title)
+	}
+	if strings.Join(titles, ",") != "Chapter Two,Opening" {
+		t.Errorf("Unexpected Part One: %v", titles)
+	}
+
+	nested := "- **Research** `RESEARCH-UUID-0001`\n  - **Draft** `DRAFT-UUID-0001`\n"
+	parsed, _ = parseOutline([]byte(nested), OutlineMarkdown)
+	if _, _, err := applyOutline(reader, s.writer, parsed); err == nil {
+		t.Error("Expected an error for the Draft moved into another folder")
+	}
+}
+
+func TestSync_HistoryAuthors(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	data, err := os.ReadFile(s.historyPath())
+	if err != nil {
+		t.Fatalf("Expected a history file: %v", err)
+	}
+	var entry HistoryEntry
+	if err := json.Unmarshal([]byte(strings.SplitN(string(data), "\n", 2)[0]), &entry); err != nil {
+		t.Fatalf("Failed to parse history: %v", err)
+	}
+	if entry.Operation != OpCreateInMarkdown || !strings.HasPrefix(entry.Path, "draft/") || entry.User == "" {
+		t.Errorf("Unexpected history entry: %+v", entry)
+	}
+	if !fileExists(filepath.Join(filepath.Dir(s.historyPath()), ".gitattributes")) {
+		t.Error("Expected the history to be merged by union in git")
+	}
+
+	// With one author, status names no one
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.authors != nil {
+		t.Errorf("Expected no authors with a single one, got %v", plan.authors)
+	}
+	plan.Close()
+
+	// A collaborator's sync, merged in through git, then a conflict
+	line, _ := json.Marshal(HistoryEntry{Time: time.Now().Add(time.Minute), User: "alice", Host: "laptop", Operation: OpUpdateInScrivener, Path: "draft/chapter-two.md"})
+	f, _ := os.OpenFile(s.historyPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write(append(line, '\n'))
+	f.Close()
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.WriteFile(chapterTwo, []byte("Edited in markdown.\n"), 0644)
+	s.writer.UpdateDocumentContent("DOC-UUID-0002", "Edited in Scrivener.", true)
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plan.Close()
+	if len(plan.Conflicts) != 1 {
+		t.Fatalf("Expected a conflict, got %s", plan.Summary())
+	}
+	if a := plan.authors[chapterTwo]; a.Author() != "alice@laptop" {
+		t.Errorf("Expected the conflict to name alice@laptop, got %+v", a)
+	}
+}
+
+func TestSync_Webhook(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+	}))
+	defer srv.Close()
+
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.Webhook = config.Webhook{URL: srv.URL, Secret: "$TEST_WEBHOOK_SECRET"}
+	s := newTestSyncer(t, opts, draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Expected a JSON payload, got %q: %v", body, err)
+	}
+	if payload.Event != WebhookEvent || payload.Summary == nil || payload.Summary.CreatedInMarkdown != 2 {
+		t.Errorf("Unexpected payload: %s", body)
+	}
+	if payload.Text == "" || payload.Content != payload.Text {
+		t.Errorf("Expected a description for chat webhooks, got %q and %q", payload.Text, payload.Content)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("Expected signature %s, got %s", want, signature)
+	}
+}
+
+// TestSync_FolderNotes tests that folder text and synopses sync through each
+// directory's folder note.
+func TestSync_FolderNotes(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.FolderNotes = config.FolderNotesIndex
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: ".", ScrivenerFolder: "/", SyncEnabled: true})
+	dataDir := filepath.Join(s.scrivPath, "Files", "Data", "FOLDER-UUID-0001")
+	os.MkdirAll(dataDir, 0755)
+	os.WriteFile(filepath.Join(dataDir, "synopsis.txt"), []byte("The cast."), 0644)
+	s = reloadSyncer(t, s)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if err := s.executePlan(plan, false); err != nil {
+		t.Fatalf("Failed to execute plan: %v", err)
+	}
+	notePath := filepath.Join(s.mdRoot, "research", "characters", "index.md")
+	data, err := os.ReadFile(notePath)
+	if err != nil {
+		t.Fatalf("Expected a folder note for Characters: %v", err)
+	}
+	if !strings.Contains(string(data), "synopsis: The cast.") {
+		t.Errorf("Expected the synopsis in the folder note, got %q", data)
+	}
+	if fileExists(filepath.Join(s.mdRoot, "draft", "index.md")) {
+		t.Error("Expected no folder note for a folder without text")
+	}
+
+	// Edits to the note are pushed to the folder, not created as a document
+	os.WriteFile(notePath, []byte("---\nsynopsis: Everyone in the story.\n---\n\nThe people of Harcroft.\n"), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "index.md"), []byte("Part one begins.\n"), 0644)
+	s = reloadSyncer(t, s)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToCreateInScriv) != 0 || len(plan.ToUpdateInScriv) != 2 {
+		t.Fatalf("Expected 2 folder updates and no new documents, got %s", plan.Summary())
+	}
+	if err := s.executePlan(plan, false); err != nil {
+		t.Fatalf("Failed to execute plan: %v", err)
+	}
+
+	s = reloadSyncer(t, s)
+	folder, err := s.reader.GetDocumentByUUID("FOLDER-UUID-0001")
+	if err != nil || folder == nil {
+		t.Fatalf("Failed to find Characters: %v", err)
+	}
+	s.reader.LoadContent(folder)
+	if folder.Synopsis != "Everyone in the story." || !strings.Contains(folder.Content, "The people of Harcroft.") {
+		t.Errorf("Expected the note pushed to the folder, got synopsis %q and text %q", folder.Synopsis, folder.Content)
+	}
+	draft, _ := s.reader.GetDocumentByUUID("DRAFT-UUID-0001")
+	s.reader.LoadContent(draft)
+	if !strings.Contains(draft.Content, "Part one begins.") {
+		t.Errorf("Expected a new note pushed to its folder, got %q", draft.Content)
+	}
+
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync after pushing the notes, got %s", plan.Summary())
+	}
+
+	// Deleting a note leaves its folder alone
+	os.Remove(notePath)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Orphans) != 0 || len(plan.ToCreateInMarkdown) != 1 {
+		t.Errorf("Expected the deleted note written again, got %s", plan.Summary())
+	}
+}
+
+func TestSync_PlainTextExtension(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true, Extension: config.ExtensionText})
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if err := s.executePlan(plan, false); err != nil {
+		t.Fatalf("Failed to execute plan: %v", err)
+	}
+	txtPath := filepath.Join(s.mdRoot, "draft", "chapter-one.txt")
+	data, err := os.ReadFile(txtPath)
+	if err != nil {
+		t.Fatalf("Expected Chapter One pulled as a .txt file: %v", err)
+	}
+	if strings.Contains(string(data), "#") || strings.Contains(string(data), "---") || !strings.Contains(string(data), "The story begins here.") {
+		t.Errorf("Expected plain text without headings or front matter, got %q", data)
+	}
+	if fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) {
+		t.Error("Expected no .md file in a txt mapping")
+	}
+
+	// Markdown-looking text is pushed as it is, not converted
+	os.WriteFile(txtPath, []byte("Not **bold** at all."), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "notes.md"), []byte("Ignored."), 0644)
+	s = reloadSyncer(t, s)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToUpdateInScriv) != 1 || len(plan.ToCreateInScriv) != 0 {
+		t.Fatalf("Expected one update and no new documents, got %s", plan.Summary())
+	}
+	if err := s.executePlan(plan, false); err != nil {
+		t.Fatalf("Failed to execute plan: %v", err)
+	}
+	rtfData, err := os.ReadFile(filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf"))
+	if err != nil {
+		t.Fatalf("Failed to read Chapter One: %v", err)
+	}
+	if !strings.Contains(string(rtfData), "Not **bold** at all.") {
+		t.Errorf("Expected the text stored unconverted, got %q", rtfData)
+	}
+
+	s = reloadSyncer(t, s)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync after the push, got %s", plan.Summary())
+	}
+}
+
+func TestSync_RefusesDamagedProject(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	scrivx := filepath.Join(s.scrivPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	os.WriteFile(scrivx, []byte(strings.Replace(string(data), "DOC-UUID-0003", "DOC-UUID-0001", 1)), 0644)
+	os.MkdirAll(filepath.Join(s.mdRoot, "draft"), 0755)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "new-scene.md"), []byte("A new scene."), 0644)
+	s = reloadSyncer(t, s)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if err := s.executePlan(plan, false); !errors.Is(err, scrivener.ErrIntegrity) {
+		t.Fatalf("Expected the damaged project refused, got %v", err)
+	}
+	if fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) {
+		t.Error("Expected nothing applied to a refused plan")
+	}
+
+	s.SetForce(true)
+	if err := s.executePlan(plan, false); err != nil {
+		t.Fatalf("Expected a forced run to apply the plan, got %v", err)
+	}
+	if !fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) {
+		t.Error("Expected the forced run to pull Chapter One")
+	}
+}
+
+func TestSync_WhitespaceOnlyConflict(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	os.MkdirAll(filepath.Dir(chapterOne), 0755)
+	edited := "# Chapter One\r\nThe story begins here.  \r\nThis is the opening paragraph of our tale.\r\n\r\n\r\n"
+	os.WriteFile(chapterOne, []byte(edited), 0644)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Conflicts) != 1 {
+		t.Fatalf("Expected one conflict, got %s", plan.Summary())
+	}
+	if err := s.executePlan(plan, true); err != nil {
+		t.Fatalf("Failed to execute plan: %v", err)
+	}
+	if got := s.summary.Conflicts[autoResolvedWhitespace]; got != 1 {
+		t.Errorf("Expected the conflict auto-resolved, got %v", s.summary.Conflicts)
+	}
+	if data, _ := os.ReadFile(chapterOne); string(data) == edited || strings.Contains(string(data), "\r") {
+		t.Errorf("Expected the Scrivener version kept, got %q", data)
+	}
+
+	// whitespace_wins: markdown keeps the markdown version instead, and
+	// other differences still conflict
+	if !sameIgnoringWhitespace("a\n\nb  \n", "a\r\nb\r\n\r\n") || sameIgnoringWhitespace("a b", "a  b") || sameIgnoringWhitespace("a", "  a") {
+		t.Error("Expected only trailing whitespace, blank lines and line endings ignored")
+	}
+	opts := config.DefaultOptions()
+	opts.WhitespaceWins = "markdown"
+	s.config.Options = opts
+	os.WriteFile(chapterOne, []byte(edited), 0644)
+	conflict := Conflict{MarkdownPath: chapterOne, MarkdownContent: edited, ScrivenerContent: "# Chapter One\n\nThe story begins here.\n\nThis is the opening paragraph of our tale.\n"}
+	if side, ok := s.whitespaceResolution(conflict); !ok || side != "markdown" {
+		t.Errorf("Expected whitespace_wins: markdown to pick markdown, got %q, %v", side, ok)
+	}
+	conflict.ScrivenerContent = "# Chapter One\n\nThe story starts here.\n"
+	if _, ok := s.whitespaceResolution(conflict); ok {
+		t.Error("Expected a real difference left to the usual resolution")
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	mapping := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true, Template: "templates/chapter.md"}
+	s := newTestSyncer(t, config.DefaultOptions(), mapping)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	os.MkdirAll(filepath.Join(s.mdRoot, "templates"), 0755)
+	os.WriteFile(filepath.Join(s.mdRoot, "templates", "chapter.md"), []byte("# {title}\n\nStart here.\n"), 0644)
+
+	s = reloadSyncer(t, s)
+	if err := s.newDocument(mapping, "Chapter Three"); err != nil {
+		t.Fatalf("Failed to create document: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(s.mdRoot, "draft", "chapter-three.md"))
+	if err != nil {
+		t.Fatalf("Expected the markdown file created: %v", err)
+	}
+	if !strings.Contains(string(data), "# Chapter Three") {
+		t.Errorf("Expected the template filled in, got %q", data)
+	}
+
+	s = reloadSyncer(t, s)
+	folder, err := s.reader.FindFolderByPath("Draft")
+	if err != nil {
+		t.Fatalf("Failed to find Draft: %v", err)
+	}
+	if n := len(folder.Children); n != 3 || folder.Children[2].Title != "Chapter Three" {
+		t.Fatalf("Expected Chapter Three added to Draft, got %d documents", n)
+	}
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected the new document recorded as in sync, got %s", plan.Summary())
+	}
+
+	if err := s.newDocument(mapping, "Chapter Three"); err == nil {
+		t.Error("Expected an error for a document that already exists")
+	}
+	os.Remove(filepath.Join(s.mdRoot, "draft", "chapter-one.md"))
+	if err := s.newDocument(mapping, "chapter one"); err == nil {
+		t.Error("Expected an error for a title the Scrivener folder already has")
+	}
+}
+
+func TestCrossLinks(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	wikiRoot := filepath.Join(filepath.Dir(s.mdRoot), "wiki")
+	os.MkdirAll(wikiRoot, 0755)
+	wikiCfg := &config.ProjectConfig{
+		ScrivPath:      s.config.ScrivPath,
+		LocalPath:      wikiRoot,
+		FolderMappings: []config.FolderMapping{{MarkdownDir: "characters", ScrivenerFolder: "Research/Characters", SyncEnabled: true}},
+		Options:        config.DefaultOptions(),
+	}
+	wiki, err := NewSyncer(wikiCfg, "wiki")
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+	wiki.SetAssumeYes(true)
+	if err := wiki.Sync(false, false); err != nil {
+		t.Fatalf("Failed to sync the wiki: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(wikiRoot, "notes.md"), []byte("Unmapped."), 0644)
+	os.WriteFile(filepath.Join(wikiRoot, "characters", "villain.md"), []byte("New."), 0644)
+	chapter := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	content := strings.Join([]string{
+		"See [the hero](../../wiki/characters/hero.md#past) and [the villain](../../wiki/characters/villain.md).",
+		"Also [the map](../../wiki/map.md), [notes](<../../wiki/notes.md>) and [chapter two](chapter-two.md).",
+		"Not [a file](https://example.com/wiki.md) or [here](#top).",
+		"```",
+		"[code](../../wiki/characters/ghost.md)",
+		"```",
+	}, "\n")
+	os.WriteFile(chapter, []byte(content), 0644)
+
+	novel := &linkProject{alias: "test", config: s.config}
+	projects := []*linkProject{novel, {alias: "wiki", config: wikiCfg}}
+	links, err := crossLinks(novel, projects)
+	if err != nil {
+		t.Fatalf("Failed to find links: %v", err)
+	}
+
+	want := map[string]string{
+		filepath.Join(wikiRoot, "characters", "hero.md"):    "in sync",
+		filepath.Join(wikiRoot, "characters", "villain.md"): "new in markdown",
+		filepath.Join(wikiRoot, "map.md"):                   linkMissing,
+		filepath.Join(wikiRoot, "notes.md"):                 linkUntracked,
+	}
+	if len(links) != len(want) {
+		t.Fatalf("Expected %d cross-project links, got %+v", len(want), links)
+	}
+	for _, link := range links {
+		if link.Source != chapter || link.Alias != "wiki" || want[link.Target] != link.Status {
+			t.Errorf("Unexpected link %+v", link)
+		}
+	}
+	if !links[0].OK() || links[1].OK() || !links[2].Broken() || links[3].Broken() {
+		t.Errorf("Unexpected link checks: %+v", links)
+	}
+}
+
+func TestSync_TOC(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.TOC = config.TOCSynopses
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: ".", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.writer.SetSynopsis("DOC-UUID-0002", "The plot thickens."); err != nil {
+		t.Fatalf("Failed to set synopsis: %v", err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.mdRoot, TOCFileName))
+	if err != nil {
+		t.Fatalf("Expected TOC.md written: %v", err)
+	}
+	toc := string(data)
+	one := strings.Index(toc, "- [Chapter One](chapter-one.md) (")
+	two := strings.Index(toc, "- [Chapter Two](chapter-two.md) (")
+	if !strings.Contains(toc, "\n- Draft (") || one < 0 || two < one {
+		t.Errorf("Expected the chapters linked in binder order, got:\n%s", toc)
+	}
+	if !strings.Contains(toc, "  - [Chapter One](chapter-one.md) (") {
+		t.Errorf("Expected the chapters nested under Draft, got:\n%s", toc)
+	}
+	if !strings.Contains(toc, "    > The plot thickens.") {
+		t.Errorf("Expected Chapter Two's synopsis, got:\n%s", toc)
+	}
+	if strings.Contains(toc, "Characters") || strings.Contains(toc, "Hero") {
+		t.Errorf("Expected items that aren't synced left out, got:\n%s", toc)
+	}
+	words := s.fileWords(filepath.Join(s.mdRoot, "chapter-one.md"))
+	if words == 0 || !strings.Contains(toc, "(chapter-one.md) ("+strconv.Itoa(words)+" words)") {
+		t.Errorf("Expected Chapter One's word count (%d), got:\n%s", words, toc)
+	}
+
+	// The TOC isn't synced as a document of the mapping at the root
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync, got %s", plan.Summary())
+	}
+}
+
+func TestSync_ImpactGate(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.ImpactGate = config.ImpactGate{MaxFiles: 1}
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "chapter-three.md"), []byte("Three."), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "chapter-four.md"), []byte("Four."), 0644)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	impact, err := estimateImpact(plan)
+	if err != nil {
+		t.Fatalf("Failed to estimate impact: %v", err)
+	}
+	if impact.ScrivenerFiles != 3 || impact.ScrivenerBytes != len("Three.")+len("Four.") || impact.ScrivenerWords != 2 || impact.MarkdownFiles != 0 {
+		t.Errorf("Expected two content files and the .scrivx, got %+v", impact)
+	}
+
+	// Over the gate, a run not confirmed writes nothing
+	s = reloadSyncer(t, s)
+	s.SetAssumeYes(false)
+	s.input = strings.NewReader("n\n")
+	if err := s.Sync(false, false); err == nil {
+		t.Fatal("Expected an unconfirmed large plan to stop the sync")
+	}
+	if s.state.GetUUIDForPath(filepath.Join(s.mdRoot, "draft", "chapter-three.md")) != "" {
+		t.Error("Expected nothing pushed")
+	}
+
+	s = reloadSyncer(t, s)
+	s.SetAssumeYes(false)
+	s.input = strings.NewReader("y\n")
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Expected a confirmed plan to run: %v", err)
+	}
+	if s.state.GetUUIDForPath(filepath.Join(s.mdRoot, "draft", "chapter-three.md")) == "" {
+		t.Error("Expected the new chapters pushed")
+	}
+}
+
+// TestSync_Compile tests that sync_compile pulls include-in-compile as a
+// compile key and pushes changes to it back to the binder.
+func TestSync_Compile(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.SyncCompile = true
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	path := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "compile: true\n") {
+		t.Fatalf("Expected the compile key in the front matter, got %q", data)
+	}
+
+	// Push: the compile key excludes the document from compile
+	os.WriteFile(path, []byte(strings.Replace(string(data), "compile: true", "compile: false", 1)), 0644)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	if doc, _ := s.reader.GetDocument("DOC-UUID-0001"); doc == nil || doc.IncludeInCompile {
+		t.Fatalf("Expected the document excluded from compile, got %+v", doc)
+	}
+
+	// A new document is included and gets the key
+	newPath := filepath.Join(s.mdRoot, "draft", "chapter-three.md")
+	os.WriteFile(newPath, []byte("Three."), 0644)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	data, _ = os.ReadFile(newPath)
+	if !strings.Contains(string(data), "compile: true\n") {
+		t.Errorf("Expected the new document's compile key, got %q", data)
+	}
+
+	plan, err := reloadSyncer(t, s).detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing left to sync, got %s", plan.Summary())
+	}
+}
+
+// TestSync_TitleAliases tests that title_aliases pairs a markdown file with
+// a document of a different title, and names pulled files after the alias.
+func TestSync_TitleAliases(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.TitleAliases = config.TitleAliases{"Ch01": "Chapter One", "Ch02": "Chapter Two"}
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	os.MkdirAll(filepath.Join(s.mdRoot, "draft"), 0755)
+	path := filepath.Join(s.mdRoot, "draft", "ch01.md")
+	os.WriteFile(path, []byte("Rewritten opening."), 0644)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToCreateInScriv) != 0 {
+		t.Errorf("Expected ch01.md not pushed as a new document, got %+v", plan.ToCreateInScriv)
+	}
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0].MarkdownPath != path || plan.Conflicts[0].ScrivUUID != "DOC-UUID-0001" {
+		t.Errorf("Expected ch01.md paired with Chapter One, got %+v", plan.Conflicts)
+	}
+	plan.Close()
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if uuid := s.state.GetUUIDForPath(filepath.Join(s.mdRoot, "draft", "ch02.md")); uuid != "DOC-UUID-0002" {
+		t.Errorf("Expected Chapter Two pulled to ch02.md, got %q", uuid)
+	}
+	for _, name := range []string{"chapter-one.md", "chapter-two.md"} {
+		if fileExists(filepath.Join(s.mdRoot, "draft", name)) {
+			t.Errorf("Expected no %s", name)
+		}
+	}
+	draft, _ := reloadSyncer(t, s).reader.FindFolderByPath("Draft")
+	if draft == nil || len(draft.Children) != 2 {
+		t.Errorf("Expected no documents added to Draft, got %+v", draft)
+	}
+}
+
+// TestSync_SafeMode tests that safe mode archives markdown files instead of
+// deleting them, keeps documents out of Scrivener's Trash and keeps both
+// versions of a conflict the default would resolve.
+func TestSync_SafeMode(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.DefaultDeletionAction = "delete"
+	opts.DeletionStyle = "hard"
+	opts.DefaultConflictResolution = "markdown"
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.WriteFile(chapterOne, []byte("Markdown edit."), 0644)
+	if err := s.writer.UpdateDocumentContent("DOC-UUID-0001", "Scrivener edit.", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writer.DeleteItem("DOC-UUID-0002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	s.SetSafeMode(true)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if fileExists(chapterTwo) {
+		t.Error("Expected chapter-two.md moved out of the mapping")
+	}
+	archived, _ := filepath.Glob(filepath.Join(s.mdRoot, ArchiveDirName, "*", "draft", "chapter-two.md"))
+	if len(archived) != 1 {
+		t.Errorf("Expected chapter-two.md archived instead of deleted, got %v", archived)
+	}
+	if data, _ := os.ReadFile(chapterOne); string(data) != "Markdown edit." {
+		t.Errorf("Expected the conflict left as it was, got %q", data)
+	}
+	if s.summary == nil || len(s.summary.Kept) != 2 {
+		t.Errorf("Expected the archive and the conflict reported, got %+v", s.summary)
+	}
+
+	// A markdown file deleted is left in Scrivener
+	os.Remove(filepath.Join(s.mdRoot, "draft", "chapter-one.md"))
+	s = reloadSyncer(t, s)
+	s.SetSafeMode(true)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	draft, _ := reloadSyncer(t, s).reader.FindFolderByPath("Draft")
+	if draft == nil || len(draft.Children) != 1 || draft.Children[0].UUID != "DOC-UUID-0001" {
+		t.Errorf("Expected Chapter One kept out of the Trash, got %+v", draft)
+	}
+}
+
+// TestSync_SessionWords tests that a sync counts the words written in
+// markdown and, with session_history, adds them to Scrivener's writing
+// history.
+func TestSync_SessionWords(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.SessionHistory = true
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if s.summary.SessionWords != 0 {
+		t.Errorf("Expected words pulled from Scrivener not counted, got %d", s.summary.SessionWords)
+	}
+
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "chapter-three.md"), []byte("One two three four five."), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if s.summary.SessionWords != 5 {
+		t.Errorf("Expected 5 words written, got %d", s.summary.SessionWords)
+	}
+
+	days, err := reloadSyncer(t, s).reader.WritingHistory()
+	if err != nil {
+		t.Fatalf("Failed to read writing history: %v", err)
+	}
+	today := time.Now().Format("2006-01-02")
+	if len(days) != 1 || days[0].Date != today || days[0].DraftWords != 5 {
+		t.Errorf("Expected today's 5 Draft words in the writing history, got %+v", days)
+	}
+
+	if got := sessionMessage(1243); got != "You wrote 1,243 words since last sync" {
+		t.Errorf("Unexpected session message %q", got)
+	}
+}
+
+// TestSync_Locale tests that options.locale cases filenames and titles by
+// its language's rules and orders plans by its collation.
+func TestSync_Locale(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.Locale = "tr"
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	s.writer.UpdateTitle("DOC-UUID-0001", "Işık Yolu")
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	path := filepath.Join(s.mdRoot, "draft", "ışık-yolu.md")
+	if s.state.GetUUIDForPath(path) != "DOC-UUID-0001" {
+		t.Fatalf("Expected Işık Yolu pulled to ışık-yolu.md, got %v", s.state.AllTrackedPaths())
+	}
+	if got := s.titleFor("ışık-yolu.md"); got != "Işık Yolu" {
+		t.Errorf("Expected the title cased by Turkish rules, got %q", got)
+	}
+	if got := s.titleFor("istanbul.md"); got != "İstanbul" {
+		t.Errorf("Expected a dotted capital İ, got %q", got)
+	}
+
+	for _, name := range []string{"zebra.md", "çay.md", "cam.md"} {
+		os.WriteFile(filepath.Join(s.mdRoot, "draft", name), []byte("Text."), 0644)
+	}
+	plan, err := reloadSyncer(t, s).detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	defer plan.Close()
+	var order []string
+	for _, fc := range plan.ToCreateInScriv {
+		order = append(order, filepath.Base(fc.MarkdownPath))
+	}
+	if strings.Join(order, " ") != "cam.md çay.md zebra.md" {
+		t.Errorf("Expected the new files in Turkish order, got %v", order)
+	}
+
+	// Without a locale, words starting with a non-ASCII letter are still
+	// title-cased whole
+	s.config.Options.Locale = ""
+	if got := s.titleFor("élan-vital.md"); got != "Élan Vital" {
+		t.Errorf("Expected Élan Vital, got %q", got)
+	}
+}
+
+// TestSync_UnsupportedItems tests that PDFs and other items with no text
+// are listed rather than synced, and never deleted or overwritten.
+func TestSync_UnsupportedItems(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	scrivx := filepath.Join(s.scrivPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	pdf := `<BinderItem UUID="PDF-UUID-0001" Type="PDF"><Title>Map</Title></BinderItem>`
+	os.WriteFile(scrivx, []byte(strings.Replace(string(data), `<BinderItem UUID="DOC-UUID-0002"`, pdf+`<BinderItem UUID="DOC-UUID-0002"`, 1)), 0644)
+	os.MkdirAll(filepath.Join(s.scrivPath, "Files", "Data", "PDF-UUID-0001"), 0755)
+	os.WriteFile(filepath.Join(s.scrivPath, "Files", "Data", "PDF-UUID-0001", "content.pdf"), []byte("%PDF-1.4"), 0644)
+	s = reloadSyncer(t, s)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Unsupported) != 1 || plan.Unsupported[0].ItemType != "PDF" {
+		t.Errorf("Expected the PDF listed as unsupported, got %+v", plan.Unsupported)
+	}
+	if len(plan.ToCreateInMarkdown) != 2 {
+		t.Errorf("Expected only the two chapters pulled, got %+v", plan.ToCreateInMarkdown)
+	}
+
+	// A markdown file with the PDF's title isn't pushed beside it
+	mapPath := filepath.Join(s.mdRoot, "draft", "map.md")
+	os.MkdirAll(filepath.Dir(mapPath), 0755)
+	os.WriteFile(mapPath, []byte("Notes on the map."), 0644)
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.ToCreateInScriv) != 0 || len(plan.Skipped) != 1 {
+		t.Errorf("Expected map.md skipped, got creates %+v, skipped %+v", plan.ToCreateInScriv, plan.Skipped)
+	}
+
+	// Nor is the PDF deleted when a file tracked with it is
+	os.Remove(mapPath)
+	s.state.RecordFile(mapPath, "PDF-UUID-0001", "hash", time.Now())
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Orphans) != 0 {
+		t.Errorf("Expected no orphans, got %+v", plan.Orphans)
+	}
+
+	if err := s.writer.UpdateDocumentContent("PDF-UUID-0001", "Text.", true); err == nil {
+		t.Error("Expected writing text over the PDF to fail")
+	}
+}
+
+// TestSync_RTFPassthrough tests that rtf_passthrough keeps each document's
+// RTF beside its markdown and pushes edits by patching it, so formatting
+// the markdown can't hold survives.
+func TestSync_RTFPassthrough(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.RTFPassthrough = true
+	opts.DefaultDeletionAction = "delete"
+	opts.DeletionStyle = "hard"
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	mdPath := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	rtfPath := filepath.Join(s.mdRoot, "draft", "chapter-one.rtf")
+	original, _ := os.ReadFile(filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf"))
+	if stored, _ := os.ReadFile(rtfPath); string(stored) != string(original) {
+		t.Fatalf("Expected the document's RTF stored beside its markdown, got %q", stored)
+	}
+
+	// Formatting the markdown can't hold survives a push of an edit
+	os.WriteFile(rtfPath, []byte(strings.Replace(string(original), "the opening", "the \\expnd20 opening\\expnd0 ", 1)), 0644)
+	data, _ := os.ReadFile(mdPath)
+	os.WriteFile(mdPath, []byte(strings.Replace(string(data), "story begins", "story starts", 1)), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	pushed, _ := os.ReadFile(filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf"))
+	if !strings.Contains(string(pushed), "\\expnd20 opening") || !strings.Contains(string(pushed), "story starts") {
+		t.Errorf("Expected the edit patched into the stored RTF, got %q", pushed)
+	}
+	if stored, _ := os.ReadFile(rtfPath); string(stored) != string(pushed) {
+		t.Errorf("Expected the stored RTF refreshed, got %q", stored)
+	}
+
+	// New documents get one, and it goes with its markdown file
+	newPath := filepath.Join(s.mdRoot, "draft", "chapter-three.md")
+	os.WriteFile(newPath, []byte("Three."), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !fileExists(rtfSidecar(newPath)) {
+		t.Error("Expected chapter-three.rtf stored")
+	}
+	if err := s.writer.DeleteItem("DOC-UUID-0001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if fileExists(mdPath) || fileExists(rtfPath) {
+		t.Error("Expected chapter-one.md and its RTF deleted with the document")
+	}
+}
+
+// TestSync_RTFPassthroughSaveFails tests that the RTF kept beside a
+// markdown file is only refreshed once the Scrivener project is saved.
+func TestSync_RTFPassthroughSaveFails(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.RTFPassthrough = true
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	mdPath := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	rtfPath := rtfSidecar(mdPath)
+	contentPath := filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf")
+	original, _ := os.ReadFile(rtfPath)
+
+	// The document's content can't be written, so the project isn't saved
+	blocker := contentPath + ".scriv-sync-tmp"
+	os.Mkdir(blocker, 0755)
+	os.WriteFile(filepath.Join(blocker, "keep"), nil, 0644)
+	data, _ := os.ReadFile(mdPath)
+	os.WriteFile(mdPath, []byte(strings.Replace(string(data), "story begins", "story starts", 1)), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err == nil {
+		t.Fatal("Expected the sync to fail saving the project")
+	}
+	if stored, _ := os.ReadFile(rtfPath); string(stored) != string(original) {
+		t.Errorf("Expected the stored RTF left alone by a failed save, got %q", stored)
+	}
+
+	os.RemoveAll(blocker)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	pushed, _ := os.ReadFile(contentPath)
+	if stored, _ := os.ReadFile(rtfPath); !strings.Contains(string(pushed), "story starts") || string(stored) != string(pushed) {
+		t.Errorf("Expected the stored RTF refreshed with the pushed edit, got %q", stored)
+	}
+}
+
+// TestProjectHealth tests the last sync, tracked file and missing
+// Scrivener project columns of list.
+func TestProjectHealth(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+
+	h := projectHealth(s.config, "test", true)
+	if h.LastSync != nil || h.TrackedFiles != 0 || h.Pending != 2 || h.ScrivMissing {
+		t.Errorf("Expected a never-synced project with 2 pending, got %+v", h)
+	}
+	if label := h.healthLabel(); label != "changes pending" {
+		t.Errorf("Expected changes pending, got %s", label)
+	}
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	h = projectHealth(s.config, "test", true)
+	if h.LastSync == nil || h.TrackedFiles != 2 || h.Pending != 0 || h.Mappings != 1 {
+		t.Errorf("Expected a synced project tracking 2 files, got %+v", h)
+	}
+	if label := h.healthLabel(); label != "ok" {
+		t.Errorf("Expected ok, got %s", label)
+	}
+
+	missing := *s.config
+	missing.ScrivPath = filepath.Join(t.TempDir(), "Gone.scriv")
+	h = projectHealth(&missing, "test", true)
+	if !h.ScrivMissing || h.Pending != -1 || h.TrackedFiles != 2 {
+		t.Errorf("Expected the Scrivener project missing and not checked, got %+v", h)
+	}
+	if label := h.healthLabel(); label != "scrivener missing" {
+		t.Errorf("Expected scrivener missing, got %s", label)
+	}
+
+	// list reads the projects of the global config
+	cfg, err := config.LoadGlobal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AddProject("test", s.config.LocalPath, s.config.ScrivPath)
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunList(true); err != nil {
+		t.Errorf("RunList failed: %v", err)
+	}
+
+	// The table keeps each project's paths
+	var out bytes.Buffer
+	printProjectHealth(&out, []ProjectHealth{h}, false)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 || strings.Index(lines[0], "LOCAL") != strings.Index(lines[1], h.LocalPath) ||
+		strings.Index(lines[0], "SCRIVENER") != strings.Index(lines[1], h.ScrivPath) {
+		t.Errorf("Expected LOCAL and SCRIVENER columns holding the paths, got:\n%s", out.String())
+	}
+}
+
+// TestSync_OrphanDeleteTrashesDocument tests that deleting the document of
+// a removed markdown file moves it to Scrivener's Trash and forgets it.
+func TestSync_OrphanDeleteTrashesDocument(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.DefaultDeletionAction = "delete"
+	s := newTestSyncer(t, opts, config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	mdPath := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.Remove(mdPath)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	s = reloadSyncer(t, s)
+	trash, err := s.reader.FindFolderByPath("Trash")
+	if err != nil || len(trash.Children) != 1 || trash.Children[0].UUID != "DOC-UUID-0002" {
+		t.Errorf("Expected Chapter Two in the Trash, got %+v", trash)
+	}
+	if s.state.GetFileState(mdPath) != nil {
+		t.Error("Expected the trashed document's file forgotten")
+	}
+}
+
+// TestMoveToTrash tests where each system's trash puts a removed file,
+// including a second file of the same name.
+func TestMoveToTrash(t *testing.T) {
+	tests := []struct {
+		name     string
+		goos     string
+		xdg      bool   // set XDG_DATA_HOME
+		trash    string // trash directory, relative to HOME
+		files    string // where files go, relative to the trash
+		wantInfo bool   // a freedesktop.org .trashinfo is written
+		wantErr  bool
+	}{
+		{name: "linux", goos: "linux", trash: ".local/share/Trash", files: "files", wantInfo: true},
+		{name: "linux with XDG_DATA_HOME", goos: "linux", xdg: true, trash: "data/Trash", files: "files", wantInfo: true},
+		{name: "darwin", goos: "darwin", trash: ".Trash", files: "."},
+		{name: "windows", goos: "windows", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_DATA_HOME", "")
+			if tt.xdg {
+				t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+			}
+			src := t.TempDir()
+
+			var dests []string
+			for i, content := range []string{"first", "second"} {
+				path := filepath.Join(src, "chapter-one.md")
+				os.WriteFile(path, []byte(content), 0644)
+				dest, err := moveToTrashOn(tt.goos, path)
+				if tt.wantErr {
+					if err == nil {
+						t.Fatal("Expected an error")
+					}
+					if !fileExists(path) {
+						t.Error("Expected the file left in place")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("moveToTrash %d failed: %v", i+1, err)
+				}
+				if fileExists(path) {
+					t.Errorf("Expected %s moved", path)
+				}
+				if data, _ := os.ReadFile(dest); string(data) != content {
+					t.Errorf("Expected %q at %s, got %q", content, dest, data)
+				}
+				dests = append(dests, dest)
+			}
+
+			files := filepath.Join(home, filepath.FromSlash(tt.trash), tt.files)
+			want := []string{filepath.Join(files, "chapter-one.md"), filepath.Join(files, "chapter-one-2.md")}
+			for i := range want {
+				if dests[i] != want[i] {
+					t.Errorf("Expected file %d at %s, got %s", i+1, want[i], dests[i])
+				}
+				info := filepath.Join(home, filepath.FromSlash(tt.trash), "info", filepath.Base(want[i])+".trashinfo")
+				data, err := os.ReadFile(info)
+				if !tt.wantInfo {
+					if err == nil {
+						t.Errorf("Expected no %s", info)
+					}
+					continue
+				}
+				if err != nil || !strings.Contains(string(data), "Path="+filepath.Join(src, "chapter-one.md")+"\n") {
+					t.Errorf("Expected %s to record the original path, got %q (%v)", info, data, err)
+				}
+			}
+		})
+	}
+}
+
+// TestArchiveFile_PreservesRelativePath tests that archived deletions are recoverable.
+func TestArchiveFile_PreservesRelativePath(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "draft", "chapter-one.md")
+	os.MkdirAll(filepath.Dir(src), 0755)
+	os.WriteFile(src, []byte("content"), 0644)
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	dest, err := archiveFile(root, src, now)
+	if err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+
+	expected := filepath.Join(root, ArchiveDirName, "20250102-030405", "draft", "chapter-one.md")
+	if dest != expected {
+		t.Errorf("Expected %s, got %s", expected, dest)
+	}
+	if fileExists(src) {
+		t.Error("Source file should be moved")
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "content" {
+		t.Errorf("Archived content mismatch: %q", data)
+	}
+}
+
+// newTestSyncer creates a Syncer over a copy of the sample project with state under a temp HOME.
+func newTestSyncer(t *testing.T, opts config.Options, mappings ...config.FolderMapping) *Syncer {
+	t.Helper()
+
+	tmpDir := copyTestProject(t)
+	t.Setenv("HOME", tmpDir)
+	mdPath := filepath.Join(tmpDir, "markdown")
+	os.MkdirAll(mdPath, 0755)
+
+	cfg := &config.ProjectConfig{
+		ScrivPath:      filepath.Join(tmpDir, "sample.scriv"),
+		LocalPath:      mdPath,
+		FolderMappings: mappings,
+		Options:        opts,
+	}
+
+	syncer, err := NewSyncer(cfg, "test")
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+	syncer.SetAssumeYes(true)
+	return syncer
+}
+
+// reloadSyncer re-reads the Scrivener project after writes made outside the syncer.
+func reloadSyncer(t *testing.T, s *Syncer) *Syncer {
+	t.Helper()
+
+	reloaded, err := NewSyncer(s.config, s.alias)
+	if err != nil {
+		t.Fatalf("Failed to reload syncer: %v", err)
+	}
+	reloaded.SetAssumeYes(true)
+	return reloaded
+}
+
+// newBuiltSyncer creates a syncer for a project built by scrivtest, with an
+// empty markdown root beside it.
+func newBuiltSyncer(t *testing.T, p *scrivtest.Project, opts config.Options, mappings ...config.FolderMapping) *Syncer {
+	t.Helper()
+
+	scrivPath := p.Build(t)
+	tmpDir := filepath.Dir(scrivPath)
+	t.Setenv("HOME", tmpDir)
+	mdPath := filepath.Join(tmpDir, "markdown")
+	os.MkdirAll(mdPath, 0755)
+
+	cfg := &config.ProjectConfig{
+		ScrivPath:      scrivPath,
+		LocalPath:      mdPath,
+		FolderMappings: mappings,
+		Options:        opts,
+	}
+
+	syncer, err := NewSyncer(cfg, "test")
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+	syncer.SetAssumeYes(true)
+	return syncer
+}
+
+// TestSync_DuplicateTitles tests that duplicate Scrivener titles are warned
+// about, reported or disambiguated.
+func TestSync_DuplicateTitles(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+
+	opts := config.DefaultOptions()
+	s := newTestSyncer(t, opts, draft)
+	draftUUID, _ := s.writer.FindFolderByTitle("Draft")
+	first, _ := s.writer.CreateDocument("Prologue", "First prologue", draftUUID, true)
+	second, _ := s.writer.CreateDocument("Prologue", "Second prologue", draftUUID, true)
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+
+	// By default they sync to numbered files, with a warning given once
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(s.state.Warned) != 1 || s.state.WarnOnce("duplicate_titles:Draft/prologue") {
+		t.Errorf("Expected the duplicate title warned about once, got %v", s.state.Warned)
+	}
+
+	s.config.Options.DuplicateTitles = "error"
+	if _, err := s.detectAllChanges(); err == nil || !strings.Contains(err.Error(), "duplicate document titles") {
+		t.Fatalf("Expected duplicate title error, got %v", err)
+	}
+
+	s.config.Options.DuplicateTitles = "disambiguate"
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+
+	paths := make(map[string]string)
+	for _, fc := range plan.ToCreateInMarkdown {
+		paths[fc.ScrivUUID] = filepath.Base(fc.MarkdownPath)
+	}
+	if paths[first] != "prologue.md" || paths[second] != "prologue-2.md" {
+		t.Errorf("Unexpected disambiguated paths: %v", paths)
+	}
+}
+
+// TestSync_WholeBinderMapping tests that a "/" mapping mirrors the binder as directories.
+func TestSync_WholeBinderMapping(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: ".", ScrivenerFolder: "/", SyncEnabled: true})
+
+	notePath := filepath.Join(s.mdRoot, "notes", "idea.md")
+	os.MkdirAll(filepath.Dir(notePath), 0755)
+	os.WriteFile(notePath, []byte("An idea"), 0644)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+
+	created := make(map[string]bool)
+	for _, fc := range plan.ToCreateInMarkdown {
+		rel, _ := filepath.Rel(s.mdRoot, fc.MarkdownPath)
+		created[rel] = true
+	}
+	expected := []string{
+		filepath.Join("draft", "chapter-one.md"),
+		filepath.Join("draft", "chapter-two.md"),
+		filepath.Join("research", "characters", "hero.md"),
+	}
+	for _, e := range expected {
+		if !created[e] {
+			t.Errorf("Expected %s to be created in markdown, got %v", e, created)
+		}
+	}
+
+	if len(plan.ToCreateInScriv) != 1 {
+		t.Fatalf("Expected 1 document to create in Scrivener, got %d", len(plan.ToCreateInScriv))
+	}
+
+	if err := s.executePlan(plan, false); err != nil {
+		t.Fatalf("Failed to execute plan: %v", err)
+	}
+
+	reader, err := scrivener.NewReader(s.scrivPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes, err := reader.FindFolderByPath("/Notes")
+	if err != nil {
+		t.Fatalf("Expected Notes folder to be created: %v", err)
+	}
+	if len(notes.Children) != 1 || notes.Children[0].Title != "Idea" {
+		t.Errorf("Expected Idea document in Notes folder, got %v", notes.Children)
+	}
+	if !fileExists(filepath.Join(s.mdRoot, "research", "characters", "hero.md")) {
+		t.Error("Expected nested markdown file to be written")
+	}
+}
+
+// TestSync_ApplySavedPlanSkipsStale tests that a saved plan is replayed with staleness checks.
+func TestSync_ApplySavedPlanSkipsStale(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	s.SetPlanOutput(planPath)
+	if err := s.Sync(true, false); err != nil {
+		t.Fatalf("Dry-run sync failed: %v", err)
+	}
+
+	pf, err := LoadPlanFile(planPath)
+	if err != nil {
+		t.Fatalf("Failed to load plan: %v", err)
+	}
+	if pf.Alias != "test" || len(pf.Plan.ToCreateInMarkdown) != 2 {
+		t.Fatalf("Unexpected plan: alias=%s creates=%d", pf.Alias, len(pf.Plan.ToCreateInMarkdown))
+	}
+
+	// Someone writes chapter two by hand after the plan was made
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.MkdirAll(filepath.Dir(chapterTwo), 0755)
+	os.WriteFile(chapterTwo, []byte("hand written"), 0644)
+
+	if err := s.Apply(planPath, false, false); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if !fileExists(filepath.Join(s.mdRoot, "draft", "chapter-one.md")) {
+		t.Error("Valid operation should be applied")
+	}
+	if data, _ := os.ReadFile(chapterTwo); string(data) != "hand written" {
+		t.Errorf("Stale operation should be skipped, got %q", data)
+	}
+}
+
+// TestSync_WritesReport tests that executing a plan leaves an audit report.
+func TestSync_WritesReport(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	dir, _ := config.ReportsDir("test")
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one report in %s, got %v (%v)", dir, entries, err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	report := string(data)
+	if !strings.Contains(report, "- Operations: 2") {
+		t.Errorf("Report should count operations, got:\n%s", report)
+	}
+	if !strings.Contains(report, "| create in markdown |") || !strings.Contains(report, "chapter-one.md") {
+		t.Errorf("Report should list each operation, got:\n%s", report)
+	}
+}
+
+// TestSync_Bookmarks tests that favorites are written to the index and index additions pushed back.
+func TestSync_Bookmarks(t *testing.T) {
+	opts := config.DefaultOptions()
+	opts.SyncBookmarks = true
+	opts.PushBookmarks = true
+	s := newTestSyncer(t, opts,
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+
+	s.writer.AddFavorite("DOC-UUID-0001")
+	s.writer.Save()
+	s = reloadSyncer(t, s)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	indexPath := filepath.Join(s.mdRoot, BookmarksFileName)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("Bookmarks index not written: %v", err)
+	}
+	if !strings.Contains(string(data), "- [Chapter One](draft/chapter-one.md)") {
+		t.Errorf("Index should link favorites, got:\n%s", data)
+	}
+
+	// Add chapter two to the index and sync again
+	os.WriteFile(indexPath, append(data, []byte("- [Chapter Two](draft/chapter-two.md)\n")...), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	reader, _ := scrivener.NewReader(s.scrivPath)
+	if favorites := reader.GetFavorites(); len(favorites) != 2 {
+		t.Errorf("Expected 2 favorites after push, got %v", favorites)
+	}
+}
+
+// TestSync_ExcludeFolders tests that excluded Scrivener folders are skipped during detection.
+func TestSync_ExcludeFolders(t *testing.T) {
+	mapping := config.FolderMapping{
+		MarkdownDir:     ".",
+		ScrivenerFolder: "Draft",
+		SyncEnabled:     true,
+		ExcludeFolders:  []string{"front matter", "*Back*"},
+	}
+	s := newTestSyncer(t, config.DefaultOptions(), mapping)
+
+	front, _ := s.writer.CreateFolderPath("Draft/Front Matter")
+	s.writer.CreateDocument("Title Page", "Title", front, true)
+	back, _ := s.writer.CreateFolderPath("Draft/Backmatter")
+	s.writer.CreateDocument("Acknowledgements", "Thanks", back, true)
+	part, _ := s.writer.CreateFolderPath("Draft/Part One")
+	s.writer.CreateDocument("Opening", "Opening", part, true)
+	s.writer.Save()
+	s = reloadSyncer(t, s)
+
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+
+	var titles []string
+	for _, fc := range plan.ToCreateInMarkdown {
+		titles = append(titles, fc.Title)
+	}
+	got := strings.Join(titles, ",")
+	if strings.Contains(got, "Title Page") || strings.Contains(got, "Acknowledgements") {
+		t.Errorf("Excluded folder contents should be skipped, got %s", got)
+	}
+	if !strings.Contains(got, "Opening") || !strings.Contains(got, "Chapter One") {
+		t.Errorf("Other documents should still sync, got %s", got)
+	}
+}
+
+// TestDecodeText tests encoding detection and the round trip back to each encoding.
+func TestDecodeText(t *testing.T) {
+	text := "Café “quoted” — done"
+
+	tests := []struct {
+		name string
+		enc  textEncoding
+	}{
+		{"utf-8", encodingUTF8},
+		{"utf-8 with BOM", encodingUTF8BOM},
+		{"utf-16le", encodingUTF16LE},
+		{"utf-16be", encodingUTF16BE},
+		{"windows-1252", encodingCP1252},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeText(text, tt.enc)
+			got, enc := decodeText(data)
+			if got != text {
+				t.Errorf("Expected %q, got %q", text, got)
+			}
+			if enc != tt.enc {
+				t.Errorf("Expected encoding %s, got %s", tt.enc, enc)
+			}
+		})
+	}
+
+	// UTF-16 without a BOM is recognized by its zero bytes
+	noBOM := encodeText("Plain ASCII text", encodingUTF16LE)[2:]
+	if got, enc := decodeText(noBOM); got != "Plain ASCII text" || enc != encodingUTF16LE {
+		t.Errorf("Expected BOM-less UTF-16LE to decode, got %q (%s)", got, enc)
+	}
+}
+
+// TestSync_PreservesMarkdownEncoding tests that UTF-16 markdown is pushed as text
+// and pulled back in its original encoding.
+func TestSync_PreservesMarkdownEncoding(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions(),
+		config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true})
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	mdPath := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	os.WriteFile(mdPath, encodeText("# Chapter One\n\nRésumé of the story.", encodingUTF16LE), 0644)
+
+	s = reloadSyncer(t, s)
+	if err := s.Push(false, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	s = reloadSyncer(t, s)
+	docs, _ := s.reader.GetAllDocuments()
+	var content string
+	for _, doc := range docs {
+		if doc.UUID == "DOC-UUID-0001" {
+			content = doc.Content
+		}
+	}
+	if !strings.Contains(content, "Résumé of the story.") {
+		t.Fatalf("Expected decoded text in Scrivener, got %q", content)
+	}
+
+	// Change the document in Scrivener and pull it back
+	s.writer.UpdateDocumentContent("DOC-UUID-0001", "# Chapter One\n\nRésumé, revised.", true)
+	s.writer.Save()
+	s = reloadSyncer(t, s)
+	if err := s.Pull(false, false); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(mdPath)
+	got, enc := decodeText(data)
+	if enc != encodingUTF16LE {
+		t.Errorf("Expected file to stay UTF-16LE, got %s", enc)
+	}
+	if !strings.Contains(got, "Résumé, revised.") {
+		t.Errorf("Expected pulled content, got %q", got)
+	}
+}
+
+// newBenchSyncer creates a Syncer over a generated project with the Draft
+// subtree mirrored into the markdown root and state under a temp HOME.
+func newBenchSyncer(b *testing.B, opts scrivgen.Options) *Syncer {
+	b.Helper()
+
+	tmpDir := b.TempDir()
+	b.Setenv("HOME", tmpDir)
+	scrivPath, err := scrivgen.Generate(filepath.Join(tmpDir, "bench.scriv"), opts)
+	if err != nil {
+		b.Fatalf("Failed to generate project: %v", err)
+	}
+	mdPath := filepath.Join(tmpDir, "markdown")
+	os.MkdirAll(mdPath, 0755)
+
+	cfg := &config.ProjectConfig{
+		ScrivPath: scrivPath,
+		LocalPath: mdPath,
+		FolderMappings: []config.FolderMapping{
+			{MarkdownDir: ".", ScrivenerFolder: "Draft", SyncEnabled: true},
+		},
+		Options: config.DefaultOptions(),
+	}
+
+	syncer, err := NewSyncer(cfg, "bench")
+	if err != nil {
+		b.Fatalf("Failed to create syncer: %v", err)
+	}
+	syncer.SetAssumeYes(true)
+	return syncer
+}
+
+// BenchmarkDetectChanges measures planning over an already-synced project.
+func BenchmarkDetectChanges(b *testing.B) {
+	s := newBenchSyncer(b, scrivgen.DefaultOptions())
+	if err := s.Sync(false, false); err != nil {
+		b.Fatalf("Initial sync failed: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.detectAllChanges(); err != nil {
+			b.Fatalf("Failed to detect changes: %v", err)
+		}
+	}
+}
+
+// BenchmarkSync measures a full initial pull of a generated project.
+func BenchmarkSync(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s := newBenchSyncer(b, scrivgen.DefaultOptions())
+		b.StartTimer()
+
+		if err := s.Sync(false, false); err != nil {
+			b.Fatalf("Sync failed: %v", err)
+		}
+	}
+}
+
+// TestPlan_SpillsContentOverBudget tests that plan content beyond the memory
+// budget goes to temp files and reads back unchanged.
+func TestPlan_SpillsContentOverBudget(t *testing.T) {
+	plan := NewPlan()
+	plan.store = &contentStore{budget: 8}
+
+	plan.AddCreateInMarkdown("/md/small.md", "UUID-1", "Small", "tiny")
+	plan.AddUpdateInScriv("/md/large.md", "UUID-2", "Large", "content over the budget")
+	plan.AddConflict("/md/both.md", "UUID-3", "Both", "markdown side", "scrivener side")
+
+	if fc := plan.ToCreateInMarkdown[0]; fc.spill != "" || fc.Content != "tiny" {
+		t.Errorf("Expected content within budget to stay in memory, got %+v", fc)
+	}
+	spilled := plan.ToUpdateInScriv[0]
+	if spilled.spill == "" || spilled.Content != "" {
+		t.Fatalf("Expected content over budget to be spilled, got %+v", spilled)
+	}
+	if content, err := spilled.content(); err != nil || content != "content over the budget" {
+		t.Errorf("Spilled content mismatch: %q, %v", content, err)
+	}
+	if content, err := plan.Conflicts[0].scrivenerContent(); err != nil || content != "scrivener side" {
+		t.Errorf("Spilled conflict content mismatch: %q, %v", content, err)
+	}
+
+	inlined, err := plan.inline()
+	if err != nil {
+		t.Fatalf("Failed to inline plan: %v", err)
+	}
+	if inlined.ToUpdateInScriv[0].Content != "content over the budget" || inlined.Conflicts[0].MarkdownContent != "markdown side" {
+		t.Errorf("Expected inlined plan to carry all content, got %+v", inlined)
+	}
+
+	if err := plan.Close(); err != nil {
+		t.Fatalf("Failed to close plan: %v", err)
+	}
+	if fileExists(spilled.spill) {
+		t.Error("Expected temp files to be removed on close")
+	}
+}
+
+// copyDir copies a directory tree.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(src, path)
+		dstPath := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, info.Mode())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dstPath, data, info.Mode())
+	})
+	if err != nil {
+		t.Fatalf("Failed to copy %s: %v", src, err)
+	}
+}
+
+// TestSync_ProjectCopyDetected tests that state recorded against one copy of
+// a project is not silently used with another.
+func TestSync_ProjectCopyDetected(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Initial sync failed: %v", err)
+	}
+
+	original := s.config.ScrivPath
+	dir := filepath.Dir(original)
+
+	// A copy next to the original has the same Identifier
+	copyPath := filepath.Join(dir, "copy.scriv")
+	copyDir(t, original, copyPath)
+	s.config.ScrivPath = copyPath
+	_, err := NewSyncer(s.config, s.alias)
+	var mismatch *ProjectMismatchError
+	if !errors.As(err, &mismatch) || !mismatch.SameProject {
+		t.Fatalf("Expected a same-project mismatch, got %v", err)
+	}
+
+	// A different project is reported as such
+	otherPath := filepath.Join(dir, "other.scriv")
+	copyDir(t, original, otherPath)
+	scrivx := filepath.Join(otherPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	os.WriteFile(scrivx, []byte(strings.Replace(string(data), "TEST-PROJECT-ID", "OTHER-PROJECT-ID", 1)), 0644)
+	s.config.ScrivPath = otherPath
+	_, err = NewSyncer(s.config, s.alias)
+	if !errors.As(err, &mismatch) || mismatch.SameProject {
+		t.Fatalf("Expected a different-project mismatch, got %v", err)
+	}
+
+	// Rebaselining against the copy records the identical documents as in sync
+	s.config.ScrivPath = copyPath
+	unchecked, err := newSyncer(s.config, s.alias, false)
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+	matched, err := unchecked.Rebaseline()
+	if err != nil {
+		t.Fatalf("Rebaseline failed: %v", err)
+	}
+	if matched != 2 {
+		t.Errorf("Expected 2 documents matched, got %d", matched)
+	}
+
+	rebased, err := NewSyncer(s.config, s.alias)
+	if err != nil {
+		t.Fatalf("Expected the rebaselined state to be accepted: %v", err)
+	}
+	plan, err := rebased.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync after rebaselining, got: %s", plan.Summary())
+	}
+}
+
+// TestSync_ProjectMoveFollowed tests that a project moved to a new path is
+// followed without intervention.
+func TestSync_ProjectMoveFollowed(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Initial sync failed: %v", err)
+	}
+
+	moved := filepath.Join(filepath.Dir(s.config.ScrivPath), "moved.scriv")
+	if err := os.Rename(s.config.ScrivPath, moved); err != nil {
+		t.Fatal(err)
+	}
+	s.config.ScrivPath = moved
+
+	reloaded, err := NewSyncer(s.config, s.alias)
+	if err != nil {
+		t.Fatalf("Expected a moved project to be followed: %v", err)
+	}
+	plan, err := reloaded.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync after a move, got: %s", plan.Summary())
+	}
+}
+
+// TestSync_StatsRecordsMarkdownWords tests that a sync records the markdown
+// word count for the day.
+func TestSync_StatsRecordsMarkdownWords(t *testing.T) {
+	if n := countWords("# Title\n\n- one *two*\n- 3 — four"); n != 5 {
+		t.Errorf("Expected 5 words, got %d", n)
+	}
+
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	expected := 0
+	for _, path := range s.state.AllTrackedPaths() {
+		data, _ := os.ReadFile(path)
+		expected += countWords(string(data))
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	today := time.Now().Format("2006-01-02")
+	if len(stats) != 1 || stats[0].Date != today || stats[0].MarkdownWords == nil {
+		t.Fatalf("Expected today's markdown count, got %+v", stats)
+	}
+	if *stats[0].MarkdownWords != expected || expected == 0 {
+		t.Errorf("Expected %d markdown words, got %d", expected, *stats[0].MarkdownWords)
+	}
+}
+
+func TestFrontMatter_SplitAndCanonical(t *testing.T) {
+	content := "---\nsection_type: Scene\ntags: [draft]\n---\n\nBody text\n"
+	fm, body, ok := splitFrontMatter(content)
+	if !ok || fm.SectionType != "Scene" || body != "Body text\n" {
+		t.Fatalf("Unexpected split: %+v %q %v", fm, body, ok)
+	}
+	if _, ok := fm.Extra["tags"]; !ok {
+		t.Error("Expected unmanaged keys to be kept")
+	}
+
+	if got := canonicalContent(content, managedKeys{}); got != "---\nsection_type: Scene\n---\n\nBody text\n" {
+		t.Errorf("Unexpected canonical content: %q", got)
+	}
+	if got := canonicalContent("---\ntags: [draft]\n---\n\nBody", managedKeys{}); got != "Body" {
+		t.Errorf("Expected unmanaged-only front matter to drop, got %q", got)
+	}
+	if got := canonicalContent("---\n\nA horizontal rule, not front matter", managedKeys{}); got != "---\n\nA horizontal rule, not front matter" {
+		t.Errorf("Expected content without front matter unchanged, got %q", got)
+	}
+}
+
+// TestSync_SectionTypes tests that section types sync as front matter and
+// that mappings assign them to documents created by push.
+func TestSync_SectionTypes(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true, SectionType: "Scene"}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+
+	scrivx := filepath.Join(s.config.ScrivPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	sections := `<SectionTypes>
+        <SectionType ID="ST-CHAPTER"><Title>Chapter</Title></SectionType>
+        <SectionType ID="ST-SCENE"><Title>Scene</Title></SectionType>
+    </SectionTypes>
+</ScrivenerProject>`
+	os.WriteFile(scrivx, []byte(strings.Replace(string(data), "</ScrivenerProject>", sections, 1)), 0644)
+
+	s = reloadSyncer(t, s)
+	if err := s.writer.SetSectionType("DOC-UUID-0001", "ST-CHAPTER"); err != nil {
+		t.Fatalf("Failed to set section type: %v", err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	md, _ := os.ReadFile(chapterOne)
+	if !strings.HasPrefix(string(md), "---\nsection_type: Chapter\n---\n\n") {
+		t.Fatalf("Expected section type front matter, got:\n%s", md)
+	}
+
+	// Change the section type from markdown and add a new document
+	os.WriteFile(chapterOne, []byte(strings.Replace(string(md), "Chapter\n", "Scene\n", 1)), 0644)
+	os.WriteFile(filepath.Join(s.mdRoot, "draft", "interlude.md"), []byte("A new scene."), 0644)
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if got := s.writer.GetSectionType("DOC-UUID-0001"); got != "ST-SCENE" {
+		t.Errorf("Expected section type ST-SCENE, got %q", got)
+	}
+	newUUID := s.state.GetUUIDForPath(filepath.Join(s.mdRoot, "draft", "interlude.md"))
+	if got := s.writer.GetSectionType(newUUID); got != "ST-SCENE" {
+		t.Errorf("Expected the mapping's section type on the new document, got %q", got)
+	}
+
+	// Everything is in sync afterwards
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync, got: %s", plan.Summary())
+	}
+}
+
+func TestSync_FrontMatterOnlyPush(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft)
+	scrivx := filepath.Join(s.config.ScrivPath, "sample.scrivx")
+	data, _ := os.ReadFile(scrivx)
+	sections := `<SectionTypes>
+        <SectionType ID="ST-CHAPTER"><Title>Chapter</Title></SectionType>
+        <SectionType ID="ST-SCENE"><Title>Scene</Title></SectionType>
+    </SectionTypes>
+</ScrivenerProject>`
+	os.WriteFile(scrivx, []byte(strings.Replace(string(data), "</ScrivenerProject>", sections, 1)), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	rtfPath := filepath.Join(s.scrivPath, "Files", "Data", "DOC-UUID-0001", "content.rtf")
+	rtfBefore, _ := os.ReadFile(rtfPath)
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	md, _ := os.ReadFile(chapterOne)
+	os.WriteFile(chapterOne, []byte("---\nsection_type: Scene\n---\n\n"+string(md)), 0644)
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if got := s.writer.GetSectionType("DOC-UUID-0001"); got != "ST-SCENE" {
+		t.Errorf("Expected the section type pushed, got %q", got)
+	}
+	if rtfAfter, _ := os.ReadFile(rtfPath); string(rtfAfter) != string(rtfBefore) {
+		t.Errorf("Expected the document's RTF left alone by a front-matter-only change, got:\n%s", rtfAfter)
+	}
+
+	// A changed body is pushed in full
+	md, _ = os.ReadFile(chapterOne)
+	os.WriteFile(chapterOne, []byte(strings.Replace(string(md), "begins here", "starts here", 1)), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if rtfAfter, _ := os.ReadFile(rtfPath); !strings.Contains(string(rtfAfter), "starts here") {
+		t.Errorf("Expected the body pushed, got:\n%s", rtfAfter)
+	}
+
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync, got: %s", plan.Summary())
+	}
+}
+
+// TestMirror_ExportsBinder tests that mirror renders the binder as a markdown
+// tree without touching sync state, and only replaces its own exports.
+func TestMirror_ExportsBinder(t *testing.T) {
+	s := newTestSyncer(t, config.DefaultOptions())
+	folderData := filepath.Join(s.config.ScrivPath, "Files", "Data", "FOLDER-UUID-0001")
+	os.MkdirAll(folderData, 0755)
+	os.WriteFile(filepath.Join(folderData, "synopsis.txt"), []byte("Everyone in the story.\n"), 0644)
+	s = reloadSyncer(t, s)
+
+	out := filepath.Join(t.TempDir(), "export")
+	m := &mirror{reader: s.reader}
+	if err := m.export(out); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	for _, path := range []string{"draft/index.md", "draft/chapter-one.md", "draft/chapter-two.md", "research/characters/hero.md"} {
+		if !fileExists(filepath.Join(out, path)) {
+			t.Errorf("Expected %s in the export", path)
+		}
+	}
+	if directoryExists(filepath.Join(out, "trash")) {
+		t.Error("Expected Trash to be left out of the export")
+	}
+	index, _ := os.ReadFile(filepath.Join(out, "research", "characters", "index.md"))
+	if string(index) != "# Characters\n\nEveryone in the story.\n" {
+		t.Errorf("Unexpected folder index:\n%s", index)
+	}
+	if fileExists(s.state.filePath) {
+		t.Error("Expected mirror not to write sync state")
+	}
+
+	// A previous export is replaced; any other non-empty directory is refused
+	os.WriteFile(filepath.Join(out, "draft", "stale.md"), []byte("old"), 0644)
+	if err := m.export(out); err != nil {
+		t.Fatalf("Second mirror failed: %v", err)
+	}
+	if fileExists(filepath.Join(out, "draft", "stale.md")) {
+		t.Error("Expected the previous export to be replaced")
+	}
+
+	other := t.TempDir()
+	os.WriteFile(filepath.Join(other, "notes.md"), []byte("mine"), 0644)
+	if err := m.export(other); err == nil {
+		t.Error("Expected mirror to refuse a directory it did not write")
+	}
+}
+
+// TestSync_MultipleScrivenerProjects tests that one alias syncs mappings
+// with several Scrivener projects, each with its own state.
+func TestSync_MultipleScrivenerProjects(t *testing.T) {
+	novel := config.FolderMapping{MarkdownDir: "novel", ScrivenerFolder: "Draft", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), novel)
+
+	storiesPath := filepath.Join(filepath.Dir(s.config.ScrivPath), "stories.scriv")
+	copyDir(t, s.config.ScrivPath, storiesPath)
+	s.config.ScrivProjects = map[string]string{"stories": storiesPath}
+	s.config.FolderMappings = append(s.config.FolderMappings,
+		config.FolderMapping{MarkdownDir: "shortstories", ScrivenerFolder: "Draft", SyncEnabled: true, Project: "stories"})
+	s = reloadSyncer(t, s)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	for _, dir := range []string{"novel", "shortstories"} {
+		if !fileExists(filepath.Join(s.mdRoot, dir, "chapter-one.md")) {
+			t.Errorf("Expected %s/chapter-one.md to be pulled", dir)
+		}
+	}
+	storiesState, _ := config.StatePath(config.StateName("test", "stories"))
+	if !fileExists(s.state.filePath) || !fileExists(storiesState) {
+		t.Error("Expected a state file per Scrivener project")
+	}
+
+	// An edit under shortstories/ goes only to the stories project
+	os.WriteFile(filepath.Join(s.mdRoot, "shortstories", "chapter-one.md"), []byte("A short story."), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	stories, _ := scrivener.NewReader(storiesPath)
+	if doc, _ := stories.GetDocument("DOC-UUID-0001"); doc == nil || !strings.Contains(doc.Content, "A short story.") {
+		t.Error("Expected the edit in the stories project")
+	}
+	primary, _ := scrivener.NewReader(s.config.ScrivPath)
+	if doc, _ := primary.GetDocument("DOC-UUID-0001"); doc == nil || strings.Contains(doc.Content, "A short story.") {
+		t.Error("Expected the novel project to be unchanged")
+	}
+
+	// Overlapping markdown directories of different projects are refused
+	s.config.FolderMappings[1].MarkdownDir = "novel/stories"
+	if _, err := NewSyncer(s.config, s.alias); err == nil {
+		t.Error("Expected overlapping mappings to be refused")
+	}
+}
+
+// TestSync_AbsoluteMarkdownDir tests that a mapping can sync a directory
+// outside the markdown root.
+func TestSync_AbsoluteMarkdownDir(t *testing.T) {
+	wiki := filepath.Join(t.TempDir(), "research-wiki")
+	draft := config.FolderMapping{MarkdownDir: "novel", ScrivenerFolder: "Draft", SyncEnabled: true}
+	research := config.FolderMapping{MarkdownDir: wiki, ScrivenerFolder: "Characters", SyncEnabled: true}
+	s := newTestSyncer(t, config.DefaultOptions(), draft, research)
+
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !fileExists(filepath.Join(s.mdRoot, "novel", "chapter-one.md")) || !fileExists(filepath.Join(wiki, "hero.md")) {
+		t.Fatal("Expected documents pulled into both markdown roots")
+	}
+
+	os.WriteFile(filepath.Join(wiki, "villain.md"), []byte("The villain."), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	s = reloadSyncer(t, s)
+	characters, _ := s.reader.FindFolderByPath("Characters")
+	if characters == nil || matchByTitle(characters.Children, "Villain") == nil {
+		t.Error("Expected villain.md to be created in the Characters folder")
+	}
+
+	if got := s.rootFor(filepath.Join(wiki, "hero.md")); got != wiki {
+		t.Errorf("Expected files in the wiki to be archived under it, got root %s", got)
+	}
+}
+
+// TestSync_SkippedConflictQuarantined tests that both versions of a skipped
+// conflict are saved until the conflict is resolved.
+func TestSync_SkippedConflictQuarantined(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.DefaultConflictResolution = "skip"
+	s := newTestSyncer(t, opts, draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	os.WriteFile(chapterOne, []byte("Markdown edit."), 0644)
+	if err := s.writer.UpdateDocumentContent("DOC-UUID-0001", "Scrivener edit.", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	mdCopy, scrivCopy := s.quarantinePaths(chapterOne)
+	if want := filepath.Join(s.mdRoot, ".scriv-sync", "conflicts", "draft", "chapter-one.markdown.md"); mdCopy != want {
+		t.Errorf("Expected markdown version at %s, got %s", want, mdCopy)
+	}
+	if data, _ := os.ReadFile(mdCopy); string(data) != "Markdown edit." {
+		t.Errorf("Unexpected quarantined markdown version: %q", data)
+	}
+	if data, _ := os.ReadFile(scrivCopy); !strings.Contains(string(data), "Scrivener edit.") {
+		t.Errorf("Unexpected quarantined Scrivener version: %q", data)
+	}
+
+	// Status points at the saved versions
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0].Quarantine != filepath.Dir(mdCopy) {
+		t.Errorf("Expected the conflict to point at its quarantine, got %+v", plan.Conflicts)
+	}
+
+	// Resolving the conflict removes them
+	s.config.Options.DefaultConflictResolution = "markdown"
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if fileExists(mdCopy) || fileExists(scrivCopy) {
+		t.Error("Expected quarantined versions to be removed once resolved")
+	}
+}
+
+// TestSync_ResumeInterruptedSync tests that --resume reuses the decisions
+// and completed operations of an interrupted sync.
+func TestSync_ResumeInterruptedSync(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.DefaultConflictResolution = "skip"
+	s := newTestSyncer(t, opts, draft)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Both documents change on both sides
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	chapterTwo := filepath.Join(s.mdRoot, "draft", "chapter-two.md")
+	os.WriteFile(chapterOne, []byte("Markdown one."), 0644)
+	os.WriteFile(chapterTwo, []byte("Markdown two."), 0644)
+	s.writer.UpdateDocumentContent("DOC-UUID-0001", "Scrivener one.", true)
+	s.writer.UpdateDocumentContent("DOC-UUID-0002", "Scrivener two.", true)
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted run chose markdown for chapter two and had already
+	// pulled chapter one from Scrivener
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	journal, _ := newJournal(s.stateName(), s.contentHash)
+	for _, c := range plan.Conflicts {
+		if c.MarkdownPath == chapterOne {
+			scriv, _ := c.scrivenerContent()
+			os.WriteFile(chapterOne, []byte(scriv), 0644)
+			journal.Completed[chapterOne] = s.contentHash(scriv)
+		} else {
+			journal.recordConflict(c, "markdown")
+		}
+	}
+	plan.Close()
+	if err := journal.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	s.SetResume(true)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	for _, path := range []string{chapterOne, chapterTwo} {
+		if mdCopy, _ := s.quarantinePaths(path); fileExists(mdCopy) {
+			t.Errorf("Expected %s not to be skipped as a conflict", path)
+		}
+	}
+	s = reloadSyncer(t, s)
+	if doc, _ := s.reader.GetDocument("DOC-UUID-0002"); doc == nil || !strings.Contains(doc.Content, "Markdown two.") {
+		t.Error("Expected the recorded markdown resolution for chapter two")
+	}
+	if fileExists(journal.path) {
+		t.Error("Expected the journal to be removed after the sync")
+	}
+	plan, err = s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected nothing to sync, got: %s", plan.Summary())
+	}
+}
+
+// TestSync_ImportedDocumentsReadOnly tests that imported DOCX documents are
+// pulled through pandoc only when enabled, and never pushed.
+func TestSync_ImportedDocumentsReadOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of pandoc")
+	}
+	characters := config.FolderMapping{MarkdownDir: "characters", ScrivenerFolder: "Characters", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	s := newTestSyncer(t, opts, characters)
+
+	folderUUID, _ := s.writer.FindFolderByTitle("Characters")
+	uuid, err := s.writer.CreateDocument("Interview", "", folderUUID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	dataDir := filepath.Join(s.scrivPath, "Files", "Data", uuid)
+	os.Remove(filepath.Join(dataDir, "content.rtf"))
+	docx := filepath.Join(dataDir, "content.docx")
+	os.WriteFile(docx, []byte("DOCX BYTES"), 0644)
+
+	// Without pandoc_imports the document is skipped
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	interview := filepath.Join(s.mdRoot, "characters", "interview.md")
+	if fileExists(interview) {
+		t.Fatal("Expected the imported document to be skipped without pandoc_imports")
+	}
+
+	// A stand-in pandoc converts it on pull
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "pandoc"), []byte("#!/bin/sh\necho 'Imported interview.'\n"), 0755)
+	t.Setenv("PATH", bin)
+	s.config.Options.PandocImports = true
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if data, _ := os.ReadFile(interview); !strings.Contains(string(data), "Imported interview.") {
+		t.Fatalf("Expected the converted document, got %q", data)
+	}
+
+	// Markdown edits are never written back
+	os.WriteFile(interview, []byte("Edited in markdown."), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if data, _ := os.ReadFile(docx); string(data) != "DOCX BYTES" {
+		t.Errorf("Expected the DOCX file untouched, got %q", data)
+	}
+	if fileExists(filepath.Join(dataDir, "content.rtf")) {
+		t.Error("Expected no RTF written next to the DOCX file")
+	}
+	if err := s.writer.UpdateDocumentContent(uuid, "x", true); err == nil {
+		t.Error("Expected the writer to refuse an imported document")
+	}
+}
+
+// TestSync_BinderDecorations tests that icons and labels are shown as front
+// matter, set from it on push, and listed in the binder index.
+func TestSync_BinderDecorations(t *testing.T) {
+	draft := config.FolderMapping{MarkdownDir: "draft", ScrivenerFolder: "Draft", SyncEnabled: true}
+	opts := config.DefaultOptions()
+	opts.Decorations = config.DecorationsFrontMatter
+	s := newTestSyncer(t, opts, draft)
+	s.writer.SetIcon("DOC-UUID-0001", "Flag")
+	s.writer.SetLabel("DOC-UUID-0001", "0")
+	if err := s.writer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	chapterOne := filepath.Join(s.mdRoot, "draft", "chapter-one.md")
+	data, _ := os.ReadFile(chapterOne)
+	for _, want := range []string{"icon: Flag\n", "label: Red\n", "label_color: '#fdb3bb'\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in front matter, got:\n%s", want, data)
+		}
+	}
+
+	// Changing the icon and removing the label in markdown is pushed
+	fm, body, _ := splitFrontMatter(string(data))
+	fm.Icon, fm.Label, fm.LabelColor = "Star", "", ""
+	os.WriteFile(chapterOne, []byte(joinFrontMatter(fm, body)), 0644)
+	s = reloadSyncer(t, s)
+	if err := s.Sync(false, false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if icon := s.writer.GetIcon("DOC-UUID-0001"); icon != "Star" {
+		t.Errorf("Expected icon Star, got %q", icon)
+	}
+	if label := s.writer.GetLabel("DOC-UUID-0001"); label != "" {
+		t.Errorf("Expected the label removed, got %q", label)
+	}
+
+	s = reloadSyncer(t, s)
+	plan, err := s.detectAllChanges()
+	if err != nil {
+		t.Fatalf("Failed to detect changes: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("Expected everything in sync, got: %s", plan.Summary())
+	}
+
+	// The index lists the binder with its decorations
+	s.config.Options.Decorations = config.DecorationsIndex
+	s = reloadSyncer(t, s)
+	if err := s.Pull(false, false); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	index, _ := os.ReadFile(filepath.Join(s.mdRoot, BinderIndexFileName))
+	if !strings.Contains(string(index), "  - [Chapter One](draft/chapter-one.md) · icon: Star\n") {
+		t.Errorf("Unexpected binder index:\n%s", index)
+	}
+}
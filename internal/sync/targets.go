@@ -0,0 +1,213 @@
+package sync
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// TargetUpdate holds the project targets to change; nil fields are left as
+// they are.
+type TargetUpdate struct {
+	Draft    *int
+	Session  *int
+	Deadline *time.Time // the zero time removes the deadline
+}
+
+// empty reports whether the update changes nothing.
+func (u TargetUpdate) empty() bool {
+	return u.Draft == nil && u.Session == nil && u.Deadline == nil
+}
+
+// ParseDeadline parses a deadline given as YYYY-MM-DD, meaning the end of that
+// day in local time, or "none" to remove it (returned as the zero time).
+func ParseDeadline(s string) (time.Time, error) {
+	if s == "none" {
+		return time.Time{}, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q: use YYYY-MM-DD or none", s)
+	}
+	return day.Add(24*time.Hour - time.Second), nil
+}
+
+// RunTarget prints the project targets of a Scrivener project of an alias
+// ("" for its scriv_path project) and applies update to them. Only the target
+// elements of the .scrivx are rewritten; the rest is saved as it was read.
+func RunTarget(alias, project string, update TargetUpdate, dryRun bool) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+	scrivPath, err := projCfg.ScrivProjectPath(project)
+	if err != nil {
+		return err
+	}
+
+	if !update.empty() && !dryRun {
+		if err := updateTargets(scrivPath, update); err != nil {
+			return err
+		}
+	}
+
+	reader, err := scrivener.NewReader(scrivPath)
+	if err != nil {
+		return fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+	}
+	targets := reader.Targets()
+	fmt.Println(scrivPath)
+	if dryRun && !update.empty() {
+		fmt.Println("  (dry run: the targets are shown as they would be, nothing was saved)")
+		targets = update.applyTo(targets)
+	}
+	printTargets(targets)
+	return nil
+}
+
+// updateTargets writes an update of the project targets to a project.
+func updateTargets(scrivPath string, update TargetUpdate) error {
+	writer, err := scrivener.NewWriter(scrivPath)
+	if err != nil {
+		return fmt.Errorf("failed to open Scrivener project for writing: %w", err)
+	}
+	if update.Draft != nil {
+		if err := writer.SetDraftTarget(*update.Draft); err != nil {
+			return err
+		}
+	}
+	if update.Session != nil {
+		if err := writer.SetSessionTarget(*update.Session); err != nil {
+			return err
+		}
+	}
+	if update.Deadline != nil {
+		writer.SetDeadline(*update.Deadline)
+	}
+	return writer.Save()
+}
+
+// applyTo returns targets as they will be after the update.
+func (u TargetUpdate) applyTo(t scrivener.Targets) scrivener.Targets {
+	if u.Draft != nil {
+		t.Draft = *u.Draft
+	}
+	if u.Session != nil {
+		t.Session = *u.Session
+	}
+	if u.Deadline != nil {
+		t.Deadline = *u.Deadline
+	}
+	return t
+}
+
+// printTargets prints project targets.
+func printTargets(t scrivener.Targets) {
+	fmt.Printf("  Draft target:    %s\n", describeTarget(t.Draft, t.DraftType))
+	fmt.Printf("  Session target:  %s\n", describeTarget(t.Session, t.SessionType))
+	deadline := "(none)"
+	if !t.Deadline.IsZero() {
+		deadline = t.Deadline.Format("2006-01-02")
+	}
+	fmt.Printf("  Deadline:        %s\n", deadline)
+}
+
+// describeTarget renders a target as "50000 words", or "(none)" if unset.
+func describeTarget(n int, targetType string) string {
+	if n == 0 {
+		return "(none)"
+	}
+	if targetType == scrivener.TargetCharacters {
+		return fmt.Sprintf("%d characters", n)
+	}
+	return fmt.Sprintf("%d words", n)
+}
+
+// draftCount counts the words, or characters, of the documents in the Draft
+// folder, as the draft target does.
+func (s *Syncer) draftCount(t scrivener.Targets) (int, error) {
+	docs, err := s.reader.GetBinderStructure()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	var walk func(docs []*scrivener.Document, inDraft bool)
+	walk = func(docs []*scrivener.Document, inDraft bool) {
+		for _, doc := range docs {
+			draft := inDraft || doc.ItemType == "DraftFolder"
+			if draft && (doc.IncludeInCompile || !t.CountIncludedOnly || doc.ItemType == "DraftFolder") {
+				if err := s.reader.LoadContent(doc); err == nil {
+					if t.DraftType == scrivener.TargetCharacters {
+						count += utf8.RuneCountInString(doc.Content)
+					} else {
+						count += countWords(doc.Content)
+					}
+				}
+			}
+			walk(doc.Children, draft)
+		}
+	}
+	walk(docs, false)
+	return count, nil
+}
+
+// printTargetProgress prints progress towards the project targets under the
+// daily stats: the draft against its target and deadline, and today's words
+// against the session target.
+func (s *Syncer) printTargetProgress(stats []DailyStats, now time.Time) error {
+	t := s.reader.Targets()
+	if t.Draft == 0 && t.Session == 0 {
+		return nil
+	}
+	fmt.Println()
+
+	if t.Draft > 0 {
+		count, err := s.draftCount(t)
+		if err != nil {
+			return err
+		}
+		unit := "words"
+		if t.DraftType == scrivener.TargetCharacters {
+			unit = "characters"
+		}
+		fmt.Printf("Draft:    %d / %d %s (%d%%)", count, t.Draft, unit, count*100/t.Draft)
+		if !t.Deadline.IsZero() {
+			days := int(math.Ceil(t.Deadline.Sub(now).Hours() / 24))
+			switch {
+			case days <= 0:
+				fmt.Printf(", deadline %s passed", t.Deadline.Format("2006-01-02"))
+			case count < t.Draft:
+				fmt.Printf(", %d day(s) to %s: %d %s a day", days, t.Deadline.Format("2006-01-02"), (t.Draft-count+days-1)/days, unit)
+			default:
+				fmt.Printf(", %d day(s) to %s", days, t.Deadline.Format("2006-01-02"))
+			}
+		}
+		fmt.Println()
+	}
+
+	if t.Session > 0 && t.SessionType == scrivener.TargetWords {
+		today := 0
+		for _, day := range stats {
+			if day.Date == now.Format("2006-01-02") {
+				today = day.DraftWords + day.OtherWords
+				if day.MarkdownWritten != nil && *day.MarkdownWritten > 0 {
+					today += *day.MarkdownWritten
+				}
+			}
+		}
+		fmt.Printf("Session:  %d / %d words today (%d%%)\n", today, t.Session, today*100/t.Session)
+	}
+	return nil
+}
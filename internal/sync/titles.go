@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// leadingNumberRe matches a filename starting with a number, such as
+// 07-the-fall, capturing the number without its leading zeros and the rest.
+var leadingNumberRe = regexp.MustCompile(`^0*(\d+)[-_. ]+(.+)$`)
+
+// docTitle converts the name of a file or directory in mdDir to a title,
+// applying the title rules of its mapping and then title_aliases.
+func (s *Syncer) docTitle(mdDir, filename string) string {
+	rules := s.titlesForDir[mdDir]
+	if rules.IsZero() {
+		return s.scrivenerAlias(s.titleFor(filename))
+	}
+
+	n := ""
+	if rules.Numbered() {
+		if m := leadingNumberRe.FindStringSubmatch(filename); m != nil {
+			n, filename = m[1], m[2]
+		}
+	}
+	return s.scrivenerAlias(expandAffix(rules.Prefix, n) + s.titleFor(filename) + expandAffix(rules.Suffix, n))
+}
+
+// docFilename converts a title to the base name of its file or directory in
+// mdDir, undoing title_aliases and then the title rules of its mapping.
+func (s *Syncer) docFilename(mdDir, title string) string {
+	title = s.markdownAlias(title)
+	rules := s.titlesForDir[mdDir]
+	if rules.IsZero() {
+		return s.filenameFor(title)
+	}
+
+	n := ""
+	if rest, num, ok := s.cutAffix(title, rules.Prefix, true); ok {
+		title, n = rest, num
+	}
+	if rest, num, ok := s.cutAffix(title, rules.Suffix, false); ok {
+		title = rest
+		if num != "" {
+			n = num
+		}
+	}
+	name := s.filenameFor(title)
+	if n != "" {
+		v, _ := strconv.Atoi(n)
+		name = fmt.Sprintf("%0*d-%s", rules.NumberWidth, v, name)
+	}
+	return name
+}
+
+// sameDocName reports whether a tracked file's base name still belongs to a
+// document titled title, ignoring a leading number the rules strip.
+func (s *Syncer) sameDocName(mdDir, base, title string) bool {
+	name := s.docFilename(mdDir, title)
+	if strings.EqualFold(base, name) {
+		return true
+	}
+	if rules := s.titlesForDir[mdDir]; rules.StripNumbers {
+		if m := leadingNumberRe.FindStringSubmatch(base); m != nil {
+			return strings.EqualFold(m[2], name)
+		}
+	}
+	return false
+}
+
+// expandAffix returns a prefix or suffix with {n} replaced by the number n.
+// One using {n} is left out when there is no number.
+func expandAffix(affix, n string) string {
+	if !strings.Contains(affix, "{n}") {
+		return affix
+	}
+	if n == "" {
+		return ""
+	}
+	return strings.ReplaceAll(affix, "{n}", n)
+}
+
+// cutAffix removes a prefix (or suffix) from title, returning the rest and
+// the number {n} matched, if any. Affixes match regardless of case unless
+// title_case is sensitive.
+func (s *Syncer) cutAffix(title, affix string, prefix bool) (string, string, bool) {
+	if affix == "" {
+		return title, "", false
+	}
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(affix), regexp.QuoteMeta("{n}"), `(\d+)`)
+	if prefix {
+		pattern = "^" + pattern
+	} else {
+		pattern += "$"
+	}
+	if !s.caseSensitive() {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return title, "", false
+	}
+	loc := re.FindStringSubmatchIndex(title)
+	if loc == nil || loc[1]-loc[0] == len(title) {
+		return title, "", false
+	}
+	n := ""
+	if len(loc) > 2 && loc[2] >= 0 {
+		n = title[loc[2]:loc[3]]
+	}
+	return title[:loc[0]] + title[loc[1]:], n, true
+}
+
+// setTitleRules records the title rules of the mapping a markdown
+// directory belongs to.
+func (s *Syncer) setTitleRules(mdDir string, rules config.TitleRules) {
+	if s.titlesForDir == nil {
+		s.titlesForDir = make(map[string]config.TitleRules)
+	}
+	s.titlesForDir[mdDir] = rules
+}
+
+// scrivenerAlias returns the Scrivener title title_aliases pairs with a
+// markdown title, or the title itself if it has no alias.
+func (s *Syncer) scrivenerAlias(title string) string {
+	for md, scriv := range s.config.Options.TitleAliases {
+		if s.titleKey(md) == s.titleKey(title) {
+			return strings.TrimSpace(scriv)
+		}
+	}
+	return title
+}
+
+// markdownAlias returns the markdown title title_aliases pairs with a
+// Scrivener title, or the title itself if it has no alias.
+func (s *Syncer) markdownAlias(title string) string {
+	for md, scriv := range s.config.Options.TitleAliases {
+		if s.titleKey(strings.TrimSpace(scriv)) == s.titleKey(title) {
+			return md
+		}
+	}
+	return title
+}
@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// TOCFileName is the table of contents kept at the markdown root with toc set.
+const TOCFileName = "TOC.md"
+
+// tocEntry is a binder item in the table of contents: synced itself, or
+// holding synced items.
+type tocEntry struct {
+	title    string
+	path     string // synced markdown file, relative to the markdown root; "" if none
+	words    int    // of its file and those of its children
+	synopsis string
+	children []tocEntry
+}
+
+// writeTOC regenerates TOC.md from the binder: the synced documents in
+// binder order and hierarchy, each linked to its markdown file with its word
+// count, and with toc: synopses its synopsis.
+func (s *Syncer) writeTOC() error {
+	// Only the scriv_path project's binder is listed
+	if s.config.Options.TOC == "" || s.project != "" {
+		return nil
+	}
+
+	docs, err := s.reader.GetBinderStructure()
+	if err != nil {
+		return err
+	}
+	var entries []tocEntry
+	total := 0
+	for _, doc := range docs {
+		if doc.IsTrash() {
+			continue
+		}
+		if entry, ok := s.tocEntry(doc); ok {
+			entries = append(entries, entry)
+			total += entry.words
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Table of Contents\n\n")
+	b.WriteString("<!-- Generated by scriv-sync from the Scrivener binder. -->\n\n")
+	s.renderTOC(&b, entries, 0)
+	fmt.Fprintf(&b, "\n%s in total\n", wordCount(total))
+	toc := b.String()
+
+	tocPath := filepath.Join(s.mdRoot, TOCFileName)
+	existing, _ := os.ReadFile(tocPath)
+	if string(existing) == toc {
+		return nil
+	}
+	if err := os.WriteFile(tocPath, []byte(toc), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tocPath, err)
+	}
+	fmt.Printf("  Updated %s\n", tocPath)
+	return nil
+}
+
+// isTOC reports whether the file name in dir is the generated TOC.md, which
+// is never synced as a document.
+func (s *Syncer) isTOC(dir, name string) bool {
+	return s.config.Options.TOC != "" && name == TOCFileName && filepath.Clean(dir) == filepath.Clean(s.mdRoot)
+}
+
+// tocEntry returns the table of contents entry of a binder item, and false
+// if neither it nor anything under it is synced.
+func (s *Syncer) tocEntry(doc *scrivener.Document) (tocEntry, bool) {
+	entry := tocEntry{title: doc.Title, synopsis: s.reader.Synopsis(doc.UUID)}
+	if mdPath := s.state.GetPathForUUID(doc.UUID); mdPath != "" {
+		rel, err := filepath.Rel(s.mdRoot, mdPath)
+		if err != nil {
+			rel = mdPath
+		}
+		entry.path = filepath.ToSlash(rel)
+		entry.words = s.fileWords(mdPath)
+	}
+	for _, child := range doc.Children {
+		if c, ok := s.tocEntry(child); ok {
+			entry.children = append(entry.children, c)
+			entry.words += c.words
+		}
+	}
+	return entry, entry.path != "" || len(entry.children) > 0
+}
+
+// renderTOC writes entries as nested list items indented by depth.
+func (s *Syncer) renderTOC(b *strings.Builder, entries []tocEntry, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, entry := range entries {
+		title := entry.title
+		if entry.path != "" {
+			title = fmt.Sprintf("[%s](%s)", entry.title, tocLink(entry.path))
+		}
+		fmt.Fprintf(b, "%s- %s (%s)\n", indent, title, wordCount(entry.words))
+		if s.config.Options.TOC == config.TOCSynopses && entry.synopsis != "" {
+			for _, line := range strings.Split(strings.TrimRight(entry.synopsis, "\n"), "\n") {
+				fmt.Fprintf(b, "%s  > %s\n", indent, line)
+			}
+		}
+		s.renderTOC(b, entry.children, depth+1)
+	}
+}
+
+// tocLink escapes the characters of a relative path that would end a
+// markdown link early.
+func tocLink(path string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(path)
+}
+
+// wordCount formats a number of words, e.g. "1 word" or "250 words".
+func wordCount(n int) string {
+	if n == 1 {
+		return "1 word"
+	}
+	return fmt.Sprintf("%d words", n)
+}
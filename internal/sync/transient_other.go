@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package sync
+
+import "syscall"
+
+// transientErrnos is empty where no errors are known to be transient.
+var transientErrnos []syscall.Errno
@@ -0,0 +1,17 @@
+//go:build unix
+
+package sync
+
+import "syscall"
+
+// transientErrnos are the errors cloud-synced filesystems return while the
+// sync client holds a file: busy, stale NFS-style handles, and the
+// "resource deadlock avoided" macOS gives for a file being downloaded.
+var transientErrnos = []syscall.Errno{
+	syscall.EBUSY,
+	syscall.ESTALE,
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.ETIMEDOUT,
+	syscall.EDEADLK,
+}
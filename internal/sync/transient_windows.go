@@ -0,0 +1,12 @@
+//go:build windows
+
+package sync
+
+import "syscall"
+
+// transientErrnos are the errors Windows returns while a cloud sync client
+// or another program has a file open: sharing and lock violations.
+var transientErrnos = []syscall.Errno{
+	32, // ERROR_SHARING_VIOLATION
+	33, // ERROR_LOCK_VIOLATION
+}
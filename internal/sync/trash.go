@@ -0,0 +1,160 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ArchiveDirName is the directory under the markdown root (or under a mapping
+// directory outside it) that receives markdown files removed with the
+// archive-dir deletion style.
+const ArchiveDirName = ".scriv-sync-archive"
+
+// removeMarkdownFile removes a markdown file according to the configured
+// deletion style, always archiving it in safe mode, and returns a
+// description of where it went. With rtf_passthrough, the RTF kept beside
+// it goes the same way.
+func (s *Syncer) removeMarkdownFile(path string) (string, error) {
+	sidecar := rtfSidecar(path)
+	delete(s.sidecars, sidecar)
+	if s.config.Options.RTFPassthrough && sidecar != path && fileExists(sidecar) {
+		if _, err := s.removeMarkdownFile(sidecar); err != nil {
+			return "", err
+		}
+	}
+	style := s.config.Options.DeletionStyle
+	if s.safe() {
+		style = ""
+	}
+	switch style {
+	case "hard":
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		return "deleted", nil
+	case "trash":
+		dest, err := moveToTrash(path)
+		if err != nil {
+			return "", err
+		}
+		return "moved to trash: " + dest, nil
+	default:
+		dest, err := archiveFile(s.rootFor(path), path, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return "archived to " + dest, nil
+	}
+}
+
+// archiveFile moves path into <root>/.scriv-sync-archive/<timestamp>/,
+// preserving its path relative to root.
+func archiveFile(root, path string, now time.Time) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+
+	dest := filepath.Join(root, ArchiveDirName, now.Format("20060102-150405"), rel)
+	if err := moveFile(path, dest); err != nil {
+		return "", fmt.Errorf("failed to archive %s: %w", path, err)
+	}
+	return dest, nil
+}
+
+// moveToTrash moves path into the system trash. macOS uses ~/.Trash; other
+// Unix systems use the freedesktop.org trash. Windows falls back to an error
+// so callers can choose a different style.
+func moveToTrash(path string) (string, error) {
+	return moveToTrashOn(runtime.GOOS, path)
+}
+
+// moveToTrashOn moves path into the trash of the operating system goos.
+func moveToTrashOn(goos, path string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	switch goos {
+	case "darwin":
+		dest := uniquePath(filepath.Join(home, ".Trash", filepath.Base(path)))
+		if err := moveFile(path, dest); err != nil {
+			return "", fmt.Errorf("failed to move %s to trash: %w", path, err)
+		}
+		return dest, nil
+	case "windows":
+		return "", fmt.Errorf("trash deletion style is not supported on Windows; use archive-dir")
+	default:
+		trashDir := filepath.Join(home, ".local", "share", "Trash")
+		if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+			trashDir = filepath.Join(xdg, "Trash")
+		}
+
+		dest := uniquePath(filepath.Join(trashDir, "files", filepath.Base(path)))
+		absPath, _ := filepath.Abs(path)
+		info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", absPath, time.Now().Format("2006-01-02T15:04:05"))
+		infoPath := filepath.Join(trashDir, "info", filepath.Base(dest)+".trashinfo")
+		if err := os.MkdirAll(filepath.Dir(infoPath), 0755); err != nil {
+			return "", fmt.Errorf("failed to create trash directory: %w", err)
+		}
+		if err := os.WriteFile(infoPath, []byte(info), 0644); err != nil {
+			return "", fmt.Errorf("failed to write trash info: %w", err)
+		}
+		if err := moveFile(path, dest); err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to move %s to trash: %w", path, err)
+		}
+		return dest, nil
+	}
+}
+
+// moveFile renames src to dst, falling back to copy and remove when the
+// rename crosses filesystems.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// uniquePath returns path, or path with a numeric suffix if it already exists.
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Defaults of the truncation guard when truncation_guard leaves them unset.
+const (
+	defaultTruncationShrink   = 80
+	defaultTruncationMinWords = 100
+)
+
+// Truncation is an update that would replace a substantial document with
+// empty or far shorter content.
+type Truncation struct {
+	MarkdownPath string `json:"markdown_path"`
+	ScrivUUID    string `json:"scriv_uuid,omitempty"`
+	Title        string `json:"title"`
+	Location     string `json:"location"` // side overwritten: "scrivener" or "markdown"
+	Before       int    `json:"words_before"`
+	After        int    `json:"words_after"`
+}
+
+// SetAllowTruncation lets updates that empty or drastically shrink a
+// document run without asking, as --allow-truncation does.
+func (s *Syncer) SetAllowTruncation(allow bool) {
+	s.allowTruncation = allow
+	for _, linked := range s.linked {
+		linked.allowTruncation = allow
+	}
+}
+
+// truncationThresholds returns the share of its words, as a percentage, a
+// document must lose to be guarded, and the fewest words it must have had;
+// shrink is -1 when the guard is off.
+func (s *Syncer) truncationThresholds() (shrink, minWords int) {
+	g := s.config.Options.TruncationGuard
+	shrink, minWords = g.Shrink, g.MinWords
+	if shrink == 0 {
+		shrink = defaultTruncationShrink
+	}
+	if minWords == 0 {
+		minWords = defaultTruncationMinWords
+	}
+	return shrink, minWords
+}
+
+// findTruncations returns the updates of a plan that would make a document
+// of at least min_words lose shrink percent or more of its words.
+func (s *Syncer) findTruncations(plan *Plan) ([]Truncation, error) {
+	shrink, minWords := s.truncationThresholds()
+	if shrink < 0 {
+		return nil, nil
+	}
+	var found []Truncation
+	check := func(fc FileChange, location string, before int) error {
+		if before < minWords {
+			return nil
+		}
+		content, err := fc.content()
+		if err != nil {
+			return err
+		}
+		after := countWords(content)
+		if (before-after)*100 >= before*shrink {
+			found = append(found, Truncation{
+				MarkdownPath: fc.MarkdownPath,
+				ScrivUUID:    fc.ScrivUUID,
+				Title:        fc.Title,
+				Location:     location,
+				Before:       before,
+				After:        after,
+			})
+		}
+		return nil
+	}
+	for _, fc := range plan.ToUpdateInScriv {
+		if err := check(fc, "scrivener", s.scrivenerWordsBefore(fc.ScrivUUID)); err != nil {
+			return nil, err
+		}
+	}
+	for _, fc := range plan.ToUpdateInMarkdown {
+		if err := check(fc, "markdown", markdownWordsBefore(fc.MarkdownPath)); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// confirmTruncations asks before each update of a plan that would empty or
+// drastically shrink a document, even in non-interactive mode, since that is
+// more often an editor crash or a conversion bug than an edit. Updates not
+// confirmed are taken out of the plan, so both sides keep their content and
+// the update is offered again next run. --allow-truncation skips the
+// questions.
+func (s *Syncer) confirmTruncations(plan *Plan) error {
+	if s.allowTruncation {
+		return nil
+	}
+	truncations, err := s.findTruncations(plan)
+	if err != nil || len(truncations) == 0 {
+		return err
+	}
+
+	in := s.input
+	if in == nil {
+		in = os.Stdin
+	}
+	reader := bufio.NewReader(in)
+	held := make(map[string]bool)
+	for _, t := range truncations {
+		fmt.Printf("\n%s would shrink from %d to %d words in %s.\n", t.MarkdownPath, t.Before, t.After, sideName(t.Location))
+		fmt.Print("Apply this update? [y/N]: ")
+		input, _ := reader.ReadString('\n')
+		switch strings.TrimSpace(strings.ToLower(input)) {
+		case "y", "yes":
+			continue
+		}
+		held[t.Location+"\x00"+t.MarkdownPath] = true
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	keep := func(changes []FileChange, location string) []FileChange {
+		var kept []FileChange
+		for _, fc := range changes {
+			if !held[location+"\x00"+fc.MarkdownPath] {
+				kept = append(kept, fc)
+			}
+		}
+		return kept
+	}
+	plan.ToUpdateInScriv = keep(plan.ToUpdateInScriv, "scrivener")
+	plan.ToUpdateInMarkdown = keep(plan.ToUpdateInMarkdown, "markdown")
+	fmt.Printf("\n%d update(s) held back; rerun with --allow-truncation to apply them.\n", len(held))
+	return nil
+}
+
+// sideName names the side a location refers to.
+func sideName(location string) string {
+	if location == "scrivener" {
+		return "Scrivener"
+	}
+	return "markdown"
+}
+
+// printTruncations lists the updates that would empty or drastically shrink
+// a document.
+func (p *Plan) printTruncations() {
+	if len(p.Truncations) == 0 {
+		return
+	}
+	fmt.Println("\nUpdates that would shrink a document (confirmed before applying):")
+	for _, t := range p.Truncations {
+		fmt.Printf("  ! %s: %d -> %d words in %s\n", t.MarkdownPath, t.Before, t.After, sideName(t.Location))
+	}
+}
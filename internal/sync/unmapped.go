@@ -0,0 +1,290 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// Unmapped is a Scrivener document outside every mapped folder, which the
+// sync doesn't pick up unless options.unmapped_dir is set.
+type Unmapped struct {
+	ScrivUUID  string `json:"scriv_uuid"`
+	Title      string `json:"title"`
+	BinderPath string `json:"binder_path"` // binder folder holding the document
+}
+
+// unmappedDocuments returns the text documents of the binder that no enabled
+// mapping covers, with the binder path of the folder holding each. Trash is
+// left out, and so are items a mapping excludes on purpose.
+func (s *Syncer) unmappedDocuments() ([]*scrivener.Document, map[string]string, error) {
+	covered := make(map[string]bool) // UUID -> synced or excluded by a mapping
+	var coverTree func(docs []*scrivener.Document)
+	coverTree = func(docs []*scrivener.Document) {
+		for _, doc := range docs {
+			covered[doc.UUID] = true
+			coverTree(doc.Children)
+		}
+	}
+
+	for _, mapping := range s.config.MappingsForProject(s.project) {
+		if mapping.ScrivenerFolder == "/" && mapping.IsRecursive() {
+			return nil, nil, nil
+		}
+		folder, err := s.reader.FindFolderByPath(mapping.ScrivenerFolder)
+		if err != nil {
+			continue
+		}
+		if mapping.IsRecursive() {
+			coverTree(folder.Children)
+			continue
+		}
+		for _, doc := range folder.Children {
+			covered[doc.UUID] = true
+			if mapping.ExcludesTitle(doc.Title) {
+				coverTree(doc.Children)
+			}
+		}
+	}
+
+	binder, err := s.reader.GetBinderStructure()
+	if err != nil {
+		return nil, nil, err
+	}
+	var docs []*scrivener.Document
+	folders := make(map[string]string) // UUID -> binder path of its folder
+	var walk func(items []*scrivener.Document, path string)
+	walk = func(items []*scrivener.Document, path string) {
+		for _, doc := range items {
+			if doc.IsTrash() {
+				continue
+			}
+			if !covered[doc.UUID] && !doc.IsFolder() && !doc.Unconverted && doc.ItemType == "Text" {
+				docs = append(docs, doc)
+				folders[doc.UUID] = path
+			}
+			walk(doc.Children, path+"/"+doc.Title)
+		}
+	}
+	walk(binder, "")
+	return docs, folders, nil
+}
+
+// unmappedDir returns the markdown directory unmapped documents are pulled
+// into, or "" when they aren't. Linked projects each get a subdirectory.
+func (s *Syncer) unmappedDir() string {
+	dir := s.config.Options.UnmappedDir
+	if dir == "" {
+		return ""
+	}
+	dir = config.ExpandPath(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.config.MarkdownPath(), dir)
+	}
+	if s.project != "" {
+		dir = filepath.Join(dir, sanitizeFilename(s.project))
+	}
+	return filepath.Clean(dir)
+}
+
+// detectUnmapped adds the documents outside every mapped folder, and the
+// markdown directories outside every mapping, to the plan.
+// Without an unmapped directory they are only listed; with one they sync to
+// it like a mapped folder, except that new markdown files there are never
+// created in Scrivener, as there is no folder to put them in.
+func (s *Syncer) detectUnmapped(plan *Plan) error {
+	plan.UnmappedDirs = s.unmappedMarkdownDirs()
+
+	docs, folders, err := s.unmappedDocuments()
+	if err != nil {
+		return err
+	}
+	list := func(doc *scrivener.Document) {
+		path := folders[doc.UUID]
+		if path == "" {
+			path = "/"
+		}
+		plan.Unmapped = append(plan.Unmapped, Unmapped{ScrivUUID: doc.UUID, Title: doc.Title, BinderPath: path})
+	}
+
+	dir := s.unmappedDir()
+	if dir == "" {
+		for _, doc := range docs {
+			list(doc)
+		}
+		return nil
+	}
+
+	// Documents sharing a title are listed rather than collected when
+	// duplicate_titles is error
+	if dups := s.duplicateTitles(docs); len(dups) > 0 && s.config.Options.DuplicateTitles == "error" {
+		var kept []*scrivener.Document
+		for _, doc := range docs {
+			if _, dup := dups[s.titleKey(doc.Title)]; dup {
+				list(doc)
+			} else {
+				kept = append(kept, doc)
+			}
+		}
+		docs = kept
+	}
+
+	mdFiles, err := s.getMarkdownFiles(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var known []string
+	for _, mdPath := range mdFiles {
+		title := s.scrivenerAlias(s.titleFor(filepath.Base(mdPath)))
+		if s.state.WasPreviouslySynced(mdPath) || s.matchTitle(docs, title) != nil {
+			known = append(known, mdPath)
+		}
+	}
+	return s.detectChangesInDir(dir, "unmapped documents", docs, known, plan)
+}
+
+// printUnmapped lists the documents outside every mapped folder.
+func (p *Plan) printUnmapped() {
+	if len(p.Unmapped) == 0 {
+		return
+	}
+	fmt.Println("\nUnmapped documents (outside every mapped folder, not synced):")
+	for _, u := range p.Unmapped {
+		fmt.Printf("  - %s (%s, %s)\n", u.Title, u.BinderPath, u.ScrivUUID)
+	}
+}
+
+// UnmappedDir is a markdown directory outside every mapping, whose files
+// never sync.
+type UnmappedDir struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+}
+
+// unmappedMarkdownDirs returns the subdirectories of the markdown root that
+// hold markdown files but lie outside every mapping's directory. Disabled
+// mappings count as covering their directory, as do hidden directories, the
+// unmapped documents directory and route directories. Only the alias's first
+// Syncer looks, as the markdown root is shared by its linked projects.
+func (s *Syncer) unmappedMarkdownDirs() []UnmappedDir {
+	if s.project != "" {
+		return nil
+	}
+	root := s.config.MarkdownPath()
+	covered := make(map[string]bool)
+	for _, mapping := range s.config.FolderMappings {
+		covered[s.config.MappingDir(mapping)] = true
+	}
+	if dir := s.unmappedDir(); dir != "" {
+		covered[dir] = true
+	}
+	for _, route := range s.config.Options.Routes {
+		covered[s.routeDir(route)] = true
+	}
+	if covered[root] {
+		return nil
+	}
+
+	var dirs []UnmappedDir
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != root && (covered[path] || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".scriv")) {
+			return filepath.SkipDir
+		}
+		if path == root {
+			return nil
+		}
+		if files, _, err := s.listMarkdownDir(path); err == nil && len(files) > 0 {
+			dirs = append(dirs, UnmappedDir{Path: path, Files: len(files)})
+		}
+		return nil
+	})
+	return dirs
+}
+
+// printUnmappedDirs lists the markdown directories outside every mapping.
+func (p *Plan) printUnmappedDirs() {
+	if len(p.UnmappedDirs) == 0 {
+		return
+	}
+	files := 0
+	for _, d := range p.UnmappedDirs {
+		files += d.Files
+	}
+	fmt.Printf("\n%d file(s) in unmapped directories (not synced):\n", files)
+	for _, d := range p.UnmappedDirs {
+		fmt.Printf("  - %s/ (%d)\n", d.Path, d.Files)
+	}
+}
+
+// offerUnmappedDirs asks, for each markdown directory outside every mapping,
+// whether to map it to a new folder in Scrivener's Draft.
+func (s *Syncer) offerUnmappedDirs(interactive bool) error {
+	dirs := s.unmappedMarkdownDirs()
+	if !interactive || len(dirs) == 0 {
+		return nil
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for _, d := range dirs {
+		folder := "Draft/" + s.titleFor(filepath.Base(d.Path))
+		question := fmt.Sprintf("%s/ holds %d unsynced file(s). Create Scrivener folder '%s' and map it", d.Path, d.Files, folder)
+		if !promptYesNo(in, question, false) {
+			continue
+		}
+		if err := s.mapMarkdownDir(d.Path, folder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapMarkdownDir creates a Scrivener folder at folderPath and adds a mapping
+// from the markdown directory dir to it, saving both. The reader is
+// reopened so the sync sees the new folder.
+func (s *Syncer) mapMarkdownDir(dir, folderPath string) error {
+	mapping := config.FolderMapping{MarkdownDir: dir, ScrivenerFolder: folderPath, SyncEnabled: true}
+	if rel, err := filepath.Rel(s.config.MarkdownPath(), dir); err == nil && !strings.HasPrefix(rel, "..") {
+		mapping.MarkdownDir = filepath.ToSlash(rel)
+	}
+
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	proj, err := globalCfg.GetProject(s.alias)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.writer.CreateFolderPath(folderPath); err != nil {
+		return fmt.Errorf("failed to create Scrivener folder '%s': %w", folderPath, err)
+	}
+	if err := s.writer.Save(); err != nil {
+		return fmt.Errorf("failed to save Scrivener project: %w", err)
+	}
+
+	proj.FolderMappings = append(proj.FolderMappings, mapping)
+	if err := globalCfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	s.config.FolderMappings = append(s.config.FolderMappings, mapping)
+
+	reader, err := newReader(s.scrivPath, s.config.Options)
+	if err != nil {
+		return err
+	}
+	reader.SetSkipContent(s.skipUnchanged)
+	s.reader = reader
+
+	fmt.Printf("  Mapped %s/ to '%s'\n", mapping.MarkdownDir, folderPath)
+	return nil
+}
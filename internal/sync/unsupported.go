@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// Unsupported is a Scrivener binder item with no text to sync, such as a
+// PDF, image or web archive. It is left alone on both sides.
+type Unsupported struct {
+	ScrivUUID string `json:"scriv_uuid"`
+	Title     string `json:"title"`
+	ItemType  string `json:"item_type"` // the binder item type, e.g. "PDF" or "WebArchive"
+}
+
+// splitUnsupported separates the items with no text to sync from the
+// documents of a folder.
+func splitUnsupported(docs []*scrivener.Document) (supported, unsupported []*scrivener.Document) {
+	for _, doc := range docs {
+		if doc.Unsupported {
+			unsupported = append(unsupported, doc)
+		} else {
+			supported = append(supported, doc)
+		}
+	}
+	return supported, unsupported
+}
+
+// addUnsupported lists items with no text to sync in the plan, once each.
+func (p *Plan) addUnsupported(docs []*scrivener.Document) {
+	for _, doc := range docs {
+		listed := false
+		for _, u := range p.Unsupported {
+			if u.ScrivUUID == doc.UUID {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			p.Unsupported = append(p.Unsupported, Unsupported{ScrivUUID: doc.UUID, Title: doc.Title, ItemType: doc.ItemType})
+		}
+	}
+}
+
+// unsupportedReason is why a new markdown file sharing its title with an
+// item with no text to sync is skipped rather than created beside it.
+func unsupportedReason(doc *scrivener.Document) string {
+	return fmt.Sprintf("Scrivener's '%s' is a %s item, which isn't synced", doc.Title, doc.ItemType)
+}
+
+// printUnsupported lists the Scrivener items left out of the sync, with a
+// count of each type.
+func (p *Plan) printUnsupported() {
+	if len(p.Unsupported) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	for _, u := range p.Unsupported {
+		counts[u.ItemType]++
+	}
+	types := make([]string, 0, len(counts))
+	for itemType := range counts {
+		types = append(types, itemType)
+	}
+	sort.Strings(types)
+	parts := make([]string, len(types))
+	for i, itemType := range types {
+		parts[i] = fmt.Sprintf("%d %s", counts[itemType], itemType)
+	}
+	fmt.Printf("\nScrivener items with no text (not synced: %s):\n", strings.Join(parts, ", "))
+	for _, u := range p.Unsupported {
+		fmt.Printf("  - %s (%s)\n", u.Title, u.ItemType)
+	}
+}
@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// RunVerify checks the integrity of each Scrivener project of an alias and
+// prints what it finds. It returns an error if any project has errors.
+func RunVerify(alias string) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range append([]string{""}, projCfg.ScrivProjectNames()...) {
+		scrivPath, err := projCfg.ScrivProjectPath(name)
+		if err != nil {
+			return err
+		}
+		errs, err := verifyProject(scrivPath)
+		if err != nil {
+			return err
+		}
+		if errs > 0 {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d Scrivener project(s) failed verification", failed)
+	}
+	return nil
+}
+
+// verifyProject verifies one Scrivener project, printing its issues, and
+// returns the number of errors.
+func verifyProject(scrivPath string) (int, error) {
+	reader, err := scrivener.NewReader(scrivPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+	}
+	issues, err := reader.Verify()
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("%s\n", scrivPath)
+	if len(issues) == 0 {
+		fmt.Println("  OK")
+		return 0, nil
+	}
+
+	errs := 0
+	for _, issue := range issues {
+		if issue.Severity == scrivener.IssueError {
+			errs++
+		}
+		fmt.Printf("  %s\n", issue)
+	}
+	fmt.Printf("  %d error(s), %d warning(s)\n", errs, len(issues)-errs)
+	return errs, nil
+}
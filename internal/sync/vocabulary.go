@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sweiss/harcroft/internal/config"
+	"github.com/sweiss/harcroft/internal/scrivener"
+)
+
+// Vocabulary kinds managed by RunVocabulary, RunAddVocabulary and
+// RunRenameVocabulary.
+const (
+	VocabLabel  = "label"
+	VocabStatus = "status"
+)
+
+// vocabEntries returns the titles and colors defined for a vocabulary kind.
+func vocabEntries(reader *scrivener.Reader, kind string) []scrivener.Label {
+	if kind == VocabLabel {
+		return reader.Labels()
+	}
+	var entries []scrivener.Label
+	for _, s := range reader.Statuses() {
+		entries = append(entries, scrivener.Label{ID: s.ID, Title: s.Title})
+	}
+	return entries
+}
+
+// forEachScrivProject calls fn with the path of each Scrivener project of an
+// alias, scriv_path first.
+func forEachScrivProject(alias string, fn func(scrivPath string) error) error {
+	globalCfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	projCfg, err := globalCfg.GetProject(alias)
+	if err != nil {
+		return err
+	}
+	projCfg, err = projCfg.WithLocalOverrides()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range append([]string{""}, projCfg.ScrivProjectNames()...) {
+		scrivPath, err := projCfg.ScrivProjectPath(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(scrivPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunVocabulary lists the labels or statuses defined in each Scrivener
+// project of an alias.
+func RunVocabulary(alias, kind string) error {
+	return forEachScrivProject(alias, func(scrivPath string) error {
+		reader, err := scrivener.NewReader(scrivPath)
+		if err != nil {
+			return fmt.Errorf("failed to open Scrivener project for reading: %w", err)
+		}
+		fmt.Println(scrivPath)
+		entries := vocabEntries(reader, kind)
+		if len(entries) == 0 {
+			fmt.Printf("  (no %ss)\n", kind)
+		}
+		for _, e := range entries {
+			if e.Color != "" {
+				fmt.Printf("  %s  %s (%s)\n", e.ID, e.Title, e.Color)
+			} else {
+				fmt.Printf("  %s  %s\n", e.ID, e.Title)
+			}
+		}
+		return nil
+	})
+}
+
+// RunAddVocabulary defines a label (with an optional "#rrggbb" color) or a
+// status in each Scrivener project of an alias that doesn't have it yet, so
+// markdown front matter can refer to it.
+func RunAddVocabulary(alias, kind, title, color string, dryRun bool) error {
+	if kind == VocabStatus && color != "" {
+		return fmt.Errorf("statuses have no color")
+	}
+	return forEachScrivProject(alias, func(scrivPath string) error {
+		writer, err := scrivener.NewWriter(scrivPath)
+		if err != nil {
+			return fmt.Errorf("failed to open Scrivener project for writing: %w", err)
+		}
+		var id string
+		if kind == VocabLabel {
+			id, err = writer.AddLabel(title, color)
+		} else {
+			id, err = writer.AddStatus(title)
+		}
+		if err != nil {
+			if errors.Is(err, scrivener.ErrVocabExists) {
+				fmt.Printf("%s: %s\n", scrivPath, err)
+				return nil
+			}
+			return err
+		}
+		if dryRun {
+			fmt.Printf("%s: would add %s %q (ID %s)\n", scrivPath, kind, title, id)
+			return nil
+		}
+		if err := writer.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: added %s %q (ID %s)\n", scrivPath, kind, title, id)
+		return nil
+	})
+}
+
+// RunRenameVocabulary renames a label or status in each Scrivener project of
+// an alias that defines it. Documents keep it, as they refer to it by ID;
+// markdown front matter naming the old title must be updated.
+func RunRenameVocabulary(alias, kind, oldTitle, newTitle string, dryRun bool) error {
+	renamed := 0
+	err := forEachScrivProject(alias, func(scrivPath string) error {
+		writer, err := scrivener.NewWriter(scrivPath)
+		if err != nil {
+			return fmt.Errorf("failed to open Scrivener project for writing: %w", err)
+		}
+		if kind == VocabLabel {
+			err = writer.RenameLabel(oldTitle, newTitle)
+		} else {
+			err = writer.RenameStatus(oldTitle, newTitle)
+		}
+		if err != nil {
+			if errors.Is(err, scrivener.ErrVocabNotFound) {
+				fmt.Printf("%s: %s\n", scrivPath, err)
+				return nil
+			}
+			return err
+		}
+		renamed++
+		if dryRun {
+			fmt.Printf("%s: would rename %s %q to %q\n", scrivPath, kind, oldTitle, newTitle)
+			return nil
+		}
+		if err := writer.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: renamed %s %q to %q\n", scrivPath, kind, oldTitle, newTitle)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if renamed == 0 {
+		return fmt.Errorf("no Scrivener project of '%s' defines %s %q", alias, kind, oldTitle)
+	}
+	return nil
+}
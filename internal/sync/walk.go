@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sweiss/harcroft/internal/config"
+)
+
+// getMarkdownFiles returns all .md files in a directory tree, skipping hidden
+// directories such as the deletion archive. Symlinks are treated as the
+// symlinks option says; a symlinked directory already walked is skipped, so
+// links back up the tree can't loop.
+func (s *Syncer) getMarkdownFiles(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	var files []string
+	visited := make(map[string]bool)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			if visited[real] {
+				return nil
+			}
+			visited[real] = true
+		}
+		dirFiles, subdirs, err := s.listMarkdownDir(dir)
+		if err != nil {
+			return err
+		}
+		files = append(files, dirFiles...)
+		for _, d := range subdirs {
+			if err := walk(filepath.Join(dir, d)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return files, walk(dir)
+}
+
+// listMarkdownDir returns the .md files and the subdirectory names directly
+// inside dir. Hidden directories (such as the deletion archive) and .scriv
+// packages are skipped, and so are symlinks the symlinks option leaves out.
+func (s *Syncer) listMarkdownDir(dir string) ([]string, []string, error) {
+	var entries []os.DirEntry
+	err := withRetry(func() (err error) {
+		entries, err = os.ReadDir(dir)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ext := s.extensionFor(dir)
+	var files, subdirs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		isDir, ok := s.entryKind(dir, entry)
+		if !ok {
+			continue
+		}
+		if isDir {
+			if !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, ".scriv") {
+				subdirs = append(subdirs, name)
+			}
+			continue
+		}
+		if strings.HasSuffix(name, ext) && !isIndexFile(name) && !s.isTOC(dir, name) && name != s.config.Options.FolderNotes {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	return files, subdirs, nil
+}
+
+// entryKind reports whether a directory entry is a directory, resolving
+// symlinks as the symlinks option says. It reports false for entries to
+// leave out: broken symlinks, and symlinks the option doesn't follow.
+func (s *Syncer) entryKind(dir string, entry os.DirEntry) (isDir, ok bool) {
+	if entry.Type()&os.ModeSymlink == 0 {
+		return entry.IsDir(), true
+	}
+	mode := s.config.Options.Symlinks
+	if mode == config.SymlinksSkip {
+		return false, false
+	}
+	info, err := os.Stat(filepath.Join(dir, entry.Name()))
+	if err != nil {
+		return false, false
+	}
+	if info.IsDir() && mode != config.SymlinksFollow {
+		return false, false
+	}
+	return info.IsDir(), true
+}
+
+// icloudStub returns the path of the stub iCloud Drive leaves in place of a
+// file it has evicted: ".name.md.icloud" next to where "name.md" was.
+func icloudStub(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".icloud")
+}
+
+// isCloudPlaceholder reports whether a markdown file is a cloud placeholder
+// whose content has not been downloaded: an evicted iCloud file, or a file
+// the system marks as dataless. Reading one would see no content, or block
+// while it downloads.
+func isCloudPlaceholder(path string) bool {
+	if fileExists(icloudStub(path)) {
+		return true
+	}
+	info, err := os.Lstat(path)
+	return err == nil && isDataless(info)
+}
@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// WebhookEvent is the event of every webhook request: a sync, pull, push or
+// apply finished running its plan.
+const WebhookEvent = "sync.completed"
+
+// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+// request body, keyed with the webhook secret, when one is set.
+const WebhookSignatureHeader = "X-Scriv-Sync-Signature"
+
+// webhookTimeout bounds how long a sync waits for the webhook to answer.
+var webhookTimeout = 10 * time.Second
+
+// WebhookPayload is the JSON body posted to the webhook. Text and Content
+// hold the same one-line description, the fields Slack and Discord incoming
+// webhooks display.
+type WebhookPayload struct {
+	Event    string   `json:"event"`
+	Text     string   `json:"text"`
+	Content  string   `json:"content"`
+	Summary  *Summary `json:"summary"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// describe returns a one-line description of a run.
+func (sm *Summary) describe() string {
+	name := sm.Alias
+	if sm.Project != "" {
+		name += " (" + sm.Project + ")"
+	}
+	line := fmt.Sprintf("scriv-sync %s: Scrivener %d created, %d updated; markdown %d created, %d updated; words +%d/-%d",
+		name, sm.CreatedInScrivener, sm.UpdatedInScrivener, sm.CreatedInMarkdown, sm.UpdatedInMarkdown, sm.WordsAdded, sm.WordsRemoved)
+	if len(sm.Conflicts) > 0 {
+		line += "; conflicts " + countsList(sm.Conflicts)
+	}
+	if sm.Failed > 0 {
+		line += fmt.Sprintf("; %d failed", sm.Failed)
+	}
+	return line
+}
+
+// notifyWebhook posts the summary of a completed run to the configured
+// webhook, if any.
+func (s *Syncer) notifyWebhook(summary *Summary, failures []Failure) error {
+	hook := s.config.Options.Webhook
+	if hook.URL == "" {
+		return nil
+	}
+
+	payload := WebhookPayload{Event: WebhookEvent, Summary: summary}
+	payload.Text = summary.describe()
+	payload.Content = payload.Text
+	for _, f := range failures {
+		payload.Failures = append(payload.Failures, fmt.Sprintf("%s %s: %v", f.Operation, s.historyKey(f.Path), f.Err))
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scriv-sync")
+	if secret := os.ExpandEnv(hook.Secret); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
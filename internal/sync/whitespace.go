@@ -0,0 +1,51 @@
+package sync
+
+import "strings"
+
+// autoResolvedWhitespace is how the summary counts conflicts resolved
+// because the two sides differ only in whitespace.
+const autoResolvedWhitespace = "auto-resolved (whitespace)"
+
+// whitespaceResolution returns the side whitespace_wins picks for a conflict
+// whose two versions differ only in trailing whitespace, blank lines or line
+// endings, and false for any other conflict or with whitespace_wins: prompt.
+func (s *Syncer) whitespaceResolution(conflict Conflict) (string, bool) {
+	side := s.config.Options.WhitespaceWins
+	if side == "" {
+		side = "scrivener"
+	}
+	if side == "prompt" {
+		return "", false
+	}
+	md, err := conflict.markdownContent()
+	if err != nil {
+		return "", false
+	}
+	scriv, err := conflict.scrivenerContent()
+	if err != nil {
+		return "", false
+	}
+	if !sameIgnoringWhitespace(s.normalize(md), s.normalize(scriv)) {
+		return "", false
+	}
+	return side, true
+}
+
+// sameIgnoringWhitespace reports whether two texts differ only in trailing
+// whitespace, the number of blank lines, and line endings.
+func sameIgnoringWhitespace(a, b string) bool {
+	return whitespaceSkeleton(a) == whitespaceSkeleton(b)
+}
+
+// whitespaceSkeleton returns text with LF line endings, without trailing
+// whitespace and without blank lines.
+func whitespaceSkeleton(text string) string {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRight(line, " \t"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,89 @@
+// Package timestamp parses, formats and compares the timestamps scriv-sync
+// reads and writes: RFC 3339 in its state files, and Scrivener's
+// "2006-01-02 15:04:05 -0700" in project files. Timestamps from different
+// machines may be in different time zones and come from clocks that
+// disagree slightly, so comparisons allow for a little skew.
+package timestamp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BinderLayout is the format of the Created and Modified attributes of
+// binder items, and of other timestamps in project files.
+const BinderLayout = "2006-01-02 15:04:05 -0700"
+
+// Skew is how far apart two timestamps may be and still be the same moment:
+// enough for clocks that drift apart and file systems that keep times to
+// the second or two.
+const Skew = 2 * time.Second
+
+// layouts are the formats Parse accepts, in the order tried. Those without a
+// zone are read in local time, as Scrivener writes them.
+var layouts = []string{
+	time.RFC3339Nano,
+	BinderLayout,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// Parse parses a timestamp in any of the formats scriv-sync or Scrivener
+// write. The zone it was written in is kept.
+func Parse(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty timestamp")
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %q", s)
+}
+
+// Format formats a timestamp for the state file: RFC 3339 in UTC, so the
+// same moment reads the same whichever machine recorded it.
+func Format(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// FormatBinder formats a timestamp for a project file, in local time as
+// Scrivener writes it.
+func FormatBinder(t time.Time) string {
+	return t.Local().Format(BinderLayout)
+}
+
+// Canonical returns a timestamp string in UTC RFC 3339 with fractional
+// seconds, or s unchanged if it doesn't parse, so the same moment written in
+// different zones compares equal.
+func Canonical(s string) string {
+	t, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Equal reports whether two timestamps are the same moment, within Skew.
+func Equal(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d <= Skew && d >= -Skew
+}
+
+// After reports whether a is later than b by more than Skew.
+func After(a, b time.Time) bool {
+	return a.Sub(b) > Skew
+}
+
+// Since returns how long before now t was. A time in the future, as a
+// machine whose clock runs ahead may record, counts as now.
+func Since(t, now time.Time) time.Duration {
+	if d := now.Sub(t); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	want := time.Date(2026, 3, 14, 17, 30, 0, 0, time.UTC)
+	for _, s := range []string{
+		"2026-03-14T17:30:00Z",
+		"2026-03-14T10:30:00-07:00",
+		"2026-03-15T03:30:00+10:00",
+		"2026-03-14T17:30:00.000000000Z",
+		"2026-03-14 10:30:00 -0700",
+		"2026-03-14 18:30:00 +0100",
+		"2026-03-14 17:30:00Z",
+	} {
+		got, err := Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", s, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("Parse(%q) = %v, want %v", s, got.UTC(), want)
+		}
+	}
+
+	for _, s := range []string{"", "yesterday", "2026-13-01 00:00:00 +0000"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): expected an error", s)
+		}
+	}
+}
+
+func TestParse_LocalWithoutZone(t *testing.T) {
+	saved := time.Local
+	time.Local = time.FixedZone("AEST", 10*60*60)
+	defer func() { time.Local = saved }()
+
+	got, err := Parse("2026-03-15 03:30:00")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := time.Date(2026, 3, 14, 17, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected a timestamp without a zone read in local time, got %v", got.UTC())
+	}
+}
+
+func TestFormat_RoundTripsAcrossZones(t *testing.T) {
+	saved := time.Local
+	defer func() { time.Local = saved }()
+
+	moment := time.Date(2026, 3, 14, 17, 30, 0, 0, time.UTC)
+	var state, binder []string
+	for _, zone := range []*time.Location{time.UTC, time.FixedZone("PDT", -7*60*60), time.FixedZone("IST", 5*60*60+30*60)} {
+		time.Local = zone
+		state = append(state, Format(moment.In(zone)))
+		binder = append(binder, FormatBinder(moment))
+	}
+
+	for i, s := range state {
+		if s != "2026-03-14T17:30:00Z" {
+			t.Errorf("Format in zone %d = %q, want UTC", i, s)
+		}
+	}
+	for _, s := range binder {
+		got, err := Parse(s)
+		if err != nil || !got.Equal(moment) {
+			t.Errorf("Parse(FormatBinder) of %q = %v, %v; want %v", s, got, err, moment)
+		}
+	}
+	if binder[1] != "2026-03-14 10:30:00 -0700" {
+		t.Errorf("Expected the binder timestamp in local time, got %q", binder[1])
+	}
+	if Canonical(binder[1]) != Canonical(binder[2]) {
+		t.Errorf("Expected the same moment in two zones to be canonically equal: %q, %q", Canonical(binder[1]), Canonical(binder[2]))
+	}
+	if Canonical("not a time") != "not a time" {
+		t.Error("Expected an unparsable timestamp left as it is")
+	}
+}
+
+func TestCompare_Skew(t *testing.T) {
+	base := time.Date(2026, 3, 14, 17, 30, 0, 0, time.UTC)
+	tokyo := base.In(time.FixedZone("JST", 9*60*60))
+
+	if !Equal(base, tokyo) || !Equal(base, base.Add(Skew)) || !Equal(base.Add(Skew), base) {
+		t.Error("Expected times within the skew to be equal")
+	}
+	if Equal(base, base.Add(Skew+time.Second)) {
+		t.Error("Expected times beyond the skew to differ")
+	}
+	if After(base.Add(time.Second), tokyo) {
+		t.Error("Expected a time within the skew not to be after")
+	}
+	if !After(base.Add(time.Minute), tokyo) || After(tokyo, base.Add(time.Minute)) {
+		t.Error("Expected After to order times beyond the skew")
+	}
+
+	if got := Since(base, base.Add(time.Hour)); got != time.Hour {
+		t.Errorf("Since = %v, want 1h", got)
+	}
+	if got := Since(base.Add(time.Hour), base); got != 0 {
+		t.Errorf("Expected a time in the future to count as now, got %v", got)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build darwin
+
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainService and keychainAccount name the key's keychain item.
+const (
+	keychainService = "scriv-sync"
+	keychainAccount = "state-key"
+)
+
+// keychainLookup returns the secret stored in the login keychain, or "" if
+// there is none.
+func keychainLookup() (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+		return "", nil // errSecItemNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the key from the keychain: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainStore stores the secret in the login keychain.
+func keychainStore(secret string) error {
+	cmd := exec.Command("security", "add-generic-password", "-s", keychainService, "-a", keychainAccount, "-l", "scriv-sync state key", "-w", secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store the key in the keychain: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainService and keychainAccount are the attributes of the key's
+// Secret Service item.
+const (
+	keychainService = "scriv-sync"
+	keychainAccount = "state-key"
+)
+
+// keychainLookup returns the secret stored with the Secret Service, through
+// secret-tool, or "" if there is none.
+func keychainLookup() (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", fmt.Errorf("secret-tool not found; install libsecret-tools or set %s", KeyEnvVar)
+	}
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(out) == 0 {
+		return "", nil // no such item
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the key from the keyring: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainStore stores the secret with the Secret Service.
+func keychainStore(secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=scriv-sync state key", "service", keychainService, "account", keychainAccount)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store the key in the keyring: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
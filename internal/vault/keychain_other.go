@@ -0,0 +1,16 @@
+//go:build !darwin && !linux
+
+package vault
+
+import "fmt"
+
+// keychainLookup fails: only the macOS keychain and the Linux Secret Service
+// are supported, so elsewhere the key comes from SCRIV_SYNC_KEY.
+func keychainLookup() (string, error) {
+	return "", fmt.Errorf("no supported keychain on this system; set %s", KeyEnvVar)
+}
+
+// keychainStore fails, as keychainLookup does.
+func keychainStore(secret string) error {
+	return fmt.Errorf("no supported keychain on this system; set %s", KeyEnvVar)
+}
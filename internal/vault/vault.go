@@ -0,0 +1,154 @@
+// Package vault encrypts the files scriv-sync keeps about a project, such as
+// its sync state, with AES-256-GCM under a key from the OS keychain or the
+// SCRIV_SYNC_KEY environment variable.
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeyEnvVar is the environment variable holding a passphrase to use as the
+// key instead of the keychain's, for machines without one and for scripts.
+const KeyEnvVar = "SCRIV_SYNC_KEY"
+
+// magic starts every sealed file, so sealed and plain files can be told
+// apart and a file is never opened with the wrong format.
+var magic = []byte("scriv-sync sealed v1\n")
+
+// ErrNoKey is returned when a sealed file must be opened but no key is
+// available.
+var ErrNoKey = errors.New("no encryption key: set " + KeyEnvVar + " or add one to the OS keychain")
+
+// IsSealed reports whether data is a sealed file.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts data with key, a 32-byte AES-256 key.
+func Seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append(append([]byte(nil), magic...), nonce...)
+	return gcm.Seal(out, nonce, data, magic), nil
+}
+
+// Open decrypts a sealed file with key. It fails if the file was sealed
+// with another key or has been altered.
+func Open(key, sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, fmt.Errorf("not a sealed file")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := sealed[len(magic):]
+	if len(body) < gcm.NonceSize() {
+		return nil, fmt.Errorf("sealed file is truncated")
+	}
+	data, err := gcm.Open(nil, body[:gcm.NonceSize()], body[gcm.NonceSize():], magic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: wrong key or damaged file")
+	}
+	return data, nil
+}
+
+// newGCM returns AES-256-GCM for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// DeriveKey turns a passphrase or stored secret into a 32-byte key.
+func DeriveKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+var (
+	keyMu  sync.Mutex
+	cached []byte
+)
+
+// Key returns the key files are sealed with: derived from SCRIV_SYNC_KEY
+// when it is set, or else from the secret kept in the OS keychain. With
+// create, a random secret is generated and stored in the keychain when it
+// has none; otherwise ErrNoKey is returned.
+func Key(create bool) ([]byte, error) {
+	if secret := os.Getenv(KeyEnvVar); secret != "" {
+		return DeriveKey(secret), nil
+	}
+
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	secret, err := keychainLookup()
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		if !create {
+			return nil, ErrNoKey
+		}
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		secret = hex.EncodeToString(raw)
+		if err := keychainStore(secret); err != nil {
+			return nil, err
+		}
+	}
+	cached = DeriveKey(strings.TrimSpace(secret))
+	return cached, nil
+}
+
+// Read reads a file, opening it if it is sealed.
+func Read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !IsSealed(data) {
+		return data, err
+	}
+	key, err := Key(false)
+	if err != nil {
+		return nil, fmt.Errorf("%s is encrypted: %w", path, err)
+	}
+	data, err = Open(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Encode returns data sealed when encrypt is set, and as it is otherwise.
+func Encode(data []byte, encrypt bool) ([]byte, error) {
+	if !encrypt {
+		return data, nil
+	}
+	key, err := Key(true)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(key, data)
+}
@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := DeriveKey("correct horse")
+	plain := []byte(`{"files":{"/notes/chapter-one.md":{}}}`)
+
+	sealed, err := Seal(key, plain)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) || IsSealed(plain) {
+		t.Error("Expected only the sealed data to be recognised as sealed")
+	}
+	if bytes.Contains(sealed, []byte("chapter-one")) {
+		t.Error("Expected no plaintext in the sealed data")
+	}
+
+	opened, err := Open(key, sealed)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("Expected %q, got %q", plain, opened)
+	}
+
+	if _, err := Open(DeriveKey("wrong"), sealed); err == nil {
+		t.Error("Expected opening with another key to fail")
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := Open(key, tampered); err == nil {
+		t.Error("Expected opening altered data to fail")
+	}
+	if _, err := Open(key, plain); err == nil {
+		t.Error("Expected opening plain data to fail")
+	}
+}
+
+func TestEncodeRead(t *testing.T) {
+	t.Setenv(KeyEnvVar, "test passphrase")
+	dir := t.TempDir()
+
+	plain := []byte("# Report\n")
+	for _, encrypt := range []bool{false, true} {
+		data, err := Encode(plain, encrypt)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if IsSealed(data) != encrypt {
+			t.Errorf("encrypt=%v: expected sealed=%v", encrypt, encrypt)
+		}
+		path := filepath.Join(dir, "file")
+		os.WriteFile(path, data, 0644)
+		read, err := Read(path)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if !bytes.Equal(read, plain) {
+			t.Errorf("encrypt=%v: expected %q, got %q", encrypt, plain, read)
+		}
+	}
+
+	if _, err := Read(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+}